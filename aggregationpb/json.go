@@ -0,0 +1,35 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregationpb
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// MarshalJSON encodes the combined metrics using the protobuf JSON mapping,
+// with the JSON names of the fields, e.g. `serviceMetrics`. The protobuf
+// JSON output is intentionally unstable in its whitespace, it is compacted
+// so that the encoding is stable across runs for equal combined metrics.
+func (m *CombinedMetrics) MarshalJSON() ([]byte, error) {
+	b, err := protojson.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, b); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON decodes the combined metrics encoded by MarshalJSON, or
+// any other encoding using the protobuf JSON mapping, e.g. with the
+// original protobuf field names. Unknown fields are rejected.
+func (m *CombinedMetrics) UnmarshalJSON(b []byte) error {
+	return protojson.Unmarshal(b, m)
+}
@@ -9,15 +9,22 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math/rand"
+	"runtime/debug"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/cespare/xxhash/v2"
 	"github.com/cockroachdb/pebble"
 	"github.com/cockroachdb/pebble/vfs"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+	"golang.org/x/time/rate"
 
 	"github.com/elastic/apm-aggregation/aggregationpb"
 	"github.com/elastic/apm-aggregation/aggregators/internal/telemetry"
@@ -34,8 +41,49 @@ var (
 	// ErrAggregatorClosed means that aggregator was closed when the
 	// method was called and thus cannot be processed further.
 	ErrAggregatorClosed = errors.New("aggregator is closed")
+	// ErrBackpressure means that the aggregator has more pending bytes,
+	// not yet committed to the database, than the configured maximum and
+	// is not configured to block until the pending bytes are committed.
+	ErrBackpressure = errors.New("aggregator pending bytes limit exceeded")
+	// ErrAggregatorPaused means that the aggregator was paused, see
+	// Aggregator.Pause, when the method was called.
+	ErrAggregatorPaused = errors.New("aggregator is paused")
+	// ErrRateLimited means that the events exceeded the ingest rate limit
+	// of their combined metrics ID, see WithIngestRateLimit. The returned
+	// error is a *RateLimitedError.
+	ErrRateLimited = errors.New("ingest rate limit exceeded")
 )
 
+// StaleProcessingTimeError is returned by AggregateCombinedMetrics when
+// the processing time of the combined metrics is older than the replay
+// horizon configured by WithReplayHorizon.
+type StaleProcessingTimeError struct {
+	ProcessingTime time.Time
+	Horizon        time.Duration
+}
+
+func (e *StaleProcessingTimeError) Error() string {
+	return fmt.Sprintf(
+		"processing time %s is older than the replay horizon of %s",
+		e.ProcessingTime.UTC().Format(time.RFC3339), e.Horizon,
+	)
+}
+
+// CombinedMetricsTooLargeError is returned by AggregateCombinedMetrics when
+// the encoded size of the combined metrics exceeds the maximum configured
+// by WithMaxCombinedMetricsSize.
+type CombinedMetricsTooLargeError struct {
+	Size    int
+	MaxSize int
+}
+
+func (e *CombinedMetricsTooLargeError) Error() string {
+	return fmt.Sprintf(
+		"combined metrics of %d bytes exceed the maximum size of %d bytes",
+		e.Size, e.MaxSize,
+	)
+}
+
 // Aggregator represents a LSM based aggregator instance to generate
 // aggregated metrics. The metrics aggregated by the aggregator are
 // harvested based on the aggregation interval and processed by the
@@ -45,19 +93,87 @@ var (
 // same processing time bucket and thereafter the processing time
 // bucket is advanced in factors of aggregation interval.
 type Aggregator struct {
-	db           *pebble.DB
+	// db is the primary shard, which also persists the checkpoints.
+	db *pebble.DB
+	// shards are the databases storing the combined metrics sharded by
+	// combined metrics ID, starting with the primary shard.
+	shards []*pebble.DB
+	// cold is the cold tier database, also the last of the shards, nil
+	// unless the cold tier is enabled. tiers routes the IDs to the tiers.
+	cold         *pebble.DB
+	tiers        *tierState
 	writeOptions *pebble.WriteOptions
 	cfg          Config
+	codec        *valueCodec
+	// converterCfg converts the aggregated events, it is created once
+	// from the config to keep the aggregation hot path allocation free.
+	converterCfg converterConfig
+	// dictDir is the directory persisting the compression dictionary,
+	// empty if the dictionary is only kept in memory.
+	dictDir string
+
+	// batches are the batches of the pending writes per shard, each
+	// guarded by its own lock, see shardBatch.
+	batches []*shardBatch
 
 	mu             sync.Mutex
 	processingTime time.Time
-	batch          *pebble.Batch
-	cachedEvents   cachedEventsMap
+	// encodeBufs are the buffers reused for adding the encoded combined
+	// metrics to the batches.
+	encodeBufs   encodeBuffers
+	cachedEvents cachedEventsMap
+	// idAttrs caches the telemetry attributes per combined metrics ID.
+	idAttrs map[[16]byte]*idAttributes
+	// limiters are the ingest rate limiters per combined metrics ID, see
+	// WithIngestRateLimit.
+	limiters map[[16]byte]*rate.Limiter
+	// partitions holds the number of partitions of the IDs partitioned
+	// adaptively beyond the configured partitions, per aggregation
+	// interval.
+	partitions map[time.Duration]map[[16]byte]uint16
+	// partitionsAdaptedAt is the end time at which the partitions were
+	// last adapted.
+	partitionsAdaptedAt time.Time
+	// watermarks holds the event time watermarks advanced by the embedder
+	// per combined metrics ID, see AdvanceWatermark.
+	watermarks map[[16]byte]time.Time
+	// dictSamples are the values sampled for training the compression
+	// dictionary.
+	dictSamples [][]byte
+
+	// inflightBytes is the size of the batch taken by the harvest loop
+	// which is not yet committed. pendingReleased is closed, and replaced,
+	// whenever pending bytes are committed to wake blocked writers. They
+	// are not guarded by mu as the harvest loop releases the pending bytes
+	// while Close may hold mu waiting for the loop to stop.
+	inflightBytes   atomic.Int64
+	pendingMu       sync.Mutex
+	pendingReleased chan struct{}
+
+	// sinks are the queues of the configured sinks, processed until
+	// sinkCtx is cancelled, see WithSinks.
+	sinks       []*sinkQueue
+	sinkCtx     context.Context
+	cancelSinks context.CancelFunc
 
 	closed     chan struct{}
 	runStopped chan struct{}
+	runState   runState
+	pause      pauseState
+	health     healthState
+	losses     lossTracker
+	coverage   coverageTracker
 
 	metrics *telemetry.Metrics
+
+	// pool is set if the aggregator was created by a Pool, in which case
+	// the telemetry instruments are owned by the pool.
+	pool                 *Pool
+	removePebbleProvider func()
+	removeLimitsProvider func()
+	removeFingerprint    func()
+
+	fingerprint string
 }
 
 // New returns a new aggregator instance.
@@ -68,64 +184,421 @@ func New(opts ...Option) (*Aggregator, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to create aggregation config: %w", err)
 	}
+	return newAggregator(cfg, nil)
+}
 
-	pebbleOpts := &pebble.Options{
-		Merger: &pebble.Merger{
-			Name: "combined_metrics_merger",
-			Merge: func(_, value []byte) (pebble.ValueMerger, error) {
-				merger := combinedMetricsMerger{
-					limits:      cfg.Limits,
-					constraints: newConstraints(cfg.Limits),
-				}
-				pb := aggregationpb.CombinedMetricsFromVTPool()
-				defer pb.ReturnToVTPool()
-				if err := pb.UnmarshalVT(value); err != nil {
-					return nil, fmt.Errorf("failed to unmarshal metrics: %w", err)
-				}
-				merger.merge(pb)
-				return &merger, nil
-			},
-		},
+// newAggregator returns a new aggregator for the given config. If a pool
+// is passed, the aggregator uses the resources shared by the pool.
+func newAggregator(cfg Config, pool *Pool) (*Aggregator, error) {
+	cfg.Logger = sampledLogger(cfg)
+	for _, w := range configWarnings(cfg) {
+		cfg.Logger.Warn(
+			"likely unintended aggregator configuration",
+			zap.String("field", w.Field),
+			zap.String("warning", w.Message),
+		)
 	}
-	writeOptions := pebble.Sync
+	dictDir := cfg.DataDir
 	if cfg.InMemory {
-		pebbleOpts.FS = vfs.NewMem()
-		pebbleOpts.DisableWAL = true
-		writeOptions = pebble.NoSync
+		dictDir = ""
 	}
-	pb, err := pebble.Open(cfg.DataDir, pebbleOpts)
+	codec, err := newValueCodec(cfg.valueCompression(), cfg.ValueCompressionLevel, cfg.ValueEncryption, dictDir)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create pebble db: %w", err)
+		return nil, fmt.Errorf("failed to create value codec: %w", err)
 	}
-
-	metrics, err := telemetry.NewMetrics(
-		func() *pebble.Metrics { return pb.Metrics() },
-		telemetry.WithMeter(cfg.Meter),
+	converterCfg, err := newConverterConfig(
+		WithHashedGlobalLabels(cfg.GlobalLabelsHashThreshold),
+		WithFilteredGlobalLabels(cfg.GlobalLabelsAllowlist, cfg.GlobalLabelsDenylist),
+		WithDurationHistogramImpl(cfg.HistogramImpl),
+		WithDurationHistogramPrecision(cfg.HistogramSignificantFigures, cfg.HistogramMaxDuration),
+		WithDurationSummarySum(cfg.DurationSumEstimate),
+		WithDurationExtremes(cfg.DurationExtremes),
+		WithEventExemplars(cfg.MaxExemplars > 0),
+		WithErrorMetrics(cfg.Limits.MaxErrorGroups > 0),
+		WithServiceGraphEdges(cfg.Limits.MaxServiceGraphEdges > 0),
+		WithServiceInstanceDimensions(cfg.InstanceDimensions...),
+		WithCollapsedServiceInstances(cfg.CollapseInstances),
+		WithNormalizedSpanResources(cfg.SpanResourceNormalizer),
+		WithCustomDimensions(cfg.KeyExtractor),
+		WithSpanSubtypeKey(cfg.SpanSubtypeGroups),
+		WithFaasDimensions(cfg.FaasGroups),
+		WithMobileDimensions(cfg.MobileGroups),
+		WithKubernetesDimensions(cfg.KubernetesGroups),
+		WithCanonicalServiceNames(cfg.ServiceNameAliases),
+		WithPartitionFunc(cfg.Partitioner),
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create metrics: %w", err)
+		return nil, fmt.Errorf("invalid converter options: %w", err)
+	}
+	// The metrics are created before opening the shards so that the merges
+	// of the shards can be recorded.
+	var metrics *telemetry.Metrics
+	if pool != nil {
+		metrics = pool.metrics
+	} else {
+		metrics, err = telemetry.NewMetrics(
+			nil,
+			telemetry.WithMeter(cfg.Meter),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create metrics: %w", err)
+		}
+	}
+	merger := newCombinedMetricsMerger(cfg, codec, metrics, newOverflowNotifier(cfg))
+	writeOptions := pebble.Sync
+	if cfg.InMemory {
+		writeOptions = pebble.NoSync
+	}
+	var cache *pebble.Cache
+	if pool != nil {
+		cache = pool.cache
+	} else if cfg.PebbleOptions.CacheSize > 0 {
+		// The cache is shared, and referenced, by the opened shards.
+		cache = pebble.NewCache(cfg.PebbleOptions.CacheSize)
+		defer cache.Unref()
+	}
+	newOptions := func() *pebble.Options {
+		pebbleOpts := &pebble.Options{
+			Merger: merger,
+			Cache:  cache,
+		}
+		if cfg.InMemory {
+			pebbleOpts.FS = vfs.NewMem()
+			pebbleOpts.DisableWAL = true
+		}
+		applyPebbleOptions(cfg.PebbleOptions, pebbleOpts)
+		if pool != nil {
+			pebbleOpts.TableCache = pool.tableCache
+		}
+		return pebbleOpts
+	}
+	shards, err := openShards(cfg, newOptions)
+	var cold *pebble.DB
+	if err == nil && cfg.ColdTierIdleIntervals > 0 {
+		if cold, err = openColdTier(cfg, newOptions); err != nil {
+			for _, shard := range shards {
+				shard.Close()
+			}
+		} else {
+			shards = append(shards, cold)
+		}
+	}
+	if err != nil {
+		if pool == nil {
+			metrics.CleanUp()
+		}
+		return nil, fmt.Errorf("failed to create pebble db: %w", err)
 	}
 
-	return &Aggregator{
-		db:             pb,
+	a := &Aggregator{
+		db:             shards[0],
+		shards:         shards,
+		batches:        newShardBatches(shards),
+		cold:           cold,
 		writeOptions:   writeOptions,
 		cfg:            cfg,
-		processingTime: time.Now().Truncate(cfg.AggregationIntervals[0]),
-		closed:         make(chan struct{}),
+		codec:          codec,
+		converterCfg:   converterCfg,
+		dictDir:        dictDir,
 		metrics:        metrics,
-	}, nil
+		processingTime: cfg.Clock.Now().Truncate(cfg.AggregationIntervals[0]),
+		closed:         make(chan struct{}),
+		pause:          newPauseState(),
+		pool:           pool,
+		fingerprint:    cfg.Fingerprint(),
+
+		pendingReleased: make(chan struct{}),
+	}
+	if err := a.migrateKeys(); err != nil {
+		for _, shard := range shards {
+			shard.Close()
+		}
+		if pool == nil {
+			metrics.CleanUp()
+		}
+		return nil, fmt.Errorf("failed to migrate combined metrics keys: %w", err)
+	}
+	if cold != nil {
+		a.tiers = newTierState()
+		if err := a.loadTiers(); err != nil {
+			for _, shard := range shards {
+				shard.Close()
+			}
+			if pool == nil {
+				metrics.CleanUp()
+			}
+			return nil, fmt.Errorf("failed to load cold tier: %w", err)
+		}
+	}
+	a.startSinks()
+	limitsProvider := func() []telemetry.Limit { return limitsTelemetry(a.cfg.Limits) }
+	if pool != nil {
+		a.removePebbleProvider = a.addPebbleProviders()
+		a.removeLimitsProvider = pool.metrics.AddLimitsProvider(limitsProvider)
+		a.removeFingerprint = pool.metrics.AddConfigFingerprint(a.fingerprint)
+		return a, nil
+	}
+	a.addPebbleProviders()
+	a.metrics.AddLimitsProvider(limitsProvider)
+	a.metrics.AddConfigFingerprint(a.fingerprint)
+	return a, nil
+}
+
+// addPebbleProviders adds a pebble metrics provider for each shard, the
+// reported pebble metrics are aggregated across the shards. The returned
+// function removes the providers.
+func (a *Aggregator) addPebbleProviders() (remove func()) {
+	removers := make([]func(), 0, len(a.shards))
+	for _, shard := range a.shards {
+		shard := shard
+		removers = append(removers, a.metrics.AddPebbleProvider(
+			func() *pebble.Metrics { return shard.Metrics() },
+		))
+	}
+	return func() {
+		for _, remove := range removers {
+			remove()
+		}
+	}
+}
+
+// Config returns the effective configuration of the aggregator, i.e. the
+// defaults with the options applied. The returned config must not be
+// modified.
+func (a *Aggregator) Config() Config {
+	return a.cfg
+}
+
+// ConfigFingerprint returns the fingerprint of the aggregator
+// configuration, see Config.Fingerprint. It can be attached to the
+// harvested metrics using WithConfigFingerprint.
+func (a *Aggregator) ConfigFingerprint() string {
+	return a.fingerprint
+}
+
+// applyPebbleOptions applies the non-zero options to the pebble options.
+// The block cache is not applied as it may be shared by a pool.
+func applyPebbleOptions(opts PebbleOptions, pebbleOpts *pebble.Options) {
+	if opts.MemTableSize > 0 {
+		pebbleOpts.MemTableSize = opts.MemTableSize
+	}
+	if opts.MemTableStopWritesThreshold > 0 {
+		pebbleOpts.MemTableStopWritesThreshold = opts.MemTableStopWritesThreshold
+	}
+	if opts.MaxConcurrentCompactions > 0 {
+		n := opts.MaxConcurrentCompactions
+		pebbleOpts.MaxConcurrentCompactions = func() int { return n }
+	}
+	if opts.L0CompactionThreshold > 0 {
+		pebbleOpts.L0CompactionThreshold = opts.L0CompactionThreshold
+	}
+	if opts.L0StopWritesThreshold > 0 {
+		pebbleOpts.L0StopWritesThreshold = opts.L0StopWritesThreshold
+	}
+	if len(opts.LevelCompression) > 0 {
+		pebbleOpts.Levels = make([]pebble.LevelOptions, maxPebbleLevels)
+		for i := range pebbleOpts.Levels {
+			c := opts.LevelCompression[len(opts.LevelCompression)-1]
+			if i < len(opts.LevelCompression) {
+				c = opts.LevelCompression[i]
+			}
+			pebbleOpts.Levels[i].Compression = sstableCompression(c)
+		}
+	}
+}
+
+// sstableCompression returns the pebble compression for the compression.
+func sstableCompression(c Compression) pebble.Compression {
+	switch c {
+	case NoCompression:
+		return pebble.NoCompression
+	case SnappyCompression:
+		return pebble.SnappyCompression
+	case ZstdCompression:
+		return pebble.ZstdCompression
+	}
+	return pebble.DefaultCompression
+}
+
+// newCombinedMetricsMerger returns the pebble merger used for merging the
+// combined metrics stored under the same key. If metrics are given, the
+// merge time and throttled time of the mergers are recorded. The mergers
+// share the throttle of the merge budget, see WithMergeBudget.
+func newCombinedMetricsMerger(
+	cfg Config,
+	codec *valueCodec,
+	metrics *telemetry.Metrics,
+	notifier *overflowNotifier,
+) *pebble.Merger {
+	var observe func(time.Duration, time.Duration)
+	if metrics != nil {
+		observe = func(mergeTime, throttled time.Duration) {
+			ctx := context.Background()
+			metrics.MergeDuration.Record(ctx, mergeTime.Seconds(), emptyAttrSetRecordOptions...)
+			if throttled > 0 {
+				metrics.MergeThrottled.Add(ctx, throttled.Seconds(), emptyAttrSetAddOptions...)
+			}
+		}
+	}
+	var throttle *rate.Limiter
+	if cfg.MergeBudget > 0 {
+		budget := int(cfg.MergeBudget.Microseconds())
+		throttle = rate.NewLimiter(rate.Limit(budget), budget)
+	}
+	// The mergers are invoked by pebble without a context, the merge
+	// spans are therefore started as root spans.
+	var startSpan func() trace.Span
+	if cfg.Tracer != nil {
+		startSpan = func() trace.Span {
+			_, span := cfg.Tracer.Start(context.Background(), "MergeCombinedMetrics")
+			return span
+		}
+	}
+	return &pebble.Merger{
+		Name: "combined_metrics_merger",
+		Merge: func(key, value []byte) (pebble.ValueMerger, error) {
+			merger := combinedMetricsMerger{
+				limits:           cfg.Limits,
+				constraints:      newConstraints(cfg.Limits),
+				topK:             cfg.TopKRetention,
+				maxExemplars:     cfg.MaxExemplars,
+				codec:            codec,
+				sparseHistograms: cfg.SparseHistograms,
+				throttle:         throttle,
+				clock:            cfg.Clock,
+				observe:          observe,
+				logger:           cfg.Logger,
+			}
+			if startSpan != nil {
+				merger.span = startSpan()
+			}
+			if notifier != nil {
+				var cmk CombinedMetricsKey
+				if err := cmk.UnmarshalBinary(key); err == nil {
+					merger.overflowed = func(limit overflowLimit, keySample string) {
+						notifier.overflowed(cmk, limit, keySample)
+					}
+				}
+			}
+			pb := aggregationpb.CombinedMetricsFromVTPool()
+			defer pb.ReturnToVTPool()
+			if err := merger.unmarshal(value, pb); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal metrics: %w", err)
+			}
+			merger.merge(pb)
+			return &merger, nil
+		},
+	}
+}
+
+var (
+	// emptyAttrSetAddOptions and emptyAttrSetRecordOptions are shared by
+	// all measurements without attributes.
+	emptyAttrSetAddOptions    = []metric.AddOption{metric.WithAttributeSet(*attribute.EmptySet())}
+	emptyAttrSetRecordOptions = []metric.RecordOption{metric.WithAttributeSet(*attribute.EmptySet())}
+)
+
+// attrSetOptions returns the options of measurements with the attributes.
+// The options are reused across the measurements of a request, passing an
+// options slice avoids allocating the variadic options per measurement.
+func attrSetOptions(attrs []attribute.KeyValue) ([]metric.AddOption, []metric.RecordOption) {
+	if len(attrs) == 0 {
+		return emptyAttrSetAddOptions, emptyAttrSetRecordOptions
+	}
+	opt := metric.WithAttributeSet(attribute.NewSet(attrs...))
+	return []metric.AddOption{opt}, []metric.RecordOption{opt}
+}
+
+// maxCachedIDAttributes is the number of combined metrics IDs for which
+// the telemetry attributes are cached, the cache is reset once full.
+const maxCachedIDAttributes = 10000
+
+// idAttributes are the telemetry attributes of a combined metrics ID,
+// cached so that the ID is not converted and the attribute set is not
+// allocated per request, see WithCombinedMetricsIDToKVs.
+type idAttributes struct {
+	attrs      []attribute.KeyValue
+	addOpts    []metric.AddOption
+	recordOpts []metric.RecordOption
+	// late holds the attributes of the events too late for each of the
+	// aggregation intervals, created on first use.
+	late [][]attribute.KeyValue
+}
+
+// attributesFor returns the cached telemetry attributes of the ID. Must
+// be called with the lock held.
+func (a *Aggregator) attributesFor(id [16]byte) *idAttributes {
+	if attrs, ok := a.idAttrs[id]; ok {
+		return attrs
+	}
+	if a.idAttrs == nil || len(a.idAttrs) >= maxCachedIDAttributes {
+		a.idAttrs = make(map[[16]byte]*idAttributes)
+	}
+	attrs := &idAttributes{
+		attrs: a.cfg.CombinedMetricsIDToKVs(id),
+		late:  make([][]attribute.KeyValue, len(a.cfg.AggregationIntervals)),
+	}
+	attrs.addOpts, attrs.recordOpts = attrSetOptions(attrs.attrs)
+	a.idAttrs[id] = attrs
+	return attrs
+}
+
+// lateAttrs returns the attributes of the events too late for the i-th
+// aggregation interval.
+func (ia *idAttributes) lateAttrs(i int, ivl time.Duration) []attribute.KeyValue {
+	if ia.late[i] == nil {
+		ia.late[i] = append(append([]attribute.KeyValue{}, ia.attrs...),
+			attribute.String(aggregationIvlKey, formatDuration(ivl)))
+	}
+	return ia.late[i]
+}
+
+// limitsTelemetry returns the limits for reporting as telemetry.
+func limitsTelemetry(limits Limits) []telemetry.Limit {
+	return []telemetry.Limit{
+		{Name: "max_services", Value: int64(limits.MaxServices)},
+		{Name: "max_service_instance_groups_per_service", Value: int64(limits.MaxServiceInstanceGroupsPerService)},
+		{Name: "max_service_instance_groups", Value: int64(limits.MaxServiceInstanceGroups)},
+		{Name: "max_global_labels_per_service", Value: int64(limits.MaxGlobalLabelsPerService)},
+		{Name: "max_kubernetes_workloads_per_service", Value: int64(limits.MaxKubernetesWorkloadsPerService)},
+		{Name: "max_span_groups", Value: int64(limits.MaxSpanGroups)},
+		{Name: "max_span_groups_per_service", Value: int64(limits.MaxSpanGroupsPerService)},
+		{Name: "max_span_name_per_destination", Value: int64(limits.MaxSpanNamePerDestination)},
+		{Name: "max_transaction_groups", Value: int64(limits.MaxTransactionGroups)},
+		{Name: "max_transaction_groups_per_service", Value: int64(limits.MaxTransactionGroupsPerService)},
+		{Name: "max_faas_ids_per_service", Value: int64(limits.MaxFaasIDsPerService)},
+		{Name: "max_service_versions_per_service", Value: int64(limits.MaxServiceVersionsPerService)},
+		{Name: "max_host_os_versions_per_service", Value: int64(limits.MaxHostOSVersionsPerService)},
+		{Name: "max_service_transaction_groups", Value: int64(limits.MaxServiceTransactionGroups)},
+		{Name: "max_service_transaction_groups_per_service", Value: int64(limits.MaxServiceTransactionGroupsPerService)},
+		{Name: "max_error_groups", Value: int64(limits.MaxErrorGroups)},
+		{Name: "max_error_groups_per_service", Value: int64(limits.MaxErrorGroupsPerService)},
+		{Name: "max_service_graph_edges", Value: int64(limits.MaxServiceGraphEdges)},
+		{Name: "max_service_graph_edges_per_service", Value: int64(limits.MaxServiceGraphEdgesPerService)},
+	}
 }
 
 // AggregateBatch aggregates all events in the batch. This function will return
 // an error if the aggregator's Run loop has errored or has been explicitly stopped.
 // However, it doesn't require aggregator to be running to perform aggregation.
+// With backfill, the aggregation periods closed by the batch are harvested
+// before AggregateBatch returns, see WithBackfill.
 func (a *Aggregator) AggregateBatch(
 	ctx context.Context,
 	id [16]byte,
 	b *modelpb.Batch,
 ) error {
-	cmIDAttrs := a.cfg.CombinedMetricsIDToKVs(id)
+	if err := a.aggregateBatch(ctx, id, b); err != nil || !a.cfg.Backfill {
+		return err
+	}
+	return a.advanceBackfillWatermark(ctx, id, b)
+}
 
+func (a *Aggregator) aggregateBatch(
+	ctx context.Context,
+	id [16]byte,
+	b *modelpb.Batch,
+) error {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
@@ -136,28 +609,68 @@ func (a *Aggregator) AggregateBatch(
 		return ErrAggregatorClosed
 	default:
 	}
+	if a.pause.paused() {
+		return ErrAggregatorPaused
+	}
+	idAttrs := a.attributesFor(id)
+	cmIDAttrs, cmIDAddOpts := idAttrs.attrs, idAttrs.addOpts
+	if err := a.allowIngest(id, len(*b)); err != nil {
+		a.metrics.RequestsTotal.Add(ctx, 1, cmIDAddOpts...)
+		a.metrics.RequestsFailed.Add(ctx, 1, cmIDAddOpts...)
+		a.recordIngestLoss(ctx, id, cmIDAttrs, lossReasonRateLimited, float64(len(*b)))
+		return err
+	}
+	if err := a.awaitPendingBytes(ctx); err != nil {
+		return err
+	}
+	a.touch(id)
 
 	var errs []error
 	var totalBytesIn int64
+	// invalid is the number of events which failed to be aggregated for
+	// the lowest interval, the events fail for all intervals alike.
+	var invalid int
 	cmk := CombinedMetricsKey{ID: id}
-	for _, ivl := range a.cfg.AggregationIntervals {
-		cmk.ProcessingTime = a.processingTime.Truncate(ivl)
-		cmk.Interval = ivl
-		for _, e := range *b {
-			bytesIn, err := a.aggregateAPMEvent(ctx, cmk, e)
-			if err != nil {
-				errs = append(errs, err)
+	for i, ivl := range a.cfg.AggregationIntervals {
+		// With interval rollups the higher intervals are derived from the
+		// lowest interval during harvest.
+		if i == 0 || !a.cfg.IntervalRollups {
+			cmk.ProcessingTime = a.processingTime.Truncate(ivl)
+			cmk.Interval = ivl
+			var tooLate int
+			for _, e := range *b {
+				eventKey, action := a.lateEventKey(cmk, e)
+				if action == dropLateEvent {
+					tooLate++
+					continue
+				}
+				bytesIn, err := a.aggregateAPMEvent(ctx, eventKey, e, action == routeLateEvent)
+				if err != nil {
+					errs = append(errs, err)
+					if i == 0 {
+						invalid++
+					}
+				}
+				totalBytesIn += int64(bytesIn)
+			}
+			if tooLate > 0 {
+				// With interval rollups the dropped events are lost for
+				// all the intervals.
+				lossIvls := []time.Duration{ivl}
+				if a.cfg.IntervalRollups {
+					lossIvls = a.cfg.AggregationIntervals
+				}
+				a.recordLoss(ctx, id, idAttrs.lateAttrs(i, ivl), lossReasonTooLate, float64(tooLate), lossIvls)
 			}
-			totalBytesIn += int64(bytesIn)
 		}
 		a.cachedEvents.add(ivl, id, float64(len(*b)))
 	}
+	a.recordIngestLoss(ctx, id, cmIDAttrs, lossReasonInvalid, float64(invalid))
 
-	cmIDAttrSet := attribute.NewSet(cmIDAttrs...)
-	a.metrics.RequestsTotal.Add(ctx, 1, metric.WithAttributeSet(cmIDAttrSet))
-	a.metrics.BytesIngested.Add(ctx, totalBytesIn, metric.WithAttributeSet(cmIDAttrSet))
+	a.metrics.RequestsTotal.Add(ctx, 1, cmIDAddOpts...)
+	a.metrics.BytesIngested.Add(ctx, totalBytesIn, cmIDAddOpts...)
 	if len(errs) > 0 {
-		a.metrics.RequestsFailed.Add(ctx, 1, metric.WithAttributeSet(cmIDAttrSet))
+		a.metrics.RequestsFailed.Add(ctx, 1, cmIDAddOpts...)
 		return fmt.Errorf("failed batch aggregation:\n%w", errors.Join(errs...))
 	}
 	return nil
@@ -189,16 +702,48 @@ func (a *Aggregator) AggregateCombinedMetrics(
 		return ErrAggregatorClosed
 	default:
 	}
+	if a.pause.paused() {
+		return ErrAggregatorPaused
+	}
+	idAttrs := a.attributesFor(cmk.ID)
+	cmIDAddOpts, cmIDRecordOpts := idAttrs.addOpts, idAttrs.recordOpts
+	size := cm.SizeVT()
+	a.metrics.CombinedMetricsSize.Record(ctx, int64(size), cmIDRecordOpts...)
+	if maxSize := a.cfg.MaxCombinedMetricsSize; maxSize > 0 && size > maxSize {
+		a.metrics.RequestsTotal.Add(ctx, 1, cmIDAddOpts...)
+		a.metrics.RequestsFailed.Add(ctx, 1, cmIDAddOpts...)
+		a.recordIngestLoss(ctx, cmk.ID, cmIDAttrs, lossReasonInvalid, cm.EventsTotal)
+		return &CombinedMetricsTooLargeError{Size: size, MaxSize: maxSize}
+	}
+	if horizon := a.cfg.ReplayHorizon; horizon > 0 &&
+		cmk.ProcessingTime.Before(a.cfg.Clock.Now().Add(-horizon)) {
+		a.metrics.RequestsTotal.Add(ctx, 1, cmIDAddOpts...)
+		a.metrics.RequestsFailed.Add(ctx, 1, cmIDAddOpts...)
+		a.recordIngestLoss(ctx, cmk.ID, cmIDAttrs, lossReasonTooOld, cm.EventsTotal)
+		return &StaleProcessingTimeError{
+			ProcessingTime: cmk.ProcessingTime,
+			Horizon:        horizon,
+		}
+	}
+	if err := a.awaitPendingBytes(ctx); err != nil {
+		return err
+	}
+	a.touch(cmk.ID)
 
 	bytesIn, err := a.aggregate(ctx, cmk, cm)
-	a.cachedEvents.add(cmk.Interval, cmk.ID, cm.EventsTotal)
+	if a.rollsUp(cmk.Interval) {
+		for _, ivl := range a.cfg.AggregationIntervals {
+			a.cachedEvents.add(ivl, cmk.ID, cm.EventsTotal)
+		}
+	} else {
+		a.cachedEvents.add(cmk.Interval, cmk.ID, cm.EventsTotal)
+	}
 
 	span.SetAttributes(attribute.Int("bytes_ingested", bytesIn))
-	cmIDAttrSet := attribute.NewSet(cmIDAttrs...)
-	a.metrics.RequestsTotal.Add(ctx, 1, metric.WithAttributeSet(cmIDAttrSet))
-	a.metrics.BytesIngested.Add(ctx, int64(bytesIn), metric.WithAttributeSet(cmIDAttrSet))
+	a.metrics.RequestsTotal.Add(ctx, 1, cmIDAddOpts...)
+	a.metrics.BytesIngested.Add(ctx, int64(bytesIn), cmIDAddOpts...)
 	if err != nil {
-		a.metrics.RequestsFailed.Add(ctx, 1, metric.WithAttributeSet(cmIDAttrSet))
+		a.metrics.RequestsFailed.Add(ctx, 1, cmIDAddOpts...)
 	}
 	return err
 }
@@ -207,6 +752,15 @@ func (a *Aggregator) AggregateCombinedMetrics(
 // Run must be called at-most once.
 // - Running more than once will return an error
 // - Running after aggregator is stopped will return ErrAggregatorClosed.
+//
+// Run supervises the harvest loop. If a harvest crashes, the loop is
+// restarted after a backoff, retrying the crashed harvest, until the
+// configured number of consecutive restarts is exhausted. After that Run
+// returns an error. The retried harvest skips the combined metrics of the
+// IDs checkpointed by the crashed harvest, only the partitions of the ID
+// being processed when the harvest crashed are processed again, see
+// Checkpoint. If restarts are disabled, which is the default, a harvest
+// panic is not recovered, see WithHarvestLoopRestarts.
 func (a *Aggregator) Run(ctx context.Context) error {
 	a.mu.Lock()
 	if a.runStopped != nil {
@@ -217,33 +771,269 @@ func (a *Aggregator) Run(ctx context.Context) error {
 	a.mu.Unlock()
 	defer close(a.runStopped)
 
-	to := a.processingTime.Add(a.cfg.AggregationIntervals[0])
-	timer := time.NewTimer(time.Until(to.Add(a.cfg.HarvestDelay)))
-	defer timer.Stop()
+	a.runState.setRunning(true)
+	defer a.runState.setRunning(false)
+
+	if a.cfg.RecoveryMode {
+		if err := a.harvestStale(ctx); err != nil {
+			a.cfg.Logger.Warn("failed to recover stale aggregated metrics", zap.Error(err))
+		}
+	}
+
+	state := harvestLoopState{to: a.processingTime.Add(a.cfg.AggregationIntervals[0])}
+	backoff := a.cfg.HarvestLoopRestartBackoff
 	for {
+		err := a.runHarvestLoop(ctx, &state)
+		var crashErr *harvestLoopCrashError
+		if !errors.As(err, &crashErr) {
+			return err
+		}
+		crashes := a.runState.recordCrash(err)
+		if crashes > a.cfg.MaxHarvestLoopRestarts {
+			return fmt.Errorf("harvest loop crashed %d consecutive times: %w", crashes, err)
+		}
+		a.cfg.Logger.Error(
+			"harvest loop crashed, restarting",
+			zap.Error(err),
+			zap.ByteString("stacktrace", crashErr.stack),
+			zap.Int("consecutive_crashes", crashes),
+			zap.Duration("backoff", backoff),
+		)
+		timer := a.cfg.Clock.NewTimer(backoff)
 		select {
 		case <-ctx.Done():
+			timer.Stop()
 			return ctx.Err()
 		case <-a.closed:
+			timer.Stop()
 			return ErrAggregatorClosed
-		case <-timer.C:
+		case <-timer.C():
+		}
+		a.runState.recordRestart()
+		backoff *= 2
+		if maxBackoff := a.cfg.AggregationIntervals[0]; backoff > maxBackoff {
+			backoff = maxBackoff
 		}
+	}
+}
 
-		a.mu.Lock()
-		batch := a.batch
-		a.batch = nil
-		a.processingTime = to
-		cachedEventsStats := a.cachedEvents.loadAndDelete(to)
-		a.mu.Unlock()
+// harvestLoopState is the state of the harvest loop, kept across restarts
+// of the loop after a crash.
+type harvestLoopState struct {
+	// to is the end of the current lowest aggregation interval.
+	to time.Time
+	// group is the index of the harvest group to harvest next for to,
+	// which is the crashed group after a crash.
+	group int
+	// cachedEventsStats holds the cached events stats loaded for to, nil
+	// until loaded by the first group.
+	cachedEventsStats map[time.Duration]map[[16]byte]float64
+	// laggedStats holds the cached events stats of the previous end times
+	// until they are harvested by the lagging groups.
+	laggedStats map[time.Time]map[time.Duration]map[[16]byte]float64
+}
+
+// runHarvestLoop harvests the aggregated results periodically starting
+// with the harvest for the state. If a harvest crashes then the loop is
+// stopped, with the state of the crashed harvest, and a
+// *harvestLoopCrashError is returned.
+//
+// The aggregation intervals are harvested in groups of intervals with the
+// same harvest offset, in order of the offsets within the lowest
+// aggregation interval. A group is only harvested if at least one of its
+// intervals ends at the harvested end time, which lags behind the end of
+// the current lowest aggregation interval for offsets not less than the
+// lowest aggregation interval.
+func (a *Aggregator) runHarvestLoop(ctx context.Context, state *harvestLoopState) error {
+	groups := a.harvestGroups()
+	lowest := a.cfg.AggregationIntervals[0]
+	var maxLag int
+	for _, group := range groups {
+		if group.lag > maxLag {
+			maxLag = group.lag
+		}
+	}
+	if maxLag > 0 && state.laggedStats == nil {
+		state.laggedStats = make(map[time.Time]map[time.Duration]map[[16]byte]float64)
+	}
+	timer := a.cfg.Clock.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C()
+	for {
+		for ; state.group < len(groups); state.group++ {
+			group := groups[state.group]
+			end := state.to.Add(-time.Duration(group.lag) * lowest)
+			ivls := group.endingAt(end)
+			if state.group > 0 && len(ivls) == 0 {
+				continue
+			}
+			timer.Reset(a.untilHarvest(end, group.offset))
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-a.closed:
+				return ErrAggregatorClosed
+			case <-timer.C():
+			}
+			// Harvests are suspended while the aggregator is paused.
+			if err := a.beginHarvest(ctx); err != nil {
+				return err
+			}
+
+			a.mu.Lock()
+			batch := a.takeBatches()
+			if batch != nil {
+				a.inflightBytes.Store(int64(batch.len()))
+			}
+			a.processingTime = state.to
+			if state.cachedEventsStats == nil {
+				state.cachedEventsStats = a.cachedEvents.loadAndDelete(state.to)
+				if state.laggedStats != nil {
+					state.laggedStats[state.to] = state.cachedEventsStats
+				}
+			}
+			a.mu.Unlock()
 
-		if err := a.commitAndHarvest(ctx, batch, to, cachedEventsStats); err != nil {
-			a.cfg.Logger.Warn("failed to commit and harvest metrics", zap.Error(err))
+			stats := state.cachedEventsStats
+			if group.lag > 0 {
+				stats = state.laggedStats[end]
+			}
+			err := a.supervisedCommitAndHarvest(ctx, batch, end, ivls, stats)
+			a.endHarvest()
+			// The batches are released by the commit, or re-queued if the
+			// commit crashed, this only makes sure that blocked writers
+			// are not stuck if the commit crashed.
+			a.releasePendingBytes()
+			var crashErr *harvestLoopCrashError
+			if errors.As(err, &crashErr) {
+				return err
+			}
+			if err != nil {
+				a.cfg.Logger.Warn("failed to commit and harvest metrics", zap.Error(err))
+			}
 		}
-		to = to.Add(a.cfg.AggregationIntervals[0])
-		timer.Reset(time.Until(to.Add(a.cfg.HarvestDelay)))
+		delete(state.laggedStats, state.to.Add(-time.Duration(maxLag)*lowest))
+		if a.cfg.MaxRetention > 0 {
+			if err := a.beginHarvest(ctx); err != nil {
+				return err
+			}
+			if err := a.collectGarbage(ctx, state.to); err != nil {
+				a.cfg.Logger.Warn("failed to collect garbage", zap.Error(err))
+			}
+			a.endHarvest()
+		}
+		a.runState.resetCrashes()
+		state.to = state.to.Add(lowest)
+		state.group = 0
+		state.cachedEventsStats = nil
 	}
 }
 
+// harvestGroup is a group of aggregation intervals harvested after the
+// same offset.
+type harvestGroup struct {
+	offset time.Duration
+	// lag is the number of lowest aggregation intervals by which the
+	// harvested end time of the group lags behind the end of the current
+	// lowest aggregation interval, for offsets not less than the lowest
+	// aggregation interval.
+	lag  int
+	ivls []time.Duration
+}
+
+// endingAt returns the intervals of the group which end at the given time.
+func (g harvestGroup) endingAt(end time.Time) []time.Duration {
+	var ivls []time.Duration
+	for _, ivl := range g.ivls {
+		if end.Truncate(ivl).Equal(end) {
+			ivls = append(ivls, ivl)
+		}
+	}
+	return ivls
+}
+
+// harvestGroups groups the aggregation intervals by their harvest offset,
+// sorted by the offset within the lowest aggregation interval. The first
+// group always has the lowest offset within the lowest aggregation
+// interval, which is used for committing the pending writes and advancing
+// the processing time at every lowest aggregation interval.
+func (a *Aggregator) harvestGroups() []harvestGroup {
+	lowest := a.cfg.AggregationIntervals[0]
+	var groups []harvestGroup
+	for _, ivl := range a.cfg.AggregationIntervals {
+		offset := a.cfg.HarvestOffsets[ivl]
+		i := sort.Search(len(groups), func(i int) bool {
+			if groups[i].offset%lowest != offset%lowest {
+				return groups[i].offset%lowest > offset%lowest
+			}
+			return groups[i].offset >= offset
+		})
+		if i == len(groups) || groups[i].offset != offset {
+			groups = append(groups, harvestGroup{})
+			copy(groups[i+1:], groups[i:])
+			groups[i] = harvestGroup{offset: offset, lag: int(offset / lowest)}
+		}
+		groups[i].ivls = append(groups[i].ivls, ivl)
+	}
+	return groups
+}
+
+// untilHarvest returns the duration until the harvest of the metrics
+// ending at the given time with the given offset, after the configured
+// harvest delay and a random jitter.
+func (a *Aggregator) untilHarvest(end time.Time, offset time.Duration) time.Duration {
+	delay := a.cfg.HarvestDelay + offset
+	if a.cfg.HarvestJitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(a.cfg.HarvestJitter)))
+	}
+	return end.Add(delay).Sub(a.cfg.Clock.Now())
+}
+
+// withLock calls f with the lock held. The lock is released even if f
+// panics, so that the harvest loop restarted after a crash does not
+// deadlock.
+func (a *Aggregator) withLock(f func()) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f()
+}
+
+// supervisedCommitAndHarvest calls commitAndHarvest recovering from any
+// panic if the harvest loop may be restarted, see WithHarvestLoopRestarts.
+// A recovered panic is returned as a *harvestLoopCrashError. If the panic
+// happened before the batches were committed then the batches are
+// re-queued to be committed by the retried harvest. Panics are not
+// recovered if restarts are disabled.
+func (a *Aggregator) supervisedCommitAndHarvest(
+	ctx context.Context,
+	batch pendingBatches,
+	to time.Time,
+	ivls []time.Duration,
+	cachedEventsStats map[time.Duration]map[[16]byte]float64,
+) (err error) {
+	if a.cfg.MaxHarvestLoopRestarts == 0 {
+		return a.commitAndHarvest(ctx, batch, to, ivls, cachedEventsStats)
+	}
+	pending := batch
+	defer func() {
+		if r := recover(); r != nil {
+			if pending != nil {
+				a.requeueBatches(pending)
+			}
+			err = &harvestLoopCrashError{
+				recovered: r,
+				stack:     debug.Stack(),
+			}
+		}
+	}()
+	var commitErr error
+	if batch != nil {
+		commitErr = a.commitTakenBatch(batch)
+		pending = nil
+	}
+	return errors.Join(commitErr, a.commitAndHarvest(ctx, nil, to, ivls, cachedEventsStats))
+}
+
 // Close commits and closes any buffered writes, stops any running harvester,
 // performs a final harvest, and closes the underlying database.
 //
@@ -252,7 +1042,14 @@ func (a *Aggregator) Run(ctx context.Context) error {
 func (a *Aggregator) Close(ctx context.Context) error {
 	ctx, span := a.cfg.Tracer.Start(ctx, "Aggregator.Close")
 	defer span.End()
+	_, err := a.close(ctx, span, false)
+	return err
+}
 
+// close implements Close and CloseWithDrain, the final harvest drains all
+// the stored aggregation periods if drain is true.
+func (a *Aggregator) close(ctx context.Context, span trace.Span, drain bool) (DrainReport, error) {
+	var report DrainReport
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
@@ -262,72 +1059,116 @@ func (a *Aggregator) Close(ctx context.Context) error {
 		a.cfg.Logger.Info("stopping aggregator")
 		close(a.closed)
 	}
+	for _, sb := range a.batches {
+		sb.mu.Lock()
+		if sb.flushTimer != nil {
+			sb.flushTimer.Stop()
+		}
+		sb.mu.Unlock()
+	}
 	if a.runStopped != nil {
 		select {
 		case <-ctx.Done():
-			return fmt.Errorf("context cancelled while waiting for run to complete: %w", ctx.Err())
+			return report, fmt.Errorf("context cancelled while waiting for run to complete: %w", ctx.Err())
 		case <-a.runStopped:
 		}
 	}
 
 	if a.db != nil {
 		a.cfg.Logger.Info("running final aggregation")
-		if a.batch != nil {
-			if err := a.batch.Commit(a.writeOptions); err != nil {
+		if batch := a.takeBatches(); batch != nil {
+			if err := a.commitBatch(batch); err != nil {
+				batch.close()
 				span.RecordError(err)
-				return fmt.Errorf("failed to commit batch: %w", err)
+				return report, fmt.Errorf("failed to commit batch: %w", err)
 			}
-			if err := a.batch.Close(); err != nil {
+			if err := batch.close(); err != nil {
 				span.RecordError(err)
-				return fmt.Errorf("failed to close batch: %w", err)
+				return report, fmt.Errorf("failed to close batch: %w", err)
 			}
-			a.batch = nil
 		}
 		var errs []error
-		for _, ivl := range a.cfg.AggregationIntervals {
-			// At any particular time there will be 1 harvest candidate for
-			// each aggregation interval. We will align the end time and
-			// process each of these.
-			//
-			// TODO (lahsivjar): It is possible to harvest the same
-			// time multiple times, not an issue but can be optimized.
-			to := a.processingTime.Truncate(ivl).Add(ivl)
-			if err := a.harvest(ctx, to, a.cachedEvents.loadAndDelete(to)); err != nil {
+		if err := a.reconcileTiers(a.processingTime); err != nil {
+			span.RecordError(err)
+			errs = append(errs, fmt.Errorf("failed to reconcile tiers before final harvest: %w", err))
+		}
+		if drain {
+			var err error
+			if report, err = a.drain(ctx); err != nil {
 				span.RecordError(err)
-				errs = append(errs, fmt.Errorf(
-					"failed to harvest metrics for interval %s: %w", formatDuration(ivl), err),
-				)
+				errs = append(errs, fmt.Errorf("failed to drain aggregated metrics: %w", err))
+			}
+		} else {
+			for _, ivl := range a.cfg.AggregationIntervals {
+				// At any particular time there will be 1 harvest candidate for
+				// each aggregation interval. We will align the end time and
+				// process each of these.
+				//
+				// TODO (lahsivjar): It is possible to harvest the same
+				// time multiple times, not an issue but can be optimized.
+				to := a.processingTime.Truncate(ivl).Add(ivl)
+				if err := a.harvest(
+					ctx, to, a.cfg.AggregationIntervals, a.cachedEvents.loadAndDelete(to),
+				); err != nil {
+					span.RecordError(err)
+					errs = append(errs, fmt.Errorf(
+						"failed to harvest metrics for interval %s: %w", formatDuration(ivl), err),
+					)
+				}
 			}
 		}
+		if err := a.stopSinks(ctx); err != nil {
+			span.RecordError(err)
+			errs = append(errs, err)
+		}
 		if len(errs) > 0 {
-			return fmt.Errorf("failed while running final harvest: %w", errors.Join(errs...))
+			return report, fmt.Errorf("failed while running final harvest: %w", errors.Join(errs...))
 		}
-		if err := a.db.Close(); err != nil {
-			span.RecordError(err)
-			return fmt.Errorf("failed to close pebble: %w", err)
+		for _, shard := range a.shards {
+			if err := shard.Close(); err != nil {
+				errs = append(errs, err)
+			}
 		}
 		// All future operations are invalid after db is closed
 		a.db = nil
+		a.shards = nil
+		a.cold = nil
+		if len(errs) > 0 {
+			err := errors.Join(errs...)
+			span.RecordError(err)
+			return report, fmt.Errorf("failed to close pebble: %w", err)
+		}
+	}
+	if a.pool != nil {
+		a.pool.release(a)
+		return report, nil
 	}
 	if err := a.metrics.CleanUp(); err != nil {
 		span.RecordError(err)
-		return fmt.Errorf("failed to cleanup instrumentation: %w", err)
+		return report, fmt.Errorf("failed to cleanup instrumentation: %w", err)
 	}
-	return nil
+	return report, nil
 }
 
+// aggregateAPMEvent aggregates the event into the combined metrics of the
+// given key, partitioned by the configured partitions unless routed to the
+// LatePartitionID partition.
 func (a *Aggregator) aggregateAPMEvent(
 	ctx context.Context,
 	cmk CombinedMetricsKey,
 	e *modelpb.APMEvent,
+	routeLate bool,
 ) (int, error) {
 	var totalBytesIn int
 	aggregateFunc := func(k CombinedMetricsKey, m *aggregationpb.CombinedMetrics) error {
+		if routeLate {
+			k.PartitionID = LatePartitionID
+		}
 		bytesIn, err := a.aggregate(ctx, k, m)
 		totalBytesIn += bytesIn
 		return err
 	}
-	err := EventToCombinedMetrics(e, cmk, a.cfg.Partitions, aggregateFunc)
+	err := eventToCombinedMetrics(e, cmk, a.partitionsFor(cmk.Interval, cmk.ID), aggregateFunc, &a.converterCfg)
 	if err != nil {
 		return 0, fmt.Errorf("failed to aggregate combined metrics: %w", err)
 	}
@@ -341,40 +1182,175 @@ func (a *Aggregator) aggregate(
 	cmk CombinedMetricsKey,
 	cm *aggregationpb.CombinedMetrics,
 ) (int, error) {
-	if a.batch == nil {
+	sb := a.batchFor(cmk.ID)
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	if sb.batch == nil {
 		// Batch is backed by a sync pool. After each commit we will release the batch
 		// back to the pool by calling Batch#Close and subsequently acquire a new batch.
-		a.batch = a.db.NewBatch()
+		sb.batch = sb.shard.NewBatch()
+		if a.cfg.FlushInterval > 0 {
+			sb.created = a.cfg.Clock.Now()
+			if sb.flushTimer == nil {
+				sb.flushTimer = a.cfg.Clock.AfterFunc(a.cfg.FlushInterval, func() { a.flushBatch(sb) })
+			} else {
+				sb.flushTimer.Reset(a.cfg.FlushInterval)
+			}
+		}
+	}
+
+	if a.codec.encodes() {
+		if err := a.aggregateEncoded(sb.batch, cmk, cm); err != nil {
+			return 0, err
+		}
+	} else {
+		op := sb.batch.MergeDeferred(cmk.SizeBinary(), cm.SizeVT())
+		if err := cmk.MarshalBinaryToSizedBuffer(op.Key); err != nil {
+			return 0, fmt.Errorf("failed to marshal combined metrics key: %w", err)
+		}
+		if _, err := cm.MarshalToSizedBufferVT(op.Value); err != nil {
+			return 0, fmt.Errorf("failed to marshal combined metrics: %w", err)
+		}
+		if err := op.Finish(); err != nil {
+			return 0, fmt.Errorf("failed to finalize merge operation: %w", err)
+		}
+	}
+	a.storeTier(cmk)
+
+	bytesIn := cm.SizeVT()
+	if sb.batch.Len() >= a.cfg.FlushBytes {
+		if err := a.flushShardBatch(sb); err != nil {
+			return bytesIn, err
+		}
 	}
+	return bytesIn, nil
+}
 
-	op := a.batch.MergeDeferred(cmk.SizeBinary(), cm.SizeVT())
-	if err := cmk.MarshalBinaryToSizedBuffer(op.Key); err != nil {
-		return 0, fmt.Errorf("failed to marshal combined metrics key: %w", err)
+// aggregateEncoded adds the encoded, i.e. compressed and/or encrypted,
+// combined metrics to the batch, sampling the value for training the
+// compression dictionary if needed. Must be called with the lock held,
+// and the lock of the shard batch.
+func (a *Aggregator) aggregateEncoded(
+	batch *pebble.Batch,
+	cmk CombinedMetricsKey,
+	cm *aggregationpb.CombinedMetrics,
+) error {
+	bufs := &a.encodeBufs
+	bufs.key = resizeBuffer(bufs.key, cmk.SizeBinary())
+	if err := cmk.MarshalBinaryToSizedBuffer(bufs.key); err != nil {
+		return fmt.Errorf("failed to marshal combined metrics key: %w", err)
+	}
+	bufs.value = resizeBuffer(bufs.value, cm.SizeVT())
+	if _, err := cm.MarshalToSizedBufferVT(bufs.value); err != nil {
+		return fmt.Errorf("failed to marshal combined metrics: %w", err)
 	}
-	if _, err := cm.MarshalToSizedBufferVT(op.Value); err != nil {
-		return 0, fmt.Errorf("failed to marshal combined metrics: %w", err)
+	encoded, err := a.codec.encodeTo(bufs, bufs.value)
+	if err != nil {
+		return fmt.Errorf("failed to encode combined metrics: %w", err)
 	}
-	if err := op.Finish(); err != nil {
-		return 0, fmt.Errorf("failed to finalize merge operation: %w", err)
+	// The batch copies the key and the value, the buffers are reused.
+	if err := batch.Merge(bufs.key, encoded, nil); err != nil {
+		return fmt.Errorf("failed to add merge operation: %w", err)
 	}
+	a.sampleDictionaryValue(bufs.value)
+	return nil
+}
 
-	bytesIn := cm.SizeVT()
-	if a.batch.Len() >= dbCommitThresholdBytes {
-		if err := a.batch.Commit(a.writeOptions); err != nil {
-			return bytesIn, fmt.Errorf("failed to commit pebble batch: %w", err)
+// sampleDictionaryValue samples the protobuf encoded value for training
+// the compression dictionary. Once enough values are sampled the
+// dictionary is trained, persisted and used for compressing the values
+// aggregated thereafter. If training fails, the dictionary is trained
+// again once more values are sampled. Must be called with the lock held.
+func (a *Aggregator) sampleDictionaryValue(value []byte) {
+	if a.cfg.DictionarySamples == 0 || a.codec.hasDictionary() {
+		return
+	}
+	// The value is copied as it is encoded into a reused buffer.
+	a.dictSamples = append(a.dictSamples, append([]byte(nil), value...))
+	if len(a.dictSamples) < a.cfg.DictionarySamples {
+		return
+	}
+	samples := a.dictSamples
+	a.dictSamples = nil
+	dict, err := a.cfg.DictionaryTrainer(samples, maxDictionarySize)
+	if err == nil {
+		err = a.codec.setDictionary(dict, a.dictDir)
+	}
+	if err != nil {
+		a.cfg.Logger.Warn("failed to train compression dictionary", zap.Error(err))
+		return
+	}
+	a.cfg.Logger.Info(
+		"trained compression dictionary",
+		zap.Int("samples", len(samples)),
+		zap.Int("size", len(dict)),
+	)
+}
+
+// awaitPendingBytes checks the bytes pending to be committed against the
+// configured maximum. If the maximum is exceeded then it either returns
+// ErrBackpressure or, if configured to block, waits until enough pending
+// bytes are committed. Must be called with the lock held, which is
+// released while waiting.
+func (a *Aggregator) awaitPendingBytes(ctx context.Context) error {
+	for {
+		// Acquire the channel before checking the pending bytes so that
+		// a release in between is not missed.
+		a.pendingMu.Lock()
+		released := a.pendingReleased
+		a.pendingMu.Unlock()
+		if !a.pendingBytesExceeded() {
+			return nil
+		}
+		if !a.cfg.BlockOnBackpressure {
+			return ErrBackpressure
 		}
-		if err := a.batch.Close(); err != nil {
-			return bytesIn, fmt.Errorf("failed to close pebble batch: %w", err)
+		a.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			a.mu.Lock()
+			return ctx.Err()
+		case <-a.closed:
+			a.mu.Lock()
+			return ErrAggregatorClosed
+		case <-released:
 		}
-		a.batch = nil
+		a.mu.Lock()
 	}
-	return bytesIn, nil
+}
+
+// pendingBytesExceeded returns true if the bytes not yet committed to the
+// database exceed the configured maximum. Must be called with the lock held.
+func (a *Aggregator) pendingBytesExceeded() bool {
+	if a.cfg.MaxPendingBytes == 0 {
+		return false
+	}
+	pending := a.inflightBytes.Load() + a.pendingBatchBytes()
+	return pending >= int64(a.cfg.MaxPendingBytes)
+}
+
+// releasePendingBytes marks the batch taken by the harvest loop as
+// committed.
+func (a *Aggregator) releasePendingBytes() {
+	if a.inflightBytes.Swap(0) == 0 {
+		return
+	}
+	a.signalPendingReleased()
+}
+
+// signalPendingReleased wakes up all writers blocked on pending bytes.
+func (a *Aggregator) signalPendingReleased() {
+	a.pendingMu.Lock()
+	defer a.pendingMu.Unlock()
+	close(a.pendingReleased)
+	a.pendingReleased = make(chan struct{})
 }
 
 func (a *Aggregator) commitAndHarvest(
 	ctx context.Context,
-	batch *pebble.Batch,
+	batch pendingBatches,
 	to time.Time,
+	ivls []time.Duration,
 	cachedEventsStats map[time.Duration]map[[16]byte]float64,
 ) error {
 	ctx, span := a.cfg.Tracer.Start(ctx, "commitAndHarvest")
@@ -382,16 +1358,26 @@ func (a *Aggregator) commitAndHarvest(
 
 	var errs []error
 	if batch != nil {
-		if err := batch.Commit(a.writeOptions); err != nil {
+		if err := a.commitTakenBatch(batch); err != nil {
 			span.RecordError(err)
-			errs = append(errs, fmt.Errorf("failed to commit batch before harvest: %w", err))
+			errs = append(errs, err)
 		}
-		if err := batch.Close(); err != nil {
+	}
+	if a.cfg.MaxAdaptivePartitions > 0 && cachedEventsStats != nil &&
+		slices.Contains(ivls, a.cfg.AggregationIntervals[0]) {
+		a.withLock(func() {
+			a.adaptPartitions(to, cachedEventsStats[a.cfg.AggregationIntervals[0]])
+		})
+	}
+	if a.tiers != nil {
+		var err error
+		a.withLock(func() { err = a.reconcileTiers(to) })
+		if err != nil {
 			span.RecordError(err)
-			errs = append(errs, fmt.Errorf("failed to close batch before harvest: %w", err))
+			errs = append(errs, fmt.Errorf("failed to reconcile tiers before harvest: %w", err))
 		}
 	}
-	if err := a.harvest(ctx, to, cachedEventsStats); err != nil {
+	if err := a.harvest(ctx, to, ivls, cachedEventsStats); err != nil {
 		span.RecordError(err)
 		errs = append(errs, fmt.Errorf("failed to harvest aggregated metrics: %w", err))
 	}
@@ -401,36 +1387,57 @@ func (a *Aggregator) commitAndHarvest(
 	return nil
 }
 
-// harvest collects the mature metrics for all aggregation intervals and
-// deletes the entries in db once the metrics are fully harvested. Harvest
-// takes an end time denoting the exclusive upper bound for harvesting.
+// commitTakenBatch commits and closes the batches taken by the harvest
+// loop and releases their pending bytes.
+func (a *Aggregator) commitTakenBatch(batch pendingBatches) error {
+	var errs []error
+	if err := a.health.recordStorageError(a.commitBatch(batch)); err != nil {
+		errs = append(errs, fmt.Errorf("failed to commit batch before harvest: %w", err))
+	}
+	if err := batch.close(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to close batch before harvest: %w", err))
+	}
+	a.releasePendingBytes()
+	return errors.Join(errs...)
+}
+
+// harvest collects the mature metrics for the given aggregation intervals
+// and deletes the entries in db once the metrics are fully harvested.
+// Harvest takes an end time denoting the exclusive upper bound for
+// harvesting.
 func (a *Aggregator) harvest(
 	ctx context.Context,
 	end time.Time,
+	ivls []time.Duration,
 	cachedEventsStats map[time.Duration]map[[16]byte]float64,
 ) error {
-	snap := a.db.NewSnapshot()
-	defer snap.Close()
-
 	var errs []error
-	for _, ivl := range a.cfg.AggregationIntervals {
+	for _, ivl := range ivls {
 		// Check if the given aggregation interval needs to be harvested now
 		if end.Truncate(ivl).Equal(end) {
-			start := end.Add(-ivl)
-			cmCount, err := a.harvestForInterval(
-				ctx, snap, start, end, ivl, cachedEventsStats[ivl],
+			// The cached events stats are consumed by the harvest so that
+			// they are not recorded again by a harvest retried after a
+			// crash.
+			stats := cachedEventsStats[ivl]
+			delete(cachedEventsStats, ivl)
+			start, upper := a.harvestBounds(end, ivl)
+			cmCount, err := a.harvestIntervalSnapshot(
+				ctx, start, upper, ivl, stats, false,
 			)
 			if err != nil {
 				errs = append(errs, fmt.Errorf(
 					"failed to harvest aggregated metrics for interval %s: %w",
 					ivl, err,
 				))
+			} else {
+				a.health.recordHarvest(ivl, upper)
 			}
+			a.reportLosses(ivl, end.Add(-ivl))
 			a.cfg.Logger.Debug(
 				"Finished harvesting aggregated metrics",
 				zap.Int("combined_metrics_successfully_harvested", cmCount),
 				zap.Duration("aggregation_interval_ns", ivl),
-				zap.Time("harvested_till(exclusive)", end),
+				zap.Time("harvested_till(exclusive)", upper),
 				zap.Error(err),
 			)
 		}
@@ -438,16 +1445,105 @@ func (a *Aggregator) harvest(
 	return errors.Join(errs...)
 }
 
-// harvestForInterval harvests aggregated metrics for a given interval.
-// Returns the number of combined metrics successfully harvested and an
-// error. It is possible to have non nil error and greater than 0
-// combined metrics if some of the combined metrics failed harvest.
+// harvestStale harvests the combined metrics of processing times older
+// than the current period of each aggregation interval. These are left
+// behind by a previous run which crashed before harvesting them.
+func (a *Aggregator) harvestStale(ctx context.Context) error {
+	var processingTime time.Time
+	var reconcileErr error
+	a.withLock(func() {
+		processingTime = a.processingTime
+		reconcileErr = a.reconcileTiers(processingTime)
+	})
+
+	var errs []error
+	if reconcileErr != nil {
+		errs = append(errs, fmt.Errorf("failed to reconcile tiers before recovery: %w", reconcileErr))
+	}
+	for _, ivl := range a.cfg.AggregationIntervals {
+		end := processingTime.Truncate(ivl)
+		cmCount, err := a.harvestIntervalSnapshot(
+			ctx, time.Unix(0, 0), end, ivl, nil, true,
+		)
+		if err != nil {
+			errs = append(errs, fmt.Errorf(
+				"failed to recover aggregated metrics for interval %s: %w",
+				ivl, err,
+			))
+		}
+		if cmCount > 0 {
+			a.cfg.Logger.Info(
+				"Recovered stale aggregated metrics",
+				zap.Int("combined_metrics_recovered", cmCount),
+				zap.Duration("aggregation_interval_ns", ivl),
+				zap.Time("recovered_till(exclusive)", end),
+			)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// harvestIntervalSnapshot harvests aggregated metrics for a given interval
+// from a new snapshot of each shard. The snapshots are taken per interval
+// so that the metrics rolled up from the lowest interval are harvested for
+// the higher intervals, see WithIntervalRollups.
+func (a *Aggregator) harvestIntervalSnapshot(
+	ctx context.Context,
+	start, end time.Time,
+	ivl time.Duration,
+	cachedEventsStats map[[16]byte]float64,
+	recovery bool,
+) (int, error) {
+	// caching and publishing events total metrics at this point helps reduce
+	// the time gap between total and processed metrics to a max of the lowest
+	// aggregation interval. This gap can be introduced if L1 aggregators are
+	// stopped when the L2 aggregator is waiting for harvest delay leading to
+	// premature harvest as part of the graceful shutdown process.
+	ivlAttr := attribute.String(aggregationIvlKey, formatDuration(ivl))
+	ctx, span := a.cfg.Tracer.Start(ctx, "HarvestInterval", trace.WithAttributes(
+		ivlAttr,
+		attribute.String("harvested_till", end.String()),
+		attribute.Int("partitions", int(a.cfg.Partitions)),
+		attribute.Bool("recovery", recovery),
+	))
+	defer span.End()
+	for cmID, eventsTotal := range cachedEventsStats {
+		attrs := append(a.cfg.CombinedMetricsIDToKVs(cmID), ivlAttr)
+		a.metrics.EventsTotal.Add(ctx, eventsTotal, metric.WithAttributes(attrs...))
+	}
+
+	var cmCount int
+	var errs []error
+	for _, shard := range a.shards {
+		snap := shard.NewSnapshot()
+		n, err := a.harvestForInterval(ctx, shard, snap, start, end, ivl, recovery)
+		snap.Close()
+		cmCount += n
+		if err != nil {
+			span.RecordError(err)
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 0 {
+		a.pruneTier(ivl, start, end)
+	}
+	span.SetAttributes(attribute.Int("combined_metrics_harvested", cmCount))
+	return cmCount, errors.Join(errs...)
+}
+
+// harvestForInterval harvests aggregated metrics for a given interval from
+// the snapshot of the shard. Returns the number of combined metrics
+// successfully harvested and an error. It is possible to have non nil
+// error and greater than 0 combined metrics if some of the combined
+// metrics failed harvest. The harvested events are also recorded as
+// recovered if recovery is true.
 func (a *Aggregator) harvestForInterval(
 	ctx context.Context,
+	shard *pebble.DB,
 	snap *pebble.Snapshot,
 	start, end time.Time,
 	ivl time.Duration,
-	cachedEventsStats map[[16]byte]float64,
+	recovery bool,
 ) (int, error) {
 	from := CombinedMetricsKey{
 		Interval:       ivl,
@@ -462,17 +1558,7 @@ func (a *Aggregator) harvestForInterval(
 	from.MarshalBinaryToSizedBuffer(lb)
 	to.MarshalBinaryToSizedBuffer(ub)
 
-	// caching and publishing events total metrics at this point helps reduce
-	// the time gap between total and processed metrics to a max of the lowest
-	// aggregation interval. This gap can be introduced if L1 aggregators are
-	// stopped when the L2 aggregator is waiting for harvest delay leading to
-	// premature harvest as part of the graceful shutdown process.
 	ivlAttr := attribute.String(aggregationIvlKey, formatDuration(ivl))
-	for cmID, eventsTotal := range cachedEventsStats {
-		attrs := append(a.cfg.CombinedMetricsIDToKVs(cmID), ivlAttr)
-		a.metrics.EventsTotal.Add(ctx, eventsTotal, metric.WithAttributes(attrs...))
-	}
-
 	iter := snap.NewIter(&pebble.IterOptions{
 		LowerBound: lb,
 		UpperBound: ub,
@@ -480,43 +1566,135 @@ func (a *Aggregator) harvestForInterval(
 	})
 	defer iter.Close()
 
-	var errs []error
-	var cmCount int
+	var rollups *pebble.Batch
+	if a.rollsUp(ivl) {
+		rollups = shard.NewBatch()
+		defer rollups.Close()
+	}
+
+	results := harvestResults{
+		failedIDs: make(map[[16]byte]struct{}),
+	}
+	// The combined metrics processed before a crash, by a previous run or
+	// by the crashed harvest restarted by the supervisor, are skipped.
+	skipProcessed := recovery || a.runState.restarting()
+	var recoveredCheckpoints map[[16]byte]time.Time
+	if skipProcessed {
+		recoveredCheckpoints = make(map[[16]byte]time.Time)
+	}
+	// checkpoint records the checkpoint of the ID and processing time of
+	// the key once all their partitions are processed, unless processing
+	// any combined metrics of the ID failed.
+	checkpointGroup := func(cmk CombinedMetricsKey) {
+		if results.failed(cmk.ID) {
+			return
+		}
+		if err := a.health.recordStorageError(
+			a.recordCheckpoint(cmk.ID, ivl, cmk.ProcessingTime),
+		); err != nil {
+			results.addError(err)
+		}
+	}
+	// With harvest concurrency, the combined metrics are distributed to
+	// the workers by ID to preserve the processing order per ID.
+	var workers []chan harvestJob
+	var wg sync.WaitGroup
+	if a.cfg.HarvestConcurrency > 1 {
+		workers = make([]chan harvestJob, a.cfg.HarvestConcurrency)
+		for i := range workers {
+			jobs := make(chan harvestJob, 1)
+			workers[i] = jobs
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for job := range jobs {
+					if job.checkpoint {
+						checkpointGroup(job.cmk)
+						continue
+					}
+					results.add(job.cmk, a.harvestCombinedMetrics(ctx, job.cmk, job.value, ivl, ivlAttr, recovery))
+				}
+			}()
+		}
+	}
+	// The keys are ordered by processing time and ID, the partitions of an
+	// ID and processing time are harvested once the iterator moves past
+	// them. The checkpoint is recorded after the rollups of the partitions
+	// are committed so that the rollups are not skipped when recovering
+	// from a crash.
+	var last CombinedMetricsKey
+	var pending bool
+	endGroup := func() {
+		if !pending {
+			return
+		}
+		pending = false
+		if rollups != nil && rollups.Count() > 0 {
+			if err := a.health.recordStorageError(rollups.Commit(a.writeOptions)); err != nil {
+				results.addError(fmt.Errorf("failed to commit interval rollups: %w", err))
+				results.fail(last.ID)
+			}
+			rollups.Reset()
+		}
+		if workers == nil {
+			checkpointGroup(last)
+			return
+		}
+		workers[xxhash.Sum64(last.ID[:])%uint64(len(workers))] <- harvestJob{cmk: last, checkpoint: true}
+	}
 	for iter.First(); iter.Valid(); iter.Next() {
 		var cmk CombinedMetricsKey
 		if err := cmk.UnmarshalBinary(iter.Key()); err != nil {
-			errs = append(errs, fmt.Errorf("failed to unmarshal key: %w", err))
+			results.addError(fmt.Errorf("failed to unmarshal key: %w", err))
 			continue
 		}
-		harvestStats, err := a.processHarvest(ctx, cmk, iter.Value(), ivl)
-		if err != nil {
-			errs = append(errs, err)
+		if cmk.ID != last.ID || !cmk.ProcessingTime.Equal(last.ProcessingTime) {
+			endGroup()
+		}
+		if skipProcessed {
+			// Skip the combined metrics which were processed by a harvest
+			// that crashed before deleting them.
+			checkpoint, ok := recoveredCheckpoints[cmk.ID]
+			if !ok {
+				var err error
+				if checkpoint, err = a.checkpoint(cmk.ID, ivl); err != nil {
+					results.addError(err)
+				}
+				recoveredCheckpoints[cmk.ID] = checkpoint
+			}
+			if !cmk.ProcessingTime.After(checkpoint) {
+				continue
+			}
+		}
+		last, pending = cmk, true
+		if rollups != nil {
+			// The value is rolled up before processing as the processor
+			// can mutate the combined metrics.
+			if err := a.rollup(rollups, cmk, iter.Value()); err != nil {
+				results.addError(err)
+				results.fail(cmk.ID)
+			}
+		}
+		if workers == nil {
+			results.add(cmk, a.harvestCombinedMetrics(ctx, cmk, iter.Value(), ivl, ivlAttr, recovery))
 			continue
 		}
-		cmCount++
-
-		attrs := append(a.cfg.CombinedMetricsIDToKVs(cmk.ID), ivlAttr)
-		attrSet := metric.WithAttributeSet(attribute.NewSet(attrs...))
-		// processingDelay is normalized by subtracting aggregation interval and
-		// harvest delay, both of which are expected delays. Normalization helps
-		// us to use the lower (higher resolution) range of the histogram for the
-		// important values. The normalized processingDelay can be negative as a
-		// result of premature harvest triggered by a stop of the aggregator. The
-		// negative value is accepted as a good value and recorded in the lower
-		// histogram buckets.
-		processingDelay := time.Since(cmk.ProcessingTime).Seconds() -
-			(ivl.Seconds() + a.cfg.HarvestDelay.Seconds())
-		// queuedDelay is not explicitly normalized because we want to record the
-		// full delay. For a healthy deployment, the queued delay would be
-		// implicitly normalized due to the usage of youngest event timestamp.
-		// Negative values are possible at edges due to delays in running the
-		// harvest loop or time sync issues between agents and server.
-		queuedDelay := time.Since(harvestStats.youngestEventTimestamp).Seconds()
-		a.metrics.MinQueuedDelay.Record(ctx, queuedDelay, attrSet)
-		a.metrics.ProcessingDelay.Record(ctx, processingDelay, attrSet)
-		a.metrics.EventsProcessed.Add(ctx, harvestStats.eventsTotal, attrSet)
-	}
-	err := a.db.DeleteRange(lb, ub, a.writeOptions)
+		// The value is only valid until the iterator is moved.
+		value := append([]byte(nil), iter.Value()...)
+		workers[xxhash.Sum64(cmk.ID[:])%uint64(len(workers))] <- harvestJob{cmk: cmk, value: value}
+	}
+	endGroup()
+	for _, jobs := range workers {
+		close(jobs)
+	}
+	wg.Wait()
+
+	errs, cmCount := results.errs, results.cmCount
+	var err error
+	if iterErr := a.health.recordStorageError(iter.Error()); iterErr != nil {
+		err = fmt.Errorf("failed to iterate combined metrics: %w", iterErr)
+	}
+	err = errors.Join(err, a.health.recordStorageError(shard.DeleteRange(lb, ub, a.writeOptions)))
 	if len(errs) > 0 {
 		err = errors.Join(err, fmt.Errorf(
 			"failed to process %d out of %d metrics:\n%w",
@@ -526,9 +1704,215 @@ func (a *Aggregator) harvestForInterval(
 	return cmCount, err
 }
 
+// harvestJob is a combined metrics to be harvested by a harvest worker.
+type harvestJob struct {
+	cmk   CombinedMetricsKey
+	value []byte
+	// checkpoint marks the end of the partitions of the ID and processing
+	// time of the key, recording their checkpoint instead of harvesting.
+	checkpoint bool
+}
+
+// harvestResults collects the results of harvesting the combined metrics
+// of an aggregation interval, possibly by concurrent harvest workers.
+type harvestResults struct {
+	mu        sync.Mutex
+	errs      []error
+	cmCount   int
+	failedIDs map[[16]byte]struct{}
+}
+
+// add records the result of harvesting the combined metrics of the key.
+func (r *harvestResults) add(cmk CombinedMetricsKey, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.errs = append(r.errs, err)
+		r.failedIDs[cmk.ID] = struct{}{}
+		return
+	}
+	r.cmCount++
+}
+
+// fail records a failure of the combined metrics ID not specific to a
+// harvested combined metrics, preventing its checkpoints.
+func (r *harvestResults) fail(id [16]byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failedIDs[id] = struct{}{}
+}
+
+// failed returns true if harvesting any combined metrics of the ID failed.
+func (r *harvestResults) failed(id [16]byte) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.failedIDs[id]
+	return ok
+}
+
+// addError records an error not specific to a harvested combined metrics.
+func (r *harvestResults) addError(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errs = append(r.errs, err)
+}
+
+// harvestCombinedMetrics processes the encoded combined metrics of the key
+// and records the harvest metrics and coverage gaps.
+func (a *Aggregator) harvestCombinedMetrics(
+	ctx context.Context,
+	cmk CombinedMetricsKey,
+	value []byte,
+	ivl time.Duration,
+	ivlAttr attribute.KeyValue,
+	recovery bool,
+) error {
+	attrs := append(a.cfg.CombinedMetricsIDToKVs(cmk.ID), ivlAttr)
+	traceAttrs := append(append([]attribute.KeyValue{}, attrs...),
+		attribute.String("processing_time", cmk.ProcessingTime.String()),
+		attribute.Int("partition_id", int(cmk.PartitionID)),
+		attribute.Int("bytes", len(value)))
+	processCtx, span := a.cfg.Tracer.Start(ctx, "ProcessorInvoke", trace.WithAttributes(traceAttrs...))
+	harvestStats, err := a.processHarvest(processCtx, cmk, value, ivl)
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+	if err != nil {
+		a.cfg.Logger.Warn(
+			"failed to harvest combined metrics",
+			errorClassField(harvestStats.errClass),
+			zap.Duration("aggregation_interval_ns", ivl),
+			zap.Time("processing_time", cmk.ProcessingTime),
+			zap.Uint16("partition_id", cmk.PartitionID),
+			zap.Error(err),
+		)
+	} else if fields := harvestStats.overflow.logFields(); len(fields) > 0 {
+		a.cfg.Logger.Info(
+			"harvested combined metrics exceeded aggregation limits",
+			append(fields,
+				errorClassField(limitOverflowErrorClass),
+				zap.Duration("aggregation_interval_ns", ivl),
+				zap.Time("processing_time", cmk.ProcessingTime),
+			)...,
+		)
+	}
+	attrSet := metric.WithAttributeSet(attribute.NewSet(attrs...))
+	if gap, ok := a.coverage.harvested(cmk.ID, ivl, cmk.ProcessingTime); ok {
+		a.reportCoverageGap(ctx, gap, attrSet)
+	}
+	if err != nil {
+		a.reportCoverageGap(ctx, CoverageGap{
+			ID:       cmk.ID,
+			Interval: ivl,
+			Start:    cmk.ProcessingTime,
+			End:      cmk.ProcessingTime.Add(ivl),
+			Err:      err,
+		}, attrSet)
+		a.recordHarvestLoss(ctx, cmk, attrs, 0, harvestStats.eventsTotal)
+		return err
+	}
+
+	// processingDelay is normalized by subtracting aggregation interval and
+	// harvest delay, both of which are expected delays. Normalization helps
+	// us to use the lower (higher resolution) range of the histogram for the
+	// important values. The normalized processingDelay can be negative as a
+	// result of premature harvest triggered by a stop of the aggregator. The
+	// negative value is accepted as a good value and recorded in the lower
+	// histogram buckets.
+	now := a.cfg.Clock.Now()
+	processingDelay := now.Sub(cmk.ProcessingTime).Seconds() -
+		(ivl.Seconds() + a.cfg.HarvestDelay.Seconds() + a.cfg.HarvestOffsets[ivl].Seconds())
+	// queuedDelay is not explicitly normalized because we want to record the
+	// full delay. For a healthy deployment, the queued delay would be
+	// implicitly normalized due to the usage of youngest event timestamp.
+	// Negative values are possible at edges due to delays in running the
+	// harvest loop or time sync issues between agents and server.
+	queuedDelay := now.Sub(harvestStats.youngestEventTimestamp).Seconds()
+	// freshnessDelay is the full delay between the end of the aggregation
+	// interval and the successful completion of the processor, allowing
+	// SLOs to be defined on the freshness of the harvested metrics.
+	freshnessDelay := now.Sub(cmk.ProcessingTime.Add(ivl)).Seconds()
+	a.metrics.MinQueuedDelay.Record(ctx, queuedDelay, attrSet)
+	a.metrics.ProcessingDelay.Record(ctx, processingDelay, attrSet)
+	a.metrics.FreshnessDelay.Record(ctx, freshnessDelay, attrSet)
+	a.metrics.EventsProcessed.Add(ctx, harvestStats.eventsTotal, attrSet)
+	if recovery {
+		a.metrics.EventsRecovered.Add(ctx, harvestStats.eventsTotal, attrSet)
+	}
+	harvestStats.overflow.record(ctx, a.metrics.OverflowGroups, attrs)
+	a.recordHarvestLoss(ctx, cmk, attrs, harvestStats.overflowEvents, 0)
+	return nil
+}
+
+// rollsUp returns true if the combined metrics of the given aggregation
+// interval are rolled up into the higher aggregation intervals.
+func (a *Aggregator) rollsUp(ivl time.Duration) bool {
+	return a.cfg.IntervalRollups &&
+		len(a.cfg.AggregationIntervals) > 1 &&
+		ivl == a.cfg.AggregationIntervals[0]
+}
+
+// rollup merges the encoded combined metrics harvested for the lowest
+// aggregation interval into the combined metrics of the higher intervals
+// using the batch. The batch is committed, and reset, once it exceeds the
+// commit threshold.
+func (a *Aggregator) rollup(batch *pebble.Batch, cmk CombinedMetricsKey, value []byte) error {
+	for _, ivl := range a.cfg.AggregationIntervals[1:] {
+		rk := cmk
+		rk.Interval = ivl
+		rk.ProcessingTime = cmk.ProcessingTime.Truncate(ivl)
+		op := batch.MergeDeferred(rk.SizeBinary(), len(value))
+		if err := rk.MarshalBinaryToSizedBuffer(op.Key); err != nil {
+			return fmt.Errorf("failed to marshal combined metrics key: %w", err)
+		}
+		copy(op.Value, value)
+		if err := op.Finish(); err != nil {
+			return fmt.Errorf("failed to finalize merge operation: %w", err)
+		}
+		a.storeTier(rk)
+	}
+	if batch.Len() >= dbCommitThresholdBytes {
+		if err := batch.Commit(a.writeOptions); err != nil {
+			return fmt.Errorf("failed to commit interval rollups: %w", err)
+		}
+		batch.Reset()
+	}
+	return nil
+}
+
+// reportCoverageGap records the coverage gap and passes it to the
+// configured coverage gap handler, if any.
+func (a *Aggregator) reportCoverageGap(
+	ctx context.Context,
+	gap CoverageGap,
+	attrSet metric.MeasurementOption,
+) {
+	a.metrics.HarvestGaps.Add(ctx, gap.Periods(), attrSet)
+	if a.cfg.CoverageGapHandler != nil {
+		a.cfg.CoverageGapHandler(gap)
+	}
+}
+
+// harvestLoopCrashError is returned when a harvest crashes due to a panic.
+type harvestLoopCrashError struct {
+	recovered any
+	stack     []byte
+}
+
+func (e *harvestLoopCrashError) Error() string {
+	return fmt.Sprintf("harvest loop crashed: %v", e.recovered)
+}
+
 type harvestStats struct {
 	eventsTotal            float64
 	youngestEventTimestamp time.Time
+	overflow               overflowCounts
+	// overflowEvents is the representative count of the events aggregated
+	// into the overflow buckets.
+	overflowEvents float64
+	// errClass classifies the error returned with the stats, if any.
+	errClass errorClass
 }
 
 func (a *Aggregator) processHarvest(
@@ -537,20 +1921,26 @@ func (a *Aggregator) processHarvest(
 	cmb []byte,
 	aggIvl time.Duration,
 ) (harvestStats, error) {
+	if a.cfg.HarvestChunkServices > 0 {
+		return a.processHarvestChunks(ctx, cmk, cmb, aggIvl)
+	}
 	var hs harvestStats
 	cm := aggregationpb.CombinedMetricsFromVTPool()
 	defer cm.ReturnToVTPool()
-	if err := cm.UnmarshalVT(cmb); err != nil {
+	if err := a.codec.unmarshal(cmb, cm); err != nil {
+		hs.errClass = mergeErrorClass
 		return hs, fmt.Errorf("failed to unmarshal metrics: %w", err)
 	}
 	// Processor can mutate the CombinedMetrics, so we cannot rely on the
 	// CombinedMetrics after Processor is called.
-	eventsTotal := cm.EventsTotal
-	youngestEventTS := timestamppb.PBTimestampToTime(cm.YoungestEventTimestamp)
-	if err := a.cfg.Processor(ctx, cmk, cm, aggIvl); err != nil {
+	hs.eventsTotal = cm.EventsTotal
+	hs.youngestEventTimestamp = timestamppb.PBTimestampToTime(cm.YoungestEventTimestamp)
+	hs.overflow.add(cm)
+	hs.overflowEvents = overflowEventCount(cm)
+	// The events total is returned on failure for the event loss accounting.
+	if err := a.process(ctx, cmk, cm, aggIvl); err != nil {
+		hs.errClass = processorErrorClass
 		return hs, fmt.Errorf("failed to process combined metrics ID %s: %w", cmk.ID, err)
 	}
-	hs.eventsTotal = eventsTotal
-	hs.youngestEventTimestamp = youngestEventTS
 	return hs, nil
 }
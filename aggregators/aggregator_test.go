@@ -6,11 +6,13 @@ package aggregators
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/rand"
 	"net/netip"
 	"sort"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -28,6 +30,7 @@ import (
 	apmmodel "go.elastic.co/apm/v2/model"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"golang.org/x/sync/errgroup"
@@ -168,6 +171,7 @@ func TestAggregateBatch(t *testing.T) {
 				"aggregator.events.total":     {Value: float64(len(batch))},
 				"aggregator.events.processed": {Value: float64(len(batch))},
 				"events.processing-delay":     {Type: "histogram", Counts: []uint64{1}, Values: []float64{0}},
+				"events.freshness-delay":      {Type: "histogram", Counts: []uint64{1}, Values: []float64{0}},
 				"events.queued-delay":         {Type: "histogram", Counts: []uint64{1}, Values: []float64{0}},
 			},
 			Labels: apmmodel.StringMap{
@@ -208,8 +212,11 @@ func TestAggregateBatch(t *testing.T) {
 			AddSpan(spanKey, WithSpanDuration(eventDuration)).
 			AddSpan(dssKey, WithSpanDuration(dssDuration))
 	}
+	// Harvested combined metrics are decoded from stored values.
+	expectedProto := expectedCombinedMetrics.GetProto()
+	expectedProto.Version = combinedMetricsVersion
 	assert.Empty(t, cmp.Diff(
-		expectedCombinedMetrics.GetProto(), cm,
+		expectedProto, cm,
 		append(combinedMetricsSliceSorters,
 			cmpopts.EquateEmpty(),
 			cmpopts.EquateApprox(0, 0.01),
@@ -223,7 +230,8 @@ func TestAggregateBatch(t *testing.T) {
 		expectedMeasurements,
 		gatherMetrics(
 			gatherer,
-			withIgnoreMetricPrefix("pebble."),
+			// Merges depend on the background pebble compactions.
+			withIgnoreMetricPrefix("pebble.", "aggregator.storage.", "aggregator.merge."),
 			withZeroHistogramValues(true),
 		),
 		cmpopts.IgnoreUnexported(apmmodel.Time{}),
@@ -231,6 +239,69 @@ func TestAggregateBatch(t *testing.T) {
 	))
 }
 
+func TestHarvestSpans(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSyncer(exp),
+	)
+	cmID := EncodeToCombinedMetricsKeyID(t, "ab01")
+	agg, err := New(
+		WithDataDir(t.TempDir()),
+		WithLimits(Limits{
+			MaxSpanGroups:                         10,
+			MaxSpanGroupsPerService:               10,
+			MaxTransactionGroups:                  10,
+			MaxTransactionGroupsPerService:        10,
+			MaxServiceTransactionGroups:           10,
+			MaxServiceTransactionGroupsPerService: 10,
+			MaxServices:                           10,
+			MaxServiceInstanceGroupsPerService:    10,
+		}),
+		WithProcessor(noOpProcessor()),
+		WithAggregationIntervals([]time.Duration{time.Minute}),
+		WithHarvestDelay(time.Hour), // disable auto harvest
+		WithTracer(tp.Tracer("test")),
+	)
+	require.NoError(t, err)
+
+	ts := time.Now()
+	for i := 0; i < 2; i++ {
+		batch := modelpb.Batch{lateTestEvent(ts)}
+		require.NoError(t, agg.AggregateBatch(context.Background(), cmID, &batch))
+	}
+	require.NoError(t, agg.Close(context.Background()))
+
+	spans := make(map[string]tracetest.SpanStub)
+	for _, s := range exp.GetSpans() {
+		spans[s.Name] = s
+	}
+	attrs := func(name string) map[attribute.Key]attribute.Value {
+		span, ok := spans[name]
+		require.True(t, ok, "span %s not found", name)
+		out := make(map[attribute.Key]attribute.Value)
+		for _, kv := range span.Attributes {
+			out[kv.Key] = kv.Value
+		}
+		return out
+	}
+
+	harvest := attrs("HarvestInterval")
+	assert.Equal(t, "1m", harvest[aggregationIvlKey].AsString())
+	assert.Equal(t, int64(1), harvest["partitions"].AsInt64())
+	assert.Equal(t, int64(1), harvest["combined_metrics_harvested"].AsInt64())
+
+	process := attrs("ProcessorInvoke")
+	assert.Equal(t, "1m", process[aggregationIvlKey].AsString())
+	assert.Equal(t, int64(0), process["partition_id"].AsInt64())
+	assert.Greater(t, process["bytes"].AsInt64(), int64(0))
+	assert.Equal(t, spans["HarvestInterval"].SpanContext.SpanID(), spans["ProcessorInvoke"].Parent.SpanID())
+
+	merge := attrs("MergeCombinedMetrics")
+	assert.Equal(t, int64(2), merge["operands"].AsInt64())
+	assert.Greater(t, merge["bytes"].AsInt64(), int64(0))
+	assert.Greater(t, merge["merged_bytes"].AsInt64(), int64(0))
+}
+
 func TestAggregateSpanMetrics(t *testing.T) {
 	type input struct {
 		serviceName         string
@@ -728,17 +799,18 @@ func TestHarvest(t *testing.T) {
 	gatherer, err := apmotel.NewGatherer()
 	require.NoError(t, err)
 
+	limits := Limits{
+		MaxSpanGroups:                         1000,
+		MaxTransactionGroups:                  100,
+		MaxTransactionGroupsPerService:        10,
+		MaxServiceTransactionGroups:           100,
+		MaxServiceTransactionGroupsPerService: 10,
+		MaxServices:                           10,
+		MaxServiceInstanceGroupsPerService:    10,
+	}
 	agg, err := New(
 		WithDataDir(t.TempDir()),
-		WithLimits(Limits{
-			MaxSpanGroups:                         1000,
-			MaxTransactionGroups:                  100,
-			MaxTransactionGroupsPerService:        10,
-			MaxServiceTransactionGroups:           100,
-			MaxServiceTransactionGroupsPerService: 10,
-			MaxServices:                           10,
-			MaxServiceInstanceGroupsPerService:    10,
-		}),
+		WithLimits(limits),
 		WithProcessor(processor),
 		WithAggregationIntervals(ivls),
 		WithMeter(metric.NewMeterProvider(metric.WithReader(gatherer)).Meter("test")),
@@ -763,6 +835,15 @@ func TestHarvest(t *testing.T) {
 		},
 	})
 	expectedMeasurements := make([]apmmodel.Metrics, 0, cmCount+(cmCount*len(ivls)))
+	expectedMeasurements = append(expectedMeasurements, limitsMeasurements(limits)...)
+	expectedMeasurements = append(expectedMeasurements, apmmodel.Metrics{
+		Samples: map[string]apmmodel.Metric{
+			"aggregator.config": {Value: 1},
+		},
+		Labels: apmmodel.StringMap{
+			apmmodel.StringMapItem{Key: "fingerprint", Value: agg.ConfigFingerprint()},
+		},
+	})
 	for i := 0; i < cmCount; i++ {
 		cmID := EncodeToCombinedMetricsKeyID(t, fmt.Sprintf("ab%2d", i))
 		require.NoError(t, agg.AggregateBatch(context.Background(), cmID, &batch))
@@ -781,6 +862,7 @@ func TestHarvest(t *testing.T) {
 					"aggregator.events.total":     {Value: float64(len(batch))},
 					"aggregator.events.processed": {Value: float64(len(batch))},
 					"events.processing-delay":     {Type: "histogram", Counts: []uint64{1}, Values: []float64{0}},
+					"events.freshness-delay":      {Type: "histogram", Counts: []uint64{1}, Values: []float64{0}},
 					"events.queued-delay":         {Type: "histogram", Counts: []uint64{1}, Values: []float64{0}},
 				},
 				Labels: apmmodel.StringMap{
@@ -806,7 +888,8 @@ func TestHarvest(t *testing.T) {
 		expectedMeasurements,
 		gatherMetrics(
 			gatherer,
-			withIgnoreMetricPrefix("pebble."),
+			// Merges depend on the background pebble compactions.
+			withIgnoreMetricPrefix("pebble.", "aggregator.storage.", "aggregator.merge."),
 			withZeroHistogramValues(true),
 		),
 		cmpopts.IgnoreUnexported(apmmodel.Time{}),
@@ -985,150 +1068,1358 @@ func TestAggregateAndHarvest(t *testing.T) {
 	))
 }
 
-func TestRunStopOrchestration(t *testing.T) {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	var firstHarvestDone atomic.Bool
-	newAggregator := func() *Aggregator {
-		agg, err := New(
-			WithDataDir(t.TempDir()),
-			WithProcessor(func(_ context.Context, _ CombinedMetricsKey, _ *aggregationpb.CombinedMetrics, _ time.Duration) error {
-				firstHarvestDone.Swap(true)
-				return nil
-			}),
-			WithAggregationIntervals([]time.Duration{time.Second}),
-		)
-		if err != nil {
-			t.Fatal("failed to create test aggregator", err)
+func TestAggregateWithHashedGlobalLabels(t *testing.T) {
+	var events []*modelpb.APMEvent
+	agg, err := New(
+		WithDataDir(t.TempDir()),
+		WithLimits(Limits{
+			MaxSpanGroups:                         100,
+			MaxSpanGroupsPerService:               100,
+			MaxTransactionGroups:                  100,
+			MaxTransactionGroupsPerService:        100,
+			MaxServiceTransactionGroups:           100,
+			MaxServiceTransactionGroupsPerService: 100,
+			MaxServices:                           100,
+			MaxServiceInstanceGroupsPerService:    100,
+		}),
+		WithProcessor(sliceProcessor(&events)),
+		WithGlobalLabelsHashThreshold(40),
+		WithLogger(zap.NewNop()),
+	)
+	require.NoError(t, err)
+
+	newEvent := func(labelValue string) *modelpb.APMEvent {
+		return &modelpb.APMEvent{
+			Event: &modelpb.Event{Duration: durationpb.New(time.Millisecond)},
+			Transaction: &modelpb.Transaction{
+				Name:                "T-1000",
+				Type:                "type",
+				RepresentativeCount: 1,
+			},
+			Service: &modelpb.Service{Name: "svc"},
+			Labels: modelpb.Labels{
+				"label": &modelpb.LabelValue{Value: labelValue, Global: true},
+			},
 		}
-		return agg
 	}
-	callAggregateBatch := func(agg *Aggregator) error {
-		return agg.AggregateBatch(
-			context.Background(),
-			EncodeToCombinedMetricsKeyID(t, "ab01"),
-			&modelpb.Batch{
-				&modelpb.APMEvent{
-					Event: &modelpb.Event{Duration: durationpb.New(time.Millisecond)},
+	longValue := strings.Repeat("a", 100)
+	require.NoError(t, agg.AggregateBatch(
+		context.Background(),
+		EncodeToCombinedMetricsKeyID(t, "ab01"),
+		&modelpb.Batch{newEvent(longValue), newEvent(longValue), newEvent("short")},
+	))
+
+	// Only the long global labels are hashed in the stored keys.
+	it, err := agg.NewIterator(IteratorOptions{})
+	require.NoError(t, err)
+	require.True(t, it.First())
+	cm := aggregationpb.CombinedMetricsFromVTPool()
+	defer cm.ReturnToVTPool()
+	require.NoError(t, it.Value(cm))
+	require.NoError(t, it.Close())
+	var keyLens []int
+	for _, ksim := range cm.ServiceMetrics[0].Metrics.ServiceInstanceMetrics {
+		keyLens = append(keyLens, len(ksim.Key.GlobalLabelsStr))
+	}
+	assert.ElementsMatch(t, []int{33, 16}, keyLens)
+
+	require.NoError(t, agg.Close(context.Background()))
+
+	docCounts := make(map[string]uint64)
+	for _, e := range events {
+		if e.GetMetricset().GetName() != txnMetricsetName {
+			continue
+		}
+		docCounts[e.Labels["label"].GetValue()] += e.Metricset.DocCount
+	}
+	assert.Equal(t, map[string]uint64{longValue: 2, "short": 1}, docCounts)
+}
+
+func TestAggregateWithHistogramImpl(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		impl HistogramImpl
+	}{
+		{name: "hdrhistogram", impl: HDRHistogramImpl},
+		{name: "tdigest", impl: TDigestImpl},
+		{name: "ddsketch", impl: DDSketchImpl},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var events []*modelpb.APMEvent
+			agg, err := New(
+				WithDataDir(t.TempDir()),
+				WithLimits(Limits{
+					MaxSpanGroups:                         100,
+					MaxSpanGroupsPerService:               100,
+					MaxTransactionGroups:                  100,
+					MaxTransactionGroupsPerService:        100,
+					MaxServiceTransactionGroups:           100,
+					MaxServiceTransactionGroupsPerService: 100,
+					MaxServices:                           100,
+					MaxServiceInstanceGroupsPerService:    100,
+				}),
+				WithProcessor(sliceProcessor(&events)),
+				WithHistogramImpl(tc.impl),
+				WithLogger(zap.NewNop()),
+			)
+			require.NoError(t, err)
+
+			var batch modelpb.Batch
+			for i := 1; i <= 100; i++ {
+				batch = append(batch, &modelpb.APMEvent{
+					Event: &modelpb.Event{
+						Duration: durationpb.New(time.Duration(i) * time.Millisecond),
+					},
 					Transaction: &modelpb.Transaction{
 						Name:                "T-1000",
 						Type:                "type",
 						RepresentativeCount: 1,
 					},
-				},
-			},
-		)
+					Service: &modelpb.Service{Name: "svc"},
+				})
+			}
+			require.NoError(t, agg.AggregateBatch(
+				context.Background(),
+				EncodeToCombinedMetricsKeyID(t, "ab01"),
+				&batch,
+			))
+			require.NoError(t, agg.Close(context.Background()))
+
+			var found int
+			for _, e := range events {
+				name := e.GetMetricset().GetName()
+				if name != txnMetricsetName && name != svcTxnMetricsetName {
+					continue
+				}
+				found++
+				h := e.GetTransaction().GetDurationHistogram()
+				var total uint64
+				for _, c := range h.Counts {
+					total += c
+				}
+				assert.Equal(t, uint64(100), total)
+				assert.True(t, sort.Float64sAreSorted(h.Values))
+				summary := e.GetTransaction().GetDurationSummary()
+				assert.Equal(t, uint64(100), summary.Count)
+				// Sum of 1..100ms in microseconds, within 2% for sketches.
+				assert.InEpsilon(t, 5050000, summary.Sum, 0.02)
+			}
+			assert.Equal(t, 2, found)
+		})
 	}
+}
 
-	t.Run("run_before_close", func(t *testing.T) {
-		agg := newAggregator()
-		// Should aggregate even without running
-		assert.NoError(t, callAggregateBatch(agg))
-		go func() { agg.Run(ctx) }()
-		assert.Eventually(t, func() bool {
-			return firstHarvestDone.Load()
-		}, 10*time.Second, 10*time.Millisecond, "failed while waiting for first harvest")
-		assert.NoError(t, callAggregateBatch(agg))
-		assert.NoError(t, agg.Close(ctx))
-		assert.ErrorIs(t, callAggregateBatch(agg), ErrAggregatorClosed)
-	})
-	t.Run("close_before_run", func(t *testing.T) {
-		agg := newAggregator()
-		assert.NoError(t, agg.Close(ctx))
-		assert.ErrorIs(t, callAggregateBatch(agg), ErrAggregatorClosed)
-		assert.ErrorIs(t, agg.Run(ctx), ErrAggregatorClosed)
-	})
-	t.Run("multiple_run", func(t *testing.T) {
-		agg := newAggregator()
-		defer agg.Close(ctx)
+func TestAggregateWithDimensionGroups(t *testing.T) {
+	event := &modelpb.APMEvent{
+		Event: &modelpb.Event{
+			Duration: durationpb.New(time.Millisecond),
+		},
+		Transaction: &modelpb.Transaction{
+			Name:                "T-1000",
+			Type:                "type",
+			RepresentativeCount: 1,
+		},
+		Service:    &modelpb.Service{Name: "svc"},
+		Faas:       &modelpb.Faas{Id: "fn-1", Name: "fn"},
+		Host:       &modelpb.Host{Os: &modelpb.OS{Name: "iOS", Version: "17.1"}},
+		Kubernetes: &modelpb.Kubernetes{Namespace: "prod"},
+		Labels: modelpb.Labels{
+			KubernetesDeploymentLabel: &modelpb.LabelValue{Value: "checkout"},
+		},
+	}
+	harvest := func(t *testing.T, opts ...Option) *modelpb.APMEvent {
+		var events []*modelpb.APMEvent
+		agg, err := New(append([]Option{
+			WithDataDir(t.TempDir()),
+			WithLimits(Limits{
+				MaxSpanGroups:                         100,
+				MaxSpanGroupsPerService:               100,
+				MaxTransactionGroups:                  100,
+				MaxTransactionGroupsPerService:        100,
+				MaxServiceTransactionGroups:           100,
+				MaxServiceTransactionGroupsPerService: 100,
+				MaxServices:                           100,
+				MaxServiceInstanceGroupsPerService:    100,
+			}),
+			WithProcessor(sliceProcessor(&events)),
+			WithLogger(zap.NewNop()),
+		}, opts...)...)
+		require.NoError(t, err)
 
-		g, ctx := errgroup.WithContext(ctx)
-		g.Go(func() error { return agg.Run(ctx) })
-		g.Go(func() error { return agg.Run(ctx) })
-		err := g.Wait()
-		assert.Error(t, err)
-		assert.EqualError(t, err, "aggregator is already running")
-	})
-	t.Run("multiple_close", func(t *testing.T) {
-		agg := newAggregator()
-		defer agg.Close(ctx)
-		go func() { agg.Run(ctx) }()
-		time.Sleep(time.Second)
+		batch := modelpb.Batch{event.CloneVT()}
+		require.NoError(t, agg.AggregateBatch(
+			context.Background(),
+			EncodeToCombinedMetricsKeyID(t, "ab01"),
+			&batch,
+		))
+		require.NoError(t, agg.Close(context.Background()))
+		for _, e := range events {
+			if e.GetMetricset().GetName() == txnMetricsetName {
+				return e
+			}
+		}
+		require.FailNow(t, "no transaction metrics harvested")
+		return nil
+	}
 
-		g, ctx := errgroup.WithContext(ctx)
-		g.Go(func() error { return agg.Close(ctx) })
-		g.Go(func() error { return agg.Close(ctx) })
-		assert.NoError(t, g.Wait())
+	t.Run("defaults", func(t *testing.T) {
+		e := harvest(t)
+		assert.Equal(t, "fn-1", e.GetFaas().GetId())
+		assert.Empty(t, e.GetHost().GetOs().GetVersion())
+		assert.Nil(t, e.GetKubernetes())
+	})
+	t.Run("configured", func(t *testing.T) {
+		e := harvest(t,
+			WithFaasGroups(false),
+			WithMobileGroups(true),
+			WithKubernetesGroups(true),
+		)
+		assert.Nil(t, e.GetFaas())
+		assert.Equal(t, "iOS", e.GetHost().GetOs().GetName())
+		assert.Equal(t, "17.1", e.GetHost().GetOs().GetVersion())
+		assert.Equal(t, "prod", e.GetKubernetes().GetNamespace())
 	})
 }
 
-func BenchmarkAggregateCombinedMetrics(b *testing.B) {
-	gatherer, err := apmotel.NewGatherer()
-	if err != nil {
-		b.Fatal(err)
+func TestAggregateWithExemplars(t *testing.T) {
+	exemplars := make(map[string][]string)
+	processor := func(
+		ctx context.Context,
+		cmk CombinedMetricsKey,
+		cm *aggregationpb.CombinedMetrics,
+		aggregationIvl time.Duration,
+	) error {
+		_, err := CombinedMetricsToBatch(
+			cm, cmk.ProcessingTime, aggregationIvl,
+			WithExemplarHandler(func(e *modelpb.APMEvent, ex []*aggregationpb.Exemplar) {
+				for _, x := range ex {
+					name := e.GetMetricset().GetName()
+					exemplars[name] = append(exemplars[name], x.TraceId+"/"+x.SpanId)
+				}
+			}),
+		)
+		return err
 	}
-	mp := metric.NewMeterProvider(metric.WithReader(gatherer))
-	aggIvl := time.Minute
 	agg, err := New(
-		WithDataDir(b.TempDir()),
+		WithDataDir(t.TempDir()),
 		WithLimits(Limits{
-			MaxSpanGroups:                         1000,
+			MaxSpanGroups:                         100,
 			MaxSpanGroupsPerService:               100,
-			MaxTransactionGroups:                  1000,
+			MaxTransactionGroups:                  100,
 			MaxTransactionGroupsPerService:        100,
-			MaxServiceTransactionGroups:           1000,
+			MaxServiceTransactionGroups:           100,
 			MaxServiceTransactionGroupsPerService: 100,
 			MaxServices:                           100,
 			MaxServiceInstanceGroupsPerService:    100,
 		}),
-		WithProcessor(noOpProcessor()),
-		WithMeter(mp.Meter("test")),
+		WithProcessor(processor),
+		WithMaxExemplars(3),
 		WithLogger(zap.NewNop()),
 	)
-	if err != nil {
-		b.Fatal(err)
-	}
-	go func() {
-		agg.Run(context.Background())
-	}()
-	b.Cleanup(func() {
-		agg.Close(context.Background())
-	})
-	cmk := CombinedMetricsKey{
-		Interval:       aggIvl,
-		ProcessingTime: time.Now().Truncate(aggIvl),
-		ID:             EncodeToCombinedMetricsKeyID(b, "ab01"),
-	}
-	cm := NewTestCombinedMetrics(WithEventsTotal(1)).
-		AddServiceMetrics(serviceAggregationKey{
-			Timestamp:   time.Now(),
-			ServiceName: "test-svc",
-		}).
-		AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
-		AddTransaction(transactionAggregationKey{
-			TransactionName: "txntest",
-			TransactionType: "txntype",
-		}).
-		AddServiceTransaction(serviceTransactionAggregationKey{
-			TransactionType: "txntype",
-		}).
-		GetProto()
-	b.Cleanup(func() { cm.ReturnToVTPool() })
-	ctx, cancel := context.WithCancel(context.Background())
-	b.Cleanup(func() { cancel() })
-	b.ReportAllocs()
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		if err := agg.AggregateCombinedMetrics(ctx, cmk, cm); err != nil {
-			b.Fatal(err)
-		}
-	}
-}
+	require.NoError(t, err)
 
-func BenchmarkAggregateBatchSerial(b *testing.B) {
-	b.ReportAllocs()
-	agg := newTestAggregator(b)
+	for i := 1; i <= 10; i++ {
+		duration := durationpb.New(time.Duration(i) * time.Millisecond)
+		if i == 5 {
+			// Far from the other durations, so it is retained.
+			duration = durationpb.New(time.Second)
+		}
+		traceID := fmt.Sprintf("trace-%d", i)
+		require.NoError(t, agg.AggregateBatch(
+			context.Background(),
+			EncodeToCombinedMetricsKeyID(t, "ab01"),
+			&modelpb.Batch{
+				{
+					Event: &modelpb.Event{Duration: duration},
+					Trace: &modelpb.Trace{Id: traceID},
+					Transaction: &modelpb.Transaction{
+						Id:                  fmt.Sprintf("txn-%d", i),
+						Name:                "T-1000",
+						Type:                "type",
+						RepresentativeCount: 1,
+					},
+					Service: &modelpb.Service{Name: "svc"},
+				},
+				{
+					Event: &modelpb.Event{Duration: duration},
+					Trace: &modelpb.Trace{Id: traceID},
+					Span: &modelpb.Span{
+						Id:                  fmt.Sprintf("span-%d", i),
+						Name:                "span",
+						Type:                "db",
+						RepresentativeCount: 1,
+					},
+					Service: &modelpb.Service{
+						Name:   "svc",
+						Target: &modelpb.ServiceTarget{Name: "target", Type: "db"},
+					},
+				},
+			},
+		))
+	}
+	require.NoError(t, agg.Close(context.Background()))
+
+	// The fastest, the slowest and the most distant exemplars are retained,
+	// sorted by duration.
+	assert.Equal(t, map[string][]string{
+		txnMetricsetName:  {"trace-1/txn-1", "trace-10/txn-10", "trace-5/txn-5"},
+		spanMetricsetName: {"trace-1/span-1", "trace-10/span-10", "trace-5/span-5"},
+	}, exemplars)
+}
+
+func TestAggregateWithErrors(t *testing.T) {
+	var events []*modelpb.APMEvent
+	agg, err := New(
+		WithDataDir(t.TempDir()),
+		WithLimits(Limits{
+			MaxServices:                        10,
+			MaxServiceInstanceGroupsPerService: 10,
+			MaxErrorGroups:                     10,
+			MaxErrorGroupsPerService:           1,
+		}),
+		WithProcessor(sliceProcessor(&events)),
+		WithAggregationIntervals([]time.Duration{time.Minute}),
+		WithLogger(zap.NewNop()),
+	)
+	require.NoError(t, err)
+
+	newError := func(groupingKey string) *modelpb.APMEvent {
+		return &modelpb.APMEvent{
+			Timestamp: timestamppb.New(time.Unix(0, 0)),
+			Service:   &modelpb.Service{Name: "svc"},
+			Error:     &modelpb.Error{GroupingKey: groupingKey},
+		}
+	}
+	require.NoError(t, agg.AggregateBatch(
+		context.Background(),
+		EncodeToCombinedMetricsKeyID(t, "ab01"),
+		&modelpb.Batch{newError("grp1"), newError("grp1"), newError("grp1")},
+	))
+	require.NoError(t, agg.AggregateBatch(
+		context.Background(),
+		EncodeToCombinedMetricsKeyID(t, "ab01"),
+		&modelpb.Batch{newError("grp2"), newError("grp3")},
+	))
+
+	stats, err := agg.Stats(EncodeToCombinedMetricsKeyID(t, "ab01"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats[time.Minute].ErrorGroups)
+	assert.Equal(t, uint64(2), stats[time.Minute].OverflowErrorGroups)
+	require.NoError(t, agg.Close(context.Background()))
+
+	counts := make(map[string]float64)
+	for _, e := range events {
+		if e.GetMetricset().GetName() != "service_error" {
+			continue
+		}
+		for _, sample := range e.Metricset.Samples {
+			if sample.Name == "error.count" {
+				counts[e.Error.GroupingKey] = sample.Value
+			}
+		}
+	}
+	// The retained group depends on the merge order of the partial
+	// aggregations, the other groups overflow.
+	require.Len(t, counts, 2)
+	assert.Contains(t, counts, "_other")
+	var total float64
+	for _, c := range counts {
+		total += c
+	}
+	assert.Equal(t, float64(5), total)
+}
+
+func TestAggregateWithBackpressure(t *testing.T) {
+	newAggregator := func(t *testing.T, opts ...Option) *Aggregator {
+		agg, err := New(append([]Option{
+			WithDataDir(t.TempDir()),
+			WithProcessor(func(_ context.Context, _ CombinedMetricsKey, _ *aggregationpb.CombinedMetrics, _ time.Duration) error {
+				return nil
+			}),
+			WithAggregationIntervals([]time.Duration{time.Second}),
+			WithMaxPendingBytes(1),
+			WithLogger(zap.NewNop()),
+		}, opts...)...)
+		require.NoError(t, err)
+		return agg
+	}
+	aggregateBatch := func(ctx context.Context, agg *Aggregator) error {
+		return agg.AggregateBatch(
+			ctx,
+			EncodeToCombinedMetricsKeyID(t, "ab01"),
+			&modelpb.Batch{
+				{
+					Event: &modelpb.Event{Duration: durationpb.New(time.Millisecond)},
+					Transaction: &modelpb.Transaction{
+						Name:                "T-1000",
+						Type:                "type",
+						RepresentativeCount: 1,
+					},
+				},
+			},
+		)
+	}
+
+	t.Run("reject", func(t *testing.T) {
+		agg := newAggregator(t)
+		defer agg.Close(context.Background())
+		require.NoError(t, aggregateBatch(context.Background(), agg))
+		assert.ErrorIs(t, aggregateBatch(context.Background(), agg), ErrBackpressure)
+	})
+	t.Run("block_until_context_done", func(t *testing.T) {
+		agg := newAggregator(t, WithBlockOnBackpressure(true))
+		defer agg.Close(context.Background())
+		require.NoError(t, aggregateBatch(context.Background(), agg))
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, aggregateBatch(ctx, agg), context.DeadlineExceeded)
+	})
+	t.Run("block_until_closed", func(t *testing.T) {
+		agg := newAggregator(t, WithBlockOnBackpressure(true))
+		require.NoError(t, aggregateBatch(context.Background(), agg))
+		errCh := make(chan error, 1)
+		go func() { errCh <- aggregateBatch(context.Background(), agg) }()
+		time.Sleep(100 * time.Millisecond)
+		require.NoError(t, agg.Close(context.Background()))
+		assert.ErrorIs(t, <-errCh, ErrAggregatorClosed)
+	})
+	t.Run("block_until_committed", func(t *testing.T) {
+		agg := newAggregator(t, WithBlockOnBackpressure(true))
+		defer agg.Close(context.Background())
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		require.NoError(t, aggregateBatch(ctx, agg))
+		go agg.Run(ctx)
+		// The harvest loop commits the pending batch within an interval.
+		ctx, cancelWait := context.WithTimeout(ctx, 5*time.Second)
+		defer cancelWait()
+		assert.NoError(t, aggregateBatch(ctx, agg))
+	})
+}
+
+func TestAggregateWithWriteCoalescing(t *testing.T) {
+	newAggregator := func(t *testing.T, opts ...Option) *Aggregator {
+		agg, err := New(append([]Option{
+			WithDataDir(t.TempDir()),
+			WithProcessor(func(_ context.Context, _ CombinedMetricsKey, _ *aggregationpb.CombinedMetrics, _ time.Duration) error {
+				return nil
+			}),
+			WithAggregationIntervals([]time.Duration{time.Second}),
+			WithLogger(zap.NewNop()),
+		}, opts...)...)
+		require.NoError(t, err)
+		t.Cleanup(func() { agg.Close(context.Background()) })
+		return agg
+	}
+	aggregateBatch := func(ctx context.Context, agg *Aggregator) error {
+		return agg.AggregateBatch(
+			ctx,
+			EncodeToCombinedMetricsKeyID(t, "ab01"),
+			&modelpb.Batch{
+				{
+					Event: &modelpb.Event{Duration: durationpb.New(time.Millisecond)},
+					Transaction: &modelpb.Transaction{
+						Name:                "T-1000",
+						Type:                "type",
+						RepresentativeCount: 1,
+					},
+				},
+			},
+		)
+	}
+	committed := func(agg *Aggregator) bool {
+		agg.mu.Lock()
+		defer agg.mu.Unlock()
+		if agg.pendingBatchBytes() > 0 {
+			return false
+		}
+		iter := agg.db.NewIter(&pebble.IterOptions{LowerBound: combinedMetricsLowerBound})
+		defer iter.Close()
+		return iter.First()
+	}
+
+	t.Run("flush_bytes", func(t *testing.T) {
+		agg := newAggregator(t, WithWriteCoalescing(0, 1))
+		require.NoError(t, aggregateBatch(context.Background(), agg))
+		assert.True(t, committed(agg))
+	})
+	t.Run("flush_interval", func(t *testing.T) {
+		agg := newAggregator(t, WithWriteCoalescing(50*time.Millisecond, 1<<20))
+		require.NoError(t, aggregateBatch(context.Background(), agg))
+		require.NoError(t, aggregateBatch(context.Background(), agg))
+		assert.False(t, committed(agg))
+		assert.Eventually(t, func() bool { return committed(agg) }, 5*time.Second, 10*time.Millisecond)
+	})
+	t.Run("flush_interval_every_batch", func(t *testing.T) {
+		agg := newAggregator(t, WithWriteCoalescing(50*time.Millisecond, 1<<20))
+		for i := 0; i < 2; i++ {
+			require.NoError(t, aggregateBatch(context.Background(), agg))
+			assert.False(t, committed(agg))
+			assert.Eventually(t, func() bool { return committed(agg) }, 5*time.Second, 10*time.Millisecond)
+		}
+	})
+	t.Run("flush_interval_releases_blocked_writers", func(t *testing.T) {
+		agg := newAggregator(t,
+			WithWriteCoalescing(50*time.Millisecond, 1<<20),
+			WithMaxPendingBytes(1),
+			WithBlockOnBackpressure(true),
+		)
+		require.NoError(t, aggregateBatch(context.Background(), agg))
+		// No harvest loop is running, the blocked write is released by
+		// the flush of the coalesced writes.
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		assert.NoError(t, aggregateBatch(ctx, agg))
+	})
+}
+
+func TestRunRecoveryMode(t *testing.T) {
+	rdr := metric.NewManualReader()
+	out := make(chan CombinedMetricsKey, 4)
+	agg, err := New(
+		WithDataDir(t.TempDir()),
+		WithProcessor(func(_ context.Context, cmk CombinedMetricsKey, _ *aggregationpb.CombinedMetrics, _ time.Duration) error {
+			out <- cmk
+			return nil
+		}),
+		WithAggregationIntervals([]time.Duration{time.Minute, time.Hour}),
+		WithHarvestDelay(time.Hour), // disable auto harvest
+		WithRecoveryMode(true),
+		WithMeter(metric.NewMeterProvider(metric.WithReader(rdr)).Meter("test")),
+		WithLogger(zap.NewNop()),
+	)
+	require.NoError(t, err)
 	defer agg.Close(context.Background())
+
+	// Aggregate into a past processing time, left behind by a crash.
+	now := time.Now().Truncate(time.Minute)
+	agg.processingTime = now.Add(-2 * time.Hour)
+	require.NoError(t, agg.AggregateBatch(
+		context.Background(),
+		EncodeToCombinedMetricsKeyID(t, "ab01"),
+		&modelpb.Batch{{
+			Event: &modelpb.Event{Duration: durationpb.New(time.Millisecond)},
+			Transaction: &modelpb.Transaction{
+				Name:                "T-1000",
+				Type:                "type",
+				RepresentativeCount: 1,
+			},
+		}},
+	))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	agg.mu.Lock()
+	require.NoError(t, agg.flushBatches())
+	agg.processingTime = now
+	agg.mu.Unlock()
+	go agg.Run(ctx)
+
+	var recovered []time.Duration
+	for i := 0; i < 2; i++ {
+		select {
+		case cmk := <-out:
+			assert.True(t, cmk.ProcessingTime.Before(now))
+			recovered = append(recovered, cmk.Interval)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for recovered metrics")
+		}
+	}
+	assert.ElementsMatch(t, []time.Duration{time.Minute, time.Hour}, recovered)
+
+	// The recovered events are recorded after processing.
+	assert.Eventually(t, func() bool {
+		var rm metricdata.ResourceMetrics
+		require.NoError(t, rdr.Collect(context.Background(), &rm))
+		var total float64
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				if m.Name != "aggregator.events.recovered" {
+					continue
+				}
+				for _, dp := range m.Data.(metricdata.Sum[float64]).DataPoints {
+					total += dp.Value
+				}
+			}
+		}
+		return total == 2
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestHarvestCoverageGaps(t *testing.T) {
+	rdr := metric.NewManualReader()
+	now := time.Now().Truncate(time.Minute)
+	failAt := now.Add(-2 * time.Minute)
+	var gaps []CoverageGap
+	agg, err := New(
+		WithDataDir(t.TempDir()),
+		WithProcessor(func(_ context.Context, cmk CombinedMetricsKey, _ *aggregationpb.CombinedMetrics, _ time.Duration) error {
+			if cmk.ProcessingTime.Equal(failAt) {
+				return errors.New("boom")
+			}
+			return nil
+		}),
+		WithCoverageGapHandler(func(gap CoverageGap) {
+			gaps = append(gaps, gap)
+		}),
+		WithMeter(metric.NewMeterProvider(metric.WithReader(rdr)).Meter("test")),
+		WithLogger(zap.NewNop()),
+	)
+	require.NoError(t, err)
+	defer agg.Close(context.Background())
+
+	cmID := EncodeToCombinedMetricsKeyID(t, "ab01")
+	// Aggregate into the periods 6, 5, 2 and 1 minutes ago, leaving a gap
+	// of two periods. The period 2 minutes ago fails to be processed.
+	for _, ago := range []time.Duration{6, 5, 2, 1} {
+		agg.mu.Lock()
+		agg.processingTime = now.Add(-ago * time.Minute)
+		agg.mu.Unlock()
+		require.NoError(t, agg.AggregateBatch(context.Background(), cmID, &modelpb.Batch{{
+			Event: &modelpb.Event{Duration: durationpb.New(time.Millisecond)},
+			Transaction: &modelpb.Transaction{
+				Name:                "T-1000",
+				Type:                "type",
+				RepresentativeCount: 1,
+			},
+		}}))
+	}
+	it, err := agg.NewIterator(IteratorOptions{})
+	require.NoError(t, err)
+	require.NoError(t, it.Close())
+	agg.mu.Lock()
+	agg.processingTime = now
+	agg.mu.Unlock()
+	assert.Error(t, agg.harvestStale(context.Background()))
+
+	require.Len(t, gaps, 2)
+	assert.Equal(t, cmID, gaps[0].ID)
+	assert.Equal(t, time.Minute, gaps[0].Interval)
+	assert.True(t, gaps[0].Start.Equal(now.Add(-4*time.Minute)))
+	assert.True(t, gaps[0].End.Equal(now.Add(-2*time.Minute)))
+	assert.NoError(t, gaps[0].Err)
+	assert.Equal(t, int64(2), gaps[0].Periods())
+	assert.True(t, gaps[1].Start.Equal(failAt))
+	assert.True(t, gaps[1].End.Equal(failAt.Add(time.Minute)))
+	assert.ErrorContains(t, gaps[1].Err, "boom")
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, rdr.Collect(context.Background(), &rm))
+	var total int64
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "aggregator.harvest.gaps" {
+				continue
+			}
+			for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				total += dp.Value
+			}
+		}
+	}
+	assert.Equal(t, int64(3), total)
+}
+
+func TestAggregateCombinedMetricsReplayHorizon(t *testing.T) {
+	agg, err := New(
+		WithDataDir(t.TempDir()),
+		WithAggregationIntervals([]time.Duration{time.Minute}),
+		WithReplayHorizon(time.Hour),
+		WithLogger(zap.NewNop()),
+	)
+	require.NoError(t, err)
+	defer agg.Close(context.Background())
+
+	aggregate := func(processingTime time.Time) error {
+		cm := NewTestCombinedMetrics(WithEventsTotal(1)).
+			AddServiceMetrics(serviceAggregationKey{
+				Timestamp:   processingTime,
+				ServiceName: "test-svc",
+			}).
+			AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+			GetProto()
+		defer cm.ReturnToVTPool()
+		return agg.AggregateCombinedMetrics(context.Background(), CombinedMetricsKey{
+			Interval:       time.Minute,
+			ProcessingTime: processingTime,
+			ID:             EncodeToCombinedMetricsKeyID(t, "ab01"),
+		}, cm)
+	}
+
+	now := time.Now().Truncate(time.Minute)
+	assert.NoError(t, aggregate(now.Add(-30*time.Minute)))
+
+	stale := now.Add(-2 * time.Hour)
+	err = aggregate(stale)
+	var staleErr *StaleProcessingTimeError
+	require.ErrorAs(t, err, &staleErr)
+	assert.True(t, stale.Equal(staleErr.ProcessingTime))
+	assert.Equal(t, time.Hour, staleErr.Horizon)
+}
+
+func TestAggregateCombinedMetricsMaxSize(t *testing.T) {
+	agg, err := New(
+		WithDataDir(t.TempDir()),
+		WithMaxCombinedMetricsSize(100),
+		WithLogger(zap.NewNop()),
+	)
+	require.NoError(t, err)
+	defer agg.Close(context.Background())
+
+	aggregate := func(serviceName string) error {
+		cm := NewTestCombinedMetrics(WithEventsTotal(1)).
+			AddServiceMetrics(serviceAggregationKey{
+				Timestamp:   time.Now(),
+				ServiceName: serviceName,
+			}).
+			AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+			GetProto()
+		defer cm.ReturnToVTPool()
+		return agg.AggregateCombinedMetrics(context.Background(), CombinedMetricsKey{
+			Interval:       time.Minute,
+			ProcessingTime: time.Now().Truncate(time.Minute),
+			ID:             EncodeToCombinedMetricsKeyID(t, "ab01"),
+		}, cm)
+	}
+
+	assert.NoError(t, aggregate("test-svc"))
+
+	err = aggregate(strings.Repeat("x", 100))
+	var tooLargeErr *CombinedMetricsTooLargeError
+	require.ErrorAs(t, err, &tooLargeErr)
+	assert.Greater(t, tooLargeErr.Size, 100)
+	assert.Equal(t, 100, tooLargeErr.MaxSize)
+}
+
+func TestAggregateWithIntervalRollups(t *testing.T) {
+	type harvested struct {
+		interval       time.Duration
+		processingTime time.Time
+	}
+	ivls := []time.Duration{time.Second, 10 * time.Second}
+	for _, rollups := range []bool{false, true} {
+		t.Run(fmt.Sprintf("rollups=%t", rollups), func(t *testing.T) {
+			ctx := context.Background()
+			eventsTotal := make(map[harvested]float64)
+			agg, err := New(
+				WithDataDir(t.TempDir()),
+				WithLimits(Limits{
+					MaxServices:                        10,
+					MaxServiceInstanceGroupsPerService: 10,
+				}),
+				WithAggregationIntervals(ivls),
+				WithIntervalRollups(rollups),
+				WithProcessor(func(
+					_ context.Context,
+					cmk CombinedMetricsKey,
+					cm *aggregationpb.CombinedMetrics,
+					ivl time.Duration,
+				) error {
+					eventsTotal[harvested{ivl, cmk.ProcessingTime}] += cm.EventsTotal
+					return nil
+				}),
+				WithLogger(zap.NewNop()),
+			)
+			require.NoError(t, err)
+			defer agg.Close(ctx)
+
+			start := agg.processingTime.Truncate(10 * time.Second)
+			for i := 0; i < 3; i++ {
+				cm := NewTestCombinedMetrics(WithEventsTotal(1)).
+					AddServiceMetrics(serviceAggregationKey{Timestamp: start, ServiceName: "svc"}).
+					AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+					GetProto()
+				require.NoError(t, agg.AggregateCombinedMetrics(ctx, CombinedMetricsKey{
+					Interval:       time.Second,
+					ProcessingTime: start.Add(time.Duration(i) * time.Second),
+					ID:             EncodeToCombinedMetricsKeyID(t, "ab01"),
+				}, cm))
+				cm.ReturnToVTPool()
+			}
+
+			agg.mu.Lock()
+			batch := agg.takeBatches()
+			agg.mu.Unlock()
+			for end := start.Add(time.Second); !end.After(start.Add(10 * time.Second)); end = end.Add(time.Second) {
+				require.NoError(t, agg.commitAndHarvest(ctx, batch, end, ivls, nil))
+				batch = nil
+			}
+
+			expected := map[harvested]float64{
+				{time.Second, start}:                      1,
+				{time.Second, start.Add(time.Second)}:     1,
+				{time.Second, start.Add(2 * time.Second)}: 1,
+			}
+			if rollups {
+				expected[harvested{10 * time.Second, start}] = 3
+			}
+			assert.Equal(t, expected, eventsTotal)
+		})
+	}
+}
+
+func TestHarvestConcurrency(t *testing.T) {
+	ctx := context.Background()
+	ivl := time.Second
+	var mu sync.Mutex
+	var active, maxActive int
+	processed := make(map[[16]byte][]time.Time)
+	agg, err := New(
+		WithDataDir(t.TempDir()),
+		WithLimits(Limits{
+			MaxServices:                        10,
+			MaxServiceInstanceGroupsPerService: 10,
+		}),
+		WithAggregationIntervals([]time.Duration{ivl}),
+		WithHarvestConcurrency(4),
+		WithProcessor(func(
+			_ context.Context,
+			cmk CombinedMetricsKey,
+			_ *aggregationpb.CombinedMetrics,
+			_ time.Duration,
+		) error {
+			mu.Lock()
+			active++
+			if active > maxActive {
+				maxActive = active
+			}
+			processed[cmk.ID] = append(processed[cmk.ID], cmk.ProcessingTime)
+			mu.Unlock()
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			active--
+			mu.Unlock()
+			return nil
+		}),
+		WithLogger(zap.NewNop()),
+	)
+	require.NoError(t, err)
+	defer agg.Close(ctx)
+
+	start := time.Unix(agg.processingTime.Unix(), 0)
+	var ids [][16]byte
+	for i := 0; i < 16; i++ {
+		id := EncodeToCombinedMetricsKeyID(t, fmt.Sprintf("ab%02d", i))
+		ids = append(ids, id)
+		for j := 0; j < 5; j++ {
+			cm := NewTestCombinedMetrics(WithEventsTotal(1)).
+				AddServiceMetrics(serviceAggregationKey{Timestamp: start, ServiceName: "svc"}).
+				AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+				GetProto()
+			require.NoError(t, agg.AggregateCombinedMetrics(ctx, CombinedMetricsKey{
+				Interval:       ivl,
+				ProcessingTime: start.Add(time.Duration(j) * ivl),
+				ID:             id,
+			}, cm))
+			cm.ReturnToVTPool()
+		}
+	}
+	agg.mu.Lock()
+	require.NoError(t, agg.flushBatches())
+	agg.processingTime = start.Add(5 * ivl)
+	agg.mu.Unlock()
+	require.NoError(t, agg.harvestStale(ctx))
+
+	assert.Len(t, processed, len(ids))
+	for _, id := range ids {
+		require.Len(t, processed[id], 5)
+		for j, processingTime := range processed[id] {
+			assert.Equal(t, start.Add(time.Duration(j)*ivl), processingTime)
+		}
+	}
+	assert.Greater(t, maxActive, 1)
+	assert.LessOrEqual(t, maxActive, 4)
+}
+
+func TestPebbleOptions(t *testing.T) {
+	opts := PebbleOptions{
+		CacheSize:                   16 << 20,
+		MemTableSize:                8 << 20,
+		MemTableStopWritesThreshold: 3,
+		MaxConcurrentCompactions:    2,
+		L0CompactionThreshold:       4,
+		L0StopWritesThreshold:       16,
+		LevelCompression:            []Compression{NoCompression, SnappyCompression, ZstdCompression},
+	}
+	var pebbleOpts pebble.Options
+	applyPebbleOptions(opts, &pebbleOpts)
+	assert.Equal(t, 8<<20, pebbleOpts.MemTableSize)
+	assert.Equal(t, 3, pebbleOpts.MemTableStopWritesThreshold)
+	assert.Equal(t, 2, pebbleOpts.MaxConcurrentCompactions())
+	assert.Equal(t, 4, pebbleOpts.L0CompactionThreshold)
+	assert.Equal(t, 16, pebbleOpts.L0StopWritesThreshold)
+	require.Len(t, pebbleOpts.Levels, 7)
+	assert.Equal(t, pebble.NoCompression, pebbleOpts.Levels[0].Compression)
+	assert.Equal(t, pebble.SnappyCompression, pebbleOpts.Levels[1].Compression)
+	for _, l := range pebbleOpts.Levels[2:] {
+		assert.Equal(t, pebble.ZstdCompression, l.Compression)
+	}
+
+	agg, err := New(
+		WithDataDir(t.TempDir()),
+		WithPebbleOptions(opts),
+		WithLogger(zap.NewNop()),
+	)
+	require.NoError(t, err)
+	require.NoError(t, agg.Close(context.Background()))
+}
+
+func TestRunStopOrchestration(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var firstHarvestDone atomic.Bool
+	newAggregator := func() *Aggregator {
+		agg, err := New(
+			WithDataDir(t.TempDir()),
+			WithProcessor(func(_ context.Context, _ CombinedMetricsKey, _ *aggregationpb.CombinedMetrics, _ time.Duration) error {
+				firstHarvestDone.Swap(true)
+				return nil
+			}),
+			WithAggregationIntervals([]time.Duration{time.Second}),
+		)
+		if err != nil {
+			t.Fatal("failed to create test aggregator", err)
+		}
+		return agg
+	}
+	callAggregateBatch := func(agg *Aggregator) error {
+		return agg.AggregateBatch(
+			context.Background(),
+			EncodeToCombinedMetricsKeyID(t, "ab01"),
+			&modelpb.Batch{
+				&modelpb.APMEvent{
+					Event: &modelpb.Event{Duration: durationpb.New(time.Millisecond)},
+					Transaction: &modelpb.Transaction{
+						Name:                "T-1000",
+						Type:                "type",
+						RepresentativeCount: 1,
+					},
+				},
+			},
+		)
+	}
+
+	t.Run("run_before_close", func(t *testing.T) {
+		agg := newAggregator()
+		// Should aggregate even without running
+		assert.NoError(t, callAggregateBatch(agg))
+		go func() { agg.Run(ctx) }()
+		assert.Eventually(t, func() bool {
+			return firstHarvestDone.Load()
+		}, 10*time.Second, 10*time.Millisecond, "failed while waiting for first harvest")
+		assert.NoError(t, callAggregateBatch(agg))
+		assert.NoError(t, agg.Close(ctx))
+		assert.ErrorIs(t, callAggregateBatch(agg), ErrAggregatorClosed)
+	})
+	t.Run("close_before_run", func(t *testing.T) {
+		agg := newAggregator()
+		assert.NoError(t, agg.Close(ctx))
+		assert.ErrorIs(t, callAggregateBatch(agg), ErrAggregatorClosed)
+		assert.ErrorIs(t, agg.Run(ctx), ErrAggregatorClosed)
+	})
+	t.Run("multiple_run", func(t *testing.T) {
+		agg := newAggregator()
+		defer agg.Close(ctx)
+
+		g, ctx := errgroup.WithContext(ctx)
+		g.Go(func() error { return agg.Run(ctx) })
+		g.Go(func() error { return agg.Run(ctx) })
+		err := g.Wait()
+		assert.Error(t, err)
+		assert.EqualError(t, err, "aggregator is already running")
+	})
+	t.Run("multiple_close", func(t *testing.T) {
+		agg := newAggregator()
+		defer agg.Close(ctx)
+		go func() { agg.Run(ctx) }()
+		time.Sleep(time.Second)
+
+		g, ctx := errgroup.WithContext(ctx)
+		g.Go(func() error { return agg.Close(ctx) })
+		g.Go(func() error { return agg.Close(ctx) })
+		assert.NoError(t, g.Wait())
+	})
+}
+
+func TestRunRestartsCrashedHarvestLoop(t *testing.T) {
+	newAggregator := func(t *testing.T, maxRestarts int, processor Processor, opts ...Option) *Aggregator {
+		agg, err := New(append([]Option{
+			WithDataDir(t.TempDir()),
+			WithProcessor(processor),
+			WithAggregationIntervals([]time.Duration{time.Second}),
+			WithHarvestLoopRestarts(maxRestarts, 10*time.Millisecond),
+			WithLogger(zap.NewNop()),
+		}, opts...)...)
+		require.NoError(t, err)
+		t.Cleanup(func() { agg.Close(context.Background()) })
+		require.NoError(t, agg.AggregateBatch(
+			context.Background(),
+			EncodeToCombinedMetricsKeyID(t, "ab01"),
+			&modelpb.Batch{
+				&modelpb.APMEvent{
+					Event: &modelpb.Event{Duration: durationpb.New(time.Millisecond)},
+					Transaction: &modelpb.Transaction{
+						Name:                "T-1000",
+						Type:                "type",
+						RepresentativeCount: 1,
+					},
+				},
+			},
+		))
+		return agg
+	}
+
+	t.Run("restart_and_retry", func(t *testing.T) {
+		var calls, harvested atomic.Int64
+		agg := newAggregator(t, 1, func(_ context.Context, _ CombinedMetricsKey, _ *aggregationpb.CombinedMetrics, _ time.Duration) error {
+			if calls.Add(1) == 1 {
+				panic("boom")
+			}
+			harvested.Add(1)
+			return nil
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go agg.Run(ctx)
+
+		// Consecutive crashes are reset once the retried harvest completes,
+		// which happens after the processor has returned.
+		assert.Eventually(t, func() bool {
+			return harvested.Load() > 0 && agg.RunStats().ConsecutiveCrashes == 0
+		}, 10*time.Second, 10*time.Millisecond, "crashed harvest was not retried")
+		stats := agg.RunStats()
+		assert.True(t, stats.Running)
+		assert.Equal(t, 1, stats.Restarts)
+		assert.ErrorContains(t, stats.LastCrash, "boom")
+		assert.False(t, stats.LastCrashTime.IsZero())
+	})
+	t.Run("retry_skips_processed_ids", func(t *testing.T) {
+		rdr := metric.NewManualReader()
+		okID := EncodeToCombinedMetricsKeyID(t, "ab01")
+		crashID := EncodeToCombinedMetricsKeyID(t, "ab02")
+		var mu sync.Mutex
+		crashed := false
+		processed := make(map[[16]byte]int)
+		agg := newAggregator(t, 1, func(_ context.Context, cmk CombinedMetricsKey, _ *aggregationpb.CombinedMetrics, _ time.Duration) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if cmk.ID == crashID && !crashed {
+				crashed = true
+				panic("boom")
+			}
+			processed[cmk.ID]++
+			return nil
+		}, WithMeter(metric.NewMeterProvider(metric.WithReader(rdr)).Meter("test")))
+		require.NoError(t, agg.AggregateBatch(context.Background(), crashID, &modelpb.Batch{{
+			Event: &modelpb.Event{Duration: durationpb.New(time.Millisecond)},
+			Transaction: &modelpb.Transaction{
+				Name:                "T-1000",
+				Type:                "type",
+				RepresentativeCount: 1,
+			},
+		}}))
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go agg.Run(ctx)
+
+		assert.Eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return processed[crashID] > 0 && agg.RunStats().ConsecutiveCrashes == 0
+		}, 10*time.Second, 10*time.Millisecond, "crashed harvest was not retried")
+		cancel()
+
+		// The ID checkpointed before the crash is not processed again and
+		// the cached events are recorded once.
+		mu.Lock()
+		assert.Equal(t, map[[16]byte]int{okID: 1, crashID: 1}, processed)
+		mu.Unlock()
+		var rm metricdata.ResourceMetrics
+		require.NoError(t, rdr.Collect(context.Background(), &rm))
+		var eventsTotal float64
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				if m.Name != "aggregator.events.total" {
+					continue
+				}
+				for _, dp := range m.Data.(metricdata.Sum[float64]).DataPoints {
+					eventsTotal += dp.Value
+				}
+			}
+		}
+		assert.Equal(t, float64(2), eventsTotal)
+	})
+	t.Run("restarts_exhausted", func(t *testing.T) {
+		agg := newAggregator(t, 2, func(_ context.Context, _ CombinedMetricsKey, _ *aggregationpb.CombinedMetrics, _ time.Duration) error {
+			panic("boom")
+		})
+		err := agg.Run(context.Background())
+		assert.EqualError(t, err, "harvest loop crashed 3 consecutive times: harvest loop crashed: boom")
+
+		stats := agg.RunStats()
+		assert.False(t, stats.Running)
+		assert.Equal(t, 2, stats.Restarts)
+		assert.Equal(t, 3, stats.ConsecutiveCrashes)
+	})
+	t.Run("restarts_disabled", func(t *testing.T) {
+		var calls atomic.Int64
+		agg := newAggregator(t, 0, func(_ context.Context, _ CombinedMetricsKey, _ *aggregationpb.CombinedMetrics, _ time.Duration) error {
+			if calls.Add(1) == 1 {
+				panic("boom")
+			}
+			return nil
+		})
+		agg.mu.Lock()
+		batch := agg.takeBatches()
+		agg.mu.Unlock()
+
+		assert.PanicsWithValue(t, "boom", func() {
+			agg.supervisedCommitAndHarvest(
+				context.Background(), batch, agg.processingTime.Add(time.Second),
+				[]time.Duration{time.Second}, nil,
+			)
+		})
+	})
+	t.Run("requeue_batch", func(t *testing.T) {
+		agg := newAggregator(t, 1, func(_ context.Context, _ CombinedMetricsKey, _ *aggregationpb.CombinedMetrics, _ time.Duration) error {
+			return nil
+		})
+		agg.mu.Lock()
+		batch := agg.takeBatches()
+		agg.mu.Unlock()
+		taken := batch[0].Count()
+
+		// Writes added after the batch was taken are kept along with the
+		// writes of the re-queued batch.
+		require.NoError(t, agg.AggregateBatch(
+			context.Background(),
+			EncodeToCombinedMetricsKeyID(t, "ab02"),
+			&modelpb.Batch{{
+				Event: &modelpb.Event{Duration: durationpb.New(time.Millisecond)},
+				Transaction: &modelpb.Transaction{
+					Name:                "T-1000",
+					Type:                "type",
+					RepresentativeCount: 1,
+				},
+			}},
+		))
+		sb := agg.batches[0]
+		sb.mu.Lock()
+		added := sb.batch.Count()
+		sb.mu.Unlock()
+		agg.requeueBatches(batch)
+
+		sb.mu.Lock()
+		defer sb.mu.Unlock()
+		assert.Same(t, batch[0], sb.batch)
+		assert.Equal(t, taken+added, sb.batch.Count())
+	})
+}
+
+func TestHarvestSchedule(t *testing.T) {
+	agg, err := New(
+		WithDataDir(t.TempDir()),
+		WithAggregationIntervals([]time.Duration{10 * time.Second, time.Minute, time.Hour}),
+		WithHarvestDelay(time.Second),
+		WithHarvestJitter(2*time.Second),
+		WithHarvestOffsets(map[time.Duration]time.Duration{
+			time.Minute: 5 * time.Second,
+			time.Hour:   5 * time.Second,
+		}),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { agg.Close(context.Background()) })
+
+	groups := agg.harvestGroups()
+	assert.Equal(t, []harvestGroup{
+		{offset: 0, ivls: []time.Duration{10 * time.Second}},
+		{offset: 5 * time.Second, ivls: []time.Duration{time.Minute, time.Hour}},
+	}, groups)
+
+	ts := time.Date(2023, 1, 1, 0, 1, 0, 0, time.UTC)
+	assert.Equal(t, []time.Duration{time.Minute}, groups[1].endingAt(ts))
+	assert.Equal(t, []time.Duration{time.Minute, time.Hour}, groups[1].endingAt(ts.Add(59*time.Minute)))
+	assert.Empty(t, groups[1].endingAt(ts.Add(10*time.Second)))
+
+	end := time.Now().Add(time.Minute)
+
+	for i := 0; i < 100; i++ {
+		until := agg.untilHarvest(end, 5*time.Second)
+		expected := time.Until(end.Add(6 * time.Second))
+		assert.GreaterOrEqual(t, until, expected-time.Second)
+		assert.Less(t, until, expected+2*time.Second)
+	}
+}
+
+func TestHarvestLaggingOffset(t *testing.T) {
+	type harvest struct {
+		ivl  time.Duration
+		end  time.Time
+		time time.Time
+	}
+	harvests := make(chan harvest, 10)
+	agg, err := New(
+		WithDataDir(t.TempDir()),
+		WithLimits(Limits{
+			MaxServices:                           10,
+			MaxServiceInstanceGroupsPerService:    10,
+			MaxTransactionGroups:                  10,
+			MaxTransactionGroupsPerService:        10,
+			MaxServiceTransactionGroups:           10,
+			MaxServiceTransactionGroupsPerService: 10,
+			MaxSpanGroups:                         10,
+			MaxSpanGroupsPerService:               10,
+		}),
+		WithAggregationIntervals([]time.Duration{time.Second, 2 * time.Second}),
+		WithHarvestOffsets(map[time.Duration]time.Duration{2 * time.Second: 1500 * time.Millisecond}),
+		WithProcessor(func(_ context.Context, cmk CombinedMetricsKey, _ *aggregationpb.CombinedMetrics, ivl time.Duration) error {
+			harvests <- harvest{ivl: ivl, end: cmk.ProcessingTime.Add(ivl), time: time.Now()}
+			return nil
+		}),
+		WithLogger(zap.NewNop()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { agg.Close(context.Background()) })
+
+	assert.Equal(t, []harvestGroup{
+		{offset: 0, ivls: []time.Duration{time.Second}},
+		{offset: 1500 * time.Millisecond, lag: 1, ivls: []time.Duration{2 * time.Second}},
+	}, agg.harvestGroups())
+
+	require.NoError(t, agg.AggregateBatch(
+		context.Background(),
+		EncodeToCombinedMetricsKeyID(t, "ab01"),
+		&modelpb.Batch{lateTestEvent(time.Now())},
+	))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go agg.Run(ctx)
+
+	// The 2s interval is harvested 1.5s after it ended, after the harvest
+	// of the following 1s interval.
+	var harvested []harvest
+	for len(harvested) < 2 {
+		select {
+		case h := <-harvests:
+			harvested = append(harvested, h)
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for harvests")
+		}
+	}
+	assert.Equal(t, time.Second, harvested[0].ivl)
+	assert.Equal(t, 2*time.Second, harvested[1].ivl)
+	assert.False(t, harvested[1].time.Before(harvested[1].end.Add(1500*time.Millisecond)))
+}
+
+func BenchmarkAggregateCombinedMetrics(b *testing.B) {
+	gatherer, err := apmotel.NewGatherer()
+	if err != nil {
+		b.Fatal(err)
+	}
+	mp := metric.NewMeterProvider(metric.WithReader(gatherer))
+	aggIvl := time.Minute
+	agg, err := New(
+		WithDataDir(b.TempDir()),
+		WithLimits(Limits{
+			MaxSpanGroups:                         1000,
+			MaxSpanGroupsPerService:               100,
+			MaxTransactionGroups:                  1000,
+			MaxTransactionGroupsPerService:        100,
+			MaxServiceTransactionGroups:           1000,
+			MaxServiceTransactionGroupsPerService: 100,
+			MaxServices:                           100,
+			MaxServiceInstanceGroupsPerService:    100,
+		}),
+		WithProcessor(noOpProcessor()),
+		WithMeter(mp.Meter("test")),
+		WithLogger(zap.NewNop()),
+	)
+	if err != nil {
+		b.Fatal(err)
+	}
+	go func() {
+		agg.Run(context.Background())
+	}()
+	b.Cleanup(func() {
+		agg.Close(context.Background())
+	})
+	cmk := CombinedMetricsKey{
+		Interval:       aggIvl,
+		ProcessingTime: time.Now().Truncate(aggIvl),
+		ID:             EncodeToCombinedMetricsKeyID(b, "ab01"),
+	}
+	cm := NewTestCombinedMetrics(WithEventsTotal(1)).
+		AddServiceMetrics(serviceAggregationKey{
+			Timestamp:   time.Now(),
+			ServiceName: "test-svc",
+		}).
+		AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+		AddTransaction(transactionAggregationKey{
+			TransactionName: "txntest",
+			TransactionType: "txntype",
+		}).
+		AddServiceTransaction(serviceTransactionAggregationKey{
+			TransactionType: "txntype",
+		}).
+		GetProto()
+	b.Cleanup(func() { cm.ReturnToVTPool() })
+	ctx, cancel := context.WithCancel(context.Background())
+	b.Cleanup(func() { cancel() })
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := agg.AggregateCombinedMetrics(ctx, cmk, cm); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestAggregateBatchAllocs(t *testing.T) {
+	for name, opts := range map[string][]Option{
+		"default": nil,
+		"encoded": {
+			WithValueCompressionCodec(SnappyCompression, 0),
+			WithValueEncryption(newTestAEAD(t, "0123456789abcdef")),
+		},
+		"zstd": {WithValueCompressionCodec(ZstdCompression, 3)},
+		"telemetry": {
+			WithMeter(metric.NewMeterProvider(metric.WithReader(metric.NewManualReader())).Meter("test")),
+			WithCombinedMetricsIDToKVs(func(id [16]byte) []attribute.KeyValue {
+				return []attribute.KeyValue{attribute.String("id", fmt.Sprintf("%x", id))}
+			}),
+		},
+		"write_coalescing": {WithWriteCoalescing(time.Hour, 1<<20)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			agg, err := New(append([]Option{
+				WithDataDir(t.TempDir()),
+				WithLimits(Limits{
+					MaxServices:                           10,
+					MaxServiceInstanceGroupsPerService:    10,
+					MaxTransactionGroups:                  10,
+					MaxTransactionGroupsPerService:        10,
+					MaxServiceTransactionGroups:           10,
+					MaxServiceTransactionGroupsPerService: 10,
+				}),
+				WithProcessor(noOpProcessor()),
+				WithAggregationIntervals([]time.Duration{time.Second, time.Minute}),
+				WithLogger(zap.NewNop()),
+			}, opts...)...)
+			require.NoError(t, err)
+			t.Cleanup(func() { agg.Close(context.Background()) })
+			batch := newTestBatchForBenchmark()
+			cmID := EncodeToCombinedMetricsKeyID(t, "ab01")
+			allocs := testing.AllocsPerRun(1000, func() {
+				if err := agg.AggregateBatch(context.Background(), cmID, batch); err != nil {
+					t.Fatal(err)
+				}
+			})
+			assert.Zero(t, allocs)
+		})
+	}
+}
+
+func BenchmarkAggregateBatchSerial(b *testing.B) {
+	b.ReportAllocs()
+	agg := newTestAggregator(b)
 	batch := newTestBatchForBenchmark()
 	cmID := EncodeToCombinedMetricsKeyID(b, "ab01")
 	b.ResetTimer()
@@ -1143,7 +2434,6 @@ func BenchmarkAggregateBatchSerial(b *testing.B) {
 func BenchmarkAggregateBatchParallel(b *testing.B) {
 	b.ReportAllocs()
 	agg := newTestAggregator(b)
-	defer agg.Close(context.Background())
 	batch := newTestBatchForBenchmark()
 	cmID := EncodeToCombinedMetricsKeyID(b, "ab01")
 	b.ResetTimer()
@@ -1237,17 +2527,17 @@ func sliceProcessor(slice *[]*modelpb.APMEvent) Processor {
 }
 
 type gatherMetricsCfg struct {
-	ignoreMetricPrefix  string
-	zeroHistogramValues bool
+	ignoreMetricPrefixes []string
+	zeroHistogramValues  bool
 }
 
 type gatherMetricsOpt func(gatherMetricsCfg) gatherMetricsCfg
 
 // withIgnoreMetricPrefix ignores some metric prefixes from the gathered
 // metrics.
-func withIgnoreMetricPrefix(s string) gatherMetricsOpt {
+func withIgnoreMetricPrefix(prefixes ...string) gatherMetricsOpt {
 	return func(cfg gatherMetricsCfg) gatherMetricsCfg {
-		cfg.ignoreMetricPrefix = s
+		cfg.ignoreMetricPrefixes = append(cfg.ignoreMetricPrefixes, prefixes...)
 		return cfg
 	}
 }
@@ -1261,6 +2551,32 @@ func withZeroHistogramValues(b bool) gatherMetricsOpt {
 	}
 }
 
+// limitsMeasurements returns the expected measurements for the limits
+// reported by the aggregator.
+func limitsMeasurements(limits Limits) []apmmodel.Metrics {
+	out := make([]apmmodel.Metrics, 0, 14)
+	for _, l := range limitsTelemetry(limits) {
+		out = append(out, apmmodel.Metrics{
+			Samples: map[string]apmmodel.Metric{
+				"aggregator.limits": {Value: float64(l.Value)},
+			},
+			Labels: apmmodel.StringMap{
+				apmmodel.StringMapItem{Key: "limit", Value: l.Name},
+			},
+		})
+	}
+	return out
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
 func gatherMetrics(g apm.MetricsGatherer, opts ...gatherMetricsOpt) []apmmodel.Metrics {
 	var cfg gatherMetricsCfg
 	for _, opt := range opts {
@@ -1283,7 +2599,7 @@ func gatherMetrics(g apm.MetricsGatherer, opts ...gatherMetricsOpt) []apmmodel.M
 				continue
 			}
 			// Remove any metrics that has been explicitly ignored
-			if cfg.ignoreMetricPrefix != "" && strings.HasPrefix(k, cfg.ignoreMetricPrefix) {
+			if hasAnyPrefix(k, cfg.ignoreMetricPrefixes) {
 				delete(m.Samples, k)
 				continue
 			}
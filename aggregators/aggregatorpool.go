@@ -0,0 +1,151 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+	"github.com/elastic/apm-data/model/modelpb"
+)
+
+// AggregatorPool manages a fixed set of independent aggregators, e.g. one
+// for each logical shard or tenant class, behind the API of a single
+// aggregator. Each aggregator has its own options, in particular its own
+// data directory, isolating the storage of the aggregators. The
+// aggregators share the resources of a Pool, including the meter and the
+// tracer. The combined metrics IDs are routed to the aggregators by a
+// routing function.
+//
+// Close must be called when the pool is no longer needed.
+type AggregatorPool struct {
+	pool        *Pool
+	aggregators []*Aggregator
+	route       func(id [16]byte) int
+}
+
+// NewAggregatorPool returns a new pool of aggregators, creating an
+// aggregator for each of the given aggregator options. The aggregators
+// are created by a Pool with a shared block cache of the given size in
+// bytes and the given base options, see NewPool.
+//
+// route returns the index, in aggregatorOpts, of the aggregator of the
+// combined metrics ID. It must return the same index for an ID for the
+// lifetime of the data directories, the metrics of an ID routed to
+// another aggregator are aggregated and harvested separately.
+func NewAggregatorPool(
+	cacheSize int64,
+	route func(id [16]byte) int,
+	aggregatorOpts [][]Option,
+	opts ...Option,
+) (*AggregatorPool, error) {
+	if route == nil {
+		return nil, errors.New("route must not be nil")
+	}
+	if len(aggregatorOpts) == 0 {
+		return nil, errors.New("at least one aggregator is required")
+	}
+	pool, err := NewPool(cacheSize, opts...)
+	if err != nil {
+		return nil, err
+	}
+	p := &AggregatorPool{
+		pool:        pool,
+		aggregators: make([]*Aggregator, 0, len(aggregatorOpts)),
+		route:       route,
+	}
+	for i, aggOpts := range aggregatorOpts {
+		a, err := pool.New(aggOpts...)
+		if err != nil {
+			return nil, errors.Join(
+				fmt.Errorf("failed to create aggregator %d: %w", i, err),
+				pool.Close(context.Background()),
+			)
+		}
+		p.aggregators = append(p.aggregators, a)
+	}
+	return p, nil
+}
+
+// Aggregators returns the aggregators of the pool, indexed as routed.
+func (p *AggregatorPool) Aggregators() []*Aggregator {
+	return append([]*Aggregator(nil), p.aggregators...)
+}
+
+// AggregateBatch aggregates all events in the batch using the aggregator
+// of the combined metrics ID, see Aggregator.AggregateBatch.
+func (p *AggregatorPool) AggregateBatch(
+	ctx context.Context,
+	id [16]byte,
+	b *modelpb.Batch,
+) error {
+	a, err := p.aggregatorFor(id)
+	if err != nil {
+		return err
+	}
+	return a.AggregateBatch(ctx, id, b)
+}
+
+// AggregateCombinedMetrics aggregates partial metrics using the aggregator
+// of the combined metrics ID of the key, see
+// Aggregator.AggregateCombinedMetrics.
+func (p *AggregatorPool) AggregateCombinedMetrics(
+	ctx context.Context,
+	cmk CombinedMetricsKey,
+	cm *aggregationpb.CombinedMetrics,
+) error {
+	a, err := p.aggregatorFor(cmk.ID)
+	if err != nil {
+		return err
+	}
+	return a.AggregateCombinedMetrics(ctx, cmk, cm)
+}
+
+// Run runs all the aggregators of the pool, see Aggregator.Run. If the
+// run of an aggregator fails, the runs of the other aggregators are
+// stopped and Run returns the error of the first failed run.
+func (p *AggregatorPool) Run(ctx context.Context) error {
+	g, ctx := errgroup.WithContext(ctx)
+	for _, a := range p.aggregators {
+		a := a
+		g.Go(func() error { return a.Run(ctx) })
+	}
+	return g.Wait()
+}
+
+// Close closes all the aggregators of the pool concurrently, performing
+// their final harvests, and releases the shared resources, see
+// Aggregator.Close and Pool.Close.
+func (p *AggregatorPool) Close(ctx context.Context) error {
+	errs := make([]error, len(p.aggregators))
+	var wg sync.WaitGroup
+	for i, a := range p.aggregators {
+		i, a := i, a
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := a.Close(ctx); err != nil {
+				errs[i] = fmt.Errorf("failed to close aggregator %d: %w", i, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errors.Join(errs...), p.pool.Close(ctx))
+}
+
+// aggregatorFor returns the aggregator the combined metrics ID is routed
+// to.
+func (p *AggregatorPool) aggregatorFor(id [16]byte) (*Aggregator, error) {
+	i := p.route(id)
+	if i < 0 || i >= len(p.aggregators) {
+		return nil, fmt.Errorf("combined metrics ID routed to unknown aggregator %d", i)
+	}
+	return p.aggregators[i], nil
+}
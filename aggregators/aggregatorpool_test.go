@@ -0,0 +1,97 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+	"github.com/elastic/apm-data/model/modelpb"
+)
+
+func TestAggregatorPool(t *testing.T) {
+	var mu sync.Mutex
+	harvested := make(map[int][][16]byte)
+	processor := func(i int) Processor {
+		return func(
+			_ context.Context,
+			cmk CombinedMetricsKey,
+			_ *aggregationpb.CombinedMetrics,
+			_ time.Duration,
+		) error {
+			mu.Lock()
+			defer mu.Unlock()
+			harvested[i] = append(harvested[i], cmk.ID)
+			return nil
+		}
+	}
+	pool, err := NewAggregatorPool(
+		1<<20,
+		func(id [16]byte) int { return int(id[15] % 3) },
+		[][]Option{
+			{WithDataDir(t.TempDir()), WithProcessor(processor(0))},
+			{WithDataDir(t.TempDir()), WithProcessor(processor(1))},
+		},
+		WithLimits(Limits{
+			MaxServices:                        10,
+			MaxServiceInstanceGroupsPerService: 10,
+			MaxTransactionGroups:               10,
+			MaxTransactionGroupsPerService:     10,
+		}),
+		WithAggregationIntervals([]time.Duration{time.Minute}),
+		WithLogger(zap.NewNop()),
+	)
+	require.NoError(t, err)
+	require.Len(t, pool.Aggregators(), 2)
+
+	batch := &modelpb.Batch{
+		&modelpb.APMEvent{
+			Event: &modelpb.Event{Duration: durationpb.New(time.Millisecond)},
+			Transaction: &modelpb.Transaction{
+				Name:                "T-1000",
+				Type:                "type",
+				RepresentativeCount: 1,
+			},
+		},
+	}
+	ids := [][16]byte{{15: 0}, {15: 1}, {15: 3}}
+	for _, id := range ids {
+		require.NoError(t, pool.AggregateBatch(context.Background(), id, batch))
+	}
+	assert.EqualError(t,
+		pool.AggregateBatch(context.Background(), [16]byte{15: 2}, batch),
+		"combined metrics ID routed to unknown aggregator 2",
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() { runErr <- pool.Run(ctx) }()
+	cancel()
+	assert.ErrorIs(t, <-runErr, context.Canceled)
+
+	// The final harvests of the aggregators process the IDs routed to them.
+	require.NoError(t, pool.Close(context.Background()))
+	assert.ElementsMatch(t, [][16]byte{ids[0], ids[2]}, harvested[0])
+	assert.ElementsMatch(t, [][16]byte{ids[1]}, harvested[1])
+	assert.ErrorIs(t, pool.AggregateBatch(context.Background(), ids[0], batch), ErrAggregatorClosed)
+}
+
+func TestAggregatorPoolInvalid(t *testing.T) {
+	route := func([16]byte) int { return 0 }
+	_, err := NewAggregatorPool(1<<20, nil, [][]Option{{WithInMemory(true)}})
+	assert.EqualError(t, err, "route must not be nil")
+	_, err = NewAggregatorPool(1<<20, route, nil)
+	assert.EqualError(t, err, "at least one aggregator is required")
+	_, err = NewAggregatorPool(1<<20, route, [][]Option{{WithHarvestChunking(-1)}})
+	assert.ErrorContains(t, err, "failed to create aggregator 0")
+}
@@ -0,0 +1,145 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregatorstest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/elastic/apm-aggregation/aggregators"
+)
+
+// FakeClock is an aggregators.Clock whose time only moves when advanced,
+// for testing the processing time bucketing and the harvest scheduling of
+// an aggregator deterministically, see aggregators.WithClock.
+type FakeClock struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	now  time.Time
+	// timers are the active timers.
+	timers []*fakeTimer
+}
+
+// NewFakeClock returns a fake clock set to the given time.
+func NewFakeClock(now time.Time) *FakeClock {
+	c := &FakeClock{now: now}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now returns the time of the clock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTimer returns a timer firing once the clock is advanced by at least
+// the given duration.
+func (c *FakeClock) NewTimer(d time.Duration) aggregators.Timer {
+	t := &fakeTimer{clock: c, ch: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+// AfterFunc returns a timer calling f in its own goroutine once the clock
+// is advanced by at least the given duration.
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) aggregators.Timer {
+	t := &fakeTimer{clock: c, f: f}
+	t.Reset(d)
+	return t
+}
+
+// Advance advances the clock by the given duration, firing the expired
+// timers in order of their expiry.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	var expired []*fakeTimer
+	for _, t := range c.timers {
+		if !t.deadline.After(c.now) {
+			expired = append(expired, t)
+		}
+	}
+	sort.SliceStable(expired, func(i, j int) bool {
+		return expired[i].deadline.Before(expired[j].deadline)
+	})
+	for _, t := range expired {
+		t.fire(c.now)
+	}
+}
+
+// BlockUntil blocks until at least n timers are active, e.g. until the
+// harvest loop of the aggregator waits for the next harvest.
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.timers) < n {
+		c.cond.Wait()
+	}
+}
+
+type fakeTimer struct {
+	clock    *FakeClock
+	ch       chan time.Time
+	f        func()
+	deadline time.Time
+	active   bool
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	return t.stop()
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	wasActive := t.stop()
+	t.deadline = c.now.Add(d)
+	if d <= 0 {
+		t.fire(c.now)
+		return wasActive
+	}
+	t.active = true
+	c.timers = append(c.timers, t)
+	c.cond.Broadcast()
+	return wasActive
+}
+
+// stop deactivates the timer, it must be called with the clock locked.
+func (t *fakeTimer) stop() bool {
+	wasActive := t.active
+	t.active = false
+	timers := t.clock.timers[:0]
+	for _, other := range t.clock.timers {
+		if other != t {
+			timers = append(timers, other)
+		}
+	}
+	t.clock.timers = timers
+	return wasActive
+}
+
+// fire fires the timer, it must be called with the clock locked.
+func (t *fakeTimer) fire(now time.Time) {
+	t.stop()
+	if t.f != nil {
+		go t.f()
+		return
+	}
+	select {
+	case t.ch <- now:
+	default:
+	}
+}
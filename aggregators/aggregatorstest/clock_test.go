@@ -0,0 +1,95 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregatorstest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+	"github.com/elastic/apm-aggregation/aggregators"
+	"github.com/elastic/apm-data/model/modelpb"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Unix(1000, 0)
+	clock := NewFakeClock(start)
+	timer := clock.NewTimer(time.Second)
+	called := make(chan struct{})
+	clock.AfterFunc(2*time.Second, func() { close(called) })
+	clock.BlockUntil(2)
+
+	clock.Advance(500 * time.Millisecond)
+	assert.Equal(t, start.Add(500*time.Millisecond), clock.Now())
+	select {
+	case <-timer.C():
+		t.Fatal("timer fired early")
+	default:
+	}
+
+	clock.Advance(time.Second)
+	assert.Equal(t, start.Add(1500*time.Millisecond), <-timer.C())
+	assert.False(t, timer.Stop())
+	assert.False(t, timer.Reset(time.Second))
+	assert.True(t, timer.Stop())
+
+	clock.Advance(time.Second)
+	<-called
+}
+
+func TestFakeClockHarvest(t *testing.T) {
+	ctx := context.Background()
+	ivl := time.Minute
+	start := time.Unix(1700000000, 0).Truncate(ivl)
+	clock := NewFakeClock(start.Add(10 * time.Second))
+	harvested := make(chan aggregators.CombinedMetricsKey, 1)
+	agg, err := aggregators.New(
+		aggregators.WithDataDir(t.TempDir()),
+		aggregators.WithAggregationIntervals([]time.Duration{ivl}),
+		aggregators.WithHarvestDelay(time.Second),
+		aggregators.WithClock(clock),
+		aggregators.WithProcessor(func(
+			_ context.Context,
+			cmk aggregators.CombinedMetricsKey,
+			_ *aggregationpb.CombinedMetrics,
+			_ time.Duration,
+		) error {
+			harvested <- cmk
+			return nil
+		}),
+		aggregators.WithLogger(zap.NewNop()),
+	)
+	require.NoError(t, err)
+	defer agg.Close(ctx)
+
+	require.NoError(t, agg.AggregateBatch(ctx, [16]byte{1}, &modelpb.Batch{{
+		Event: &modelpb.Event{Duration: durationpb.New(time.Millisecond)},
+		Transaction: &modelpb.Transaction{
+			Name:                "txn",
+			Type:                "typ",
+			RepresentativeCount: 1,
+		},
+		Service: &modelpb.Service{Name: "svc"},
+	}}))
+	go agg.Run(ctx)
+
+	// The harvest is scheduled after the end of the period and the delay.
+	clock.BlockUntil(1)
+	clock.Advance(50 * time.Second)
+	select {
+	case <-harvested:
+		t.Fatal("harvested before the end of the period")
+	case <-time.After(10 * time.Millisecond):
+	}
+	clock.Advance(time.Second)
+	cmk := <-harvested
+	assert.True(t, start.Equal(cmk.ProcessingTime))
+}
@@ -0,0 +1,275 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+// Package aggregatorstest provides fixtures and golden-file helpers for
+// testing Processor implementations against stable CombinedMetrics inputs.
+package aggregatorstest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+	"github.com/elastic/apm-aggregation/aggregators"
+	"github.com/elastic/apm-data/model/modelpb"
+)
+
+// FixtureConfig configures the CombinedMetrics produced by
+// GenerateCombinedMetrics. All cardinalities are per the parent
+// dimension, e.g. TransactionGroups is the number of transaction groups
+// generated for each service instance.
+type FixtureConfig struct {
+	// Services is the number of distinct services. Defaults to 1.
+	Services int
+	// ServiceInstances is the number of distinct global label sets
+	// generated per service. Defaults to 1.
+	ServiceInstances int
+	// Labels is the number of global labels set on each service instance.
+	Labels int
+	// TransactionGroups is the number of transaction groups generated per
+	// service instance.
+	TransactionGroups int
+	// SpanGroups is the number of span groups generated per service
+	// instance.
+	SpanGroups int
+	// ErrorGroups is the number of error groups generated per service
+	// instance.
+	ErrorGroups int
+	// Limits are the aggregation limits applied while aggregating the
+	// generated events. If left empty the limits are derived from the
+	// configured cardinality so that nothing overflows. Limits lower than
+	// the configured cardinality produce overflow buckets.
+	Limits aggregators.Limits
+	// Timestamp is the timestamp of the generated events. Defaults to the
+	// unix epoch so that the fixture is stable across runs.
+	Timestamp time.Time
+	// Interval is the aggregation interval. Defaults to one minute.
+	Interval time.Duration
+}
+
+// GenerateCombinedMetrics aggregates a deterministic set of events described
+// by cfg and returns the harvested CombinedMetrics along with its key. All
+// keyed metrics are sorted by their marshaled key so that the output is
+// stable, with the exception of the overflow cardinality estimators whose
+// binary encoding is not deterministic. The processing time of the returned
+// key is set to cfg.Timestamp truncated to the aggregation interval rather
+// than the wall clock time at which the fixture was aggregated.
+func GenerateCombinedMetrics(cfg FixtureConfig) (
+	aggregators.CombinedMetricsKey, *aggregationpb.CombinedMetrics, error,
+) {
+	cfg = cfg.withDefaults()
+
+	dataDir, err := os.MkdirTemp("", "aggregatorstest")
+	if err != nil {
+		return aggregators.CombinedMetricsKey{}, nil, fmt.Errorf("failed to create data dir: %w", err)
+	}
+	defer os.RemoveAll(dataDir)
+
+	var (
+		cmk aggregators.CombinedMetricsKey
+		out *aggregationpb.CombinedMetrics
+	)
+	processor := func(
+		_ context.Context,
+		k aggregators.CombinedMetricsKey,
+		cm *aggregationpb.CombinedMetrics,
+		_ time.Duration,
+	) error {
+		cmk = k
+		out = cm.CloneVT()
+		return nil
+	}
+	agg, err := aggregators.New(
+		aggregators.WithDataDir(dataDir),
+		aggregators.WithInMemory(true),
+		aggregators.WithLimits(cfg.Limits),
+		aggregators.WithProcessor(processor),
+		aggregators.WithAggregationIntervals([]time.Duration{cfg.Interval}),
+		aggregators.WithLogger(zap.NewNop()),
+	)
+	if err != nil {
+		return aggregators.CombinedMetricsKey{}, nil, fmt.Errorf("failed to create aggregator: %w", err)
+	}
+	ctx := context.Background()
+	var id [16]byte
+	copy(id[:], "fixture")
+	batch := cfg.events()
+	if err := agg.AggregateBatch(ctx, id, &batch); err != nil {
+		agg.Close(ctx)
+		return aggregators.CombinedMetricsKey{}, nil, fmt.Errorf("failed to aggregate fixture events: %w", err)
+	}
+	if err := agg.Close(ctx); err != nil {
+		return aggregators.CombinedMetricsKey{}, nil, fmt.Errorf("failed to harvest fixture: %w", err)
+	}
+	if out == nil {
+		return aggregators.CombinedMetricsKey{}, nil, fmt.Errorf("no metrics harvested for fixture")
+	}
+	cmk.ProcessingTime = cfg.Timestamp.Truncate(cfg.Interval)
+	sortCombinedMetrics(out)
+	return cmk, out, nil
+}
+
+func (cfg FixtureConfig) withDefaults() FixtureConfig {
+	if cfg.Services <= 0 {
+		cfg.Services = 1
+	}
+	if cfg.ServiceInstances <= 0 {
+		cfg.ServiceInstances = 1
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Minute
+	}
+	if cfg.Timestamp.IsZero() {
+		cfg.Timestamp = time.Unix(0, 0).UTC()
+	}
+	if cfg.Limits == (aggregators.Limits{}) {
+		instances := cfg.Services * cfg.ServiceInstances
+		cfg.Limits = aggregators.Limits{
+			MaxServices:                           cfg.Services,
+			MaxServiceInstanceGroupsPerService:    cfg.ServiceInstances,
+			MaxServiceInstanceGroups:              instances,
+			MaxTransactionGroups:                  instances * cfg.TransactionGroups,
+			MaxTransactionGroupsPerService:        cfg.ServiceInstances * cfg.TransactionGroups,
+			MaxServiceTransactionGroups:           instances,
+			MaxServiceTransactionGroupsPerService: cfg.ServiceInstances,
+			MaxSpanGroups:                         instances * cfg.SpanGroups,
+			MaxSpanGroupsPerService:               cfg.ServiceInstances * cfg.SpanGroups,
+			MaxErrorGroups:                        instances * cfg.ErrorGroups,
+			MaxErrorGroupsPerService:              cfg.ServiceInstances * cfg.ErrorGroups,
+		}
+	}
+	return cfg
+}
+
+func (cfg FixtureConfig) events() modelpb.Batch {
+	var batch modelpb.Batch
+	for s := 0; s < cfg.Services; s++ {
+		for i := 0; i < cfg.ServiceInstances; i++ {
+			base := func() *modelpb.APMEvent {
+				labels := make(modelpb.Labels, cfg.Labels)
+				for l := 0; l < cfg.Labels; l++ {
+					labels[fmt.Sprintf("label-%d", l)] = &modelpb.LabelValue{
+						Value:  fmt.Sprintf("value-%d-%d", i, l),
+						Global: true,
+					}
+				}
+				return &modelpb.APMEvent{
+					Timestamp: timestamppb.New(cfg.Timestamp),
+					Service: &modelpb.Service{
+						Name:        fmt.Sprintf("service-%d", s),
+						Environment: "production",
+						Language:    &modelpb.Language{Name: "go"},
+					},
+					Labels: labels,
+				}
+			}
+			for t := 0; t < cfg.TransactionGroups; t++ {
+				e := base()
+				e.Event = &modelpb.Event{
+					Duration: durationpb.New(time.Duration(t+1) * 10 * time.Millisecond),
+					Outcome:  outcome(t),
+				}
+				e.Transaction = &modelpb.Transaction{
+					Name:                fmt.Sprintf("transaction-%d", t),
+					Type:                "request",
+					Result:              "HTTP 2xx",
+					RepresentativeCount: 1,
+				}
+				batch = append(batch, e)
+			}
+			for sp := 0; sp < cfg.SpanGroups; sp++ {
+				e := base()
+				e.Event = &modelpb.Event{
+					Duration: durationpb.New(time.Duration(sp+1) * time.Millisecond),
+					Outcome:  outcome(sp),
+				}
+				e.Span = &modelpb.Span{
+					Name:                fmt.Sprintf("span-%d", sp),
+					Type:                "db",
+					Subtype:             "postgresql",
+					RepresentativeCount: 1,
+					DestinationService: &modelpb.DestinationService{
+						Resource: fmt.Sprintf("postgresql-%d", sp),
+					},
+				}
+				batch = append(batch, e)
+			}
+			for er := 0; er < cfg.ErrorGroups; er++ {
+				e := base()
+				e.Event = &modelpb.Event{Outcome: outcome(er)}
+				e.Error = &modelpb.Error{
+					GroupingKey: fmt.Sprintf("error-%d", er),
+				}
+				batch = append(batch, e)
+			}
+		}
+	}
+	return batch
+}
+
+func outcome(i int) string {
+	if i%2 == 0 {
+		return "success"
+	}
+	return "failure"
+}
+
+// sortCombinedMetrics sorts all keyed metrics in cm by their marshaled key.
+func sortCombinedMetrics(cm *aggregationpb.CombinedMetrics) {
+	sortByKey(cm.ServiceMetrics, func(m *aggregationpb.KeyedServiceMetrics) []byte {
+		return marshalKey(m.Key)
+	})
+	for _, ksm := range cm.ServiceMetrics {
+		if ksm.Metrics == nil {
+			continue
+		}
+		sortByKey(ksm.Metrics.ServiceInstanceMetrics, func(m *aggregationpb.KeyedServiceInstanceMetrics) []byte {
+			return marshalKey(m.Key)
+		})
+		for _, ksim := range ksm.Metrics.ServiceInstanceMetrics {
+			sim := ksim.Metrics
+			if sim == nil {
+				continue
+			}
+			sortByKey(sim.TransactionMetrics, func(m *aggregationpb.KeyedTransactionMetrics) []byte {
+				return marshalKey(m.Key)
+			})
+			sortByKey(sim.ServiceTransactionMetrics, func(m *aggregationpb.KeyedServiceTransactionMetrics) []byte {
+				return marshalKey(m.Key)
+			})
+			sortByKey(sim.SpanMetrics, func(m *aggregationpb.KeyedSpanMetrics) []byte {
+				return marshalKey(m.Key)
+			})
+			sortByKey(sim.ErrorMetrics, func(m *aggregationpb.KeyedErrorMetrics) []byte {
+				return marshalKey(m.Key)
+			})
+			sortByKey(sim.ServiceGraphEdgeMetrics, func(m *aggregationpb.KeyedServiceGraphEdgeMetrics) []byte {
+				return marshalKey(m.Key)
+			})
+		}
+	}
+}
+
+type vtMarshaler interface {
+	MarshalVT() ([]byte, error)
+}
+
+func marshalKey(k vtMarshaler) []byte {
+	b, _ := k.MarshalVT()
+	return b
+}
+
+func sortByKey[T any](s []T, key func(T) []byte) {
+	sort.SliceStable(s, func(i, j int) bool {
+		return bytes.Compare(key(s[i]), key(s[j])) < 0
+	})
+}
@@ -0,0 +1,91 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregatorstest
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/apm-aggregation/aggregators"
+)
+
+func TestGenerateCombinedMetrics(t *testing.T) {
+	cfg := FixtureConfig{
+		Services:          2,
+		ServiceInstances:  3,
+		Labels:            2,
+		TransactionGroups: 4,
+		SpanGroups:        2,
+		ErrorGroups:       1,
+	}
+	cmk, cm, err := GenerateCombinedMetrics(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, cmk.Interval)
+	assert.Equal(t, time.Unix(0, 0).UTC(), cmk.ProcessingTime.UTC())
+
+	require.Len(t, cm.ServiceMetrics, 2)
+	for i, ksm := range cm.ServiceMetrics {
+		if i > 0 {
+			assert.Less(t, cm.ServiceMetrics[i-1].Key.ServiceName, ksm.Key.ServiceName)
+		}
+		require.Len(t, ksm.Metrics.ServiceInstanceMetrics, 3)
+		for _, ksim := range ksm.Metrics.ServiceInstanceMetrics {
+			assert.NotEmpty(t, ksim.Key.GlobalLabelsStr)
+			assert.Len(t, ksim.Metrics.TransactionMetrics, 4)
+			assert.Len(t, ksim.Metrics.ServiceTransactionMetrics, 1)
+			assert.Len(t, ksim.Metrics.SpanMetrics, 2)
+			assert.Len(t, ksim.Metrics.ErrorMetrics, 1)
+		}
+		assert.Nil(t, ksm.Metrics.OverflowGroups.GetOverflowTransactions())
+		assert.Nil(t, ksm.Metrics.OverflowGroups.GetOverflowSpans())
+	}
+	assert.Nil(t, cm.OverflowServices.GetOverflowTransactions())
+
+	// Generating the same fixture twice yields equal output.
+	_, cm2, err := GenerateCombinedMetrics(cfg)
+	require.NoError(t, err)
+	b1, err := MarshalGolden(cm)
+	require.NoError(t, err)
+	b2, err := MarshalGolden(cm2)
+	require.NoError(t, err)
+	assert.Equal(t, string(b1), string(b2))
+}
+
+func TestGenerateCombinedMetricsOverflow(t *testing.T) {
+	_, cm, err := GenerateCombinedMetrics(FixtureConfig{
+		TransactionGroups: 5,
+		Limits: aggregators.Limits{
+			MaxServices:                           1,
+			MaxServiceInstanceGroupsPerService:    1,
+			MaxServiceInstanceGroups:              1,
+			MaxTransactionGroups:                  2,
+			MaxTransactionGroupsPerService:        2,
+			MaxServiceTransactionGroups:           1,
+			MaxServiceTransactionGroupsPerService: 1,
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, cm.ServiceMetrics, 1)
+	ksm := cm.ServiceMetrics[0]
+	require.Len(t, ksm.Metrics.ServiceInstanceMetrics, 1)
+	assert.Len(t, ksm.Metrics.ServiceInstanceMetrics[0].Metrics.TransactionMetrics, 2)
+	assert.NotNil(t, ksm.Metrics.OverflowGroups.GetOverflowTransactions())
+}
+
+func TestAssertGolden(t *testing.T) {
+	_, cm, err := GenerateCombinedMetrics(FixtureConfig{TransactionGroups: 1})
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "golden.json")
+
+	t.Setenv(UpdateGoldenEnv, "true")
+	AssertGolden(t, path, cm)
+
+	t.Setenv(UpdateGoldenEnv, "")
+	AssertGolden(t, path, cm)
+}
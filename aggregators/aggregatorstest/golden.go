@@ -0,0 +1,78 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregatorstest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// UpdateGoldenEnv is the environment variable which, when set to a
+// non-empty value, makes AssertGolden (re)write golden files instead of
+// comparing against them.
+const UpdateGoldenEnv = "UPDATE_GOLDEN"
+
+// MarshalGolden encodes msgs as an indented JSON array suitable for
+// storing in a golden file. The encoding is stable across runs for equal
+// messages.
+func MarshalGolden(msgs ...proto.Message) ([]byte, error) {
+	docs := make([]json.RawMessage, 0, len(msgs))
+	for _, m := range msgs {
+		b, err := protojson.Marshal(m)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal message: %w", err)
+		}
+		// protojson output is intentionally unstable in its whitespace,
+		// compact it so that the indentation below is deterministic.
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, b); err != nil {
+			return nil, fmt.Errorf("failed to compact message: %w", err)
+		}
+		docs = append(docs, buf.Bytes())
+	}
+	out, err := json.MarshalIndent(docs, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode golden: %w", err)
+	}
+	return append(out, '\n'), nil
+}
+
+// AssertGolden compares msgs against the golden file at path, failing tb
+// with a diff on mismatch. If the UPDATE_GOLDEN environment variable is set
+// the golden file is written instead. Overflow cardinality estimators are
+// not encoded deterministically; when asserting fixtures with overflow,
+// compare the converted output of the Processor rather than the raw
+// CombinedMetrics.
+func AssertGolden(tb testing.TB, path string, msgs ...proto.Message) {
+	tb.Helper()
+	actual, err := MarshalGolden(msgs...)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			tb.Fatalf("failed to create golden dir: %v", err)
+		}
+		if err := os.WriteFile(path, actual, 0o644); err != nil {
+			tb.Fatalf("failed to write golden file: %v", err)
+		}
+		return
+	}
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		tb.Fatalf("failed to read golden file, set %s to create it: %v", UpdateGoldenEnv, err)
+	}
+	if diff := cmp.Diff(string(expected), string(actual)); diff != "" {
+		tb.Errorf("golden file %s mismatch (-want +got):\n%s", path, diff)
+	}
+}
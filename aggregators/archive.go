@@ -0,0 +1,292 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+)
+
+// archiveHeader identifies the protobuf archive format written by the
+// Archiver. The last byte is the version of the format.
+var archiveHeader = []byte("apm-aggregation-archive\x02")
+
+// legacyArchiveHeader identifies the protobuf archives written before the
+// combined metrics keys were versioned, see combinedMetricsKeyVersion.
+var legacyArchiveHeader = []byte("apm-aggregation-archive\x01")
+
+const (
+	archiveFilePrefix         = "combined-metrics-"
+	defaultArchiveMaxFileSize = 64 * 1024 * 1024
+)
+
+// ArchiveFormat is the format of the files written by the Archiver.
+type ArchiveFormat uint8
+
+const (
+	// ArchiveNDJSON writes newline delimited JSON, one object per combined
+	// metrics in the format of ReadOnlyStore.DumpJSON. It is convenient for
+	// offline analysis with generic tooling.
+	ArchiveNDJSON ArchiveFormat = iota
+	// ArchiveProtobuf writes length delimited protobuf encoded combined
+	// metrics, each preceded by the binary encoded key. It is more compact
+	// and cheaper to write than ArchiveNDJSON.
+	ArchiveProtobuf
+)
+
+// ArchiveOption configures the Archiver.
+type ArchiveOption func(archiveConfig) archiveConfig
+
+type archiveConfig struct {
+	format      ArchiveFormat
+	maxFileSize int64
+	maxFiles    int
+}
+
+// WithArchiveFormat configures the format of the archive files. Defaults
+// to ArchiveNDJSON.
+func WithArchiveFormat(format ArchiveFormat) ArchiveOption {
+	return func(c archiveConfig) archiveConfig {
+		c.format = format
+		return c
+	}
+}
+
+// WithArchiveMaxFileSize configures the size in bytes after which the
+// archive file is rotated. The size is measured on the compressed output
+// and checked after each combined metrics is written, so files may exceed
+// it slightly. Defaults to 64MiB.
+func WithArchiveMaxFileSize(size int64) ArchiveOption {
+	return func(c archiveConfig) archiveConfig {
+		c.maxFileSize = size
+		return c
+	}
+}
+
+// WithArchiveMaxFiles configures the maximum number of archive files kept
+// in the directory, the oldest files are removed on rotation. Defaults to
+// 0, keeping all the files.
+func WithArchiveMaxFiles(n int) ArchiveOption {
+	return func(c archiveConfig) archiveConfig {
+		c.maxFiles = n
+		return c
+	}
+}
+
+// Archiver writes the harvested combined metrics to gzip compressed files
+// in a directory, rotated by size, as a cheap archive of the aggregated
+// metrics for offline analysis. The archived files can be read back with
+// Replay. Archiver.Process is a Processor, e.g. configured as one of the
+// sinks with WithSinks. It is safe for concurrent use.
+type Archiver struct {
+	dir string
+	cfg archiveConfig
+
+	mu     sync.Mutex
+	seq    int
+	file   *os.File
+	cw     *countingWriter
+	zw     *gzip.Writer
+	enc    *json.Encoder
+	buf    []byte
+	closed bool
+}
+
+// NewArchiver returns an archiver writing the archive files to dir, which
+// is created if it does not exist. Close must be called to flush the
+// current archive file.
+func NewArchiver(dir string, opts ...ArchiveOption) (*Archiver, error) {
+	cfg := archiveConfig{
+		format:      ArchiveNDJSON,
+		maxFileSize: defaultArchiveMaxFileSize,
+	}
+	for _, opt := range opts {
+		cfg = opt(cfg)
+	}
+	if cfg.format != ArchiveNDJSON && cfg.format != ArchiveProtobuf {
+		return nil, fmt.Errorf("unsupported archive format %d", cfg.format)
+	}
+	if cfg.maxFileSize <= 0 {
+		return nil, errors.New("archive max file size must be greater than zero")
+	}
+	if cfg.maxFiles < 0 {
+		return nil, errors.New("archive max files must not be negative")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	return &Archiver{dir: dir, cfg: cfg}, nil
+}
+
+// Process writes the combined metrics to the current archive file,
+// rotating it once it exceeds the maximum file size.
+func (a *Archiver) Process(
+	_ context.Context,
+	cmk CombinedMetricsKey,
+	cm *aggregationpb.CombinedMetrics,
+	_ time.Duration,
+) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return errors.New("archiver is closed")
+	}
+	if a.file == nil {
+		if err := a.open(); err != nil {
+			return err
+		}
+	}
+	var err error
+	switch a.cfg.format {
+	case ArchiveProtobuf:
+		err = a.writeProtobuf(cmk, cm)
+	default:
+		err = a.writeJSON(cmk, cm)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to archive combined metrics: %w", err)
+	}
+	if a.cw.n >= a.cfg.maxFileSize {
+		return a.rotate()
+	}
+	return nil
+}
+
+// Close flushes and closes the current archive file. Processing after
+// Close returns an error.
+func (a *Archiver) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.closed = true
+	return a.closeFile()
+}
+
+func (a *Archiver) writeProtobuf(cmk CombinedMetricsKey, cm *aggregationpb.CombinedMetrics) error {
+	size := cm.SizeVT()
+	n := CombinedMetricsKeyEncodedSize + binary.MaxVarintLen64 + size
+	if cap(a.buf) < n {
+		a.buf = make([]byte, n)
+	}
+	buf := a.buf[:n]
+	if err := cmk.MarshalBinaryToSizedBuffer(buf[:CombinedMetricsKeyEncodedSize]); err != nil {
+		return err
+	}
+	n = CombinedMetricsKeyEncodedSize
+	n += binary.PutUvarint(buf[n:], uint64(size))
+	if _, err := cm.MarshalToSizedBufferVT(buf[n : n+size]); err != nil {
+		return err
+	}
+	_, err := a.zw.Write(buf[:n+size])
+	return err
+}
+
+func (a *Archiver) writeJSON(cmk CombinedMetricsKey, cm *aggregationpb.CombinedMetrics) error {
+	return a.enc.Encode(dumpedCombinedMetrics{
+		combinedMetricsKeyJSON: cmk.toJSON(),
+		Metrics:                cm,
+	})
+}
+
+func (a *Archiver) open() error {
+	ext := ".ndjson.gz"
+	if a.cfg.format == ArchiveProtobuf {
+		ext = ".pb.gz"
+	}
+	// The timestamp prefix orders the files across restarts, the sequence
+	// number within the archiver.
+	name := fmt.Sprintf(
+		"%s%s-%06d%s",
+		archiveFilePrefix, time.Now().UTC().Format("20060102T150405Z"), a.seq, ext,
+	)
+	a.seq++
+	f, err := os.OpenFile(filepath.Join(a.dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create archive file: %w", err)
+	}
+	a.file = f
+	a.cw = &countingWriter{w: f}
+	a.zw = gzip.NewWriter(a.cw)
+	a.enc = json.NewEncoder(a.zw)
+	if a.cfg.format == ArchiveProtobuf {
+		if _, err := a.zw.Write(archiveHeader); err != nil {
+			return fmt.Errorf("failed to write archive header: %w", err)
+		}
+	}
+	return nil
+}
+
+func (a *Archiver) rotate() error {
+	if err := a.closeFile(); err != nil {
+		return err
+	}
+	if a.cfg.maxFiles == 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(a.dir)
+	if err != nil {
+		return fmt.Errorf("failed to list archive files: %w", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasPrefix(entry.Name(), archiveFilePrefix) {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	var errs []error
+	for len(names) > a.cfg.maxFiles {
+		if err := os.Remove(filepath.Join(a.dir, names[0])); err != nil {
+			errs = append(errs, err)
+		}
+		names = names[1:]
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to remove archive files: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func (a *Archiver) closeFile() error {
+	if a.file == nil {
+		return nil
+	}
+	err := a.zw.Close()
+	if syncErr := a.file.Sync(); err == nil {
+		err = syncErr
+	}
+	if closeErr := a.file.Close(); err == nil {
+		err = closeErr
+	}
+	a.file, a.cw, a.zw, a.enc = nil, nil, nil, nil
+	if err != nil {
+		return fmt.Errorf("failed to close archive file: %w", err)
+	}
+	return nil
+}
+
+// countingWriter counts the bytes written to the underlying writer.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,114 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchiver(t *testing.T) {
+	ctx := context.Background()
+	ivl := time.Minute
+	processingTime := time.Unix(1700000000, 0).UTC()
+	cmk := CombinedMetricsKey{
+		Interval:       ivl,
+		ProcessingTime: processingTime,
+		ID:             EncodeToCombinedMetricsKeyID(t, "ab01"),
+	}
+	cm := NewTestCombinedMetrics(WithEventsTotal(3)).
+		AddServiceMetrics(serviceAggregationKey{Timestamp: processingTime, ServiceName: "svc"}).
+		AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+		GetProto()
+	defer cm.ReturnToVTPool()
+
+	readFiles := func(t *testing.T, dir string) [][]byte {
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		var contents [][]byte
+		for _, entry := range entries {
+			f, err := os.Open(filepath.Join(dir, entry.Name()))
+			require.NoError(t, err)
+			zr, err := gzip.NewReader(f)
+			require.NoError(t, err)
+			b, err := io.ReadAll(zr)
+			require.NoError(t, err)
+			require.NoError(t, f.Close())
+			contents = append(contents, b)
+		}
+		return contents
+	}
+
+	t.Run("ndjson", func(t *testing.T) {
+		dir := t.TempDir()
+		archiver, err := NewArchiver(dir)
+		require.NoError(t, err)
+		require.NoError(t, archiver.Process(ctx, cmk, cm, ivl))
+		require.NoError(t, archiver.Process(ctx, cmk, cm, ivl))
+		require.NoError(t, archiver.Close())
+		assert.Error(t, archiver.Process(ctx, cmk, cm, ivl))
+
+		contents := readFiles(t, dir)
+		require.Len(t, contents, 1)
+		scanner := bufio.NewScanner(bytes.NewReader(contents[0]))
+		var lines int
+		for scanner.Scan() {
+			var dumped dumpedCombinedMetrics
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &dumped))
+			assert.Equal(t, "1m0s", dumped.Interval)
+			assert.Equal(t, processingTime, dumped.ProcessingTime)
+			assert.Equal(t, hex.EncodeToString(cmk.ID[:]), dumped.ID)
+			lines++
+		}
+		assert.Equal(t, 2, lines)
+	})
+
+	t.Run("protobuf_rotated", func(t *testing.T) {
+		dir := t.TempDir()
+		archiver, err := NewArchiver(
+			dir,
+			WithArchiveFormat(ArchiveProtobuf),
+			// Rotated after each combined metrics.
+			WithArchiveMaxFileSize(1),
+			WithArchiveMaxFiles(2),
+		)
+		require.NoError(t, err)
+		for i := 0; i < 3; i++ {
+			require.NoError(t, archiver.Process(ctx, cmk, cm, ivl))
+		}
+		require.NoError(t, archiver.Close())
+
+		contents := readFiles(t, dir)
+		require.Len(t, contents, 2)
+		for _, b := range contents {
+			assert.True(t, bytes.HasPrefix(b, archiveHeader))
+			var key CombinedMetricsKey
+			require.NoError(t, key.UnmarshalBinary(
+				b[len(archiveHeader):len(archiveHeader)+CombinedMetricsKeyEncodedSize],
+			))
+			key.ProcessingTime = key.ProcessingTime.UTC()
+			assert.Equal(t, cmk, key)
+		}
+	})
+
+	t.Run("invalid_options", func(t *testing.T) {
+		_, err := NewArchiver(t.TempDir(), WithArchiveFormat(ArchiveFormat(10)))
+		assert.EqualError(t, err, "unsupported archive format 10")
+		_, err = NewArchiver(t.TempDir(), WithArchiveMaxFileSize(0))
+		assert.EqualError(t, err, "archive max file size must be greater than zero")
+	})
+}
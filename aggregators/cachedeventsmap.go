@@ -48,6 +48,24 @@ func (m *cachedEventsMap) loadAndDelete(end time.Time) map[time.Duration]map[[16
 	return loaded
 }
 
+// loadAndDeleteInterval loads and deletes the counts of the given
+// interval, keyed by ID.
+func (m *cachedEventsMap) loadAndDeleteInterval(interval time.Duration) map[[16]byte]float64 {
+	loaded := make(map[[16]byte]float64)
+	m.m.Range(func(k, v any) bool {
+		key := k.(cachedEventsStatsKey)
+		if key.interval != interval {
+			return true
+		}
+		vscaled := *v.(*uint64)
+		loaded[key.id] = float64(vscaled / math.MaxUint16)
+		m.m.Delete(k)
+		m.countPool.Put(v)
+		return true
+	})
+	return loaded
+}
+
 func (m *cachedEventsMap) add(interval time.Duration, id [16]byte, n float64) {
 	// We use a pool for the value to minimise allocations, as it will
 	// always escape to the heap through LoadOrStore.
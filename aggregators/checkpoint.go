@@ -0,0 +1,140 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// checkpointKeyEncodedSize is the size of the encoded checkpoint keys:
+// - 2 bytes for the checkpoint key prefix
+// - 2 bytes for interval encoding
+// - 16 bytes for ID encoding
+const checkpointKeyEncodedSize = 20
+
+// checkpointKeyPrefix prefixes the keys of the checkpoints. Combined
+// metrics keys start with the aggregation interval in seconds, which is
+// never 0, so the checkpoints are ordered before all combined metrics.
+var checkpointKeyPrefix = []byte{0, 0}
+
+// combinedMetricsLowerBound is the inclusive lower bound of the combined
+// metrics keys, excluding the checkpoints.
+var combinedMetricsLowerBound = []byte{0, 1}
+
+// Checkpoint is the watermark of the combined metrics of a combined
+// metrics ID and aggregation interval successfully processed by the
+// configured Processor.
+type Checkpoint struct {
+	ID       [16]byte
+	Interval time.Duration
+	// ProcessingTime is the latest processing time for which all the
+	// harvested partitions were successfully processed.
+	ProcessingTime time.Time
+}
+
+// Checkpoint returns the persisted checkpoints of all combined metrics IDs
+// and aggregation intervals, ordered by interval and ID. The checkpoint of
+// an ID is recorded as soon as all the partitions of a processing time of
+// the ID are processed, during the harvest. The checkpoints survive
+// restarts of the aggregator, allowing downstream systems to detect gaps
+// in the harvested metrics. Combined metrics harvested while recovering
+// stale metrics after a restart are not processed again if their
+// processing time is not after the checkpoint, only the partitions of the
+// ID being processed when the aggregator crashed may be processed again.
+// Checkpoints older than the max retention are dropped, see
+// WithMaxRetention.
+func (a *Aggregator) Checkpoint() ([]Checkpoint, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	select {
+	case <-a.closed:
+		return nil, ErrAggregatorClosed
+	default:
+	}
+
+	iter := a.db.NewIter(&pebble.IterOptions{
+		LowerBound: checkpointKeyPrefix,
+		UpperBound: combinedMetricsLowerBound,
+		KeyTypes:   pebble.IterKeyTypePointsOnly,
+	})
+	defer iter.Close()
+
+	var checkpoints []Checkpoint
+	for iter.First(); iter.Valid(); iter.Next() {
+		cp, err := decodeCheckpoint(iter.Key(), iter.Value())
+		if err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, cp)
+	}
+	if err := iter.Error(); err != nil {
+		return nil, fmt.Errorf("failed to iterate checkpoints: %w", err)
+	}
+	return checkpoints, nil
+}
+
+// checkpoint returns the persisted checkpoint processing time of the
+// combined metrics ID and aggregation interval, or the zero time if there
+// is no checkpoint.
+func (a *Aggregator) checkpoint(id [16]byte, ivl time.Duration) (time.Time, error) {
+	value, closer, err := a.db.Get(encodeCheckpointKey(id, ivl))
+	if errors.Is(err, pebble.ErrNotFound) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get checkpoint: %w", err)
+	}
+	defer closer.Close()
+	cp, err := decodeCheckpoint(encodeCheckpointKey(id, ivl), value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return cp.ProcessingTime, nil
+}
+
+// recordCheckpoint persists the given processing time as the checkpoint of
+// the combined metrics ID for the aggregation interval. Checkpoints never
+// move backwards, a processing time which is not after the persisted
+// checkpoint is ignored.
+func (a *Aggregator) recordCheckpoint(id [16]byte, ivl time.Duration, processingTime time.Time) error {
+	current, err := a.checkpoint(id, ivl)
+	if err != nil {
+		return err
+	}
+	if !processingTime.After(current) {
+		return nil
+	}
+	var value [8]byte
+	binary.BigEndian.PutUint64(value[:], uint64(processingTime.Unix()))
+	if err := a.db.Set(encodeCheckpointKey(id, ivl), value[:], a.writeOptions); err != nil {
+		return fmt.Errorf("failed to set checkpoint: %w", err)
+	}
+	return nil
+}
+
+func encodeCheckpointKey(id [16]byte, ivl time.Duration) []byte {
+	key := make([]byte, checkpointKeyEncodedSize)
+	copy(key, checkpointKeyPrefix)
+	binary.BigEndian.PutUint16(key[2:], uint16(ivl.Seconds()))
+	copy(key[4:], id[:])
+	return key
+}
+
+func decodeCheckpoint(key, value []byte) (Checkpoint, error) {
+	var cp Checkpoint
+	if len(key) != checkpointKeyEncodedSize || len(value) != 8 {
+		return cp, errors.New("invalid encoded checkpoint")
+	}
+	cp.Interval = time.Duration(binary.BigEndian.Uint16(key[2:])) * time.Second
+	copy(cp.ID[:], key[4:])
+	cp.ProcessingTime = time.Unix(int64(binary.BigEndian.Uint64(value)), 0)
+	return cp, nil
+}
@@ -0,0 +1,188 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+)
+
+func TestCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	ivl := time.Second
+	okID := EncodeToCombinedMetricsKeyID(t, "ab01")
+	failID := EncodeToCombinedMetricsKeyID(t, "ab02")
+	var processed []CombinedMetricsKey
+	agg, err := New(
+		WithDataDir(t.TempDir()),
+		WithLimits(Limits{
+			MaxServices:                        10,
+			MaxServiceInstanceGroupsPerService: 10,
+		}),
+		WithAggregationIntervals([]time.Duration{ivl}),
+		WithProcessor(func(
+			_ context.Context,
+			cmk CombinedMetricsKey,
+			_ *aggregationpb.CombinedMetrics,
+			_ time.Duration,
+		) error {
+			if cmk.ID == failID {
+				return errors.New("failed")
+			}
+			processed = append(processed, cmk)
+			return nil
+		}),
+		WithLogger(zap.NewNop()),
+	)
+	require.NoError(t, err)
+	defer agg.Close(ctx)
+
+	start := time.Unix(agg.processingTime.Unix(), 0)
+	aggregate := func(id [16]byte, processingTime time.Time) {
+		cm := NewTestCombinedMetrics(WithEventsTotal(1)).
+			AddServiceMetrics(serviceAggregationKey{Timestamp: start, ServiceName: "svc"}).
+			AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+			GetProto()
+		defer cm.ReturnToVTPool()
+		require.NoError(t, agg.AggregateCombinedMetrics(ctx, CombinedMetricsKey{
+			Interval:       ivl,
+			ProcessingTime: processingTime,
+			ID:             id,
+		}, cm))
+	}
+	takeBatch := func() pendingBatches {
+		agg.mu.Lock()
+		defer agg.mu.Unlock()
+		return agg.takeBatches()
+	}
+
+	checkpoints, err := agg.Checkpoint()
+	require.NoError(t, err)
+	assert.Empty(t, checkpoints)
+
+	aggregate(okID, start)
+	aggregate(okID, start.Add(ivl))
+	aggregate(failID, start)
+	assert.Error(t, agg.commitAndHarvest(ctx, takeBatch(), start.Add(ivl), []time.Duration{ivl}, nil))
+	assert.NoError(t, agg.commitAndHarvest(ctx, nil, start.Add(2*ivl), []time.Duration{ivl}, nil))
+	assert.Len(t, processed, 2)
+
+	checkpoints, err = agg.Checkpoint()
+	require.NoError(t, err)
+	assert.Equal(t, []Checkpoint{
+		{ID: okID, Interval: ivl, ProcessingTime: start.Add(ivl)},
+	}, checkpoints)
+
+	// Checkpoints are not exposed as combined metrics.
+	it, err := agg.NewIterator(IteratorOptions{})
+	require.NoError(t, err)
+	assert.False(t, it.First())
+	require.NoError(t, it.Close())
+
+	// Combined metrics left behind by a crash after processing are not
+	// processed again when recovering stale metrics.
+	processed = nil
+	aggregate(okID, start.Add(ivl))
+	aggregate(okID, start.Add(2*ivl))
+	require.NoError(t, agg.commitBatch(takeBatch()))
+	agg.mu.Lock()
+	agg.processingTime = start.Add(3 * ivl)
+	agg.mu.Unlock()
+	require.NoError(t, agg.harvestStale(ctx))
+	require.Len(t, processed, 1)
+	assert.Equal(t, start.Add(2*ivl), processed[0].ProcessingTime)
+
+	checkpoints, err = agg.Checkpoint()
+	require.NoError(t, err)
+	assert.Equal(t, []Checkpoint{
+		{ID: okID, Interval: ivl, ProcessingTime: start.Add(2 * ivl)},
+	}, checkpoints)
+
+	require.NoError(t, agg.Close(ctx))
+	_, err = agg.Checkpoint()
+	assert.ErrorIs(t, err, ErrAggregatorClosed)
+}
+
+func TestCheckpointDuringHarvest(t *testing.T) {
+	ctx := context.Background()
+	ivl := time.Second
+	okID := EncodeToCombinedMetricsKeyID(t, "ab01")
+	crashID := EncodeToCombinedMetricsKeyID(t, "ab02")
+	crash := true
+	var processed [][16]byte
+	agg, err := New(
+		WithDataDir(t.TempDir()),
+		WithLimits(Limits{
+			MaxServices:                        10,
+			MaxServiceInstanceGroupsPerService: 10,
+		}),
+		WithAggregationIntervals([]time.Duration{ivl}),
+		WithPartitions(2),
+		WithHarvestLoopRestarts(1, time.Second),
+		WithProcessor(func(
+			_ context.Context,
+			cmk CombinedMetricsKey,
+			_ *aggregationpb.CombinedMetrics,
+			_ time.Duration,
+		) error {
+			if crash && cmk.ID == crashID {
+				panic("crash")
+			}
+			processed = append(processed, cmk.ID)
+			return nil
+		}),
+		WithLogger(zap.NewNop()),
+	)
+	require.NoError(t, err)
+	defer agg.Close(ctx)
+
+	start := time.Unix(agg.processingTime.Unix(), 0)
+	for _, cmk := range []CombinedMetricsKey{
+		{Interval: ivl, ProcessingTime: start, ID: okID, PartitionID: 0},
+		{Interval: ivl, ProcessingTime: start, ID: okID, PartitionID: 1},
+		{Interval: ivl, ProcessingTime: start, ID: crashID},
+	} {
+		cm := NewTestCombinedMetrics(WithEventsTotal(1)).
+			AddServiceMetrics(serviceAggregationKey{Timestamp: start, ServiceName: "svc"}).
+			AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+			GetProto()
+		require.NoError(t, agg.AggregateCombinedMetrics(ctx, cmk, cm))
+		cm.ReturnToVTPool()
+	}
+	agg.mu.Lock()
+	batch := agg.takeBatches()
+	agg.mu.Unlock()
+
+	// The checkpoint of an ID is recorded once all its partitions are
+	// processed, before the harvest of the interval completes.
+	var crashErr *harvestLoopCrashError
+	assert.ErrorAs(t, agg.supervisedCommitAndHarvest(
+		ctx, batch, start.Add(ivl), []time.Duration{ivl}, nil,
+	), &crashErr)
+	assert.Equal(t, [][16]byte{okID, okID}, processed)
+	checkpoints, err := agg.Checkpoint()
+	require.NoError(t, err)
+	assert.Equal(t, []Checkpoint{
+		{ID: okID, Interval: ivl, ProcessingTime: start},
+	}, checkpoints)
+
+	// Only the combined metrics of the ID being processed when the
+	// harvest crashed are processed when recovering.
+	crash = false
+	processed = nil
+	agg.mu.Lock()
+	agg.processingTime = start.Add(ivl)
+	agg.mu.Unlock()
+	require.NoError(t, agg.harvestStale(ctx))
+	assert.Equal(t, [][16]byte{crashID}, processed)
+}
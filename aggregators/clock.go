@@ -0,0 +1,57 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import "time"
+
+// Clock provides the current time and the timers used by the aggregator
+// for bucketing the aggregated metrics by processing time and scheduling
+// the harvests, see WithClock.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// NewTimer returns a timer sending the current time on its channel
+	// after at least the given duration.
+	NewTimer(d time.Duration) Timer
+	// AfterFunc calls f in its own goroutine after at least the given
+	// duration, the returned timer can be used to cancel the call.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a timer created by a Clock, with the semantics of time.Timer.
+type Timer interface {
+	// C returns the channel on which the time is delivered, it is nil for
+	// timers created by AfterFunc.
+	C() <-chan time.Time
+	// Stop prevents the timer from firing, it returns false if the timer
+	// has already expired or been stopped.
+	Stop() bool
+	// Reset changes the timer to expire after the given duration, it
+	// returns true if the timer had been active.
+	Reset(d time.Duration) bool
+}
+
+// realClock is the Clock backed by the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return realTimer{time.AfterFunc(d, f)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
@@ -9,7 +9,10 @@ package aggregators
 
 import (
 	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"sort"
 	"time"
 
@@ -17,26 +20,47 @@ import (
 	"golang.org/x/exp/slices"
 
 	"github.com/elastic/apm-aggregation/aggregationpb"
+	"github.com/elastic/apm-aggregation/aggregators/internal/ddsketch"
 	"github.com/elastic/apm-aggregation/aggregators/internal/hdrhistogram"
+	"github.com/elastic/apm-aggregation/aggregators/internal/tdigest"
 	"github.com/elastic/apm-aggregation/aggregators/internal/timestamppb"
 	"github.com/elastic/apm-aggregation/aggregators/nullable"
 	"github.com/elastic/apm-data/model/modelpb"
 )
 
 // CombinedMetricsKeyEncodedSize gives the encoded size gives the size of
-// CombinedMetricsKey in bytes. The size is used as follows:
+// CombinedMetricsKey in bytes. The size changed from 28 to 29 bytes with
+// the version byte, see CHANGELOG.md. The size is used as follows:
 // - 2 bytes for interval encoding
 // - 8 bytes for timestamp encoding
 // - 16 bytes for ID encoding
 // - 2 bytes for partition ID
-const CombinedMetricsKeyEncodedSize = 28
+// - 1 byte for the version of the encoding
+const CombinedMetricsKeyEncodedSize = 29
+
+// legacyCombinedMetricsKeyEncodedSize is the size of the combined metrics
+// keys encoded without a version by older versions of the library. The
+// legacy keys are decoded as version 0.
+const legacyCombinedMetricsKeyEncodedSize = 28
+
+// combinedMetricsKeyPrefixSize is the size of the encoded combined metrics
+// key up to, and excluding, the partition ID.
+const combinedMetricsKeyPrefixSize = 26
+
+// combinedMetricsKeyVersion is the version of the binary encoding of
+// CombinedMetricsKey. The version is the last byte of the encoded key so
+// that it does not affect the ordering of the keys. It must be bumped
+// whenever the encoding changes, with a migration of the keys of the older
+// versions, see Aggregator.migrateKeys.
+const combinedMetricsKeyVersion = 1
 
 // MarshalBinaryToSizedBuffer will marshal the combined metrics key into
 // its binary representation. The encoded byte slice will be used as a
 // key in pebbledb. To ensure efficient sorting and time range based
 // query, the first 2 bytes of the encoded slice is the aggregation
 // interval, the next 8 bytes of the encoded slice is the processing time
-// followed by combined metrics ID, the last 2 bytes is the partition ID.
+// followed by combined metrics ID and the 2 bytes partition ID. The last
+// byte is the version of the encoding.
 // The binary representation ensures that all entries are ordered by the
 // ID first and then ordered by the partition ID.
 func (k *CombinedMetricsKey) MarshalBinaryToSizedBuffer(data []byte) error {
@@ -56,13 +80,24 @@ func (k *CombinedMetricsKey) MarshalBinaryToSizedBuffer(data []byte) error {
 	offset += 16
 
 	binary.BigEndian.PutUint16(data[offset:], k.PartitionID)
+	offset += 2
+
+	data[offset] = combinedMetricsKeyVersion
 	return nil
 }
 
 // UnmarshalBinary will convert the byte encoded data into CombinedMetricsKey.
+// Keys encoded without a version by older versions of the library are
+// supported.
 func (k *CombinedMetricsKey) UnmarshalBinary(data []byte) error {
-	if len(data) < 12 {
-		return errors.New("invalid encoded data of insufficient length")
+	switch len(data) {
+	case legacyCombinedMetricsKeyEncodedSize:
+	case CombinedMetricsKeyEncodedSize:
+		if v := data[len(data)-1]; v != combinedMetricsKeyVersion {
+			return fmt.Errorf("unsupported combined metrics key version %d", v)
+		}
+	default:
+		return fmt.Errorf("invalid encoded data of length %d", len(data))
 	}
 	var offset int
 	k.Interval = time.Duration(binary.BigEndian.Uint16(data[offset:2])) * time.Second
@@ -85,11 +120,81 @@ func (k *CombinedMetricsKey) SizeBinary() int {
 	return CombinedMetricsKeyEncodedSize
 }
 
+// combinedMetricsKeyJSON is the JSON representation of CombinedMetricsKey.
+type combinedMetricsKeyJSON struct {
+	Interval       string    `json:"interval"`
+	ProcessingTime time.Time `json:"processing_time"`
+	PartitionID    uint16    `json:"partition_id"`
+	ID             string    `json:"id"`
+}
+
+func (k CombinedMetricsKey) toJSON() combinedMetricsKeyJSON {
+	return combinedMetricsKeyJSON{
+		Interval:       k.Interval.String(),
+		ProcessingTime: k.ProcessingTime.UTC(),
+		PartitionID:    k.PartitionID,
+		ID:             hex.EncodeToString(k.ID[:]),
+	}
+}
+
+func (j combinedMetricsKeyJSON) key() (CombinedMetricsKey, error) {
+	var k CombinedMetricsKey
+	ivl, err := time.ParseDuration(j.Interval)
+	if err != nil {
+		return k, fmt.Errorf("failed to parse interval: %w", err)
+	}
+	id, err := hex.DecodeString(j.ID)
+	if err != nil || len(id) != len(k.ID) {
+		return k, fmt.Errorf("invalid combined metrics ID %q", j.ID)
+	}
+	k.Interval = ivl
+	k.ProcessingTime = j.ProcessingTime
+	k.PartitionID = j.PartitionID
+	copy(k.ID[:], id)
+	return k, nil
+}
+
+// MarshalJSON encodes the combined metrics key as a JSON object with the
+// fields `interval`, as a Go duration, `processing_time`, as an RFC 3339
+// timestamp in UTC, `partition_id` and `id`, as a hex encoded string.
+func (k CombinedMetricsKey) MarshalJSON() ([]byte, error) {
+	return json.Marshal(k.toJSON())
+}
+
+// UnmarshalJSON decodes the combined metrics key encoded by MarshalJSON.
+func (k *CombinedMetricsKey) UnmarshalJSON(data []byte) error {
+	var j combinedMetricsKeyJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	key, err := j.key()
+	if err != nil {
+		return err
+	}
+	*k = key
+	return nil
+}
+
 // GetEncodedCombinedMetricsKeyWithoutPartitionID is a util function to
-// remove partition bits from an encoded CombinedMetricsKey.
+// remove partition bits from an encoded CombinedMetricsKey. The returned
+// key keeps the unversioned encoding of 28 bytes, as returned before the
+// encoding was versioned, see
+// GetVersionedCombinedMetricsKeyWithoutPartitionID for the current
+// encoding.
 func GetEncodedCombinedMetricsKeyWithoutPartitionID(src []byte) []byte {
+	var buf [legacyCombinedMetricsKeyEncodedSize]byte
+	copy(buf[:combinedMetricsKeyPrefixSize], src)
+	return buf[:]
+}
+
+// GetVersionedCombinedMetricsKeyWithoutPartitionID is a util function to
+// remove partition bits from an encoded CombinedMetricsKey, returning the
+// key in the current, versioned, encoding of CombinedMetricsKeyEncodedSize
+// bytes.
+func GetVersionedCombinedMetricsKeyWithoutPartitionID(src []byte) []byte {
 	var buf [CombinedMetricsKeyEncodedSize]byte
-	copy(buf[:CombinedMetricsKeyEncodedSize-2], src)
+	copy(buf[:combinedMetricsKeyPrefixSize], src)
+	buf[CombinedMetricsKeyEncodedSize-1] = combinedMetricsKeyVersion
 	return buf[:]
 }
 
@@ -123,6 +228,9 @@ func (k *serviceAggregationKey) ToProto() *aggregationpb.ServiceAggregationKey {
 	pb.ServiceEnvironment = k.ServiceEnvironment
 	pb.ServiceLanguageName = k.ServiceLanguageName
 	pb.AgentName = k.AgentName
+	pb.CustomDimensions = []byte(k.CustomDimensions)
+	pb.KubernetesNamespace = k.KubernetesNamespace
+	pb.KubernetesDeploymentName = k.KubernetesDeploymentName
 	return pb
 }
 
@@ -133,6 +241,9 @@ func (k *serviceAggregationKey) FromProto(pb *aggregationpb.ServiceAggregationKe
 	k.ServiceEnvironment = pb.ServiceEnvironment
 	k.ServiceLanguageName = pb.ServiceLanguageName
 	k.AgentName = pb.AgentName
+	k.CustomDimensions = string(pb.CustomDimensions)
+	k.KubernetesNamespace = pb.KubernetesNamespace
+	k.KubernetesDeploymentName = pb.KubernetesDeploymentName
 }
 
 // ToProto converts ServiceMetrics to its protobuf representation.
@@ -156,17 +267,26 @@ func (m *serviceMetrics) ToProto() *aggregationpb.ServiceMetrics {
 func (k *serviceInstanceAggregationKey) ToProto() *aggregationpb.ServiceInstanceAggregationKey {
 	pb := aggregationpb.ServiceInstanceAggregationKeyFromVTPool()
 	pb.GlobalLabelsStr = []byte(k.GlobalLabelsStr)
+	pb.HostName = k.HostName
+	pb.ContainerId = k.ContainerID
+	pb.KubernetesPodName = k.KubernetesPodName
 	return pb
 }
 
 // FromProto converts protobuf representation to ServiceInstanceAggregationKey.
 func (k *serviceInstanceAggregationKey) FromProto(pb *aggregationpb.ServiceInstanceAggregationKey) {
 	k.GlobalLabelsStr = string(pb.GlobalLabelsStr)
+	k.HostName = pb.HostName
+	k.ContainerID = pb.ContainerId
+	k.KubernetesPodName = pb.KubernetesPodName
 }
 
 // ToProto converts ServiceInstanceMetrics to its protobuf representation.
 func (m *serviceInstanceMetrics) ToProto() *aggregationpb.ServiceInstanceMetrics {
 	pb := aggregationpb.ServiceInstanceMetricsFromVTPool()
+	if m.GlobalLabelsStr != "" {
+		pb.GlobalLabelsStr = []byte(m.GlobalLabelsStr)
+	}
 
 	pb.TransactionMetrics = slices.Grow(pb.TransactionMetrics, len(m.TransactionGroups))
 	for _, m := range m.TransactionGroups {
@@ -183,6 +303,16 @@ func (m *serviceInstanceMetrics) ToProto() *aggregationpb.ServiceInstanceMetrics
 		pb.SpanMetrics = append(pb.SpanMetrics, m)
 	}
 
+	pb.ErrorMetrics = slices.Grow(pb.ErrorMetrics, len(m.ErrorGroups))
+	for _, m := range m.ErrorGroups {
+		pb.ErrorMetrics = append(pb.ErrorMetrics, m)
+	}
+
+	pb.ServiceGraphEdgeMetrics = slices.Grow(pb.ServiceGraphEdgeMetrics, len(m.ServiceGraphEdgeGroups))
+	for _, m := range m.ServiceGraphEdgeGroups {
+		pb.ServiceGraphEdgeMetrics = append(pb.ServiceGraphEdgeMetrics, m)
+	}
+
 	return pb
 }
 
@@ -204,6 +334,10 @@ func (k *transactionAggregationKey) ToProto() *aggregationpb.TransactionAggregat
 	pb.HostHostname = k.HostHostname
 	pb.HostName = k.HostName
 	pb.HostOsPlatform = k.HostOSPlatform
+	pb.HostOsName = k.HostOSName
+	pb.HostOsVersion = k.HostOSVersion
+
+	pb.NetworkConnectionType = k.NetworkConnectionType
 
 	pb.EventOutcome = k.EventOutcome
 
@@ -226,6 +360,8 @@ func (k *transactionAggregationKey) ToProto() *aggregationpb.TransactionAggregat
 	pb.CloudMachineType = k.CloudMachineType
 	pb.CloudProjectId = k.CloudProjectID
 	pb.CloudProjectName = k.CloudProjectName
+
+	pb.CustomDimensions = []byte(k.CustomDimensions)
 	return pb
 }
 
@@ -246,6 +382,10 @@ func (k *transactionAggregationKey) FromProto(pb *aggregationpb.TransactionAggre
 	k.HostHostname = pb.HostHostname
 	k.HostName = pb.HostName
 	k.HostOSPlatform = pb.HostOsPlatform
+	k.HostOSName = pb.HostOsName
+	k.HostOSVersion = pb.HostOsVersion
+
+	k.NetworkConnectionType = pb.NetworkConnectionType
 
 	k.EventOutcome = pb.EventOutcome
 
@@ -268,6 +408,8 @@ func (k *transactionAggregationKey) FromProto(pb *aggregationpb.TransactionAggre
 	k.CloudMachineType = pb.CloudMachineType
 	k.CloudProjectID = pb.CloudProjectId
 	k.CloudProjectName = pb.CloudProjectName
+
+	k.CustomDimensions = string(pb.CustomDimensions)
 }
 
 // ToProto converts ServiceTransactionAggregationKey to its protobuf representation.
@@ -292,6 +434,9 @@ func (k *spanAggregationKey) ToProto() *aggregationpb.SpanAggregationKey {
 	pb.TargetName = k.TargetName
 
 	pb.Resource = k.Resource
+
+	pb.SpanSubtype = k.SpanSubtype
+	pb.CustomDimensions = []byte(k.CustomDimensions)
 	return pb
 }
 
@@ -304,6 +449,41 @@ func (k *spanAggregationKey) FromProto(pb *aggregationpb.SpanAggregationKey) {
 	k.TargetName = pb.TargetName
 
 	k.Resource = pb.Resource
+
+	k.SpanSubtype = pb.SpanSubtype
+	k.CustomDimensions = string(pb.CustomDimensions)
+}
+
+// ToProto converts ErrorAggregationKey to its protobuf representation.
+func (k *errorAggregationKey) ToProto() *aggregationpb.ErrorAggregationKey {
+	pb := aggregationpb.ErrorAggregationKeyFromVTPool()
+	pb.GroupingKey = k.GroupingKey
+	pb.Outcome = k.Outcome
+	return pb
+}
+
+// FromProto converts protobuf representation to ErrorAggregationKey.
+func (k *errorAggregationKey) FromProto(pb *aggregationpb.ErrorAggregationKey) {
+	k.GroupingKey = pb.GroupingKey
+	k.Outcome = pb.Outcome
+}
+
+// ToProto converts ServiceGraphEdgeAggregationKey to its protobuf representation.
+func (k *serviceGraphEdgeAggregationKey) ToProto() *aggregationpb.ServiceGraphEdgeAggregationKey {
+	pb := aggregationpb.ServiceGraphEdgeAggregationKeyFromVTPool()
+	pb.TargetType = k.TargetType
+	pb.TargetName = k.TargetName
+
+	pb.Resource = k.Resource
+	return pb
+}
+
+// FromProto converts protobuf representation to ServiceGraphEdgeAggregationKey.
+func (k *serviceGraphEdgeAggregationKey) FromProto(pb *aggregationpb.ServiceGraphEdgeAggregationKey) {
+	k.TargetType = pb.TargetType
+	k.TargetName = pb.TargetName
+
+	k.Resource = pb.Resource
 }
 
 // ToProto converts Overflow to its protobuf representation.
@@ -321,6 +501,17 @@ func (o *overflow) ToProto() *aggregationpb.Overflow {
 		pb.OverflowSpans = o.OverflowSpan.Metrics
 		pb.OverflowSpansEstimator = hllBytes(o.OverflowSpan.Estimator)
 	}
+	if !o.OverflowError.Empty() {
+		pb.OverflowErrors = o.OverflowError.Metrics
+		pb.OverflowErrorsEstimator = hllBytes(o.OverflowError.Estimator)
+	}
+	if !o.OverflowServiceGraphEdge.Empty() {
+		pb.OverflowServiceGraphEdges = o.OverflowServiceGraphEdge.Metrics
+		pb.OverflowServiceGraphEdgesEstimator = hllBytes(o.OverflowServiceGraphEdge.Estimator)
+	}
+	if o.GlobalLabelsEstimator != nil {
+		pb.OverflowGlobalLabelsEstimator = hllBytes(o.GlobalLabelsEstimator)
+	}
 	return pb
 }
 
@@ -341,6 +532,19 @@ func (o *overflow) FromProto(pb *aggregationpb.Overflow) {
 		o.OverflowSpan.Metrics = pb.OverflowSpans
 		pb.OverflowSpans = nil
 	}
+	if pb.OverflowErrors != nil {
+		o.OverflowError.Estimator = hllSketch(pb.OverflowErrorsEstimator)
+		o.OverflowError.Metrics = pb.OverflowErrors
+		pb.OverflowErrors = nil
+	}
+	if pb.OverflowServiceGraphEdges != nil {
+		o.OverflowServiceGraphEdge.Estimator = hllSketch(pb.OverflowServiceGraphEdgesEstimator)
+		o.OverflowServiceGraphEdge.Metrics = pb.OverflowServiceGraphEdges
+		pb.OverflowServiceGraphEdges = nil
+	}
+	if len(pb.OverflowGlobalLabelsEstimator) > 0 {
+		o.GlobalLabelsEstimator = hllSketch(pb.OverflowGlobalLabelsEstimator)
+	}
 }
 
 // ToProto converts GlobalLabels to its protobuf representation.
@@ -431,6 +635,43 @@ func (gl *GlobalLabels) UnmarshalString(data string) error {
 	return gl.UnmarshalBinary([]byte(data))
 }
 
+// marshalBinary marshals the custom dimensions to binary using protobuf.
+// The dimensions are sorted by key so that the binary representations of
+// equal custom dimensions are equal.
+func (d *CustomDimensions) marshalBinary() ([]byte, error) {
+	if len(d.dims) == 0 {
+		return nil, nil
+	}
+	pb := aggregationpb.GlobalLabelsFromVTPool()
+	defer pb.ReturnToVTPool()
+	pb.Labels = slices.Grow(pb.Labels, len(d.dims))
+	for k, v := range d.dims {
+		pb.Labels = append(pb.Labels, &aggregationpb.Label{Key: k, Value: v})
+	}
+	sort.Slice(pb.Labels, func(i, j int) bool {
+		return pb.Labels[i].Key < pb.Labels[j].Key
+	})
+	return pb.MarshalVT()
+}
+
+// customDimensionLabels unmarshals the binary custom dimensions of an
+// aggregation key to labels.
+func customDimensionLabels(data []byte) (modelpb.Labels, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	pb := aggregationpb.GlobalLabelsFromVTPool()
+	defer pb.ReturnToVTPool()
+	if err := pb.UnmarshalVT(data); err != nil {
+		return nil, err
+	}
+	labels := make(modelpb.Labels, len(pb.Labels))
+	for _, l := range pb.Labels {
+		labels[l.Key] = &modelpb.LabelValue{Value: l.Value}
+	}
+	return labels, nil
+}
+
 func histogramFromProto(h *hdrhistogram.HistogramRepresentation, pb *aggregationpb.HDRHistogram) {
 	if pb == nil {
 		return
@@ -460,6 +701,7 @@ func setHistogramProto(h *hdrhistogram.HistogramRepresentation, pb *aggregationp
 	pb.SignificantFigures = h.SignificantFigures
 	pb.Buckets = pb.Buckets[:0]
 	pb.Counts = pb.Counts[:0]
+	pb.Sparse = pb.Sparse[:0]
 	countsLen := h.CountsRep.Len()
 	if countsLen > cap(pb.Buckets) {
 		pb.Buckets = make([]int32, 0, countsLen)
@@ -473,6 +715,153 @@ func setHistogramProto(h *hdrhistogram.HistogramRepresentation, pb *aggregationp
 	})
 }
 
+// encodeSparseHistogram replaces the buckets and counts of the histogram
+// with their sparse encoding, see the sparse field of HDRHistogram. The
+// buckets are sorted, the index deltas are thus positive and mostly fit in
+// a single byte.
+func encodeSparseHistogram(pb *aggregationpb.HDRHistogram) {
+	if pb == nil || len(pb.Buckets) == 0 {
+		return
+	}
+	sparse := pb.Sparse[:0]
+	var prev int32
+	for i, bucket := range pb.Buckets {
+		sparse = binary.AppendUvarint(sparse, uint64(bucket-prev))
+		sparse = binary.AppendUvarint(sparse, uint64(pb.Counts[i]))
+		prev = bucket
+	}
+	pb.Sparse = sparse
+	pb.Buckets = pb.Buckets[:0]
+	pb.Counts = pb.Counts[:0]
+}
+
+// decodeSparseHistogram replaces the sparse encoding of the histogram, if
+// any, with the decoded buckets and counts. Histograms encoded before the
+// sparse encoding are left as is.
+func decodeSparseHistogram(pb *aggregationpb.HDRHistogram) error {
+	if pb == nil || len(pb.Sparse) == 0 {
+		return nil
+	}
+	pb.Buckets = pb.Buckets[:0]
+	pb.Counts = pb.Counts[:0]
+	var bucket int32
+	for data := pb.Sparse; len(data) > 0; {
+		delta, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errors.New("invalid sparse histogram bucket")
+		}
+		data = data[n:]
+		count, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errors.New("invalid sparse histogram count")
+		}
+		data = data[n:]
+		bucket += int32(delta)
+		pb.Buckets = append(pb.Buckets, bucket)
+		pb.Counts = append(pb.Counts, int64(count))
+	}
+	pb.Sparse = pb.Sparse[:0]
+	return nil
+}
+
+// encodeSparseHistograms encodes all the histograms of the combined
+// metrics sparsely, see encodeSparseHistogram.
+func encodeSparseHistograms(cm *aggregationpb.CombinedMetrics) {
+	forEachHistogram(cm, func(pb *aggregationpb.HDRHistogram) error {
+		encodeSparseHistogram(pb)
+		return nil
+	})
+}
+
+// decodeSparseHistograms decodes all the sparsely encoded histograms of
+// the combined metrics, see decodeSparseHistogram.
+func decodeSparseHistograms(cm *aggregationpb.CombinedMetrics) error {
+	if err := forEachHistogram(cm, decodeSparseHistogram); err != nil {
+		return fmt.Errorf("failed to decode histogram: %w", err)
+	}
+	return nil
+}
+
+// forEachHistogram calls f for each HDR histogram of the combined metrics,
+// stopping at the first error.
+func forEachHistogram(cm *aggregationpb.CombinedMetrics, f func(*aggregationpb.HDRHistogram) error) error {
+	forOverflow := func(o *aggregationpb.Overflow) error {
+		if o == nil {
+			return nil
+		}
+		if err := f(o.OverflowTransactions.GetHistogram()); err != nil {
+			return err
+		}
+		if err := f(o.OverflowServiceTransactions.GetHistogram()); err != nil {
+			return err
+		}
+		return f(o.OverflowServiceGraphEdges.GetHistogram())
+	}
+	for _, ksm := range cm.ServiceMetrics {
+		sm := ksm.GetMetrics()
+		for _, ksim := range sm.GetServiceInstanceMetrics() {
+			sim := ksim.GetMetrics()
+			for _, ktm := range sim.GetTransactionMetrics() {
+				if err := f(ktm.GetMetrics().GetHistogram()); err != nil {
+					return err
+				}
+			}
+			for _, kstm := range sim.GetServiceTransactionMetrics() {
+				if err := f(kstm.GetMetrics().GetHistogram()); err != nil {
+					return err
+				}
+			}
+			for _, kem := range sim.GetServiceGraphEdgeMetrics() {
+				if err := f(kem.GetMetrics().GetHistogram()); err != nil {
+					return err
+				}
+			}
+		}
+		if err := forOverflow(sm.GetOverflowGroups()); err != nil {
+			return err
+		}
+	}
+	return forOverflow(cm.OverflowServices)
+}
+
+func ddSketchFromProto(pb *aggregationpb.DDSketch) *ddsketch.Sketch {
+	relativeAccuracy := ddsketch.DefaultRelativeAccuracy
+	if pb.RelativeAccuracy > 0 {
+		relativeAccuracy = pb.RelativeAccuracy
+	}
+	s := ddsketch.NewWithRelativeAccuracy(relativeAccuracy)
+	s.ZeroCount = pb.ZeroCount
+	s.Indexes = append(s.Indexes, pb.Indexes...)
+	s.Counts = append(s.Counts, pb.Counts...)
+	return s
+}
+
+func setDDSketchProto(s *ddsketch.Sketch, pb *aggregationpb.DDSketch) {
+	pb.RelativeAccuracy = s.RelativeAccuracy
+	pb.ZeroCount = s.ZeroCount
+	pb.Indexes = append(pb.Indexes[:0], s.Indexes...)
+	pb.Counts = append(pb.Counts[:0], s.Counts...)
+}
+
+func tDigestFromProto(pb *aggregationpb.TDigest) *tdigest.TDigest {
+	compression := float64(tdigest.DefaultCompression)
+	if pb.Compression > 0 {
+		compression = pb.Compression
+	}
+	t := tdigest.NewWithCompression(compression)
+	for i, mean := range pb.Means {
+		t.Add(mean, pb.Weights[i])
+	}
+	return t
+}
+
+func setTDigestProto(t *tdigest.TDigest, pb *aggregationpb.TDigest) {
+	means, weights := t.Centroids()
+	pb.Compression = t.Compression()
+	pb.Means = append(pb.Means[:0], means...)
+	pb.Weights = append(pb.Weights[:0], weights...)
+}
+
 func hllBytes(estimator *hyperloglog.Sketch) []byte {
 	if estimator == nil {
 		return nil
@@ -5,14 +5,20 @@
 package aggregators
 
 import (
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/testing/protocmp"
 
+	"github.com/elastic/apm-aggregation/aggregationpb"
 	"github.com/elastic/apm-aggregation/aggregators/internal/hdrhistogram"
 	"github.com/elastic/apm-data/model/modelpb"
 )
@@ -30,6 +36,73 @@ func TestCombinedMetricsKey(t *testing.T) {
 	assert.Empty(t, cmp.Diff(expected, actual))
 }
 
+func TestCombinedMetricsKeyVersion(t *testing.T) {
+	expected := CombinedMetricsKey{
+		Interval:       time.Minute,
+		ProcessingTime: time.Now().Truncate(time.Minute),
+		ID:             EncodeToCombinedMetricsKeyID(t, "ab01"),
+		PartitionID:    3,
+	}
+	data := make([]byte, CombinedMetricsKeyEncodedSize)
+	require.NoError(t, expected.MarshalBinaryToSizedBuffer(data))
+	assert.Equal(t, byte(combinedMetricsKeyVersion), data[len(data)-1])
+
+	// Keys without version written by older versions of the library.
+	var actual CombinedMetricsKey
+	require.NoError(t, actual.UnmarshalBinary(data[:legacyCombinedMetricsKeyEncodedSize]))
+	assert.Empty(t, cmp.Diff(expected, actual))
+
+	data[len(data)-1] = combinedMetricsKeyVersion + 1
+	assert.EqualError(t, actual.UnmarshalBinary(data), "unsupported combined metrics key version 2")
+	assert.EqualError(t, actual.UnmarshalBinary(data[:12]), "invalid encoded data of length 12")
+}
+
+func TestCombinedMetricsKeyJSON(t *testing.T) {
+	expected := CombinedMetricsKey{
+		Interval:       time.Minute,
+		ProcessingTime: time.Unix(1700000000, 0).UTC(),
+		ID:             EncodeToCombinedMetricsKeyID(t, "ab01"),
+		PartitionID:    2,
+	}
+	data, err := json.Marshal(expected)
+	require.NoError(t, err)
+	assert.JSONEq(t, fmt.Sprintf(
+		`{"interval":"1m0s","processing_time":"2023-11-14T22:13:20Z","partition_id":2,"id":%q}`,
+		hex.EncodeToString(expected.ID[:]),
+	), string(data))
+
+	var actual CombinedMetricsKey
+	require.NoError(t, json.Unmarshal(data, &actual))
+	assert.Equal(t, expected, actual)
+
+	assert.EqualError(t, json.Unmarshal([]byte(`{"interval":"1m","id":"ab"}`), &actual), `invalid combined metrics ID "ab"`)
+}
+
+func TestCombinedMetricsJSON(t *testing.T) {
+	ts := time.Unix(1700000000, 0).UTC()
+	expected := NewTestCombinedMetrics(WithEventsTotal(3)).
+		AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "svc"}).
+		AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+		AddTransaction(transactionAggregationKey{
+			TransactionName: "txn",
+			TransactionType: "typ",
+		}, WithTransactionCount(3)).
+		GetProto()
+	data, err := json.Marshal(expected)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"eventsTotal":3`)
+	// The encoding is stable.
+	again, err := json.Marshal(expected)
+	require.NoError(t, err)
+	assert.Equal(t, data, again)
+
+	actual := &aggregationpb.CombinedMetrics{}
+	require.NoError(t, json.Unmarshal(data, actual))
+	assert.Empty(t, cmp.Diff(expected, actual, protocmp.Transform()))
+
+	assert.Error(t, json.Unmarshal([]byte(`{"unknown":1}`), actual))
+}
+
 func TestGetEncodedCombinedMetricsKeyWithoutPartitionID(t *testing.T) {
 	key := CombinedMetricsKey{
 		Interval:       time.Minute,
@@ -44,11 +117,17 @@ func TestGetEncodedCombinedMetricsKeyWithoutPartitionID(t *testing.T) {
 	var expected [CombinedMetricsKeyEncodedSize]byte
 	assert.NoError(t, key.MarshalBinaryToSizedBuffer(expected[:]))
 
+	// The unversioned encoding is kept for compatibility.
 	assert.Equal(
 		t,
-		expected[:],
+		expected[:legacyCombinedMetricsKeyEncodedSize],
 		GetEncodedCombinedMetricsKeyWithoutPartitionID(encoded[:]),
 	)
+	assert.Equal(
+		t,
+		expected[:],
+		GetVersionedCombinedMetricsKeyWithoutPartitionID(encoded[:]),
+	)
 }
 
 func TestGlobalLabels(t *testing.T) {
@@ -79,6 +158,37 @@ func TestGlobalLabels(t *testing.T) {
 	))
 }
 
+func TestCustomDimensions(t *testing.T) {
+	var a, b CustomDimensions
+	for i := 0; i < MaxCustomDimensions; i++ {
+		assert.True(t, a.Set(fmt.Sprintf("dim%d", i), "value"))
+	}
+	assert.False(t, a.Set("extra", "value"))
+	assert.True(t, a.Set("dim0", strings.Repeat("x", MaxCustomDimensionValueLength+1)))
+	for i := MaxCustomDimensions - 1; i >= 0; i-- {
+		b.Set(fmt.Sprintf("dim%d", i), "value")
+	}
+	b.Set("dim0", strings.Repeat("x", MaxCustomDimensionValueLength))
+
+	aBytes, err := a.marshalBinary()
+	require.NoError(t, err)
+	bBytes, err := b.marshalBinary()
+	require.NoError(t, err)
+	assert.Equal(t, aBytes, bBytes)
+
+	labels, err := customDimensionLabels(aBytes)
+	require.NoError(t, err)
+	assert.Len(t, labels, MaxCustomDimensions)
+	assert.NotContains(t, labels, "extra")
+	assert.Len(t, labels["dim0"].Value, MaxCustomDimensionValueLength)
+	assert.Equal(t, "value", labels["dim1"].Value)
+
+	var empty CustomDimensions
+	emptyBytes, err := empty.marshalBinary()
+	require.NoError(t, err)
+	assert.Nil(t, emptyBytes)
+}
+
 func TestHistogramRepresentation(t *testing.T) {
 	expected := hdrhistogram.New()
 	expected.RecordDuration(time.Minute, 2)
@@ -93,6 +203,66 @@ func TestHistogramRepresentation(t *testing.T) {
 	))
 }
 
+func TestSparseHistogram(t *testing.T) {
+	h := hdrhistogram.New()
+	h.RecordDuration(time.Millisecond, 1)
+	h.RecordDuration(2*time.Millisecond, 3)
+	h.RecordDuration(time.Minute, 0.5)
+	expected := histogramToProto(h)
+
+	pb := expected.CloneVT()
+	encodeSparseHistogram(pb)
+	assert.Empty(t, pb.Buckets)
+	assert.Empty(t, pb.Counts)
+	assert.Less(t, pb.SizeVT(), expected.SizeVT())
+
+	require.NoError(t, decodeSparseHistogram(pb))
+	assert.Empty(t, pb.Sparse)
+	assert.Equal(t, expected.Buckets, pb.Buckets)
+	assert.Equal(t, expected.Counts, pb.Counts)
+
+	// Histograms encoded before the sparse encoding are left as is.
+	require.NoError(t, decodeSparseHistogram(pb))
+	assert.Equal(t, expected.Buckets, pb.Buckets)
+
+	pb.Sparse = []byte{0x80}
+	assert.EqualError(t, decodeSparseHistogram(pb), "invalid sparse histogram bucket")
+}
+
+func TestSparseHistograms(t *testing.T) {
+	cm := NewTestCombinedMetrics(WithEventsTotal(3))
+	sim := cm.AddServiceMetrics(serviceAggregationKey{
+		Timestamp:   time.Now(),
+		ServiceName: "svc",
+	}).AddServiceInstanceMetrics(serviceInstanceAggregationKey{})
+	sim.AddTransaction(transactionAggregationKey{TransactionName: "txn"})
+	sim.AddServiceTransaction(serviceTransactionAggregationKey{TransactionType: "typ"})
+	sim.AddTransactionOverflow(transactionAggregationKey{TransactionName: "overflow"})
+	expected := cm.GetProto()
+
+	pb := expected.CloneVT()
+	var histograms int
+	forEachHistogram(pb, func(h *aggregationpb.HDRHistogram) error {
+		if h != nil {
+			histograms++
+		}
+		return nil
+	})
+	assert.Equal(t, 3, histograms)
+
+	encodeSparseHistograms(pb)
+	assert.Less(t, pb.SizeVT(), expected.SizeVT())
+	data, err := pb.MarshalVT()
+	require.NoError(t, err)
+	var actual aggregationpb.CombinedMetrics
+	require.NoError(t, actual.UnmarshalVT(data))
+	require.NoError(t, decodeSparseHistograms(&actual))
+	assert.Empty(t, cmp.Diff(
+		expected, &actual,
+		protocmp.Transform(),
+	))
+}
+
 func BenchmarkCombinedMetricsEncoding(b *testing.B) {
 	b.ReportAllocs()
 	ts := time.Now()
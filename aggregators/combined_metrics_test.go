@@ -231,7 +231,7 @@ func (tsim *TestServiceInstanceMetrics) AddTransaction(
 	svc := tsim.tsm.tcm.Services[tsim.tsm.sk]
 	svcIns := svc.ServiceInstanceGroups[tsim.sik]
 	if oldKtm, ok := svcIns.TransactionGroups[tk]; ok {
-		mergeKeyedTransactionMetrics(oldKtm, ktm)
+		mergeKeyedTransactionMetrics(oldKtm, ktm, 0)
 		ktm = oldKtm
 	}
 	svcIns.TransactionGroups[tk] = ktm
@@ -361,7 +361,7 @@ func (tsim *TestServiceInstanceMetrics) AddSpan(
 	svc := tsim.tsm.tcm.Services[tsim.tsm.sk]
 	svcIns := svc.ServiceInstanceGroups[tsim.sik]
 	if oldKsm, ok := svcIns.SpanGroups[spk]; ok {
-		mergeKeyedSpanMetrics(oldKsm, ksm)
+		mergeKeyedSpanMetrics(oldKsm, ksm, 0)
 		ksm = oldKsm
 	}
 	svcIns.SpanGroups[spk] = ksm
@@ -400,6 +400,108 @@ func (tsim *TestServiceInstanceMetrics) AddSpanOverflow(
 	return tsim
 }
 
+func (tsim *TestServiceInstanceMetrics) AddError(
+	ek errorAggregationKey,
+	count int,
+) *TestServiceInstanceMetrics {
+	kem := aggregationpb.KeyedErrorMetricsFromVTPool()
+	kem.Key = ek.ToProto()
+	kem.Metrics = aggregationpb.ErrorMetricsFromVTPool()
+	kem.Metrics.Count = float64(count)
+
+	svc := tsim.tsm.tcm.Services[tsim.tsm.sk]
+	svcIns := svc.ServiceInstanceGroups[tsim.sik]
+	if oldKem, ok := svcIns.ErrorGroups[ek]; ok {
+		mergeKeyedErrorMetrics(oldKem, kem)
+		kem = oldKem
+	}
+	svcIns.ErrorGroups[ek] = kem
+	return tsim
+}
+
+func (tsim *TestServiceInstanceMetrics) AddErrorOverflow(
+	ek errorAggregationKey,
+	count int,
+) *TestServiceInstanceMetrics {
+	from := aggregationpb.ErrorMetricsFromVTPool()
+	from.Count = float64(count)
+
+	hash := protohash.HashErrorAggregationKey(
+		protohash.HashServiceInstanceAggregationKey(
+			protohash.HashServiceAggregationKey(xxhash.Digest{}, tsim.tsm.sk.ToProto()),
+			tsim.sik.ToProto(),
+		),
+		ek.ToProto(),
+	)
+	if tsim.tsm.overflow {
+		// Global overflow
+		tsim.tsm.tcm.OverflowServices.OverflowError.Merge(from, hash.Sum64())
+	} else {
+		// Per service overflow
+		svc := tsim.tsm.tcm.Services[tsim.tsm.sk]
+		svc.OverflowGroups.OverflowError.Merge(from, hash.Sum64())
+		tsim.tsm.tcm.Services[tsim.tsm.sk] = svc
+	}
+	return tsim
+}
+
+func (tsim *TestServiceInstanceMetrics) AddServiceGraphEdge(
+	ek serviceGraphEdgeAggregationKey,
+	count, errorCount int,
+	duration time.Duration,
+) *TestServiceInstanceMetrics {
+	kgm := aggregationpb.KeyedServiceGraphEdgeMetricsFromVTPool()
+	kgm.Key = ek.ToProto()
+	kgm.Metrics = newTestServiceGraphEdgeMetrics(count, errorCount, duration)
+
+	svc := tsim.tsm.tcm.Services[tsim.tsm.sk]
+	svcIns := svc.ServiceInstanceGroups[tsim.sik]
+	if oldKgm, ok := svcIns.ServiceGraphEdgeGroups[ek]; ok {
+		mergeKeyedServiceGraphEdgeMetrics(oldKgm, kgm)
+		kgm = oldKgm
+	}
+	svcIns.ServiceGraphEdgeGroups[ek] = kgm
+	return tsim
+}
+
+func (tsim *TestServiceInstanceMetrics) AddServiceGraphEdgeOverflow(
+	ek serviceGraphEdgeAggregationKey,
+	count, errorCount int,
+	duration time.Duration,
+) *TestServiceInstanceMetrics {
+	from := newTestServiceGraphEdgeMetrics(count, errorCount, duration)
+	hash := protohash.HashServiceGraphEdgeAggregationKey(
+		protohash.HashServiceInstanceAggregationKey(
+			protohash.HashServiceAggregationKey(xxhash.Digest{}, tsim.tsm.sk.ToProto()),
+			tsim.sik.ToProto(),
+		),
+		ek.ToProto(),
+	)
+	if tsim.tsm.overflow {
+		// Global overflow
+		tsim.tsm.tcm.OverflowServices.OverflowServiceGraphEdge.Merge(from, hash.Sum64())
+	} else {
+		// Per service overflow
+		svc := tsim.tsm.tcm.Services[tsim.tsm.sk]
+		svc.OverflowGroups.OverflowServiceGraphEdge.Merge(from, hash.Sum64())
+		tsim.tsm.tcm.Services[tsim.tsm.sk] = svc
+	}
+	return tsim
+}
+
+func newTestServiceGraphEdgeMetrics(
+	count, errorCount int,
+	duration time.Duration,
+) *aggregationpb.ServiceGraphEdgeMetrics {
+	hdr := hdrhistogram.New()
+	hdr.RecordDuration(duration, float64(count))
+	m := aggregationpb.ServiceGraphEdgeMetricsFromVTPool()
+	m.Count = float64(count)
+	m.ErrorCount = float64(errorCount)
+	m.Histogram = histogramToProto(hdr)
+	return m
+}
+
 func (tsim *TestServiceInstanceMetrics) GetProto() *aggregationpb.CombinedMetrics {
 	return tsim.tsm.tcm.GetProto()
 }
@@ -441,6 +543,18 @@ var combinedMetricsSliceSorters = []cmp.Option{
 			protohash.HashSpanAggregationKey(xxhash.Digest{}, b.Key),
 		)
 	}),
+	protocmp.SortRepeated(func(a, b *aggregationpb.KeyedErrorMetrics) bool {
+		return xxhashDigestLess(
+			protohash.HashErrorAggregationKey(xxhash.Digest{}, a.Key),
+			protohash.HashErrorAggregationKey(xxhash.Digest{}, b.Key),
+		)
+	}),
+	protocmp.SortRepeated(func(a, b *aggregationpb.KeyedServiceGraphEdgeMetrics) bool {
+		return xxhashDigestLess(
+			protohash.HashServiceGraphEdgeAggregationKey(xxhash.Digest{}, a.Key),
+			protohash.HashServiceGraphEdgeAggregationKey(xxhash.Digest{}, b.Key),
+		)
+	}),
 }
 
 func xxhashDigestLess(a, b xxhash.Digest) bool {
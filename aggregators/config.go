@@ -6,8 +6,11 @@ package aggregators
 
 import (
 	"context"
+	"crypto/cipher"
 	"errors"
 	"fmt"
+	"math"
+	"path/filepath"
 	"sort"
 	"time"
 
@@ -16,8 +19,11 @@ import (
 	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"golang.org/x/time/rate"
 
 	"github.com/elastic/apm-aggregation/aggregationpb"
+	"github.com/elastic/apm-aggregation/aggregators/internal/hdrhistogram"
+	"github.com/elastic/apm-data/model/modelpb"
 )
 
 const instrumentationName = "aggregators"
@@ -34,20 +40,212 @@ type Processor func(
 	aggregationIvl time.Duration,
 ) error
 
+// HistogramImpl identifies the data structure used for recording the
+// transaction duration distribution.
+type HistogramImpl uint8
+
+const (
+	// HDRHistogramImpl records durations in an HDR histogram. This is the
+	// default.
+	HDRHistogramImpl HistogramImpl = iota
+	// TDigestImpl records durations in a merging t-digest, trading
+	// accuracy in the middle of the distribution for a smaller size.
+	TDigestImpl
+	// DDSketchImpl records durations in a DDSketch with a relative
+	// accuracy of 1%.
+	DDSketchImpl
+)
+
+// DurationSumEstimate identifies how the sum of the transaction duration
+// summary is derived when harvesting transaction and service transaction
+// metrics.
+type DurationSumEstimate uint8
+
+const (
+	// UpperBoundSumEstimate estimates the sum from the upper bound of the
+	// histogram buckets. This is the default.
+	UpperBoundSumEstimate DurationSumEstimate = iota
+	// LowerBoundSumEstimate estimates the sum from the lower bound of the
+	// histogram buckets.
+	LowerBoundSumEstimate
+	// MidpointSumEstimate estimates the sum from the midpoint of the
+	// histogram buckets.
+	MidpointSumEstimate
+	// RecordedSumEstimate uses the exact sum of the durations, recorded
+	// alongside the histogram.
+	RecordedSumEstimate
+)
+
+// InstanceDimension identifies an additional dimension of the service
+// instance aggregation key.
+type InstanceDimension uint8
+
+const (
+	// HostNameDimension adds the host.name of events to the service
+	// instance aggregation key.
+	HostNameDimension InstanceDimension = iota + 1
+	// ContainerIDDimension adds the container.id of events to the service
+	// instance aggregation key.
+	ContainerIDDimension
+	// KubernetesPodNameDimension adds the kubernetes.pod.name of events to
+	// the service instance aggregation key.
+	KubernetesPodNameDimension
+)
+
+// Compression identifies a compression algorithm.
+type Compression uint8
+
+const (
+	// DefaultCompression uses the default compression of the compressed
+	// data.
+	DefaultCompression Compression = iota
+	// NoCompression disables compression.
+	NoCompression
+	// SnappyCompression compresses using snappy, trading compression ratio
+	// for speed.
+	SnappyCompression
+	// ZstdCompression compresses using zstd.
+	ZstdCompression
+)
+
+// LateEventPolicy identifies how events older than the current aggregation
+// period of an aggregation interval are aggregated, see WithLateEventPolicy.
+type LateEventPolicy uint8
+
+const (
+	// AcceptLateEvents aggregates late events into the current period.
+	// This is the default.
+	AcceptLateEvents LateEventPolicy = iota
+	// DropLateEvents drops the events later than the allowed lateness,
+	// recording them as lost. Events within the allowed lateness are
+	// aggregated into the current period.
+	DropLateEvents
+	// ReopenLateEvents aggregates the events within the allowed lateness
+	// into their own, already harvested, period which is harvested again
+	// with the next harvest of the interval. Events later than the allowed
+	// lateness are dropped, recording them as lost.
+	ReopenLateEvents
+	// RouteLateEvents aggregates the events later than the allowed
+	// lateness into the current period under the dedicated LatePartitionID
+	// partition, allowing the processor to handle them separately. Events
+	// within the allowed lateness are aggregated into the current period.
+	RouteLateEvents
+)
+
+// LatePartitionID is the partition ID of the combined metrics aggregating
+// the late events with RouteLateEvents. It is never used as a partition of
+// the regular combined metrics.
+const LatePartitionID = math.MaxUint16
+
+// maxPebbleLevels is the number of levels of the pebble LSM tree.
+const maxPebbleLevels = 7
+
+// PebbleOptions tunes the pebble database storing the aggregated metrics.
+// Zero values keep the pebble defaults.
+type PebbleOptions struct {
+	// CacheSize is the size of the block cache in bytes. It is ignored for
+	// aggregators created by a Pool, which share the cache of the pool.
+	CacheSize int64
+	// MemTableSize is the size of a memtable in bytes. Larger memtables
+	// reduce write amplification at the cost of memory.
+	MemTableSize int
+	// MemTableStopWritesThreshold is the number of queued memtables at
+	// which writes are stopped until the memtables are flushed.
+	MemTableStopWritesThreshold int
+	// MaxConcurrentCompactions is the maximum number of concurrent
+	// compactions.
+	MaxConcurrentCompactions int
+	// L0CompactionThreshold is the number of L0 read-amplification at
+	// which L0 compactions are started.
+	L0CompactionThreshold int
+	// L0StopWritesThreshold is the number of L0 read-amplification at
+	// which writes are stopped until L0 is compacted.
+	L0StopWritesThreshold int
+	// LevelCompression configures the compression of the sstables of each
+	// level, starting at L0. Levels beyond the configured levels use the
+	// compression of the last configured level.
+	LevelCompression []Compression
+}
+
 // Config contains the required config for running the aggregator.
 type Config struct {
 	DataDir                string
+	ShardDataDirs          []string
+	ColdTierIdleIntervals  int
 	Limits                 Limits
 	Processor              Processor
+	Sinks                  []Sink
 	Partitions             uint16
+	Partitioner            func(hash uint64) uint16
+	MaxAdaptivePartitions  uint16
+	EventsPerPartition     int
 	AggregationIntervals   []time.Duration
 	HarvestDelay           time.Duration
+	HarvestJitter          time.Duration
+	HarvestOffsets         map[time.Duration]time.Duration
+	IntervalRollups        bool
+	HarvestConcurrency     int
+	HarvestChunkServices   int
+	MaxRetention           time.Duration
+	LateEventPolicy        LateEventPolicy
+	AllowedLateness        time.Duration
+	EventTimeBucketing     bool
+	Backfill               bool
 	CombinedMetricsIDToKVs func([16]byte) []attribute.KeyValue
 	InMemory               bool
+	TopKRetention          bool
+	MaxExemplars           int
+	MergeBudget            time.Duration
+	MaxPendingBytes        int
+	FlushInterval          time.Duration
+	FlushBytes             int
+	BlockOnBackpressure    bool
+	ReplayHorizon          time.Duration
+	IngestRateLimit        func([16]byte) rate.Limit
+	MaxCombinedMetricsSize int
+	RecoveryMode           bool
+	CoverageGapHandler     func(CoverageGap)
+	EventLossHandler       func(EventLoss)
+	OverflowNotifier       func(OverflowEvent)
+	ValueCompression       bool
+	ValueCompressionCodec  Compression
+	ValueCompressionLevel  int
+	ValueEncryption        cipher.AEAD
+	DictionarySamples      int
+	DictionaryTrainer      DictionaryTrainer
+	PebbleOptions          PebbleOptions
+
+	GlobalLabelsHashThreshold   int
+	GlobalLabelsAllowlist       []string
+	GlobalLabelsDenylist        []string
+	HistogramImpl               HistogramImpl
+	HistogramSignificantFigures int
+	HistogramMaxDuration        time.Duration
+	SparseHistograms            bool
+	DurationSumEstimate         DurationSumEstimate
+	DurationExtremes            bool
+	SpanResourceNormalizer      func(string) string
+	KeyExtractor                func(*modelpb.APMEvent, *KeySet)
+	SpanSubtypeGroups           bool
+	FaasGroups                  bool
+	MobileGroups                bool
+	KubernetesGroups            bool
+	ServiceNameAliases          map[string]string
+	InstanceDimensions          []InstanceDimension
+	CollapseInstances           bool
+
+	MaxHarvestLoopRestarts    int
+	HarvestLoopRestartBackoff time.Duration
+
+	Clock Clock
 
 	Meter  metric.Meter
 	Tracer trace.Tracer
 	Logger *zap.Logger
+
+	LogSamplingInterval   time.Duration
+	LogSamplingFirst      int
+	LogSamplingThereafter int
 }
 
 // Option allows configuring aggregator based on functional options.
@@ -70,6 +268,45 @@ func WithDataDir(dataDir string) Option {
 	}
 }
 
+// WithShardDataDirs configures the data directories of additional database
+// shards. The stored combined metrics are sharded by combined metrics ID
+// across a database in the data directory configured by WithDataDir and a
+// database in each of the shard data directories, e.g. one per disk, so
+// that writes are not limited by a single database. The database in the
+// data directory additionally persists the harvest checkpoints.
+//
+// Changing the shards of an existing data directory does not lose stored
+// combined metrics, but the combined metrics of an ID stored before the
+// change may be harvested separately from the ones stored after it.
+// Defaults to no additional shards.
+func WithShardDataDirs(dirs []string) Option {
+	return func(c Config) Config {
+		c.ShardDataDirs = dirs
+		return c
+	}
+}
+
+// WithColdTier configures a cold tier for the combined metrics IDs which
+// have not aggregated any events for the given number of lowest aggregation
+// intervals, e.g. mostly idle tenants sharing the aggregator. The combined
+// metrics of cold IDs which are not harvested yet, i.e. of the higher
+// aggregation intervals, are moved out of the memtables of the shards into
+// a separate database in the `cold` directory of the data directory. The
+// cold database is flushed after every move, so that the metrics of cold
+// IDs are only read back for harvesting, reducing the steady-state memory
+// of the aggregator.
+//
+// An ID which aggregates events while cold is moved back to the shards
+// before the next harvest. Moving IDs between the tiers iterates over the
+// stored combined metrics and blocks aggregation while the moved metrics
+// are written. Defaults to 0, disabling the cold tier.
+func WithColdTier(idleIntervals int) Option {
+	return func(c Config) Config {
+		c.ColdTierIdleIntervals = idleIntervals
+		return c
+	}
+}
+
 // WithLimits configures the limits to be used by the aggregator.
 func WithLimits(limits Limits) Option {
 	return func(c Config) Config {
@@ -92,6 +329,63 @@ func WithProcessor(processor Processor) Option {
 	}
 }
 
+// Sink is a named processor of the harvested metrics, see WithSinks.
+type Sink struct {
+	// Name identifies the sink in the logs and the telemetry, it must be
+	// unique.
+	Name string
+	// Processor processes the harvested metrics for the sink.
+	Processor Processor
+	// MaxRetries is the number of times processing is retried after the
+	// processor fails. Defaults to 0, no retries.
+	MaxRetries int
+	// RetryBackoff is the time waited before each retry.
+	RetryBackoff time.Duration
+	// QueueSize is the number of harvested combined metrics queued for
+	// the sink while it processes, or retries, earlier combined metrics.
+	// Combined metrics harvested while the queue is full are dropped for
+	// the sink. Defaults to 1024.
+	QueueSize int
+	// FailureHandler, if set, is called with the key of the combined
+	// metrics which the sink failed to process after its retries, or
+	// which were dropped as the queue was full, see ErrSinkQueueFull.
+	// It is called from the goroutine of the sink and the harvest, and
+	// must not block.
+	FailureHandler func(CombinedMetricsKey, error)
+}
+
+// WithSinks configures several processors of the harvested metrics, e.g.
+// a processor indexing the metrics and a debug processor writing them to a
+// file, replacing the processor configured by WithProcessor. The sinks
+// process each harvested combined metrics concurrently and independently:
+// the harvest queues a copy of the combined metrics for each sink, which
+// processes its queue from its own goroutine and retries on failure as
+// configured, so that a failing or slow sink neither delays the harvest
+// nor the other sinks. Failures of the sinks do not fail the harvest, the
+// processed, failed, retried and dropped combined metrics are reported per
+// sink and the failures are passed to the FailureHandler of the sink. The
+// queued combined metrics are processed before Close returns, unless the
+// context passed to Close is done first. The checkpoints record the
+// combined metrics queued for the sinks, see Aggregator.Checkpoint.
+func WithSinks(sinks ...Sink) Option {
+	return func(c Config) Config {
+		c.Sinks = sinks
+		return c
+	}
+}
+
+// WithClock configures the clock used for bucketing the aggregated metrics
+// by processing time, scheduling the harvests and measuring the harvest
+// delays, allowing tests and simulations to control time
+// deterministically instead of relying on the wall clock. Defaults to the
+// wall clock.
+func WithClock(clock Clock) Option {
+	return func(c Config) Config {
+		c.Clock = clock
+		return c
+	}
+}
+
 // WithPartitions configures the number of partitions for combined metrics
 // written to pebble. Defaults to 1.
 //
@@ -105,6 +399,47 @@ func WithPartitions(n uint16) Option {
 	}
 }
 
+// WithPartitioner configures the number of partitions for combined metrics
+// written to pebble, see WithPartitions, and the function assigning the
+// metrics of an event to the partitions. The partitioner is called with
+// the hash of the aggregation key of every metric and returns its
+// partition ID, which is taken modulo the number of partitions. Metrics
+// of the same aggregation key must always be assigned the same partition.
+// This allows tuning the layout of the partitions for the parallelism of
+// the merges, or forcing specific layouts in tests. Defaults to the hash
+// modulo the number of partitions.
+func WithPartitioner(partitioner func(hash uint64) uint16, n uint16) Option {
+	return func(c Config) Config {
+		c.Partitioner = partitioner
+		c.Partitions = n
+		return c
+	}
+}
+
+// WithAdaptivePartitions enables adaptive partitioning, adjusting the
+// number of partitions of the combined metrics of every ID to its volume
+// between aggregation periods, instead of using the same number of
+// partitions for all IDs. At the end of every period of an aggregation
+// interval, the number of partitions of an ID for the interval is set to
+// one partition per eventsPerPartition events aggregated by the ID in the
+// lowest aggregation interval of the previous period, within the range of
+// the partitions configured by WithPartitions and the given maximum. The
+// number of partitions is thus constant within a period, routing an
+// aggregation key to the same partition for the whole period. With
+// interval rollups, all intervals are adapted at the end of the periods of
+// the highest interval. Hot IDs are thus spread over more partitions,
+// bounding the cost of merging a partition, while idle IDs shrink back to
+// the configured partitions, bounding the number of keys. Only the events
+// aggregated by AggregateBatch are partitioned adaptively. Defaults to a
+// maximum of 0, i.e. disabled.
+func WithAdaptivePartitions(maxPartitions uint16, eventsPerPartition int) Option {
+	return func(c Config) Config {
+		c.MaxAdaptivePartitions = maxPartitions
+		c.EventsPerPartition = eventsPerPartition
+		return c
+	}
+}
+
 // WithAggregationIntervals defines the intervals that aggregator will
 // aggregate for.
 func WithAggregationIntervals(aggIvls []time.Duration) Option {
@@ -139,6 +474,173 @@ func WithHarvestDelay(delay time.Duration) Option {
 	}
 }
 
+// WithHarvestJitter adds a random delay in the range [0, jitter) to every
+// harvest, on top of the harvest delay. The jitter spreads the harvests of
+// many aggregators, which would otherwise all harvest at the same aligned
+// time, over a window. Jitter must be less than the lowest aggregation
+// interval. Defaults to 0, i.e. no jitter.
+func WithHarvestJitter(jitter time.Duration) Option {
+	return func(c Config) Config {
+		c.HarvestJitter = jitter
+		return c
+	}
+}
+
+// WithHarvestConcurrency configures the number of workers calling the
+// processor for the harvested combined metrics of an aggregation interval.
+// Combined metrics are distributed to the workers by combined metrics ID,
+// so that the partitions and processing times of an ID are processed in
+// order by the same worker. With a concurrency greater than 1, the
+// processor must be safe for concurrent use. Defaults to 1, i.e.
+// sequential harvests.
+func WithHarvestConcurrency(n int) Option {
+	return func(c Config) Config {
+		c.HarvestConcurrency = n
+		return c
+	}
+}
+
+// WithHarvestChunking configures the harvest to call the processor with
+// chunks of at most maxServices services of a harvested combined metrics,
+// instead of the whole combined metrics. The services of a chunk are only
+// decoded when the chunk is processed, bounding the memory used by the
+// harvest of high cardinality combined metrics. The context passed to the
+// processor identifies the chunk, see HarvestChunkFromContext. The last
+// chunk holds the overflow and the events total of the combined metrics.
+// Chunking does not bound the memory of merging the combined metrics,
+// which the database merges in memory when reading them. The merged
+// combined metrics are bounded by the configured limits instead, see
+// WithLimits, the excess groups are aggregated into the overflow.
+// Defaults to 0, i.e. no chunking.
+func WithHarvestChunking(maxServices int) Option {
+	return func(c Config) Config {
+		c.HarvestChunkServices = maxServices
+		return c
+	}
+}
+
+// WithMergeBudget configures the maximum time per second spent merging
+// combined metrics, e.g. during compactions of large histograms, across
+// all the merges of the aggregator. Merges exceeding the budget wait for
+// it to replenish, so that they cannot stall the foreground writes. The
+// budget is shared by the merges of the harvest reads as well. The merge
+// time and the time waited are recorded by the aggregator.merge.duration
+// and aggregator.merge.throttled metrics. Defaults to 0, i.e. merges are
+// not throttled.
+func WithMergeBudget(budget time.Duration) Option {
+	return func(c Config) Config {
+		c.MergeBudget = budget
+		return c
+	}
+}
+
+// WithLateEventPolicy configures how events with a timestamp older than
+// the start of the current aggregation period of an interval are
+// aggregated. Without a policy, late events silently land in the current
+// period. Defaults to AcceptLateEvents.
+func WithLateEventPolicy(policy LateEventPolicy) Option {
+	return func(c Config) Config {
+		c.LateEventPolicy = policy
+		return c
+	}
+}
+
+// WithAllowedLateness configures the maximum lateness of an event, i.e.
+// the time by which its timestamp precedes the start of the current
+// aggregation period, handled by the late event policy as described by
+// the LateEventPolicy values, or by event time bucketing as described by
+// WithEventTimeBucketing. ReopenLateEvents requires a positive allowed
+// lateness. Defaults to 0.
+func WithAllowedLateness(lateness time.Duration) Option {
+	return func(c Config) Config {
+		c.AllowedLateness = lateness
+		return c
+	}
+}
+
+// WithEventTimeBucketing configures the aggregator to bucket the events
+// into the aggregation periods of their timestamp, rather than the current
+// processing period, so that the harvested metrics align with when the
+// events happened. A period is held back from harvest until the watermark,
+// i.e. the harvested end time minus the allowed lateness, passes its end.
+// Events of periods already behind the watermark are dropped, recording
+// them as lost, and events timestamped after the current period are
+// bucketed into the current period. The held back periods are harvested
+// by Close. Event time bucketing is not supported with a late event policy
+// or with interval rollups. Defaults to false.
+func WithEventTimeBucketing(enabled bool) Option {
+	return func(c Config) Config {
+		c.EventTimeBucketing = enabled
+		return c
+	}
+}
+
+// WithBackfill configures the aggregator to accept historical events, for
+// example to rebuild the metrics after an outage from archived raw events.
+// The events are bucketed into the aggregation periods of their
+// timestamps, however old, and a call to AggregateBatch advances the
+// watermark of the combined metrics ID to the latest event timestamp of
+// the batch minus the allowed lateness when it closes a period of the
+// lowest aggregation interval, harvesting the closed periods immediately,
+// see AdvanceWatermark. The periods still open
+// at the end of the backfill are harvested by advancing the watermark past
+// them. Backfill requires event time bucketing. Defaults to false.
+func WithBackfill(enabled bool) Option {
+	return func(c Config) Config {
+		c.Backfill = enabled
+		return c
+	}
+}
+
+// WithMaxRetention configures the maximum age of the processing time of
+// stored combined metrics, relative to the harvested end time. Keys older
+// than the retention are dropped by a garbage collection run after each
+// harvest. This reclaims keys which would otherwise never be harvested,
+// e.g. keys of an aggregation interval removed from the configuration.
+// Checkpoints older than the retention are dropped as well, see
+// Aggregator.Checkpoint. The retention must be greater than the highest
+// aggregation interval. Defaults to 0, i.e. no garbage collection.
+func WithMaxRetention(retention time.Duration) Option {
+	return func(c Config) Config {
+		c.MaxRetention = retention
+		return c
+	}
+}
+
+// WithHarvestOffsets configures an additional delay per aggregation
+// interval for harvesting the metrics of that interval, on top of the
+// harvest delay. This allows, for example, harvesting the sub-minute
+// intervals as soon as they end while aligning the harvest of larger
+// intervals to a later point, or waiting longer for late events of the
+// larger intervals, e.g. 5s for 1m metrics but 5m for 60m metrics,
+// without delaying the harvest of the smaller intervals. Each offset must
+// be keyed by a configured aggregation interval and must be less than
+// that aggregation interval. Intervals without an offset are harvested
+// without any additional delay.
+func WithHarvestOffsets(offsets map[time.Duration]time.Duration) Option {
+	return func(c Config) Config {
+		c.HarvestOffsets = offsets
+		return c
+	}
+}
+
+// WithIntervalRollups configures the aggregator to aggregate events and
+// combined metrics only for the lowest aggregation interval, deriving the
+// metrics of the higher intervals by merging the combined metrics
+// harvested for the lowest interval. This reduces the writes to the
+// database by a factor of the number of aggregation intervals, at the
+// cost of applying the limits once per lowest interval before merging.
+// The harvest offsets of the higher intervals must not be lower than the
+// offset of the lowest interval, so that the lowest interval is harvested
+// first. Combined metrics aggregated explicitly for a higher interval are
+// aggregated as is. Defaults to false.
+func WithIntervalRollups(enabled bool) Option {
+	return func(c Config) Config {
+		c.IntervalRollups = enabled
+		return c
+	}
+}
+
 // WithMeter defines a custom meter which will be used for collecting
 // telemetry. Defaults to the meter provided by global provider.
 func WithMeter(meter metric.Meter) Option {
@@ -174,6 +676,23 @@ func WithLogger(logger *zap.Logger) Option {
 	}
 }
 
+// WithLogSampling rate limits the repeated log messages of the aggregator,
+// e.g. the errors logged for every failed harvest of a combined metrics.
+// Within every interval, the first occurrences of a message and level are
+// logged, after which only every thereafter-th occurrence is logged, a
+// thereafter of 0 dropping all the other occurrences. Independently of
+// the sampling, the harvest errors are logged with an error.class field
+// classifying them as processor, merge or limit_overflow errors. Defaults
+// to an interval of 0, i.e. disabled.
+func WithLogSampling(interval time.Duration, first, thereafter int) Option {
+	return func(c Config) Config {
+		c.LogSamplingInterval = interval
+		c.LogSamplingFirst = first
+		c.LogSamplingThereafter = thereafter
+		return c
+	}
+}
+
 // WithInMemory defines whether aggregator uses in-memory file system.
 func WithInMemory(enabled bool) Option {
 	return func(c Config) Config {
@@ -182,6 +701,470 @@ func WithInMemory(enabled bool) Option {
 	}
 }
 
+// WithTopKRetention enables retaining the highest throughput groups once
+// the transaction, service transaction or span group limits are reached.
+// By default, the groups seen first are retained and any new group
+// overflows. With top-K retention, a new group replaces the lowest
+// throughput group of the same service instance if the new group has a
+// higher throughput, and the replaced group is merged into the overflow
+// bucket instead. Service and service instance limits are not affected.
+//
+// Throughput is compared when the aggregated metrics are merged, so the
+// retained groups approximate the top-K groups for an aggregation period.
+func WithTopKRetention(enabled bool) Option {
+	return func(c Config) Config {
+		c.TopKRetention = enabled
+		return c
+	}
+}
+
+// WithMaxPendingBytes configures the maximum number of aggregated bytes
+// that can be pending to be committed to the database. Once the limit is
+// reached, AggregateBatch and AggregateCombinedMetrics return
+// ErrBackpressure, or block if configured by WithBlockOnBackpressure,
+// until the pending bytes are committed. This bounds the memory used by
+// the aggregator when the database commits fall behind the ingestion.
+// Defaults to 0, i.e. no limit.
+func WithMaxPendingBytes(n int) Option {
+	return func(c Config) Config {
+		c.MaxPendingBytes = n
+		return c
+	}
+}
+
+// WithWriteCoalescing configures how the writes of concurrent AggregateBatch
+// and AggregateCombinedMetrics calls are coalesced into a single database
+// commit. The coalesced writes are committed once they exceed flushBytes,
+// or at the latest flushInterval after the first coalesced write, and
+// before every harvest. A longer interval and a higher size trade a
+// bounded increase in the latency until writes are durable for fewer
+// commits under many concurrent producers. Defaults to a size of 10MB and
+// no interval, i.e. writes are only committed by size or harvest.
+func WithWriteCoalescing(flushInterval time.Duration, flushBytes int) Option {
+	return func(c Config) Config {
+		c.FlushInterval = flushInterval
+		c.FlushBytes = flushBytes
+		return c
+	}
+}
+
+// WithBlockOnBackpressure configures the aggregator to block writes until
+// the pending bytes are committed, instead of returning ErrBackpressure,
+// when the limit configured by WithMaxPendingBytes is reached. Blocked
+// writes return when the passed context is cancelled or the aggregator
+// is closed.
+func WithBlockOnBackpressure(block bool) Option {
+	return func(c Config) Config {
+		c.BlockOnBackpressure = block
+		return c
+	}
+}
+
+// WithRecoveryMode configures Run to immediately harvest the combined
+// metrics of processing times older than the current aggregation period
+// before starting the harvest loop. Such combined metrics are left behind
+// in the database if a previous run crashed and would otherwise never be
+// harvested. Defaults to false.
+func WithRecoveryMode(enabled bool) Option {
+	return func(c Config) Config {
+		c.RecoveryMode = enabled
+		return c
+	}
+}
+
+// WithCoverageGapHandler configures a function called with the coverage
+// gaps detected while harvesting, i.e. aggregation periods of a combined
+// metrics ID and aggregation interval for which no metrics were emitted
+// to the processor. A gap is either detected when the harvest of a period
+// fails, or when a period is harvested and the preceding periods since the
+// previously harvested period were not harvested. The latter includes
+// periods without any aggregated metrics. Gaps are also recorded in the
+// aggregator.harvest.gaps metric. The handler is called synchronously from
+// the harvest and must not block.
+func WithCoverageGapHandler(fn func(CoverageGap)) Option {
+	return func(c Config) Config {
+		c.CoverageGapHandler = fn
+		return c
+	}
+}
+
+// WithEventLossHandler configures a function called at harvest with the
+// event losses of each combined metrics ID during the harvested period of
+// an aggregation interval, e.g. for emitting them as the aggregation_loss
+// metricset using EventLossToAPMEvent. Events rejected at ingestion are
+// lost for all the aggregation intervals. Losses are also recorded in the
+// aggregator.events.lost metric regardless of the handler. The handler is
+// called synchronously from the harvest and must not block.
+func WithEventLossHandler(fn func(EventLoss)) Option {
+	return func(c Config) Config {
+		c.EventLossHandler = fn
+		return c
+	}
+}
+
+// WithOverflowNotifier configures a function called when the combined
+// metrics of an ID hit an aggregation limit for the first time in an
+// aggregation period, with the limit and a sample of the offending keys,
+// e.g. for surfacing a warning to the users of the ID. The limits are
+// detected as the combined metrics are merged, thus the notifications
+// precede the harvest of the period. Notifications are rate limited
+// across all IDs and may be dropped. The notifier is called synchronously
+// from the storage merge operations and must not block.
+func WithOverflowNotifier(fn func(OverflowEvent)) Option {
+	return func(c Config) Config {
+		c.OverflowNotifier = fn
+		return c
+	}
+}
+
+// WithPebbleOptions tunes the pebble database storing the aggregated
+// metrics, for example to trade memory for lower write amplification in
+// large installations. Zero values of the options keep the pebble
+// defaults. Defaults to the pebble defaults for all options.
+func WithPebbleOptions(opts PebbleOptions) Option {
+	return func(c Config) Config {
+		c.PebbleOptions = opts
+		return c
+	}
+}
+
+// WithValueCompression enables zstd compression of the combined metrics
+// values stored in the database. Values stored uncompressed remain
+// readable, so compression can be enabled for existing data directories.
+// Defaults to false.
+func WithValueCompression(enabled bool) Option {
+	return func(c Config) Config {
+		c.ValueCompression = enabled
+		return c
+	}
+}
+
+// WithValueCompressionCodec configures the compression of the combined
+// metrics values stored in the database, taking precedence over
+// WithValueCompression. SnappyCompression trades compression ratio for
+// CPU, ZstdCompression compresses at the given zstd level in the range
+// [1, 22], the level 0 uses the fastest level. Larger levels shrink the
+// on-disk footprint of high-cardinality combined metrics, such as for
+// long aggregation intervals, at the cost of ingest CPU. Values stored
+// with a different compression remain readable. Defaults to
+// DefaultCompression, i.e. as configured by WithValueCompression.
+func WithValueCompressionCodec(compression Compression, level int) Option {
+	return func(c Config) Config {
+		c.ValueCompressionCodec = compression
+		c.ValueCompressionLevel = level
+		return c
+	}
+}
+
+// WithValueEncryption configures the encryption of the combined metrics
+// values stored in the database, and of the compression dictionary, using
+// the given AEAD, for example AES-GCM created with a caller-provided key.
+// Values hold the service names and labels of the aggregated metrics,
+// keys only hold the aggregation interval, processing time, combined
+// metrics ID and partition ID and are not encrypted as they need to be
+// ordered. The same key must be used to read encrypted values, values
+// stored unencrypted remain readable. Note that Snapshot exports values
+// unencrypted. Defaults to no encryption.
+func WithValueEncryption(aead cipher.AEAD) Option {
+	return func(c Config) Config {
+		c.ValueEncryption = aead
+		return c
+	}
+}
+
+// WithDictionaryTraining configures training a compression dictionary from
+// the first samples values aggregated with value compression enabled.
+// Highly repetitive values, such as the combined metrics of many similar
+// deployments on large multi-tenant nodes, compress materially better
+// with a dictionary. The dictionary is trained once and persisted in the
+// data directory, it is only kept in memory for in-memory databases. If
+// trainer is nil, RawContentDictionary is used. Requires value compression.
+func WithDictionaryTraining(samples int, trainer DictionaryTrainer) Option {
+	return func(c Config) Config {
+		c.DictionarySamples = samples
+		c.DictionaryTrainer = trainer
+		if trainer == nil {
+			c.DictionaryTrainer = RawContentDictionary
+		}
+		return c
+	}
+}
+
+// WithMaxCombinedMetricsSize configures the maximum encoded size, in bytes,
+// of the combined metrics accepted by AggregateCombinedMetrics. Larger
+// combined metrics are rejected with a *CombinedMetricsTooLargeError,
+// protecting aggregators receiving forwarded combined metrics from
+// pathological or malicious inputs which would be expensive to merge. The
+// size of all combined metrics is recorded in the
+// aggregator.combined_metrics.size metric. Defaults to 0, which disables
+// the limit.
+func WithMaxCombinedMetricsSize(size int) Option {
+	return func(c Config) Config {
+		c.MaxCombinedMetricsSize = size
+		return c
+	}
+}
+
+// WithIngestRateLimit configures a limit of the rate of events, in events
+// per second, aggregated by AggregateBatch for each combined metrics ID, so
+// that a single ID cannot consume the whole write budget of the aggregator.
+// The limit function is called with the ID of every batch, so that limits
+// can be changed at runtime, and must be cheap and safe for concurrent use.
+// Batches exceeding the limit of their ID are rejected as a whole with a
+// *RateLimitedError, matching ErrRateLimited, and their events are
+// accounted as lost. Bursts of up to one second of events are accepted,
+// and batches larger than that once enough time has elapsed for the rate.
+// A limit of rate.Inf disables the limit of the ID, a limit of 0 rejects
+// all the events of the ID. Defaults to nil, i.e. no limits.
+func WithIngestRateLimit(limit func(id [16]byte) rate.Limit) Option {
+	return func(c Config) Config {
+		c.IngestRateLimit = limit
+		return c
+	}
+}
+
+// WithReplayHorizon configures the maximum age of the processing time of
+// combined metrics accepted by AggregateCombinedMetrics. Combined metrics
+// with a processing time older than the horizon, relative to the current
+// time, are rejected with a *StaleProcessingTimeError. This prevents a
+// replaying producer from resurrecting long harvested aggregation periods.
+// Defaults to 0, i.e. no horizon.
+func WithReplayHorizon(horizon time.Duration) Option {
+	return func(c Config) Config {
+		c.ReplayHorizon = horizon
+		return c
+	}
+}
+
+// WithMaxExemplars configures the maximum number of exemplars retained per
+// transaction and span group. An exemplar identifies a sampled event of a
+// group by its trace ID, transaction or span ID, exact duration and
+// timestamp. If more exemplars are recorded for a group, the exemplars
+// covering the range of recorded durations are retained, see
+// WithExemplarHandler for accessing the exemplars when converting to
+// APMEvents. Overflow buckets do not retain exemplars. Defaults to 0,
+// which disables recording exemplars.
+func WithMaxExemplars(n int) Option {
+	return func(c Config) Config {
+		c.MaxExemplars = n
+		return c
+	}
+}
+
+// WithGlobalLabelsHashThreshold configures the aggregator to replace the
+// serialized global labels in service instance keys with a hash when the
+// serialized global labels are larger than the given threshold in bytes,
+// see WithHashedGlobalLabels. A threshold of 0, the default, disables
+// hashing.
+func WithGlobalLabelsHashThreshold(threshold int) Option {
+	return func(c Config) Config {
+		c.GlobalLabelsHashThreshold = threshold
+		return c
+	}
+}
+
+// WithGlobalLabelFilter configures the global labels included in the
+// service instance aggregation key, see WithFilteredGlobalLabels. If allow
+// is not empty, only the global labels with a key in allow are included.
+// Global labels with a key in deny are dropped. Dropped global labels are
+// not part of the aggregated metrics. By default, all global labels are
+// included.
+func WithGlobalLabelFilter(allow, deny []string) Option {
+	return func(c Config) Config {
+		c.GlobalLabelsAllowlist = allow
+		c.GlobalLabelsDenylist = deny
+		return c
+	}
+}
+
+// WithKeyExtractor configures a function for adding custom dimensions to
+// the service, transaction and span aggregation keys of events, see
+// WithCustomDimensions. The custom dimensions are added as labels to the
+// aggregated metrics. Each custom dimension multiplies the number of
+// groups, so the extractor should only add dimensions of low cardinality.
+func WithKeyExtractor(extractor func(*modelpb.APMEvent, *KeySet)) Option {
+	return func(c Config) Config {
+		c.KeyExtractor = extractor
+		return c
+	}
+}
+
+// WithSpanResourceNormalizer configures a function for normalizing the
+// destination service resource of spans and dropped span stats before the
+// span aggregation key is built, see WithNormalizedSpanResources. This
+// allows, for example, collapsing per-shard hostnames into a cluster name
+// to limit the cardinality of span groups. Defaults to nil, i.e. resources
+// are used as is.
+func WithSpanResourceNormalizer(normalize func(string) string) Option {
+	return func(c Config) Config {
+		c.SpanResourceNormalizer = normalize
+		return c
+	}
+}
+
+// WithSpanSubtypeGroups configures the aggregator to include the span
+// subtype in the span aggregation key, see WithSpanSubtypeKey. This breaks
+// down service destination metrics per span subtype. Defaults to false.
+func WithSpanSubtypeGroups(enabled bool) Option {
+	return func(c Config) Config {
+		c.SpanSubtypeGroups = enabled
+		return c
+	}
+}
+
+// WithServiceNameAliases configures a mapping from reported service names
+// to the canonical service name they are aggregated under, see
+// WithCanonicalServiceNames. This allows merging the metrics of services
+// reported under multiple names, for example while a service is being
+// renamed. Service names without an alias are used as is.
+func WithServiceNameAliases(aliases map[string]string) Option {
+	return func(c Config) Config {
+		c.ServiceNameAliases = aliases
+		return c
+	}
+}
+
+// WithInstanceDimensions configures additional dimensions of the service
+// instance aggregation key, see WithServiceInstanceDimensions. This allows
+// breaking down service metrics per host, container or pod. The number of
+// service instance groups is limited by Limits.MaxServiceInstanceGroups
+// and Limits.MaxServiceInstanceGroupsPerService. By default, service
+// instances are only identified by their global labels.
+func WithInstanceDimensions(dims ...InstanceDimension) Option {
+	return func(c Config) Config {
+		c.InstanceDimensions = dims
+		return c
+	}
+}
+
+// WithCollapsedInstances configures the aggregator to aggregate the events
+// of a service into a single service instance, see
+// WithCollapsedServiceInstances. This removes the service instance keying
+// for consumers not querying per service instance metrics, reducing the
+// key cardinality and storage. Combined metrics aggregated explicitly are
+// aggregated as is. Collapsed instances are not supported with instance
+// dimensions. Defaults to false.
+func WithCollapsedInstances(enabled bool) Option {
+	return func(c Config) Config {
+		c.CollapseInstances = enabled
+		return c
+	}
+}
+
+// WithFaasGroups configures the aggregator to include the FaaS dimensions
+// in the transaction aggregation key, see WithFaasDimensions. The number of
+// FaaS IDs per service is limited by Limits.MaxFaasIDsPerService. Defaults
+// to true.
+func WithFaasGroups(enabled bool) Option {
+	return func(c Config) Config {
+		c.FaasGroups = enabled
+		return c
+	}
+}
+
+// WithMobileGroups configures the aggregator to include the OS and network
+// dimensions of mobile and RUM transactions in the transaction aggregation
+// key, see WithMobileDimensions. Defaults to false.
+func WithMobileGroups(enabled bool) Option {
+	return func(c Config) Config {
+		c.MobileGroups = enabled
+		return c
+	}
+}
+
+// WithKubernetesGroups configures the aggregator to include the Kubernetes
+// namespace and deployment in the service aggregation key, see
+// WithKubernetesDimensions. Defaults to false.
+func WithKubernetesGroups(enabled bool) Option {
+	return func(c Config) Config {
+		c.KubernetesGroups = enabled
+		return c
+	}
+}
+
+// WithDurationSumEstimate configures how the sum of the transaction
+// duration summary is derived, see WithDurationSummarySum. Estimating the
+// sum from the histogram buckets introduces a bias for coarse buckets,
+// recording the exact sum avoids it at the cost of a slightly bigger
+// aggregated state. Defaults to UpperBoundSumEstimate.
+func WithDurationSumEstimate(estimate DurationSumEstimate) Option {
+	return func(c Config) Config {
+		c.DurationSumEstimate = estimate
+		return c
+	}
+}
+
+// WithRecordedDurationExtremes configures whether the exact minimum and
+// maximum transaction durations are recorded and harvested, see
+// WithDurationExtremes. Recording them grows the aggregated state of
+// every transaction and service transaction group. Defaults to false.
+func WithRecordedDurationExtremes(enabled bool) Option {
+	return func(c Config) Config {
+		c.DurationExtremes = enabled
+		return c
+	}
+}
+
+// WithHistogramImpl configures the data structure used for recording the
+// duration distribution of transaction and service transaction metrics.
+// Metrics recorded with different implementations are merged into a
+// single distribution when harvested. Defaults to HDRHistogramImpl.
+func WithHistogramImpl(impl HistogramImpl) Option {
+	return func(c Config) Config {
+		c.HistogramImpl = impl
+		return c
+	}
+}
+
+// WithHistogramPrecision configures the precision of the HDR histograms
+// recording the duration distribution of transaction and service
+// transaction metrics: the number of significant figures, between 1 and
+// 5, and the maximum recorded duration, durations above the maximum being
+// recorded as the maximum. Fewer significant figures and a lower maximum
+// reduce the size of the histograms stored in every key at the cost of
+// accuracy, e.g. for coarse aggregation intervals. Histograms recorded
+// with a previous precision are merged with the configured precision.
+// Defaults to 2 significant figures and a maximum of 1 hour.
+func WithHistogramPrecision(significantFigures int, maxDuration time.Duration) Option {
+	return func(c Config) Config {
+		c.HistogramSignificantFigures = significantFigures
+		c.HistogramMaxDuration = maxDuration
+		return c
+	}
+}
+
+// WithSparseHistograms configures the stored combined metrics values to
+// encode their histograms sparsely, as pairs of varint encoded bucket
+// deltas and counts, which is much smaller for the mostly empty histograms
+// of low traffic groups. Values with sparse histograms are decoded as
+// empty histograms by versions of the library predating the sparse
+// encoding, sparse histograms must therefore only be enabled once all the
+// aggregators reading the data directory, e.g. during a rolling upgrade,
+// decode them. Values are decoded from either encoding regardless of the
+// setting. Defaults to false, i.e. histograms are encoded densely.
+func WithSparseHistograms(enabled bool) Option {
+	return func(c Config) Config {
+		c.SparseHistograms = enabled
+		return c
+	}
+}
+
+// WithHarvestLoopRestarts configures the supervision of the harvest loop
+// started by Run. If a harvest crashes, the harvest loop is restarted after
+// waiting for the given backoff, retrying the crashed harvest. The backoff
+// is doubled for every consecutive crash, capped at the lowest aggregation
+// interval. Run returns an error once the harvest loop crashes more than
+// maxConsecutiveRestarts times without a successful harvest in between.
+// Defaults to no restarts, in which case a harvest panic is not recovered
+// and crashes the process.
+func WithHarvestLoopRestarts(maxConsecutiveRestarts int, backoff time.Duration) Option {
+	return func(c Config) Config {
+		c.MaxHarvestLoopRestarts = maxConsecutiveRestarts
+		c.HarvestLoopRestartBackoff = backoff
+		return c
+	}
+}
+
 func defaultCfg() Config {
 	return Config{
 		DataDir:                "/tmp",
@@ -190,21 +1173,116 @@ func defaultCfg() Config {
 		AggregationIntervals:   []time.Duration{time.Minute},
 		Meter:                  otel.Meter(instrumentationName),
 		Tracer:                 otel.Tracer(instrumentationName),
+		Clock:                  realClock{},
 		CombinedMetricsIDToKVs: func(_ [16]byte) []attribute.KeyValue { return nil },
 		Logger:                 zap.Must(zap.NewDevelopment()),
+
+		HarvestLoopRestartBackoff:   time.Second,
+		FlushBytes:                  dbCommitThresholdBytes,
+		HistogramSignificantFigures: 2,
+		HistogramMaxDuration:        time.Hour,
+		FaasGroups:                  true,
+	}
+}
+
+// validateLimits returns an error if any of the limits is negative.
+func validateLimits(l Limits) error {
+	for _, limit := range []struct {
+		name  string
+		value int
+	}{
+		{"MaxServices", l.MaxServices},
+		{"MaxServiceInstanceGroupsPerService", l.MaxServiceInstanceGroupsPerService},
+		{"MaxServiceInstanceGroups", l.MaxServiceInstanceGroups},
+		{"MaxGlobalLabelsPerService", l.MaxGlobalLabelsPerService},
+		{"MaxKubernetesWorkloadsPerService", l.MaxKubernetesWorkloadsPerService},
+		{"MaxSpanGroups", l.MaxSpanGroups},
+		{"MaxSpanGroupsPerService", l.MaxSpanGroupsPerService},
+		{"MaxSpanNamePerDestination", l.MaxSpanNamePerDestination},
+		{"MaxTransactionGroups", l.MaxTransactionGroups},
+		{"MaxTransactionGroupsPerService", l.MaxTransactionGroupsPerService},
+		{"MaxFaasIDsPerService", l.MaxFaasIDsPerService},
+		{"MaxServiceVersionsPerService", l.MaxServiceVersionsPerService},
+		{"MaxHostOSVersionsPerService", l.MaxHostOSVersionsPerService},
+		{"MaxServiceTransactionGroups", l.MaxServiceTransactionGroups},
+		{"MaxServiceTransactionGroupsPerService", l.MaxServiceTransactionGroupsPerService},
+		{"MaxErrorGroups", l.MaxErrorGroups},
+		{"MaxErrorGroupsPerService", l.MaxErrorGroupsPerService},
+		{"MaxServiceGraphEdges", l.MaxServiceGraphEdges},
+		{"MaxServiceGraphEdgesPerService", l.MaxServiceGraphEdgesPerService},
+	} {
+		if limit.value < 0 {
+			return fmt.Errorf("limit %s must not be negative", limit.name)
+		}
 	}
+	return nil
 }
 
 func validateCfg(cfg Config) error {
 	if cfg.DataDir == "" {
 		return errors.New("data directory is required")
 	}
+	dataDirs := map[string]struct{}{filepath.Clean(cfg.DataDir): {}}
+	for _, dir := range cfg.ShardDataDirs {
+		if dir == "" {
+			return errors.New("shard data directories must not be empty")
+		}
+		if _, ok := dataDirs[filepath.Clean(dir)]; ok {
+			return fmt.Errorf("shard data directory %q is already in use", dir)
+		}
+		dataDirs[filepath.Clean(dir)] = struct{}{}
+	}
+	if cfg.ColdTierIdleIntervals < 0 {
+		return errors.New("cold tier idle intervals must not be negative")
+	}
+	if cfg.ColdTierIdleIntervals > 0 {
+		coldDir := filepath.Join(cfg.DataDir, coldTierDir)
+		if _, ok := dataDirs[coldDir]; ok {
+			return fmt.Errorf("shard data directory %q is in use by the cold tier", coldDir)
+		}
+	}
 	if cfg.Processor == nil {
 		return errors.New("processor is required")
 	}
+	if cfg.Clock == nil {
+		return errors.New("clock is required")
+	}
+	sinkNames := make(map[string]struct{}, len(cfg.Sinks))
+	for _, sink := range cfg.Sinks {
+		if sink.Name == "" {
+			return errors.New("sink name must not be empty")
+		}
+		if _, ok := sinkNames[sink.Name]; ok {
+			return fmt.Errorf("sink %q is already configured", sink.Name)
+		}
+		sinkNames[sink.Name] = struct{}{}
+		if sink.Processor == nil {
+			return fmt.Errorf("sink %q processor is required", sink.Name)
+		}
+		if sink.MaxRetries < 0 {
+			return fmt.Errorf("sink %q max retries must not be negative", sink.Name)
+		}
+		if sink.RetryBackoff < 0 {
+			return fmt.Errorf("sink %q retry backoff must not be negative", sink.Name)
+		}
+		if sink.QueueSize < 0 {
+			return fmt.Errorf("sink %q queue size must not be negative", sink.Name)
+		}
+	}
 	if cfg.Partitions == 0 {
 		return errors.New("partitions must be greater than zero")
 	}
+	if cfg.MaxAdaptivePartitions > 0 {
+		if cfg.MaxAdaptivePartitions < cfg.Partitions {
+			return errors.New("max adaptive partitions must not be less than partitions")
+		}
+		if cfg.EventsPerPartition <= 0 {
+			return errors.New("events per partition must be greater than zero")
+		}
+	}
+	if err := validateLimits(cfg.Limits); err != nil {
+		return err
+	}
 	if len(cfg.AggregationIntervals) == 0 {
 		return errors.New("at least one aggregation interval is required")
 	}
@@ -231,6 +1309,227 @@ func validateCfg(cfg Config) error {
 	if highest > 18*time.Hour {
 		return errors.New("aggregation interval greater than 18 hours is not supported")
 	}
+	if cfg.HarvestJitter < 0 {
+		return errors.New("harvest jitter must not be negative")
+	}
+	if cfg.HarvestJitter >= lowest {
+		return errors.New("harvest jitter must be less than the lowest aggregation interval")
+	}
+	for ivl, offset := range cfg.HarvestOffsets {
+		i := sort.Search(len(cfg.AggregationIntervals), func(i int) bool {
+			return cfg.AggregationIntervals[i] >= ivl
+		})
+		if i == len(cfg.AggregationIntervals) || cfg.AggregationIntervals[i] != ivl {
+			return fmt.Errorf("harvest offset configured for unknown aggregation interval %s", ivl)
+		}
+		if offset < 0 {
+			return fmt.Errorf("harvest offset for aggregation interval %s must not be negative", ivl)
+		}
+		if offset >= ivl {
+			return fmt.Errorf(
+				"harvest offset for aggregation interval %s must be less than the aggregation interval", ivl,
+			)
+		}
+	}
+	if cfg.HarvestConcurrency < 0 {
+		return errors.New("harvest concurrency must not be negative")
+	}
+	if cfg.HarvestChunkServices < 0 {
+		return errors.New("harvest chunk services must not be negative")
+	}
+	if cfg.LateEventPolicy > RouteLateEvents {
+		return fmt.Errorf("unsupported late event policy %d", cfg.LateEventPolicy)
+	}
+	if cfg.AllowedLateness < 0 {
+		return errors.New("allowed lateness must not be negative")
+	}
+	if cfg.LateEventPolicy == ReopenLateEvents {
+		if cfg.AllowedLateness == 0 {
+			return errors.New("reopening late events requires a positive allowed lateness")
+		}
+		if cfg.IntervalRollups {
+			return errors.New("reopening late events is not supported with interval rollups")
+		}
+	}
+	if cfg.Backfill && !cfg.EventTimeBucketing {
+		return errors.New("backfill requires event time bucketing")
+	}
+	if cfg.EventTimeBucketing {
+		if cfg.LateEventPolicy != AcceptLateEvents {
+			return errors.New("event time bucketing is not supported with a late event policy")
+		}
+		if cfg.IntervalRollups {
+			return errors.New("event time bucketing is not supported with interval rollups")
+		}
+		if cfg.MaxRetention > 0 && cfg.MaxRetention <= highest+cfg.AllowedLateness {
+			return errors.New("max retention must be greater than the highest aggregation interval plus the allowed lateness with event time bucketing")
+		}
+	}
+	if cfg.MergeBudget < 0 {
+		return errors.New("merge budget must not be negative")
+	}
+	if cfg.MergeBudget > 0 && (cfg.MergeBudget < time.Microsecond || cfg.MergeBudget > time.Second) {
+		return errors.New("merge budget must be between a microsecond and a second")
+	}
+	if cfg.MaxRetention < 0 {
+		return errors.New("max retention must not be negative")
+	}
+	if cfg.MaxRetention > 0 && cfg.MaxRetention <= highest {
+		return errors.New("max retention must be greater than the highest aggregation interval")
+	}
+	if cfg.IntervalRollups {
+		for _, ivl := range cfg.AggregationIntervals[1:] {
+			if cfg.HarvestOffsets[ivl] < cfg.HarvestOffsets[lowest] {
+				return fmt.Errorf(
+					"harvest offset for aggregation interval %s must not be less than the offset of the lowest aggregation interval with interval rollups", ivl,
+				)
+			}
+		}
+	}
+	for alias, name := range cfg.ServiceNameAliases {
+		if name == "" {
+			return fmt.Errorf("service name alias for %q must not be empty", alias)
+		}
+	}
+	if cfg.MaxPendingBytes < 0 {
+		return errors.New("max pending bytes must not be negative")
+	}
+	if cfg.FlushInterval < 0 {
+		return errors.New("flush interval must not be negative")
+	}
+	if cfg.FlushBytes <= 0 {
+		return errors.New("flush bytes must be greater than 0")
+	}
+	if cfg.MaxCombinedMetricsSize < 0 {
+		return errors.New("max combined metrics size must not be negative")
+	}
+	if cfg.ReplayHorizon < 0 {
+		return errors.New("replay horizon must not be negative")
+	}
+	if cfg.MaxExemplars < 0 {
+		return errors.New("max exemplars must not be negative")
+	}
+	if cfg.GlobalLabelsHashThreshold < 0 {
+		return errors.New("global labels hash threshold must not be negative")
+	}
+	for _, keys := range [][]string{cfg.GlobalLabelsAllowlist, cfg.GlobalLabelsDenylist} {
+		for _, k := range keys {
+			if k == "" {
+				return errors.New("global label filter keys must not be empty")
+			}
+		}
+	}
+	if cfg.HistogramImpl > DDSketchImpl {
+		return fmt.Errorf("unsupported histogram implementation %d", cfg.HistogramImpl)
+	}
+	if cfg.HistogramSignificantFigures < hdrhistogram.MinSignificantFigures ||
+		cfg.HistogramSignificantFigures > hdrhistogram.MaxSignificantFigures {
+		return fmt.Errorf(
+			"histogram significant figures must be between %d and %d",
+			hdrhistogram.MinSignificantFigures, hdrhistogram.MaxSignificantFigures,
+		)
+	}
+	if cfg.HistogramMaxDuration < time.Millisecond {
+		return errors.New("histogram max duration must be at least 1ms")
+	}
+	if cfg.DurationSumEstimate > RecordedSumEstimate {
+		return fmt.Errorf("unsupported duration sum estimate %d", cfg.DurationSumEstimate)
+	}
+	for _, dim := range cfg.InstanceDimensions {
+		if dim < HostNameDimension || dim > KubernetesPodNameDimension {
+			return fmt.Errorf("unsupported instance dimension %d", dim)
+		}
+	}
+	if cfg.CollapseInstances && len(cfg.InstanceDimensions) > 0 {
+		return errors.New("instance dimensions are not supported with collapsed instances")
+	}
+	if cfg.DictionarySamples < 0 {
+		return errors.New("dictionary samples must not be negative")
+	}
+	if cfg.ValueCompressionCodec > ZstdCompression {
+		return fmt.Errorf("unsupported value compression %d", cfg.ValueCompressionCodec)
+	}
+	if cfg.ValueCompressionLevel < 0 {
+		return errors.New("value compression level must not be negative")
+	}
+	if cfg.ValueCompressionLevel > 0 && cfg.valueCompression() != ZstdCompression {
+		return errors.New("value compression level is only supported for zstd compression")
+	}
+	if cfg.ValueCompressionLevel > 22 {
+		return errors.New("zstd value compression level must not exceed 22")
+	}
+	if cfg.DictionarySamples > 0 && cfg.valueCompression() == NoCompression {
+		return errors.New("dictionary training requires value compression")
+	}
+	if cfg.DictionarySamples > 0 && cfg.valueCompression() != ZstdCompression {
+		return errors.New("dictionary training requires zstd value compression")
+	}
+	if err := validatePebbleOptions(cfg.PebbleOptions); err != nil {
+		return err
+	}
+	if cfg.MaxHarvestLoopRestarts < 0 {
+		return errors.New("max harvest loop restarts must not be negative")
+	}
+	if cfg.HarvestLoopRestartBackoff <= 0 {
+		return errors.New("harvest loop restart backoff must be greater than zero")
+	}
+	if cfg.LogSamplingInterval < 0 {
+		return errors.New("log sampling interval must not be negative")
+	}
+	if cfg.LogSamplingInterval > 0 {
+		if cfg.LogSamplingFirst <= 0 {
+			return errors.New("log sampling first must be greater than zero")
+		}
+		if cfg.LogSamplingThereafter < 0 {
+			return errors.New("log sampling thereafter must not be negative")
+		}
+	}
+	return nil
+}
+
+// valueCompression returns the compression of the stored combined metrics
+// values.
+func (c Config) valueCompression() Compression {
+	switch {
+	case c.ValueCompressionCodec != DefaultCompression:
+		return c.ValueCompressionCodec
+	case c.ValueCompression:
+		return ZstdCompression
+	}
+	return NoCompression
+}
+
+func validatePebbleOptions(opts PebbleOptions) error {
+	if opts.CacheSize < 0 {
+		return errors.New("pebble cache size must not be negative")
+	}
+	if opts.MemTableSize < 0 {
+		return errors.New("pebble memtable size must not be negative")
+	}
+	if opts.MemTableStopWritesThreshold < 0 {
+		return errors.New("pebble memtable stop writes threshold must not be negative")
+	}
+	if opts.MaxConcurrentCompactions < 0 {
+		return errors.New("pebble max concurrent compactions must not be negative")
+	}
+	if opts.L0CompactionThreshold < 0 {
+		return errors.New("pebble L0 compaction threshold must not be negative")
+	}
+	if opts.L0StopWritesThreshold < 0 {
+		return errors.New("pebble L0 stop writes threshold must not be negative")
+	}
+	if opts.L0CompactionThreshold > 0 && opts.L0StopWritesThreshold > 0 &&
+		opts.L0StopWritesThreshold < opts.L0CompactionThreshold {
+		return errors.New("pebble L0 stop writes threshold must not be less than the L0 compaction threshold")
+	}
+	if len(opts.LevelCompression) > maxPebbleLevels {
+		return fmt.Errorf("pebble level compression must not exceed %d levels", maxPebbleLevels)
+	}
+	for _, c := range opts.LevelCompression {
+		if c > ZstdCompression {
+			return fmt.Errorf("unsupported compression %d", c)
+		}
+	}
 	return nil
 }
 
@@ -0,0 +1,190 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+// Package config loads the aggregator configuration from YAML or JSON, so
+// that embedders of the aggregator and the apmaggr tool share a single
+// configuration format.
+//
+// Environment variables referenced as ${VAR} or $VAR are substituted before
+// parsing, ${VAR:-default} substitutes default if VAR is unset or empty. A
+// literal $ is written as $$. Durations are written as Go durations, e.g.
+// `1m` or `30s`. Unset fields keep the aggregator defaults.
+//
+//	data_dir: ${DATA_DIR:-/var/lib/aggregator}
+//	aggregation_intervals: [1m, 10m, 60m]
+//	harvest_delay: 5s
+//	limits:
+//	  max_services: 1000
+//	pebble:
+//	  mem_table_size: 67108864
+//	  level_compression: [none, snappy, zstd]
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/elastic/apm-aggregation/aggregators"
+)
+
+// Config is the file representation of the aggregator configuration.
+type Config struct {
+	DataDir              string          `yaml:"data_dir"`
+	ShardDataDirs        []string        `yaml:"shard_data_dirs"`
+	Partitions           uint16          `yaml:"partitions"`
+	AggregationIntervals []time.Duration `yaml:"aggregation_intervals"`
+	HarvestDelay         time.Duration   `yaml:"harvest_delay"`
+	FaasGroups           *bool           `yaml:"faas_groups"`
+	MobileGroups         bool            `yaml:"mobile_groups"`
+	KubernetesGroups     bool            `yaml:"kubernetes_groups"`
+	Limits               Limits          `yaml:"limits"`
+	Pebble               Pebble          `yaml:"pebble"`
+}
+
+// Limits is the file representation of aggregators.Limits.
+type Limits struct {
+	MaxServices                           int `yaml:"max_services"`
+	MaxServiceInstanceGroupsPerService    int `yaml:"max_service_instance_groups_per_service"`
+	MaxServiceInstanceGroups              int `yaml:"max_service_instance_groups"`
+	MaxGlobalLabelsPerService             int `yaml:"max_global_labels_per_service"`
+	MaxKubernetesWorkloadsPerService      int `yaml:"max_kubernetes_workloads_per_service"`
+	MaxSpanGroups                         int `yaml:"max_span_groups"`
+	MaxSpanGroupsPerService               int `yaml:"max_span_groups_per_service"`
+	MaxSpanNamePerDestination             int `yaml:"max_span_name_per_destination"`
+	MaxTransactionGroups                  int `yaml:"max_transaction_groups"`
+	MaxTransactionGroupsPerService        int `yaml:"max_transaction_groups_per_service"`
+	MaxFaasIDsPerService                  int `yaml:"max_faas_ids_per_service"`
+	MaxServiceVersionsPerService          int `yaml:"max_service_versions_per_service"`
+	MaxHostOSVersionsPerService           int `yaml:"max_host_os_versions_per_service"`
+	MaxServiceTransactionGroups           int `yaml:"max_service_transaction_groups"`
+	MaxServiceTransactionGroupsPerService int `yaml:"max_service_transaction_groups_per_service"`
+	MaxErrorGroups                        int `yaml:"max_error_groups"`
+	MaxErrorGroupsPerService              int `yaml:"max_error_groups_per_service"`
+	MaxServiceGraphEdges                  int `yaml:"max_service_graph_edges"`
+	MaxServiceGraphEdgesPerService        int `yaml:"max_service_graph_edges_per_service"`
+}
+
+// Pebble is the file representation of aggregators.PebbleOptions. The
+// level compression is configured by name, one of `default`, `none`,
+// `snappy` or `zstd`.
+type Pebble struct {
+	CacheSize                   int64    `yaml:"cache_size"`
+	MemTableSize                int      `yaml:"mem_table_size"`
+	MemTableStopWritesThreshold int      `yaml:"mem_table_stop_writes_threshold"`
+	MaxConcurrentCompactions    int      `yaml:"max_concurrent_compactions"`
+	L0CompactionThreshold       int      `yaml:"l0_compaction_threshold"`
+	L0StopWritesThreshold       int      `yaml:"l0_stop_writes_threshold"`
+	LevelCompression            []string `yaml:"level_compression"`
+}
+
+var compressions = map[string]aggregators.Compression{
+	"default": aggregators.DefaultCompression,
+	"none":    aggregators.NoCompression,
+	"snappy":  aggregators.SnappyCompression,
+	"zstd":    aggregators.ZstdCompression,
+}
+
+// Load reads and parses the configuration file at the given path.
+func Load(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read config file: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse parses the YAML or JSON configuration, substituting the
+// environment variables. Unknown fields are rejected.
+func Parse(data []byte) (Config, error) {
+	expanded := os.Expand(string(data), expandEnv)
+	dec := yaml.NewDecoder(strings.NewReader(expanded))
+	dec.KnownFields(true)
+	var cfg Config
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return Config{}, fmt.Errorf("failed to parse config: %w", err)
+	}
+	if _, err := cfg.pebbleOptions(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// expandEnv returns the value of the environment variable, or the default
+// of a ${VAR:-default} reference if the variable is unset or empty.
+func expandEnv(name string) string {
+	if name == "$" {
+		return "$"
+	}
+	name, def, hasDefault := strings.Cut(name, ":-")
+	if v := os.Getenv(name); v != "" || !hasDefault {
+		return v
+	}
+	return def
+}
+
+// Options returns the aggregator options for the configured fields. The
+// options are validated when creating the aggregator.
+func (c Config) Options() []aggregators.Option {
+	var opts []aggregators.Option
+	if c.DataDir != "" {
+		opts = append(opts, aggregators.WithDataDir(c.DataDir))
+	}
+	if len(c.ShardDataDirs) > 0 {
+		opts = append(opts, aggregators.WithShardDataDirs(c.ShardDataDirs))
+	}
+	if c.Partitions > 0 {
+		opts = append(opts, aggregators.WithPartitions(c.Partitions))
+	}
+	if len(c.AggregationIntervals) > 0 {
+		opts = append(opts, aggregators.WithAggregationIntervals(c.AggregationIntervals))
+	}
+	if c.HarvestDelay > 0 {
+		opts = append(opts, aggregators.WithHarvestDelay(c.HarvestDelay))
+	}
+	// The FaaS groups are enabled by default, so they are only configured
+	// if set explicitly.
+	if c.FaasGroups != nil {
+		opts = append(opts, aggregators.WithFaasGroups(*c.FaasGroups))
+	}
+	if c.MobileGroups {
+		opts = append(opts, aggregators.WithMobileGroups(true))
+	}
+	if c.KubernetesGroups {
+		opts = append(opts, aggregators.WithKubernetesGroups(true))
+	}
+	if c.Limits != (Limits{}) {
+		opts = append(opts, aggregators.WithLimits(aggregators.Limits(c.Limits)))
+	}
+	// The pebble options are validated by Parse.
+	if pebbleOpts, _ := c.pebbleOptions(); !reflect.DeepEqual(pebbleOpts, aggregators.PebbleOptions{}) {
+		opts = append(opts, aggregators.WithPebbleOptions(pebbleOpts))
+	}
+	return opts
+}
+
+func (c Config) pebbleOptions() (aggregators.PebbleOptions, error) {
+	opts := aggregators.PebbleOptions{
+		CacheSize:                   c.Pebble.CacheSize,
+		MemTableSize:                c.Pebble.MemTableSize,
+		MemTableStopWritesThreshold: c.Pebble.MemTableStopWritesThreshold,
+		MaxConcurrentCompactions:    c.Pebble.MaxConcurrentCompactions,
+		L0CompactionThreshold:       c.Pebble.L0CompactionThreshold,
+		L0StopWritesThreshold:       c.Pebble.L0StopWritesThreshold,
+	}
+	for _, name := range c.Pebble.LevelCompression {
+		compression, ok := compressions[name]
+		if !ok {
+			return opts, fmt.Errorf("unknown pebble level compression %q", name)
+		}
+		opts.LevelCompression = append(opts.LevelCompression, compression)
+	}
+	return opts, nil
+}
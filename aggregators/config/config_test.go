@@ -0,0 +1,140 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/apm-aggregation/aggregators"
+)
+
+func TestParse(t *testing.T) {
+	t.Setenv("AGGR_DATA_DIR", "/data")
+	t.Setenv("AGGR_EMPTY", "")
+	expected := Config{
+		DataDir:              "/data",
+		ShardDataDirs:        []string{"/shard", "/default$"},
+		AggregationIntervals: []time.Duration{time.Minute, 10 * time.Minute},
+		HarvestDelay:         5 * time.Second,
+		Limits: Limits{
+			MaxServices:                    100,
+			MaxTransactionGroupsPerService: 10,
+		},
+		Pebble: Pebble{
+			MemTableSize:     1 << 20,
+			LevelCompression: []string{"none", "zstd"},
+		},
+	}
+	for _, tc := range []struct {
+		name string
+		data string
+	}{
+		{
+			name: "yaml",
+			data: `
+data_dir: ${AGGR_DATA_DIR}
+shard_data_dirs: [/shard, "${AGGR_EMPTY:-/default}$$"]
+aggregation_intervals: [1m, 10m]
+harvest_delay: 5s
+limits:
+  max_services: 100
+  max_transaction_groups_per_service: 10
+pebble:
+  mem_table_size: 1048576
+  level_compression: [none, zstd]
+`,
+		},
+		{
+			name: "json",
+			data: `{
+  "data_dir": "$AGGR_DATA_DIR",
+  "shard_data_dirs": ["/shard", "${AGGR_UNSET:-/default}$$"],
+  "aggregation_intervals": ["1m", "10m"],
+  "harvest_delay": "5s",
+  "limits": {"max_services": 100, "max_transaction_groups_per_service": 10},
+  "pebble": {"mem_table_size": 1048576, "level_compression": ["none", "zstd"]}
+}`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, err := Parse([]byte(tc.data))
+			require.NoError(t, err)
+			assert.Equal(t, expected, cfg)
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		data     string
+		expected string
+	}{
+		{
+			name:     "unknown_field",
+			data:     "unknown: 1",
+			expected: "failed to parse config: yaml: unmarshal errors:\n  line 1: field unknown not found in type config.Config",
+		},
+		{
+			name:     "invalid_duration",
+			data:     "harvest_delay: soon",
+			expected: "failed to parse config: yaml: unmarshal errors:\n  line 1: cannot unmarshal !!str `soon` into time.Duration",
+		},
+		{
+			name:     "unknown_level_compression",
+			data:     "pebble: {level_compression: [lz4]}",
+			expected: `unknown pebble level compression "lz4"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse([]byte(tc.data))
+			assert.EqualError(t, err, tc.expected)
+		})
+	}
+}
+
+func TestOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+data_dir: /data
+aggregation_intervals: [1m, 10m]
+harvest_delay: 5s
+faas_groups: false
+kubernetes_groups: true
+limits:
+  max_services: 100
+pebble:
+  level_compression: [snappy]
+`), 0o600))
+	cfg, err := Load(path)
+	require.NoError(t, err)
+
+	actual, err := aggregators.NewConfig(cfg.Options()...)
+	require.NoError(t, err)
+	assert.Equal(t, "/data", actual.DataDir)
+	assert.Equal(t, []time.Duration{time.Minute, 10 * time.Minute}, actual.AggregationIntervals)
+	assert.Equal(t, 5*time.Second, actual.HarvestDelay)
+	assert.False(t, actual.FaasGroups)
+	assert.False(t, actual.MobileGroups)
+	assert.True(t, actual.KubernetesGroups)
+	assert.Equal(t, aggregators.Limits{MaxServices: 100}, actual.Limits)
+	assert.Equal(t, aggregators.PebbleOptions{
+		LevelCompression: []aggregators.Compression{aggregators.SnappyCompression},
+	}, actual.PebbleOptions)
+	// Unset fields keep the defaults.
+	assert.Equal(t, uint16(1), actual.Partitions)
+	defaults, err := aggregators.NewConfig(Config{}.Options()...)
+	require.NoError(t, err)
+	assert.True(t, defaults.FaasGroups)
+
+	// An empty config keeps all the defaults.
+	assert.Empty(t, Config{}.Options())
+}
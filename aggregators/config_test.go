@@ -5,12 +5,17 @@
 package aggregators
 
 import (
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/trace"
+	"golang.org/x/time/rate"
+
+	"github.com/elastic/apm-data/model/modelpb"
 )
 
 func TestNewConfig(t *testing.T) {
@@ -93,97 +98,1137 @@ func TestNewConfig(t *testing.T) {
 			},
 		},
 		{
-			name: "with_meter",
+			name: "with_harvest_jitter",
 			opts: []Option{
-				WithMeter(customMeter),
+				WithHarvestJitter(10 * time.Second),
 			},
 			expected: func() Config {
 				cfg := defaultCfg
-				cfg.Meter = customMeter
+				cfg.HarvestJitter = 10 * time.Second
 				return cfg
 			},
 		},
 		{
-			name: "with_tracer",
+			name: "with_harvest_concurrency",
 			opts: []Option{
-				WithTracer(customTracer),
+				WithHarvestConcurrency(4),
 			},
 			expected: func() Config {
 				cfg := defaultCfg
-				cfg.Tracer = customTracer
+				cfg.HarvestConcurrency = 4
 				return cfg
 			},
 		},
 		{
-			name: "with_empty_data_dir",
+			name: "with_shard_data_dirs",
 			opts: []Option{
-				WithDataDir(""),
+				WithShardDataDirs([]string{"/tmp/shard-1", "/tmp/shard-2"}),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.ShardDataDirs = []string{"/tmp/shard-1", "/tmp/shard-2"}
+				return cfg
 			},
-			expectedErrorMsg: "data directory is required",
 		},
 		{
-			name: "with_nil_processor",
+			name: "with_write_coalescing",
 			opts: []Option{
-				WithProcessor(nil),
+				WithWriteCoalescing(10*time.Millisecond, 1<<20),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.FlushInterval = 10 * time.Millisecond
+				cfg.FlushBytes = 1 << 20
+				return cfg
 			},
-			expectedErrorMsg: "processor is required",
 		},
 		{
-			name: "with_no_aggregation_interval",
+			name: "with_harvest_chunking",
 			opts: []Option{
-				WithAggregationIntervals(nil),
+				WithHarvestChunking(100),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.HarvestChunkServices = 100
+				return cfg
 			},
-			expectedErrorMsg: "at least one aggregation interval is required",
 		},
 		{
-			name: "with_unsorted_aggregation_intervals",
+			name: "with_merge_budget",
 			opts: []Option{
-				WithAggregationIntervals([]time.Duration{time.Hour, time.Minute}),
+				WithMergeBudget(time.Millisecond),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.MergeBudget = time.Millisecond
+				return cfg
 			},
-			expectedErrorMsg: "aggregation intervals must be in ascending order",
 		},
 		{
-			name: "with_invalid_aggregation_intervals",
+			name: "with_late_event_policy",
 			opts: []Option{
-				WithAggregationIntervals([]time.Duration{10 * time.Second, 15 * time.Second}),
+				WithLateEventPolicy(ReopenLateEvents),
+				WithAllowedLateness(time.Minute),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.LateEventPolicy = ReopenLateEvents
+				cfg.AllowedLateness = time.Minute
+				return cfg
 			},
-			expectedErrorMsg: "aggregation intervals must be a factor of lowest interval",
 		},
 		{
-			name: "with_out_of_lower_range_aggregation_interval",
+			name: "with_event_time_bucketing",
 			opts: []Option{
-				WithAggregationIntervals([]time.Duration{time.Millisecond}),
+				WithEventTimeBucketing(true),
+				WithAllowedLateness(time.Minute),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.EventTimeBucketing = true
+				cfg.AllowedLateness = time.Minute
+				return cfg
 			},
-			expectedErrorMsg: "aggregation interval less than one second is not supported",
 		},
 		{
-			name: "with_out_of_upper_range_aggregation_interval",
+			name: "with_backfill",
 			opts: []Option{
-				WithAggregationIntervals([]time.Duration{20 * time.Hour}),
+				WithEventTimeBucketing(true),
+				WithBackfill(true),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.EventTimeBucketing = true
+				cfg.Backfill = true
+				return cfg
 			},
-			expectedErrorMsg: "aggregation interval greater than 18 hours is not supported",
 		},
-	} {
-		actual, err := NewConfig(tc.opts...)
-
-		if tc.expectedErrorMsg != "" {
-			assert.EqualError(t, err, tc.expectedErrorMsg)
-			continue
-		}
-
-		expected := tc.expected()
-		assert.NoError(t, err)
-
-		// New logger is created for every call
-		assert.NotNil(t, actual.Logger)
-		actual.Logger, expected.Logger = nil, nil
-
-		// Function values are not comparable
-		assert.NotNil(t, actual.CombinedMetricsIDToKVs)
-		actual.CombinedMetricsIDToKVs, expected.CombinedMetricsIDToKVs = nil, nil
-		assert.NotNil(t, actual.Processor)
-		actual.Processor, expected.Processor = nil, nil
-
-		assert.Equal(t, expected, actual)
+		{
+			name: "with_cold_tier",
+			opts: []Option{
+				WithColdTier(3),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.ColdTierIdleIntervals = 3
+				return cfg
+			},
+		},
+		{
+			name: "with_max_retention",
+			opts: []Option{
+				WithMaxRetention(time.Hour),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.MaxRetention = time.Hour
+				return cfg
+			},
+		},
+		{
+			name: "with_pebble_options",
+			opts: []Option{
+				WithPebbleOptions(PebbleOptions{
+					CacheSize:        64 << 20,
+					MemTableSize:     32 << 20,
+					LevelCompression: []Compression{NoCompression, ZstdCompression},
+				}),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.PebbleOptions = PebbleOptions{
+					CacheSize:        64 << 20,
+					MemTableSize:     32 << 20,
+					LevelCompression: []Compression{NoCompression, ZstdCompression},
+				}
+				return cfg
+			},
+		},
+		{
+			name: "with_value_compression_codec",
+			opts: []Option{
+				WithValueCompressionCodec(ZstdCompression, 9),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.ValueCompressionCodec = ZstdCompression
+				cfg.ValueCompressionLevel = 9
+				return cfg
+			},
+		},
+		{
+			name: "with_harvest_offsets",
+			opts: []Option{
+				WithAggregationIntervals([]time.Duration{10 * time.Second, time.Minute}),
+				WithHarvestOffsets(map[time.Duration]time.Duration{time.Minute: 5 * time.Second}),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.AggregationIntervals = []time.Duration{10 * time.Second, time.Minute}
+				cfg.HarvestOffsets = map[time.Duration]time.Duration{time.Minute: 5 * time.Second}
+				return cfg
+			},
+		},
+		{
+			name: "with_harvest_offsets_exceeding_lowest_interval",
+			opts: []Option{
+				WithAggregationIntervals([]time.Duration{time.Minute, time.Hour}),
+				WithHarvestOffsets(map[time.Duration]time.Duration{
+					time.Minute: 5 * time.Second,
+					time.Hour:   5 * time.Minute,
+				}),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.AggregationIntervals = []time.Duration{time.Minute, time.Hour}
+				cfg.HarvestOffsets = map[time.Duration]time.Duration{
+					time.Minute: 5 * time.Second,
+					time.Hour:   5 * time.Minute,
+				}
+				return cfg
+			},
+		},
+		{
+			name: "with_interval_rollups",
+			opts: []Option{
+				WithAggregationIntervals([]time.Duration{time.Minute, 10 * time.Minute}),
+				WithIntervalRollups(true),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.AggregationIntervals = []time.Duration{time.Minute, 10 * time.Minute}
+				cfg.IntervalRollups = true
+				return cfg
+			},
+		},
+		{
+			name: "with_top_k_retention",
+			opts: []Option{
+				WithTopKRetention(true),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.TopKRetention = true
+				return cfg
+			},
+		},
+		{
+			name: "with_max_exemplars",
+			opts: []Option{
+				WithMaxExemplars(5),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.MaxExemplars = 5
+				return cfg
+			},
+		},
+		{
+			name: "with_max_pending_bytes",
+			opts: []Option{
+				WithMaxPendingBytes(1024),
+				WithBlockOnBackpressure(true),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.MaxPendingBytes = 1024
+				cfg.BlockOnBackpressure = true
+				return cfg
+			},
+		},
+		{
+			name: "with_duration_sum_estimate",
+			opts: []Option{
+				WithDurationSumEstimate(RecordedSumEstimate),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.DurationSumEstimate = RecordedSumEstimate
+				return cfg
+			},
+		},
+		{
+			name: "with_recovery_mode",
+			opts: []Option{
+				WithRecoveryMode(true),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.RecoveryMode = true
+				return cfg
+			},
+		},
+		{
+			name: "with_coverage_gap_handler",
+			opts: []Option{
+				WithCoverageGapHandler(func(CoverageGap) {}),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.CoverageGapHandler = func(CoverageGap) {}
+				return cfg
+			},
+		},
+		{
+			name: "with_partitioner",
+			opts: []Option{
+				WithPartitioner(func(uint64) uint16 { return 0 }, 4),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.Partitioner = func(uint64) uint16 { return 0 }
+				cfg.Partitions = 4
+				return cfg
+			},
+		},
+		{
+			name: "with_adaptive_partitions",
+			opts: []Option{
+				WithPartitions(2),
+				WithAdaptivePartitions(8, 1000),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.Partitions = 2
+				cfg.MaxAdaptivePartitions = 8
+				cfg.EventsPerPartition = 1000
+				return cfg
+			},
+		},
+		{
+			name: "with_ingest_rate_limit",
+			opts: []Option{
+				WithIngestRateLimit(func([16]byte) rate.Limit { return 100 }),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.IngestRateLimit = func([16]byte) rate.Limit { return 100 }
+				return cfg
+			},
+		},
+		{
+			name: "with_event_loss_handler",
+			opts: []Option{
+				WithEventLossHandler(func(EventLoss) {}),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.EventLossHandler = func(EventLoss) {}
+				return cfg
+			},
+		},
+		{
+			name: "with_value_compression_and_dictionary_training",
+			opts: []Option{
+				WithValueCompression(true),
+				WithDictionaryTraining(100, nil),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.ValueCompression = true
+				cfg.DictionarySamples = 100
+				cfg.DictionaryTrainer = RawContentDictionary
+				return cfg
+			},
+		},
+		{
+			name: "with_max_combined_metrics_size",
+			opts: []Option{
+				WithMaxCombinedMetricsSize(1 << 20),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.MaxCombinedMetricsSize = 1 << 20
+				return cfg
+			},
+		},
+		{
+			name: "with_replay_horizon",
+			opts: []Option{
+				WithReplayHorizon(time.Hour),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.ReplayHorizon = time.Hour
+				return cfg
+			},
+		},
+		{
+			name: "with_span_resource_normalizer",
+			opts: []Option{
+				WithSpanResourceNormalizer(strings.ToLower),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.SpanResourceNormalizer = strings.ToLower
+				return cfg
+			},
+		},
+		{
+			name: "with_key_extractor",
+			opts: []Option{
+				WithKeyExtractor(func(e *modelpb.APMEvent, ks *KeySet) {
+					ks.Service.Set("cloud.region", e.GetCloud().GetRegion())
+				}),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.KeyExtractor = func(*modelpb.APMEvent, *KeySet) {}
+				return cfg
+			},
+		},
+		{
+			name: "with_span_subtype_groups",
+			opts: []Option{
+				WithSpanSubtypeGroups(true),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.SpanSubtypeGroups = true
+				return cfg
+			},
+		},
+		{
+			name: "with_dimension_groups",
+			opts: []Option{
+				WithFaasGroups(false),
+				WithMobileGroups(true),
+				WithKubernetesGroups(true),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.FaasGroups = false
+				cfg.MobileGroups = true
+				cfg.KubernetesGroups = true
+				return cfg
+			},
+		},
+		{
+			name: "with_service_name_aliases",
+			opts: []Option{
+				WithServiceNameAliases(map[string]string{"svc-blue": "svc"}),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.ServiceNameAliases = map[string]string{"svc-blue": "svc"}
+				return cfg
+			},
+		},
+		{
+			name: "with_instance_dimensions",
+			opts: []Option{
+				WithInstanceDimensions(HostNameDimension, KubernetesPodNameDimension),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.InstanceDimensions = []InstanceDimension{HostNameDimension, KubernetesPodNameDimension}
+				return cfg
+			},
+		},
+		{
+			name: "with_collapsed_instances",
+			opts: []Option{
+				WithCollapsedInstances(true),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.CollapseInstances = true
+				return cfg
+			},
+		},
+		{
+			name: "with_global_labels_hash_threshold",
+			opts: []Option{
+				WithGlobalLabelsHashThreshold(64),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.GlobalLabelsHashThreshold = 64
+				return cfg
+			},
+		},
+		{
+			name: "with_global_label_filter",
+			opts: []Option{
+				WithGlobalLabelFilter([]string{"a", "b"}, []string{"b"}),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.GlobalLabelsAllowlist = []string{"a", "b"}
+				cfg.GlobalLabelsDenylist = []string{"b"}
+				return cfg
+			},
+		},
+		{
+			name: "with_histogram_impl",
+			opts: []Option{
+				WithHistogramImpl(DDSketchImpl),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.HistogramImpl = DDSketchImpl
+				return cfg
+			},
+		},
+		{
+			name: "with_histogram_precision",
+			opts: []Option{
+				WithHistogramPrecision(1, time.Minute),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.HistogramSignificantFigures = 1
+				cfg.HistogramMaxDuration = time.Minute
+				return cfg
+			},
+		},
+		{
+			name: "with_sparse_histograms",
+			opts: []Option{
+				WithSparseHistograms(true),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.SparseHistograms = true
+				return cfg
+			},
+		},
+		{
+			name: "with_harvest_loop_restarts",
+			opts: []Option{
+				WithHarvestLoopRestarts(3, time.Minute),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.MaxHarvestLoopRestarts = 3
+				cfg.HarvestLoopRestartBackoff = time.Minute
+				return cfg
+			},
+		},
+		{
+			name: "with_meter",
+			opts: []Option{
+				WithMeter(customMeter),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.Meter = customMeter
+				return cfg
+			},
+		},
+		{
+			name: "with_tracer",
+			opts: []Option{
+				WithTracer(customTracer),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.Tracer = customTracer
+				return cfg
+			},
+		},
+		{
+			name: "with_empty_data_dir",
+			opts: []Option{
+				WithDataDir(""),
+			},
+			expectedErrorMsg: "data directory is required",
+		},
+		{
+			name: "with_nil_processor",
+			opts: []Option{
+				WithProcessor(nil),
+			},
+			expectedErrorMsg: "processor is required",
+		},
+		{
+			name: "with_no_aggregation_interval",
+			opts: []Option{
+				WithAggregationIntervals(nil),
+			},
+			expectedErrorMsg: "at least one aggregation interval is required",
+		},
+		{
+			name: "with_unsorted_aggregation_intervals",
+			opts: []Option{
+				WithAggregationIntervals([]time.Duration{time.Hour, time.Minute}),
+			},
+			expectedErrorMsg: "aggregation intervals must be in ascending order",
+		},
+		{
+			name: "with_invalid_aggregation_intervals",
+			opts: []Option{
+				WithAggregationIntervals([]time.Duration{10 * time.Second, 15 * time.Second}),
+			},
+			expectedErrorMsg: "aggregation intervals must be a factor of lowest interval",
+		},
+		{
+			name: "with_out_of_lower_range_aggregation_interval",
+			opts: []Option{
+				WithAggregationIntervals([]time.Duration{time.Millisecond}),
+			},
+			expectedErrorMsg: "aggregation interval less than one second is not supported",
+		},
+		{
+			name: "with_out_of_upper_range_aggregation_interval",
+			opts: []Option{
+				WithAggregationIntervals([]time.Duration{20 * time.Hour}),
+			},
+			expectedErrorMsg: "aggregation interval greater than 18 hours is not supported",
+		},
+		{
+			name: "with_negative_harvest_jitter",
+			opts: []Option{
+				WithHarvestJitter(-time.Second),
+			},
+			expectedErrorMsg: "harvest jitter must not be negative",
+		},
+		{
+			name: "with_harvest_jitter_exceeding_lowest_interval",
+			opts: []Option{
+				WithHarvestJitter(time.Minute),
+			},
+			expectedErrorMsg: "harvest jitter must be less than the lowest aggregation interval",
+		},
+		{
+			name: "with_negative_harvest_concurrency",
+			opts: []Option{
+				WithHarvestConcurrency(-1),
+			},
+			expectedErrorMsg: "harvest concurrency must not be negative",
+		},
+		{
+			name: "with_empty_shard_data_dir",
+			opts: []Option{
+				WithShardDataDirs([]string{""}),
+			},
+			expectedErrorMsg: "shard data directories must not be empty",
+		},
+		{
+			name: "with_shard_data_dir_same_as_data_dir",
+			opts: []Option{
+				WithDataDir("/tmp/data"),
+				WithShardDataDirs([]string{"/tmp/data/"}),
+			},
+			expectedErrorMsg: `shard data directory "/tmp/data/" is already in use`,
+		},
+		{
+			name: "with_negative_flush_interval",
+			opts: []Option{
+				WithWriteCoalescing(-1, 1<<20),
+			},
+			expectedErrorMsg: "flush interval must not be negative",
+		},
+		{
+			name: "with_zero_flush_bytes",
+			opts: []Option{
+				WithWriteCoalescing(time.Second, 0),
+			},
+			expectedErrorMsg: "flush bytes must be greater than 0",
+		},
+		{
+			name: "with_negative_harvest_chunk_services",
+			opts: []Option{
+				WithHarvestChunking(-1),
+			},
+			expectedErrorMsg: "harvest chunk services must not be negative",
+		},
+		{
+			name: "with_negative_merge_budget",
+			opts: []Option{
+				WithMergeBudget(-1),
+			},
+			expectedErrorMsg: "merge budget must not be negative",
+		},
+		{
+			name: "with_merge_budget_over_a_second",
+			opts: []Option{
+				WithMergeBudget(2 * time.Second),
+			},
+			expectedErrorMsg: "merge budget must be between a microsecond and a second",
+		},
+		{
+			name: "with_unsupported_late_event_policy",
+			opts: []Option{
+				WithLateEventPolicy(RouteLateEvents + 1),
+			},
+			expectedErrorMsg: "unsupported late event policy 4",
+		},
+		{
+			name: "with_negative_allowed_lateness",
+			opts: []Option{
+				WithAllowedLateness(-1),
+			},
+			expectedErrorMsg: "allowed lateness must not be negative",
+		},
+		{
+			name: "with_reopen_late_events_without_allowed_lateness",
+			opts: []Option{
+				WithLateEventPolicy(ReopenLateEvents),
+			},
+			expectedErrorMsg: "reopening late events requires a positive allowed lateness",
+		},
+		{
+			name: "with_reopen_late_events_and_interval_rollups",
+			opts: []Option{
+				WithAggregationIntervals([]time.Duration{time.Second, time.Minute}),
+				WithIntervalRollups(true),
+				WithLateEventPolicy(ReopenLateEvents),
+				WithAllowedLateness(time.Minute),
+			},
+			expectedErrorMsg: "reopening late events is not supported with interval rollups",
+		},
+		{
+			name: "with_backfill_without_event_time_bucketing",
+			opts: []Option{
+				WithBackfill(true),
+			},
+			expectedErrorMsg: "backfill requires event time bucketing",
+		},
+		{
+			name: "with_event_time_bucketing_and_late_event_policy",
+			opts: []Option{
+				WithEventTimeBucketing(true),
+				WithLateEventPolicy(DropLateEvents),
+			},
+			expectedErrorMsg: "event time bucketing is not supported with a late event policy",
+		},
+		{
+			name: "with_event_time_bucketing_and_interval_rollups",
+			opts: []Option{
+				WithAggregationIntervals([]time.Duration{time.Second, time.Minute}),
+				WithIntervalRollups(true),
+				WithEventTimeBucketing(true),
+			},
+			expectedErrorMsg: "event time bucketing is not supported with interval rollups",
+		},
+		{
+			name: "with_event_time_bucketing_and_max_retention_within_allowed_lateness",
+			opts: []Option{
+				WithAggregationIntervals([]time.Duration{time.Minute}),
+				WithEventTimeBucketing(true),
+				WithAllowedLateness(time.Hour),
+				WithMaxRetention(time.Hour),
+			},
+			expectedErrorMsg: "max retention must be greater than the highest aggregation interval plus the allowed lateness with event time bucketing",
+		},
+		{
+			name: "with_negative_limit",
+			opts: []Option{
+				WithLimits(Limits{MaxTransactionGroups: -1}),
+			},
+			expectedErrorMsg: "limit MaxTransactionGroups must not be negative",
+		},
+		{
+			name: "with_unnamed_sink",
+			opts: []Option{
+				WithSinks(Sink{Processor: stdoutProcessor}),
+			},
+			expectedErrorMsg: "sink name must not be empty",
+		},
+		{
+			name: "with_duplicate_sinks",
+			opts: []Option{
+				WithSinks(
+					Sink{Name: "a", Processor: stdoutProcessor},
+					Sink{Name: "a", Processor: stdoutProcessor},
+				),
+			},
+			expectedErrorMsg: `sink "a" is already configured`,
+		},
+		{
+			name: "with_sink_without_processor",
+			opts: []Option{
+				WithSinks(Sink{Name: "a"}),
+			},
+			expectedErrorMsg: `sink "a" processor is required`,
+		},
+		{
+			name: "with_sink_negative_max_retries",
+			opts: []Option{
+				WithSinks(Sink{Name: "a", Processor: stdoutProcessor, MaxRetries: -1}),
+			},
+			expectedErrorMsg: `sink "a" max retries must not be negative`,
+		},
+		{
+			name: "with_sink_negative_queue_size",
+			opts: []Option{
+				WithSinks(Sink{Name: "a", Processor: stdoutProcessor, QueueSize: -1}),
+			},
+			expectedErrorMsg: `sink "a" queue size must not be negative`,
+		},
+		{
+			name: "with_nil_clock",
+			opts: []Option{
+				WithClock(nil),
+			},
+			expectedErrorMsg: "clock is required",
+		},
+		{
+			name: "with_negative_cold_tier_idle_intervals",
+			opts: []Option{
+				WithColdTier(-1),
+			},
+			expectedErrorMsg: "cold tier idle intervals must not be negative",
+		},
+		{
+			name: "with_shard_data_dir_of_cold_tier",
+			opts: []Option{
+				WithDataDir("/data"),
+				WithShardDataDirs([]string{"/data/cold"}),
+				WithColdTier(1),
+			},
+			expectedErrorMsg: `shard data directory "/data/cold" is in use by the cold tier`,
+		},
+		{
+			name: "with_max_adaptive_partitions_less_than_partitions",
+			opts: []Option{
+				WithPartitions(4),
+				WithAdaptivePartitions(2, 1000),
+			},
+			expectedErrorMsg: "max adaptive partitions must not be less than partitions",
+		},
+		{
+			name: "with_zero_events_per_partition",
+			opts: []Option{
+				WithAdaptivePartitions(4, 0),
+			},
+			expectedErrorMsg: "events per partition must be greater than zero",
+		},
+		{
+			name: "with_negative_max_retention",
+			opts: []Option{
+				WithMaxRetention(-1),
+			},
+			expectedErrorMsg: "max retention must not be negative",
+		},
+		{
+			name: "with_max_retention_not_greater_than_highest_interval",
+			opts: []Option{
+				WithAggregationIntervals([]time.Duration{time.Second, time.Minute}),
+				WithMaxRetention(time.Minute),
+			},
+			expectedErrorMsg: "max retention must be greater than the highest aggregation interval",
+		},
+		{
+			name: "with_negative_pebble_memtable_size",
+			opts: []Option{
+				WithPebbleOptions(PebbleOptions{MemTableSize: -1}),
+			},
+			expectedErrorMsg: "pebble memtable size must not be negative",
+		},
+		{
+			name: "with_pebble_l0_stop_writes_threshold_below_compaction_threshold",
+			opts: []Option{
+				WithPebbleOptions(PebbleOptions{L0CompactionThreshold: 8, L0StopWritesThreshold: 4}),
+			},
+			expectedErrorMsg: "pebble L0 stop writes threshold must not be less than the L0 compaction threshold",
+		},
+		{
+			name: "with_pebble_level_compression_exceeding_levels",
+			opts: []Option{
+				WithPebbleOptions(PebbleOptions{LevelCompression: make([]Compression, 8)}),
+			},
+			expectedErrorMsg: "pebble level compression must not exceed 7 levels",
+		},
+		{
+			name: "with_unsupported_pebble_level_compression",
+			opts: []Option{
+				WithPebbleOptions(PebbleOptions{LevelCompression: []Compression{10}}),
+			},
+			expectedErrorMsg: "unsupported compression 10",
+		},
+		{
+			name: "with_harvest_offset_for_unknown_interval",
+			opts: []Option{
+				WithHarvestOffsets(map[time.Duration]time.Duration{time.Hour: time.Second}),
+			},
+			expectedErrorMsg: "harvest offset configured for unknown aggregation interval 1h0m0s",
+		},
+		{
+			name: "with_negative_harvest_offset",
+			opts: []Option{
+				WithHarvestOffsets(map[time.Duration]time.Duration{time.Minute: -time.Second}),
+			},
+			expectedErrorMsg: "harvest offset for aggregation interval 1m0s must not be negative",
+		},
+		{
+			name: "with_harvest_offset_exceeding_interval",
+			opts: []Option{
+				WithAggregationIntervals([]time.Duration{time.Minute, time.Hour}),
+				WithHarvestOffsets(map[time.Duration]time.Duration{time.Hour: time.Hour}),
+			},
+			expectedErrorMsg: "harvest offset for aggregation interval 1h0m0s must be less than the aggregation interval",
+		},
+		{
+			name: "with_interval_rollups_and_lower_harvest_offset",
+			opts: []Option{
+				WithAggregationIntervals([]time.Duration{time.Minute, 10 * time.Minute}),
+				WithHarvestOffsets(map[time.Duration]time.Duration{time.Minute: 5 * time.Second}),
+				WithIntervalRollups(true),
+			},
+			expectedErrorMsg: "harvest offset for aggregation interval 10m0s must not be less than the offset of the lowest aggregation interval with interval rollups",
+		},
+		{
+			name: "with_unsupported_instance_dimension",
+			opts: []Option{
+				WithInstanceDimensions(InstanceDimension(0)),
+			},
+			expectedErrorMsg: "unsupported instance dimension 0",
+		},
+		{
+			name: "with_instance_dimensions_and_collapsed_instances",
+			opts: []Option{
+				WithInstanceDimensions(HostNameDimension),
+				WithCollapsedInstances(true),
+			},
+			expectedErrorMsg: "instance dimensions are not supported with collapsed instances",
+		},
+		{
+			name: "with_empty_service_name_alias",
+			opts: []Option{
+				WithServiceNameAliases(map[string]string{"svc-blue": ""}),
+			},
+			expectedErrorMsg: `service name alias for "svc-blue" must not be empty`,
+		},
+		{
+			name: "with_negative_max_pending_bytes",
+			opts: []Option{
+				WithMaxPendingBytes(-1),
+			},
+			expectedErrorMsg: "max pending bytes must not be negative",
+		},
+		{
+			name: "with_unsupported_duration_sum_estimate",
+			opts: []Option{
+				WithDurationSumEstimate(RecordedSumEstimate + 1),
+			},
+			expectedErrorMsg: "unsupported duration sum estimate 4",
+		},
+		{
+			name: "with_negative_max_combined_metrics_size",
+			opts: []Option{
+				WithMaxCombinedMetricsSize(-1),
+			},
+			expectedErrorMsg: "max combined metrics size must not be negative",
+		},
+		{
+			name: "with_negative_replay_horizon",
+			opts: []Option{
+				WithReplayHorizon(-time.Second),
+			},
+			expectedErrorMsg: "replay horizon must not be negative",
+		},
+		{
+			name: "with_negative_max_exemplars",
+			opts: []Option{
+				WithMaxExemplars(-1),
+			},
+			expectedErrorMsg: "max exemplars must not be negative",
+		},
+		{
+			name: "with_negative_global_labels_hash_threshold",
+			opts: []Option{
+				WithGlobalLabelsHashThreshold(-1),
+			},
+			expectedErrorMsg: "global labels hash threshold must not be negative",
+		},
+		{
+			name: "with_empty_global_label_filter_key",
+			opts: []Option{
+				WithGlobalLabelFilter(nil, []string{""}),
+			},
+			expectedErrorMsg: "global label filter keys must not be empty",
+		},
+		{
+			name: "with_unsupported_histogram_impl",
+			opts: []Option{
+				WithHistogramImpl(DDSketchImpl + 1),
+			},
+			expectedErrorMsg: "unsupported histogram implementation 3",
+		},
+		{
+			name: "with_invalid_histogram_significant_figures",
+			opts: []Option{
+				WithHistogramPrecision(6, time.Hour),
+			},
+			expectedErrorMsg: "histogram significant figures must be between 1 and 5",
+		},
+		{
+			name: "with_invalid_histogram_max_duration",
+			opts: []Option{
+				WithHistogramPrecision(2, time.Microsecond),
+			},
+			expectedErrorMsg: "histogram max duration must be at least 1ms",
+		},
+		{
+			name: "with_unsupported_value_compression",
+			opts: []Option{
+				WithValueCompressionCodec(10, 0),
+			},
+			expectedErrorMsg: "unsupported value compression 10",
+		},
+		{
+			name: "with_snappy_value_compression_level",
+			opts: []Option{
+				WithValueCompressionCodec(SnappyCompression, 3),
+			},
+			expectedErrorMsg: "value compression level is only supported for zstd compression",
+		},
+		{
+			name: "with_zstd_value_compression_level_exceeding_max",
+			opts: []Option{
+				WithValueCompressionCodec(ZstdCompression, 23),
+			},
+			expectedErrorMsg: "zstd value compression level must not exceed 22",
+		},
+		{
+			name: "with_dictionary_training_for_snappy_value_compression",
+			opts: []Option{
+				WithValueCompressionCodec(SnappyCompression, 0),
+				WithDictionaryTraining(10, nil),
+			},
+			expectedErrorMsg: "dictionary training requires zstd value compression",
+		},
+		{
+			name: "with_negative_dictionary_samples",
+			opts: []Option{
+				WithValueCompression(true),
+				WithDictionaryTraining(-1, nil),
+			},
+			expectedErrorMsg: "dictionary samples must not be negative",
+		},
+		{
+			name: "with_dictionary_training_without_value_compression",
+			opts: []Option{
+				WithDictionaryTraining(100, nil),
+			},
+			expectedErrorMsg: "dictionary training requires value compression",
+		},
+		{
+			name: "with_negative_harvest_loop_restarts",
+			opts: []Option{
+				WithHarvestLoopRestarts(-1, time.Second),
+			},
+			expectedErrorMsg: "max harvest loop restarts must not be negative",
+		},
+		{
+			name: "with_zero_harvest_loop_restart_backoff",
+			opts: []Option{
+				WithHarvestLoopRestarts(1, 0),
+			},
+			expectedErrorMsg: "harvest loop restart backoff must be greater than zero",
+		},
+		{
+			name: "with_overflow_notifier",
+			opts: []Option{
+				WithOverflowNotifier(func(OverflowEvent) {}),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.OverflowNotifier = func(OverflowEvent) {}
+				return cfg
+			},
+		},
+		{
+			name: "with_log_sampling",
+			opts: []Option{
+				WithLogSampling(time.Second, 10, 100),
+			},
+			expected: func() Config {
+				cfg := defaultCfg
+				cfg.LogSamplingInterval = time.Second
+				cfg.LogSamplingFirst = 10
+				cfg.LogSamplingThereafter = 100
+				return cfg
+			},
+		},
+		{
+			name: "with_negative_log_sampling_interval",
+			opts: []Option{
+				WithLogSampling(-time.Second, 10, 100),
+			},
+			expectedErrorMsg: "log sampling interval must not be negative",
+		},
+		{
+			name: "with_zero_log_sampling_first",
+			opts: []Option{
+				WithLogSampling(time.Second, 0, 100),
+			},
+			expectedErrorMsg: "log sampling first must be greater than zero",
+		},
+		{
+			name: "with_negative_log_sampling_thereafter",
+			opts: []Option{
+				WithLogSampling(time.Second, 10, -1),
+			},
+			expectedErrorMsg: "log sampling thereafter must not be negative",
+		},
+	} {
+		actual, err := NewConfig(tc.opts...)
+
+		if tc.expectedErrorMsg != "" {
+			assert.EqualError(t, err, tc.expectedErrorMsg)
+			continue
+		}
+
+		expected := tc.expected()
+		assert.NoError(t, err)
+
+		// New logger is created for every call
+		assert.NotNil(t, actual.Logger)
+		actual.Logger, expected.Logger = nil, nil
+
+		// Function values are not comparable
+		assert.NotNil(t, actual.CombinedMetricsIDToKVs)
+		actual.CombinedMetricsIDToKVs, expected.CombinedMetricsIDToKVs = nil, nil
+		assert.NotNil(t, actual.Processor)
+		actual.Processor, expected.Processor = nil, nil
+		assert.Equal(t, expected.SpanResourceNormalizer != nil, actual.SpanResourceNormalizer != nil)
+		actual.SpanResourceNormalizer, expected.SpanResourceNormalizer = nil, nil
+		assert.Equal(t, expected.KeyExtractor != nil, actual.KeyExtractor != nil)
+		actual.KeyExtractor, expected.KeyExtractor = nil, nil
+		assert.Equal(t, expected.CoverageGapHandler != nil, actual.CoverageGapHandler != nil)
+		actual.CoverageGapHandler, expected.CoverageGapHandler = nil, nil
+		assert.Equal(t, expected.EventLossHandler != nil, actual.EventLossHandler != nil)
+		actual.EventLossHandler, expected.EventLossHandler = nil, nil
+		assert.Equal(t, expected.OverflowNotifier != nil, actual.OverflowNotifier != nil)
+		actual.OverflowNotifier, expected.OverflowNotifier = nil, nil
+		assert.Equal(t, expected.DictionaryTrainer != nil, actual.DictionaryTrainer != nil)
+		actual.DictionaryTrainer, expected.DictionaryTrainer = nil, nil
+		assert.Equal(t, expected.IngestRateLimit != nil, actual.IngestRateLimit != nil)
+		actual.IngestRateLimit, expected.IngestRateLimit = nil, nil
+		assert.Equal(t, expected.Partitioner != nil, actual.Partitioner != nil)
+		actual.Partitioner, expected.Partitioner = nil, nil
+
+		assert.Equal(t, expected, actual)
+	}
+}
+
+func TestConfigFingerprint(t *testing.T) {
+	newConfig := func(opts ...Option) Config {
+		cfg, err := NewConfig(opts...)
+		require.NoError(t, err)
+		return cfg
+	}
+	fp := newConfig().Fingerprint()
+	assert.Len(t, fp, 16)
+	assert.Equal(t, fp, newConfig().Fingerprint())
+	assert.Equal(t, fp, newConfig(WithHarvestDelay(time.Second), WithDataDir("/tmp")).Fingerprint())
+
+	for _, opt := range []Option{
+		WithLimits(Limits{MaxServices: 1}),
+		WithAggregationIntervals([]time.Duration{time.Minute, time.Hour}),
+		WithSpanSubtypeGroups(true),
+		WithFaasGroups(false),
+		WithMobileGroups(true),
+		WithKubernetesGroups(true),
+		WithServiceNameAliases(map[string]string{"a": "b"}),
+	} {
+		assert.NotEqual(t, fp, newConfig(opt).Fingerprint())
 	}
+	assert.Equal(t,
+		newConfig(WithServiceNameAliases(map[string]string{"a": "b", "c": "d"})).Fingerprint(),
+		newConfig(WithServiceNameAliases(map[string]string{"c": "d", "a": "b"})).Fingerprint(),
+	)
 }
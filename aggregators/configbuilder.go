@@ -0,0 +1,195 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ConfigIssue is an issue of the aggregator configuration reported by
+// ConfigBuilder.Validate.
+type ConfigIssue struct {
+	// Field is the name of the Config field the issue relates to, e.g.
+	// `Limits.MaxServices`. It is empty if the issue is not specific to a
+	// single field.
+	Field string
+
+	// Message describes the issue.
+	Message string
+}
+
+// String returns the message of the issue prefixed with the field, if any.
+func (i ConfigIssue) String() string {
+	if i.Field == "" {
+		return i.Message
+	}
+	return i.Field + ": " + i.Message
+}
+
+// ValidationReport is the result of validating the aggregator
+// configuration. Errors make the configuration invalid, warnings point to
+// valid but likely unintended configurations.
+type ValidationReport struct {
+	Errors   []ConfigIssue
+	Warnings []ConfigIssue
+}
+
+// Valid returns true if the report has no errors.
+func (r ValidationReport) Valid() bool {
+	return len(r.Errors) == 0
+}
+
+// Err returns the errors of the report joined into a single error, or nil
+// if the report has no errors.
+func (r ValidationReport) Err() error {
+	errs := make([]error, 0, len(r.Errors))
+	for _, issue := range r.Errors {
+		errs = append(errs, errors.New(issue.String()))
+	}
+	return errors.Join(errs...)
+}
+
+// ConfigBuilder builds the aggregator configuration from options,
+// allowing the configuration to be validated, including the warnings for
+// likely unintended configurations, before creating the aggregator.
+type ConfigBuilder struct {
+	opts []Option
+}
+
+// NewConfigBuilder returns a config builder with the given options.
+func NewConfigBuilder(opts ...Option) *ConfigBuilder {
+	return &ConfigBuilder{opts: opts}
+}
+
+// With appends the options to the builder, the options are applied in the
+// order they were added. It returns the builder for chaining.
+func (b *ConfigBuilder) With(opts ...Option) *ConfigBuilder {
+	b.opts = append(b.opts, opts...)
+	return b
+}
+
+// Options returns the options of the builder, e.g. for passing to New.
+func (b *ConfigBuilder) Options() []Option {
+	return append([]Option(nil), b.opts...)
+}
+
+// Validate validates the configuration built from the options.
+func (b *ConfigBuilder) Validate() ValidationReport {
+	cfg := b.apply()
+	var report ValidationReport
+	if err := validateCfg(cfg); err != nil {
+		report.Errors = append(report.Errors, ConfigIssue{Message: err.Error()})
+	}
+	report.Warnings = configWarnings(cfg)
+	return report
+}
+
+// Build returns the configuration built from the options, or an error if
+// the configuration is invalid. Warnings do not fail the build.
+func (b *ConfigBuilder) Build() (Config, error) {
+	return NewConfig(b.opts...)
+}
+
+func (b *ConfigBuilder) apply() Config {
+	cfg := defaultCfg()
+	for _, opt := range b.opts {
+		cfg = opt(cfg)
+	}
+	return cfg
+}
+
+// configWarnings returns the warnings for valid but likely unintended
+// configurations, e.g. limits aggregating all the metrics into overflow
+// buckets. Zero limits are only reported if the limits were configured,
+// not for the default limits, so that creating an aggregator with the
+// default limits does not log a warning per limit.
+func configWarnings(cfg Config) []ConfigIssue {
+	var warnings []ConfigIssue
+	warn := func(field, format string, args ...any) {
+		warnings = append(warnings, ConfigIssue{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+	if len(cfg.AggregationIntervals) == 0 {
+		return warnings
+	}
+	lowest := cfg.AggregationIntervals[0]
+	highest := cfg.AggregationIntervals[len(cfg.AggregationIntervals)-1]
+	if cfg.HarvestDelay >= lowest {
+		warn("HarvestDelay",
+			"harvest delay %s is not shorter than the lowest aggregation interval %s, harvests fall a period behind",
+			cfg.HarvestDelay, lowest,
+		)
+	}
+	if cfg.ReplayHorizon > 0 && cfg.ReplayHorizon <= highest {
+		warn("ReplayHorizon",
+			"replay horizon %s is not longer than the highest aggregation interval %s, combined metrics of the current period may be rejected",
+			cfg.ReplayHorizon, highest,
+		)
+	}
+	if cfg.MaxPendingBytes > 0 && cfg.FlushInterval == 0 && cfg.FlushBytes > cfg.MaxPendingBytes {
+		warn("FlushBytes",
+			"flush bytes %d exceed max pending bytes %d without a flush interval, writes are backpressured until the next harvest",
+			cfg.FlushBytes, cfg.MaxPendingBytes,
+		)
+	}
+
+	if cfg.Limits == defaultCfg().Limits {
+		return warnings
+	}
+	for _, l := range []struct {
+		name  string
+		value int
+	}{
+		{"MaxServices", cfg.Limits.MaxServices},
+		{"MaxServiceInstanceGroupsPerService", cfg.Limits.MaxServiceInstanceGroupsPerService},
+		{"MaxTransactionGroups", cfg.Limits.MaxTransactionGroups},
+		{"MaxTransactionGroupsPerService", cfg.Limits.MaxTransactionGroupsPerService},
+		{"MaxServiceTransactionGroups", cfg.Limits.MaxServiceTransactionGroups},
+		{"MaxServiceTransactionGroupsPerService", cfg.Limits.MaxServiceTransactionGroupsPerService},
+		{"MaxSpanGroups", cfg.Limits.MaxSpanGroups},
+		{"MaxSpanGroupsPerService", cfg.Limits.MaxSpanGroupsPerService},
+	} {
+		if l.value == 0 {
+			warn("Limits."+l.name, "limit of 0 aggregates all the groups into overflow buckets")
+		}
+	}
+	for _, l := range []struct {
+		name, globalName   string
+		value, globalValue int
+	}{
+		{
+			"MaxServiceInstanceGroupsPerService", "MaxServiceInstanceGroups",
+			cfg.Limits.MaxServiceInstanceGroupsPerService, cfg.Limits.MaxServiceInstanceGroups,
+		},
+		{
+			"MaxTransactionGroupsPerService", "MaxTransactionGroups",
+			cfg.Limits.MaxTransactionGroupsPerService, cfg.Limits.MaxTransactionGroups,
+		},
+		{
+			"MaxServiceTransactionGroupsPerService", "MaxServiceTransactionGroups",
+			cfg.Limits.MaxServiceTransactionGroupsPerService, cfg.Limits.MaxServiceTransactionGroups,
+		},
+		{
+			"MaxSpanGroupsPerService", "MaxSpanGroups",
+			cfg.Limits.MaxSpanGroupsPerService, cfg.Limits.MaxSpanGroups,
+		},
+		{
+			"MaxErrorGroupsPerService", "MaxErrorGroups",
+			cfg.Limits.MaxErrorGroupsPerService, cfg.Limits.MaxErrorGroups,
+		},
+		{
+			"MaxServiceGraphEdgesPerService", "MaxServiceGraphEdges",
+			cfg.Limits.MaxServiceGraphEdgesPerService, cfg.Limits.MaxServiceGraphEdges,
+		},
+	} {
+		if l.globalValue > 0 && l.value > l.globalValue {
+			warn("Limits."+l.name,
+				"limit of %d exceeds Limits.%s of %d and has no effect",
+				l.value, l.globalName, l.globalValue,
+			)
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,116 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestConfigBuilder(t *testing.T) {
+	limits := Limits{
+		MaxServices:                           10,
+		MaxServiceInstanceGroupsPerService:    10,
+		MaxTransactionGroups:                  100,
+		MaxTransactionGroupsPerService:        10,
+		MaxServiceTransactionGroups:           100,
+		MaxServiceTransactionGroupsPerService: 10,
+		MaxSpanGroups:                         100,
+		MaxSpanGroupsPerService:               10,
+	}
+	for _, tc := range []struct {
+		name             string
+		opts             []Option
+		expectedErrors   []ConfigIssue
+		expectedWarnings []ConfigIssue
+	}{
+		{
+			name: "valid",
+			opts: []Option{WithLimits(limits)},
+		},
+		{
+			name: "default_limits",
+		},
+		{
+			name: "warnings",
+			opts: []Option{
+				WithLimits(func() Limits {
+					l := limits
+					l.MaxServices = 0
+					l.MaxSpanGroupsPerService = 1000
+					return l
+				}()),
+				WithHarvestDelay(time.Minute),
+				WithReplayHorizon(time.Minute),
+			},
+			expectedWarnings: []ConfigIssue{
+				{
+					Field:   "HarvestDelay",
+					Message: "harvest delay 1m0s is not shorter than the lowest aggregation interval 1m0s, harvests fall a period behind",
+				},
+				{
+					Field:   "ReplayHorizon",
+					Message: "replay horizon 1m0s is not longer than the highest aggregation interval 1m0s, combined metrics of the current period may be rejected",
+				},
+				{
+					Field:   "Limits.MaxServices",
+					Message: "limit of 0 aggregates all the groups into overflow buckets",
+				},
+				{
+					Field:   "Limits.MaxSpanGroupsPerService",
+					Message: "limit of 1000 exceeds Limits.MaxSpanGroups of 100 and has no effect",
+				},
+			},
+		},
+		{
+			name: "invalid",
+			opts: []Option{
+				WithLimits(limits),
+				WithAggregationIntervals(nil),
+			},
+			expectedErrors: []ConfigIssue{
+				{Message: "at least one aggregation interval is required"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b := NewConfigBuilder(WithDataDir(t.TempDir())).With(tc.opts...)
+			report := b.Validate()
+			assert.Equal(t, tc.expectedErrors, report.Errors)
+			assert.Equal(t, tc.expectedWarnings, report.Warnings)
+			assert.Equal(t, len(tc.expectedErrors) == 0, report.Valid())
+
+			_, err := b.Build()
+			if report.Valid() {
+				assert.NoError(t, err)
+				assert.NoError(t, report.Err())
+			} else {
+				assert.EqualError(t, err, report.Err().Error())
+			}
+		})
+	}
+}
+
+func TestAggregatorConfig(t *testing.T) {
+	b := NewConfigBuilder(
+		WithDataDir(t.TempDir()),
+		WithAggregationIntervals([]time.Duration{time.Second, time.Minute}),
+		WithLogger(zap.NewNop()),
+	)
+	agg, err := New(b.Options()...)
+	require.NoError(t, err)
+	defer agg.Close(context.Background())
+
+	cfg := agg.Config()
+	assert.Equal(t, []time.Duration{time.Second, time.Minute}, cfg.AggregationIntervals)
+	// Defaults are resolved.
+	assert.Equal(t, uint16(1), cfg.Partitions)
+	assert.Equal(t, dbCommitThresholdBytes, cfg.FlushBytes)
+}
@@ -5,10 +5,13 @@
 package aggregators
 
 import (
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"math"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,10 +19,13 @@ import (
 	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"github.com/cespare/xxhash/v2"
+	"golang.org/x/exp/slices"
 
 	"github.com/elastic/apm-aggregation/aggregationpb"
+	"github.com/elastic/apm-aggregation/aggregators/internal/ddsketch"
 	"github.com/elastic/apm-aggregation/aggregators/internal/hdrhistogram"
 	"github.com/elastic/apm-aggregation/aggregators/internal/protohash"
+	"github.com/elastic/apm-aggregation/aggregators/internal/tdigest"
 	tspb "github.com/elastic/apm-aggregation/aggregators/internal/timestamppb"
 	"github.com/elastic/apm-aggregation/aggregators/nullable"
 	"github.com/elastic/apm-data/model/modelpb"
@@ -30,10 +36,750 @@ const (
 	txnMetricsetName     = "transaction"
 	svcTxnMetricsetName  = "service_transaction"
 	summaryMetricsetName = "service_summary"
+	errorMetricsetName   = "service_error"
+
+	serviceGraphEdgeMetricsetName = "service_graph_edge"
 
 	overflowBucketName = "_other"
 )
 
+// DocumentIDLabel is the name of the label holding the document ID of
+// events converted by CombinedMetricsToBatch, see WithDocumentIDs.
+const DocumentIDLabel = "aggregation_document_id"
+
+// ConfigFingerprintLabel is the name of the label holding the aggregation
+// configuration fingerprint of events converted by CombinedMetricsToBatch,
+// see WithConfigFingerprint.
+const ConfigFingerprintLabel = "aggregation_config_fingerprint"
+
+// TemporalityLabel is the name of the label declaring the temporality of
+// the counters of events converted by CombinedMetricsToBatch, see
+// WithTemporality.
+const TemporalityLabel = "aggregation_temporality"
+
+// KubernetesDeploymentLabel is the name of the label holding the
+// Kubernetes deployment name of events, see WithKubernetesDimensions.
+const KubernetesDeploymentLabel = "kubernetes_deployment_name"
+
+// MetricsetType identifies a metricset emitted by CombinedMetricsToBatch.
+type MetricsetType uint8
+
+const (
+	// TransactionMetricset identifies the transaction metricset.
+	TransactionMetricset MetricsetType = iota
+	// ServiceTransactionMetricset identifies the service_transaction
+	// metricset.
+	ServiceTransactionMetricset
+	// ServiceDestinationMetricset identifies the service_destination
+	// metricset.
+	ServiceDestinationMetricset
+	// ServiceSummaryMetricset identifies the service_summary metricset.
+	ServiceSummaryMetricset
+	// ServiceErrorMetricset identifies the service_error metricset.
+	ServiceErrorMetricset
+	// ServiceGraphEdgeMetricset identifies the service_graph_edge
+	// metricset.
+	ServiceGraphEdgeMetricset
+)
+
+// metricsetTypes is a set of metricset types.
+type metricsetTypes uint8
+
+const allMetricsetTypes metricsetTypes = 1<<(ServiceGraphEdgeMetricset+1) - 1
+
+func (m metricsetTypes) has(t MetricsetType) bool {
+	return m&(1<<t) != 0
+}
+
+// ConverterOption configures the conversion of CombinedMetrics to a batch
+// of APMEvents by CombinedMetricsToBatch.
+type ConverterOption func(converterConfig) converterConfig
+
+type converterConfig struct {
+	percentiles               []float64
+	serviceTransactionRates   bool
+	throughput                bool
+	globalLabelsHashThreshold int
+	globalLabelsAllow         []string
+	globalLabelsDeny          []string
+	histogramImpl             HistogramImpl
+	histogramPrecision        histogramPrecision
+	durationSumEstimate       DurationSumEstimate
+	durationExtremes          bool
+	exemplars                 bool
+	exemplarHandler           func(*modelpb.APMEvent, []*aggregationpb.Exemplar)
+	errors                    bool
+	normalizeSpanResource     func(string) string
+	keyExtractor              func(*modelpb.APMEvent, *KeySet)
+	spanSubtype               bool
+	omitFaasDimensions        bool
+	mobileDimensions          bool
+	kubernetesDimensions      bool
+	partitioner               func(uint64) uint16
+	serviceNameAliases        map[string]string
+	documentIDs               bool
+	serviceGraphEdges         bool
+	instanceDimensions        []InstanceDimension
+	collapseServiceInstances  bool
+	serviceSummaryIntervals   []time.Duration
+	intervalMetricsets        map[time.Duration][]MetricsetType
+	metricsetNames            map[string]string
+	suppressedMetricsets      []string
+	intervalFormat            func(time.Duration) string
+	configFingerprint         string
+	temporality               Temporality
+	cumulativeCounters        *CumulativeCounters
+	maxBatchEvents            int
+	maxBatchBytes             int
+}
+
+// WithPercentiles configures the percentiles of the transaction duration
+// to be calculated from the duration histogram and added to transaction
+// and service transaction metrics as gauge metricset samples, named for
+// example `transaction.duration.p95` for the 95th percentile. Dots in the
+// percentile are replaced with underscores, the 99.9th percentile is named
+// `transaction.duration.p99_9`. Percentiles must be in the range (0, 100].
+// The percentile values are in microseconds, same as the histogram values.
+func WithPercentiles(percentiles []float64) ConverterOption {
+	return func(c converterConfig) converterConfig {
+		c.percentiles = percentiles
+		return c
+	}
+}
+
+// WithServiceTransactionRates configures CombinedMetricsToBatch to add
+// precomputed rates to the service transaction metrics as gauge metricset
+// samples: `service_transaction.failure_rate`, the fraction of the
+// transactions with a known outcome which failed, derived from the success
+// count, and `service_transaction.throughput_per_minute`, the number of
+// transactions per minute of the aggregation interval. The failure rate is
+// omitted if none of the transactions have a known outcome.
+func WithServiceTransactionRates(enabled bool) ConverterOption {
+	return func(c converterConfig) converterConfig {
+		c.serviceTransactionRates = enabled
+		return c
+	}
+}
+
+// WithThroughput configures CombinedMetricsToBatch to add the throughput,
+// the number of transactions per minute of the aggregation interval, to
+// the transaction and service transaction metrics as a gauge metricset
+// sample named `throughput`. The number of transactions is weighted by
+// their representative count, same as the doc count, so that consumers
+// need not derive the throughput from the doc count and the interval.
+func WithThroughput(enabled bool) ConverterOption {
+	return func(c converterConfig) converterConfig {
+		c.throughput = enabled
+		return c
+	}
+}
+
+// WithHashedGlobalLabels configures EventToCombinedMetrics to replace the
+// serialized global labels in the service instance key with a hash if the
+// serialized global labels are larger than the given threshold in bytes.
+// The full serialized global labels are stored in the service instance
+// metrics instead, keeping the keys short and fast to compare for events
+// with a large number of global labels. A threshold of 0 disables hashing.
+//
+// The hashed key is 33 bytes long, so thresholds lower than that only
+// increase the size of the keys.
+func WithHashedGlobalLabels(threshold int) ConverterOption {
+	return func(c converterConfig) converterConfig {
+		c.globalLabelsHashThreshold = threshold
+		return c
+	}
+}
+
+// WithFilteredGlobalLabels configures EventToCombinedMetrics to only
+// include the approved global labels in the service instance key, dropping
+// the other global labels before keying. If allow is not empty, only the
+// global labels with a key in allow are included. Global labels with a key
+// in deny are never included, even if allowed. This prevents volatile
+// global labels, such as build numbers, from splitting the metrics of a
+// service. By default, all global labels are included.
+func WithFilteredGlobalLabels(allow, deny []string) ConverterOption {
+	return func(c converterConfig) converterConfig {
+		c.globalLabelsAllow = allow
+		c.globalLabelsDeny = deny
+		return c
+	}
+}
+
+// WithDurationHistogramImpl configures EventToCombinedMetrics to record
+// transaction durations using the given implementation. Durations recorded
+// using different implementations are combined into a single histogram by
+// CombinedMetricsToBatch. Defaults to HDRHistogramImpl.
+func WithDurationHistogramImpl(impl HistogramImpl) ConverterOption {
+	return func(c converterConfig) converterConfig {
+		c.histogramImpl = impl
+		return c
+	}
+}
+
+// WithDurationHistogramPrecision configures EventToCombinedMetrics to
+// record transaction durations in HDR histograms with the given number of
+// significant figures, up to the given maximum duration. Durations above
+// the maximum are recorded as the maximum. Histograms recorded with
+// different precisions are merged with the precision of the histogram
+// merged into. Defaults to 2 significant figures and a maximum of 1 hour.
+func WithDurationHistogramPrecision(significantFigures int, maxDuration time.Duration) ConverterOption {
+	return func(c converterConfig) converterConfig {
+		c.histogramPrecision = histogramPrecision{
+			significantFigures:    int64(significantFigures),
+			highestTrackableValue: maxDuration.Microseconds(),
+		}
+		return c
+	}
+}
+
+// histogramPrecision holds the parameters of the recorded HDR histograms,
+// the zero value standing for the default parameters.
+type histogramPrecision struct {
+	significantFigures    int64
+	highestTrackableValue int64
+}
+
+func (p histogramPrecision) newHistogram() *hdrhistogram.HistogramRepresentation {
+	if p == (histogramPrecision{}) {
+		return hdrhistogram.New()
+	}
+	return hdrhistogram.NewWithPrecision(p.significantFigures, p.highestTrackableValue)
+}
+
+// WithDurationSummarySum configures how the sum of the transaction
+// duration summary of transaction and service transaction metrics is
+// derived. For RecordedSumEstimate, EventToCombinedMetrics records the
+// exact sum of the durations alongside the histogram and
+// CombinedMetricsToBatch uses the recorded sum, making the sum exact at
+// the cost of 9 bytes per group. The sum is recorded in fractional
+// microseconds, so that sub-microsecond durations are not truncated. If
+// the sum was not recorded for some of the merged metrics, the recorded
+// sum is dropped and the upper bound estimate is used instead. The other
+// estimates only apply to the buckets of HDR histograms; the buckets of
+// DDSketch and t-digest are represented by a single value. Defaults to
+// UpperBoundSumEstimate.
+func WithDurationSummarySum(estimate DurationSumEstimate) ConverterOption {
+	return func(c converterConfig) converterConfig {
+		c.durationSumEstimate = estimate
+		return c
+	}
+}
+
+// WithDurationExtremes configures EventToCombinedMetrics to record the
+// exact minimum and maximum durations of transaction and service
+// transaction metrics, which CombinedMetricsToBatch reports as the
+// transaction.duration.min and transaction.duration.max gauge samples.
+// Recording the extremes costs 18 bytes per group. Metrics recorded
+// without the extremes do not widen the extremes of the metrics they are
+// merged with. Defaults to false.
+func WithDurationExtremes(enabled bool) ConverterOption {
+	return func(c converterConfig) converterConfig {
+		c.durationExtremes = enabled
+		return c
+	}
+}
+
+// WithEventExemplars configures EventToCombinedMetrics to record the
+// transaction and span events as exemplars of their transaction and span
+// groups. Events without a trace ID are not recorded as exemplars.
+func WithEventExemplars(enabled bool) ConverterOption {
+	return func(c converterConfig) converterConfig {
+		c.exemplars = enabled
+		return c
+	}
+}
+
+// WithExemplarHandler configures CombinedMetricsToBatch to call the handler
+// for each transaction and span metrics event with the exemplars of the
+// group, sorted by duration. The handler is not called for groups without
+// exemplars. The handler MUST NOT hold the reference of the passed
+// exemplars, as they are released back to the pool with the metrics.
+func WithExemplarHandler(handler func(*modelpb.APMEvent, []*aggregationpb.Exemplar)) ConverterOption {
+	return func(c converterConfig) converterConfig {
+		c.exemplarHandler = handler
+		return c
+	}
+}
+
+// WithErrorMetrics configures EventToCombinedMetrics to aggregate error
+// events into error groups, keyed by the error grouping key and the event
+// outcome. Error events only add to the service summary metrics otherwise.
+func WithErrorMetrics(enabled bool) ConverterOption {
+	return func(c converterConfig) converterConfig {
+		c.errors = enabled
+		return c
+	}
+}
+
+// WithServiceGraphEdges configures EventToCombinedMetrics to aggregate span
+// events into service graph edges from the service of the span to the
+// service target and destination resource of the span, recording request
+// counts, error counts and the latency distribution of the edge.
+func WithServiceGraphEdges(enabled bool) ConverterOption {
+	return func(c converterConfig) converterConfig {
+		c.serviceGraphEdges = enabled
+		return c
+	}
+}
+
+// WithServiceInstanceDimensions configures EventToCombinedMetrics to add
+// the given dimensions of events to the service instance aggregation key,
+// in addition to the global labels.
+func WithServiceInstanceDimensions(dims ...InstanceDimension) ConverterOption {
+	return func(c converterConfig) converterConfig {
+		c.instanceDimensions = dims
+		return c
+	}
+}
+
+// WithCollapsedServiceInstances configures EventToCombinedMetrics to
+// aggregate all the events of a service into a single service instance
+// with an empty aggregation key, i.e. without keying by global labels or
+// instance dimensions. This reduces the key cardinality for consumers not
+// querying per service instance metrics, the global labels are not added
+// to the converted metrics. Collapsed service instances are not supported
+// with instance dimensions.
+func WithCollapsedServiceInstances(enabled bool) ConverterOption {
+	return func(c converterConfig) converterConfig {
+		c.collapseServiceInstances = enabled
+		return c
+	}
+}
+
+// WithNormalizedSpanResources configures EventToCombinedMetrics to replace
+// the non-empty destination service resource of span events and dropped
+// span stats with the value returned by the given function before building
+// the span aggregation key. The function must be safe for concurrent use.
+func WithNormalizedSpanResources(normalize func(string) string) ConverterOption {
+	return func(c converterConfig) converterConfig {
+		c.normalizeSpanResource = normalize
+		return c
+	}
+}
+
+// WithCustomDimensions configures EventToCombinedMetrics to call the
+// extractor for each event to add custom dimensions to the service,
+// transaction and span aggregation keys. The span custom dimensions of
+// transaction events apply to their dropped spans stats. Each key holds at
+// most MaxCustomDimensions custom dimensions. CombinedMetricsToBatch adds
+// the custom dimensions of the keys as labels to the metrics events. The
+// extractor must be safe for concurrent use.
+func WithCustomDimensions(extractor func(*modelpb.APMEvent, *KeySet)) ConverterOption {
+	return func(c converterConfig) converterConfig {
+		c.keyExtractor = extractor
+		return c
+	}
+}
+
+// WithSpanSubtypeKey configures whether the span subtype is included in
+// the span aggregation key, breaking down service destination metrics per
+// span subtype. Dropped span stats have no subtype and are not affected.
+func WithSpanSubtypeKey(enabled bool) ConverterOption {
+	return func(c converterConfig) converterConfig {
+		c.spanSubtype = enabled
+		return c
+	}
+}
+
+// WithFaasDimensions configures whether the FaaS dimensions, i.e. the
+// faas.coldstart, faas.id, faas.name, faas.version and faas.trigger.type
+// of the event, are included in the transaction aggregation key, breaking
+// down transaction metrics per function for serverless services. The
+// cardinality of FaaS IDs can be limited with Limits.MaxFaasIDsPerService.
+// Defaults to true.
+func WithFaasDimensions(enabled bool) ConverterOption {
+	return func(c converterConfig) converterConfig {
+		c.omitFaasDimensions = !enabled
+		return c
+	}
+}
+
+// WithMobileDimensions configures whether the dimensions of mobile and RUM
+// transactions, i.e. the host.os.name, host.os.version and
+// network.connection.type of the event, are included in the transaction
+// aggregation key, allowing mobile dashboards to be driven by transaction
+// metrics. The app version is the service.version, which is always part of
+// the key. The cardinality of app and OS versions can be limited with
+// Limits.MaxServiceVersionsPerService and Limits.MaxHostOSVersionsPerService.
+func WithMobileDimensions(enabled bool) ConverterOption {
+	return func(c converterConfig) converterConfig {
+		c.mobileDimensions = enabled
+		return c
+	}
+}
+
+// WithKubernetesDimensions configures whether the Kubernetes dimensions,
+// i.e. the kubernetes.namespace and the deployment name of the event, are
+// included in the service aggregation key, allowing metrics to be rolled
+// up per namespace and deployment without adding them to the service
+// instance key as global labels. The deployment name is read from the
+// KubernetesDeploymentLabel label, as events carry no dedicated field for
+// it, and is added back as a label to the metrics. The number of
+// namespace and deployment combinations per service can be limited with
+// Limits.MaxKubernetesWorkloadsPerService.
+func WithKubernetesDimensions(enabled bool) ConverterOption {
+	return func(c converterConfig) converterConfig {
+		c.kubernetesDimensions = enabled
+		return c
+	}
+}
+
+// WithPartitionFunc configures the function assigning the metrics of an
+// event to the partitions in EventToCombinedMetrics. The function is
+// called with the hash of the aggregation key of every metric and returns
+// its partition ID, which is taken modulo the number of partitions.
+// Defaults to nil, i.e. the hash modulo the number of partitions.
+func WithPartitionFunc(partitioner func(hash uint64) uint16) ConverterOption {
+	return func(c converterConfig) converterConfig {
+		c.partitioner = partitioner
+		return c
+	}
+}
+
+// WithCanonicalServiceNames configures EventToCombinedMetrics to replace
+// the service name of events found in the given map with the mapped
+// canonical service name before building the service aggregation key.
+func WithCanonicalServiceNames(aliases map[string]string) ConverterOption {
+	return func(c converterConfig) converterConfig {
+		c.serviceNameAliases = aliases
+		return c
+	}
+}
+
+// WithDocumentIDs configures CombinedMetricsToBatch to attach a
+// deterministic document ID to each event as the DocumentIDLabel label.
+// The document ID is derived from the aggregation key, the aggregation
+// interval and the processing time of the metrics, so that harvesting the
+// same aggregation bucket again results in the same document ID. This
+// allows sinks to upsert documents, making at-least-once delivery of
+// harvested metrics idempotent.
+func WithDocumentIDs(enabled bool) ConverterOption {
+	return func(c converterConfig) converterConfig {
+		c.documentIDs = enabled
+		return c
+	}
+}
+
+// WithServiceSummaryIntervals configures CombinedMetricsToBatch to emit
+// service_summary metrics only for the given aggregation intervals, for
+// example only for the shortest configured interval. Service summary
+// metrics carry no counters, so emitting them for a single interval
+// avoids redundant documents. Metrics converted for other intervals omit
+// the service_summary metricset, all other metricsets are unaffected.
+// By default, service_summary metrics are emitted for all intervals.
+func WithServiceSummaryIntervals(ivls ...time.Duration) ConverterOption {
+	return func(c converterConfig) converterConfig {
+		c.serviceSummaryIntervals = ivls
+		return c
+	}
+}
+
+// WithIntervalMetricsets configures CombinedMetricsToBatch to emit only
+// the given metricsets for the given aggregation intervals, for example
+// service_destination metrics only for 1m while emitting transaction
+// metrics for all intervals. The events of other metricsets, including
+// their overflow events, are not generated for the interval. Metrics
+// converted for intervals missing from the map emit all metricsets.
+// WithServiceSummaryIntervals additionally restricts the intervals
+// emitting service_summary metrics.
+func WithIntervalMetricsets(metricsets map[time.Duration][]MetricsetType) ConverterOption {
+	return func(c converterConfig) converterConfig {
+		c.intervalMetricsets = metricsets
+		return c
+	}
+}
+
+// WithMetricsetNames configures CombinedMetricsToBatch to rename the
+// metricsets of the converted events, for consumers with index templates
+// expecting different names. The names map the default metricset names,
+// i.e. transaction, service_transaction, service_destination,
+// service_summary, service_error and service_graph_edge, to the names to
+// use instead. Document IDs, see WithDocumentIDs, are derived from the
+// default names and are not affected by renaming.
+func WithMetricsetNames(names map[string]string) ConverterOption {
+	return func(c converterConfig) converterConfig {
+		c.metricsetNames = names
+		return c
+	}
+}
+
+// WithSuppressedMetricsets configures CombinedMetricsToBatch to omit the
+// events of the given metricsets, identified by their default names, see
+// WithMetricsetNames. By default, the events of all metricsets are emitted.
+func WithSuppressedMetricsets(names ...string) ConverterOption {
+	return func(c converterConfig) converterConfig {
+		c.suppressedMetricsets = names
+		return c
+	}
+}
+
+// WithIntervalFormat configures the function formatting the aggregation
+// interval set as the metricset interval of the converted events and used
+// in document IDs. By default, intervals of at least a minute are
+// formatted in minutes, e.g. `1m` or `60m`, and shorter intervals in
+// seconds, e.g. `10s`.
+func WithIntervalFormat(format func(time.Duration) string) ConverterOption {
+	return func(c converterConfig) converterConfig {
+		c.intervalFormat = format
+		return c
+	}
+}
+
+// WithMaxOutputBatchSize configures CombinedMetricsToBatches to split the
+// converted events into batches of at most maxEvents events and maxBytes
+// protobuf encoded bytes, keeping the downstream bulk requests within the
+// limits of the output. An event larger than maxBytes is emitted in its own
+// batch. A zero limit disables the respective limit. By default, all the
+// events are emitted in a single batch.
+func WithMaxOutputBatchSize(maxEvents, maxBytes int) ConverterOption {
+	return func(c converterConfig) converterConfig {
+		c.maxBatchEvents = maxEvents
+		c.maxBatchBytes = maxBytes
+		return c
+	}
+}
+
+func newConverterConfig(opts ...ConverterOption) (converterConfig, error) {
+	var cfg converterConfig
+	for _, opt := range opts {
+		cfg = opt(cfg)
+	}
+	for _, p := range cfg.percentiles {
+		if !(p > 0 && p <= 100) {
+			return cfg, fmt.Errorf("percentile %v must be in the range (0, 100]", p)
+		}
+	}
+	if cfg.globalLabelsHashThreshold < 0 {
+		return cfg, errors.New("global labels hash threshold must not be negative")
+	}
+	for _, keys := range [][]string{cfg.globalLabelsAllow, cfg.globalLabelsDeny} {
+		for _, k := range keys {
+			if k == "" {
+				return cfg, errors.New("global label filter keys must not be empty")
+			}
+		}
+	}
+	if cfg.histogramImpl > DDSketchImpl {
+		return cfg, fmt.Errorf("unsupported histogram implementation %d", cfg.histogramImpl)
+	}
+	if cfg.durationSumEstimate > RecordedSumEstimate {
+		return cfg, fmt.Errorf("unsupported duration sum estimate %d", cfg.durationSumEstimate)
+	}
+	if p := cfg.histogramPrecision; p != (histogramPrecision{}) {
+		if p.significantFigures < hdrhistogram.MinSignificantFigures ||
+			p.significantFigures > hdrhistogram.MaxSignificantFigures {
+			return cfg, fmt.Errorf(
+				"histogram significant figures must be between %d and %d",
+				hdrhistogram.MinSignificantFigures, hdrhistogram.MaxSignificantFigures,
+			)
+		}
+		if p.highestTrackableValue < time.Millisecond.Microseconds() {
+			return cfg, errors.New("histogram max duration must be at least 1ms")
+		}
+	}
+	for _, ivl := range cfg.serviceSummaryIntervals {
+		if ivl <= 0 {
+			return cfg, fmt.Errorf("service summary interval %s must be positive", ivl)
+		}
+	}
+	for ivl, types := range cfg.intervalMetricsets {
+		if ivl <= 0 {
+			return cfg, fmt.Errorf("metricsets interval %s must be positive", ivl)
+		}
+		for _, t := range types {
+			if t > ServiceGraphEdgeMetricset {
+				return cfg, fmt.Errorf("unsupported metricset type %d", t)
+			}
+		}
+	}
+	for _, dim := range cfg.instanceDimensions {
+		if dim < HostNameDimension || dim > KubernetesPodNameDimension {
+			return cfg, fmt.Errorf("unsupported instance dimension %d", dim)
+		}
+	}
+	if cfg.collapseServiceInstances && len(cfg.instanceDimensions) > 0 {
+		return cfg, errors.New("instance dimensions are not supported with collapsed service instances")
+	}
+	for name, rename := range cfg.metricsetNames {
+		if !isMetricsetName(name) {
+			return cfg, fmt.Errorf("unknown metricset %q", name)
+		}
+		if rename == "" {
+			return cfg, fmt.Errorf("metricset name for %q must not be empty", name)
+		}
+	}
+	for _, name := range cfg.suppressedMetricsets {
+		if !isMetricsetName(name) {
+			return cfg, fmt.Errorf("unknown metricset %q", name)
+		}
+	}
+	if cfg.temporality > CumulativeTemporality {
+		return cfg, fmt.Errorf("unsupported temporality %d", cfg.temporality)
+	}
+	if cfg.temporality == CumulativeTemporality && cfg.cumulativeCounters == nil {
+		return cfg, errors.New("cumulative temporality requires cumulative counters")
+	}
+	if cfg.maxBatchEvents < 0 {
+		return cfg, errors.New("max output batch events must not be negative")
+	}
+	if cfg.maxBatchBytes < 0 {
+		return cfg, errors.New("max output batch bytes must not be negative")
+	}
+	if cfg.intervalFormat == nil {
+		cfg.intervalFormat = formatDuration
+	}
+	return cfg, nil
+}
+
+// WithConfigFingerprint configures CombinedMetricsToBatch to attach the
+// given aggregation configuration fingerprint, typically
+// Aggregator.ConfigFingerprint, to each event as the ConfigFingerprintLabel
+// label. This allows consumers to detect when the semantics of the metrics
+// changed due to a configuration rollout. By default, no fingerprint is
+// attached.
+func WithConfigFingerprint(fingerprint string) ConverterOption {
+	return func(c converterConfig) converterConfig {
+		c.configFingerprint = fingerprint
+		return c
+	}
+}
+
+// WithTemporality configures CombinedMetricsToBatch to declare the
+// temporality of the emitted counters as the TemporalityLabel label.
+// Harvested metrics are delta metrics, reporting the counts of a single
+// aggregation interval. With CumulativeTemporality, the counters are
+// instead accumulated across harvests in the given counters, which must be
+// reused for all conversions of the same aggregated metrics. Cumulative
+// counters are keyed by the aggregation keys of the metrics, the metricset
+// name and the aggregation interval, so a separate CumulativeCounters
+// should be used per CombinedMetricsKey ID. By default, the temporality is
+// not declared and counters are delta counters.
+func WithTemporality(temporality Temporality, counters *CumulativeCounters) ConverterOption {
+	return func(c converterConfig) converterConfig {
+		c.temporality = temporality
+		c.cumulativeCounters = nil
+		if temporality == CumulativeTemporality {
+			c.cumulativeCounters = counters
+		}
+		return c
+	}
+}
+
+// isMetricsetName returns true if name is the default name of a metricset
+// emitted by CombinedMetricsToBatch.
+func isMetricsetName(name string) bool {
+	switch name {
+	case spanMetricsetName, txnMetricsetName, svcTxnMetricsetName,
+		summaryMetricsetName, errorMetricsetName, serviceGraphEdgeMetricsetName:
+		return true
+	}
+	return false
+}
+
+// renameMetricsets applies the configured metricset names and suppressed
+// metricsets to the converted events. Suppressed events are released back
+// to the pool.
+func (c converterConfig) renameMetricsets(b modelpb.Batch) modelpb.Batch {
+	if len(c.metricsetNames) == 0 && len(c.suppressedMetricsets) == 0 {
+		return b
+	}
+	out := b[:0]
+	for _, e := range b {
+		name := e.GetMetricset().GetName()
+		if slices.Contains(c.suppressedMetricsets, name) {
+			e.ReturnToVTPool()
+			continue
+		}
+		if rename, ok := c.metricsetNames[name]; ok {
+			e.Metricset.Name = rename
+		}
+		out = append(out, e)
+	}
+	for i := len(out); i < len(b); i++ {
+		b[i] = nil
+	}
+	return out
+}
+
+// setInstanceDimensions sets the configured instance dimensions of the
+// event on the service instance aggregation key.
+func (c converterConfig) setInstanceDimensions(
+	e *modelpb.APMEvent,
+	key *aggregationpb.ServiceInstanceAggregationKey,
+) {
+	for _, dim := range c.instanceDimensions {
+		switch dim {
+		case HostNameDimension:
+			key.HostName = e.GetHost().GetName()
+		case ContainerIDDimension:
+			key.ContainerId = e.GetContainer().GetId()
+		case KubernetesPodNameDimension:
+			key.KubernetesPodName = e.GetKubernetes().GetPodName()
+		}
+	}
+}
+
+// serviceName returns the canonical service name for the event.
+func (c converterConfig) serviceName(e *modelpb.APMEvent) string {
+	name := e.GetService().GetName()
+	if canonical, ok := c.serviceNameAliases[name]; ok {
+		return canonical
+	}
+	return name
+}
+
+// handleExemplars calls the configured exemplar handler, if any, for the
+// event and its exemplars.
+func (c converterConfig) handleExemplars(e *modelpb.APMEvent, exemplars []*aggregationpb.Exemplar) {
+	if c.exemplarHandler == nil || len(exemplars) == 0 {
+		return
+	}
+	c.exemplarHandler(e, exemplars)
+}
+
+// globalLabelFilter returns a function reporting whether the global label
+// with the given key is included in the service instance key, or nil if
+// all global labels are included.
+func (c converterConfig) globalLabelFilter() func(string) bool {
+	if len(c.globalLabelsAllow) == 0 && len(c.globalLabelsDeny) == 0 {
+		return nil
+	}
+	return func(k string) bool {
+		if len(c.globalLabelsAllow) > 0 && !slices.Contains(c.globalLabelsAllow, k) {
+			return false
+		}
+		return !slices.Contains(c.globalLabelsDeny, k)
+	}
+}
+
+// emitServiceSummary returns true if service_summary metrics should be
+// emitted for the given aggregation interval.
+func (c converterConfig) emitServiceSummary(aggInterval time.Duration) bool {
+	if len(c.serviceSummaryIntervals) == 0 {
+		return true
+	}
+	for _, ivl := range c.serviceSummaryIntervals {
+		if ivl == aggInterval {
+			return true
+		}
+	}
+	return false
+}
+
+// metricsets returns the metricsets to emit for the given aggregation
+// interval.
+func (c converterConfig) metricsets(aggInterval time.Duration) metricsetTypes {
+	types, ok := c.intervalMetricsets[aggInterval]
+	if !ok {
+		return allMetricsetTypes
+	}
+	var m metricsetTypes
+	for _, t := range types {
+		m |= 1 << t
+	}
+	return m
+}
+
 var (
 	partitionedMetricsBuilderPool sync.Pool
 	eventMetricsBuilderPool       sync.Pool
@@ -43,9 +789,34 @@ var (
 // sets of metrics from an event.
 type partitionedMetricsBuilder struct {
 	partitions          uint16
+	partitioner         func(uint64) uint16
+	histogramImpl       HistogramImpl
+	histogramPrecision  histogramPrecision
+	recordDurationSum   bool
+	durationExtremes    bool
+	exemplars           bool
+	errors              bool
+	normalizeResource   func(string) string
+	spanSubtype         bool
+	omitFaasDimensions  bool
+	mobileDimensions    bool
+	txnDimensions       []byte
+	spanDimensions      []byte
+	serviceGraphEdges   bool
 	serviceInstanceHash xxhash.Digest
 	builders            []*eventMetricsBuilder // partitioned metrics
 
+	// The keys of the metrics of the event are built in place before
+	// the partition of the metrics, given by the hash of the key, is
+	// known. They are held by the partitioned builder rather than the
+	// event metrics builders of the partitions, which point to them.
+	transactionAggregationKey        aggregationpb.TransactionAggregationKey
+	serviceTransactionAggregationKey aggregationpb.ServiceTransactionAggregationKey
+	spanAggregationKey               [128]aggregationpb.SpanAggregationKey
+	spanAggregationKeys              int
+	errorAggregationKey              aggregationpb.ErrorAggregationKey
+	serviceGraphEdgeAggregationKey   aggregationpb.ServiceGraphEdgeAggregationKey
+
 	// Event metrics are for exactly one service instance, so we create an
 	// array of a single element and use that for backing the slice in
 	// ServiceMetrics.
@@ -67,11 +838,10 @@ type partitionedMetricsBuilder struct {
 	combinedMetrics aggregationpb.CombinedMetrics
 }
 
-func getPartitionedMetricsBuilder(
-	serviceAggregationKey aggregationpb.ServiceAggregationKey,
-	serviceInstanceAggregationKey aggregationpb.ServiceInstanceAggregationKey,
-	partitions uint16,
-) *partitionedMetricsBuilder {
+// getPartitionedMetricsBuilder returns a builder with empty service and
+// service instance keys, which are set in place by the caller before
+// calling hashServiceInstance.
+func getPartitionedMetricsBuilder(partitions uint16) *partitionedMetricsBuilder {
 	p, ok := partitionedMetricsBuilderPool.Get().(*partitionedMetricsBuilder)
 	if !ok {
 		p = &partitionedMetricsBuilder{}
@@ -84,14 +854,21 @@ func getPartitionedMetricsBuilder(
 		p.keyedServiceMetricsArray[0] = &p.keyedServiceMetrics
 		p.combinedMetrics.ServiceMetrics = p.keyedServiceMetricsArray[:]
 	}
-	p.serviceAggregationKey = serviceAggregationKey
-	p.serviceInstanceAggregationKey = serviceInstanceAggregationKey
+	// Explicitly reset the keys, as for the event metrics builders.
+	p.serviceAggregationKey = aggregationpb.ServiceAggregationKey{}
+	p.serviceInstanceAggregationKey = aggregationpb.ServiceInstanceAggregationKey{}
+	p.spanAggregationKeys = 0
+	p.partitions = partitions
+	return p
+}
+
+// hashServiceInstance hashes the service and service instance keys set
+// by the caller, for hashing the keys of the metrics of the event.
+func (p *partitionedMetricsBuilder) hashServiceInstance() {
 	p.serviceInstanceHash = protohash.HashServiceInstanceAggregationKey(
 		protohash.HashServiceAggregationKey(xxhash.Digest{}, &p.serviceAggregationKey),
 		&p.serviceInstanceAggregationKey,
 	)
-	p.partitions = partitions
-	return p
 }
 
 // release releases all partitioned builders back to their pools.
@@ -128,6 +905,15 @@ func (p *partitionedMetricsBuilder) processEvent(e *modelpb.APMEvent) {
 			return
 		}
 		p.addSpanMetrics(e, repCount)
+		if p.serviceGraphEdges {
+			p.addServiceGraphEdgeMetrics(e, repCount)
+		}
+	case modelpb.ErrorEventType:
+		if !p.errors {
+			p.addServiceSummaryMetrics()
+			return
+		}
+		p.addErrorMetrics(e)
 	default:
 		// All other event types should add an empty service metrics,
 		// for adding to service summary metrics.
@@ -136,36 +922,66 @@ func (p *partitionedMetricsBuilder) processEvent(e *modelpb.APMEvent) {
 }
 
 func (p *partitionedMetricsBuilder) addTransactionMetrics(e *modelpb.APMEvent, count float64, duration time.Duration) {
-	var key aggregationpb.TransactionAggregationKey
-	setTransactionKey(e, &key)
-	hash := protohash.HashTransactionAggregationKey(p.serviceInstanceHash, &key)
+	key := &p.transactionAggregationKey
+	*key = aggregationpb.TransactionAggregationKey{}
+	setTransactionKey(e, key)
+	if p.omitFaasDimensions {
+		key.FaasColdstart = uint32(nullable.Nil)
+		key.FaasId = ""
+		key.FaasName = ""
+		key.FaasVersion = ""
+		key.FaasTriggerType = ""
+	}
+	if p.mobileDimensions {
+		key.HostOsName = e.GetHost().GetOs().GetName()
+		key.HostOsVersion = e.GetHost().GetOs().GetVersion()
+		key.NetworkConnectionType = e.GetNetwork().GetConnection().GetType()
+	}
+	key.CustomDimensions = p.txnDimensions
+	hash := protohash.HashTransactionAggregationKey(p.serviceInstanceHash, key)
 
 	mb := p.get(hash)
-	mb.transactionAggregationKey = key
+	mb.keyedTransactionMetrics.Key = key
 
-	hdr := hdrhistogram.New()
-	hdr.RecordDuration(duration, count)
-	setHistogramProto(hdr, &mb.transactionHistogram)
-	mb.transactionMetrics.Histogram = &mb.transactionHistogram
+	mb.recordDuration(p.histogramImpl, p.histogramPrecision, duration, count)
+	mb.transactionMetrics.Histogram, mb.transactionMetrics.DdSketch, mb.transactionMetrics.TDigest =
+		mb.durationDistribution(p.histogramImpl)
+	if p.recordDurationSum {
+		mb.transactionMetrics.Sum = count * float64(duration) / float64(time.Microsecond)
+	}
+	if p.durationExtremes {
+		mb.transactionMetrics.Min = float64(duration.Microseconds())
+		mb.transactionMetrics.Max = mb.transactionMetrics.Min
+	}
+	if p.exemplars && setExemplar(e, e.GetTransaction().GetId(), &mb.transactionExemplar) {
+		mb.transactionMetrics.Exemplars = mb.transactionExemplarArray[:]
+	}
 	mb.keyedTransactionMetricsSlice = mb.keyedTransactionMetricsArray[:]
 }
 
 func (p *partitionedMetricsBuilder) addServiceTransactionMetrics(e *modelpb.APMEvent, count float64, duration time.Duration) {
-	var key aggregationpb.ServiceTransactionAggregationKey
-	setServiceTransactionKey(e, &key)
-	hash := protohash.HashServiceTransactionAggregationKey(p.serviceInstanceHash, &key)
+	key := &p.serviceTransactionAggregationKey
+	*key = aggregationpb.ServiceTransactionAggregationKey{}
+	setServiceTransactionKey(e, key)
+	hash := protohash.HashServiceTransactionAggregationKey(p.serviceInstanceHash, key)
 
 	mb := p.get(hash)
-	mb.serviceTransactionAggregationKey = key
+	mb.keyedServiceTransactionMetrics.Key = key
 
-	if mb.transactionMetrics.Histogram == nil {
-		// mb.TransactionMetrics.Histogram will be set if the event's
+	if !mb.durationRecorded {
+		// The duration will already be recorded if the event's
 		// transaction metric ended up in the same partition.
-		hdr := hdrhistogram.New()
-		hdr.RecordDuration(duration, count)
-		setHistogramProto(hdr, &mb.transactionHistogram)
+		mb.recordDuration(p.histogramImpl, p.histogramPrecision, duration, count)
+	}
+	mb.serviceTransactionMetrics.Histogram, mb.serviceTransactionMetrics.DdSketch, mb.serviceTransactionMetrics.TDigest =
+		mb.durationDistribution(p.histogramImpl)
+	if p.recordDurationSum {
+		mb.serviceTransactionMetrics.Sum = count * float64(duration) / float64(time.Microsecond)
+	}
+	if p.durationExtremes {
+		mb.serviceTransactionMetrics.Min = float64(duration.Microseconds())
+		mb.serviceTransactionMetrics.Max = mb.serviceTransactionMetrics.Min
 	}
-	mb.serviceTransactionMetrics.Histogram = &mb.transactionHistogram
 	switch e.GetEvent().GetOutcome() {
 	case "failure":
 		mb.serviceTransactionMetrics.SuccessCount = 0
@@ -181,40 +997,96 @@ func (p *partitionedMetricsBuilder) addServiceTransactionMetrics(e *modelpb.APME
 }
 
 func (p *partitionedMetricsBuilder) addDroppedSpanStatsMetrics(dss *modelpb.DroppedSpanStats, repCount float64) {
-	var key aggregationpb.SpanAggregationKey
-	setDroppedSpanStatsKey(dss, &key)
-	hash := protohash.HashSpanAggregationKey(p.serviceInstanceHash, &key)
-
-	mb := p.get(hash)
-	i := len(mb.keyedSpanMetricsSlice)
-	if i == len(mb.keyedSpanMetricsArray) {
+	if p.spanAggregationKeys == len(p.spanAggregationKey) {
 		// No more capacity. The spec says that when 128 dropped span
 		// stats entries are reached, then any remaining entries will
 		// be silently discarded.
 		return
 	}
+	key := p.nextSpanAggregationKey()
+	setDroppedSpanStatsKey(dss, key)
+	key.Resource = p.normalizeSpanResource(key.Resource)
+	key.CustomDimensions = p.spanDimensions
+	hash := protohash.HashSpanAggregationKey(p.serviceInstanceHash, key)
 
-	mb.spanAggregationKey[i] = key
+	mb := p.get(hash)
+	i := len(mb.keyedSpanMetricsSlice)
 	setDroppedSpanStatsMetrics(dss, repCount, &mb.spanMetrics[i])
-	mb.keyedSpanMetrics[i].Key = &mb.spanAggregationKey[i]
+	mb.keyedSpanMetrics[i].Key = key
 	mb.keyedSpanMetrics[i].Metrics = &mb.spanMetrics[i]
 	mb.keyedSpanMetricsSlice = append(mb.keyedSpanMetricsSlice, &mb.keyedSpanMetrics[i])
 }
 
 func (p *partitionedMetricsBuilder) addSpanMetrics(e *modelpb.APMEvent, repCount float64) {
-	var key aggregationpb.SpanAggregationKey
-	setSpanKey(e, &key)
-	hash := protohash.HashSpanAggregationKey(p.serviceInstanceHash, &key)
+	key := p.nextSpanAggregationKey()
+	setSpanKey(e, key)
+	if p.spanSubtype {
+		key.SpanSubtype = e.GetSpan().GetSubtype()
+	}
+	key.Resource = p.normalizeSpanResource(key.Resource)
+	key.CustomDimensions = p.spanDimensions
+	hash := protohash.HashSpanAggregationKey(p.serviceInstanceHash, key)
 
 	mb := p.get(hash)
 	i := len(mb.keyedSpanMetricsSlice)
-	mb.spanAggregationKey[i] = key
 	setSpanMetrics(e, repCount, &mb.spanMetrics[i])
-	mb.keyedSpanMetrics[i].Key = &mb.spanAggregationKey[i]
+	if p.exemplars && setExemplar(e, e.GetSpan().GetId(), &mb.spanExemplar) {
+		mb.spanMetrics[i].Exemplars = mb.spanExemplarArray[:]
+	}
+	mb.keyedSpanMetrics[i].Key = key
 	mb.keyedSpanMetrics[i].Metrics = &mb.spanMetrics[i]
 	mb.keyedSpanMetricsSlice = append(mb.keyedSpanMetricsSlice, &mb.keyedSpanMetrics[i])
 }
 
+func (p *partitionedMetricsBuilder) addErrorMetrics(e *modelpb.APMEvent) {
+	key := &p.errorAggregationKey
+	*key = aggregationpb.ErrorAggregationKey{}
+	setErrorKey(e, key)
+	hash := protohash.HashErrorAggregationKey(p.serviceInstanceHash, key)
+
+	mb := p.get(hash)
+	mb.keyedErrorMetrics.Key = key
+	mb.errorMetrics.Count = 1
+	mb.keyedErrorMetricsSlice = mb.keyedErrorMetricsArray[:]
+}
+
+func (p *partitionedMetricsBuilder) addServiceGraphEdgeMetrics(e *modelpb.APMEvent, repCount float64) {
+	key := &p.serviceGraphEdgeAggregationKey
+	*key = aggregationpb.ServiceGraphEdgeAggregationKey{}
+	setServiceGraphEdgeKey(e, key)
+	key.Resource = p.normalizeSpanResource(key.Resource)
+	hash := protohash.HashServiceGraphEdgeAggregationKey(p.serviceInstanceHash, key)
+
+	count := repCount
+	duration := e.GetEvent().GetDuration().AsDuration()
+	if composite := e.GetSpan().GetComposite(); composite != nil && composite.GetCount() > 0 {
+		// Record the average duration of the composite span for each of
+		// the spans it represents.
+		count *= float64(composite.GetCount())
+		duration = time.Duration(composite.GetSum() * float64(time.Millisecond) / float64(composite.GetCount()))
+	}
+
+	mb := p.get(hash)
+	mb.keyedServiceGraphEdgeMetrics.Key = key
+	mb.serviceGraphEdgeMetrics.Count = count
+	if e.GetEvent().GetOutcome() == "failure" {
+		mb.serviceGraphEdgeMetrics.ErrorCount = count
+	}
+	mb.recordDuration(p.histogramImpl, p.histogramPrecision, duration, count)
+	mb.serviceGraphEdgeMetrics.Histogram, mb.serviceGraphEdgeMetrics.DdSketch, mb.serviceGraphEdgeMetrics.TDigest =
+		mb.durationDistribution(p.histogramImpl)
+	mb.keyedServiceGraphEdgeMetricsSlice = mb.keyedServiceGraphEdgeMetricsArray[:]
+}
+
+// normalizeSpanResource returns the destination service resource normalized
+// using the configured function, if any.
+func (p *partitionedMetricsBuilder) normalizeSpanResource(resource string) string {
+	if p.normalizeResource == nil || resource == "" {
+		return resource
+	}
+	return p.normalizeResource(resource)
+}
+
 func (p *partitionedMetricsBuilder) addServiceSummaryMetrics() {
 	// There are no actual metric values, we're just want to
 	// create documents for the dimensions, so we can build a
@@ -222,8 +1094,21 @@ func (p *partitionedMetricsBuilder) addServiceSummaryMetrics() {
 	_ = p.get(p.serviceInstanceHash)
 }
 
+// nextSpanAggregationKey returns the next empty span key of the event.
+func (p *partitionedMetricsBuilder) nextSpanAggregationKey() *aggregationpb.SpanAggregationKey {
+	key := &p.spanAggregationKey[p.spanAggregationKeys]
+	*key = aggregationpb.SpanAggregationKey{}
+	p.spanAggregationKeys++
+	return key
+}
+
 func (p *partitionedMetricsBuilder) get(h xxhash.Digest) *eventMetricsBuilder {
-	partition := uint16(h.Sum64() % uint64(p.partitions))
+	var partition uint16
+	if p.partitioner != nil {
+		partition = p.partitioner(h.Sum64()) % p.partitions
+	} else {
+		partition = uint16(h.Sum64() % uint64(p.partitions))
+	}
 	for _, mb := range p.builders {
 		if mb.partition == partition {
 			return mb
@@ -256,15 +1141,27 @@ type eventMetricsBuilder struct {
 	transactionHistogramBuckets           [1]int32
 	transactionHistogram                  aggregationpb.HDRHistogram
 
-	// There can be at most 1 transaction metric per event.
-	transactionAggregationKey    aggregationpb.TransactionAggregationKey
+	// Single-valued sketches, used instead of the histogram depending on
+	// the configured HistogramImpl.
+	transactionDDSketch aggregationpb.DDSketch
+	transactionTDigest  aggregationpb.TDigest
+	durationRecorded    bool
+
+	// Preallocate space for a single exemplar of the transaction and span
+	// event. There is at most 1 transaction or span event per event.
+	transactionExemplar      aggregationpb.Exemplar
+	transactionExemplarArray [1]*aggregationpb.Exemplar
+	spanExemplar             aggregationpb.Exemplar
+	spanExemplarArray        [1]*aggregationpb.Exemplar
+
+	// There can be at most 1 transaction metric per event. The keys of
+	// the metrics are held by the partitioned metrics builder.
 	transactionMetrics           aggregationpb.TransactionMetrics
 	keyedTransactionMetrics      aggregationpb.KeyedTransactionMetrics
 	keyedTransactionMetricsArray [1]*aggregationpb.KeyedTransactionMetrics
 	keyedTransactionMetricsSlice []*aggregationpb.KeyedTransactionMetrics
 
 	// There can be at most 1 service transaction metric per event.
-	serviceTransactionAggregationKey    aggregationpb.ServiceTransactionAggregationKey
 	serviceTransactionMetrics           aggregationpb.ServiceTransactionMetrics
 	keyedServiceTransactionMetrics      aggregationpb.KeyedServiceTransactionMetrics
 	keyedServiceTransactionMetricsArray [1]*aggregationpb.KeyedServiceTransactionMetrics
@@ -275,11 +1172,24 @@ type eventMetricsBuilder struct {
 	// - at most 128 (dropped span stats) for a transaction event (1)
 	//
 	// (1) https://github.com/elastic/apm/blob/main/specs/agents/handling-huge-traces/tracing-spans-dropped-stats.md#limits
-	spanAggregationKey    [128]aggregationpb.SpanAggregationKey
 	spanMetrics           [128]aggregationpb.SpanMetrics
 	keyedSpanMetrics      [128]aggregationpb.KeyedSpanMetrics
 	keyedSpanMetricsArray [128]*aggregationpb.KeyedSpanMetrics
 	keyedSpanMetricsSlice []*aggregationpb.KeyedSpanMetrics
+
+	// There can be at most 1 error metric per event.
+	errorMetrics           aggregationpb.ErrorMetrics
+	keyedErrorMetrics      aggregationpb.KeyedErrorMetrics
+	keyedErrorMetricsArray [1]*aggregationpb.KeyedErrorMetrics
+	keyedErrorMetricsSlice []*aggregationpb.KeyedErrorMetrics
+
+	// There can be at most 1 service graph edge metric per event. The
+	// edge records its latency in the single-valued histogram, which is
+	// not otherwise used for span events.
+	serviceGraphEdgeMetrics           aggregationpb.ServiceGraphEdgeMetrics
+	keyedServiceGraphEdgeMetrics      aggregationpb.KeyedServiceGraphEdgeMetrics
+	keyedServiceGraphEdgeMetricsArray [1]*aggregationpb.KeyedServiceGraphEdgeMetrics
+	keyedServiceGraphEdgeMetricsSlice []*aggregationpb.KeyedServiceGraphEdgeMetrics
 }
 
 func getEventMetricsBuilder(partition uint16) *eventMetricsBuilder {
@@ -290,13 +1200,18 @@ func getEventMetricsBuilder(partition uint16) *eventMetricsBuilder {
 		// additional protobuf specfic resetting logic implemented by `Reset`.
 		mb.serviceTransactionMetrics = aggregationpb.ServiceTransactionMetrics{}
 		mb.transactionMetrics = aggregationpb.TransactionMetrics{}
+		mb.errorMetrics = aggregationpb.ErrorMetrics{}
+		mb.serviceGraphEdgeMetrics = aggregationpb.ServiceGraphEdgeMetrics{}
 		for i := range mb.spanMetrics {
 			mb.spanMetrics[i] = aggregationpb.SpanMetrics{}
 		}
 		mb.transactionHDRHistogramRepresentation.CountsRep.Reset()
+		mb.durationRecorded = false
 		mb.keyedServiceTransactionMetricsSlice = mb.keyedServiceTransactionMetricsSlice[:0]
 		mb.keyedTransactionMetricsSlice = mb.keyedTransactionMetricsSlice[:0]
 		mb.keyedSpanMetricsSlice = mb.keyedSpanMetricsSlice[:0]
+		mb.keyedErrorMetricsSlice = mb.keyedErrorMetricsSlice[:0]
+		mb.keyedServiceGraphEdgeMetricsSlice = mb.keyedServiceGraphEdgeMetricsSlice[:0]
 		return mb
 	}
 	mb = &eventMetricsBuilder{partition: partition}
@@ -304,18 +1219,68 @@ func getEventMetricsBuilder(partition uint16) *eventMetricsBuilder {
 	mb.transactionHistogram.Counts = mb.transactionHistogramCounts[:0]
 	mb.transactionHistogram.Buckets = mb.transactionHistogramBuckets[:0]
 	mb.transactionMetrics.Histogram = nil
-	mb.keyedTransactionMetrics.Key = &mb.transactionAggregationKey
+	mb.transactionExemplarArray[0] = &mb.transactionExemplar
+	mb.spanExemplarArray[0] = &mb.spanExemplar
 	mb.keyedTransactionMetrics.Metrics = &mb.transactionMetrics
 	mb.keyedTransactionMetricsArray[0] = &mb.keyedTransactionMetrics
 	mb.keyedTransactionMetricsSlice = mb.keyedTransactionMetricsArray[:0]
-	mb.keyedServiceTransactionMetrics.Key = &mb.serviceTransactionAggregationKey
 	mb.keyedServiceTransactionMetrics.Metrics = &mb.serviceTransactionMetrics
 	mb.keyedServiceTransactionMetricsArray[0] = &mb.keyedServiceTransactionMetrics
 	mb.keyedServiceTransactionMetricsSlice = mb.keyedServiceTransactionMetricsArray[:0]
 	mb.keyedSpanMetricsSlice = mb.keyedSpanMetricsArray[:0]
+	mb.keyedErrorMetrics.Metrics = &mb.errorMetrics
+	mb.keyedErrorMetricsArray[0] = &mb.keyedErrorMetrics
+	mb.keyedErrorMetricsSlice = mb.keyedErrorMetricsArray[:0]
+	mb.keyedServiceGraphEdgeMetrics.Metrics = &mb.serviceGraphEdgeMetrics
+	mb.keyedServiceGraphEdgeMetricsArray[0] = &mb.keyedServiceGraphEdgeMetrics
+	mb.keyedServiceGraphEdgeMetricsSlice = mb.keyedServiceGraphEdgeMetricsArray[:0]
 	return mb
 }
 
+// recordDuration records the duration in the single-valued histogram or
+// sketch of the given implementation.
+func (mb *eventMetricsBuilder) recordDuration(
+	impl HistogramImpl,
+	precision histogramPrecision,
+	duration time.Duration,
+	count float64,
+) {
+	switch impl {
+	case TDigestImpl:
+		td := tdigest.New()
+		td.RecordDuration(duration, count)
+		setTDigestProto(td, &mb.transactionTDigest)
+	case DDSketchImpl:
+		dd := ddsketch.New()
+		dd.RecordDuration(duration, count)
+		setDDSketchProto(dd, &mb.transactionDDSketch)
+	default:
+		hdr := precision.newHistogram()
+		if max := time.Duration(hdr.HighestTrackableValue) * time.Microsecond; duration > max {
+			duration = max
+		}
+		hdr.RecordDuration(duration, count)
+		setHistogramProto(hdr, &mb.transactionHistogram)
+	}
+	mb.durationRecorded = true
+}
+
+// durationDistribution returns the recorded duration distribution for
+// setting on transaction and service transaction metrics. Only the
+// distribution of the given implementation is non-nil.
+func (mb *eventMetricsBuilder) durationDistribution(impl HistogramImpl) (
+	*aggregationpb.HDRHistogram, *aggregationpb.DDSketch, *aggregationpb.TDigest,
+) {
+	switch impl {
+	case TDigestImpl:
+		return nil, nil, &mb.transactionTDigest
+	case DDSketchImpl:
+		return nil, &mb.transactionDDSketch, nil
+	default:
+		return &mb.transactionHistogram, nil, nil
+	}
+}
+
 // release releases the builder back to the pool.
 // Objects will be reset as needed if/when the builder is reacquired.
 func (mb *eventMetricsBuilder) release() {
@@ -336,26 +1301,91 @@ func EventToCombinedMetrics(
 	unpartitionedKey CombinedMetricsKey,
 	partitions uint16,
 	callback func(CombinedMetricsKey, *aggregationpb.CombinedMetrics) error,
+	opts ...ConverterOption,
 ) error {
-	globalLabels, err := marshalEventGlobalLabels(e)
+	cfg, err := newConverterConfig(opts...)
 	if err != nil {
-		return fmt.Errorf("failed to marshal global labels: %w", err)
-	}
-
-	pmb := getPartitionedMetricsBuilder(
-		aggregationpb.ServiceAggregationKey{
-			Timestamp: tspb.TimeToPBTimestamp(
-				e.GetTimestamp().AsTime().Truncate(unpartitionedKey.Interval),
-			),
-			ServiceName:         e.GetService().GetName(),
-			ServiceEnvironment:  e.GetService().GetEnvironment(),
-			ServiceLanguageName: e.GetService().GetLanguage().GetName(),
-			AgentName:           e.GetAgent().GetName(),
-		},
-		aggregationpb.ServiceInstanceAggregationKey{GlobalLabelsStr: globalLabels},
-		partitions,
-	)
+		return fmt.Errorf("invalid converter options: %w", err)
+	}
+	return eventToCombinedMetrics(e, unpartitionedKey, partitions, callback, &cfg)
+}
+
+// eventToCombinedMetrics is EventToCombinedMetrics with a validated
+// converter config, allowing callers on the hot path to reuse the config
+// across events. No allocations are made for events without global labels
+// and custom dimensions, all the intermediate objects are pooled.
+func eventToCombinedMetrics(
+	e *modelpb.APMEvent,
+	unpartitionedKey CombinedMetricsKey,
+	partitions uint16,
+	callback func(CombinedMetricsKey, *aggregationpb.CombinedMetrics) error,
+	cfg *converterConfig,
+) error {
+	var globalLabels []byte
+	var err error
+	if !cfg.collapseServiceInstances {
+		globalLabels, err = marshalGlobalLabels(e.Labels, e.NumericLabels, cfg.globalLabelFilter())
+		if err != nil {
+			return fmt.Errorf("failed to marshal global labels: %w", err)
+		}
+	}
+	var fullGlobalLabels []byte
+	if cfg.globalLabelsHashThreshold > 0 && len(globalLabels) > cfg.globalLabelsHashThreshold {
+		fullGlobalLabels = globalLabels
+		globalLabels = hashGlobalLabels(globalLabels)
+	}
+
+	var svcDimensions, txnDimensions, spanDimensions []byte
+	if cfg.keyExtractor != nil {
+		// The key set escapes to the heap, it is only allocated if
+		// custom dimensions are extracted.
+		keySet := &KeySet{}
+		cfg.keyExtractor(e, keySet)
+		if svcDimensions, err = keySet.Service.marshalBinary(); err != nil {
+			return fmt.Errorf("failed to marshal service custom dimensions: %w", err)
+		}
+		if txnDimensions, err = keySet.Transaction.marshalBinary(); err != nil {
+			return fmt.Errorf("failed to marshal transaction custom dimensions: %w", err)
+		}
+		if spanDimensions, err = keySet.Span.marshalBinary(); err != nil {
+			return fmt.Errorf("failed to marshal span custom dimensions: %w", err)
+		}
+	}
+
+	pmb := getPartitionedMetricsBuilder(partitions)
 	defer pmb.release()
+	sik := &pmb.serviceInstanceAggregationKey
+	sik.GlobalLabelsStr = globalLabels
+	cfg.setInstanceDimensions(e, sik)
+	sk := &pmb.serviceAggregationKey
+	sk.Timestamp = tspb.TimeToPBTimestamp(
+		e.GetTimestamp().AsTime().Truncate(unpartitionedKey.Interval),
+	)
+	sk.ServiceName = cfg.serviceName(e)
+	sk.ServiceEnvironment = e.GetService().GetEnvironment()
+	sk.ServiceLanguageName = e.GetService().GetLanguage().GetName()
+	sk.AgentName = e.GetAgent().GetName()
+	sk.CustomDimensions = svcDimensions
+	if cfg.kubernetesDimensions {
+		sk.KubernetesNamespace = e.GetKubernetes().GetNamespace()
+		sk.KubernetesDeploymentName = e.GetLabels()[KubernetesDeploymentLabel].GetValue()
+	}
+	pmb.hashServiceInstance()
+	pmb.histogramImpl = cfg.histogramImpl
+	pmb.histogramPrecision = cfg.histogramPrecision
+	pmb.recordDurationSum = cfg.durationSumEstimate == RecordedSumEstimate
+	pmb.durationExtremes = cfg.durationExtremes
+	pmb.exemplars = cfg.exemplars
+	pmb.errors = cfg.errors
+	pmb.normalizeResource = cfg.normalizeSpanResource
+	pmb.spanSubtype = cfg.spanSubtype
+	pmb.omitFaasDimensions = cfg.omitFaasDimensions
+	pmb.mobileDimensions = cfg.mobileDimensions
+	pmb.partitioner = cfg.partitioner
+	pmb.txnDimensions = txnDimensions
+	pmb.spanDimensions = spanDimensions
+	pmb.serviceGraphEdges = cfg.serviceGraphEdges
+	pmb.serviceInstanceMetrics.GlobalLabelsStr = fullGlobalLabels
 
 	pmb.processEvent(e)
 	if len(pmb.builders) == 0 {
@@ -375,6 +1405,8 @@ func EventToCombinedMetrics(
 		pmb.serviceInstanceMetrics.TransactionMetrics = mb.keyedTransactionMetricsSlice
 		pmb.serviceInstanceMetrics.ServiceTransactionMetrics = mb.keyedServiceTransactionMetricsSlice
 		pmb.serviceInstanceMetrics.SpanMetrics = mb.keyedSpanMetricsSlice
+		pmb.serviceInstanceMetrics.ErrorMetrics = mb.keyedErrorMetricsSlice
+		pmb.serviceInstanceMetrics.ServiceGraphEdgeMetrics = mb.keyedServiceGraphEdgeMetricsSlice
 		if err := callback(key, &pmb.combinedMetrics); err != nil {
 			errs = append(errs, err)
 		}
@@ -392,11 +1424,25 @@ func CombinedMetricsToBatch(
 	cm *aggregationpb.CombinedMetrics,
 	processingTime time.Time,
 	aggInterval time.Duration,
+	opts ...ConverterOption,
 ) (*modelpb.Batch, error) {
+	cfg, err := newConverterConfig(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid converter options: %w", err)
+	}
 	if cm == nil || len(cm.ServiceMetrics) == 0 {
 		return nil, nil
 	}
 
+	metricsets := cfg.metricsets(aggInterval)
+	emitTxn := metricsets.has(TransactionMetricset)
+	emitSvcTxn := metricsets.has(ServiceTransactionMetricset)
+	emitSpan := metricsets.has(ServiceDestinationMetricset)
+	emitError := metricsets.has(ServiceErrorMetricset)
+	emitGraphEdge := metricsets.has(ServiceGraphEdgeMetricset)
+	emitSummary := metricsets.has(ServiceSummaryMetricset)
+	serviceSummary := emitSummary && cfg.emitServiceSummary(aggInterval)
+
 	var batchSize int
 	// service_summary overflow metric
 	if len(cm.OverflowServiceInstancesEstimator) > 0 {
@@ -410,6 +1456,12 @@ func CombinedMetricsToBatch(
 		if len(cm.OverflowServices.OverflowSpansEstimator) > 0 {
 			batchSize++
 		}
+		if len(cm.OverflowServices.OverflowErrorsEstimator) > 0 {
+			batchSize++
+		}
+		if len(cm.OverflowServices.OverflowServiceGraphEdgesEstimator) > 0 {
+			batchSize++
+		}
 	}
 
 	for _, ksm := range cm.ServiceMetrics {
@@ -419,9 +1471,13 @@ func CombinedMetricsToBatch(
 			batchSize += len(sim.TransactionMetrics)
 			batchSize += len(sim.ServiceTransactionMetrics)
 			batchSize += len(sim.SpanMetrics)
+			batchSize += len(sim.ErrorMetrics)
+			batchSize += len(sim.ServiceGraphEdgeMetrics)
 
 			// Each service instance will create a service summary metric
-			batchSize++
+			if serviceSummary {
+				batchSize++
+			}
 		}
 		if sm.OverflowGroups == nil {
 			continue
@@ -435,95 +1491,255 @@ func CombinedMetricsToBatch(
 		if len(sm.OverflowGroups.OverflowSpansEstimator) > 0 {
 			batchSize++
 		}
+		if len(sm.OverflowGroups.OverflowErrorsEstimator) > 0 {
+			batchSize++
+		}
+		if len(sm.OverflowGroups.OverflowServiceGraphEdgesEstimator) > 0 {
+			batchSize++
+		}
+		if len(sm.OverflowGroups.OverflowGlobalLabelsEstimator) > 0 {
+			batchSize++
+		}
 	}
 
 	b := make(modelpb.Batch, 0, batchSize)
-	aggIntervalStr := formatDuration(aggInterval)
+	aggIntervalStr := cfg.intervalFormat(aggInterval)
+	hashKeys := cfg.documentIDs || cfg.cumulativeCounters != nil
+	var cumulativeKeys map[*modelpb.APMEvent]uint64
+	if cfg.cumulativeCounters != nil {
+		cumulativeKeys = make(map[*modelpb.APMEvent]uint64, batchSize)
+	}
+	// setEventKey identifies the event by the given aggregation key hash
+	// and its metricset name, for the document ID and cumulative counters.
+	setEventKey := func(event *modelpb.APMEvent, h xxhash.Digest) {
+		h.WriteString(event.GetMetricset().GetName())
+		if cfg.documentIDs {
+			setDocumentID(event, h, processingTime, aggIntervalStr)
+		}
+		if cumulativeKeys != nil {
+			h.WriteString(aggIntervalStr)
+			cumulativeKeys[event] = h.Sum64()
+		}
+	}
 	for _, ksm := range cm.ServiceMetrics {
 		sk, sm := ksm.Key, ksm.Metrics
+		var skHash xxhash.Digest
+		if hashKeys {
+			skHash = protohash.HashServiceAggregationKey(xxhash.Digest{}, sk)
+		}
+		svcLabels, err := customDimensionLabels(sk.CustomDimensions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal service custom dimensions: %w", err)
+		}
+		if sk.KubernetesDeploymentName != "" {
+			svcLabels = mergeLabels(svcLabels, modelpb.Labels{
+				KubernetesDeploymentLabel: &modelpb.LabelValue{Value: sk.KubernetesDeploymentName},
+			})
+		}
+		getServiceBaseEvent := func() *modelpb.APMEvent {
+			event := getBaseEvent(sk)
+			event.Labels = svcLabels
+			return event
+		}
 		for _, ksim := range sm.ServiceInstanceMetrics {
 			sik, sim := ksim.Key, ksim.Metrics
+			var sikHash xxhash.Digest
+			if hashKeys {
+				sikHash = protohash.HashServiceInstanceAggregationKey(skHash, sik)
+			}
+			globalLabelsStr := sik.GlobalLabelsStr
+			if len(sim.GlobalLabelsStr) > 0 {
+				globalLabelsStr = sim.GlobalLabelsStr
+			}
 			var gl GlobalLabels
-			err := gl.UnmarshalBinary(sik.GlobalLabelsStr)
+			err := gl.UnmarshalBinary(globalLabelsStr)
 			if err != nil {
 				return nil, fmt.Errorf("failed to unmarshal global labels: %w", err)
 			}
+			labels := gl.Labels
+			if len(svcLabels) > 0 {
+				labels = mergeLabels(gl.Labels, svcLabels)
+			}
 			getBaseEventWithLabels := func() *modelpb.APMEvent {
 				event := getBaseEvent(sk)
-				event.Labels = gl.Labels
+				event.Labels = labels
 				event.NumericLabels = gl.NumericLabels
+				setServiceInstanceDimensions(sik, event)
 				return event
 			}
 
 			// transaction metrics
-			for _, ktm := range sim.TransactionMetrics {
-				event := getBaseEventWithLabels()
-				txnMetricsToAPMEvent(ktm.Key, ktm.Metrics, event, aggIntervalStr)
-				b = append(b, event)
+			if emitTxn {
+				for _, ktm := range sim.TransactionMetrics {
+					event := getBaseEventWithLabels()
+					txnMetricsToAPMEvent(ktm.Key, ktm.Metrics, event, aggIntervalStr, cfg.durationSumEstimate)
+					if err := setCustomDimensionLabels(event, ktm.Key.CustomDimensions); err != nil {
+						return nil, fmt.Errorf("failed to unmarshal transaction custom dimensions: %w", err)
+					}
+					setEventKey(event, protohash.HashTransactionAggregationKey(sikHash, ktm.Key))
+					addDurationPercentiles(event, cfg.percentiles)
+					if cfg.throughput {
+						addThroughput(event, aggInterval)
+					}
+					cfg.handleExemplars(event, ktm.Metrics.GetExemplars())
+					b = append(b, event)
+				}
 			}
 			// service transaction metrics
-			for _, kstm := range sim.ServiceTransactionMetrics {
-				event := getBaseEventWithLabels()
-				svcTxnMetricsToAPMEvent(kstm.Key, kstm.Metrics, event, aggIntervalStr)
-				b = append(b, event)
+			if emitSvcTxn {
+				for _, kstm := range sim.ServiceTransactionMetrics {
+					event := getBaseEventWithLabels()
+					svcTxnMetricsToAPMEvent(kstm.Key, kstm.Metrics, event, aggIntervalStr, cfg.durationSumEstimate)
+					setEventKey(event, protohash.HashServiceTransactionAggregationKey(sikHash, kstm.Key))
+					addDurationPercentiles(event, cfg.percentiles)
+					if cfg.serviceTransactionRates {
+						addServiceTransactionRates(event, aggInterval)
+					}
+					if cfg.throughput {
+						addThroughput(event, aggInterval)
+					}
+					b = append(b, event)
+				}
 			}
 			// service destination metrics
-			for _, kspm := range sim.SpanMetrics {
-				event := getBaseEventWithLabels()
-				spanMetricsToAPMEvent(kspm.Key, kspm.Metrics, event, aggIntervalStr)
-				b = append(b, event)
+			if emitSpan {
+				for _, kspm := range sim.SpanMetrics {
+					event := getBaseEventWithLabels()
+					spanMetricsToAPMEvent(kspm.Key, kspm.Metrics, event, aggIntervalStr)
+					if err := setCustomDimensionLabels(event, kspm.Key.CustomDimensions); err != nil {
+						return nil, fmt.Errorf("failed to unmarshal span custom dimensions: %w", err)
+					}
+					setEventKey(event, protohash.HashSpanAggregationKey(sikHash, kspm.Key))
+					cfg.handleExemplars(event, kspm.Metrics.GetExemplars())
+					b = append(b, event)
+				}
+			}
+			// service error metrics
+			if emitError {
+				for _, kem := range sim.ErrorMetrics {
+					event := getBaseEventWithLabels()
+					errorMetricsToAPMEvent(kem.Key, kem.Metrics, event, aggInterval, aggIntervalStr)
+					setEventKey(event, protohash.HashErrorAggregationKey(sikHash, kem.Key))
+					b = append(b, event)
+				}
+			}
+			// service graph edge metrics
+			if emitGraphEdge {
+				for _, kgm := range sim.ServiceGraphEdgeMetrics {
+					event := getBaseEventWithLabels()
+					serviceGraphEdgeMetricsToAPMEvent(kgm.Key, kgm.Metrics, event, aggIntervalStr)
+					setEventKey(event, protohash.HashServiceGraphEdgeAggregationKey(sikHash, kgm.Key))
+					b = append(b, event)
+				}
 			}
 
 			// service summary metrics
-			event := getBaseEventWithLabels()
-			serviceMetricsToAPMEvent(event, aggIntervalStr)
-			b = append(b, event)
+			if serviceSummary {
+				event := getBaseEventWithLabels()
+				serviceMetricsToAPMEvent(event, aggIntervalStr)
+				setEventKey(event, sikHash)
+				b = append(b, event)
+			}
 		}
 
 		if sm.OverflowGroups == nil {
 			continue
 		}
-		if len(sm.OverflowGroups.OverflowTransactionsEstimator) > 0 {
+		if emitTxn && len(sm.OverflowGroups.OverflowTransactionsEstimator) > 0 {
 			estimator := hllSketch(sm.OverflowGroups.OverflowTransactionsEstimator)
-			event := getBaseEvent(sk)
+			event := getServiceBaseEvent()
 			overflowTxnMetricsToAPMEvent(
 				processingTime,
-				sm.OverflowGroups.OverflowTransactions,
+				sm.OverflowGroups.OverflowTransactions,
+				estimator.Estimate(),
+				event,
+				aggIntervalStr,
+				cfg.durationSumEstimate,
+			)
+			setEventKey(event, skHash)
+			addDurationPercentiles(event, cfg.percentiles)
+			if cfg.throughput {
+				addThroughput(event, aggInterval)
+			}
+			b = append(b, event)
+		}
+		if emitSvcTxn && len(sm.OverflowGroups.OverflowServiceTransactionsEstimator) > 0 {
+			estimator := hllSketch(
+				sm.OverflowGroups.OverflowServiceTransactionsEstimator,
+			)
+			event := getServiceBaseEvent()
+			overflowSvcTxnMetricsToAPMEvent(
+				processingTime,
+				sm.OverflowGroups.OverflowServiceTransactions,
+				estimator.Estimate(),
+				event,
+				aggIntervalStr,
+				cfg.durationSumEstimate,
+			)
+			setEventKey(event, skHash)
+			addDurationPercentiles(event, cfg.percentiles)
+			if cfg.serviceTransactionRates {
+				addServiceTransactionRates(event, aggInterval)
+			}
+			if cfg.throughput {
+				addThroughput(event, aggInterval)
+			}
+			b = append(b, event)
+		}
+		if emitSpan && len(sm.OverflowGroups.OverflowSpansEstimator) > 0 {
+			estimator := hllSketch(sm.OverflowGroups.OverflowSpansEstimator)
+			event := getServiceBaseEvent()
+			overflowSpanMetricsToAPMEvent(
+				processingTime,
+				sm.OverflowGroups.OverflowSpans,
+				estimator.Estimate(),
+				event,
+				aggIntervalStr,
+			)
+			setEventKey(event, skHash)
+			b = append(b, event)
+		}
+		if emitError && len(sm.OverflowGroups.OverflowErrorsEstimator) > 0 {
+			estimator := hllSketch(sm.OverflowGroups.OverflowErrorsEstimator)
+			event := getServiceBaseEvent()
+			overflowErrorMetricsToAPMEvent(
+				processingTime,
+				sm.OverflowGroups.OverflowErrors,
 				estimator.Estimate(),
 				event,
+				aggInterval,
 				aggIntervalStr,
 			)
+			setEventKey(event, skHash)
 			b = append(b, event)
 		}
-		if len(sm.OverflowGroups.OverflowServiceTransactionsEstimator) > 0 {
-			estimator := hllSketch(
-				sm.OverflowGroups.OverflowServiceTransactionsEstimator,
-			)
-			event := getBaseEvent(sk)
-			overflowSvcTxnMetricsToAPMEvent(
+		if emitGraphEdge && len(sm.OverflowGroups.OverflowServiceGraphEdgesEstimator) > 0 {
+			estimator := hllSketch(sm.OverflowGroups.OverflowServiceGraphEdgesEstimator)
+			event := getServiceBaseEvent()
+			overflowServiceGraphEdgeMetricsToAPMEvent(
 				processingTime,
-				sm.OverflowGroups.OverflowServiceTransactions,
+				sm.OverflowGroups.OverflowServiceGraphEdges,
 				estimator.Estimate(),
 				event,
 				aggIntervalStr,
 			)
+			setEventKey(event, skHash)
 			b = append(b, event)
 		}
-		if len(sm.OverflowGroups.OverflowSpansEstimator) > 0 {
-			estimator := hllSketch(sm.OverflowGroups.OverflowSpansEstimator)
-			event := getBaseEvent(sk)
-			overflowSpanMetricsToAPMEvent(
+		if emitSummary && len(sm.OverflowGroups.OverflowGlobalLabelsEstimator) > 0 {
+			estimator := hllSketch(sm.OverflowGroups.OverflowGlobalLabelsEstimator)
+			event := getServiceBaseEvent()
+			overflowGlobalLabelsToAPMEvent(
 				processingTime,
-				sm.OverflowGroups.OverflowSpans,
 				estimator.Estimate(),
 				event,
 				aggIntervalStr,
 			)
+			setEventKey(event, skHash)
 			b = append(b, event)
 		}
 	}
 	if len(cm.OverflowServiceInstancesEstimator) > 0 {
-		estimator := hllSketch(cm.OverflowServiceInstancesEstimator)
 		getOverflowBaseEvent := func() *modelpb.APMEvent {
 			e := modelpb.APMEventFromVTPool()
 			e.Metricset = modelpb.MetricsetFromVTPool()
@@ -531,15 +1747,19 @@ func CombinedMetricsToBatch(
 			e.Service.Name = overflowBucketName
 			return e
 		}
-		event := getOverflowBaseEvent()
-		overflowServiceMetricsToAPMEvent(
-			processingTime,
-			estimator.Estimate(),
-			event,
-			aggIntervalStr,
-		)
-		b = append(b, event)
-		if len(cm.OverflowServices.OverflowTransactionsEstimator) > 0 {
+		if emitSummary {
+			estimator := hllSketch(cm.OverflowServiceInstancesEstimator)
+			event := getOverflowBaseEvent()
+			overflowServiceMetricsToAPMEvent(
+				processingTime,
+				estimator.Estimate(),
+				event,
+				aggIntervalStr,
+			)
+			setEventKey(event, xxhash.Digest{})
+			b = append(b, event)
+		}
+		if emitTxn && len(cm.OverflowServices.OverflowTransactionsEstimator) > 0 {
 			estimator := hllSketch(cm.OverflowServices.OverflowTransactionsEstimator)
 			event := getOverflowBaseEvent()
 			overflowTxnMetricsToAPMEvent(
@@ -548,11 +1768,14 @@ func CombinedMetricsToBatch(
 				estimator.Estimate(),
 				event,
 				aggIntervalStr,
+				cfg.durationSumEstimate,
 			)
+			setEventKey(event, xxhash.Digest{})
+			addDurationPercentiles(event, cfg.percentiles)
 			b = append(b, event)
 
 		}
-		if len(cm.OverflowServices.OverflowServiceTransactionsEstimator) > 0 {
+		if emitSvcTxn && len(cm.OverflowServices.OverflowServiceTransactionsEstimator) > 0 {
 			estimator := hllSketch(
 				cm.OverflowServices.OverflowServiceTransactionsEstimator,
 			)
@@ -563,10 +1786,13 @@ func CombinedMetricsToBatch(
 				estimator.Estimate(),
 				event,
 				aggIntervalStr,
+				cfg.durationSumEstimate,
 			)
+			setEventKey(event, xxhash.Digest{})
+			addDurationPercentiles(event, cfg.percentiles)
 			b = append(b, event)
 		}
-		if len(cm.OverflowServices.OverflowSpansEstimator) > 0 {
+		if emitSpan && len(cm.OverflowServices.OverflowSpansEstimator) > 0 {
 			estimator := hllSketch(cm.OverflowServices.OverflowSpansEstimator)
 			event := getOverflowBaseEvent()
 			overflowSpanMetricsToAPMEvent(
@@ -576,16 +1802,225 @@ func CombinedMetricsToBatch(
 				event,
 				aggIntervalStr,
 			)
+			setEventKey(event, xxhash.Digest{})
+			b = append(b, event)
+		}
+		if emitError && len(cm.OverflowServices.OverflowErrorsEstimator) > 0 {
+			estimator := hllSketch(cm.OverflowServices.OverflowErrorsEstimator)
+			event := getOverflowBaseEvent()
+			overflowErrorMetricsToAPMEvent(
+				processingTime,
+				cm.OverflowServices.OverflowErrors,
+				estimator.Estimate(),
+				event,
+				aggInterval,
+				aggIntervalStr,
+			)
+			setEventKey(event, xxhash.Digest{})
+			b = append(b, event)
+		}
+		if emitGraphEdge && len(cm.OverflowServices.OverflowServiceGraphEdgesEstimator) > 0 {
+			estimator := hllSketch(cm.OverflowServices.OverflowServiceGraphEdgesEstimator)
+			event := getOverflowBaseEvent()
+			overflowServiceGraphEdgeMetricsToAPMEvent(
+				processingTime,
+				cm.OverflowServices.OverflowServiceGraphEdges,
+				estimator.Estimate(),
+				event,
+				aggIntervalStr,
+			)
+			setEventKey(event, xxhash.Digest{})
 			b = append(b, event)
 		}
 	}
+	if cfg.cumulativeCounters != nil {
+		cfg.cumulativeCounters.accumulate(b, cumulativeKeys, processingTime)
+	}
+	b = cfg.renameMetricsets(b)
+	if cfg.configFingerprint != "" {
+		for _, e := range b {
+			setLabel(e, ConfigFingerprintLabel, cfg.configFingerprint)
+		}
+	}
+	if cfg.temporality != 0 {
+		for _, e := range b {
+			setLabel(e, TemporalityLabel, cfg.temporality.String())
+		}
+	}
 	return &b, nil
 }
 
+// CombinedMetricsToBatches converts the combined metrics like
+// CombinedMetricsToBatch, splitting the events into multiple batches
+// bounded by the limits configured with WithMaxOutputBatchSize, e.g. to
+// publish each batch with a separate bulk request.
+func CombinedMetricsToBatches(
+	cm *aggregationpb.CombinedMetrics,
+	processingTime time.Time,
+	aggInterval time.Duration,
+	opts ...ConverterOption,
+) ([]*modelpb.Batch, error) {
+	cfg, err := newConverterConfig(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid converter options: %w", err)
+	}
+	b, err := CombinedMetricsToBatch(cm, processingTime, aggInterval, opts...)
+	if err != nil || b == nil {
+		return nil, err
+	}
+	return splitBatch(*b, cfg.maxBatchEvents, cfg.maxBatchBytes), nil
+}
+
+// splitBatch splits the events of the batch, in order, into batches of at
+// most maxEvents events and maxBytes encoded bytes. Zero limits are ignored.
+func splitBatch(b modelpb.Batch, maxEvents, maxBytes int) []*modelpb.Batch {
+	if len(b) == 0 {
+		return nil
+	}
+	if maxEvents <= 0 && maxBytes <= 0 {
+		return []*modelpb.Batch{&b}
+	}
+	var batches []*modelpb.Batch
+	var start, size int
+	for i, e := range b {
+		var eventSize int
+		if maxBytes > 0 {
+			eventSize = e.SizeVT()
+		}
+		full := maxEvents > 0 && i-start >= maxEvents ||
+			maxBytes > 0 && i > start && size+eventSize > maxBytes
+		if full {
+			chunk := b[start:i:i]
+			batches = append(batches, &chunk)
+			start, size = i, 0
+		}
+		size += eventSize
+	}
+	chunk := b[start:]
+	return append(batches, &chunk)
+}
+
+// setLabel sets the label of the event. The labels of the event are
+// copied as they may be shared with other events.
+func setLabel(event *modelpb.APMEvent, name, value string) {
+	labels := make(modelpb.Labels, len(event.Labels)+1)
+	for k, v := range event.Labels {
+		labels[k] = v
+	}
+	labels[name] = &modelpb.LabelValue{Value: value}
+	event.Labels = labels
+}
+
+// setDocumentID sets a deterministic document ID, derived from the given
+// event key hash, the processing time and the aggregation interval, as the
+// DocumentIDLabel label of the event.
+func setDocumentID(
+	event *modelpb.APMEvent,
+	h xxhash.Digest,
+	processingTime time.Time,
+	aggIntervalStr string,
+) {
+	setLabel(event, DocumentIDLabel,
+		fmt.Sprintf("%d-%s-%016x", processingTime.Unix(), aggIntervalStr, h.Sum64()),
+	)
+}
+
+// addDurationPercentiles adds the given percentiles of the transaction
+// duration histogram of the event as metricset samples.
+func addDurationPercentiles(e *modelpb.APMEvent, percentiles []float64) {
+	if len(percentiles) == 0 {
+		return
+	}
+	h := e.GetTransaction().GetDurationHistogram()
+	if h == nil || len(h.Counts) == 0 {
+		return
+	}
+	var total uint64
+	for _, c := range h.Counts {
+		total += c
+	}
+	for _, p := range percentiles {
+		sample := modelpb.MetricsetSampleFromVTPool()
+		sample.Type = modelpb.MetricType_METRIC_TYPE_GAUGE
+		sample.Name = "transaction.duration.p" + strings.ReplaceAll(
+			strconv.FormatFloat(p, 'f', -1, 64), ".", "_",
+		)
+		sample.Unit = "us"
+		sample.Value = histogramPercentile(h.Counts, h.Values, total, p)
+		e.Metricset.Samples = append(e.Metricset.Samples, sample)
+	}
+}
+
+// addDurationExtremes adds the exact minimum and maximum transaction
+// durations, in microseconds, as gauge samples if they were recorded.
+func addDurationExtremes(e *modelpb.APMEvent, min, max float64) {
+	if min == 0 && max == 0 {
+		return
+	}
+	for _, extreme := range [...]struct {
+		name  string
+		value float64
+	}{
+		{name: "transaction.duration.min", value: min},
+		{name: "transaction.duration.max", value: max},
+	} {
+		sample := modelpb.MetricsetSampleFromVTPool()
+		sample.Type = modelpb.MetricType_METRIC_TYPE_GAUGE
+		sample.Name = extreme.name
+		sample.Unit = "us"
+		sample.Value = extreme.value
+		e.Metricset.Samples = append(e.Metricset.Samples, sample)
+	}
+}
+
+// addServiceTransactionRates adds the failure rate and the throughput per
+// minute of the service transaction event as gauge samples.
+func addServiceTransactionRates(e *modelpb.APMEvent, interval time.Duration) {
+	if sc := e.GetEvent().GetSuccessCount(); sc.GetCount() > 0 {
+		failureRate := modelpb.MetricsetSampleFromVTPool()
+		failureRate.Type = modelpb.MetricType_METRIC_TYPE_GAUGE
+		failureRate.Name = "service_transaction.failure_rate"
+		failureRate.Value = 1 - sc.Sum/float64(sc.Count)
+		e.Metricset.Samples = append(e.Metricset.Samples, failureRate)
+	}
+	throughput := modelpb.MetricsetSampleFromVTPool()
+	throughput.Type = modelpb.MetricType_METRIC_TYPE_GAUGE
+	throughput.Name = "service_transaction.throughput_per_minute"
+	throughput.Value = float64(e.GetTransaction().GetDurationSummary().GetCount()) / interval.Minutes()
+	e.Metricset.Samples = append(e.Metricset.Samples, throughput)
+}
+
+// addThroughput adds the number of transactions per minute of the
+// interval, weighted by representative count, as a gauge sample.
+func addThroughput(e *modelpb.APMEvent, interval time.Duration) {
+	throughput := modelpb.MetricsetSampleFromVTPool()
+	throughput.Type = modelpb.MetricType_METRIC_TYPE_GAUGE
+	throughput.Name = "throughput"
+	throughput.Value = float64(e.GetMetricset().GetDocCount()) / interval.Minutes()
+	e.Metricset.Samples = append(e.Metricset.Samples, throughput)
+}
+
+// histogramPercentile returns the value of the given percentile using
+// nearest-rank over the histogram counts and values, sorted by value.
+func histogramPercentile(counts []uint64, values []float64, total uint64, p float64) float64 {
+	rank := uint64(math.Ceil(p / 100 * float64(total)))
+	var cumulative uint64
+	for i, c := range counts {
+		cumulative += c
+		if cumulative >= rank {
+			return values[i]
+		}
+	}
+	return values[len(values)-1]
+}
+
 func setSpanMetrics(e *modelpb.APMEvent, repCount float64, out *aggregationpb.SpanMetrics) {
 	var count uint32 = 1
 	duration := e.GetEvent().GetDuration().AsDuration()
-	if composite := e.GetSpan().GetComposite(); composite != nil {
+	// Composite spans compressed by agents represent composite.count spans
+	// with a total duration of composite.sum milliseconds. Composites
+	// without a count are aggregated as a single span.
+	if composite := e.GetSpan().GetComposite(); composite != nil && composite.GetCount() > 0 {
 		count = composite.GetCount()
 		duration = time.Duration(composite.GetSum() * float64(time.Millisecond))
 	}
@@ -593,6 +2028,20 @@ func setSpanMetrics(e *modelpb.APMEvent, repCount float64, out *aggregationpb.Sp
 	out.Sum = float64(duration) * repCount
 }
 
+// setExemplar sets the exemplar for the event identified by the given
+// transaction or span ID. It returns false if the event has no trace ID.
+func setExemplar(e *modelpb.APMEvent, id string, out *aggregationpb.Exemplar) bool {
+	traceID := e.GetTrace().GetId()
+	if traceID == "" {
+		return false
+	}
+	out.TraceId = traceID
+	out.SpanId = id
+	out.Duration = int64(e.GetEvent().GetDuration().AsDuration())
+	out.Timestamp = tspb.TimeToPBTimestamp(e.GetTimestamp().AsTime())
+	return true
+}
+
 func setDroppedSpanStatsMetrics(dss *modelpb.DroppedSpanStats, repCount float64, out *aggregationpb.SpanMetrics) {
 	out.Count = float64(dss.GetDuration().GetCount()) * repCount
 	out.Sum = float64(dss.GetDuration().GetSum().AsDuration()) * repCount
@@ -616,9 +2065,62 @@ func getBaseEvent(key *aggregationpb.ServiceAggregationKey) *modelpb.APMEvent {
 		event.Agent.Name = key.AgentName
 	}
 
+	if key.KubernetesNamespace != "" {
+		event.Kubernetes = modelpb.KubernetesFromVTPool()
+		event.Kubernetes.Namespace = key.KubernetesNamespace
+	}
+
 	return event
 }
 
+// setCustomDimensionLabels adds the custom dimensions of an aggregation
+// key as labels to the event. The labels of the event are copied as they
+// may be shared with other events.
+func setCustomDimensionLabels(event *modelpb.APMEvent, customDimensions []byte) error {
+	labels, err := customDimensionLabels(customDimensions)
+	if err != nil || len(labels) == 0 {
+		return err
+	}
+	event.Labels = mergeLabels(event.Labels, labels)
+	return nil
+}
+
+// mergeLabels returns a new map with the labels of a and b. The labels of
+// b take precedence over the labels of a with the same key.
+func mergeLabels(a, b modelpb.Labels) modelpb.Labels {
+	labels := make(modelpb.Labels, len(a)+len(b))
+	for k, v := range a {
+		labels[k] = v
+	}
+	for k, v := range b {
+		labels[k] = v
+	}
+	return labels
+}
+
+// setServiceInstanceDimensions sets the instance dimensions of the service
+// instance key, if any, on the event.
+func setServiceInstanceDimensions(key *aggregationpb.ServiceInstanceAggregationKey, event *modelpb.APMEvent) {
+	if key.HostName != "" {
+		if event.Host == nil {
+			event.Host = modelpb.HostFromVTPool()
+		}
+		event.Host.Name = key.HostName
+	}
+	if key.ContainerId != "" {
+		if event.Container == nil {
+			event.Container = modelpb.ContainerFromVTPool()
+		}
+		event.Container.Id = key.ContainerId
+	}
+	if key.KubernetesPodName != "" {
+		if event.Kubernetes == nil {
+			event.Kubernetes = modelpb.KubernetesFromVTPool()
+		}
+		event.Kubernetes.PodName = key.KubernetesPodName
+	}
+}
+
 func serviceMetricsToAPMEvent(
 	baseEvent *modelpb.APMEvent,
 	intervalStr string,
@@ -636,10 +2138,9 @@ func txnMetricsToAPMEvent(
 	metrics *aggregationpb.TransactionMetrics,
 	baseEvent *modelpb.APMEvent,
 	intervalStr string,
+	sumEstimate DurationSumEstimate,
 ) {
-	histogram := hdrhistogram.New()
-	histogramFromProto(histogram, metrics.Histogram)
-	totalCount, counts, values := histogram.Buckets()
+	totalCount, counts, values := durationBuckets(metrics.Histogram, metrics.DdSketch, metrics.TDigest)
 	eventSuccessCount := modelpb.SummaryMetricFromVTPool()
 	switch key.EventOutcome {
 	case "success":
@@ -652,9 +2153,9 @@ func txnMetricsToAPMEvent(
 	}
 	transactionDurationSummary := modelpb.SummaryMetricFromVTPool()
 	transactionDurationSummary.Count = totalCount
-	for i, v := range values {
-		transactionDurationSummary.Sum += v * float64(counts[i])
-	}
+	transactionDurationSummary.Sum = durationSum(
+		sumEstimate, counts, values, metrics.Histogram, metrics.DdSketch, metrics.TDigest, metrics.Sum,
+	)
 
 	if baseEvent.Transaction == nil {
 		baseEvent.Transaction = modelpb.TransactionFromVTPool()
@@ -674,6 +2175,7 @@ func txnMetricsToAPMEvent(
 	baseEvent.Metricset.Name = txnMetricsetName
 	baseEvent.Metricset.DocCount = totalCount
 	baseEvent.Metricset.Interval = intervalStr
+	addDurationExtremes(baseEvent, metrics.Min, metrics.Max)
 
 	if baseEvent.Event == nil {
 		baseEvent.Event = modelpb.EventFromVTPool()
@@ -745,7 +2247,10 @@ func txnMetricsToAPMEvent(
 		baseEvent.Host.Name = key.HostName
 	}
 
-	if key.HostOsPlatform != "" {
+	if key.HostOsPlatform != "" ||
+		key.HostOsName != "" ||
+		key.HostOsVersion != "" {
+
 		if baseEvent.Host == nil {
 			baseEvent.Host = modelpb.HostFromVTPool()
 		}
@@ -753,6 +2258,18 @@ func txnMetricsToAPMEvent(
 			baseEvent.Host.Os = modelpb.OSFromVTPool()
 		}
 		baseEvent.Host.Os.Platform = key.HostOsPlatform
+		baseEvent.Host.Os.Name = key.HostOsName
+		baseEvent.Host.Os.Version = key.HostOsVersion
+	}
+
+	if key.NetworkConnectionType != "" {
+		if baseEvent.Network == nil {
+			baseEvent.Network = modelpb.NetworkFromVTPool()
+		}
+		if baseEvent.Network.Connection == nil {
+			baseEvent.Network.Connection = modelpb.NetworkConnectionFromVTPool()
+		}
+		baseEvent.Network.Connection.Type = key.NetworkConnectionType
 	}
 
 	faasColdstart := nullable.Bool(key.FaasColdstart)
@@ -797,20 +2314,128 @@ func txnMetricsToAPMEvent(
 	}
 }
 
+// durationBuckets combines the duration distributions recorded using the
+// different HistogramImpl into ordered slices of counts and values along
+// with the total count.
+func durationBuckets(
+	h *aggregationpb.HDRHistogram,
+	dd *aggregationpb.DDSketch,
+	td *aggregationpb.TDigest,
+) (uint64, []uint64, []float64) {
+	histogram := hdrhistogram.New()
+	histogramFromProto(histogram, h)
+	totalCount, counts, values := histogram.Buckets()
+	if dd == nil && td == nil {
+		return totalCount, counts, values
+	}
+	if dd != nil {
+		ddTotal, ddCounts, ddValues := ddSketchFromProto(dd).Buckets()
+		totalCount += ddTotal
+		counts = append(counts, ddCounts...)
+		values = append(values, ddValues...)
+	}
+	if td != nil {
+		tdTotal, tdCounts, tdValues := tDigestFromProto(td).Buckets()
+		totalCount += tdTotal
+		counts = append(counts, tdCounts...)
+		values = append(values, tdValues...)
+	}
+	sort.Sort(buckets{counts: counts, values: values})
+	n := 0
+	for i := range values {
+		if n > 0 && values[n-1] == values[i] {
+			counts[n-1] += counts[i]
+			continue
+		}
+		counts[n], values[n] = counts[i], values[i]
+		n++
+	}
+	return totalCount, counts[:n], values[:n]
+}
+
+// durationSum returns the sum of the transaction duration summary. The
+// counts and values are the merged buckets returned by durationBuckets,
+// used for the upper bound estimate. For the lower bound and midpoint
+// estimates, the bounds of the HDR histogram buckets are used instead,
+// while DDSketch and t-digest buckets are represented by their values.
+// A zero recorded sum means that the sum was not recorded for all the
+// merged durations, see mergeDurationSum, in which case the upper bound
+// estimate is used.
+func durationSum(
+	estimate DurationSumEstimate,
+	counts []uint64,
+	values []float64,
+	h *aggregationpb.HDRHistogram,
+	dd *aggregationpb.DDSketch,
+	td *aggregationpb.TDigest,
+	recordedSum float64,
+) float64 {
+	var sum float64
+	switch estimate {
+	case RecordedSumEstimate:
+		if recordedSum > 0 {
+			return recordedSum
+		}
+		for i, v := range values {
+			sum += v * float64(counts[i])
+		}
+	case LowerBoundSumEstimate, MidpointSumEstimate:
+		histogram := hdrhistogram.New()
+		histogramFromProto(histogram, h)
+		_, hdrCounts, lowers, uppers := histogram.BucketBounds()
+		for i, lower := range lowers {
+			v := lower
+			if estimate == MidpointSumEstimate {
+				v = (lower + uppers[i]) / 2
+			}
+			sum += v * float64(hdrCounts[i])
+		}
+		if dd != nil {
+			_, ddCounts, ddValues := ddSketchFromProto(dd).Buckets()
+			for i, v := range ddValues {
+				sum += v * float64(ddCounts[i])
+			}
+		}
+		if td != nil {
+			_, tdCounts, tdValues := tDigestFromProto(td).Buckets()
+			for i, v := range tdValues {
+				sum += v * float64(tdCounts[i])
+			}
+		}
+	default:
+		for i, v := range values {
+			sum += v * float64(counts[i])
+		}
+	}
+	return sum
+}
+
+// buckets sorts histogram counts and values by value.
+type buckets struct {
+	counts []uint64
+	values []float64
+}
+
+func (b buckets) Len() int           { return len(b.values) }
+func (b buckets) Less(i, j int) bool { return b.values[i] < b.values[j] }
+func (b buckets) Swap(i, j int) {
+	b.counts[i], b.counts[j] = b.counts[j], b.counts[i]
+	b.values[i], b.values[j] = b.values[j], b.values[i]
+}
+
 func svcTxnMetricsToAPMEvent(
 	key *aggregationpb.ServiceTransactionAggregationKey,
 	metrics *aggregationpb.ServiceTransactionMetrics,
 	baseEvent *modelpb.APMEvent,
 	intervalStr string,
+	sumEstimate DurationSumEstimate,
 ) {
-	histogram := hdrhistogram.New()
-	histogramFromProto(histogram, metrics.Histogram)
-	totalCount, counts, values := histogram.Buckets()
+	totalCount, counts, values := durationBuckets(metrics.Histogram, metrics.DdSketch, metrics.TDigest)
 	transactionDurationSummary := modelpb.SummaryMetric{
 		Count: totalCount,
-	}
-	for i, v := range values {
-		transactionDurationSummary.Sum += v * float64(counts[i])
+		Sum: durationSum(
+			sumEstimate, counts, values, metrics.Histogram, metrics.DdSketch, metrics.TDigest, metrics.Sum,
+		),
 	}
 
 	if baseEvent.Metricset == nil {
@@ -819,6 +2444,7 @@ func svcTxnMetricsToAPMEvent(
 	baseEvent.Metricset.Name = svcTxnMetricsetName
 	baseEvent.Metricset.DocCount = totalCount
 	baseEvent.Metricset.Interval = intervalStr
+	addDurationExtremes(baseEvent, metrics.Min, metrics.Max)
 
 	if baseEvent.Transaction == nil {
 		baseEvent.Transaction = modelpb.TransactionFromVTPool()
@@ -870,6 +2496,7 @@ func spanMetricsToAPMEvent(
 		baseEvent.Span = modelpb.SpanFromVTPool()
 	}
 	baseEvent.Span.Name = key.SpanName
+	baseEvent.Span.Subtype = key.SpanSubtype
 
 	if baseEvent.Span.DestinationService == nil {
 		baseEvent.Span.DestinationService = modelpb.DestinationServiceFromVTPool()
@@ -892,6 +2519,104 @@ func spanMetricsToAPMEvent(
 	}
 }
 
+// errorMetricsToAPMEvent maps the error metrics to the passed APMEvent,
+// reporting the count of errors and the rate of errors per second over
+// the aggregation interval.
+func errorMetricsToAPMEvent(
+	key *aggregationpb.ErrorAggregationKey,
+	metrics *aggregationpb.ErrorMetrics,
+	baseEvent *modelpb.APMEvent,
+	interval time.Duration,
+	intervalStr string,
+) {
+	count := math.Round(metrics.GetCount())
+
+	if baseEvent.Metricset == nil {
+		baseEvent.Metricset = modelpb.MetricsetFromVTPool()
+	}
+	baseEvent.Metricset.Name = errorMetricsetName
+	baseEvent.Metricset.DocCount = uint64(count)
+	baseEvent.Metricset.Interval = intervalStr
+
+	countSample := modelpb.MetricsetSampleFromVTPool()
+	countSample.Type = modelpb.MetricType_METRIC_TYPE_COUNTER
+	countSample.Name = "error.count"
+	countSample.Value = count
+	rateSample := modelpb.MetricsetSampleFromVTPool()
+	rateSample.Type = modelpb.MetricType_METRIC_TYPE_GAUGE
+	rateSample.Name = "error.rate"
+	rateSample.Value = count / interval.Seconds()
+	baseEvent.Metricset.Samples = append(baseEvent.Metricset.Samples, countSample, rateSample)
+
+	if baseEvent.Error == nil {
+		baseEvent.Error = modelpb.ErrorFromVTPool()
+	}
+	baseEvent.Error.GroupingKey = key.GroupingKey
+
+	if key.Outcome != "" {
+		if baseEvent.Event == nil {
+			baseEvent.Event = modelpb.EventFromVTPool()
+		}
+		baseEvent.Event.Outcome = key.Outcome
+	}
+}
+
+// serviceGraphEdgeMetricsToAPMEvent maps the service graph edge metrics to
+// the passed APMEvent. The edge is from the service of the event to the
+// service target and destination resource of the key.
+func serviceGraphEdgeMetricsToAPMEvent(
+	key *aggregationpb.ServiceGraphEdgeAggregationKey,
+	metrics *aggregationpb.ServiceGraphEdgeMetrics,
+	baseEvent *modelpb.APMEvent,
+	intervalStr string,
+) {
+	var target *modelpb.ServiceTarget
+	if key.TargetName != "" || key.TargetType != "" {
+		target = modelpb.ServiceTargetFromVTPool()
+		target.Type = key.TargetType
+		target.Name = key.TargetName
+	}
+	if baseEvent.Service == nil {
+		baseEvent.Service = modelpb.ServiceFromVTPool()
+	}
+	baseEvent.Service.Target = target
+
+	if key.Resource != "" {
+		if baseEvent.Span == nil {
+			baseEvent.Span = modelpb.SpanFromVTPool()
+		}
+		if baseEvent.Span.DestinationService == nil {
+			baseEvent.Span.DestinationService = modelpb.DestinationServiceFromVTPool()
+		}
+		baseEvent.Span.DestinationService.Resource = key.Resource
+	}
+
+	count := math.Round(metrics.GetCount())
+	if baseEvent.Metricset == nil {
+		baseEvent.Metricset = modelpb.MetricsetFromVTPool()
+	}
+	baseEvent.Metricset.Name = serviceGraphEdgeMetricsetName
+	baseEvent.Metricset.DocCount = uint64(count)
+	baseEvent.Metricset.Interval = intervalStr
+
+	_, counts, values := durationBuckets(metrics.GetHistogram(), metrics.GetDdSketch(), metrics.GetTDigest())
+	requestSample := modelpb.MetricsetSampleFromVTPool()
+	requestSample.Type = modelpb.MetricType_METRIC_TYPE_COUNTER
+	requestSample.Name = "service_graph_edge.request.count"
+	requestSample.Value = count
+	errorSample := modelpb.MetricsetSampleFromVTPool()
+	errorSample.Type = modelpb.MetricType_METRIC_TYPE_COUNTER
+	errorSample.Name = "service_graph_edge.error.count"
+	errorSample.Value = math.Round(metrics.GetErrorCount())
+	latencySample := modelpb.MetricsetSampleFromVTPool()
+	latencySample.Type = modelpb.MetricType_METRIC_TYPE_HISTOGRAM
+	latencySample.Name = "service_graph_edge.latency"
+	latencySample.Histogram = modelpb.HistogramFromVTPool()
+	latencySample.Histogram.Counts = counts
+	latencySample.Histogram.Values = values
+	baseEvent.Metricset.Samples = append(baseEvent.Metricset.Samples, requestSample, errorSample, latencySample)
+}
+
 func overflowServiceMetricsToAPMEvent(
 	processingTime time.Time,
 	overflowCount uint64,
@@ -913,6 +2638,25 @@ func overflowServiceMetricsToAPMEvent(
 	baseEvent.Metricset.Samples = append(baseEvent.Metricset.Samples, sample)
 }
 
+// overflowGlobalLabelsToAPMEvent maps the estimated number of unique global
+// label sets dropped due to the max global labels per service limit to the
+// passed APMEvent as a service_summary metric. This expects that service
+// related fields are present in the passed APMEvent.
+func overflowGlobalLabelsToAPMEvent(
+	processingTime time.Time,
+	overflowCount uint64,
+	baseEvent *modelpb.APMEvent,
+	intervalStr string,
+) {
+	baseEvent.Timestamp = timestamppb.New(processingTime)
+	serviceMetricsToAPMEvent(baseEvent, intervalStr)
+
+	sample := modelpb.MetricsetSampleFromVTPool()
+	sample.Name = "service_summary.aggregation.global_labels_overflow_count"
+	sample.Value = float64(overflowCount)
+	baseEvent.Metricset.Samples = append(baseEvent.Metricset.Samples, sample)
+}
+
 // overflowTxnMetricsToAPMEvent maps the fields of overflow
 // transaction to the passed APMEvent. This only updates transcation
 // metrics related fields and expects that service related fields
@@ -928,6 +2672,7 @@ func overflowTxnMetricsToAPMEvent(
 	overflowCount uint64,
 	baseEvent *modelpb.APMEvent,
 	intervalStr string,
+	sumEstimate DurationSumEstimate,
 ) {
 	// Overflow metrics use the processing time as their timestamp rather than
 	// the event time. This makes sure that they can be associated with the
@@ -936,7 +2681,7 @@ func overflowTxnMetricsToAPMEvent(
 	overflowKey := &aggregationpb.TransactionAggregationKey{
 		TransactionName: overflowBucketName,
 	}
-	txnMetricsToAPMEvent(overflowKey, overflowTxn, baseEvent, intervalStr)
+	txnMetricsToAPMEvent(overflowKey, overflowTxn, baseEvent, intervalStr, sumEstimate)
 
 	sample := modelpb.MetricsetSampleFromVTPool()
 	sample.Name = "transaction.aggregation.overflow_count"
@@ -953,6 +2698,7 @@ func overflowSvcTxnMetricsToAPMEvent(
 	overflowCount uint64,
 	baseEvent *modelpb.APMEvent,
 	intervalStr string,
+	sumEstimate DurationSumEstimate,
 ) {
 	// Overflow metrics use the processing time as their timestamp rather than
 	// the event time. This makes sure that they can be associated with the
@@ -961,7 +2707,7 @@ func overflowSvcTxnMetricsToAPMEvent(
 	overflowKey := &aggregationpb.ServiceTransactionAggregationKey{
 		TransactionType: overflowBucketName,
 	}
-	svcTxnMetricsToAPMEvent(overflowKey, overflowSvcTxn, baseEvent, intervalStr)
+	svcTxnMetricsToAPMEvent(overflowKey, overflowSvcTxn, baseEvent, intervalStr, sumEstimate)
 
 	sample := modelpb.MetricsetSampleFromVTPool()
 	sample.Name = "service_transaction.aggregation.overflow_count"
@@ -998,8 +2744,98 @@ func overflowSpanMetricsToAPMEvent(
 	baseEvent.Metricset.DocCount = overflowCount
 }
 
+func overflowErrorMetricsToAPMEvent(
+	processingTime time.Time,
+	overflowErr *aggregationpb.ErrorMetrics,
+	overflowCount uint64,
+	baseEvent *modelpb.APMEvent,
+	interval time.Duration,
+	intervalStr string,
+) {
+	// Overflow metrics use the processing time as their timestamp rather than
+	// the event time. This makes sure that they can be associated with the
+	// appropriate time when the event volume caused them to overflow.
+	baseEvent.Timestamp = timestamppb.New(processingTime)
+	overflowKey := &aggregationpb.ErrorAggregationKey{
+		GroupingKey: overflowBucketName,
+	}
+	errorMetricsToAPMEvent(overflowKey, overflowErr, baseEvent, interval, intervalStr)
+
+	sample := modelpb.MetricsetSampleFromVTPool()
+	sample.Name = "service_error.aggregation.overflow_count"
+	sample.Value = float64(overflowCount)
+	baseEvent.Metricset.Samples = append(baseEvent.Metricset.Samples, sample)
+}
+
+func overflowServiceGraphEdgeMetricsToAPMEvent(
+	processingTime time.Time,
+	overflowEdge *aggregationpb.ServiceGraphEdgeMetrics,
+	overflowCount uint64,
+	baseEvent *modelpb.APMEvent,
+	intervalStr string,
+) {
+	// Overflow metrics use the processing time as their timestamp rather than
+	// the event time. This makes sure that they can be associated with the
+	// appropriate time when the event volume caused them to overflow.
+	baseEvent.Timestamp = timestamppb.New(processingTime)
+	overflowKey := &aggregationpb.ServiceGraphEdgeAggregationKey{
+		TargetName: overflowBucketName,
+	}
+	serviceGraphEdgeMetricsToAPMEvent(overflowKey, overflowEdge, baseEvent, intervalStr)
+
+	sample := modelpb.MetricsetSampleFromVTPool()
+	sample.Name = "service_graph_edge.aggregation.overflow_count"
+	sample.Value = float64(overflowCount)
+	baseEvent.Metricset.Samples = append(baseEvent.Metricset.Samples, sample)
+}
+
+// hashGlobalLabels returns the hashed representation of serialized global
+// labels. The hashed representation is prefixed with a zero byte, which is
+// never the first byte of serialized global labels as protobuf field
+// numbers start from 1.
+func hashGlobalLabels(globalLabels []byte) []byte {
+	sum := sha256.Sum256(globalLabels)
+	hashed := make([]byte, 0, len(sum)+1)
+	hashed = append(hashed, 0)
+	return append(hashed, sum[:]...)
+}
+
 func marshalEventGlobalLabels(e *modelpb.APMEvent) ([]byte, error) {
-	if len(e.Labels) == 0 && len(e.NumericLabels) == 0 {
+	return MarshalGlobalLabels(e.Labels, e.NumericLabels)
+}
+
+// GlobalLabelsEncodingVersion is the version of the canonical global labels
+// encoding produced by MarshalGlobalLabels. The version is incremented with
+// any change to the encoding, as serialized global labels encoded with
+// different versions are not comparable and would split groups with the
+// same global labels when merged.
+const GlobalLabelsEncodingVersion = 1
+
+// MarshalGlobalLabels returns the canonical serialization of the global
+// labels in the given labels and numeric labels, as used for the global
+// labels of service instance aggregation keys. Labels which are not global
+// are ignored. The serialization is deterministic: global labels with the
+// same keys and values always produce byte-identical output, irrespective
+// of map iteration order. Producers of CombinedMetrics outside of this
+// package must use MarshalGlobalLabels for setting the global labels of
+// service instance aggregation keys, so that the service instance groups
+// are merged with the groups produced by the aggregator.
+//
+// Nil is returned if there are no global labels. The encoding is described
+// by GlobalLabelsEncodingVersion.
+func MarshalGlobalLabels(labels modelpb.Labels, numericLabels modelpb.NumericLabels) ([]byte, error) {
+	return marshalGlobalLabels(labels, numericLabels, nil)
+}
+
+// marshalGlobalLabels returns the canonical serialization of the global
+// labels for which include returns true, see MarshalGlobalLabels. All
+// global labels are included if include is nil.
+func marshalGlobalLabels(
+	labels modelpb.Labels,
+	numericLabels modelpb.NumericLabels,
+	include func(string) bool,
+) ([]byte, error) {
+	if len(labels) == 0 && len(numericLabels) == 0 {
 		return nil, nil
 	}
 
@@ -1007,8 +2843,8 @@ func marshalEventGlobalLabels(e *modelpb.APMEvent) ([]byte, error) {
 
 	// Keys must be sorted to ensure wire formats are deterministically generated and strings are directly comparable
 	// i.e. Protobuf formats are equal if and only if the structs are equal
-	for k, v := range e.Labels {
-		if !v.Global {
+	for k, v := range labels {
+		if !v.Global || (include != nil && !include(k)) {
 			continue
 		}
 
@@ -1036,8 +2872,8 @@ func marshalEventGlobalLabels(e *modelpb.APMEvent) ([]byte, error) {
 		})
 	}
 
-	for k, v := range e.NumericLabels {
-		if !v.Global {
+	for k, v := range numericLabels {
+		if !v.Global || (include != nil && !include(k)) {
 			continue
 		}
 
@@ -1149,6 +2985,19 @@ func setDroppedSpanStatsKey(dss *modelpb.DroppedSpanStats, key *aggregationpb.Sp
 	key.Resource = dss.GetDestinationServiceResource()
 }
 
+func setErrorKey(e *modelpb.APMEvent, key *aggregationpb.ErrorAggregationKey) {
+	key.GroupingKey = e.GetError().GetGroupingKey()
+	key.Outcome = e.GetEvent().GetOutcome()
+}
+
+func setServiceGraphEdgeKey(e *modelpb.APMEvent, key *aggregationpb.ServiceGraphEdgeAggregationKey) {
+	if target := e.GetService().GetTarget(); target != nil {
+		key.TargetType = target.GetType()
+		key.TargetName = target.GetName()
+	}
+	key.Resource = e.GetSpan().GetDestinationService().GetResource()
+}
+
 func formatDuration(d time.Duration) string {
 	if duration := d.Minutes(); duration >= 1 {
 		return fmt.Sprintf("%.0fm", duration)
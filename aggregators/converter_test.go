@@ -7,6 +7,7 @@ package aggregators
 import (
 	"fmt"
 	"net/netip"
+	"strings"
 	"testing"
 	"time"
 
@@ -41,6 +42,7 @@ func TestEventToCombinedMetrics(t *testing.T) {
 		name       string
 		input      func() []*modelpb.APMEvent
 		partitions uint16
+		opts       []ConverterOption
 		expected   func() []*aggregationpb.CombinedMetrics
 	}{
 		{
@@ -101,6 +103,126 @@ func TestEventToCombinedMetrics(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "with-faas-txn",
+			input: func() []*modelpb.APMEvent {
+				coldStart := true
+				event := baseEvent.CloneVT()
+				event.Transaction = &modelpb.Transaction{
+					Name:                "testtxn",
+					Type:                "testtyp",
+					RepresentativeCount: 1,
+				}
+				event.Faas = &modelpb.Faas{
+					Id:          "fn1",
+					ColdStart:   &coldStart,
+					TriggerType: "http",
+				}
+				return []*modelpb.APMEvent{event}
+			},
+			partitions: 1,
+			expected: func() []*aggregationpb.CombinedMetrics {
+				return []*aggregationpb.CombinedMetrics{
+					NewTestCombinedMetrics(
+						WithEventsTotal(1),
+						WithYoungestEventTimestamp(receivedTS)).
+						AddServiceMetrics(serviceAggregationKey{
+							Timestamp:   ts.Truncate(time.Minute),
+							ServiceName: "test"}).
+						AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+						AddTransaction(transactionAggregationKey{
+							TransactionName: "testtxn",
+							TransactionType: "testtyp",
+							EventOutcome:    "success",
+							FAASID:          "fn1",
+							FAASColdstart:   nullable.True,
+							FAASTriggerType: "http",
+						}).
+						AddServiceTransaction(serviceTransactionAggregationKey{
+							TransactionType: "testtyp",
+						}).GetProto(),
+				}
+			},
+		},
+		{
+			name: "with-faas-txn-without-faas-dimensions",
+			input: func() []*modelpb.APMEvent {
+				coldStart := true
+				event := baseEvent.CloneVT()
+				event.Transaction = &modelpb.Transaction{
+					Name:                "testtxn",
+					Type:                "testtyp",
+					RepresentativeCount: 1,
+				}
+				event.Faas = &modelpb.Faas{
+					Id:          "fn1",
+					ColdStart:   &coldStart,
+					TriggerType: "http",
+				}
+				return []*modelpb.APMEvent{event}
+			},
+			partitions: 1,
+			opts:       []ConverterOption{WithFaasDimensions(false)},
+			expected: func() []*aggregationpb.CombinedMetrics {
+				return []*aggregationpb.CombinedMetrics{
+					NewTestCombinedMetrics(
+						WithEventsTotal(1),
+						WithYoungestEventTimestamp(receivedTS)).
+						AddServiceMetrics(serviceAggregationKey{
+							Timestamp:   ts.Truncate(time.Minute),
+							ServiceName: "test"}).
+						AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+						AddTransaction(transactionAggregationKey{
+							TransactionName: "testtxn",
+							TransactionType: "testtyp",
+							EventOutcome:    "success",
+						}).
+						AddServiceTransaction(serviceTransactionAggregationKey{
+							TransactionType: "testtyp",
+						}).GetProto(),
+				}
+			},
+		},
+		{
+			name: "with-mobile-dimensions",
+			input: func() []*modelpb.APMEvent {
+				event := baseEvent.CloneVT()
+				event.Service.Version = "1.2.0"
+				event.Transaction = &modelpb.Transaction{
+					Name:                "testtxn",
+					Type:                "mobile",
+					RepresentativeCount: 1,
+				}
+				event.Host = &modelpb.Host{Os: &modelpb.OS{Name: "android", Version: "14"}}
+				event.Network = &modelpb.Network{Connection: &modelpb.NetworkConnection{Type: "wifi"}}
+				return []*modelpb.APMEvent{event}
+			},
+			partitions: 1,
+			opts:       []ConverterOption{WithMobileDimensions(true)},
+			expected: func() []*aggregationpb.CombinedMetrics {
+				return []*aggregationpb.CombinedMetrics{
+					NewTestCombinedMetrics(
+						WithEventsTotal(1),
+						WithYoungestEventTimestamp(receivedTS)).
+						AddServiceMetrics(serviceAggregationKey{
+							Timestamp:   ts.Truncate(time.Minute),
+							ServiceName: "test"}).
+						AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+						AddTransaction(transactionAggregationKey{
+							TransactionName:       "testtxn",
+							TransactionType:       "mobile",
+							EventOutcome:          "success",
+							ServiceVersion:        "1.2.0",
+							HostOSName:            "android",
+							HostOSVersion:         "14",
+							NetworkConnectionType: "wifi",
+						}).
+						AddServiceTransaction(serviceTransactionAggregationKey{
+							TransactionType: "mobile",
+						}).GetProto(),
+				}
+			},
+		},
 		{
 			name: "with-zero-rep-count-span",
 			input: func() []*modelpb.APMEvent {
@@ -204,13 +326,23 @@ func TestEventToCombinedMetrics(t *testing.T) {
 			},
 		},
 		{
-			name: "with-metricset",
+			name: "with-composite-span",
 			input: func() []*modelpb.APMEvent {
 				event := baseEvent.CloneVT()
-				event.Metricset = &modelpb.Metricset{
-					Name:     "testmetricset",
-					Interval: "1m",
+				event.Span = &modelpb.Span{
+					Name:                "testspan",
+					Type:                "testtyp",
+					RepresentativeCount: 2,
+					DestinationService: &modelpb.DestinationService{
+						Resource: "db",
+					},
+					// The composite span represents 3 spans of 9ms in total.
+					Composite: &modelpb.Composite{
+						Count: 3,
+						Sum:   9,
+					},
 				}
+				event.Event.Duration = durationpb.New(5 * time.Millisecond)
 				return []*modelpb.APMEvent{event}
 			},
 			partitions: 1,
@@ -223,15 +355,29 @@ func TestEventToCombinedMetrics(t *testing.T) {
 							Timestamp:   ts.Truncate(time.Minute),
 							ServiceName: "test"}).
 						AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
-						GetProto(),
+						AddSpan(spanAggregationKey{
+							SpanName: "testspan",
+							Resource: "db",
+							Outcome:  "success",
+						}, WithSpanCount(6), WithSpanDuration(3*time.Millisecond)).GetProto(),
 				}
 			},
 		},
 		{
-			name: "with-log",
+			name: "with-empty-composite-span",
 			input: func() []*modelpb.APMEvent {
 				event := baseEvent.CloneVT()
-				event.Log = &modelpb.Log{}
+				event.Span = &modelpb.Span{
+					Name:                "testspan",
+					Type:                "testtyp",
+					RepresentativeCount: 1,
+					DestinationService: &modelpb.DestinationService{
+						Resource: "db",
+					},
+					Composite: &modelpb.Composite{},
+				}
+				// Current test structs are hardcoded to use 1ns for spans
+				event.Event.Duration = durationpb.New(time.Nanosecond)
 				return []*modelpb.APMEvent{event}
 			},
 			partitions: 1,
@@ -244,29 +390,72 @@ func TestEventToCombinedMetrics(t *testing.T) {
 							Timestamp:   ts.Truncate(time.Minute),
 							ServiceName: "test"}).
 						AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
-						GetProto(),
+						AddSpan(spanAggregationKey{
+							SpanName: "testspan",
+							Resource: "db",
+							Outcome:  "success",
+						}).GetProto(),
 				}
 			},
 		},
 		{
-			name: "with-success-txn-followed-by-unknown-txn",
+			name: "with-normalized-span-resource",
 			input: func() []*modelpb.APMEvent {
-				success := baseEvent.CloneVT()
-				success.Transaction = &modelpb.Transaction{
-					Name:                "testtxn1",
-					Type:                "testtyp1",
+				event := baseEvent.CloneVT()
+				event.Span = &modelpb.Span{
+					Name:                "testspan",
+					Type:                "db",
 					RepresentativeCount: 1,
+					DestinationService: &modelpb.DestinationService{
+						Resource: "shard-1.cluster:9200",
+					},
 				}
-				unknown := baseEvent.CloneVT()
-				unknown.Event.Outcome = "unknown"
-				unknown.Transaction = &modelpb.Transaction{
-					Name:                "testtxn2",
-					Type:                "testtyp2",
+				// Current test structs are hardcoded to use 1ns for spans
+				event.Event.Duration = durationpb.New(time.Nanosecond)
+				return []*modelpb.APMEvent{event}
+			},
+			partitions: 1,
+			opts: []ConverterOption{
+				WithNormalizedSpanResources(func(resource string) string {
+					return strings.TrimPrefix(resource, "shard-1.")
+				}),
+			},
+			expected: func() []*aggregationpb.CombinedMetrics {
+				return []*aggregationpb.CombinedMetrics{
+					NewTestCombinedMetrics(
+						WithEventsTotal(1),
+						WithYoungestEventTimestamp(receivedTS)).
+						AddServiceMetrics(serviceAggregationKey{
+							Timestamp:   ts.Truncate(time.Minute),
+							ServiceName: "test"}).
+						AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+						AddSpan(spanAggregationKey{
+							SpanName: "testspan",
+							Resource: "cluster:9200",
+							Outcome:  "success",
+						}).GetProto(),
+				}
+			},
+		},
+		{
+			name: "with-span-subtype-key",
+			input: func() []*modelpb.APMEvent {
+				event := baseEvent.CloneVT()
+				event.Span = &modelpb.Span{
+					Name:                "testspan",
+					Type:                "db",
+					Subtype:             "elasticsearch",
 					RepresentativeCount: 1,
+					DestinationService: &modelpb.DestinationService{
+						Resource: "elasticsearch",
+					},
 				}
-				return []*modelpb.APMEvent{success, unknown}
+				// Current test structs are hardcoded to use 1ns for spans
+				event.Event.Duration = durationpb.New(time.Nanosecond)
+				return []*modelpb.APMEvent{event}
 			},
 			partitions: 1,
+			opts:       []ConverterOption{WithSpanSubtypeKey(true)},
 			expected: func() []*aggregationpb.CombinedMetrics {
 				return []*aggregationpb.CombinedMetrics{
 					NewTestCombinedMetrics(
@@ -276,14 +465,32 @@ func TestEventToCombinedMetrics(t *testing.T) {
 							Timestamp:   ts.Truncate(time.Minute),
 							ServiceName: "test"}).
 						AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
-						AddTransaction(transactionAggregationKey{
-							TransactionName: "testtxn1",
-							TransactionType: "testtyp1",
-							EventOutcome:    "success",
-						}).
-						AddServiceTransaction(serviceTransactionAggregationKey{
-							TransactionType: "testtyp1",
+						AddSpan(spanAggregationKey{
+							SpanName:    "testspan",
+							Resource:    "elasticsearch",
+							Outcome:     "success",
+							SpanSubtype: "elasticsearch",
 						}).GetProto(),
+				}
+			},
+		},
+		{
+			name: "with-canonical-service-name",
+			input: func() []*modelpb.APMEvent {
+				event := baseEvent.CloneVT()
+				event.Service.Name = "test-blue"
+				event.Metricset = &modelpb.Metricset{
+					Name:     "testmetricset",
+					Interval: "1m",
+				}
+				return []*modelpb.APMEvent{event}
+			},
+			partitions: 1,
+			opts: []ConverterOption{
+				WithCanonicalServiceNames(map[string]string{"test-blue": "test"}),
+			},
+			expected: func() []*aggregationpb.CombinedMetrics {
+				return []*aggregationpb.CombinedMetrics{
 					NewTestCombinedMetrics(
 						WithEventsTotal(1),
 						WithYoungestEventTimestamp(receivedTS)).
@@ -291,21 +498,259 @@ func TestEventToCombinedMetrics(t *testing.T) {
 							Timestamp:   ts.Truncate(time.Minute),
 							ServiceName: "test"}).
 						AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
-						AddTransaction(transactionAggregationKey{
-							TransactionName: "testtxn2",
-							TransactionType: "testtyp2",
-							EventOutcome:    "unknown",
+						GetProto(),
+				}
+			},
+		},
+		{
+			name: "with-collapsed-service-instances",
+			input: func() []*modelpb.APMEvent {
+				event := baseEvent.CloneVT()
+				event.Host = &modelpb.Host{Name: "host1"}
+				event.Labels = modelpb.Labels{"test": &modelpb.LabelValue{Global: true, Value: "1"}}
+				event.Metricset = &modelpb.Metricset{
+					Name:     "testmetricset",
+					Interval: "1m",
+				}
+				return []*modelpb.APMEvent{event}
+			},
+			partitions: 1,
+			opts: []ConverterOption{
+				WithCollapsedServiceInstances(true),
+			},
+			expected: func() []*aggregationpb.CombinedMetrics {
+				return []*aggregationpb.CombinedMetrics{
+					NewTestCombinedMetrics(
+						WithEventsTotal(1),
+						WithYoungestEventTimestamp(receivedTS)).
+						AddServiceMetrics(serviceAggregationKey{
+							Timestamp:   ts.Truncate(time.Minute),
+							ServiceName: "test"}).
+						AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+						GetProto(),
+				}
+			},
+		},
+		{
+			name: "with-instance-dimensions",
+			input: func() []*modelpb.APMEvent {
+				event := baseEvent.CloneVT()
+				event.Host = &modelpb.Host{Name: "host1"}
+				event.Container = &modelpb.Container{Id: "container1"}
+				event.Kubernetes = &modelpb.Kubernetes{PodName: "pod1"}
+				event.Metricset = &modelpb.Metricset{
+					Name:     "testmetricset",
+					Interval: "1m",
+				}
+				return []*modelpb.APMEvent{event}
+			},
+			partitions: 1,
+			opts: []ConverterOption{
+				WithServiceInstanceDimensions(HostNameDimension, KubernetesPodNameDimension),
+			},
+			expected: func() []*aggregationpb.CombinedMetrics {
+				return []*aggregationpb.CombinedMetrics{
+					NewTestCombinedMetrics(
+						WithEventsTotal(1),
+						WithYoungestEventTimestamp(receivedTS)).
+						AddServiceMetrics(serviceAggregationKey{
+							Timestamp:   ts.Truncate(time.Minute),
+							ServiceName: "test"}).
+						AddServiceInstanceMetrics(serviceInstanceAggregationKey{
+							HostName:          "host1",
+							KubernetesPodName: "pod1",
 						}).
-						AddServiceTransaction(serviceTransactionAggregationKey{
-							TransactionType: "testtyp2",
-						}, WithEventOutcome("unknown")).GetProto(),
+						GetProto(),
 				}
 			},
 		},
-	} {
-		t.Run(tc.name, func(t *testing.T) {
-			cmk := CombinedMetricsKey{
-				Interval:       time.Minute,
+		{
+			name: "with-metricset",
+			input: func() []*modelpb.APMEvent {
+				event := baseEvent.CloneVT()
+				event.Metricset = &modelpb.Metricset{
+					Name:     "testmetricset",
+					Interval: "1m",
+				}
+				return []*modelpb.APMEvent{event}
+			},
+			partitions: 1,
+			expected: func() []*aggregationpb.CombinedMetrics {
+				return []*aggregationpb.CombinedMetrics{
+					NewTestCombinedMetrics(
+						WithEventsTotal(1),
+						WithYoungestEventTimestamp(receivedTS)).
+						AddServiceMetrics(serviceAggregationKey{
+							Timestamp:   ts.Truncate(time.Minute),
+							ServiceName: "test"}).
+						AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+						GetProto(),
+				}
+			},
+		},
+		{
+			name: "with-log",
+			input: func() []*modelpb.APMEvent {
+				event := baseEvent.CloneVT()
+				event.Log = &modelpb.Log{}
+				return []*modelpb.APMEvent{event}
+			},
+			partitions: 1,
+			expected: func() []*aggregationpb.CombinedMetrics {
+				return []*aggregationpb.CombinedMetrics{
+					NewTestCombinedMetrics(
+						WithEventsTotal(1),
+						WithYoungestEventTimestamp(receivedTS)).
+						AddServiceMetrics(serviceAggregationKey{
+							Timestamp:   ts.Truncate(time.Minute),
+							ServiceName: "test"}).
+						AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+						GetProto(),
+				}
+			},
+		},
+		{
+			name: "with-error",
+			input: func() []*modelpb.APMEvent {
+				event := baseEvent.CloneVT()
+				event.Error = &modelpb.Error{GroupingKey: "grp1"}
+				return []*modelpb.APMEvent{event}
+			},
+			partitions: 1,
+			expected: func() []*aggregationpb.CombinedMetrics {
+				return []*aggregationpb.CombinedMetrics{
+					NewTestCombinedMetrics(
+						WithEventsTotal(1),
+						WithYoungestEventTimestamp(receivedTS)).
+						AddServiceMetrics(serviceAggregationKey{
+							Timestamp:   ts.Truncate(time.Minute),
+							ServiceName: "test"}).
+						AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+						GetProto(),
+				}
+			},
+		},
+		{
+			name: "with-error-metrics",
+			input: func() []*modelpb.APMEvent {
+				event := baseEvent.CloneVT()
+				event.Error = &modelpb.Error{GroupingKey: "grp1"}
+				return []*modelpb.APMEvent{event}
+			},
+			partitions: 1,
+			opts:       []ConverterOption{WithErrorMetrics(true)},
+			expected: func() []*aggregationpb.CombinedMetrics {
+				return []*aggregationpb.CombinedMetrics{
+					NewTestCombinedMetrics(
+						WithEventsTotal(1),
+						WithYoungestEventTimestamp(receivedTS)).
+						AddServiceMetrics(serviceAggregationKey{
+							Timestamp:   ts.Truncate(time.Minute),
+							ServiceName: "test"}).
+						AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+						AddError(errorAggregationKey{GroupingKey: "grp1", Outcome: "success"}, 1).
+						GetProto(),
+				}
+			},
+		},
+		{
+			name: "with-service-graph-edges",
+			input: func() []*modelpb.APMEvent {
+				event := baseEvent.CloneVT()
+				event.Event.Outcome = "failure"
+				event.Service.Target = &modelpb.ServiceTarget{Type: "http", Name: "svc2"}
+				event.Span = &modelpb.Span{
+					Name:                "testspan",
+					Type:                "external",
+					RepresentativeCount: 2,
+				}
+				// Current test structs are hardcoded to use 1ns for spans
+				event.Event.Duration = durationpb.New(time.Nanosecond)
+				return []*modelpb.APMEvent{event}
+			},
+			partitions: 1,
+			opts:       []ConverterOption{WithServiceGraphEdges(true)},
+			expected: func() []*aggregationpb.CombinedMetrics {
+				return []*aggregationpb.CombinedMetrics{
+					NewTestCombinedMetrics(
+						WithEventsTotal(1),
+						WithYoungestEventTimestamp(receivedTS)).
+						AddServiceMetrics(serviceAggregationKey{
+							Timestamp:   ts.Truncate(time.Minute),
+							ServiceName: "test"}).
+						AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+						AddSpan(spanAggregationKey{
+							SpanName:   "testspan",
+							TargetType: "http",
+							TargetName: "svc2",
+							Outcome:    "failure",
+						}, WithSpanCount(2)).
+						AddServiceGraphEdge(
+							serviceGraphEdgeAggregationKey{TargetType: "http", TargetName: "svc2"},
+							2, 2, time.Nanosecond,
+						).
+						GetProto(),
+				}
+			},
+		},
+		{
+			name: "with-success-txn-followed-by-unknown-txn",
+			input: func() []*modelpb.APMEvent {
+				success := baseEvent.CloneVT()
+				success.Transaction = &modelpb.Transaction{
+					Name:                "testtxn1",
+					Type:                "testtyp1",
+					RepresentativeCount: 1,
+				}
+				unknown := baseEvent.CloneVT()
+				unknown.Event.Outcome = "unknown"
+				unknown.Transaction = &modelpb.Transaction{
+					Name:                "testtxn2",
+					Type:                "testtyp2",
+					RepresentativeCount: 1,
+				}
+				return []*modelpb.APMEvent{success, unknown}
+			},
+			partitions: 1,
+			expected: func() []*aggregationpb.CombinedMetrics {
+				return []*aggregationpb.CombinedMetrics{
+					NewTestCombinedMetrics(
+						WithEventsTotal(1),
+						WithYoungestEventTimestamp(receivedTS)).
+						AddServiceMetrics(serviceAggregationKey{
+							Timestamp:   ts.Truncate(time.Minute),
+							ServiceName: "test"}).
+						AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+						AddTransaction(transactionAggregationKey{
+							TransactionName: "testtxn1",
+							TransactionType: "testtyp1",
+							EventOutcome:    "success",
+						}).
+						AddServiceTransaction(serviceTransactionAggregationKey{
+							TransactionType: "testtyp1",
+						}).GetProto(),
+					NewTestCombinedMetrics(
+						WithEventsTotal(1),
+						WithYoungestEventTimestamp(receivedTS)).
+						AddServiceMetrics(serviceAggregationKey{
+							Timestamp:   ts.Truncate(time.Minute),
+							ServiceName: "test"}).
+						AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+						AddTransaction(transactionAggregationKey{
+							TransactionName: "testtxn2",
+							TransactionType: "testtyp2",
+							EventOutcome:    "unknown",
+						}).
+						AddServiceTransaction(serviceTransactionAggregationKey{
+							TransactionType: "testtyp2",
+						}, WithEventOutcome("unknown")).GetProto(),
+				}
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cmk := CombinedMetricsKey{
+				Interval:       time.Minute,
 				ProcessingTime: time.Now().Truncate(time.Minute),
 				ID:             EncodeToCombinedMetricsKeyID(t, "ab01"),
 			}
@@ -318,7 +763,7 @@ func TestEventToCombinedMetrics(t *testing.T) {
 				return nil
 			}
 			for _, e := range tc.input() {
-				err := EventToCombinedMetrics(e, cmk, tc.partitions, collector)
+				err := EventToCombinedMetrics(e, cmk, tc.partitions, collector, tc.opts...)
 				require.NoError(t, err)
 			}
 			assert.Empty(t, cmp.Diff(
@@ -351,8 +796,14 @@ func TestCombinedMetricsToBatch(t *testing.T) {
 		txn            = transactionAggregationKey{TransactionName: "txn", TransactionType: "typ"}
 		txnFaas        = transactionAggregationKey{TransactionName: "txn", TransactionType: "typ",
 			FAASID: faas.Id, FAASColdstart: coldstart, FAASVersion: faas.Version, FAASTriggerType: faas.TriggerType}
-		overflowTxn = transactionAggregationKey{TransactionName: "_other"}
-		txnCount    = 100
+		overflowTxn  = transactionAggregationKey{TransactionName: "_other"}
+		txnCount     = 100
+		errGroup     = errorAggregationKey{GroupingKey: "grp", Outcome: "failure"}
+		overflowErr  = errorAggregationKey{GroupingKey: "_other"}
+		errCount     = 30
+		edge         = serviceGraphEdgeAggregationKey{TargetType: "http", TargetName: "svc2", Resource: "svc2:8080"}
+		overflowEdge = serviceGraphEdgeAggregationKey{TargetName: "_other"}
+		edgeCount    = 20
 	)
 	for _, tc := range []struct {
 		name                string
@@ -399,89 +850,1096 @@ func TestCombinedMetricsToBatch(t *testing.T) {
 			},
 		},
 		{
-			name:                "overflow",
-			aggregationInterval: aggIvl,
-			combinedMetrics: func() *aggregationpb.CombinedMetrics {
-				tcm := NewTestCombinedMetrics()
-				tcm.
-					AddServiceMetrics(svc).
-					AddServiceInstanceMetrics(svcIns).
-					AddSpan(span, WithSpanCount(spanCount)).
-					AddTransaction(txnFaas, WithTransactionCount(txnCount)).
-					AddServiceTransaction(svcTxn, WithTransactionCount(txnCount)).
-					AddTransactionOverflow(txn, WithTransactionCount(txnCount)).
-					AddServiceTransactionOverflow(svcTxn, WithTransactionCount(txnCount)).
-					AddSpanOverflow(span, WithSpanCount(spanCount))
-				// Add global service overflow
-				tcm.
-					AddServiceMetricsOverflow(
-						serviceAggregationKey{Timestamp: ts, ServiceName: "svc_overflow"}).
-					AddServiceInstanceMetricsOverflow(serviceInstanceAggregationKey{})
-				return tcm.GetProto()
-			},
-			expectedEvents: []*modelpb.APMEvent{
-				createTestTransactionMetric(ts, aggIvl, svcName, txnFaas, faas, txnCount, 0),
-				createTestServiceTransactionMetric(ts, aggIvl, svcName, svcTxn, txnCount, 0),
-				createTestSpanMetric(ts, aggIvl, svcName, span, spanCount, 0),
-				createTestServiceSummaryMetric(ts, aggIvl, svcName, 0),
-				// Events due to overflow
-				createTestTransactionMetric(processingTime, aggIvl, svcName, overflowTxn, nil, txnCount, 1),
-				createTestServiceTransactionMetric(processingTime, aggIvl, svcName, overflowSvcTxn, txnCount, 1),
-				createTestSpanMetric(processingTime, aggIvl, svcName, overflowSpan, spanCount, 1),
-				createTestServiceSummaryMetric(processingTime, aggIvl, "_other", 1),
-			},
+			name:                "overflow",
+			aggregationInterval: aggIvl,
+			combinedMetrics: func() *aggregationpb.CombinedMetrics {
+				tcm := NewTestCombinedMetrics()
+				tcm.
+					AddServiceMetrics(svc).
+					AddServiceInstanceMetrics(svcIns).
+					AddSpan(span, WithSpanCount(spanCount)).
+					AddTransaction(txnFaas, WithTransactionCount(txnCount)).
+					AddServiceTransaction(svcTxn, WithTransactionCount(txnCount)).
+					AddTransactionOverflow(txn, WithTransactionCount(txnCount)).
+					AddServiceTransactionOverflow(svcTxn, WithTransactionCount(txnCount)).
+					AddSpanOverflow(span, WithSpanCount(spanCount))
+				// Add global service overflow
+				tcm.
+					AddServiceMetricsOverflow(
+						serviceAggregationKey{Timestamp: ts, ServiceName: "svc_overflow"}).
+					AddServiceInstanceMetricsOverflow(serviceInstanceAggregationKey{})
+				return tcm.GetProto()
+			},
+			expectedEvents: []*modelpb.APMEvent{
+				createTestTransactionMetric(ts, aggIvl, svcName, txnFaas, faas, txnCount, 0),
+				createTestServiceTransactionMetric(ts, aggIvl, svcName, svcTxn, txnCount, 0),
+				createTestSpanMetric(ts, aggIvl, svcName, span, spanCount, 0),
+				createTestServiceSummaryMetric(ts, aggIvl, svcName, 0),
+				// Events due to overflow
+				createTestTransactionMetric(processingTime, aggIvl, svcName, overflowTxn, nil, txnCount, 1),
+				createTestServiceTransactionMetric(processingTime, aggIvl, svcName, overflowSvcTxn, txnCount, 1),
+				createTestSpanMetric(processingTime, aggIvl, svcName, overflowSpan, spanCount, 1),
+				createTestServiceSummaryMetric(processingTime, aggIvl, "_other", 1),
+			},
+		},
+		{
+			name:                "error_metrics",
+			aggregationInterval: aggIvl,
+			combinedMetrics: func() *aggregationpb.CombinedMetrics {
+				tcm := NewTestCombinedMetrics()
+				tcm.
+					AddServiceMetrics(svc).
+					AddServiceInstanceMetrics(svcIns).
+					AddError(errGroup, errCount).
+					AddErrorOverflow(errorAggregationKey{GroupingKey: "grp2"}, errCount)
+				tcm.
+					AddServiceMetricsOverflow(
+						serviceAggregationKey{Timestamp: ts, ServiceName: "svc_overflow"}).
+					AddServiceInstanceMetricsOverflow(serviceInstanceAggregationKey{}).
+					AddErrorOverflow(errGroup, errCount)
+				return tcm.GetProto()
+			},
+			expectedEvents: []*modelpb.APMEvent{
+				createTestErrorMetric(ts, aggIvl, svcName, errGroup, errCount, 0),
+				createTestServiceSummaryMetric(ts, aggIvl, svcName, 0),
+				// Events due to overflow
+				createTestErrorMetric(processingTime, aggIvl, svcName, overflowErr, errCount, 1),
+				createTestErrorMetric(processingTime, aggIvl, "_other", overflowErr, errCount, 1),
+				createTestServiceSummaryMetric(processingTime, aggIvl, "_other", 1),
+			},
+		},
+		{
+			name:                "instance_dimensions",
+			aggregationInterval: aggIvl,
+			combinedMetrics: func() *aggregationpb.CombinedMetrics {
+				return NewTestCombinedMetrics().
+					AddServiceMetrics(svc).
+					AddServiceInstanceMetrics(serviceInstanceAggregationKey{
+						HostName:          "host1",
+						ContainerID:       "container1",
+						KubernetesPodName: "pod1",
+					}).
+					GetProto()
+			},
+			expectedEvents: []*modelpb.APMEvent{
+				func() *modelpb.APMEvent {
+					event := createTestServiceSummaryMetric(ts, aggIvl, svcName, 0)
+					event.Host = &modelpb.Host{Name: "host1"}
+					event.Container = &modelpb.Container{Id: "container1"}
+					event.Kubernetes = &modelpb.Kubernetes{PodName: "pod1"}
+					return event
+				}(),
+			},
+		},
+		{
+			name:                "service_graph_edge_metrics",
+			aggregationInterval: aggIvl,
+			combinedMetrics: func() *aggregationpb.CombinedMetrics {
+				tcm := NewTestCombinedMetrics()
+				tcm.
+					AddServiceMetrics(svc).
+					AddServiceInstanceMetrics(svcIns).
+					AddServiceGraphEdge(edge, edgeCount, 1, time.Millisecond).
+					AddServiceGraphEdgeOverflow(
+						serviceGraphEdgeAggregationKey{Resource: "cache"}, edgeCount, 0, time.Millisecond)
+				tcm.
+					AddServiceMetricsOverflow(
+						serviceAggregationKey{Timestamp: ts, ServiceName: "svc_overflow"}).
+					AddServiceInstanceMetricsOverflow(serviceInstanceAggregationKey{}).
+					AddServiceGraphEdgeOverflow(edge, edgeCount, 0, time.Millisecond)
+				return tcm.GetProto()
+			},
+			expectedEvents: []*modelpb.APMEvent{
+				createTestServiceGraphEdgeMetric(ts, aggIvl, svcName, edge, edgeCount, 1, 0),
+				createTestServiceSummaryMetric(ts, aggIvl, svcName, 0),
+				// Events due to overflow
+				createTestServiceGraphEdgeMetric(processingTime, aggIvl, svcName, overflowEdge, edgeCount, 0, 1),
+				createTestServiceGraphEdgeMetric(processingTime, aggIvl, "_other", overflowEdge, edgeCount, 0, 1),
+				createTestServiceSummaryMetric(processingTime, aggIvl, "_other", 1),
+			},
+		},
+		{
+			name:                "service_instance_overflow_in_global_and_per_svc",
+			aggregationInterval: aggIvl,
+			combinedMetrics: func() *aggregationpb.CombinedMetrics {
+				tcm := NewTestCombinedMetrics()
+				tcm.
+					AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "svc1"}).
+					AddServiceInstanceMetrics(serviceInstanceAggregationKey{})
+				tcm.
+					AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "svc1"}).
+					AddServiceInstanceMetricsOverflow(serviceInstanceAggregationKey{GlobalLabelsStr: getTestGlobalLabelsStr(t, "1")})
+				tcm.
+					AddServiceMetricsOverflow(serviceAggregationKey{Timestamp: ts, ServiceName: "svc2"}).
+					AddServiceInstanceMetricsOverflow(serviceInstanceAggregationKey{GlobalLabelsStr: getTestGlobalLabelsStr(t, "2")})
+				return tcm.GetProto()
+			},
+			expectedEvents: []*modelpb.APMEvent{
+				createTestServiceSummaryMetric(ts, aggIvl, "svc1", 0),
+				createTestServiceSummaryMetric(processingTime, aggIvl, "_other", 2),
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := CombinedMetricsToBatch(
+				tc.combinedMetrics(),
+				processingTime,
+				tc.aggregationInterval,
+			)
+			assert.NoError(t, err)
+			assert.Empty(t, cmp.Diff(
+				tc.expectedEvents, *b,
+				cmpopts.IgnoreTypes(netip.Addr{}),
+				cmpopts.SortSlices(func(e1, e2 *modelpb.APMEvent) bool {
+					m1Name := e1.GetMetricset().GetName()
+					m2Name := e2.GetMetricset().GetName()
+					if m1Name != m2Name {
+						return m1Name < m2Name
+					}
+
+					a1Name := e1.GetAgent().GetName()
+					a2Name := e2.GetAgent().GetName()
+					if a1Name != a2Name {
+						return a1Name < a2Name
+					}
+
+					return e1.GetService().GetEnvironment() < e2.GetService().GetEnvironment()
+				}),
+				protocmp.Transform(),
+			))
+		})
+	}
+}
+
+func TestCombinedMetricsToBatchPercentiles(t *testing.T) {
+	ts := time.Now()
+	aggIvl := time.Minute
+	cm := NewTestCombinedMetrics().
+		AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "test"}).
+		AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+		AddTransaction(transactionAggregationKey{TransactionName: "txn", TransactionType: "typ"}).
+		AddServiceTransaction(serviceTransactionAggregationKey{TransactionType: "typ"}).
+		GetProto()
+
+	// 90 transactions of 1ms, 9 of 10ms and 1 of 100ms.
+	hist := hdrhistogram.New()
+	require.NoError(t, hist.RecordDuration(time.Millisecond, 90))
+	require.NoError(t, hist.RecordDuration(10*time.Millisecond, 9))
+	require.NoError(t, hist.RecordDuration(100*time.Millisecond, 1))
+	sim := cm.ServiceMetrics[0].Metrics.ServiceInstanceMetrics[0].Metrics
+	sim.TransactionMetrics[0].Metrics.Histogram = histogramToProto(hist)
+	sim.ServiceTransactionMetrics[0].Metrics.Histogram = histogramToProto(hist)
+
+	b, err := CombinedMetricsToBatch(
+		cm, ts.Truncate(aggIvl), aggIvl,
+		WithPercentiles([]float64{50, 95, 99.9}),
+	)
+	require.NoError(t, err)
+
+	var found int
+	for _, e := range *b {
+		name := e.GetMetricset().GetName()
+		if name != txnMetricsetName && name != svcTxnMetricsetName {
+			assert.Empty(t, e.GetMetricset().GetSamples())
+			continue
+		}
+		found++
+		samples := make(map[string]float64)
+		for _, s := range e.Metricset.Samples {
+			assert.Equal(t, modelpb.MetricType_METRIC_TYPE_GAUGE, s.Type)
+			assert.Equal(t, "us", s.Unit)
+			samples[s.Name] = s.Value
+		}
+		assert.InDelta(t, 1000, samples["transaction.duration.p50"], 10)
+		assert.InDelta(t, 10000, samples["transaction.duration.p95"], 100)
+		assert.InDelta(t, 100000, samples["transaction.duration.p99_9"], 1000)
+	}
+	assert.Equal(t, 2, found)
+
+	_, err = CombinedMetricsToBatch(
+		cm, ts.Truncate(aggIvl), aggIvl, WithPercentiles([]float64{0}),
+	)
+	assert.EqualError(t, err, "invalid converter options: percentile 0 must be in the range (0, 100]")
+}
+
+func TestCombinedMetricsToBatchServiceTransactionRates(t *testing.T) {
+	ts := time.Now()
+	aggIvl := 10 * time.Minute
+	cm := NewTestCombinedMetrics().
+		AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "test"}).
+		AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+		AddServiceTransaction(serviceTransactionAggregationKey{TransactionType: "typ"}).
+		GetProto()
+
+	hist := hdrhistogram.New()
+	require.NoError(t, hist.RecordDuration(time.Millisecond, 100))
+	stm := cm.ServiceMetrics[0].Metrics.ServiceInstanceMetrics[0].Metrics.ServiceTransactionMetrics[0].Metrics
+	stm.Histogram = histogramToProto(hist)
+	stm.SuccessCount = 60
+	stm.FailureCount = 20
+
+	rates := func(opts ...ConverterOption) map[string]float64 {
+		b, err := CombinedMetricsToBatch(cm, ts.Truncate(aggIvl), aggIvl, opts...)
+		require.NoError(t, err)
+		samples := make(map[string]float64)
+		for _, e := range *b {
+			if e.GetMetricset().GetName() != svcTxnMetricsetName {
+				continue
+			}
+			for _, s := range e.Metricset.Samples {
+				if strings.HasPrefix(s.Name, "service_transaction.") {
+					assert.Equal(t, modelpb.MetricType_METRIC_TYPE_GAUGE, s.Type)
+					samples[s.Name] = s.Value
+				}
+			}
+		}
+		return samples
+	}
+
+	assert.Empty(t, rates())
+	assert.Equal(t, map[string]float64{
+		"service_transaction.failure_rate":          0.25,
+		"service_transaction.throughput_per_minute": 10,
+	}, rates(WithServiceTransactionRates(true)))
+
+	// The failure rate is omitted without transactions of known outcome.
+	stm.SuccessCount = 0
+	stm.FailureCount = 0
+	assert.Equal(t, map[string]float64{
+		"service_transaction.throughput_per_minute": 10,
+	}, rates(WithServiceTransactionRates(true)))
+}
+
+func TestCombinedMetricsToBatchThroughput(t *testing.T) {
+	ts := time.Now()
+	aggIvl := 10 * time.Minute
+	cmk := CombinedMetricsKey{
+		Interval:       aggIvl,
+		ProcessingTime: ts.Truncate(aggIvl),
+		ID:             EncodeToCombinedMetricsKeyID(t, "ab01"),
+	}
+	event := &modelpb.APMEvent{
+		Timestamp: timestamppb.New(ts),
+		Service:   &modelpb.Service{Name: "test"},
+		Event: &modelpb.Event{
+			Duration: durationpb.New(time.Millisecond),
+			Outcome:  "success",
+		},
+		Transaction: &modelpb.Transaction{
+			Name:                "txn",
+			Type:                "typ",
+			RepresentativeCount: 20,
+		},
+	}
+	var cm *aggregationpb.CombinedMetrics
+	require.NoError(t, EventToCombinedMetrics(
+		event, cmk, 1,
+		func(_ CombinedMetricsKey, m *aggregationpb.CombinedMetrics) error {
+			cm = m.CloneVT()
+			return nil
+		},
+	))
+
+	throughputs := func(opts ...ConverterOption) map[string]float64 {
+		b, err := CombinedMetricsToBatch(cm, cmk.ProcessingTime, aggIvl, opts...)
+		require.NoError(t, err)
+		samples := make(map[string]float64)
+		for _, e := range *b {
+			for _, s := range e.GetMetricset().GetSamples() {
+				if s.Name == "throughput" {
+					assert.Equal(t, modelpb.MetricType_METRIC_TYPE_GAUGE, s.Type)
+					samples[e.Metricset.Name] = s.Value
+				}
+			}
+		}
+		return samples
+	}
+
+	assert.Empty(t, throughputs())
+	// The throughput is weighted by the representative count.
+	assert.Equal(t, map[string]float64{
+		txnMetricsetName:    2,
+		svcTxnMetricsetName: 2,
+	}, throughputs(WithThroughput(true)))
+}
+
+func TestCombinedMetricsToBatchMobileDimensions(t *testing.T) {
+	ts := time.Now()
+	aggIvl := time.Minute
+	cm := NewTestCombinedMetrics().
+		AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "app"}).
+		AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+		AddTransaction(transactionAggregationKey{
+			TransactionName:       "txn",
+			TransactionType:       "mobile",
+			HostOSName:            "ios",
+			HostOSVersion:         "17.0",
+			NetworkConnectionType: "cell",
+		}).
+		GetProto()
+
+	b, err := CombinedMetricsToBatch(cm, ts.Truncate(aggIvl), aggIvl)
+	require.NoError(t, err)
+	var found bool
+	for _, e := range *b {
+		if e.GetMetricset().GetName() != txnMetricsetName {
+			continue
+		}
+		found = true
+		assert.Equal(t, "ios", e.GetHost().GetOs().GetName())
+		assert.Equal(t, "17.0", e.GetHost().GetOs().GetVersion())
+		assert.Equal(t, "cell", e.GetNetwork().GetConnection().GetType())
+	}
+	assert.True(t, found)
+}
+
+func TestKubernetesDimensions(t *testing.T) {
+	ts := time.Now()
+	aggIvl := time.Minute
+	cmk := CombinedMetricsKey{
+		Interval:       aggIvl,
+		ProcessingTime: ts.Truncate(aggIvl),
+		ID:             EncodeToCombinedMetricsKeyID(t, "ab01"),
+	}
+	event := &modelpb.APMEvent{
+		Timestamp:  timestamppb.New(ts),
+		Service:    &modelpb.Service{Name: "test"},
+		Kubernetes: &modelpb.Kubernetes{Namespace: "ns1", PodName: "dep1-7d9c8b-x2k4p"},
+		Labels: modelpb.Labels{
+			KubernetesDeploymentLabel: &modelpb.LabelValue{Value: "dep1"},
+		},
+		Event: &modelpb.Event{Duration: durationpb.New(time.Millisecond)},
+		Transaction: &modelpb.Transaction{
+			Name:                "txn",
+			Type:                "typ",
+			RepresentativeCount: 1,
+		},
+	}
+	serviceKey := func(opts ...ConverterOption) *aggregationpb.ServiceAggregationKey {
+		var cm *aggregationpb.CombinedMetrics
+		require.NoError(t, EventToCombinedMetrics(
+			event, cmk, 1,
+			func(_ CombinedMetricsKey, m *aggregationpb.CombinedMetrics) error {
+				cm = m.CloneVT()
+				return nil
+			},
+			opts...,
+		))
+		require.Len(t, cm.ServiceMetrics, 1)
+		return cm.ServiceMetrics[0].Key
+	}
+
+	assert.Empty(t, serviceKey().KubernetesNamespace)
+	sk := serviceKey(WithKubernetesDimensions(true))
+	assert.Equal(t, "ns1", sk.KubernetesNamespace)
+	assert.Equal(t, "dep1", sk.KubernetesDeploymentName)
+
+	cm := NewTestCombinedMetrics().
+		AddServiceMetrics(serviceAggregationKey{
+			Timestamp:                ts,
+			ServiceName:              "test",
+			KubernetesNamespace:      "ns1",
+			KubernetesDeploymentName: "dep1",
+		}).
+		AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+		AddTransaction(transactionAggregationKey{TransactionName: "txn", TransactionType: "typ"}).
+		GetProto()
+	b, err := CombinedMetricsToBatch(cm, cmk.ProcessingTime, aggIvl)
+	require.NoError(t, err)
+	require.NotEmpty(t, *b)
+	for _, e := range *b {
+		assert.Equal(t, "ns1", e.GetKubernetes().GetNamespace())
+		assert.Equal(t, "dep1", e.GetLabels()[KubernetesDeploymentLabel].GetValue())
+	}
+}
+
+func TestEventToCombinedMetricsPartitionFunc(t *testing.T) {
+	ts := time.Now()
+	cmk := CombinedMetricsKey{
+		Interval:       time.Minute,
+		ProcessingTime: ts.Truncate(time.Minute),
+		ID:             EncodeToCombinedMetricsKeyID(t, "ab01"),
+	}
+	event := &modelpb.APMEvent{
+		Timestamp: timestamppb.New(ts),
+		Service:   &modelpb.Service{Name: "test"},
+		Event:     &modelpb.Event{Duration: durationpb.New(time.Millisecond)},
+		Transaction: &modelpb.Transaction{
+			Name:                "txn",
+			Type:                "typ",
+			RepresentativeCount: 1,
+		},
+	}
+	partitionIDs := func(opts ...ConverterOption) []uint16 {
+		var ids []uint16
+		require.NoError(t, EventToCombinedMetrics(
+			event, cmk, 4,
+			func(k CombinedMetricsKey, _ *aggregationpb.CombinedMetrics) error {
+				ids = append(ids, k.PartitionID)
+				return nil
+			},
+			opts...,
+		))
+		return ids
+	}
+
+	// The transaction and service transaction metrics are assigned to the
+	// partition of the partitioner, modulo the number of partitions.
+	assert.Equal(t, []uint16{2}, partitionIDs(WithPartitionFunc(func(uint64) uint16 { return 2 })))
+	assert.Equal(t, []uint16{1}, partitionIDs(WithPartitionFunc(func(uint64) uint16 { return 5 })))
+	var hashes []uint64
+	partitionIDs(WithPartitionFunc(func(h uint64) uint16 {
+		hashes = append(hashes, h)
+		return 0
+	}))
+	assert.Len(t, hashes, 2)
+}
+
+func TestCombinedMetricsToBatchDurationSum(t *testing.T) {
+	ts := time.Now()
+	aggIvl := time.Minute
+	cmk := CombinedMetricsKey{
+		Interval:       aggIvl,
+		ProcessingTime: ts.Truncate(aggIvl),
+		ID:             EncodeToCombinedMetricsKeyID(t, "ab01"),
+	}
+	event := &modelpb.APMEvent{
+		Timestamp: timestamppb.New(ts),
+		Service:   &modelpb.Service{Name: "test"},
+		Event: &modelpb.Event{
+			Duration: durationpb.New(123456789 * time.Nanosecond),
+			Outcome:  "success",
+		},
+		Transaction: &modelpb.Transaction{
+			Name:                "txn",
+			Type:                "typ",
+			RepresentativeCount: 2,
+		},
+	}
+	var cm *aggregationpb.CombinedMetrics
+	require.NoError(t, EventToCombinedMetrics(
+		event, cmk, 1,
+		func(_ CombinedMetricsKey, m *aggregationpb.CombinedMetrics) error {
+			cm = m.CloneVT()
+			return nil
+		},
+		WithDurationSummarySum(RecordedSumEstimate),
+	))
+	// The sum is recorded without truncating the sub-microsecond part.
+	const recordedSum = 2 * 123456.789
+	sim := cm.ServiceMetrics[0].Metrics.ServiceInstanceMetrics[0].Metrics
+	assert.Equal(t, float64(recordedSum), sim.TransactionMetrics[0].Metrics.Sum)
+	assert.Equal(t, float64(recordedSum), sim.ServiceTransactionMetrics[0].Metrics.Sum)
+
+	durationSums := func(opts ...ConverterOption) []float64 {
+		b, err := CombinedMetricsToBatch(cm, cmk.ProcessingTime, aggIvl, opts...)
+		require.NoError(t, err)
+		var sums []float64
+		for _, e := range *b {
+			if summary := e.GetTransaction().GetDurationSummary(); summary != nil {
+				assert.Equal(t, uint64(2), summary.Count)
+				sums = append(sums, summary.Sum)
+			}
+		}
+		require.Len(t, sums, 2)
+		assert.Equal(t, sums[0], sums[1])
+		return sums
+	}
+	upper := durationSums()[0]
+	assert.Equal(t, upper, durationSums(WithDurationSummarySum(UpperBoundSumEstimate))[0])
+	lower := durationSums(WithDurationSummarySum(LowerBoundSumEstimate))[0]
+	midpoint := durationSums(WithDurationSummarySum(MidpointSumEstimate))[0]
+	recorded := durationSums(WithDurationSummarySum(RecordedSumEstimate))[0]
+	assert.Equal(t, float64(recordedSum), recorded)
+	assert.LessOrEqual(t, lower, recorded)
+	assert.GreaterOrEqual(t, upper, recorded)
+	assert.Less(t, lower, upper)
+	assert.Equal(t, (lower+upper)/2, midpoint)
+
+	// Without a recorded sum, the upper bound estimate is used.
+	sim.TransactionMetrics[0].Metrics.Sum = 0
+	sim.ServiceTransactionMetrics[0].Metrics.Sum = 0
+	assert.Equal(t, upper, durationSums(WithDurationSummarySum(RecordedSumEstimate))[0])
+
+	_, err := CombinedMetricsToBatch(
+		cm, cmk.ProcessingTime, aggIvl, WithDurationSummarySum(RecordedSumEstimate+1),
+	)
+	assert.EqualError(t, err, "invalid converter options: unsupported duration sum estimate 4")
+}
+
+func TestEventToCombinedMetricsDurationExtremes(t *testing.T) {
+	ts := time.Now()
+	aggIvl := time.Minute
+	cmk := CombinedMetricsKey{
+		Interval:       aggIvl,
+		ProcessingTime: ts.Truncate(aggIvl),
+		ID:             EncodeToCombinedMetricsKeyID(t, "ab01"),
+	}
+	event := &modelpb.APMEvent{
+		Timestamp: timestamppb.New(ts),
+		Service:   &modelpb.Service{Name: "test"},
+		Event: &modelpb.Event{
+			Duration: durationpb.New(100 * time.Millisecond),
+			Outcome:  "success",
+		},
+		Transaction: &modelpb.Transaction{
+			Name:                "txn",
+			Type:                "typ",
+			RepresentativeCount: 1,
+		},
+	}
+	harvestSamples := func(opts ...ConverterOption) [][]*modelpb.MetricsetSample {
+		var cm *aggregationpb.CombinedMetrics
+		require.NoError(t, EventToCombinedMetrics(
+			event, cmk, 1,
+			func(_ CombinedMetricsKey, m *aggregationpb.CombinedMetrics) error {
+				cm = m.CloneVT()
+				return nil
+			},
+			opts...,
+		))
+		b, err := CombinedMetricsToBatch(cm, cmk.ProcessingTime, aggIvl)
+		require.NoError(t, err)
+		var samples [][]*modelpb.MetricsetSample
+		for _, e := range *b {
+			if e.GetTransaction().GetDurationSummary() != nil {
+				samples = append(samples, e.GetMetricset().GetSamples())
+			}
+		}
+		require.Len(t, samples, 2)
+		return samples
+	}
+
+	// The extremes are not recorded by default.
+	for _, samples := range harvestSamples() {
+		assert.Empty(t, samples)
+	}
+	for _, samples := range harvestSamples(WithDurationExtremes(true)) {
+		assert.Empty(t, cmp.Diff(
+			testDurationExtremes(100*time.Millisecond), samples,
+			protocmp.Transform(),
+		))
+	}
+}
+
+func TestEventToCombinedMetricsHistogramPrecision(t *testing.T) {
+	ts := time.Now()
+	cmk := CombinedMetricsKey{
+		Interval:       time.Minute,
+		ProcessingTime: ts.Truncate(time.Minute),
+		ID:             EncodeToCombinedMetricsKeyID(t, "ab01"),
+	}
+	event := &modelpb.APMEvent{
+		Timestamp: timestamppb.New(ts),
+		Service:   &modelpb.Service{Name: "test"},
+		Event: &modelpb.Event{
+			Duration: durationpb.New(2 * time.Minute),
+			Outcome:  "success",
+		},
+		Transaction: &modelpb.Transaction{
+			Name:                "txn",
+			Type:                "typ",
+			RepresentativeCount: 1,
+		},
+	}
+	histogram := func(opts ...ConverterOption) *aggregationpb.HDRHistogram {
+		var cm *aggregationpb.CombinedMetrics
+		require.NoError(t, EventToCombinedMetrics(
+			event, cmk, 1,
+			func(_ CombinedMetricsKey, m *aggregationpb.CombinedMetrics) error {
+				cm = m.CloneVT()
+				return nil
+			},
+			opts...,
+		))
+		sim := cm.ServiceMetrics[0].Metrics.ServiceInstanceMetrics[0].Metrics
+		return sim.TransactionMetrics[0].Metrics.Histogram
+	}
+
+	coarse := histogram(WithDurationHistogramPrecision(1, time.Minute))
+	assert.Equal(t, int64(1), coarse.SignificantFigures)
+	assert.Equal(t, time.Minute.Microseconds(), coarse.HighestTrackableValue)
+	fine := histogram()
+	assert.Greater(t, fine.Buckets[0], coarse.Buckets[0])
+
+	// Histograms with different precisions are merged with the precision
+	// of the histogram merged into.
+	mergeHistogram(coarse, fine)
+	assert.Equal(t, int64(1), coarse.SignificantFigures)
+	require.Len(t, coarse.Buckets, 1)
+	assert.Equal(t, int64(2000), coarse.Counts[0])
+
+	err := EventToCombinedMetrics(
+		event, cmk, 1,
+		func(CombinedMetricsKey, *aggregationpb.CombinedMetrics) error { return nil },
+		WithDurationHistogramPrecision(0, time.Minute),
+	)
+	assert.EqualError(t, err, "invalid converter options: histogram significant figures must be between 1 and 5")
+}
+
+func TestCombinedMetricsToBatchCustomDimensions(t *testing.T) {
+	ts := time.Now()
+	aggIvl := time.Minute
+	cmk := CombinedMetricsKey{
+		Interval:       aggIvl,
+		ProcessingTime: ts.Truncate(aggIvl),
+		ID:             EncodeToCombinedMetricsKeyID(t, "ab01"),
+	}
+	extractor := func(e *modelpb.APMEvent, ks *KeySet) {
+		ks.Service.Set("cloud.region", e.GetCloud().GetRegion())
+		if e.GetFaas().GetColdStart() {
+			ks.Transaction.Set("faas.coldstart", "true")
+		}
+		ks.Span.Set("span.db", "true")
+	}
+	coldstart := true
+	events := []*modelpb.APMEvent{
+		{
+			Timestamp: timestamppb.New(ts),
+			Service:   &modelpb.Service{Name: "test"},
+			Cloud:     &modelpb.Cloud{Region: "us-east-1"},
+			Faas:      &modelpb.Faas{ColdStart: &coldstart},
+			Event:     &modelpb.Event{Duration: durationpb.New(time.Millisecond)},
+			Transaction: &modelpb.Transaction{
+				Name:                "txn",
+				Type:                "typ",
+				RepresentativeCount: 1,
+			},
+		},
+		{
+			Timestamp: timestamppb.New(ts),
+			Service: &modelpb.Service{
+				Name:   "test",
+				Target: &modelpb.ServiceTarget{Type: "db", Name: "mysql"},
+			},
+			Cloud: &modelpb.Cloud{Region: "us-east-1"},
+			Event: &modelpb.Event{Duration: durationpb.New(time.Millisecond)},
+			Span: &modelpb.Span{
+				Name:                "SELECT",
+				Type:                "db",
+				RepresentativeCount: 1,
+			},
+		},
+	}
+	var cms []*aggregationpb.CombinedMetrics
+	for _, e := range events {
+		require.NoError(t, EventToCombinedMetrics(
+			e, cmk, 1,
+			func(_ CombinedMetricsKey, m *aggregationpb.CombinedMetrics) error {
+				cms = append(cms, m.CloneVT())
+				return nil
+			},
+			WithCustomDimensions(extractor),
+		))
+	}
+
+	labels := make(map[string]map[string]string)
+	for _, cm := range cms {
+		b, err := CombinedMetricsToBatch(cm, cmk.ProcessingTime, aggIvl)
+		require.NoError(t, err)
+		for _, e := range *b {
+			name := e.GetMetricset().GetName()
+			if labels[name] == nil {
+				labels[name] = make(map[string]string)
+			}
+			for k, v := range e.Labels {
+				labels[name][k] = v.Value
+			}
+		}
+	}
+	assert.Equal(t, map[string]map[string]string{
+		txnMetricsetName: {
+			"cloud.region":   "us-east-1",
+			"faas.coldstart": "true",
+		},
+		svcTxnMetricsetName: {
+			"cloud.region": "us-east-1",
+		},
+		spanMetricsetName: {
+			"cloud.region": "us-east-1",
+			"span.db":      "true",
+		},
+		summaryMetricsetName: {
+			"cloud.region": "us-east-1",
+		},
+	}, labels)
+}
+
+func TestCombinedMetricsToBatchDocumentIDs(t *testing.T) {
+	ts := time.Now()
+	aggIvl := time.Minute
+	processingTime := ts.Truncate(aggIvl)
+	cm := func() *aggregationpb.CombinedMetrics {
+		tcm := NewTestCombinedMetrics()
+		tcm.
+			AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "svc1"}).
+			AddServiceInstanceMetrics(serviceInstanceAggregationKey{
+				GlobalLabelsStr: getTestGlobalLabelsStr(t, "1"),
+			}).
+			AddSpan(spanAggregationKey{SpanName: "spn1", Resource: "db"}).
+			AddSpan(spanAggregationKey{SpanName: "spn2", Resource: "db"}).
+			AddTransaction(transactionAggregationKey{TransactionName: "txn", TransactionType: "typ"}).
+			AddServiceTransaction(serviceTransactionAggregationKey{TransactionType: "typ"}).
+			AddTransactionOverflow(transactionAggregationKey{TransactionName: "txn2", TransactionType: "typ"})
+		tcm.
+			AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "svc2"}).
+			AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+			AddTransaction(transactionAggregationKey{TransactionName: "txn", TransactionType: "typ"})
+		tcm.
+			AddServiceMetricsOverflow(serviceAggregationKey{Timestamp: ts, ServiceName: "svc3"}).
+			AddServiceInstanceMetricsOverflow(serviceInstanceAggregationKey{})
+		return tcm.GetProto()
+	}
+	documentIDs := func(processingTime time.Time, opts ...ConverterOption) []string {
+		b, err := CombinedMetricsToBatch(cm(), processingTime, aggIvl, opts...)
+		require.NoError(t, err)
+		ids := make([]string, 0, len(*b))
+		var withGlobalLabels int
+		for _, e := range *b {
+			if e.GetLabels()["test"].GetValue() == "1" {
+				withGlobalLabels++
+			}
+			if id := e.GetLabels()[DocumentIDLabel].GetValue(); id != "" {
+				ids = append(ids, id)
+			}
+		}
+		// Global labels of svc1 must be retained for all of its
+		// non overflow events.
+		assert.Equal(t, 5, withGlobalLabels)
+		return ids
+	}
+
+	assert.Empty(t, documentIDs(processingTime))
+
+	ids := documentIDs(processingTime, WithDocumentIDs(true))
+	require.Len(t, ids, 9)
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		assert.False(t, seen[id], "duplicate document ID %s", id)
+		seen[id] = true
+	}
+	assert.ElementsMatch(t, ids, documentIDs(processingTime, WithDocumentIDs(true)))
+	for _, id := range documentIDs(processingTime.Add(aggIvl), WithDocumentIDs(true)) {
+		assert.False(t, seen[id], "document ID %s reused for different processing time", id)
+	}
+}
+
+func TestCombinedMetricsToBatchServiceSummaryIntervals(t *testing.T) {
+	ts := time.Now()
+	cm := NewTestCombinedMetrics().
+		AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "test"}).
+		AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+		AddTransaction(transactionAggregationKey{TransactionName: "txn", TransactionType: "typ"}).
+		AddServiceTransaction(serviceTransactionAggregationKey{TransactionType: "typ"}).
+		GetProto()
+	metricsets := func(aggIvl time.Duration, opts ...ConverterOption) []string {
+		b, err := CombinedMetricsToBatch(cm, ts.Truncate(aggIvl), aggIvl, opts...)
+		require.NoError(t, err)
+		var names []string
+		for _, e := range *b {
+			names = append(names, e.GetMetricset().GetName())
+		}
+		return names
+	}
+
+	all := []string{txnMetricsetName, svcTxnMetricsetName, summaryMetricsetName}
+	assert.ElementsMatch(t, all, metricsets(time.Minute))
+	assert.ElementsMatch(t, all, metricsets(time.Hour))
+
+	opt := WithServiceSummaryIntervals(time.Minute)
+	assert.ElementsMatch(t, all, metricsets(time.Minute, opt))
+	assert.ElementsMatch(t,
+		[]string{txnMetricsetName, svcTxnMetricsetName},
+		metricsets(time.Hour, opt),
+	)
+
+	_, err := CombinedMetricsToBatch(cm, ts, time.Minute, WithServiceSummaryIntervals(0))
+	assert.EqualError(t, err, "invalid converter options: service summary interval 0s must be positive")
+}
+
+func TestCombinedMetricsToBatchMetricsetNames(t *testing.T) {
+	ts := time.Now()
+	cm := NewTestCombinedMetrics().
+		AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "test"}).
+		AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+		AddTransaction(transactionAggregationKey{TransactionName: "txn", TransactionType: "typ"}).
+		AddServiceTransaction(serviceTransactionAggregationKey{TransactionType: "typ"}).
+		AddSpan(spanAggregationKey{SpanName: "spn"}).
+		GetProto()
+	metricsets := func(opts ...ConverterOption) map[string]string {
+		b, err := CombinedMetricsToBatch(cm, ts.Truncate(time.Minute), time.Minute, opts...)
+		require.NoError(t, err)
+		intervals := make(map[string]string)
+		for _, e := range *b {
+			intervals[e.GetMetricset().GetName()] = e.GetMetricset().GetInterval()
+		}
+		return intervals
+	}
+
+	assert.Equal(t, map[string]string{
+		txnMetricsetName:     "1m",
+		svcTxnMetricsetName:  "1m",
+		spanMetricsetName:    "1m",
+		summaryMetricsetName: "1m",
+	}, metricsets())
+	assert.Equal(t, map[string]string{
+		"apm_transaction":         "60s",
+		svcTxnMetricsetName:       "60s",
+		"apm_service_destination": "60s",
+	}, metricsets(
+		WithMetricsetNames(map[string]string{
+			txnMetricsetName:  "apm_transaction",
+			spanMetricsetName: "apm_service_destination",
+		}),
+		WithSuppressedMetricsets(summaryMetricsetName),
+		WithIntervalFormat(func(d time.Duration) string {
+			return fmt.Sprintf("%.0fs", d.Seconds())
+		}),
+	))
+
+	for _, tc := range []struct {
+		opt         ConverterOption
+		expectedErr string
+	}{
+		{
+			opt:         WithMetricsetNames(map[string]string{"unknown": "x"}),
+			expectedErr: `invalid converter options: unknown metricset "unknown"`,
+		},
+		{
+			opt:         WithMetricsetNames(map[string]string{txnMetricsetName: ""}),
+			expectedErr: `invalid converter options: metricset name for "transaction" must not be empty`,
+		},
+		{
+			opt:         WithSuppressedMetricsets("unknown"),
+			expectedErr: `invalid converter options: unknown metricset "unknown"`,
+		},
+	} {
+		_, err := CombinedMetricsToBatch(cm, ts, time.Minute, tc.opt)
+		assert.EqualError(t, err, tc.expectedErr)
+	}
+}
+
+func TestCombinedMetricsToBatchIntervalMetricsets(t *testing.T) {
+	ts := time.Now()
+	cm := NewTestCombinedMetrics().
+		AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "test"}).
+		AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+		AddTransaction(transactionAggregationKey{TransactionName: "txn", TransactionType: "typ"}).
+		AddServiceTransaction(serviceTransactionAggregationKey{TransactionType: "typ"}).
+		AddSpan(spanAggregationKey{SpanName: "spn"}).
+		GetProto()
+	opt := WithIntervalMetricsets(map[time.Duration][]MetricsetType{
+		time.Minute:      {TransactionMetricset, ServiceDestinationMetricset, ServiceSummaryMetricset},
+		10 * time.Minute: {TransactionMetricset},
+	})
+	metricsets := func(aggIvl time.Duration) []string {
+		b, err := CombinedMetricsToBatch(cm, ts.Truncate(aggIvl), aggIvl, opt)
+		require.NoError(t, err)
+		var names []string
+		for _, e := range *b {
+			names = append(names, e.GetMetricset().GetName())
+		}
+		return names
+	}
+
+	assert.ElementsMatch(t, []string{txnMetricsetName, spanMetricsetName, summaryMetricsetName}, metricsets(time.Minute))
+	assert.ElementsMatch(t, []string{txnMetricsetName}, metricsets(10*time.Minute))
+	assert.ElementsMatch(t, []string{
+		txnMetricsetName, svcTxnMetricsetName, spanMetricsetName, summaryMetricsetName,
+	}, metricsets(time.Hour))
+
+	for _, tc := range []struct {
+		opt         ConverterOption
+		expectedErr string
+	}{
+		{
+			opt:         WithIntervalMetricsets(map[time.Duration][]MetricsetType{0: {TransactionMetricset}}),
+			expectedErr: "invalid converter options: metricsets interval 0s must be positive",
+		},
+		{
+			opt:         WithIntervalMetricsets(map[time.Duration][]MetricsetType{time.Minute: {100}}),
+			expectedErr: "invalid converter options: unsupported metricset type 100",
 		},
+	} {
+		_, err := CombinedMetricsToBatch(cm, ts, time.Minute, tc.opt)
+		assert.EqualError(t, err, tc.expectedErr)
+	}
+}
+
+func TestCombinedMetricsToBatchTemporality(t *testing.T) {
+	ts := time.Now()
+	cm := NewTestCombinedMetrics().
+		AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "test"}).
+		AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+		AddTransaction(
+			transactionAggregationKey{TransactionName: "txn", TransactionType: "typ", EventOutcome: "success"},
+			WithTransactionCount(2),
+		).
+		AddSpan(spanAggregationKey{SpanName: "spn"}, WithSpanCount(3), WithSpanDuration(time.Second)).
+		AddError(errorAggregationKey{GroupingKey: "err"}, 4).
+		GetProto()
+	eventsByMetricset := func(b *modelpb.Batch) map[string]*modelpb.APMEvent {
+		events := make(map[string]*modelpb.APMEvent)
+		for _, e := range *b {
+			events[e.GetMetricset().GetName()] = e
+		}
+		return events
+	}
+
+	b, err := CombinedMetricsToBatch(cm, ts.Truncate(time.Minute), time.Minute,
+		WithTemporality(DeltaTemporality, nil),
+	)
+	require.NoError(t, err)
+	for _, e := range *b {
+		assert.Equal(t, "delta", e.Labels[TemporalityLabel].GetValue())
+	}
+
+	deltaDurationSum := eventsByMetricset(b)[txnMetricsetName].Transaction.DurationSummary.Sum
+	counters := NewCumulativeCounters(time.Hour)
+	opt := WithTemporality(CumulativeTemporality, counters)
+	for i := 1; i <= 3; i++ {
+		b, err := CombinedMetricsToBatch(cm, ts.Truncate(time.Minute).Add(time.Duration(i)*time.Minute), time.Minute, opt)
+		require.NoError(t, err)
+		events := eventsByMetricset(b)
+		for _, e := range events {
+			assert.Equal(t, "cumulative", e.Labels[TemporalityLabel].GetValue())
+		}
+
+		txn := events[txnMetricsetName]
+		assert.Equal(t, uint64(2*i), txn.Metricset.DocCount)
+		assert.Equal(t, uint64(2*i), txn.Transaction.DurationSummary.Count)
+		assert.Equal(t, float64(i)*deltaDurationSum, txn.Transaction.DurationSummary.Sum)
+		assert.Equal(t, []uint64{uint64(2 * i)}, txn.Transaction.DurationHistogram.Counts)
+		assert.Equal(t, uint64(2*i), txn.Event.SuccessCount.Count)
+		assert.Equal(t, float64(2*i), txn.Event.SuccessCount.Sum)
+
+		span := events[spanMetricsetName]
+		assert.Equal(t, uint64(3*i), span.Span.DestinationService.ResponseTime.Count)
+		assert.Equal(t, time.Duration(3*i)*time.Second, span.Span.DestinationService.ResponseTime.Sum.AsDuration())
+
+		errEvent := events[errorMetricsetName]
+		for _, sample := range errEvent.Metricset.Samples {
+			switch sample.Name {
+			case "error.count":
+				assert.Equal(t, float64(4*i), sample.Value)
+			case "error.rate":
+				assert.Equal(t, float64(4)/60, sample.Value)
+			}
+		}
+	}
+	assert.Equal(t, 4, counters.Len())
+
+	// Counters are accumulated per aggregation interval.
+	b, err = CombinedMetricsToBatch(cm, ts.Truncate(time.Hour), time.Hour, opt)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), eventsByMetricset(b)[txnMetricsetName].Metricset.DocCount)
+	assert.Equal(t, 8, counters.Len())
+
+	for _, tc := range []struct {
+		opt         ConverterOption
+		expectedErr string
+	}{
 		{
-			name:                "service_instance_overflow_in_global_and_per_svc",
-			aggregationInterval: aggIvl,
-			combinedMetrics: func() *aggregationpb.CombinedMetrics {
-				tcm := NewTestCombinedMetrics()
-				tcm.
-					AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "svc1"}).
-					AddServiceInstanceMetrics(serviceInstanceAggregationKey{})
-				tcm.
-					AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "svc1"}).
-					AddServiceInstanceMetricsOverflow(serviceInstanceAggregationKey{GlobalLabelsStr: getTestGlobalLabelsStr(t, "1")})
-				tcm.
-					AddServiceMetricsOverflow(serviceAggregationKey{Timestamp: ts, ServiceName: "svc2"}).
-					AddServiceInstanceMetricsOverflow(serviceInstanceAggregationKey{GlobalLabelsStr: getTestGlobalLabelsStr(t, "2")})
-				return tcm.GetProto()
-			},
-			expectedEvents: []*modelpb.APMEvent{
-				createTestServiceSummaryMetric(ts, aggIvl, "svc1", 0),
-				createTestServiceSummaryMetric(processingTime, aggIvl, "_other", 2),
-			},
+			opt:         WithTemporality(CumulativeTemporality, nil),
+			expectedErr: "invalid converter options: cumulative temporality requires cumulative counters",
+		},
+		{
+			opt:         WithTemporality(100, counters),
+			expectedErr: "invalid converter options: unsupported temporality 100",
 		},
+	} {
+		_, err := CombinedMetricsToBatch(cm, ts, time.Minute, tc.opt)
+		assert.EqualError(t, err, tc.expectedErr)
+	}
+}
+
+func TestCombinedMetricsToBatchConfigFingerprint(t *testing.T) {
+	ts := time.Now()
+	cm := NewTestCombinedMetrics().
+		AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "test"}).
+		AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+		AddTransaction(transactionAggregationKey{TransactionName: "txn", TransactionType: "typ"}).
+		AddSpan(spanAggregationKey{SpanName: "spn"}).
+		GetProto()
+
+	b, err := CombinedMetricsToBatch(cm, ts.Truncate(time.Minute), time.Minute)
+	require.NoError(t, err)
+	for _, e := range *b {
+		assert.NotContains(t, e.Labels, ConfigFingerprintLabel)
+	}
+
+	b, err = CombinedMetricsToBatch(cm, ts.Truncate(time.Minute), time.Minute, WithConfigFingerprint("abc"))
+	require.NoError(t, err)
+	require.NotEmpty(t, *b)
+	for _, e := range *b {
+		assert.Equal(t, "abc", e.Labels[ConfigFingerprintLabel].GetValue())
+	}
+}
+
+func TestCombinedMetricsToBatches(t *testing.T) {
+	ts := time.Now()
+	pt := ts.Truncate(time.Minute)
+	tcm := NewTestCombinedMetrics().
+		AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "test"}).
+		AddServiceInstanceMetrics(serviceInstanceAggregationKey{})
+	for i := 0; i < 5; i++ {
+		tcm.AddTransaction(transactionAggregationKey{
+			TransactionName: fmt.Sprintf("txn%d", i),
+			TransactionType: "typ",
+		})
+	}
+	cm := tcm.GetProto()
+
+	expected, err := CombinedMetricsToBatch(cm, pt, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, *expected, 6)
+	var maxEventSize int
+	for _, e := range *expected {
+		if size := e.SizeVT(); size > maxEventSize {
+			maxEventSize = size
+		}
+	}
+
+	for _, tc := range []struct {
+		name          string
+		maxEvents     int
+		maxBytes      int
+		expectedSizes []int
+	}{
+		{name: "unlimited", expectedSizes: []int{6}},
+		{name: "max_events", maxEvents: 4, expectedSizes: []int{4, 2}},
+		{name: "max_bytes", maxBytes: 2*maxEventSize + 1, expectedSizes: []int{2, 2, 2}},
+		{name: "oversized_events", maxBytes: 1, expectedSizes: []int{1, 1, 1, 1, 1, 1}},
+		{name: "max_events_and_bytes", maxEvents: 1, maxBytes: 1 << 20, expectedSizes: []int{1, 1, 1, 1, 1, 1}},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
-			b, err := CombinedMetricsToBatch(
-				tc.combinedMetrics(),
-				processingTime,
-				tc.aggregationInterval,
+			batches, err := CombinedMetricsToBatches(
+				cm, pt, time.Minute, WithMaxOutputBatchSize(tc.maxEvents, tc.maxBytes),
 			)
-			assert.NoError(t, err)
+			require.NoError(t, err)
+			var sizes []int
+			var events modelpb.Batch
+			for _, b := range batches {
+				sizes = append(sizes, len(*b))
+				events = append(events, *b...)
+			}
+			assert.Equal(t, tc.expectedSizes, sizes)
 			assert.Empty(t, cmp.Diff(
-				tc.expectedEvents, *b,
-				cmpopts.IgnoreTypes(netip.Addr{}),
-				cmpopts.SortSlices(func(e1, e2 *modelpb.APMEvent) bool {
-					m1Name := e1.GetMetricset().GetName()
-					m2Name := e2.GetMetricset().GetName()
-					if m1Name != m2Name {
-						return m1Name < m2Name
-					}
-
-					a1Name := e1.GetAgent().GetName()
-					a2Name := e2.GetAgent().GetName()
-					if a1Name != a2Name {
-						return a1Name < a2Name
-					}
-
-					return e1.GetService().GetEnvironment() < e2.GetService().GetEnvironment()
-				}),
+				*expected, events,
 				protocmp.Transform(),
+				protocmp.IgnoreFields(&modelpb.Event{}, "received"),
 			))
 		})
 	}
+
+	batches, err := CombinedMetricsToBatches(nil, pt, time.Minute, WithMaxOutputBatchSize(1, 0))
+	require.NoError(t, err)
+	assert.Empty(t, batches)
+
+	_, err = CombinedMetricsToBatches(cm, pt, time.Minute, WithMaxOutputBatchSize(-1, 0))
+	assert.EqualError(t, err, "invalid converter options: max output batch events must not be negative")
+	_, err = CombinedMetricsToBatches(cm, pt, time.Minute, WithMaxOutputBatchSize(0, -1))
+	assert.EqualError(t, err, "invalid converter options: max output batch bytes must not be negative")
 }
 
 func BenchmarkCombinedMetricsToBatch(b *testing.B) {
@@ -521,6 +1979,38 @@ func BenchmarkCombinedMetricsToBatch(b *testing.B) {
 	}
 }
 
+func TestEventToCombinedMetricsAllocs(t *testing.T) {
+	event := &modelpb.APMEvent{
+		Timestamp: timestamppb.Now(),
+		Service:   &modelpb.Service{Name: "test"},
+		Event: &modelpb.Event{
+			Duration: durationpb.New(time.Second),
+			Outcome:  "success",
+		},
+		Transaction: &modelpb.Transaction{
+			RepresentativeCount: 1,
+			Name:                "testtxn",
+			Type:                "testtyp",
+		},
+	}
+	cmk := CombinedMetricsKey{
+		Interval:       time.Minute,
+		ProcessingTime: time.Now().Truncate(time.Minute),
+		ID:             EncodeToCombinedMetricsKeyID(t, "ab01"),
+	}
+	cfg, err := newConverterConfig(WithEventExemplars(true))
+	require.NoError(t, err)
+	noop := func(_ CombinedMetricsKey, _ *aggregationpb.CombinedMetrics) error {
+		return nil
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := eventToCombinedMetrics(event, cmk, 2, noop, &cfg); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assert.Zero(t, allocs)
+}
+
 func BenchmarkEventToCombinedMetrics(b *testing.B) {
 	event := &modelpb.APMEvent{
 		Timestamp: timestamppb.Now(),
@@ -546,6 +2036,7 @@ func BenchmarkEventToCombinedMetrics(b *testing.B) {
 	noop := func(_ CombinedMetricsKey, _ *aggregationpb.CombinedMetrics) error {
 		return nil
 	}
+	b.ReportAllocs()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		err := EventToCombinedMetrics(event, cmk, 1 /*partitions*/, noop)
@@ -692,6 +2183,25 @@ func createTestServiceTransactionMetric(
 	}
 }
 
+// testDurationExtremes returns the expected minimum and maximum duration
+// samples of transactions all of the given duration.
+func testDurationExtremes(d time.Duration) []*modelpb.MetricsetSample {
+	return []*modelpb.MetricsetSample{
+		{
+			Type:  modelpb.MetricType_METRIC_TYPE_GAUGE,
+			Name:  "transaction.duration.min",
+			Unit:  "us",
+			Value: float64(d.Microseconds()),
+		},
+		{
+			Type:  modelpb.MetricType_METRIC_TYPE_GAUGE,
+			Name:  "transaction.duration.max",
+			Unit:  "us",
+			Value: float64(d.Microseconds()),
+		},
+	}
+}
+
 func createTestSpanMetric(
 	ts time.Time,
 	ivl time.Duration,
@@ -740,6 +2250,112 @@ func createTestSpanMetric(
 	}
 }
 
+func createTestServiceGraphEdgeMetric(
+	ts time.Time,
+	ivl time.Duration,
+	svcName string,
+	ek serviceGraphEdgeAggregationKey,
+	count, errorCount, overflowCount int,
+) *modelpb.APMEvent {
+	histRep := hdrhistogram.New()
+	histRep.RecordDuration(time.Millisecond, float64(count))
+	_, counts, values := histRep.Buckets()
+	metricsetSamples := []*modelpb.MetricsetSample{
+		{
+			Type:  modelpb.MetricType_METRIC_TYPE_COUNTER,
+			Name:  "service_graph_edge.request.count",
+			Value: float64(count),
+		},
+		{
+			Type:  modelpb.MetricType_METRIC_TYPE_COUNTER,
+			Name:  "service_graph_edge.error.count",
+			Value: float64(errorCount),
+		},
+		{
+			Type:      modelpb.MetricType_METRIC_TYPE_HISTOGRAM,
+			Name:      "service_graph_edge.latency",
+			Histogram: &modelpb.Histogram{Counts: counts, Values: values},
+		},
+	}
+	if overflowCount > 0 {
+		metricsetSamples = append(metricsetSamples, &modelpb.MetricsetSample{
+			Name:  "service_graph_edge.aggregation.overflow_count",
+			Value: float64(overflowCount),
+		})
+	}
+	var target *modelpb.ServiceTarget
+	if ek.TargetType != "" || ek.TargetName != "" {
+		target = &modelpb.ServiceTarget{Type: ek.TargetType, Name: ek.TargetName}
+	}
+	var span *modelpb.Span
+	if ek.Resource != "" {
+		span = &modelpb.Span{
+			DestinationService: &modelpb.DestinationService{Resource: ek.Resource},
+		}
+	}
+	return &modelpb.APMEvent{
+		Timestamp: timestamppb.New(ts),
+		Metricset: &modelpb.Metricset{
+			Name:     "service_graph_edge",
+			Interval: formatDuration(ivl),
+			Samples:  metricsetSamples,
+			DocCount: uint64(count),
+		},
+		Service: &modelpb.Service{
+			Name:   svcName,
+			Target: target,
+		},
+		Span: span,
+	}
+}
+
+func createTestErrorMetric(
+	ts time.Time,
+	ivl time.Duration,
+	svcName string,
+	ek errorAggregationKey,
+	count, overflowCount int,
+) *modelpb.APMEvent {
+	metricsetSamples := []*modelpb.MetricsetSample{
+		{
+			Type:  modelpb.MetricType_METRIC_TYPE_COUNTER,
+			Name:  "error.count",
+			Value: float64(count),
+		},
+		{
+			Type:  modelpb.MetricType_METRIC_TYPE_GAUGE,
+			Name:  "error.rate",
+			Value: float64(count) / ivl.Seconds(),
+		},
+	}
+	if overflowCount > 0 {
+		metricsetSamples = append(metricsetSamples, &modelpb.MetricsetSample{
+			Name:  "service_error.aggregation.overflow_count",
+			Value: float64(overflowCount),
+		})
+	}
+	var event *modelpb.Event
+	if ek.Outcome != "" {
+		event = &modelpb.Event{Outcome: ek.Outcome}
+	}
+	return &modelpb.APMEvent{
+		Timestamp: timestamppb.New(ts),
+		Metricset: &modelpb.Metricset{
+			Name:     "service_error",
+			Interval: formatDuration(ivl),
+			Samples:  metricsetSamples,
+			DocCount: uint64(count),
+		},
+		Service: &modelpb.Service{
+			Name: svcName,
+		},
+		Event: event,
+		Error: &modelpb.Error{
+			GroupingKey: ek.GroupingKey,
+		},
+	}
+}
+
 func getTestGlobalLabelsStr(t *testing.T, s string) string {
 	t.Helper()
 	var gl GlobalLabels
@@ -829,3 +2445,87 @@ func TestMarshalEventGlobalLabels(t *testing.T) {
 		},
 	}, gl.NumericLabels)
 }
+
+func TestEventToCombinedMetricsFilteredGlobalLabels(t *testing.T) {
+	e := &modelpb.APMEvent{
+		Service: &modelpb.Service{Name: "svc"},
+		Transaction: &modelpb.Transaction{
+			Name:                "txn",
+			Type:                "type",
+			RepresentativeCount: 1,
+		},
+		Labels: modelpb.Labels{
+			"env":    &modelpb.LabelValue{Value: "prod", Global: true},
+			"region": &modelpb.LabelValue{Value: "eu", Global: true},
+			"build":  &modelpb.LabelValue{Value: "1234", Global: true},
+		},
+		NumericLabels: modelpb.NumericLabels{
+			"build_number": &modelpb.NumericLabelValue{Value: 1234, Global: true},
+		},
+	}
+	globalLabels := func(opts ...ConverterOption) GlobalLabels {
+		var gl GlobalLabels
+		err := EventToCombinedMetrics(e, CombinedMetricsKey{}, 1,
+			func(_ CombinedMetricsKey, cm *aggregationpb.CombinedMetrics) error {
+				require.Len(t, cm.ServiceMetrics, 1)
+				require.Len(t, cm.ServiceMetrics[0].Metrics.ServiceInstanceMetrics, 1)
+				key := cm.ServiceMetrics[0].Metrics.ServiceInstanceMetrics[0].Key
+				return gl.UnmarshalBinary(key.GlobalLabelsStr)
+			}, opts...)
+		require.NoError(t, err)
+		return gl
+	}
+
+	gl := globalLabels()
+	assert.Len(t, gl.Labels, 3)
+	assert.Len(t, gl.NumericLabels, 1)
+
+	gl = globalLabels(WithFilteredGlobalLabels([]string{"env", "region"}, []string{"region"}))
+	assert.Equal(t, modelpb.Labels{
+		"env": &modelpb.LabelValue{Value: "prod", Global: true},
+	}, gl.Labels)
+	assert.Empty(t, gl.NumericLabels)
+
+	gl = globalLabels(WithFilteredGlobalLabels(nil, []string{"build", "build_number"}))
+	assert.Len(t, gl.Labels, 2)
+	assert.Empty(t, gl.NumericLabels)
+
+	err := EventToCombinedMetrics(e, CombinedMetricsKey{}, 1,
+		func(CombinedMetricsKey, *aggregationpb.CombinedMetrics) error { return nil },
+		WithFilteredGlobalLabels([]string{""}, nil),
+	)
+	assert.EqualError(t, err, "invalid converter options: global label filter keys must not be empty")
+}
+
+func TestMarshalGlobalLabels(t *testing.T) {
+	labels := make(modelpb.Labels)
+	numericLabels := make(modelpb.NumericLabels)
+	for i := 0; i < 50; i++ {
+		labels[fmt.Sprintf("label%d", i)] = &modelpb.LabelValue{Value: fmt.Sprint(i), Global: true}
+		numericLabels[fmt.Sprintf("numeric%d", i)] = &modelpb.NumericLabelValue{Value: float64(i), Global: true}
+	}
+	expected, err := MarshalGlobalLabels(labels, numericLabels)
+	require.NoError(t, err)
+	require.NotEmpty(t, expected)
+
+	// Map iteration order and non-global labels do not affect the output.
+	labels["local"] = &modelpb.LabelValue{Value: "local"}
+	numericLabels["local"] = &modelpb.NumericLabelValue{Value: 1}
+	for i := 0; i < 10; i++ {
+		b, err := MarshalGlobalLabels(labels, numericLabels)
+		require.NoError(t, err)
+		assert.Equal(t, expected, b)
+	}
+
+	// The output is identical to GlobalLabels#MarshalBinary for global labels.
+	delete(labels, "local")
+	delete(numericLabels, "local")
+	gl := GlobalLabels{Labels: labels, NumericLabels: numericLabels}
+	b, err := gl.MarshalBinary()
+	require.NoError(t, err)
+	assert.Equal(t, expected, b)
+
+	b, err = MarshalGlobalLabels(modelpb.Labels{"local": &modelpb.LabelValue{Value: "local"}}, nil)
+	require.NoError(t, err)
+	assert.Nil(t, b)
+}
@@ -0,0 +1,77 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"sync"
+	"time"
+)
+
+// CoverageGap describes consecutive aggregation periods of a combined
+// metrics ID and aggregation interval for which no metrics were emitted
+// to the processor.
+type CoverageGap struct {
+	ID       [16]byte
+	Interval time.Duration
+	// Start is the processing time of the first missing period.
+	Start time.Time
+	// End is the processing time following the last missing period.
+	End time.Time
+	// Err is the error of the failed harvest which caused the gap, nil if
+	// the periods were not harvested or had no aggregated metrics.
+	Err error
+}
+
+// Periods returns the number of aggregation periods missing in the gap.
+func (g CoverageGap) Periods() int64 {
+	return int64(g.End.Sub(g.Start) / g.Interval)
+}
+
+type coverageKey struct {
+	id  [16]byte
+	ivl time.Duration
+}
+
+// coverageTracker tracks the last harvested processing time per combined
+// metrics ID and aggregation interval for detecting coverage gaps.
+type coverageTracker struct {
+	mu   sync.Mutex
+	last map[coverageKey]time.Time
+}
+
+// harvested records that the period of the given processing time was
+// harvested and returns the gap preceding it, if any. Gaps are only
+// detected after the first harvest of an ID and interval.
+func (t *coverageTracker) harvested(
+	id [16]byte,
+	ivl time.Duration,
+	processingTime time.Time,
+) (CoverageGap, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.last == nil {
+		t.last = make(map[coverageKey]time.Time)
+	}
+	k := coverageKey{id: id, ivl: ivl}
+	last, ok := t.last[k]
+	if ok && !processingTime.After(last) {
+		return CoverageGap{}, false
+	}
+	t.last[k] = processingTime
+	if !ok {
+		return CoverageGap{}, false
+	}
+	start := last.Add(ivl)
+	if !processingTime.After(start) {
+		return CoverageGap{}, false
+	}
+	return CoverageGap{
+		ID:       id,
+		Interval: ivl,
+		Start:    start,
+		End:      processingTime,
+	}, true
+}
@@ -0,0 +1,150 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	"go.uber.org/zap"
+)
+
+// DrainReport reports the outcome of draining the aggregated metrics by
+// CloseWithDrain.
+type DrainReport struct {
+	// Flushed is the number of combined metrics successfully processed.
+	Flushed int
+
+	// Failed is the number of combined metrics which failed to be
+	// processed, e.g. because the deadline was exceeded while processing.
+	// As with any harvest, these are dropped.
+	Failed int
+
+	// Remaining is the number of combined metrics which were not drained
+	// before the deadline. These are kept in the database and harvested
+	// as stale metrics by the next aggregator using the data directory.
+	Remaining int
+
+	// RemainingBytes is the size, in bytes, of the keys and values of the
+	// remaining combined metrics.
+	RemainingBytes int64
+}
+
+// Complete returns true if all the aggregated metrics were flushed.
+func (r DrainReport) Complete() bool {
+	return r.Failed == 0 && r.Remaining == 0
+}
+
+// CloseWithDrain closes the aggregator as Close, except that the final
+// harvest drains all the aggregated periods of all the aggregation
+// intervals through the processor, including the periods which are not
+// yet due, e.g. the current period or the periods held back for late
+// events. The periods are drained in order of aggregation interval and
+// processing time until the context is done, the deadline of the context
+// bounds the drain. The returned report accounts for the metrics which
+// could not be flushed.
+func (a *Aggregator) CloseWithDrain(ctx context.Context) (DrainReport, error) {
+	ctx, span := a.cfg.Tracer.Start(ctx, "Aggregator.CloseWithDrain")
+	defer span.End()
+	return a.close(ctx, span, true)
+}
+
+// storedPeriod is an aggregation period with combined metrics stored in
+// the shards.
+type storedPeriod struct {
+	processingTime time.Time
+	count          int
+	bytes          int64
+}
+
+// drain harvests all the stored periods of each aggregation interval, in
+// ascending order of the intervals so that the metrics rolled up from the
+// lowest interval are drained too. Harvest failures are accounted for in
+// the report, the returned error is only set if the stored periods could
+// not be read. Must be called with the lock held.
+func (a *Aggregator) drain(ctx context.Context) (DrainReport, error) {
+	var report DrainReport
+	for _, ivl := range a.cfg.AggregationIntervals {
+		periods, err := a.storedPeriods(ivl)
+		if err != nil {
+			return report, err
+		}
+		cachedEventsStats := a.cachedEvents.loadAndDeleteInterval(ivl)
+		for _, p := range periods {
+			if ctx.Err() != nil {
+				report.Remaining += p.count
+				report.RemainingBytes += p.bytes
+				continue
+			}
+			end := p.processingTime.Add(ivl)
+			cmCount, err := a.harvestIntervalSnapshot(
+				ctx, p.processingTime, end, ivl, cachedEventsStats, false,
+			)
+			cachedEventsStats = nil
+			report.Flushed += cmCount
+			report.Failed += p.count - cmCount
+			if err != nil {
+				a.cfg.Logger.Warn(
+					"failed to drain aggregated metrics",
+					zap.Duration("aggregation_interval_ns", ivl),
+					zap.Time("processing_time", p.processingTime),
+					zap.Error(err),
+				)
+			} else {
+				a.health.recordHarvest(ivl, end)
+			}
+			a.reportLosses(ivl, p.processingTime)
+		}
+	}
+	return report, nil
+}
+
+// storedPeriods returns the periods of the aggregation interval stored in
+// the shards, ordered by processing time.
+func (a *Aggregator) storedPeriods(ivl time.Duration) ([]storedPeriod, error) {
+	lb := make([]byte, 2)
+	ub := make([]byte, 2)
+	binary.BigEndian.PutUint16(lb, uint16(ivl.Seconds()))
+	binary.BigEndian.PutUint16(ub, uint16(ivl.Seconds())+1)
+
+	periods := make(map[int64]*storedPeriod)
+	for _, shard := range a.shards {
+		iter := shard.NewIter(&pebble.IterOptions{
+			LowerBound: lb,
+			UpperBound: ub,
+			KeyTypes:   pebble.IterKeyTypePointsOnly,
+		})
+		for iter.First(); iter.Valid(); iter.Next() {
+			var cmk CombinedMetricsKey
+			if err := cmk.UnmarshalBinary(iter.Key()); err != nil {
+				continue
+			}
+			p, ok := periods[cmk.ProcessingTime.Unix()]
+			if !ok {
+				p = &storedPeriod{processingTime: cmk.ProcessingTime}
+				periods[cmk.ProcessingTime.Unix()] = p
+			}
+			p.count++
+			p.bytes += int64(len(iter.Key()) + len(iter.Value()))
+		}
+		err := a.health.recordStorageError(iter.Error())
+		iter.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate stored periods: %w", err)
+		}
+	}
+	sorted := make([]storedPeriod, 0, len(periods))
+	for _, p := range periods {
+		sorted = append(sorted, *p)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].processingTime.Before(sorted[j].processingTime)
+	})
+	return sorted, nil
+}
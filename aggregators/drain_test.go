@@ -0,0 +1,87 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+	"github.com/elastic/apm-data/model/modelpb"
+)
+
+func TestCloseWithDrain(t *testing.T) {
+	ivls := []time.Duration{time.Minute, 10 * time.Minute}
+	id := EncodeToCombinedMetricsKeyID(t, "ab01")
+	newDrainTestAggregator := func(t *testing.T, processed *[]time.Duration) *Aggregator {
+		agg, err := New(
+			WithDataDir(t.TempDir()),
+			WithLimits(Limits{
+				MaxServices:                           10,
+				MaxServiceInstanceGroupsPerService:    10,
+				MaxTransactionGroups:                  10,
+				MaxTransactionGroupsPerService:        10,
+				MaxServiceTransactionGroups:           10,
+				MaxServiceTransactionGroupsPerService: 10,
+				MaxSpanGroups:                         10,
+				MaxSpanGroupsPerService:               10,
+			}),
+			WithAggregationIntervals(ivls),
+			WithProcessor(func(
+				_ context.Context,
+				_ CombinedMetricsKey,
+				_ *aggregationpb.CombinedMetrics,
+				ivl time.Duration,
+			) error {
+				*processed = append(*processed, ivl)
+				return nil
+			}),
+			WithLogger(zap.NewNop()),
+		)
+		require.NoError(t, err)
+		return agg
+	}
+
+	t.Run("complete", func(t *testing.T) {
+		ctx := context.Background()
+		var processed []time.Duration
+		agg := newDrainTestAggregator(t, &processed)
+		start := time.Unix(agg.processingTime.Unix(), 0)
+		batch := modelpb.Batch{lateTestEvent(start.Add(time.Second))}
+		require.NoError(t, agg.AggregateBatch(ctx, id, &batch))
+
+		report, err := agg.CloseWithDrain(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, DrainReport{Flushed: 2}, report)
+		assert.True(t, report.Complete())
+		assert.Equal(t, ivls, processed)
+		assert.Nil(t, agg.db)
+	})
+
+	t.Run("deadline_exceeded", func(t *testing.T) {
+		ctx := context.Background()
+		var processed []time.Duration
+		agg := newDrainTestAggregator(t, &processed)
+		start := time.Unix(agg.processingTime.Unix(), 0)
+		batch := modelpb.Batch{lateTestEvent(start.Add(time.Second))}
+		require.NoError(t, agg.AggregateBatch(ctx, id, &batch))
+
+		cancelledCtx, cancel := context.WithCancel(ctx)
+		cancel()
+		report, err := agg.CloseWithDrain(cancelledCtx)
+		require.NoError(t, err)
+		assert.Empty(t, processed)
+		assert.False(t, report.Complete())
+		assert.Equal(t, 0, report.Flushed)
+		assert.Equal(t, 2, report.Remaining)
+		assert.Positive(t, report.RemainingBytes)
+		assert.Nil(t, agg.db)
+	})
+}
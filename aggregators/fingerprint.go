@@ -0,0 +1,93 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"sort"
+)
+
+const modulePath = "github.com/elastic/apm-aggregation"
+
+// Fingerprint returns a stable hash of the configuration affecting the
+// semantics of the aggregated metrics: the library version, the limits,
+// the aggregation intervals and the aggregation dimensions. Settings only
+// affecting the operation of the aggregator, e.g. the data directory or
+// the harvest delay, are excluded. Custom functions are only taken into
+// account by whether they are set.
+//
+// The fingerprint changes whenever the metrics may be aggregated
+// differently, allowing consumers to detect configuration rollouts.
+func (c Config) Fingerprint() string {
+	h := sha256.New()
+	write := func(name string, v any) {
+		fmt.Fprintf(h, "%s=%+v\n", name, v)
+	}
+	write("version", libraryVersion())
+	write("limits", c.Limits)
+	write("intervals", c.AggregationIntervals)
+	write("interval_rollups", c.IntervalRollups)
+	write("partitions", c.Partitions)
+	write("partitioner", c.Partitioner != nil)
+	write("max_adaptive_partitions", c.MaxAdaptivePartitions)
+	write("events_per_partition", c.EventsPerPartition)
+	write("top_k_retention", c.TopKRetention)
+	write("max_exemplars", c.MaxExemplars)
+	write("global_labels_hash_threshold", c.GlobalLabelsHashThreshold)
+	write("global_labels_allowlist", c.GlobalLabelsAllowlist)
+	write("global_labels_denylist", c.GlobalLabelsDenylist)
+	write("histogram_impl", c.HistogramImpl)
+	write("histogram_significant_figures", c.HistogramSignificantFigures)
+	write("histogram_max_duration", c.HistogramMaxDuration)
+	write("duration_sum_estimate", c.DurationSumEstimate)
+	write("duration_extremes", c.DurationExtremes)
+	write("span_subtype_groups", c.SpanSubtypeGroups)
+	write("faas_groups", c.FaasGroups)
+	write("mobile_groups", c.MobileGroups)
+	write("kubernetes_groups", c.KubernetesGroups)
+	write("instance_dimensions", c.InstanceDimensions)
+	write("collapse_instances", c.CollapseInstances)
+	write("span_resource_normalizer", c.SpanResourceNormalizer != nil)
+	write("key_extractor", c.KeyExtractor != nil)
+	write("late_event_policy", c.LateEventPolicy)
+	write("allowed_lateness", c.AllowedLateness)
+	write("event_time_bucketing", c.EventTimeBucketing)
+	write("backfill", c.Backfill)
+	aliases := make([]string, 0, len(c.ServiceNameAliases))
+	for alias := range c.ServiceNameAliases {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	for _, alias := range aliases {
+		io.WriteString(h, "service_name_alias=")
+		write(alias, c.ServiceNameAliases[alias])
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// libraryVersion returns the version of the module as recorded in the
+// build info of the binary, or "(devel)" if unknown.
+func libraryVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "(devel)"
+	}
+	if info.Main.Path == modulePath {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			if dep.Replace != nil {
+				dep = dep.Replace
+			}
+			return dep.Version
+		}
+	}
+	return "(devel)"
+}
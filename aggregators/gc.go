@@ -0,0 +1,150 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	"go.uber.org/zap"
+)
+
+// collectGarbage drops the combined metrics keys, of all the aggregation
+// intervals found in the shards, and the checkpoints with a processing
+// time older than the configured max retention relative to the given
+// harvest end time. The number of reclaimed bytes of the dropped keys and
+// values is recorded by the aggregator.gc.reclaimed metric.
+func (a *Aggregator) collectGarbage(ctx context.Context, end time.Time) error {
+	cutoff := end.Add(-a.cfg.MaxRetention)
+	if cutoff.Unix() <= 0 {
+		return nil
+	}
+
+	var reclaimed int64
+	var errs []error
+	for _, shard := range a.shards {
+		size, err := a.collectShardGarbage(shard, cutoff)
+		reclaimed += size
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 0 && a.tiers != nil {
+		a.tiers.expire(cutoff)
+	}
+	size, err := a.collectCheckpointGarbage(cutoff)
+	reclaimed += size
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if reclaimed > 0 {
+		a.metrics.GCReclaimed.Add(ctx, reclaimed)
+	}
+	return errors.Join(errs...)
+}
+
+// collectShardGarbage drops the combined metrics keys of the shard with a
+// processing time before the cutoff and returns the reclaimed bytes.
+func (a *Aggregator) collectShardGarbage(shard *pebble.DB, cutoff time.Time) (int64, error) {
+	iter := shard.NewIter(&pebble.IterOptions{
+		LowerBound: combinedMetricsLowerBound,
+		KeyTypes:   pebble.IterKeyTypePointsOnly,
+	})
+	defer iter.Close()
+
+	var reclaimed int64
+	for valid := iter.First(); valid; {
+		ivlSeconds := binary.BigEndian.Uint16(iter.Key())
+		// The keys of an interval are ordered by processing time, all the
+		// expired keys are in the range [ivl|0, ivl|cutoff).
+		lb := make([]byte, 10)
+		ub := make([]byte, 10)
+		binary.BigEndian.PutUint16(lb, ivlSeconds)
+		binary.BigEndian.PutUint16(ub, ivlSeconds)
+		binary.BigEndian.PutUint64(ub[2:], uint64(cutoff.Unix()))
+
+		var size int64
+		for ; valid && bytes.Compare(iter.Key(), ub) < 0; valid = iter.Next() {
+			size += int64(len(iter.Key()) + len(iter.Value()))
+		}
+		if err := iter.Error(); err != nil {
+			return reclaimed, fmt.Errorf("failed to iterate expired keys: %w", err)
+		}
+		if size > 0 {
+			if err := shard.DeleteRange(lb, ub, a.writeOptions); err != nil {
+				return reclaimed, fmt.Errorf("failed to delete expired keys: %w", err)
+			}
+			reclaimed += size
+			a.cfg.Logger.Debug(
+				"dropped keys older than max retention",
+				zap.Duration("aggregation_interval_ns", time.Duration(ivlSeconds)*time.Second),
+				zap.Time("cutoff", cutoff),
+				zap.Int64("reclaimed_bytes", size),
+			)
+		}
+		if ivlSeconds == math.MaxUint16 {
+			break
+		}
+		// Skip the retained keys of the interval.
+		var next [2]byte
+		binary.BigEndian.PutUint16(next[:], ivlSeconds+1)
+		valid = iter.SeekGE(next[:])
+	}
+	if err := iter.Error(); err != nil {
+		return reclaimed, fmt.Errorf("failed to iterate expired keys: %w", err)
+	}
+	return reclaimed, nil
+}
+
+// collectCheckpointGarbage drops the checkpoints with a processing time
+// before the cutoff and returns the reclaimed bytes. The combined metrics
+// of such checkpoints are dropped as well, the checkpoints are thus never
+// needed to skip processed combined metrics.
+func (a *Aggregator) collectCheckpointGarbage(cutoff time.Time) (int64, error) {
+	iter := a.db.NewIter(&pebble.IterOptions{
+		LowerBound: checkpointKeyPrefix,
+		UpperBound: combinedMetricsLowerBound,
+		KeyTypes:   pebble.IterKeyTypePointsOnly,
+	})
+	defer iter.Close()
+
+	batch := a.db.NewBatch()
+	defer batch.Close()
+	var reclaimed int64
+	for iter.First(); iter.Valid(); iter.Next() {
+		cp, err := decodeCheckpoint(iter.Key(), iter.Value())
+		if err != nil {
+			return 0, err
+		}
+		if !cp.ProcessingTime.Before(cutoff) {
+			continue
+		}
+		if err := batch.Delete(iter.Key(), nil); err != nil {
+			return 0, fmt.Errorf("failed to delete expired checkpoint: %w", err)
+		}
+		reclaimed += int64(len(iter.Key()) + len(iter.Value()))
+	}
+	if err := iter.Error(); err != nil {
+		return 0, fmt.Errorf("failed to iterate checkpoints: %w", err)
+	}
+	if batch.Empty() {
+		return reclaimed, nil
+	}
+	if err := batch.Commit(a.writeOptions); err != nil {
+		return 0, fmt.Errorf("failed to delete expired checkpoints: %w", err)
+	}
+	a.cfg.Logger.Debug(
+		"dropped checkpoints older than max retention",
+		zap.Time("cutoff", cutoff),
+		zap.Int64("reclaimed_bytes", reclaimed),
+	)
+	return reclaimed, nil
+}
@@ -0,0 +1,92 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap"
+)
+
+func TestCollectGarbage(t *testing.T) {
+	ctx := context.Background()
+	rdr := metric.NewManualReader()
+	agg, err := New(
+		WithDataDir(t.TempDir()),
+		WithAggregationIntervals([]time.Duration{time.Second, time.Minute}),
+		WithMaxRetention(time.Hour),
+		WithMeter(metric.NewMeterProvider(metric.WithReader(rdr)).Meter("test")),
+		WithLogger(zap.NewNop()),
+	)
+	require.NoError(t, err)
+	defer agg.Close(ctx)
+
+	end := time.Unix(agg.processingTime.Unix(), 0)
+	cmID := EncodeToCombinedMetricsKeyID(t, "ab01")
+	value := []byte("value")
+	keys := map[CombinedMetricsKey]bool{
+		// Keys of configured intervals, e.g. left by partially failed harvests.
+		{Interval: time.Second, ProcessingTime: end.Add(-2 * time.Hour), ID: cmID}:    false,
+		{Interval: time.Minute, ProcessingTime: end.Add(-61 * time.Minute), ID: cmID}: false,
+		{Interval: time.Minute, ProcessingTime: end.Add(-time.Minute), ID: cmID}:      true,
+		// Keys of an interval removed from the configuration.
+		{Interval: 10 * time.Second, ProcessingTime: end.Add(-2 * time.Hour), ID: cmID, PartitionID: 1}: false,
+		{Interval: 10 * time.Second, ProcessingTime: end.Add(-time.Hour), ID: cmID}:                     true,
+		{Interval: time.Hour, ProcessingTime: end.Add(-3 * time.Hour), ID: cmID}:                        false,
+	}
+	for cmk := range keys {
+		key := make([]byte, CombinedMetricsKeyEncodedSize)
+		require.NoError(t, cmk.MarshalBinaryToSizedBuffer(key))
+		require.NoError(t, agg.db.Set(key, value, pebble.Sync))
+	}
+	// Checkpoints older than the max retention are dropped.
+	require.NoError(t, agg.recordCheckpoint(cmID, time.Second, end.Add(-2*time.Hour)))
+	require.NoError(t, agg.recordCheckpoint(cmID, time.Minute, end.Add(-time.Minute)))
+
+	require.NoError(t, agg.collectGarbage(ctx, end))
+
+	var expected, retained []CombinedMetricsKey
+	for cmk, retain := range keys {
+		if retain {
+			expected = append(expected, cmk)
+		}
+	}
+	iter := agg.db.NewIter(&pebble.IterOptions{LowerBound: combinedMetricsLowerBound})
+	for iter.First(); iter.Valid(); iter.Next() {
+		var cmk CombinedMetricsKey
+		require.NoError(t, cmk.UnmarshalBinary(iter.Key()))
+		retained = append(retained, cmk)
+	}
+	require.NoError(t, iter.Close())
+	assert.ElementsMatch(t, expected, retained)
+
+	checkpoints, err := agg.Checkpoint()
+	require.NoError(t, err)
+	assert.Equal(t, []Checkpoint{
+		{ID: cmID, Interval: time.Minute, ProcessingTime: end.Add(-time.Minute)},
+	}, checkpoints)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, rdr.Collect(ctx, &rm))
+	var reclaimed int64
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "aggregator.gc.reclaimed" {
+				continue
+			}
+			for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				reclaimed += dp.Value
+			}
+		}
+	}
+	assert.Equal(t, int64(4*(CombinedMetricsKeyEncodedSize+len(value))+checkpointKeyEncodedSize+8), reclaimed)
+}
@@ -0,0 +1,206 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+// Package grpcserver exposes an aggregator as a gRPC service, so that
+// collectors can push batches of APM events, or pre-aggregated combined
+// metrics, to a shared aggregation node without linking the library.
+//
+// Batches are pushed using the elastic.apm.BatchAggregation service
+// defined in proto/remote.proto, with the events encoded as
+// elastic.apm.v1.APMEvent messages. Combined metrics are pushed using the
+// elastic.apm.Aggregation service implemented by the remote package.
+package grpcserver
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+	"github.com/elastic/apm-aggregation/aggregators"
+	"github.com/elastic/apm-aggregation/aggregators/remote"
+	"github.com/elastic/apm-data/model/modelpb"
+)
+
+const aggregateBatchMethod = "/elastic.apm.BatchAggregation/AggregateBatch"
+
+// Aggregator is the aggregator which the received batches and combined
+// metrics are aggregated into, typically an *aggregators.Aggregator.
+type Aggregator interface {
+	remote.Aggregator
+	AggregateBatch(ctx context.Context, id [16]byte, b *modelpb.Batch) error
+}
+
+// Option configures a Server.
+type Option func(config) config
+
+type config struct {
+	authFunc func(ctx context.Context, method string) error
+}
+
+// WithAuthFunc configures a function to authenticate each request before it
+// is aggregated, for both batches and combined metrics. The function is
+// called with the request context, holding the incoming gRPC metadata, and
+// the full gRPC method name. If the function returns an error, the request
+// is rejected with the Unauthenticated code, unless the error is already a
+// gRPC status error.
+func WithAuthFunc(fn func(ctx context.Context, method string) error) Option {
+	return func(c config) config {
+		c.authFunc = fn
+		return c
+	}
+}
+
+// Server implements the gRPC BatchAggregation and Aggregation services by
+// aggregating the received batches and combined metrics into an
+// Aggregator. Gzip compressed requests are supported.
+type Server struct {
+	agg    Aggregator
+	cfg    config
+	remote *remote.Server
+}
+
+// NewServer returns a new Server aggregating into agg.
+func NewServer(agg Aggregator, opts ...Option) *Server {
+	var cfg config
+	for _, opt := range opts {
+		cfg = opt(cfg)
+	}
+	var remoteOpts []remote.ServerOption
+	if cfg.authFunc != nil {
+		remoteOpts = append(remoteOpts, remote.WithAuthFunc(cfg.authFunc))
+	}
+	return &Server{
+		agg:    agg,
+		cfg:    cfg,
+		remote: remote.NewServer(agg, remoteOpts...),
+	}
+}
+
+// Register registers the BatchAggregation and Aggregation services with
+// the gRPC server.
+func (s *Server) Register(r grpc.ServiceRegistrar) {
+	r.RegisterService(&batchAggregationServiceDesc, s)
+	s.remote.Register(r)
+}
+
+// AggregateBatch aggregates the events of the request.
+func (s *Server) AggregateBatch(
+	ctx context.Context,
+	req *aggregationpb.AggregateBatchRequest,
+) (*aggregationpb.AggregateResponse, error) {
+	if s.cfg.authFunc != nil {
+		if err := s.cfg.authFunc(ctx, aggregateBatchMethod); err != nil {
+			if _, ok := status.FromError(err); ok {
+				return nil, err
+			}
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+	}
+	var id [16]byte
+	if len(req.Id) != len(id) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid combined metrics ID of length %d", len(req.Id))
+	}
+	copy(id[:], req.Id)
+	batch := make(modelpb.Batch, 0, len(req.Events))
+	for i, data := range req.Events {
+		event := &modelpb.APMEvent{}
+		if err := event.UnmarshalVT(data); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid event at index %d: %v", i, err)
+		}
+		batch = append(batch, event)
+	}
+	if err := s.agg.AggregateBatch(ctx, id, &batch); err != nil {
+		return nil, status.Error(remote.ErrorCode(err), err.Error())
+	}
+	return &aggregationpb.AggregateResponse{}, nil
+}
+
+// Client pushes batches and combined metrics to a Server.
+type Client struct {
+	*remote.Client
+
+	conn     grpc.ClientConnInterface
+	callOpts []grpc.CallOption
+}
+
+// NewClient returns a new Client using the given connection. The call
+// options are used for every request, for example grpc.UseCompressor to
+// compress the requests or grpc.PerRPCCredentials for authentication.
+func NewClient(conn grpc.ClientConnInterface, callOpts ...grpc.CallOption) *Client {
+	return &Client{
+		Client:   remote.NewClient(conn, remote.WithCallOptions(callOpts...)),
+		conn:     conn,
+		callOpts: callOpts,
+	}
+}
+
+// AggregateBatch pushes the batch to the server for aggregation into the
+// combined metrics ID. The signature matches
+// aggregators.Aggregator.AggregateBatch.
+func (c *Client) AggregateBatch(ctx context.Context, id [16]byte, b *modelpb.Batch) error {
+	req := &aggregationpb.AggregateBatchRequest{
+		Id:     id[:],
+		Events: make([][]byte, 0, len(*b)),
+	}
+	for _, event := range *b {
+		data, err := event.MarshalVT()
+		if err != nil {
+			return fmt.Errorf("failed to marshal event: %w", err)
+		}
+		req.Events = append(req.Events, data)
+	}
+	var resp aggregationpb.AggregateResponse
+	if err := c.conn.Invoke(ctx, aggregateBatchMethod, req, &resp, c.callOpts...); err != nil {
+		return fmt.Errorf("failed to aggregate batch remotely: %w", err)
+	}
+	return nil
+}
+
+var (
+	_ Aggregator = (*aggregators.Aggregator)(nil)
+	_ Aggregator = (*Client)(nil)
+)
+
+// batchAggregationServer is the interface implemented by Server, used for
+// type checking the registered service implementation.
+type batchAggregationServer interface {
+	AggregateBatch(context.Context, *aggregationpb.AggregateBatchRequest) (*aggregationpb.AggregateResponse, error)
+}
+
+var batchAggregationServiceDesc = grpc.ServiceDesc{
+	ServiceName: "elastic.apm.BatchAggregation",
+	HandlerType: (*batchAggregationServer)(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "AggregateBatch",
+		Handler:    aggregateBatchHandler,
+	}},
+	Metadata: "proto/remote.proto",
+}
+
+func aggregateBatchHandler(
+	srv interface{},
+	ctx context.Context,
+	dec func(interface{}) error,
+	interceptor grpc.UnaryServerInterceptor,
+) (interface{}, error) {
+	in := new(aggregationpb.AggregateBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(batchAggregationServer).AggregateBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: aggregateBatchMethod,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(batchAggregationServer).AggregateBatch(ctx, req.(*aggregationpb.AggregateBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
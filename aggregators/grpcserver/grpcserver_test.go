@@ -0,0 +1,160 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+	"github.com/elastic/apm-aggregation/aggregators"
+	"github.com/elastic/apm-data/model/modelpb"
+)
+
+type batchCall struct {
+	id    [16]byte
+	batch modelpb.Batch
+}
+
+type fakeAggregator struct {
+	batches  []batchCall
+	combined int
+	err      error
+}
+
+func (f *fakeAggregator) AggregateBatch(_ context.Context, id [16]byte, b *modelpb.Batch) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.batches = append(f.batches, batchCall{id: id, batch: *b})
+	return nil
+}
+
+func (f *fakeAggregator) AggregateCombinedMetrics(
+	_ context.Context,
+	_ aggregators.CombinedMetricsKey,
+	_ *aggregationpb.CombinedMetrics,
+) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.combined++
+	return nil
+}
+
+func newTestClient(t *testing.T, srv *Server, callOpts ...grpc.CallOption) *Client {
+	lis := bufconn.Listen(1 << 20)
+	gs := grpc.NewServer()
+	srv.Register(gs)
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+
+	conn, err := grpc.Dial(
+		"bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return NewClient(conn, callOpts...)
+}
+
+func TestAggregateBatch(t *testing.T) {
+	batch := modelpb.Batch{
+		{
+			Event:       &modelpb.Event{Duration: durationpb.New(time.Millisecond)},
+			Transaction: &modelpb.Transaction{Name: "T-1000", Type: "type", RepresentativeCount: 1},
+			Service:     &modelpb.Service{Name: "svc"},
+		},
+		{
+			Event: &modelpb.Event{Duration: durationpb.New(time.Second)},
+			Span:  &modelpb.Span{Name: "S-1000", Type: "db", RepresentativeCount: 1},
+		},
+	}
+	id := [16]byte{1, 2, 3}
+
+	for _, callOpts := range [][]grpc.CallOption{nil, {grpc.UseCompressor(gzip.Name)}} {
+		agg := &fakeAggregator{}
+		client := newTestClient(t, NewServer(agg), callOpts...)
+		require.NoError(t, client.AggregateBatch(context.Background(), id, &batch))
+		require.Len(t, agg.batches, 1)
+		assert.Equal(t, id, agg.batches[0].id)
+		require.Len(t, agg.batches[0].batch, len(batch))
+		for i, event := range batch {
+			assert.True(t, proto.Equal(event, agg.batches[0].batch[i]))
+		}
+
+		// Combined metrics are served by the same server.
+		cmk := aggregators.CombinedMetricsKey{Interval: time.Minute, ProcessingTime: time.Unix(60, 0)}
+		require.NoError(t, client.AggregateCombinedMetrics(context.Background(), cmk, &aggregationpb.CombinedMetrics{}))
+		assert.Equal(t, 1, agg.combined)
+	}
+}
+
+func TestAggregateBatchAuth(t *testing.T) {
+	agg := &fakeAggregator{}
+	var methods []string
+	srv := NewServer(agg, WithAuthFunc(func(ctx context.Context, method string) error {
+		methods = append(methods, method)
+		md, _ := metadata.FromIncomingContext(ctx)
+		if tokens := md.Get("authorization"); len(tokens) == 1 && tokens[0] == "secret" {
+			return nil
+		}
+		return errors.New("invalid token")
+	}))
+	client := newTestClient(t, srv)
+
+	cmk := aggregators.CombinedMetricsKey{Interval: time.Minute, ProcessingTime: time.Unix(0, 0)}
+	err := client.AggregateBatch(context.Background(), [16]byte{}, &modelpb.Batch{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(errors.Unwrap(err)))
+	err = client.AggregateCombinedMetrics(context.Background(), cmk, &aggregationpb.CombinedMetrics{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(errors.Unwrap(err)))
+	assert.Empty(t, agg.batches)
+	assert.Zero(t, agg.combined)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "secret")
+	assert.NoError(t, client.AggregateBatch(ctx, [16]byte{}, &modelpb.Batch{}))
+	assert.NoError(t, client.AggregateCombinedMetrics(ctx, cmk, &aggregationpb.CombinedMetrics{}))
+	assert.Len(t, agg.batches, 1)
+	assert.Equal(t, 1, agg.combined)
+	assert.Equal(t, []string{
+		"/elastic.apm.BatchAggregation/AggregateBatch",
+		"/elastic.apm.Aggregation/Aggregate",
+		"/elastic.apm.BatchAggregation/AggregateBatch",
+		"/elastic.apm.Aggregation/Aggregate",
+	}, methods)
+}
+
+func TestAggregateBatchErrors(t *testing.T) {
+	client := newTestClient(t, NewServer(&fakeAggregator{err: aggregators.ErrBackpressure}))
+	err := client.AggregateBatch(context.Background(), [16]byte{}, &modelpb.Batch{})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(errors.Unwrap(err)))
+
+	srv := NewServer(&fakeAggregator{})
+	_, err = srv.AggregateBatch(context.Background(), &aggregationpb.AggregateBatchRequest{Id: []byte{1, 2, 3}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = srv.AggregateBatch(context.Background(), &aggregationpb.AggregateBatchRequest{
+		Id:     make([]byte, 16),
+		Events: [][]byte{{0xff}},
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
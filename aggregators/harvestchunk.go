@@ -0,0 +1,168 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+	"github.com/elastic/apm-aggregation/aggregators/internal/timestamppb"
+)
+
+// serviceMetricsFieldNum and eventsTotalFieldNum are the field numbers of
+// the service metrics and the events total of the protobuf encoded combined
+// metrics.
+const (
+	serviceMetricsFieldNum = 1
+	eventsTotalFieldNum    = 4
+)
+
+// HarvestChunk identifies the chunk of a harvested combined metrics passed
+// to the processor, see WithHarvestChunking.
+type HarvestChunk struct {
+	// Index is the index of the chunk, starting at 0.
+	Index int
+	// Last is true for the last chunk of the combined metrics.
+	Last bool
+}
+
+type harvestChunkKey struct{}
+
+// HarvestChunkFromContext returns the chunk of the harvested combined
+// metrics being processed, if the harvest is chunked.
+func HarvestChunkFromContext(ctx context.Context) (HarvestChunk, bool) {
+	chunk, ok := ctx.Value(harvestChunkKey{}).(HarvestChunk)
+	return chunk, ok
+}
+
+// processHarvestChunks calls the processor with chunks of the services of
+// the encoded combined metrics. Only the services of the processed chunk
+// are decoded. The remaining fields, e.g. the overflow and events total,
+// are decoded into the last chunk.
+func (a *Aggregator) processHarvestChunks(
+	ctx context.Context,
+	cmk CombinedMetricsKey,
+	cmb []byte,
+	aggIvl time.Duration,
+) (harvestStats, error) {
+	var hs harvestStats
+	decoded, err := a.codec.decode(cmb)
+	if err != nil {
+		hs.errClass = mergeErrorClass
+		return hs, fmt.Errorf("failed to decode metrics: %w", err)
+	}
+	services, rest, err := splitServiceMetrics(decoded)
+	if err != nil {
+		hs.errClass = mergeErrorClass
+		return hs, fmt.Errorf("failed to unmarshal metrics: %w", err)
+	}
+
+	// The events total is known upfront for the event loss accounting of
+	// chunks failing to be processed.
+	hs.eventsTotal = eventsTotalField(rest)
+
+	cm := aggregationpb.CombinedMetricsFromVTPool()
+	defer cm.ReturnToVTPool()
+	size := a.cfg.HarvestChunkServices
+	chunks := (len(services) + size - 1) / size
+	if chunks == 0 {
+		chunks = 1
+	}
+	for i := 0; i < chunks; i++ {
+		cm.ResetVT()
+		end := (i + 1) * size
+		if end > len(services) {
+			end = len(services)
+		}
+		// Unmarshaling the encoded fields one by one merges them into the
+		// combined metrics, reusing its pooled services.
+		for _, field := range services[i*size : end] {
+			if err := cm.UnmarshalVT(field); err != nil {
+				hs.errClass = mergeErrorClass
+				return hs, fmt.Errorf("failed to unmarshal metrics: %w", err)
+			}
+		}
+		last := i == chunks-1
+		if last {
+			if err := cm.UnmarshalVT(rest); err != nil {
+				hs.errClass = mergeErrorClass
+				return hs, fmt.Errorf("failed to unmarshal metrics: %w", err)
+			}
+			hs.youngestEventTimestamp = timestamppb.PBTimestampToTime(cm.YoungestEventTimestamp)
+		}
+		if err := decodeSparseHistograms(cm); err != nil {
+			hs.errClass = mergeErrorClass
+			return hs, fmt.Errorf("failed to unmarshal metrics: %w", err)
+		}
+		hs.overflow.add(cm)
+		hs.overflowEvents += overflowEventCount(cm)
+		chunkCtx := context.WithValue(ctx, harvestChunkKey{}, HarvestChunk{Index: i, Last: last})
+		if err := a.process(chunkCtx, cmk, cm, aggIvl); err != nil {
+			hs.errClass = processorErrorClass
+			return hs, fmt.Errorf(
+				"failed to process chunk %d of combined metrics ID %s: %w", i, cmk.ID, err,
+			)
+		}
+	}
+	return hs, nil
+}
+
+// splitServiceMetrics splits the protobuf encoded combined metrics into the
+// encoded service metrics fields and the remaining encoded fields, without
+// decoding the service metrics.
+func splitServiceMetrics(b []byte) (services [][]byte, rest []byte, err error) {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, nil, protowire.ParseError(n)
+		}
+		m := protowire.ConsumeFieldValue(num, typ, b[n:])
+		if m < 0 {
+			return nil, nil, protowire.ParseError(m)
+		}
+		field := b[:n+m]
+		b = b[n+m:]
+		if num == serviceMetricsFieldNum && typ == protowire.BytesType {
+			services = append(services, field)
+			continue
+		}
+		rest = append(rest, field...)
+	}
+	return services, rest, nil
+}
+
+// eventsTotalField returns the events total of the encoded fields of the
+// combined metrics split by splitServiceMetrics.
+func eventsTotalField(rest []byte) float64 {
+	var total float64
+	for len(rest) > 0 {
+		num, typ, n := protowire.ConsumeTag(rest)
+		if n < 0 {
+			return total
+		}
+		rest = rest[n:]
+		if num == eventsTotalFieldNum && typ == protowire.Fixed64Type {
+			v, m := protowire.ConsumeFixed64(rest)
+			if m < 0 {
+				return total
+			}
+			// The last occurrence of a scalar field wins.
+			total = math.Float64frombits(v)
+			rest = rest[m:]
+			continue
+		}
+		m := protowire.ConsumeFieldValue(num, typ, rest)
+		if m < 0 {
+			return total
+		}
+		rest = rest[m:]
+	}
+	return total
+}
@@ -0,0 +1,96 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+)
+
+func TestHarvestChunking(t *testing.T) {
+	ctx := context.Background()
+	ivl := time.Second
+	type processedChunk struct {
+		chunk       HarvestChunk
+		services    []string
+		eventsTotal float64
+	}
+	var processed []processedChunk
+	agg, err := New(
+		WithDataDir(t.TempDir()),
+		WithLimits(Limits{
+			MaxServices:                        10,
+			MaxServiceInstanceGroupsPerService: 10,
+		}),
+		WithAggregationIntervals([]time.Duration{ivl}),
+		WithHarvestChunking(2),
+		WithProcessor(func(
+			ctx context.Context,
+			_ CombinedMetricsKey,
+			cm *aggregationpb.CombinedMetrics,
+			_ time.Duration,
+		) error {
+			chunk, ok := HarvestChunkFromContext(ctx)
+			require.True(t, ok)
+			p := processedChunk{chunk: chunk, eventsTotal: cm.EventsTotal}
+			for _, ksm := range cm.ServiceMetrics {
+				p.services = append(p.services, ksm.Key.ServiceName)
+			}
+			processed = append(processed, p)
+			return nil
+		}),
+		WithLogger(zap.NewNop()),
+	)
+	require.NoError(t, err)
+	defer agg.Close(ctx)
+
+	start := time.Unix(agg.processingTime.Unix(), 0)
+	cmk := CombinedMetricsKey{
+		Interval:       ivl,
+		ProcessingTime: start,
+		ID:             EncodeToCombinedMetricsKeyID(t, "ab01"),
+	}
+	for i := 0; i < 5; i++ {
+		cm := NewTestCombinedMetrics(WithEventsTotal(1)).
+			AddServiceMetrics(serviceAggregationKey{
+				Timestamp:   start,
+				ServiceName: fmt.Sprintf("svc-%d", i),
+			}).
+			AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+			GetProto()
+		require.NoError(t, agg.AggregateCombinedMetrics(ctx, cmk, cm))
+		cm.ReturnToVTPool()
+	}
+	agg.mu.Lock()
+	batch := agg.takeBatches()
+	agg.mu.Unlock()
+	require.NoError(t, agg.commitAndHarvest(ctx, batch, start.Add(ivl), []time.Duration{ivl}, nil))
+
+	require.Len(t, processed, 3)
+	var services []string
+	for i, p := range processed {
+		assert.Equal(t, HarvestChunk{Index: i, Last: i == 2}, p.chunk)
+		services = append(services, p.services...)
+	}
+	assert.Len(t, processed[0].services, 2)
+	assert.Len(t, processed[1].services, 2)
+	assert.Len(t, processed[2].services, 1)
+	assert.ElementsMatch(t, []string{"svc-0", "svc-1", "svc-2", "svc-3", "svc-4"}, services)
+	assert.Zero(t, processed[0].eventsTotal)
+	assert.Equal(t, float64(5), processed[2].eventsTotal)
+}
+
+func TestHarvestChunkFromContext(t *testing.T) {
+	_, ok := HarvestChunkFromContext(context.Background())
+	assert.False(t, ok)
+}
@@ -0,0 +1,118 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"sync"
+	"time"
+)
+
+// Health reports the state of the aggregator, e.g. for wiring into the
+// health and readiness endpoints of the embedding server.
+type Health struct {
+	// Running is true if Run has been called and has not returned yet.
+	Running bool
+
+	// Closed is true once Close has been called.
+	Closed bool
+
+	// Paused is true while the aggregator is paused, see Aggregator.Pause.
+	Paused bool
+
+	// LastHarvest is the end time of the last successfully harvested
+	// period, keyed by aggregation interval. Intervals which were not yet
+	// successfully harvested are not present.
+	LastHarvest map[time.Duration]time.Time
+
+	// LastStorageError is the last error returned by the database when
+	// reading or writing the aggregated metrics, if any.
+	LastStorageError error
+
+	// LastStorageErrorTime is the time of the last storage error.
+	LastStorageErrorTime time.Time
+
+	// DiskUsage is the total disk space, in bytes, used by the databases
+	// of all the shards.
+	DiskUsage uint64
+
+	// PendingBytes is the size, in bytes, of the aggregated writes not yet
+	// committed to the database.
+	PendingBytes int64
+}
+
+// Ready returns true if the aggregator is running, not paused and not
+// closed.
+func (h Health) Ready() bool {
+	return h.Running && !h.Paused && !h.Closed
+}
+
+// Health returns the current state of the aggregator.
+func (a *Aggregator) Health() Health {
+	h := a.health.get()
+	h.Running = a.RunStats().Running
+	h.Paused = a.pause.paused()
+	select {
+	case <-a.closed:
+		h.Closed = true
+	default:
+	}
+
+	// The shards are closed, and removed, by Close with the lock held.
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	h.PendingBytes = a.inflightBytes.Load() + a.pendingBatchBytes()
+	for _, shard := range a.shards {
+		h.DiskUsage += shard.Metrics().DiskSpaceUsage()
+	}
+	return h
+}
+
+// healthState tracks the harvests and storage errors for Health.
+type healthState struct {
+	mu                   sync.Mutex
+	lastHarvest          map[time.Duration]time.Time
+	lastStorageError     error
+	lastStorageErrorTime time.Time
+}
+
+func (s *healthState) get() Health {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lastHarvest := make(map[time.Duration]time.Time, len(s.lastHarvest))
+	for ivl, end := range s.lastHarvest {
+		lastHarvest[ivl] = end
+	}
+	return Health{
+		LastHarvest:          lastHarvest,
+		LastStorageError:     s.lastStorageError,
+		LastStorageErrorTime: s.lastStorageErrorTime,
+	}
+}
+
+// recordHarvest records the successful harvest of the aggregation
+// interval up to the given end time.
+func (s *healthState) recordHarvest(ivl time.Duration, end time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lastHarvest == nil {
+		s.lastHarvest = make(map[time.Duration]time.Time)
+	}
+	if end.After(s.lastHarvest[ivl]) {
+		s.lastHarvest[ivl] = end
+	}
+}
+
+// recordStorageError records the error, if not nil, returned by the
+// database and returns it.
+func (s *healthState) recordStorageError(err error) error {
+	if err == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastStorageError = err
+	s.lastStorageErrorTime = time.Now()
+	return err
+}
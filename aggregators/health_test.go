@@ -0,0 +1,86 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestHealth(t *testing.T) {
+	ctx := context.Background()
+	ivl := time.Second
+	agg, err := New(
+		WithDataDir(t.TempDir()),
+		WithLimits(Limits{
+			MaxServices:                        10,
+			MaxServiceInstanceGroupsPerService: 10,
+		}),
+		WithAggregationIntervals([]time.Duration{ivl}),
+		WithLogger(zap.NewNop()),
+	)
+	require.NoError(t, err)
+
+	h := agg.Health()
+	assert.False(t, h.Running)
+	assert.False(t, h.Closed)
+	assert.False(t, h.Ready())
+	assert.Empty(t, h.LastHarvest)
+	assert.NoError(t, h.LastStorageError)
+	assert.Zero(t, h.PendingBytes)
+
+	start := time.Unix(agg.processingTime.Unix(), 0)
+	cm := NewTestCombinedMetrics(WithEventsTotal(1)).
+		AddServiceMetrics(serviceAggregationKey{Timestamp: start, ServiceName: "svc"}).
+		AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+		GetProto()
+	require.NoError(t, agg.AggregateCombinedMetrics(ctx, CombinedMetricsKey{
+		Interval:       ivl,
+		ProcessingTime: start,
+		ID:             EncodeToCombinedMetricsKeyID(t, "ab01"),
+	}, cm))
+	cm.ReturnToVTPool()
+	assert.Positive(t, agg.Health().PendingBytes)
+
+	agg.mu.Lock()
+	batch := agg.takeBatches()
+	agg.mu.Unlock()
+	require.NoError(t, agg.commitAndHarvest(ctx, batch, start.Add(ivl), []time.Duration{ivl}, nil))
+	h = agg.Health()
+	assert.Zero(t, h.PendingBytes)
+	assert.Positive(t, h.DiskUsage)
+	assert.Equal(t, map[time.Duration]time.Time{ivl: start.Add(ivl)}, h.LastHarvest)
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- agg.Run(ctx) }()
+	assert.Eventually(t, func() bool {
+		return agg.Health().Ready()
+	}, 10*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, agg.Close(ctx))
+	assert.ErrorIs(t, <-runErr, ErrAggregatorClosed)
+	h = agg.Health()
+	assert.True(t, h.Closed)
+	assert.False(t, h.Ready())
+	assert.Zero(t, h.DiskUsage)
+}
+
+func TestHealthStorageError(t *testing.T) {
+	var s healthState
+	assert.NoError(t, s.recordStorageError(nil))
+	assert.NoError(t, s.get().LastStorageError)
+
+	err := errors.New("disk failure")
+	assert.Equal(t, err, s.recordStorageError(err))
+	h := s.get()
+	assert.Equal(t, err, h.LastStorageError)
+	assert.False(t, h.LastStorageErrorTime.IsZero())
+}
@@ -0,0 +1,163 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+// Package ddsketch provides a DDSketch implementation for recording
+// durations with relative-error guarantees, as described in the paper
+// [DDSketch: A fast and fully-mergeable quantile sketch with relative-error guarantees](https://arxiv.org/abs/1908.10693).
+package ddsketch
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// DefaultRelativeAccuracy is the relative accuracy of the values returned
+// by a sketch created with New.
+const DefaultRelativeAccuracy = 0.01
+
+// Sketch is a DDSketch for recording positive values. Values are mapped to
+// logarithmically sized bins, such that the value reported for a bin is
+// within the relative accuracy of all the values recorded in the bin.
+// Values lower than or equal to zero are recorded in a dedicated bin. The
+// sketch is not safe for concurrent usage, use an external lock
+// protection if required.
+type Sketch struct {
+	RelativeAccuracy float64
+	ZeroCount        float64
+
+	// Indexes and Counts hold the non-empty bins, sorted by index.
+	Indexes []int32
+	Counts  []float64
+
+	gamma    float64
+	logGamma float64
+}
+
+// New returns a new sketch with the default relative accuracy.
+func New() *Sketch {
+	return NewWithRelativeAccuracy(DefaultRelativeAccuracy)
+}
+
+// NewWithRelativeAccuracy returns a new sketch with the given relative
+// accuracy, which must be in the range (0, 1).
+func NewWithRelativeAccuracy(relativeAccuracy float64) *Sketch {
+	s := &Sketch{RelativeAccuracy: relativeAccuracy}
+	s.init()
+	return s
+}
+
+func (s *Sketch) init() {
+	s.gamma = (1 + s.RelativeAccuracy) / (1 - s.RelativeAccuracy)
+	s.logGamma = math.Log(s.gamma)
+}
+
+// Reset removes all recorded values from the sketch.
+func (s *Sketch) Reset() {
+	s.ZeroCount = 0
+	s.Indexes = s.Indexes[:0]
+	s.Counts = s.Counts[:0]
+}
+
+// RecordDuration records the duration in microseconds n times. Fractional
+// counts are supported.
+func (s *Sketch) RecordDuration(d time.Duration, n float64) {
+	s.RecordValue(float64(d)/float64(time.Microsecond), n)
+}
+
+// RecordValue records the value n times. Fractional counts are supported.
+func (s *Sketch) RecordValue(v, n float64) {
+	if v <= 0 {
+		s.ZeroCount += n
+		return
+	}
+	s.add(s.index(v), n)
+}
+
+// Merge merges the provided sketch. If the sketches have a different
+// relative accuracy, the values of the provided sketch are re-recorded,
+// losing accuracy.
+func (s *Sketch) Merge(from *Sketch) {
+	if from == nil {
+		return
+	}
+	s.ZeroCount += from.ZeroCount
+	if from.RelativeAccuracy != s.RelativeAccuracy {
+		for i, idx := range from.Indexes {
+			s.RecordValue(from.value(idx), from.Counts[i])
+		}
+		return
+	}
+	for i, idx := range from.Indexes {
+		s.add(idx, from.Counts[i])
+	}
+}
+
+// Buckets converts the sketch into ordered slices of counts and values
+// per bin along with the total count. Counts are rounded to the nearest
+// integer and bins with a zero rounded count are omitted.
+func (s *Sketch) Buckets() (uint64, []uint64, []float64) {
+	counts := make([]uint64, 0, len(s.Indexes)+1)
+	values := make([]float64, 0, len(s.Indexes)+1)
+
+	var totalCount uint64
+	if count := uint64(math.Round(s.ZeroCount)); count > 0 {
+		counts = append(counts, count)
+		values = append(values, 0)
+		totalCount += count
+	}
+	for i, idx := range s.Indexes {
+		count := uint64(math.Round(s.Counts[i]))
+		if count == 0 {
+			continue
+		}
+		counts = append(counts, count)
+		values = append(values, s.value(idx))
+		totalCount += count
+	}
+	return totalCount, counts, values
+}
+
+// TotalCount returns the total count of values recorded by the sketch.
+func (s *Sketch) TotalCount() float64 {
+	total := s.ZeroCount
+	for _, c := range s.Counts {
+		total += c
+	}
+	return total
+}
+
+func (s *Sketch) add(idx int32, n float64) {
+	i := sort.Search(len(s.Indexes), func(i int) bool {
+		return s.Indexes[i] >= idx
+	})
+	if i < len(s.Indexes) && s.Indexes[i] == idx {
+		s.Counts[i] += n
+		return
+	}
+	s.Indexes = append(s.Indexes, 0)
+	s.Counts = append(s.Counts, 0)
+	copy(s.Indexes[i+1:], s.Indexes[i:])
+	copy(s.Counts[i+1:], s.Counts[i:])
+	s.Indexes[i] = idx
+	s.Counts[i] = n
+}
+
+// index returns the index of the bin holding the value. The bin with
+// index i holds the values in the range (gamma^(i-1), gamma^i].
+func (s *Sketch) index(v float64) int32 {
+	if s.logGamma == 0 {
+		s.init()
+	}
+	return int32(math.Ceil(math.Log(v) / s.logGamma))
+}
+
+// value returns the value reported for the bin with the given index, which
+// is within the relative accuracy of all values in the bin.
+func (s *Sketch) value(idx int32) float64 {
+	if s.logGamma == 0 {
+		s.init()
+	}
+	return 2 * math.Pow(s.gamma, float64(idx)) / (s.gamma + 1)
+}
@@ -0,0 +1,62 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package ddsketch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRelativeAccuracy(t *testing.T) {
+	s := New()
+	for v := 1.0; v < 1e9; v *= 1.7 {
+		s.Reset()
+		s.RecordValue(v, 1)
+		_, _, values := s.Buckets()
+		assert.InEpsilon(t, v, values[0], DefaultRelativeAccuracy)
+	}
+}
+
+func TestRecordDuration(t *testing.T) {
+	s := New()
+	s.RecordDuration(0, 1)
+	s.RecordDuration(time.Millisecond, 2.5)
+	s.RecordDuration(time.Second, 1)
+	s.RecordDuration(time.Millisecond, 0.5)
+
+	total, counts, values := s.Buckets()
+	assert.Equal(t, uint64(5), total)
+	assert.Equal(t, []uint64{1, 3, 1}, counts)
+	assert.Equal(t, float64(0), values[0])
+	assert.InEpsilon(t, 1000, values[1], DefaultRelativeAccuracy)
+	assert.InEpsilon(t, 1e6, values[2], DefaultRelativeAccuracy)
+	assert.Equal(t, float64(5), s.TotalCount())
+}
+
+func TestMerge(t *testing.T) {
+	s1 := New()
+	s1.RecordValue(10, 1)
+	s1.RecordValue(1000, 1)
+	s2 := New()
+	s2.RecordValue(10, 2)
+	s2.RecordValue(100, 1)
+	s1.Merge(s2)
+
+	total, counts, values := s1.Buckets()
+	assert.Equal(t, uint64(5), total)
+	assert.Equal(t, []uint64{3, 1, 1}, counts)
+	for i, v := range []float64{10, 100, 1000} {
+		assert.InEpsilon(t, v, values[i], DefaultRelativeAccuracy)
+	}
+
+	// Merging a sketch with a different accuracy re-records its values.
+	s3 := NewWithRelativeAccuracy(0.05)
+	s3.RecordValue(100, 1)
+	s1.Merge(s3)
+	total, _, _ = s1.Buckets()
+	assert.Equal(t, uint64(6), total)
+}
@@ -32,6 +32,7 @@ import (
 	"fmt"
 	"math"
 	"math/bits"
+	"sync"
 	"time"
 
 	"golang.org/x/exp/slices"
@@ -42,6 +43,11 @@ const (
 	highestTrackableValue = 3.6e+9 // 1 hour in microseconds
 	significantFigures    = 2
 
+	// MinSignificantFigures and MaxSignificantFigures are the bounds of
+	// the significant figures of the histograms, see NewWithPrecision.
+	MinSignificantFigures = 1
+	MaxSignificantFigures = 5
+
 	// We scale transaction counts in the histogram, which only permits storing
 	// integer counts, to allow for fractional transactions due to sampling.
 	//
@@ -52,15 +58,69 @@ const (
 	histogramCountScale = 1000
 )
 
-var (
-	unitMagnitude               = getUnitMagnitude()
-	bucketCount                 = getBucketCount()
-	subBucketCount              = getSubBucketCount()
-	subBucketHalfCountMagnitude = getSubBucketHalfCountMagnitude()
-	subBucketHalfCount          = getSubBucketHalfCount()
-	subBucketMask               = getSubBucketMask()
-	countsLen                   = getCountsLen()
-)
+// defaultLayout is the layout of the histograms created by New.
+var defaultLayout = newLayout(params{
+	lowestTrackableValue:  lowestTrackableValue,
+	highestTrackableValue: highestTrackableValue,
+	significantFigures:    significantFigures,
+})
+
+// layouts caches the layouts of the histograms with non default
+// parameters, keyed by the parameters.
+var layouts sync.Map
+
+// params are the parameters of a histogram, defining its layout.
+type params struct {
+	lowestTrackableValue  int64
+	highestTrackableValue int64
+	significantFigures    int64
+}
+
+// layout holds the bucket layout derived from the histogram parameters.
+type layout struct {
+	unitMagnitude               int32
+	bucketCount                 int32
+	subBucketCount              int32
+	subBucketHalfCountMagnitude int32
+	subBucketHalfCount          int32
+	subBucketMask               int64
+	countsLen                   int64
+}
+
+func newLayout(p params) *layout {
+	var l layout
+	largestValueWithSingleUnitResolution := 2 * math.Pow10(int(p.significantFigures))
+	subBucketCountMagnitude := int32(math.Ceil(math.Log2(
+		largestValueWithSingleUnitResolution,
+	)))
+	if subBucketCountMagnitude >= 1 {
+		l.subBucketHalfCountMagnitude = subBucketCountMagnitude - 1
+	}
+	if unitMag := int32(math.Floor(math.Log2(
+		float64(p.lowestTrackableValue),
+	))); unitMag > 0 {
+		l.unitMagnitude = unitMag
+	}
+	l.subBucketCount = int32(math.Pow(2, float64(l.subBucketHalfCountMagnitude+1)))
+	l.subBucketHalfCount = l.subBucketCount / 2
+	l.subBucketMask = int64(l.subBucketCount-1) << uint(l.unitMagnitude)
+
+	smallestUntrackableValue := int64(l.subBucketCount) << uint(l.unitMagnitude)
+	l.bucketCount = 1
+	for smallestUntrackableValue < p.highestTrackableValue {
+		if smallestUntrackableValue > (math.MaxInt64 / 2) {
+			// next shift will overflow, meaning that bucket could
+			// represent values up to ones greater than math.MaxInt64,
+			// so it's the last bucket
+			l.bucketCount++
+			break
+		}
+		smallestUntrackableValue <<= 1
+		l.bucketCount++
+	}
+	l.countsLen = int64((l.bucketCount + 1) * l.subBucketHalfCount)
+	return &l
+}
 
 // HistogramRepresentation is an optimization over HDR histogram mainly useful
 // for recording values clustered in some range rather than distributed over
@@ -83,6 +143,57 @@ func New() *HistogramRepresentation {
 	}
 }
 
+// NewWithPrecision returns a new instance of HistogramRepresentation
+// recording values up to the highest trackable value with the given
+// number of significant figures, which must be within
+// [MinSignificantFigures, MaxSignificantFigures]. Fewer significant
+// figures and a lower highest trackable value reduce the number of
+// buckets, and thus the size of the histograms, at the cost of accuracy.
+func NewWithPrecision(significantFigures, highestTrackableValue int64) *HistogramRepresentation {
+	return &HistogramRepresentation{
+		LowestTrackableValue:  lowestTrackableValue,
+		HighestTrackableValue: highestTrackableValue,
+		SignificantFigures:    significantFigures,
+	}
+}
+
+// SamePrecision returns true if the histograms have the same parameters,
+// and thus the same buckets.
+func (h *HistogramRepresentation) SamePrecision(other *HistogramRepresentation) bool {
+	return h.params() == other.params()
+}
+
+// params returns the parameters of the histogram. Histograms decoded
+// without parameters have the default parameters.
+func (h *HistogramRepresentation) params() params {
+	if h.LowestTrackableValue <= 0 || h.HighestTrackableValue <= 0 || h.SignificantFigures <= 0 {
+		return params{
+			lowestTrackableValue:  lowestTrackableValue,
+			highestTrackableValue: highestTrackableValue,
+			significantFigures:    significantFigures,
+		}
+	}
+	return params{
+		lowestTrackableValue:  h.LowestTrackableValue,
+		highestTrackableValue: h.HighestTrackableValue,
+		significantFigures:    h.SignificantFigures,
+	}
+}
+
+func (h *HistogramRepresentation) layout() *layout {
+	p := h.params()
+	if p.lowestTrackableValue == lowestTrackableValue &&
+		p.highestTrackableValue == highestTrackableValue &&
+		p.significantFigures == significantFigures {
+		return defaultLayout
+	}
+	if l, ok := layouts.Load(p); ok {
+		return l.(*layout)
+	}
+	l, _ := layouts.LoadOrStore(p, newLayout(p))
+	return l.(*layout)
+}
+
 // RecordDuration records duration in the histogram representation. It
 // supports recording float64 upto 3 decimal places. This is achieved
 // by scaling the count.
@@ -93,25 +204,47 @@ func (h *HistogramRepresentation) RecordDuration(d time.Duration, n float64) err
 	return h.RecordValues(v, count)
 }
 
+// UnscaledCount converts a sum of the scaled counts stored by the
+// histogram representation to the number of recorded values.
+func UnscaledCount(scaledCount int64) float64 {
+	return float64(scaledCount) / histogramCountScale
+}
+
 // RecordValues records values in the histogram representation.
 func (h *HistogramRepresentation) RecordValues(v, n int64) error {
-	idx := h.countsIndexFor(v)
-	if idx < 0 || int32(countsLen) <= idx {
+	l := h.layout()
+	idx := l.countsIndexFor(v)
+	if idx < 0 || int32(l.countsLen) <= idx {
 		return fmt.Errorf("value %d is too large to be recorded", v)
 	}
 	h.CountsRep.Add(idx, n)
 	return nil
 }
 
-// Merge merges the provided histogram representation.
-// TODO: Add support for migration from a histogram representation
-// with different parameters.
+// Merge merges the provided histogram representation. The values of a
+// histogram with different parameters are recorded again in the buckets
+// of the histogram, at the highest equivalent value of their bucket,
+// values too large to be recorded being recorded as the highest
+// trackable value.
 func (h *HistogramRepresentation) Merge(from *HistogramRepresentation) {
 	if from == nil {
 		return
 	}
+	if h.SamePrecision(from) {
+		from.CountsRep.ForEach(func(bucket int32, value int64) {
+			h.CountsRep.Add(bucket, value)
+		})
+		return
+	}
+	fromLayout := from.layout()
+	highest := h.params().highestTrackableValue
 	from.CountsRep.ForEach(func(bucket int32, value int64) {
-		h.CountsRep.Add(bucket, value)
+		v := fromLayout.highestEquivalentValue(fromLayout.valueFromCountsIndex(bucket))
+		if v > highest {
+			v = highest
+		}
+		// The value is within the trackable range after clamping.
+		_ = h.RecordValues(v, value)
 	})
 }
 
@@ -140,63 +273,103 @@ func (h *HistogramRepresentation) Buckets() (uint64, []uint64, []float64) {
 	return totalCount, counts, values
 }
 
-func (h *HistogramRepresentation) countsIndexFor(v int64) int32 {
-	bucketIdx := h.getBucketIndex(v)
-	subBucketIdx := h.getSubBucketIdx(v, bucketIdx)
-	return h.countsIndex(bucketIdx, subBucketIdx)
+// BucketBounds converts the histogram into ordered slices of counts,
+// lower bounds and upper bounds per bar along with the total count. The
+// upper bounds are the values returned by Buckets.
+func (h *HistogramRepresentation) BucketBounds() (uint64, []uint64, []float64, []float64) {
+	counts := make([]uint64, 0, h.CountsRep.Len())
+	lowers := make([]float64, 0, h.CountsRep.Len())
+	uppers := make([]float64, 0, h.CountsRep.Len())
+
+	var totalCount uint64
+	var prevBucket int32
+	iter := h.iterator()
+	iter.nextCountAtIdx()
+	h.CountsRep.ForEach(func(bucket int32, scaledCounts int64) {
+		if scaledCounts <= 0 {
+			return
+		}
+		if iter.advance(int(bucket - prevBucket)) {
+			count := uint64(math.Round(float64(scaledCounts) / histogramCountScale))
+			counts = append(counts, count)
+			lowers = append(lowers, float64(iter.valueFromIdx))
+			uppers = append(uppers, float64(iter.highestEquivalentValue))
+			totalCount += count
+		}
+		prevBucket = bucket
+	})
+	return totalCount, counts, lowers, uppers
+}
+
+func (l *layout) countsIndexFor(v int64) int32 {
+	bucketIdx := l.getBucketIndex(v)
+	subBucketIdx := l.getSubBucketIdx(v, bucketIdx)
+	return l.countsIndex(bucketIdx, subBucketIdx)
+}
+
+func (l *layout) countsIndex(bucketIdx, subBucketIdx int32) int32 {
+	baseBucketIdx := (bucketIdx + 1) << uint(l.subBucketHalfCountMagnitude)
+	return baseBucketIdx + subBucketIdx - l.subBucketHalfCount
 }
 
-func (h *HistogramRepresentation) countsIndex(bucketIdx, subBucketIdx int32) int32 {
-	baseBucketIdx := (bucketIdx + 1) << uint(subBucketHalfCountMagnitude)
-	return baseBucketIdx + subBucketIdx - subBucketHalfCount
+// valueFromCountsIndex returns the lowest value of the bucket at the
+// counts index, the inverse of countsIndexFor.
+func (l *layout) valueFromCountsIndex(idx int32) int64 {
+	bucketIdx := (idx >> uint(l.subBucketHalfCountMagnitude)) - 1
+	subBucketIdx := (idx & (l.subBucketHalfCount - 1)) + l.subBucketHalfCount
+	if bucketIdx < 0 {
+		subBucketIdx -= l.subBucketHalfCount
+		bucketIdx = 0
+	}
+	return l.valueFromIndex(bucketIdx, subBucketIdx)
 }
 
-func (h *HistogramRepresentation) getBucketIndex(v int64) int32 {
-	var pow2Ceiling = int64(64 - bits.LeadingZeros64(uint64(v|subBucketMask)))
-	return int32(pow2Ceiling - int64(unitMagnitude) -
-		int64(subBucketHalfCountMagnitude+1))
+func (l *layout) getBucketIndex(v int64) int32 {
+	var pow2Ceiling = int64(64 - bits.LeadingZeros64(uint64(v|l.subBucketMask)))
+	return int32(pow2Ceiling - int64(l.unitMagnitude) -
+		int64(l.subBucketHalfCountMagnitude+1))
 }
 
-func (h *HistogramRepresentation) getSubBucketIdx(v int64, idx int32) int32 {
-	return int32(v >> uint(int64(idx)+int64(unitMagnitude)))
+func (l *layout) getSubBucketIdx(v int64, idx int32) int32 {
+	return int32(v >> uint(int64(idx)+int64(l.unitMagnitude)))
 }
 
-func (h *HistogramRepresentation) valueFromIndex(bucketIdx, subBucketIdx int32) int64 {
-	return int64(subBucketIdx) << uint(bucketIdx+unitMagnitude)
+func (l *layout) valueFromIndex(bucketIdx, subBucketIdx int32) int64 {
+	return int64(subBucketIdx) << uint(bucketIdx+l.unitMagnitude)
 }
 
-func (h *HistogramRepresentation) highestEquivalentValue(v int64) int64 {
-	return h.nextNonEquivalentValue(v) - 1
+func (l *layout) highestEquivalentValue(v int64) int64 {
+	return l.nextNonEquivalentValue(v) - 1
 }
 
-func (h *HistogramRepresentation) nextNonEquivalentValue(v int64) int64 {
-	bucketIdx := h.getBucketIndex(v)
-	return h.lowestEquivalentValueGivenBucketIdx(v, bucketIdx) + h.sizeOfEquivalentValueRangeGivenBucketIdx(v, bucketIdx)
+func (l *layout) nextNonEquivalentValue(v int64) int64 {
+	bucketIdx := l.getBucketIndex(v)
+	return l.lowestEquivalentValueGivenBucketIdx(v, bucketIdx) + l.sizeOfEquivalentValueRangeGivenBucketIdx(v, bucketIdx)
 }
 
-func (h *HistogramRepresentation) lowestEquivalentValueGivenBucketIdx(v int64, bucketIdx int32) int64 {
-	subBucketIdx := h.getSubBucketIdx(v, bucketIdx)
-	return h.valueFromIndex(bucketIdx, subBucketIdx)
+func (l *layout) lowestEquivalentValueGivenBucketIdx(v int64, bucketIdx int32) int64 {
+	subBucketIdx := l.getSubBucketIdx(v, bucketIdx)
+	return l.valueFromIndex(bucketIdx, subBucketIdx)
 }
 
-func (h *HistogramRepresentation) sizeOfEquivalentValueRangeGivenBucketIdx(v int64, bucketIdx int32) int64 {
-	subBucketIdx := h.getSubBucketIdx(v, bucketIdx)
+func (l *layout) sizeOfEquivalentValueRangeGivenBucketIdx(v int64, bucketIdx int32) int64 {
+	subBucketIdx := l.getSubBucketIdx(v, bucketIdx)
 	adjustedBucket := bucketIdx
-	if subBucketIdx >= subBucketCount {
+	if subBucketIdx >= l.subBucketCount {
 		adjustedBucket++
 	}
-	return int64(1) << uint(unitMagnitude+adjustedBucket)
+	return int64(1) << uint(l.unitMagnitude+adjustedBucket)
 }
 
 func (h *HistogramRepresentation) iterator() *iterator {
 	return &iterator{
-		h:            h,
+		l:            h.layout(),
 		subBucketIdx: -1,
 	}
 }
 
 type iterator struct {
-	h                       *HistogramRepresentation
+	l                       *layout
 	bucketIdx, subBucketIdx int32
 	valueFromIdx            int64
 	highestEquivalentValue  int64
@@ -209,79 +382,26 @@ func (i *iterator) advance(count int) bool {
 			return false
 		}
 	}
-	i.highestEquivalentValue = i.h.highestEquivalentValue(i.valueFromIdx)
+	i.highestEquivalentValue = i.l.highestEquivalentValue(i.valueFromIdx)
 	return true
 }
 
 func (i *iterator) nextCountAtIdx() bool {
 	// increment bucket
 	i.subBucketIdx++
-	if i.subBucketIdx >= subBucketCount {
-		i.subBucketIdx = subBucketHalfCount
+	if i.subBucketIdx >= i.l.subBucketCount {
+		i.subBucketIdx = i.l.subBucketHalfCount
 		i.bucketIdx++
 	}
 
-	if i.bucketIdx >= bucketCount {
+	if i.bucketIdx >= i.l.bucketCount {
 		return false
 	}
 
-	i.valueFromIdx = i.h.valueFromIndex(i.bucketIdx, i.subBucketIdx)
+	i.valueFromIdx = i.l.valueFromIndex(i.bucketIdx, i.subBucketIdx)
 	return true
 }
 
-func getSubBucketHalfCountMagnitude() int32 {
-	largetValueWithSingleUnitResolution := 2 * math.Pow10(significantFigures)
-	subBucketCountMagnitude := int32(math.Ceil(math.Log2(
-		largetValueWithSingleUnitResolution,
-	)))
-	if subBucketCountMagnitude < 1 {
-		return 0
-	}
-	return subBucketCountMagnitude - 1
-}
-
-func getUnitMagnitude() int32 {
-	unitMag := int32(math.Floor(math.Log2(
-		lowestTrackableValue,
-	)))
-	if unitMag < 0 {
-		return 0
-	}
-	return unitMag
-}
-
-func getSubBucketCount() int32 {
-	return int32(math.Pow(2, float64(getSubBucketHalfCountMagnitude()+1)))
-}
-
-func getSubBucketHalfCount() int32 {
-	return getSubBucketCount() / 2
-}
-
-func getSubBucketMask() int64 {
-	return int64(getSubBucketCount()-1) << uint(getUnitMagnitude())
-}
-
-func getCountsLen() int64 {
-	return int64((getBucketCount() + 1) * (getSubBucketCount() / 2))
-}
-
-func getBucketCount() int32 {
-	smallestUntrackableValue := int64(getSubBucketCount()) << uint(getUnitMagnitude())
-	bucketsNeeded := int32(1)
-	for smallestUntrackableValue < highestTrackableValue {
-		if smallestUntrackableValue > (math.MaxInt64 / 2) {
-			// next shift will overflow, meaning that bucket could
-			// represent values up to ones greater than math.MaxInt64,
-			// so it's the last bucket
-			return bucketsNeeded + 1
-		}
-		smallestUntrackableValue <<= 1
-		bucketsNeeded++
-	}
-	return bucketsNeeded
-}
-
 // bar represents a bar of histogram. Each bar has a bucket, representing
 // where the bar belongs to in the histogram range, and the count of values
 // in each bucket.
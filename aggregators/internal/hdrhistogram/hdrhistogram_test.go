@@ -74,6 +74,25 @@ func TestBuckets(t *testing.T) {
 	assert.Equal(t, expectedValues, actualValues)
 }
 
+func TestBucketBounds(t *testing.T) {
+	histRep := New()
+	values := []int64{0, 1, 999, 1_000, 123_456, 3_600_000_000}
+	for _, v := range values {
+		histRep.RecordValues(v, int64(histogramCountScale))
+	}
+	totalCount, counts, lowers, uppers := histRep.BucketBounds()
+	expectedTotalCount, expectedCounts, expectedValues := histRep.Buckets()
+
+	assert.Equal(t, expectedTotalCount, totalCount)
+	assert.Equal(t, expectedCounts, counts)
+	assert.Equal(t, expectedValues, uppers)
+	require.Len(t, lowers, len(values))
+	for i, v := range values {
+		assert.LessOrEqual(t, lowers[i], float64(v))
+		assert.GreaterOrEqual(t, uppers[i], float64(v))
+	}
+}
+
 func getTestHistogram() *hdrhistogram.Histogram {
 	return hdrhistogram.New(
 		lowestTrackableValue,
@@ -83,7 +102,7 @@ func getTestHistogram() *hdrhistogram.Histogram {
 }
 
 func convertHistogramRepToSnapshot(h *HistogramRepresentation) *hdrhistogram.Snapshot {
-	counts := make([]int64, countsLen)
+	counts := make([]int64, h.layout().countsLen)
 	h.CountsRep.ForEach(func(bucket int32, value int64) {
 		counts[bucket] += value
 	})
@@ -94,3 +113,48 @@ func convertHistogramRepToSnapshot(h *HistogramRepresentation) *hdrhistogram.Sna
 		Counts:                counts,
 	}
 }
+
+func TestNewWithPrecision(t *testing.T) {
+	for _, tc := range []struct {
+		significantFigures    int64
+		highestTrackableValue int64
+	}{
+		{significantFigures: 1, highestTrackableValue: 60_000_000},
+		{significantFigures: 3, highestTrackableValue: 3_600_000_000},
+		{significantFigures: 2, highestTrackableValue: 86_400_000_000},
+	} {
+		hist := hdrhistogram.New(lowestTrackableValue, tc.highestTrackableValue, int(tc.significantFigures))
+		histRep := NewWithPrecision(tc.significantFigures, tc.highestTrackableValue)
+		for i := 0; i < 100_000; i++ {
+			v := rand.Int63n(tc.highestTrackableValue)
+			c := rand.Int63n(1_000)
+			require.NoError(t, hist.RecordValues(v, c))
+			require.NoError(t, histRep.RecordValues(v, c))
+		}
+		assert.Empty(t, cmp.Diff(hist.Export(), convertHistogramRepToSnapshot(histRep)))
+		assert.Error(t, histRep.RecordValues(2*tc.highestTrackableValue, 1))
+	}
+}
+
+func TestMergeDifferentPrecision(t *testing.T) {
+	coarse := NewWithPrecision(1, 60_000_000)
+	fine := New()
+	values := []int64{0, 1, 999, 123_456, 59_000_000, 3_600_000_000}
+	for _, v := range values {
+		require.NoError(t, fine.RecordValues(v, int64(histogramCountScale)))
+	}
+	assert.False(t, coarse.SamePrecision(fine))
+	coarse.Merge(fine)
+
+	totalCount, counts, upperValues := coarse.Buckets()
+	assert.Equal(t, uint64(len(values)), totalCount)
+	// The values above the highest trackable value of the coarse
+	// histogram are recorded in its last bucket.
+	assert.Equal(t, uint64(2), counts[len(counts)-1])
+	for _, v := range upperValues {
+		assert.LessOrEqual(t, v, float64(2*60_000_000))
+	}
+
+	// Histograms decoded without parameters have the default parameters.
+	assert.True(t, New().SamePrecision(&HistogramRepresentation{}))
+}
@@ -26,17 +26,36 @@ func writeUint64(h *xxhash.Digest, v uint64) {
 	h.Write(buf[:])
 }
 
+func HashErrorAggregationKey(h xxhash.Digest, k *aggregationpb.ErrorAggregationKey) xxhash.Digest {
+	h.WriteString(k.GroupingKey)
+	h.WriteString(k.Outcome)
+	return h
+}
+
 func HashServiceAggregationKey(h xxhash.Digest, k *aggregationpb.ServiceAggregationKey) xxhash.Digest {
 	writeUint64(&h, k.Timestamp)
 	h.WriteString(k.ServiceName)
 	h.WriteString(k.ServiceEnvironment)
 	h.WriteString(k.ServiceLanguageName)
 	h.WriteString(k.AgentName)
+	h.Write(k.CustomDimensions)
+	h.WriteString(k.KubernetesNamespace)
+	h.WriteString(k.KubernetesDeploymentName)
+	return h
+}
+
+func HashServiceGraphEdgeAggregationKey(h xxhash.Digest, k *aggregationpb.ServiceGraphEdgeAggregationKey) xxhash.Digest {
+	h.WriteString(k.TargetType)
+	h.WriteString(k.TargetName)
+	h.WriteString(k.Resource)
 	return h
 }
 
 func HashServiceInstanceAggregationKey(h xxhash.Digest, k *aggregationpb.ServiceInstanceAggregationKey) xxhash.Digest {
 	h.Write(k.GlobalLabelsStr)
+	h.WriteString(k.HostName)
+	h.WriteString(k.ContainerId)
+	h.WriteString(k.KubernetesPodName)
 	return h
 }
 
@@ -51,6 +70,8 @@ func HashSpanAggregationKey(h xxhash.Digest, k *aggregationpb.SpanAggregationKey
 	h.WriteString(k.TargetType)
 	h.WriteString(k.TargetName)
 	h.WriteString(k.Resource)
+	h.WriteString(k.SpanSubtype)
+	h.Write(k.CustomDimensions)
 	return h
 }
 
@@ -86,5 +107,9 @@ func HashTransactionAggregationKey(h xxhash.Digest, k *aggregationpb.Transaction
 	h.WriteString(k.CloudMachineType)
 	h.WriteString(k.CloudProjectId)
 	h.WriteString(k.CloudProjectName)
+	h.Write(k.CustomDimensions)
+	h.WriteString(k.HostOsName)
+	h.WriteString(k.HostOsVersion)
+	h.WriteString(k.NetworkConnectionType)
 	return h
 }
@@ -0,0 +1,183 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+// Package tdigest provides a merging t-digest implementation for recording
+// durations, as described in the paper
+// [Computing Extremely Accurate Quantiles Using t-Digests](https://arxiv.org/abs/1902.04023).
+package tdigest
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// DefaultCompression is the compression of a t-digest created with New.
+// Higher compression keeps more centroids, improving accuracy at the cost
+// of size.
+const DefaultCompression = 100
+
+// TDigest is a merging t-digest. Recorded values are buffered and merged
+// into centroids once the buffer is full or when the centroids are read.
+// The t-digest is not safe for concurrent usage, use an external lock
+// protection if required.
+type TDigest struct {
+	compression float64
+
+	// means and weights hold the centroids, sorted by mean, followed by
+	// any unmerged values.
+	means    []float64
+	weights  []float64
+	unmerged int
+}
+
+// New returns a new t-digest with the default compression.
+func New() *TDigest {
+	return NewWithCompression(DefaultCompression)
+}
+
+// NewWithCompression returns a new t-digest with the given compression.
+func NewWithCompression(compression float64) *TDigest {
+	return &TDigest{compression: compression}
+}
+
+// Compression returns the compression of the t-digest.
+func (t *TDigest) Compression() float64 {
+	return t.compression
+}
+
+// Reset removes all recorded values from the t-digest.
+func (t *TDigest) Reset() {
+	t.means = t.means[:0]
+	t.weights = t.weights[:0]
+	t.unmerged = 0
+}
+
+// RecordDuration records the duration in microseconds n times. Fractional
+// counts are supported.
+func (t *TDigest) RecordDuration(d time.Duration, n float64) {
+	t.Add(float64(d)/float64(time.Microsecond), n)
+}
+
+// Add records the value with the given weight.
+func (t *TDigest) Add(v, w float64) {
+	if w <= 0 {
+		return
+	}
+	t.means = append(t.means, v)
+	t.weights = append(t.weights, w)
+	t.unmerged++
+	if t.unmerged > 5*int(t.compression) {
+		t.compress()
+	}
+}
+
+// Merge merges the provided t-digest.
+func (t *TDigest) Merge(from *TDigest) {
+	if from == nil {
+		return
+	}
+	for i, m := range from.means {
+		t.Add(m, from.weights[i])
+	}
+}
+
+// Centroids returns the means and weights of the centroids of the
+// t-digest, sorted by mean. The returned slices must not be modified.
+func (t *TDigest) Centroids() ([]float64, []float64) {
+	t.compress()
+	return t.means, t.weights
+}
+
+// Buckets converts the t-digest into ordered slices of counts and values
+// per centroid along with the total count. Counts are rounded to the
+// nearest integer and centroids with a zero rounded count are omitted.
+func (t *TDigest) Buckets() (uint64, []uint64, []float64) {
+	t.compress()
+	counts := make([]uint64, 0, len(t.means))
+	values := make([]float64, 0, len(t.means))
+
+	var totalCount uint64
+	for i, m := range t.means {
+		count := uint64(math.Round(t.weights[i]))
+		if count == 0 {
+			continue
+		}
+		counts = append(counts, count)
+		values = append(values, m)
+		totalCount += count
+	}
+	return totalCount, counts, values
+}
+
+// TotalCount returns the total weight of values recorded by the t-digest.
+func (t *TDigest) TotalCount() float64 {
+	var total float64
+	for _, w := range t.weights {
+		total += w
+	}
+	return total
+}
+
+// compress merges all the centroids and unmerged values, keeping the size
+// of each centroid within the bound given by the k1 scale function.
+func (t *TDigest) compress() {
+	if t.unmerged == 0 {
+		return
+	}
+	t.unmerged = 0
+	sort.Sort(centroids{t})
+
+	var total float64
+	for _, w := range t.weights {
+		total += w
+	}
+
+	n := 0
+	weightSoFar := 0.0
+	qLimit := t.kInv(t.k(0) + 1)
+	for i := 1; i < len(t.means); i++ {
+		w := t.weights[i]
+		if t.means[i] == t.means[n] || (weightSoFar+t.weights[n]+w)/total <= qLimit {
+			// Merge into the current centroid, equal values are always
+			// merged as it does not lose any accuracy.
+			t.weights[n] += w
+			t.means[n] += (t.means[i] - t.means[n]) * w / t.weights[n]
+			continue
+		}
+		weightSoFar += t.weights[n]
+		qLimit = t.kInv(t.k(weightSoFar/total) + 1)
+		n++
+		t.means[n] = t.means[i]
+		t.weights[n] = w
+	}
+	t.means = t.means[:n+1]
+	t.weights = t.weights[:n+1]
+}
+
+// k is the k1 scale function mapping a quantile to a scale.
+func (t *TDigest) k(q float64) float64 {
+	return t.compression / (2 * math.Pi) * math.Asin(2*q-1)
+}
+
+// kInv is the inverse of the k1 scale function.
+func (t *TDigest) kInv(k float64) float64 {
+	if k >= t.compression/4 {
+		return 1
+	}
+	return (math.Sin(k*2*math.Pi/t.compression) + 1) / 2
+}
+
+type centroids struct {
+	t *TDigest
+}
+
+func (c centroids) Len() int { return len(c.t.means) }
+
+func (c centroids) Less(i, j int) bool { return c.t.means[i] < c.t.means[j] }
+
+func (c centroids) Swap(i, j int) {
+	c.t.means[i], c.t.means[j] = c.t.means[j], c.t.means[i]
+	c.t.weights[i], c.t.weights[j] = c.t.weights[j], c.t.weights[i]
+}
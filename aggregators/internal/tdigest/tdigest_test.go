@@ -0,0 +1,61 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package tdigest
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuckets(t *testing.T) {
+	td := New()
+	td.RecordDuration(time.Millisecond, 2.5)
+	td.RecordDuration(time.Second, 1)
+	td.RecordDuration(time.Millisecond, 0.5)
+
+	total, counts, values := td.Buckets()
+	assert.Equal(t, uint64(4), total)
+	assert.Equal(t, []uint64{3, 1}, counts)
+	assert.Equal(t, []float64{1000, 1e6}, values)
+	assert.Equal(t, float64(4), td.TotalCount())
+}
+
+func TestQuantileAccuracy(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+	values := make([]float64, 100000)
+	td1, td2 := New(), New()
+	for i := range values {
+		values[i] = r.ExpFloat64() * 1000
+		if i%2 == 0 {
+			td1.Add(values[i], 1)
+		} else {
+			td2.Add(values[i], 1)
+		}
+	}
+	td1.Merge(td2)
+	sort.Float64s(values)
+
+	means, weights := td1.Centroids()
+	assert.Less(t, len(means), 2*DefaultCompression)
+	assert.True(t, sort.Float64sAreSorted(means))
+	for _, q := range []float64{0.5, 0.9, 0.99} {
+		rank := q * float64(len(values))
+		var cumulative float64
+		var estimate float64
+		for i, w := range weights {
+			cumulative += w
+			if cumulative >= rank {
+				estimate = means[i]
+				break
+			}
+		}
+		expected := values[int(rank)]
+		assert.InEpsilon(t, expected, estimate, 0.05, "quantile %v", q)
+	}
+}
@@ -8,8 +8,13 @@ package telemetry
 import (
 	"context"
 	"fmt"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/cockroachdb/pebble"
+	dto "github.com/prometheus/client_model/go"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
 )
 
@@ -32,8 +37,22 @@ type Metrics struct {
 	BytesIngested   metric.Int64Counter
 	EventsTotal     metric.Float64Counter
 	EventsProcessed metric.Float64Counter
+	EventsRecovered metric.Float64Counter
+	EventsLost      metric.Float64Counter
+	HarvestGaps     metric.Int64Counter
+	GCReclaimed     metric.Int64Counter
+	OverflowGroups  metric.Int64Counter
+	MergeThrottled  metric.Float64Counter
+	SinkProcessed   metric.Int64Counter
+	SinkFailed      metric.Int64Counter
+	SinkRetries     metric.Int64Counter
+	SinkDropped     metric.Int64Counter
+	MergeDuration   metric.Float64Histogram
 	MinQueuedDelay  metric.Float64Histogram
 	ProcessingDelay metric.Float64Histogram
+	FreshnessDelay  metric.Float64Histogram
+
+	CombinedMetricsSize metric.Int64Histogram
 
 	// Asynchronous metrics used to get pebble metrics and
 	// record measurements. These are kept unexported as they are
@@ -54,16 +73,68 @@ type Metrics struct {
 	pebbleMarkedForCompactionFiles metric.Int64ObservableGauge
 	pebbleKeysTombstones           metric.Int64ObservableGauge
 
+	// Asynchronous metrics reporting a curated set of storage engine
+	// measurements. The pebble metrics above are named after the storage
+	// engine internals, whereas the following names and units are kept
+	// stable across storage engine upgrades:
+	//
+	//   - aggregator.storage.compaction.duration (s): cumulative time
+	//     spent in compactions.
+	//   - aggregator.storage.levels.size (by): size of the files in each
+	//     LSM level, identified by the level attribute.
+	//   - aggregator.storage.memtable.size (by): current size of the
+	//     memtables.
+	//   - aggregator.storage.wal.fsync.duration (s): cumulative time
+	//     spent syncing the write-ahead log.
+	//   - aggregator.storage.wal.fsyncs (1): number of write-ahead log
+	//     syncs.
+	storageCompactionDuration metric.Float64ObservableCounter
+	storageLevelsSize         metric.Int64ObservableGauge
+	storageMemtableSize       metric.Int64ObservableGauge
+	storageWALFsyncDuration   metric.Float64ObservableCounter
+	storageWALFsyncs          metric.Int64ObservableCounter
+
+	// Asynchronous metric used to report the aggregation limits in
+	// force, updated via the registered callback from the limits
+	// providers.
+	limits metric.Int64ObservableGauge
+
+	// Asynchronous metric used to report the number of aggregators per
+	// configuration fingerprint.
+	configs metric.Int64ObservableGauge
+
 	// registration represents the token for a the configured callback.
 	registration metric.Registration
+
+	mu              sync.Mutex
+	providers       map[int]pebbleProvider
+	limitsProviders map[int]limitsProvider
+	fingerprints    map[int]string
+	nextProviderID  int
 }
 
 type pebbleProvider func() *pebble.Metrics
 
-// NewMetrics returns a new instance of the metrics.
+// Limit is the value of a named aggregation limit.
+type Limit struct {
+	Name  string
+	Value int64
+}
+
+type limitsProvider func() []Limit
+
+// NewMetrics returns a new instance of the metrics. The provider is
+// optional, more providers can be added by calling AddPebbleProvider.
 func NewMetrics(provider pebbleProvider, opts ...Option) (*Metrics, error) {
 	var err error
-	var i Metrics
+	i := Metrics{
+		providers:       make(map[int]pebbleProvider),
+		limitsProviders: make(map[int]limitsProvider),
+		fingerprints:    make(map[int]string),
+	}
+	if provider != nil {
+		i.AddPebbleProvider(provider)
+	}
 
 	cfg := newConfig(opts...)
 	meter := cfg.Meter
@@ -109,6 +180,94 @@ func NewMetrics(provider pebbleProvider, opts ...Option) (*Metrics, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to create metric for events processed: %w", err)
 	}
+	i.EventsRecovered, err = meter.Float64Counter(
+		"aggregator.events.recovered",
+		metric.WithDescription("APM Events of stale processing times harvested by the recovery phase of the aggregator per aggregation interval"),
+		metric.WithUnit(countUnit),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metric for events recovered: %w", err)
+	}
+	i.HarvestGaps, err = meter.Int64Counter(
+		"aggregator.harvest.gaps",
+		metric.WithDescription("Aggregation periods without harvested metrics between harvested periods per aggregation interval"),
+		metric.WithUnit(countUnit),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metric for harvest gaps: %w", err)
+	}
+	i.GCReclaimed, err = meter.Int64Counter(
+		"aggregator.gc.reclaimed",
+		metric.WithDescription("Number of bytes of keys older than the max retention dropped by the garbage collection"),
+		metric.WithUnit(bytesUnit),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metric for gc reclaimed: %w", err)
+	}
+	i.EventsLost, err = meter.Float64Counter(
+		"aggregator.events.lost",
+		metric.WithDescription("APM Events, or representative counts, discarded or folded into overflow, by reason"),
+		metric.WithUnit(countUnit),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metric for events lost: %w", err)
+	}
+	i.OverflowGroups, err = meter.Int64Counter(
+		"aggregator.overflow.groups",
+		metric.WithDescription("Estimated number of aggregation groups folded into overflow per harvested combined metrics, by limit"),
+		metric.WithUnit(countUnit),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metric for overflow groups: %w", err)
+	}
+	i.MergeThrottled, err = meter.Float64Counter(
+		"aggregator.merge.throttled",
+		metric.WithDescription("Time merging combined metrics waited after exhausting the merge budget"),
+		metric.WithUnit(durationUnit),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metric for merge throttled: %w", err)
+	}
+	i.SinkProcessed, err = meter.Int64Counter(
+		"aggregator.sink.processed",
+		metric.WithDescription("Number of combined metrics successfully processed per sink"),
+		metric.WithUnit(countUnit),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metric for sink processed: %w", err)
+	}
+	i.SinkFailed, err = meter.Int64Counter(
+		"aggregator.sink.failed",
+		metric.WithDescription("Number of combined metrics which failed to be processed per sink, after the retries"),
+		metric.WithUnit(countUnit),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metric for sink failed: %w", err)
+	}
+	i.SinkRetries, err = meter.Int64Counter(
+		"aggregator.sink.retries",
+		metric.WithDescription("Number of retries of processing combined metrics per sink"),
+		metric.WithUnit(countUnit),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metric for sink retries: %w", err)
+	}
+	i.SinkDropped, err = meter.Int64Counter(
+		"aggregator.sink.dropped",
+		metric.WithDescription("Number of combined metrics dropped per sink as its queue was full"),
+		metric.WithUnit(countUnit),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metric for sink dropped: %w", err)
+	}
+	i.MergeDuration, err = meter.Float64Histogram(
+		"aggregator.merge.duration",
+		metric.WithDescription("Records the time spent merging the combined metrics of a key, e.g. during compactions"),
+		metric.WithUnit(durationUnit),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metric for merge duration: %w", err)
+	}
 	i.MinQueuedDelay, err = meter.Float64Histogram(
 		"events.queued-delay",
 		metric.WithDescription("Records total duration for aggregating a batch w.r.t. its youngest member"),
@@ -125,6 +284,22 @@ func NewMetrics(provider pebbleProvider, opts ...Option) (*Metrics, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to create metric for processing delay: %w", err)
 	}
+	i.FreshnessDelay, err = meter.Float64Histogram(
+		"events.freshness-delay",
+		metric.WithDescription("Records the delay between the end of an aggregation interval and the successful completion of the processor"),
+		metric.WithUnit(durationUnit),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metric for freshness delay: %w", err)
+	}
+	i.CombinedMetricsSize, err = meter.Int64Histogram(
+		"aggregator.combined_metrics.size",
+		metric.WithDescription("Records the encoded size of the combined metrics passed to AggregateCombinedMetrics"),
+		metric.WithUnit(bytesUnit),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metric for combined metrics size: %w", err)
+	}
 
 	// Pebble metrics
 	i.pebbleFlushes, err = meter.Int64ObservableCounter(
@@ -240,12 +415,122 @@ func NewMetrics(provider pebbleProvider, opts ...Option) (*Metrics, error) {
 		return nil, fmt.Errorf("failed to create metric for tombstones: %w", err)
 	}
 
-	if err := i.registerCallback(meter, provider); err != nil {
+	// Storage metrics
+	i.storageCompactionDuration, err = meter.Float64ObservableCounter(
+		"aggregator.storage.compaction.duration",
+		metric.WithDescription("Cumulative time spent in storage compactions"),
+		metric.WithUnit(durationUnit),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metric for storage compaction duration: %w", err)
+	}
+	i.storageLevelsSize, err = meter.Int64ObservableGauge(
+		"aggregator.storage.levels.size",
+		metric.WithDescription("Size of the storage files per LSM level, identified by the level attribute"),
+		metric.WithUnit(bytesUnit),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metric for storage levels size: %w", err)
+	}
+	i.storageMemtableSize, err = meter.Int64ObservableGauge(
+		"aggregator.storage.memtable.size",
+		metric.WithDescription("Current size of the storage memtables"),
+		metric.WithUnit(bytesUnit),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metric for storage memtable size: %w", err)
+	}
+	i.storageWALFsyncDuration, err = meter.Float64ObservableCounter(
+		"aggregator.storage.wal.fsync.duration",
+		metric.WithDescription("Cumulative time spent syncing the storage write-ahead log"),
+		metric.WithUnit(durationUnit),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metric for storage WAL fsync duration: %w", err)
+	}
+	i.storageWALFsyncs, err = meter.Int64ObservableCounter(
+		"aggregator.storage.wal.fsyncs",
+		metric.WithDescription("Number of storage write-ahead log syncs"),
+		metric.WithUnit(countUnit),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metric for storage WAL fsyncs: %w", err)
+	}
+
+	i.limits, err = meter.Int64ObservableGauge(
+		"aggregator.limits",
+		metric.WithDescription("Aggregation limits in force, identified by the limit attribute"),
+		metric.WithUnit(countUnit),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metric for limits: %w", err)
+	}
+	i.configs, err = meter.Int64ObservableGauge(
+		"aggregator.config",
+		metric.WithDescription("Number of aggregators per configuration, identified by the fingerprint attribute"),
+		metric.WithUnit(countUnit),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metric for config: %w", err)
+	}
+
+	if err := i.registerCallback(meter); err != nil {
 		return nil, fmt.Errorf("failed to register callback: %w", err)
 	}
 	return &i, nil
 }
 
+// AddPebbleProvider adds a provider for pebble metrics. When multiple
+// providers are added, the reported pebble metrics are aggregated across
+// all the providers. The returned function removes the provider.
+func (i *Metrics) AddPebbleProvider(provider pebbleProvider) (remove func()) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	id := i.nextProviderID
+	i.nextProviderID++
+	i.providers[id] = provider
+	return func() {
+		i.mu.Lock()
+		defer i.mu.Unlock()
+		delete(i.providers, id)
+	}
+}
+
+// AddLimitsProvider adds a provider for the aggregation limits in force.
+// The limits are read every time the metrics are collected, so updated
+// limits are reported on the next collection. When multiple providers
+// report the same limit, the highest value is reported. The returned
+// function removes the provider.
+func (i *Metrics) AddLimitsProvider(provider limitsProvider) (remove func()) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	id := i.nextProviderID
+	i.nextProviderID++
+	i.limitsProviders[id] = provider
+	return func() {
+		i.mu.Lock()
+		defer i.mu.Unlock()
+		delete(i.limitsProviders, id)
+	}
+}
+
+// AddConfigFingerprint adds the configuration fingerprint of an
+// aggregator. The number of aggregators per fingerprint is reported, so
+// that configuration rollouts can be followed. The returned function
+// removes the fingerprint.
+func (i *Metrics) AddConfigFingerprint(fingerprint string) (remove func()) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	id := i.nextProviderID
+	i.nextProviderID++
+	i.fingerprints[id] = fingerprint
+	return func() {
+		i.mu.Lock()
+		defer i.mu.Unlock()
+		delete(i.fingerprints, id)
+	}
+}
+
 // CleanUp unregisters any registered callback for collecting async
 // measurements.
 func (i *Metrics) CleanUp() error {
@@ -258,28 +543,71 @@ func (i *Metrics) CleanUp() error {
 	return nil
 }
 
-func (i *Metrics) registerCallback(meter metric.Meter, provider pebbleProvider) (err error) {
+func (i *Metrics) registerCallback(meter metric.Meter) (err error) {
 	i.registration, err = meter.RegisterCallback(func(ctx context.Context, obs metric.Observer) error {
-		pm := provider()
-		obs.ObserveInt64(i.pebbleMemtableTotalSize, int64(pm.MemTable.Size))
-		obs.ObserveInt64(i.pebbleTotalDiskUsage, int64(pm.DiskSpaceUsage()))
-
-		obs.ObserveInt64(i.pebbleFlushes, pm.Flush.Count)
-		obs.ObserveInt64(i.pebbleFlushedBytes, int64(pm.Levels[0].BytesFlushed))
-
-		obs.ObserveInt64(i.pebbleCompactions, pm.Compact.Count)
-		obs.ObserveInt64(i.pebblePendingCompaction, int64(pm.Compact.EstimatedDebt))
-		obs.ObserveInt64(i.pebbleMarkedForCompactionFiles, int64(pm.Compact.MarkedFiles))
-
-		obs.ObserveInt64(i.pebbleTableReadersMemEstimate, pm.TableCache.Size)
-		obs.ObserveInt64(i.pebbleKeysTombstones, int64(pm.Keys.TombstoneCount))
-
-		lm := pm.Total()
-		obs.ObserveInt64(i.pebbleNumSSTables, lm.NumFiles)
-		obs.ObserveInt64(i.pebbleIngestedBytes, int64(lm.BytesIngested))
-		obs.ObserveInt64(i.pebbleCompactedBytesRead, int64(lm.BytesRead))
-		obs.ObserveInt64(i.pebbleCompactedBytesWritten, int64(lm.BytesCompacted))
-		obs.ObserveInt64(i.pebbleReadAmplification, int64(lm.Sublevels))
+		var m pebbleMeasurements
+		limits := make(map[string]int64)
+		configs := make(map[string]int64)
+		i.mu.Lock()
+		for _, fingerprint := range i.fingerprints {
+			configs[fingerprint]++
+		}
+		for _, provider := range i.providers {
+			m.add(provider())
+		}
+		for _, provider := range i.limitsProviders {
+			for _, l := range provider() {
+				if v, ok := limits[l.Name]; !ok || l.Value > v {
+					limits[l.Name] = l.Value
+				}
+			}
+		}
+		i.mu.Unlock()
+
+		names := make([]string, 0, len(limits))
+		for name := range limits {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			obs.ObserveInt64(i.limits, limits[name], metric.WithAttributes(
+				attribute.String("limit", name),
+			))
+		}
+		for fingerprint, n := range configs {
+			obs.ObserveInt64(i.configs, n, metric.WithAttributes(
+				attribute.String("fingerprint", fingerprint),
+			))
+		}
+
+		obs.ObserveInt64(i.pebbleMemtableTotalSize, m.memtableTotalSize)
+		obs.ObserveInt64(i.pebbleTotalDiskUsage, m.totalDiskUsage)
+
+		obs.ObserveInt64(i.pebbleFlushes, m.flushes)
+		obs.ObserveInt64(i.pebbleFlushedBytes, m.flushedBytes)
+
+		obs.ObserveInt64(i.pebbleCompactions, m.compactions)
+		obs.ObserveInt64(i.pebblePendingCompaction, m.pendingCompaction)
+		obs.ObserveInt64(i.pebbleMarkedForCompactionFiles, m.markedForCompactionFiles)
+
+		obs.ObserveInt64(i.pebbleTableReadersMemEstimate, m.tableReadersMemEstimate)
+		obs.ObserveInt64(i.pebbleKeysTombstones, m.keysTombstones)
+
+		obs.ObserveInt64(i.pebbleNumSSTables, m.numSSTables)
+		obs.ObserveInt64(i.pebbleIngestedBytes, m.ingestedBytes)
+		obs.ObserveInt64(i.pebbleCompactedBytesRead, m.compactedBytesRead)
+		obs.ObserveInt64(i.pebbleCompactedBytesWritten, m.compactedBytesWritten)
+		obs.ObserveInt64(i.pebbleReadAmplification, m.readAmplification)
+
+		obs.ObserveFloat64(i.storageCompactionDuration, m.compactionDuration.Seconds())
+		for level, size := range m.levelsSize {
+			obs.ObserveInt64(i.storageLevelsSize, size, metric.WithAttributes(
+				attribute.Int("level", level),
+			))
+		}
+		obs.ObserveInt64(i.storageMemtableSize, m.memtableTotalSize)
+		obs.ObserveFloat64(i.storageWALFsyncDuration, m.walFsyncDuration.Seconds())
+		obs.ObserveInt64(i.storageWALFsyncs, m.walFsyncs)
 		return nil
 	},
 		i.pebbleMemtableTotalSize,
@@ -296,6 +624,78 @@ func (i *Metrics) registerCallback(meter metric.Meter, provider pebbleProvider)
 		i.pebblePendingCompaction,
 		i.pebbleMarkedForCompactionFiles,
 		i.pebbleKeysTombstones,
+		i.storageCompactionDuration,
+		i.storageLevelsSize,
+		i.storageMemtableSize,
+		i.storageWALFsyncDuration,
+		i.storageWALFsyncs,
+		i.limits,
+		i.configs,
 	)
 	return
 }
+
+// pebbleMeasurements aggregates the measurements of one or more pebble
+// databases. All measurements are summed except the read amplification,
+// which reports the highest read amplification of any database.
+type pebbleMeasurements struct {
+	memtableTotalSize        int64
+	totalDiskUsage           int64
+	flushes                  int64
+	flushedBytes             int64
+	compactions              int64
+	pendingCompaction        int64
+	markedForCompactionFiles int64
+	tableReadersMemEstimate  int64
+	keysTombstones           int64
+	numSSTables              int64
+	ingestedBytes            int64
+	compactedBytesRead       int64
+	compactedBytesWritten    int64
+	readAmplification        int64
+	compactionDuration       time.Duration
+	levelsSize               [numLevels]int64
+	walFsyncDuration         time.Duration
+	walFsyncs                int64
+}
+
+// numLevels is the number of LSM levels of the pebble databases.
+const numLevels = len(pebble.Metrics{}.Levels)
+
+func (m *pebbleMeasurements) add(pm *pebble.Metrics) {
+	m.memtableTotalSize += int64(pm.MemTable.Size)
+	m.totalDiskUsage += int64(pm.DiskSpaceUsage())
+
+	m.flushes += pm.Flush.Count
+	m.flushedBytes += int64(pm.Levels[0].BytesFlushed)
+
+	m.compactions += pm.Compact.Count
+	m.pendingCompaction += int64(pm.Compact.EstimatedDebt)
+	m.markedForCompactionFiles += int64(pm.Compact.MarkedFiles)
+
+	m.tableReadersMemEstimate += pm.TableCache.Size
+	m.keysTombstones += int64(pm.Keys.TombstoneCount)
+
+	m.compactionDuration += pm.Compact.Duration
+	for level, lm := range pm.Levels {
+		m.levelsSize[level] += lm.Size
+	}
+	// The fsync latency histogram is only set for open databases and
+	// records the latencies in nanoseconds.
+	if h := pm.LogWriter.FsyncLatency; h != nil {
+		var dm dto.Metric
+		if err := h.Write(&dm); err == nil && dm.Histogram != nil {
+			m.walFsyncDuration += time.Duration(dm.Histogram.GetSampleSum())
+			m.walFsyncs += int64(dm.Histogram.GetSampleCount())
+		}
+	}
+
+	lm := pm.Total()
+	m.numSSTables += lm.NumFiles
+	m.ingestedBytes += int64(lm.BytesIngested)
+	m.compactedBytesRead += int64(lm.BytesRead)
+	m.compactedBytesWritten += int64(lm.BytesCompacted)
+	if ra := int64(lm.Sublevels); ra > m.readAmplification {
+		m.readAmplification = ra
+	}
+}
@@ -6,11 +6,15 @@ package telemetry
 
 import (
 	"context"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/cockroachdb/pebble"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 	"go.opentelemetry.io/otel/sdk/metric/metricdata/metricdatatest"
@@ -170,6 +174,68 @@ func TestNewInstruments(t *testing.T) {
 				},
 			},
 		},
+		{
+			Name:        "aggregator.storage.compaction.duration",
+			Description: "Cumulative time spent in storage compactions",
+			Unit:        "s",
+			Data: metricdata.Sum[float64]{
+				DataPoints: []metricdata.DataPoint[float64]{
+					{Value: 0},
+				},
+				Temporality: metricdata.CumulativeTemporality,
+				IsMonotonic: true,
+			},
+		},
+		{
+			Name:        "aggregator.storage.levels.size",
+			Description: "Size of the storage files per LSM level, identified by the level attribute",
+			Unit:        "by",
+			Data: metricdata.Gauge[int64]{
+				DataPoints: []metricdata.DataPoint[int64]{
+					{Value: 0, Attributes: attribute.NewSet(attribute.Int("level", 0))},
+					{Value: 0, Attributes: attribute.NewSet(attribute.Int("level", 1))},
+					{Value: 0, Attributes: attribute.NewSet(attribute.Int("level", 2))},
+					{Value: 0, Attributes: attribute.NewSet(attribute.Int("level", 3))},
+					{Value: 0, Attributes: attribute.NewSet(attribute.Int("level", 4))},
+					{Value: 0, Attributes: attribute.NewSet(attribute.Int("level", 5))},
+					{Value: 0, Attributes: attribute.NewSet(attribute.Int("level", 6))},
+				},
+			},
+		},
+		{
+			Name:        "aggregator.storage.memtable.size",
+			Description: "Current size of the storage memtables",
+			Unit:        "by",
+			Data: metricdata.Gauge[int64]{
+				DataPoints: []metricdata.DataPoint[int64]{
+					{Value: 0},
+				},
+			},
+		},
+		{
+			Name:        "aggregator.storage.wal.fsync.duration",
+			Description: "Cumulative time spent syncing the storage write-ahead log",
+			Unit:        "s",
+			Data: metricdata.Sum[float64]{
+				DataPoints: []metricdata.DataPoint[float64]{
+					{Value: 0},
+				},
+				Temporality: metricdata.CumulativeTemporality,
+				IsMonotonic: true,
+			},
+		},
+		{
+			Name:        "aggregator.storage.wal.fsyncs",
+			Description: "Number of storage write-ahead log syncs",
+			Unit:        "1",
+			Data: metricdata.Sum[int64]{
+				DataPoints: []metricdata.DataPoint[int64]{
+					{Value: 0},
+				},
+				Temporality: metricdata.CumulativeTemporality,
+				IsMonotonic: true,
+			},
+		},
 	}
 
 	rdr := metric.NewManualReader()
@@ -191,3 +257,167 @@ func TestNewInstruments(t *testing.T) {
 		metricdatatest.AssertEqual(t, em, sm.Metrics[i], metricdatatest.IgnoreTimestamp())
 	}
 }
+
+func TestAddPebbleProvider(t *testing.T) {
+	rdr := metric.NewManualReader()
+	meter := metric.NewMeterProvider(metric.WithReader(rdr)).Meter("test")
+	instruments, err := NewMetrics(nil, WithMeter(meter))
+	require.NoError(t, err)
+
+	newProvider := func(flushes int64) pebbleProvider {
+		return func() *pebble.Metrics {
+			var pm pebble.Metrics
+			pm.Flush.Count = flushes
+			return &pm
+		}
+	}
+	collectFlushes := func() int64 {
+		var rm metricdata.ResourceMetrics
+		require.NoError(t, rdr.Collect(context.Background(), &rm))
+		require.Len(t, rm.ScopeMetrics, 1)
+		for _, m := range rm.ScopeMetrics[0].Metrics {
+			if m.Name == "pebble.flushes" {
+				return m.Data.(metricdata.Sum[int64]).DataPoints[0].Value
+			}
+		}
+		t.Fatal("pebble.flushes metric not found")
+		return 0
+	}
+
+	assert.Equal(t, int64(0), collectFlushes())
+	remove := instruments.AddPebbleProvider(newProvider(1))
+	instruments.AddPebbleProvider(newProvider(2))
+	assert.Equal(t, int64(3), collectFlushes())
+	remove()
+	assert.Equal(t, int64(2), collectFlushes())
+}
+
+func TestStorageMetrics(t *testing.T) {
+	rdr := metric.NewManualReader()
+	meter := metric.NewMeterProvider(metric.WithReader(rdr)).Meter("test")
+	instruments, err := NewMetrics(nil, WithMeter(meter))
+	require.NoError(t, err)
+
+	newProvider := func(level int, size int64) pebbleProvider {
+		fsyncLatency := prometheus.NewHistogram(prometheus.HistogramOpts{})
+		fsyncLatency.Observe(float64(250 * time.Millisecond))
+		return func() *pebble.Metrics {
+			var pm pebble.Metrics
+			pm.Compact.Duration = 2 * time.Second
+			pm.Levels[level].Size = size
+			pm.MemTable.Size = 100
+			pm.LogWriter.FsyncLatency = fsyncLatency
+			return &pm
+		}
+	}
+	instruments.AddPebbleProvider(newProvider(0, 10))
+	instruments.AddPebbleProvider(newProvider(6, 20))
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, rdr.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	storage := make(map[string]metricdata.Aggregation)
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		if strings.HasPrefix(m.Name, "aggregator.storage.") {
+			storage[m.Name] = m.Data
+		}
+	}
+	require.Len(t, storage, 5)
+
+	sumValue := func(data metricdata.Aggregation) float64 {
+		return data.(metricdata.Sum[float64]).DataPoints[0].Value
+	}
+	assert.Equal(t, 4.0, sumValue(storage["aggregator.storage.compaction.duration"]))
+	assert.Equal(t, 0.5, sumValue(storage["aggregator.storage.wal.fsync.duration"]))
+	assert.Equal(t, int64(2), storage["aggregator.storage.wal.fsyncs"].(metricdata.Sum[int64]).DataPoints[0].Value)
+	assert.Equal(t, int64(200), storage["aggregator.storage.memtable.size"].(metricdata.Gauge[int64]).DataPoints[0].Value)
+
+	levels := make(map[int64]int64)
+	for _, dp := range storage["aggregator.storage.levels.size"].(metricdata.Gauge[int64]).DataPoints {
+		level, ok := dp.Attributes.Value("level")
+		require.True(t, ok)
+		levels[level.AsInt64()] = dp.Value
+	}
+	assert.Equal(t, map[int64]int64{0: 10, 1: 0, 2: 0, 3: 0, 4: 0, 5: 0, 6: 20}, levels)
+}
+
+func TestAddLimitsProvider(t *testing.T) {
+	rdr := metric.NewManualReader()
+	meter := metric.NewMeterProvider(metric.WithReader(rdr)).Meter("test")
+	instruments, err := NewMetrics(nil, WithMeter(meter))
+	require.NoError(t, err)
+
+	collectLimits := func() map[string]int64 {
+		var rm metricdata.ResourceMetrics
+		require.NoError(t, rdr.Collect(context.Background(), &rm))
+		limits := make(map[string]int64)
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				if m.Name != "aggregator.limits" {
+					continue
+				}
+				for _, dp := range m.Data.(metricdata.Gauge[int64]).DataPoints {
+					name, ok := dp.Attributes.Value("limit")
+					require.True(t, ok)
+					limits[name.AsString()] = dp.Value
+				}
+			}
+		}
+		return limits
+	}
+
+	assert.Empty(t, collectLimits())
+	maxServices := int64(10)
+	remove := instruments.AddLimitsProvider(func() []Limit {
+		return []Limit{{Name: "max_services", Value: maxServices}}
+	})
+	instruments.AddLimitsProvider(func() []Limit {
+		return []Limit{
+			{Name: "max_services", Value: 5},
+			{Name: "max_span_groups", Value: 100},
+		}
+	})
+	assert.Equal(t, map[string]int64{"max_services": 10, "max_span_groups": 100}, collectLimits())
+
+	// Updated limits are reported on the next collection.
+	maxServices = 20
+	assert.Equal(t, map[string]int64{"max_services": 20, "max_span_groups": 100}, collectLimits())
+
+	remove()
+	assert.Equal(t, map[string]int64{"max_services": 5, "max_span_groups": 100}, collectLimits())
+}
+
+func TestAddConfigFingerprint(t *testing.T) {
+	rdr := metric.NewManualReader()
+	meter := metric.NewMeterProvider(metric.WithReader(rdr)).Meter("test")
+	instruments, err := NewMetrics(nil, WithMeter(meter))
+	require.NoError(t, err)
+
+	collectConfigs := func() map[string]int64 {
+		var rm metricdata.ResourceMetrics
+		require.NoError(t, rdr.Collect(context.Background(), &rm))
+		configs := make(map[string]int64)
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				if m.Name != "aggregator.config" {
+					continue
+				}
+				for _, dp := range m.Data.(metricdata.Gauge[int64]).DataPoints {
+					fingerprint, ok := dp.Attributes.Value("fingerprint")
+					require.True(t, ok)
+					configs[fingerprint.AsString()] = dp.Value
+				}
+			}
+		}
+		return configs
+	}
+
+	assert.Empty(t, collectConfigs())
+	remove := instruments.AddConfigFingerprint("a")
+	instruments.AddConfigFingerprint("a")
+	instruments.AddConfigFingerprint("b")
+	assert.Equal(t, map[string]int64{"a": 2, "b": 1}, collectConfigs())
+
+	remove()
+	assert.Equal(t, map[string]int64{"a": 1, "b": 1}, collectConfigs())
+}
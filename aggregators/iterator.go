@@ -0,0 +1,352 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+)
+
+// IteratorOptions configures the range of keys iterated by an Iterator.
+// The keys are ordered by interval, processing time, combined metrics ID
+// and partition ID, in that order.
+type IteratorOptions struct {
+	// LowerBound is the inclusive lower bound of the iterated keys. A nil
+	// lower bound iterates from the first combined metrics key.
+	LowerBound *CombinedMetricsKey
+
+	// UpperBound is the exclusive upper bound of the iterated keys. A nil
+	// upper bound iterates till the last key.
+	UpperBound *CombinedMetricsKey
+}
+
+// Iterator is a read-only iterator over the combined metrics stored by an
+// aggregator. The iterator reads from a consistent snapshot of the stored
+// combined metrics taken when the iterator is created. Keys are decoded
+// while iterating, values are only decoded when requested. The combined
+// metrics of all shards are iterated in key order, see WithShardDataDirs.
+//
+// An Iterator is not safe for concurrent use.
+type Iterator struct {
+	snaps []*pebble.Snapshot
+	iters []*pebble.Iterator
+	// cur is the iterator of the shard positioned at the smallest key.
+	cur   *pebble.Iterator
+	codec *valueCodec
+	key   CombinedMetricsKey
+	err   error
+}
+
+// NewIterator returns a new iterator over the combined metrics stored by
+// the aggregator, including any buffered writes. Close must be called when
+// the iterator is no longer needed and all iterators must be closed before
+// the aggregator is closed.
+func (a *Aggregator) NewIterator(opts IteratorOptions) (*Iterator, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	select {
+	case <-a.closed:
+		return nil, ErrAggregatorClosed
+	default:
+	}
+
+	// Flushing wakes up writers blocked on pending bytes, the iterator
+	// may be used for harvesting without a running harvest loop.
+	if err := a.flushBatches(); err != nil {
+		return nil, fmt.Errorf("failed to commit batch: %w", err)
+	}
+
+	return newIterator(a.shards, a.codec, opts)
+}
+
+func newIterator(shards []*pebble.DB, codec *valueCodec, opts IteratorOptions) (*Iterator, error) {
+	iterOpts := &pebble.IterOptions{
+		LowerBound: combinedMetricsLowerBound,
+		KeyTypes:   pebble.IterKeyTypePointsOnly,
+	}
+	if opts.LowerBound != nil {
+		lb := make([]byte, CombinedMetricsKeyEncodedSize)
+		if err := opts.LowerBound.MarshalBinaryToSizedBuffer(lb); err != nil {
+			return nil, fmt.Errorf("failed to marshal lower bound: %w", err)
+		}
+		iterOpts.LowerBound = lb
+	}
+	if opts.UpperBound != nil {
+		ub := make([]byte, CombinedMetricsKeyEncodedSize)
+		if err := opts.UpperBound.MarshalBinaryToSizedBuffer(ub); err != nil {
+			return nil, fmt.Errorf("failed to marshal upper bound: %w", err)
+		}
+		iterOpts.UpperBound = ub
+	}
+	it := &Iterator{codec: codec}
+	for _, shard := range shards {
+		snap := shard.NewSnapshot()
+		it.snaps = append(it.snaps, snap)
+		it.iters = append(it.iters, snap.NewIter(iterOpts))
+	}
+	it.cur = it.iters[0]
+	return it, nil
+}
+
+// First moves the iterator to the first key and returns true if the
+// iterator is positioned at a valid key.
+func (it *Iterator) First() bool {
+	for _, iter := range it.iters {
+		iter.First()
+	}
+	return it.decodeKey(it.position())
+}
+
+// Next moves the iterator to the next key and returns true if the iterator
+// is positioned at a valid key.
+func (it *Iterator) Next() bool {
+	it.cur.Next()
+	return it.decodeKey(it.position())
+}
+
+// Valid returns true if the iterator is positioned at a valid key.
+func (it *Iterator) Valid() bool {
+	return it.err == nil && it.cur.Valid()
+}
+
+// Key returns the decoded key at the current position of the iterator.
+func (it *Iterator) Key() CombinedMetricsKey {
+	return it.key
+}
+
+// Value decodes the combined metrics at the current position of the
+// iterator into cm. The decoded combined metrics may be pooled by the
+// caller, for example using aggregationpb.CombinedMetricsFromVTPool.
+func (it *Iterator) Value(cm *aggregationpb.CombinedMetrics) error {
+	if !it.Valid() {
+		return fmt.Errorf("iterator is not positioned at a valid key")
+	}
+	if err := it.codec.unmarshal(it.cur.Value(), cm); err != nil {
+		return fmt.Errorf("failed to unmarshal combined metrics: %w", err)
+	}
+	return nil
+}
+
+// Error returns any error encountered while iterating.
+func (it *Iterator) Error() error {
+	if it.err != nil {
+		return it.err
+	}
+	var errs []error
+	for _, iter := range it.iters {
+		if err := iter.Error(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close releases the resources held by the iterator.
+func (it *Iterator) Close() error {
+	var iterErrs, snapErrs []error
+	for i, iter := range it.iters {
+		if err := iter.Close(); err != nil {
+			iterErrs = append(iterErrs, err)
+		}
+		if err := it.snaps[i].Close(); err != nil {
+			snapErrs = append(snapErrs, err)
+		}
+	}
+	if len(iterErrs) > 0 {
+		return fmt.Errorf("failed to close iterator: %w", errors.Join(iterErrs...))
+	}
+	if len(snapErrs) > 0 {
+		return fmt.Errorf("failed to close snapshot: %w", errors.Join(snapErrs...))
+	}
+	return nil
+}
+
+// position positions the iterator at the shard iterator with the
+// smallest key and returns true if the iterator is positioned at a valid
+// key.
+func (it *Iterator) position() bool {
+	for _, iter := range it.iters {
+		if !iter.Valid() {
+			continue
+		}
+		if !it.cur.Valid() || bytes.Compare(iter.Key(), it.cur.Key()) < 0 {
+			it.cur = iter
+		}
+	}
+	return it.cur.Valid()
+}
+
+func (it *Iterator) decodeKey(valid bool) bool {
+	it.key = CombinedMetricsKey{}
+	if !valid {
+		return false
+	}
+	if err := it.key.UnmarshalBinary(it.cur.Key()); err != nil {
+		it.err = fmt.Errorf("failed to unmarshal combined metrics key: %w", err)
+		return false
+	}
+	return true
+}
+
+// HarvestIterator is a pull based alternative to harvesting the mature
+// combined metrics with Run and the configured Processor. The caller
+// drives the harvest by calling Next, which allows pacing the harvest
+// and integrating the aggregator in pull based pipelines.
+//
+// The combined metrics are deleted from the aggregator when the iterator
+// is closed. Combined metrics are only deleted once consumed, i.e. after
+// Next has been called past them, so the combined metrics at the current
+// position of an iterator closed early are harvested again by the next
+// HarvestIterator.
+//
+// A HarvestIterator is not safe for concurrent use.
+type HarvestIterator struct {
+	ctx     context.Context
+	a       *Aggregator
+	it      *Iterator
+	ivlAttr attribute.KeyValue
+	lb, ub  []byte
+	cm      *aggregationpb.CombinedMetrics
+	started bool
+	done    bool
+	err     error
+}
+
+// HarvestIterator returns an iterator over the mature combined metrics of
+// the given aggregation interval, i.e. the combined metrics of processing
+// times which ended before the current time, accounting for the harvest
+// delay and the harvest offset of the interval. The context is used for
+// recording the harvest metrics.
+//
+// HarvestIterator must not be used while Run is running. The processing
+// time of the aggregator is advanced to the current time, as done by Run.
+// Close must be called when the iterator is no longer needed and before
+// the aggregator is closed.
+func (a *Aggregator) HarvestIterator(ctx context.Context, ivl time.Duration) (*HarvestIterator, error) {
+	var found bool
+	for _, aggIvl := range a.cfg.AggregationIntervals {
+		if aggIvl == ivl {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("unknown aggregation interval %s", formatDuration(ivl))
+	}
+
+	now := a.cfg.Clock.Now()
+	a.mu.Lock()
+	if current := now.Truncate(a.cfg.AggregationIntervals[0]); current.After(a.processingTime) {
+		a.processingTime = current
+	}
+	a.mu.Unlock()
+
+	end := now.Add(-a.cfg.HarvestDelay - a.cfg.HarvestOffsets[ivl]).Truncate(ivl)
+	from := CombinedMetricsKey{Interval: ivl, ProcessingTime: time.Unix(0, 0)}
+	to := CombinedMetricsKey{Interval: ivl, ProcessingTime: end}
+	it, err := a.NewIterator(IteratorOptions{LowerBound: &from, UpperBound: &to})
+	if err != nil {
+		return nil, err
+	}
+	lb := make([]byte, CombinedMetricsKeyEncodedSize)
+	ub := make([]byte, CombinedMetricsKeyEncodedSize)
+	from.MarshalBinaryToSizedBuffer(lb)
+	to.MarshalBinaryToSizedBuffer(ub)
+
+	ivlAttr := attribute.String(aggregationIvlKey, formatDuration(ivl))
+	for cmID, eventsTotal := range a.cachedEvents.loadAndDeleteInterval(ivl) {
+		attrs := append(a.cfg.CombinedMetricsIDToKVs(cmID), ivlAttr)
+		a.metrics.EventsTotal.Add(ctx, eventsTotal, metric.WithAttributes(attrs...))
+	}
+	return &HarvestIterator{
+		ctx:     ctx,
+		a:       a,
+		it:      it,
+		ivlAttr: ivlAttr,
+		lb:      lb,
+		ub:      ub,
+		cm:      aggregationpb.CombinedMetricsFromVTPool(),
+	}, nil
+}
+
+// Next moves the iterator to the next mature combined metrics and returns
+// true if there is one. Next consumes the combined metrics at the previous
+// position of the iterator.
+func (h *HarvestIterator) Next() bool {
+	if h.done || h.err != nil {
+		return false
+	}
+	var valid bool
+	if h.started {
+		valid = h.it.Next()
+	} else {
+		valid = h.it.First()
+		h.started = true
+	}
+	if !valid {
+		h.err = h.it.Error()
+		h.done = h.err == nil
+		return false
+	}
+	h.cm.ResetVT()
+	if err := h.it.Value(h.cm); err != nil {
+		h.err = err
+		return false
+	}
+	attrs := append(h.a.cfg.CombinedMetricsIDToKVs(h.it.Key().ID), h.ivlAttr)
+	h.a.metrics.EventsProcessed.Add(h.ctx, h.cm.EventsTotal, metric.WithAttributes(attrs...))
+	return true
+}
+
+// Key returns the key of the combined metrics at the current position of
+// the iterator.
+func (h *HarvestIterator) Key() CombinedMetricsKey {
+	return h.it.Key()
+}
+
+// Value returns the combined metrics at the current position of the
+// iterator. The combined metrics are reused by the iterator and MUST NOT
+// be used after the next call to Next or Close.
+func (h *HarvestIterator) Value() *aggregationpb.CombinedMetrics {
+	return h.cm
+}
+
+// Err returns any error encountered while iterating.
+func (h *HarvestIterator) Err() error {
+	return h.err
+}
+
+// Close deletes the consumed combined metrics from the aggregator and
+// releases the resources held by the iterator.
+func (h *HarvestIterator) Close() error {
+	defer h.cm.ReturnToVTPool()
+
+	var deleteErr error
+	switch {
+	case h.done:
+		deleteErr = h.a.deleteRange(h.lb, h.ub)
+	case h.started && h.it.Valid():
+		// Keep the combined metrics at the current position, they may
+		// not have been processed by the caller. As the shards are
+		// iterated in key order, all the smaller keys were consumed.
+		current := append([]byte(nil), h.it.cur.Key()...)
+		deleteErr = h.a.deleteRange(h.lb, current)
+	}
+	closeErr := h.it.Close()
+	if deleteErr != nil {
+		return fmt.Errorf("failed to delete harvested metrics: %w", deleteErr)
+	}
+	return closeErr
+}
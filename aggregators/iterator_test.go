@@ -0,0 +1,155 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+	"github.com/elastic/apm-data/model/modelpb"
+)
+
+func TestIterator(t *testing.T) {
+	ivls := []time.Duration{time.Minute, time.Hour}
+	agg, err := New(
+		WithDataDir(t.TempDir()),
+		WithProcessor(noOpProcessor()),
+		WithAggregationIntervals(ivls),
+		WithLogger(zap.NewNop()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { agg.Close(context.Background()) })
+
+	ids := [][16]byte{
+		EncodeToCombinedMetricsKeyID(t, "ab01"),
+		EncodeToCombinedMetricsKeyID(t, "ab02"),
+	}
+	for _, id := range ids {
+		require.NoError(t, agg.AggregateBatch(context.Background(), id, &modelpb.Batch{
+			&modelpb.APMEvent{
+				Event: &modelpb.Event{Duration: durationpb.New(time.Millisecond)},
+				Transaction: &modelpb.Transaction{
+					Name:                "T-1000",
+					Type:                "type",
+					RepresentativeCount: 1,
+				},
+				Service: &modelpb.Service{Name: "svc"},
+			},
+		}))
+	}
+
+	collect := func(opts IteratorOptions) []CombinedMetricsKey {
+		it, err := agg.NewIterator(opts)
+		require.NoError(t, err)
+		defer func() { assert.NoError(t, it.Close()) }()
+
+		var keys []CombinedMetricsKey
+		cm := aggregationpb.CombinedMetricsFromVTPool()
+		defer cm.ReturnToVTPool()
+		for valid := it.First(); valid; valid = it.Next() {
+			keys = append(keys, it.Key())
+			cm.ResetVT()
+			require.NoError(t, it.Value(cm))
+			assert.Equal(t, float64(1), cm.EventsTotal)
+		}
+		require.NoError(t, it.Error())
+		return keys
+	}
+
+	var expected []CombinedMetricsKey
+	for _, ivl := range ivls {
+		for _, id := range ids {
+			expected = append(expected, CombinedMetricsKey{
+				Interval:       ivl,
+				ProcessingTime: agg.processingTime.Truncate(ivl),
+				ID:             id,
+			})
+		}
+	}
+	assert.Equal(t, expected, collect(IteratorOptions{}))
+
+	lb := expected[0]
+	ub := lb
+	ub.PartitionID++
+	assert.Equal(t, expected[:1], collect(IteratorOptions{LowerBound: &lb, UpperBound: &ub}))
+
+	require.NoError(t, agg.Close(context.Background()))
+	_, err = agg.NewIterator(IteratorOptions{})
+	assert.ErrorIs(t, err, ErrAggregatorClosed)
+}
+
+func TestHarvestIterator(t *testing.T) {
+	ivls := []time.Duration{time.Minute, time.Hour}
+	agg, err := New(
+		WithDataDir(t.TempDir()),
+		WithProcessor(noOpProcessor()),
+		WithAggregationIntervals(ivls),
+		WithLogger(zap.NewNop()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { agg.Close(context.Background()) })
+
+	// Aggregate into a past processing time to have mature metrics.
+	agg.processingTime = time.Now().Truncate(time.Minute).Add(-time.Hour)
+	ids := [][16]byte{
+		EncodeToCombinedMetricsKeyID(t, "ab01"),
+		EncodeToCombinedMetricsKeyID(t, "ab02"),
+	}
+	for _, id := range ids {
+		require.NoError(t, agg.AggregateBatch(context.Background(), id, &modelpb.Batch{
+			&modelpb.APMEvent{
+				Event: &modelpb.Event{Duration: durationpb.New(time.Millisecond)},
+				Transaction: &modelpb.Transaction{
+					Name:                "T-1000",
+					Type:                "type",
+					RepresentativeCount: 1,
+				},
+				Service: &modelpb.Service{Name: "svc"},
+			},
+		}))
+	}
+
+	harvest := func(ivl time.Duration, limit int) []CombinedMetricsKey {
+		it, err := agg.HarvestIterator(context.Background(), ivl)
+		require.NoError(t, err)
+		defer func() { assert.NoError(t, it.Close()) }()
+
+		var keys []CombinedMetricsKey
+		for len(keys) < limit && it.Next() {
+			keys = append(keys, it.Key())
+			assert.Equal(t, float64(1), it.Value().EventsTotal)
+		}
+		require.NoError(t, it.Err())
+		return keys
+	}
+
+	_, err = agg.HarvestIterator(context.Background(), time.Second)
+	assert.EqualError(t, err, "unknown aggregation interval 1s")
+
+	// Closing early keeps the metrics at the current position.
+	keys := harvest(time.Minute, 1)
+	require.Len(t, keys, 1)
+	// The processing time is advanced to the current time, so new
+	// metrics are not mature.
+	assert.True(t, agg.processingTime.Equal(time.Now().Truncate(time.Minute)))
+	assert.Equal(t, ids[0], keys[0].ID)
+	keys = harvest(time.Minute, 2)
+	require.Len(t, keys, 2)
+	assert.Equal(t, ids[0], keys[0].ID)
+	assert.Equal(t, ids[1], keys[1].ID)
+
+	// Consumed metrics are deleted once the iterator is exhausted.
+	harvest(time.Minute, len(ids)+1)
+	assert.Empty(t, harvest(time.Minute, len(ids)+1))
+	assert.Len(t, harvest(time.Hour, len(ids)+1), len(ids))
+	assert.Empty(t, harvest(time.Hour, len(ids)+1))
+}
@@ -0,0 +1,126 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"time"
+
+	"github.com/elastic/apm-data/model/modelpb"
+)
+
+// lateEventAction is the action taken for an event by the late event
+// policy, see lateEventKey.
+type lateEventAction uint8
+
+const (
+	aggregateLateEvent lateEventAction = iota
+	dropLateEvent
+	routeLateEvent
+)
+
+// lateEventKey returns the key of the combined metrics to aggregate the
+// event into, and the action to take for the event, according to the late
+// event policy. The given key identifies the current period of the
+// aggregation interval. An event is late if its timestamp precedes the
+// start of the current period. With event time bucketing, the key of the
+// period of the event timestamp is returned instead.
+func (a *Aggregator) lateEventKey(
+	cmk CombinedMetricsKey,
+	e *modelpb.APMEvent,
+) (CombinedMetricsKey, lateEventAction) {
+	if a.cfg.EventTimeBucketing {
+		return a.eventTimeKey(cmk, e)
+	}
+	if a.cfg.LateEventPolicy == AcceptLateEvents || e.GetTimestamp() == nil {
+		return cmk, aggregateLateEvent
+	}
+	ts := e.GetTimestamp().AsTime()
+	if !ts.Before(cmk.ProcessingTime) {
+		return cmk, aggregateLateEvent
+	}
+	withinLateness := cmk.ProcessingTime.Sub(ts) <= a.cfg.AllowedLateness
+	switch a.cfg.LateEventPolicy {
+	case DropLateEvents:
+		if !withinLateness {
+			return cmk, dropLateEvent
+		}
+	case ReopenLateEvents:
+		if !withinLateness {
+			return cmk, dropLateEvent
+		}
+		cmk.ProcessingTime = ts.Truncate(cmk.Interval)
+	case RouteLateEvents:
+		if !withinLateness {
+			return cmk, routeLateEvent
+		}
+	}
+	return cmk, aggregateLateEvent
+}
+
+// eventTimeKey returns the key of the combined metrics of the period of
+// the event timestamp, bounded by the current period identified by the
+// given key, and the action to take for the event. Events of periods
+// behind the watermark of the current period are dropped. For IDs with a
+// watermark advanced by the embedder, events timestamped before that
+// watermark, or for all IDs with backfill, are dropped instead, see
+// AdvanceWatermark.
+func (a *Aggregator) eventTimeKey(
+	cmk CombinedMetricsKey,
+	e *modelpb.APMEvent,
+) (CombinedMetricsKey, lateEventAction) {
+	if e.GetTimestamp() == nil {
+		return cmk, aggregateLateEvent
+	}
+	ts := e.GetTimestamp().AsTime()
+	period := ts.Truncate(cmk.Interval)
+	if period.After(cmk.ProcessingTime) {
+		return cmk, aggregateLateEvent
+	}
+	if watermark, ok := a.watermarks[cmk.ID]; ok || a.cfg.Backfill {
+		if ts.Before(watermark) {
+			return cmk, dropLateEvent
+		}
+		cmk.ProcessingTime = period
+		return cmk, aggregateLateEvent
+	}
+	if period.Before(a.watermark(cmk.ProcessingTime, cmk.Interval)) {
+		return cmk, dropLateEvent
+	}
+	cmk.ProcessingTime = period
+	return cmk, aggregateLateEvent
+}
+
+// watermark returns the end of the periods of the aggregation interval
+// which are harvested, with event time bucketing, by the harvest ending at
+// the given time. The periods after the watermark are held back for the
+// events arriving within the allowed lateness.
+func (a *Aggregator) watermark(end time.Time, ivl time.Duration) time.Time {
+	return end.Add(-a.cfg.AllowedLateness).Truncate(ivl)
+}
+
+// harvestBounds returns the inclusive lower bound and the exclusive upper
+// bound of the processing times harvested for the aggregation interval
+// with the harvest ending at the given time.
+//
+// With ReopenLateEvents, the periods within the allowed lateness are
+// harvested again as they may have been reopened by late events since
+// their harvest. With event time bucketing, the periods up to the
+// watermark are harvested, except for the final harvest on Close which
+// harvests all the held back periods.
+func (a *Aggregator) harvestBounds(end time.Time, ivl time.Duration) (time.Time, time.Time) {
+	switch {
+	case a.cfg.EventTimeBucketing:
+		select {
+		case <-a.closed:
+			return a.watermark(end.Add(-ivl), ivl), end
+		default:
+		}
+		upper := a.watermark(end, ivl)
+		return upper.Add(-ivl), upper
+	case a.cfg.LateEventPolicy == ReopenLateEvents:
+		return a.watermark(end.Add(-ivl), ivl), end
+	}
+	return end.Add(-ivl), end
+}
@@ -0,0 +1,218 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+	"github.com/elastic/apm-data/model/modelpb"
+)
+
+func TestLateEventPolicy(t *testing.T) {
+	ivl := time.Minute
+	id := EncodeToCombinedMetricsKeyID(t, "ab01")
+	type harvested struct {
+		processingOffset time.Duration
+		partitionID      uint16
+	}
+	for _, tc := range []struct {
+		name      string
+		policy    LateEventPolicy
+		expected  map[harvested]float64
+		lostEvent bool
+	}{
+		{
+			name:     "accept",
+			policy:   AcceptLateEvents,
+			expected: map[harvested]float64{{0, 0}: 3},
+		},
+		{
+			name:      "drop",
+			policy:    DropLateEvents,
+			expected:  map[harvested]float64{{0, 0}: 2},
+			lostEvent: true,
+		},
+		{
+			name:   "reopen",
+			policy: ReopenLateEvents,
+			expected: map[harvested]float64{
+				{0, 0}:    1,
+				{-ivl, 0}: 1,
+			},
+			lostEvent: true,
+		},
+		{
+			name:   "route",
+			policy: RouteLateEvents,
+			expected: map[harvested]float64{
+				{0, 0}:               2,
+				{0, LatePartitionID}: 1,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			var start time.Time
+			actual := make(map[harvested]float64)
+			var losses []EventLoss
+			agg, err := New(
+				WithDataDir(t.TempDir()),
+				WithLimits(Limits{
+					MaxServices:                           10,
+					MaxServiceInstanceGroupsPerService:    10,
+					MaxTransactionGroups:                  10,
+					MaxTransactionGroupsPerService:        10,
+					MaxServiceTransactionGroups:           10,
+					MaxServiceTransactionGroupsPerService: 10,
+					MaxSpanGroups:                         10,
+					MaxSpanGroupsPerService:               10,
+				}),
+				WithAggregationIntervals([]time.Duration{ivl}),
+				WithLateEventPolicy(tc.policy),
+				WithAllowedLateness(2*time.Minute),
+				WithProcessor(func(
+					_ context.Context,
+					cmk CombinedMetricsKey,
+					cm *aggregationpb.CombinedMetrics,
+					_ time.Duration,
+				) error {
+					actual[harvested{
+						processingOffset: cmk.ProcessingTime.Sub(start),
+						partitionID:      cmk.PartitionID,
+					}] += cm.EventsTotal
+					return nil
+				}),
+				WithEventLossHandler(func(loss EventLoss) {
+					losses = append(losses, loss)
+				}),
+				WithLogger(zap.NewNop()),
+			)
+			require.NoError(t, err)
+			defer agg.Close(ctx)
+
+			start = time.Unix(agg.processingTime.Unix(), 0)
+			batch := modelpb.Batch{
+				lateTestEvent(start.Add(time.Second)),
+				// Late, within the allowed lateness.
+				lateTestEvent(start.Add(-30 * time.Second)),
+				// Late, beyond the allowed lateness.
+				lateTestEvent(start.Add(-10 * time.Minute)),
+			}
+			require.NoError(t, agg.AggregateBatch(ctx, id, &batch))
+
+			agg.mu.Lock()
+			pb := agg.takeBatches()
+			agg.mu.Unlock()
+			require.NoError(t, agg.commitAndHarvest(ctx, pb, start.Add(ivl), []time.Duration{ivl}, nil))
+
+			assert.Equal(t, tc.expected, actual)
+			if tc.lostEvent {
+				assert.Equal(t, []EventLoss{{
+					ID:             id,
+					Interval:       ivl,
+					ProcessingTime: start,
+					TooLate:        1,
+				}}, losses)
+			} else {
+				assert.Empty(t, losses)
+			}
+		})
+	}
+}
+
+func lateTestEvent(ts time.Time) *modelpb.APMEvent {
+	return &modelpb.APMEvent{
+		Timestamp: timestamppb.New(ts),
+		Event: &modelpb.Event{
+			Duration: durationpb.New(time.Millisecond),
+			Outcome:  "success",
+		},
+		Transaction: &modelpb.Transaction{
+			Name:                "txn",
+			Type:                "typ",
+			RepresentativeCount: 1,
+		},
+		Service: &modelpb.Service{Name: "svc"},
+	}
+}
+
+func TestEventTimeBucketing(t *testing.T) {
+	ctx := context.Background()
+	ivl := time.Minute
+	id := EncodeToCombinedMetricsKeyID(t, "ab01")
+	var start time.Time
+	var harvested []time.Duration
+	var losses []EventLoss
+	agg, err := New(
+		WithDataDir(t.TempDir()),
+		WithLimits(Limits{
+			MaxServices:                           10,
+			MaxServiceInstanceGroupsPerService:    10,
+			MaxTransactionGroups:                  10,
+			MaxTransactionGroupsPerService:        10,
+			MaxServiceTransactionGroups:           10,
+			MaxServiceTransactionGroupsPerService: 10,
+			MaxSpanGroups:                         10,
+			MaxSpanGroupsPerService:               10,
+		}),
+		WithAggregationIntervals([]time.Duration{ivl}),
+		WithEventTimeBucketing(true),
+		WithAllowedLateness(2*time.Minute),
+		WithProcessor(func(
+			_ context.Context,
+			cmk CombinedMetricsKey,
+			cm *aggregationpb.CombinedMetrics,
+			_ time.Duration,
+		) error {
+			assert.Equal(t, float64(1), cm.EventsTotal)
+			harvested = append(harvested, cmk.ProcessingTime.Sub(start))
+			return nil
+		}),
+		WithEventLossHandler(func(loss EventLoss) {
+			losses = append(losses, loss)
+		}),
+		WithLogger(zap.NewNop()),
+	)
+	require.NoError(t, err)
+
+	start = time.Unix(agg.processingTime.Unix(), 0)
+	batch := modelpb.Batch{
+		lateTestEvent(start.Add(time.Second)),
+		lateTestEvent(start.Add(-30 * time.Second)),
+		lateTestEvent(start.Add(-90 * time.Second)),
+		// Behind the watermark of the current period.
+		lateTestEvent(start.Add(-10 * time.Minute)),
+	}
+	require.NoError(t, agg.AggregateBatch(ctx, id, &batch))
+
+	agg.mu.Lock()
+	pb := agg.takeBatches()
+	agg.mu.Unlock()
+	require.NoError(t, agg.commitAndHarvest(ctx, pb, start.Add(ivl), []time.Duration{ivl}, nil))
+
+	// Only the period behind the watermark is harvested.
+	assert.Equal(t, []time.Duration{-2 * ivl}, harvested)
+	assert.Equal(t, map[time.Duration]time.Time{ivl: start.Add(-ivl)}, agg.Health().LastHarvest)
+	assert.Equal(t, []EventLoss{{
+		ID:             id,
+		Interval:       ivl,
+		ProcessingTime: start,
+		TooLate:        1,
+	}}, losses)
+
+	// The held back periods are harvested on close.
+	harvested = nil
+	require.NoError(t, agg.Close(ctx))
+	assert.ElementsMatch(t, []time.Duration{-ivl, 0}, harvested)
+}
@@ -0,0 +1,51 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// errorClassKey is the log field classifying the logged errors, allowing
+// the logs to be filtered and alerted on by the cause of the errors.
+const errorClassKey = "error.class"
+
+// errorClass is the class of a logged error.
+type errorClass string
+
+const (
+	// processorErrorClass classifies the errors returned by the processor
+	// or the sinks when processing harvested combined metrics.
+	processorErrorClass errorClass = "processor"
+	// mergeErrorClass classifies the errors merging combined metrics,
+	// usually caused by corrupted or undecodable values.
+	mergeErrorClass errorClass = "merge"
+	// limitOverflowErrorClass classifies the harvested combined metrics
+	// with groups folded into overflow due to the aggregation limits.
+	limitOverflowErrorClass errorClass = "limit_overflow"
+)
+
+func errorClassField(class errorClass) zap.Field {
+	return zap.String(errorClassKey, string(class))
+}
+
+// sampledLogger returns the logger of the config, sampled as configured
+// by WithLogSampling. The sampling is keyed by the message and level of
+// the logs, thus repeated errors are rate limited regardless of their
+// fields.
+func sampledLogger(cfg Config) *zap.Logger {
+	if cfg.LogSamplingInterval <= 0 {
+		return cfg.Logger
+	}
+	return cfg.Logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return zapcore.NewSamplerWithOptions(
+			core,
+			cfg.LogSamplingInterval,
+			cfg.LogSamplingFirst,
+			cfg.LogSamplingThereafter,
+		)
+	}))
+}
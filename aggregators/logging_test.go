@@ -0,0 +1,110 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+	"github.com/elastic/apm-data/model/modelpb"
+)
+
+func TestSampledLogger(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	cfg := Config{Logger: zap.New(core)}
+	assert.Same(t, cfg.Logger, sampledLogger(cfg))
+
+	cfg.LogSamplingInterval = time.Minute
+	cfg.LogSamplingFirst = 2
+	logger := sampledLogger(cfg)
+	for i := 0; i < 5; i++ {
+		logger.Warn("repeated", zap.Int("i", i))
+	}
+	logger.Warn("other")
+	assert.Equal(t, 2, logs.FilterMessage("repeated").Len())
+	assert.Equal(t, 1, logs.FilterMessage("other").Len())
+}
+
+func TestHarvestErrorClasses(t *testing.T) {
+	cmID := EncodeToCombinedMetricsKeyID(t, "ab01")
+	limits := Limits{
+		MaxSpanGroups:                         10,
+		MaxSpanGroupsPerService:               10,
+		MaxTransactionGroups:                  10,
+		MaxTransactionGroupsPerService:        10,
+		MaxServiceTransactionGroups:           10,
+		MaxServiceTransactionGroupsPerService: 10,
+		MaxServices:                           1,
+		MaxServiceInstanceGroupsPerService:    10,
+	}
+
+	// harvest aggregates an event per service and harvests them on close,
+	// returning the logs and the close error.
+	harvest := func(t *testing.T, processor Processor, services ...string) (*observer.ObservedLogs, error) {
+		core, logs := observer.New(zapcore.InfoLevel)
+		agg, err := New(
+			WithDataDir(t.TempDir()),
+			WithLimits(limits),
+			WithProcessor(processor),
+			WithAggregationIntervals([]time.Duration{time.Minute}),
+			WithHarvestDelay(time.Hour), // disable auto harvest
+			WithLogger(zap.New(core)),
+		)
+		require.NoError(t, err)
+		var batch modelpb.Batch
+		for _, svc := range services {
+			event := lateTestEvent(time.Now())
+			event.Service.Name = svc
+			batch = append(batch, event)
+		}
+		require.NoError(t, agg.AggregateBatch(context.Background(), cmID, &batch))
+		return logs, agg.Close(context.Background())
+	}
+	errorClass := func(t *testing.T, entry observer.LoggedEntry) string {
+		class, ok := entry.ContextMap()[errorClassKey]
+		require.True(t, ok)
+		return class.(string)
+	}
+
+	t.Run("processor", func(t *testing.T) {
+		logs, err := harvest(t, func(
+			context.Context, CombinedMetricsKey, *aggregationpb.CombinedMetrics, time.Duration,
+		) error {
+			return errors.New("processor failure")
+		}, "svc")
+		assert.Error(t, err)
+		entries := logs.FilterMessage("failed to harvest combined metrics").All()
+		require.Len(t, entries, 1)
+		assert.Equal(t, zapcore.WarnLevel, entries[0].Level)
+		assert.Equal(t, string(processorErrorClass), errorClass(t, entries[0]))
+	})
+	t.Run("limit_overflow", func(t *testing.T) {
+		logs, err := harvest(t, noOpProcessor(), "svc1", "svc2")
+		require.NoError(t, err)
+		assert.Zero(t, logs.FilterMessage("failed to harvest combined metrics").Len())
+		entries := logs.FilterMessage("harvested combined metrics exceeded aggregation limits").All()
+		require.Len(t, entries, 1)
+		assert.Equal(t, string(limitOverflowErrorClass), errorClass(t, entries[0]))
+		assert.Equal(t, uint64(1), entries[0].ContextMap()["service_instance_groups"])
+	})
+	t.Run("merge", func(t *testing.T) {
+		core, logs := observer.New(zapcore.InfoLevel)
+		merger := newCombinedMetricsMerger(Config{Logger: zap.New(core)}, nil, nil, nil)
+		_, err := merger.Merge(nil, []byte("invalid"))
+		require.Error(t, err)
+		entries := logs.FilterMessage("failed to merge combined metrics").All()
+		require.Len(t, entries, 1)
+		assert.Equal(t, string(mergeErrorClass), errorClass(t, entries[0]))
+	})
+}
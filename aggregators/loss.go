@@ -0,0 +1,252 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+	"github.com/elastic/apm-data/model/modelpb"
+)
+
+// lossMetricsetName is the name of the metricset of the events converted
+// by EventLossToAPMEvent.
+const lossMetricsetName = "aggregation_loss"
+
+// lossReasonKey is the attribute key of the aggregator.events.lost metric
+// identifying the reason of the loss.
+const lossReasonKey = "reason"
+
+const (
+	lossReasonOverflow         = "overflow"
+	lossReasonInvalid          = "invalid"
+	lossReasonTooOld           = "too_old"
+	lossReasonTooLate          = "too_late"
+	lossReasonRateLimited      = "rate_limited"
+	lossReasonProcessorFailure = "processor_failure"
+)
+
+// EventLoss accounts for the events, or representative counts, of a
+// combined metrics ID and aggregation interval which were discarded, or
+// folded into overflow buckets, during an aggregation period.
+type EventLoss struct {
+	ID       [16]byte
+	Interval time.Duration
+	// ProcessingTime is the processing time of the harvested period.
+	ProcessingTime time.Time
+
+	// Overflow is the representative count of the transactions, spans
+	// and errors aggregated into the overflow buckets due to the limits.
+	Overflow float64
+	// Invalid is the number of events rejected as invalid, e.g. events
+	// failing conversion or combined metrics exceeding the maximum size.
+	Invalid float64
+	// TooOld is the number of events rejected because their processing
+	// time is older than the replay horizon.
+	TooOld float64
+	// TooLate is the number of events dropped by the late event policy
+	// because their timestamp is later than the allowed lateness.
+	TooLate float64
+	// RateLimited is the number of events rejected because the ingest
+	// rate limit of the combined metrics ID was exceeded.
+	RateLimited float64
+	// ProcessorFailure is the number of events of the combined metrics
+	// which failed to be processed at harvest.
+	ProcessorFailure float64
+}
+
+// Total returns the total number of lost events.
+func (l EventLoss) Total() float64 {
+	return l.Overflow + l.Invalid + l.TooOld + l.TooLate + l.RateLimited + l.ProcessorFailure
+}
+
+// EventLossToAPMEvent converts the event loss to an APM event of the
+// aggregation_loss metricset, with a sample per loss reason, e.g.
+// `aggregation.loss.overflow`, and the total loss.
+func EventLossToAPMEvent(loss EventLoss) *modelpb.APMEvent {
+	event := modelpb.APMEventFromVTPool()
+	event.Timestamp = timestamppb.New(loss.ProcessingTime)
+	event.Metricset = modelpb.MetricsetFromVTPool()
+	event.Metricset.Name = lossMetricsetName
+	event.Metricset.Interval = formatDuration(loss.Interval)
+	for _, s := range []struct {
+		name  string
+		value float64
+	}{
+		{"aggregation.loss.overflow", loss.Overflow},
+		{"aggregation.loss.invalid", loss.Invalid},
+		{"aggregation.loss.too_old", loss.TooOld},
+		{"aggregation.loss.too_late", loss.TooLate},
+		{"aggregation.loss.rate_limited", loss.RateLimited},
+		{"aggregation.loss.processor_failure", loss.ProcessorFailure},
+		{"aggregation.loss.total", loss.Total()},
+	} {
+		sample := modelpb.MetricsetSampleFromVTPool()
+		sample.Name = s.name
+		sample.Value = s.value
+		event.Metricset.Samples = append(event.Metricset.Samples, sample)
+	}
+	return event
+}
+
+// overflowEventCount returns the representative count of the transactions,
+// spans and errors aggregated into the overflow buckets of the combined
+// metrics.
+func overflowEventCount(cm *aggregationpb.CombinedMetrics) float64 {
+	count := overflowGroupsEventCount(cm.OverflowServices)
+	for _, ksm := range cm.ServiceMetrics {
+		if ksm.Metrics != nil {
+			count += overflowGroupsEventCount(ksm.Metrics.OverflowGroups)
+		}
+	}
+	return count
+}
+
+func overflowGroupsEventCount(o *aggregationpb.Overflow) float64 {
+	if o == nil {
+		return 0
+	}
+	return transactionCount(o.OverflowTransactions) +
+		spanCount(o.OverflowSpans) +
+		errorCount(o.OverflowErrors)
+}
+
+type lossKey struct {
+	id  [16]byte
+	ivl time.Duration
+}
+
+// lossTracker accumulates the event losses per combined metrics ID and
+// aggregation interval until they are reported at harvest.
+type lossTracker struct {
+	mu     sync.Mutex
+	losses map[lossKey]EventLoss
+}
+
+func (t *lossTracker) add(id [16]byte, ivl time.Duration, fn func(*EventLoss)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.losses == nil {
+		t.losses = make(map[lossKey]EventLoss)
+	}
+	k := lossKey{id: id, ivl: ivl}
+	loss := t.losses[k]
+	fn(&loss)
+	t.losses[k] = loss
+}
+
+// take removes and returns the event losses of the aggregation interval.
+func (t *lossTracker) take(ivl time.Duration) []EventLoss {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var losses []EventLoss
+	for k, loss := range t.losses {
+		if k.ivl != ivl {
+			continue
+		}
+		loss.ID, loss.Interval = k.id, k.ivl
+		losses = append(losses, loss)
+		delete(t.losses, k)
+	}
+	return losses
+}
+
+// recordIngestLoss records the events of the combined metrics ID rejected
+// at ingestion. The events are lost for all the aggregation intervals.
+func (a *Aggregator) recordIngestLoss(
+	ctx context.Context,
+	id [16]byte,
+	cmIDAttrs []attribute.KeyValue,
+	reason string,
+	n float64,
+) {
+	a.recordLoss(ctx, id, cmIDAttrs, reason, n, a.cfg.AggregationIntervals)
+}
+
+// recordLoss records the events of the combined metrics ID lost for the
+// given aggregation intervals.
+func (a *Aggregator) recordLoss(
+	ctx context.Context,
+	id [16]byte,
+	baseAttrs []attribute.KeyValue,
+	reason string,
+	n float64,
+	ivls []time.Duration,
+) {
+	if n <= 0 {
+		return
+	}
+	attrs := make([]attribute.KeyValue, 0, len(baseAttrs)+1)
+	attrs = append(attrs, baseAttrs...)
+	attrs = append(attrs, attribute.String(lossReasonKey, reason))
+	a.metrics.EventsLost.Add(ctx, n, metric.WithAttributeSet(attribute.NewSet(attrs...)))
+	if a.cfg.EventLossHandler == nil {
+		return
+	}
+	for _, ivl := range ivls {
+		a.losses.add(id, ivl, func(l *EventLoss) {
+			switch reason {
+			case lossReasonInvalid:
+				l.Invalid += n
+			case lossReasonTooOld:
+				l.TooOld += n
+			case lossReasonTooLate:
+				l.TooLate += n
+			case lossReasonRateLimited:
+				l.RateLimited += n
+			}
+		})
+	}
+}
+
+// recordHarvestLoss records the events of the harvested combined metrics
+// which were folded into overflow or failed to be processed.
+func (a *Aggregator) recordHarvestLoss(
+	ctx context.Context,
+	cmk CombinedMetricsKey,
+	attrs []attribute.KeyValue,
+	overflow, processorFailure float64,
+) {
+	for _, l := range []struct {
+		reason string
+		n      float64
+	}{
+		{lossReasonOverflow, overflow},
+		{lossReasonProcessorFailure, processorFailure},
+	} {
+		if l.n <= 0 {
+			continue
+		}
+		reasonAttrs := make([]attribute.KeyValue, 0, len(attrs)+1)
+		reasonAttrs = append(reasonAttrs, attrs...)
+		reasonAttrs = append(reasonAttrs, attribute.String(lossReasonKey, l.reason))
+		a.metrics.EventsLost.Add(ctx, l.n, metric.WithAttributeSet(attribute.NewSet(reasonAttrs...)))
+	}
+	if a.cfg.EventLossHandler == nil || overflow+processorFailure <= 0 {
+		return
+	}
+	a.losses.add(cmk.ID, cmk.Interval, func(l *EventLoss) {
+		l.Overflow += overflow
+		l.ProcessorFailure += processorFailure
+	})
+}
+
+// reportLosses passes the event losses of the harvested period of the
+// aggregation interval to the configured event loss handler, if any.
+func (a *Aggregator) reportLosses(ivl time.Duration, processingTime time.Time) {
+	if a.cfg.EventLossHandler == nil {
+		return
+	}
+	for _, loss := range a.losses.take(ivl) {
+		loss.ProcessingTime = processingTime
+		a.cfg.EventLossHandler(loss)
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+)
+
+func TestEventLoss(t *testing.T) {
+	ctx := context.Background()
+	ivl := time.Minute
+	rdr := metric.NewManualReader()
+	okID := EncodeToCombinedMetricsKeyID(t, "ab01")
+	failedID := EncodeToCombinedMetricsKeyID(t, "ab02")
+	var losses []EventLoss
+	agg, err := New(
+		WithDataDir(t.TempDir()),
+		WithLimits(Limits{
+			MaxServices:                           10,
+			MaxServiceInstanceGroupsPerService:    10,
+			MaxTransactionGroups:                  10,
+			MaxTransactionGroupsPerService:        1,
+			MaxServiceTransactionGroups:           10,
+			MaxServiceTransactionGroupsPerService: 10,
+			MaxSpanGroups:                         10,
+			MaxSpanGroupsPerService:               10,
+		}),
+		WithAggregationIntervals([]time.Duration{ivl}),
+		WithReplayHorizon(time.Hour),
+		WithProcessor(func(
+			_ context.Context,
+			cmk CombinedMetricsKey,
+			_ *aggregationpb.CombinedMetrics,
+			_ time.Duration,
+		) error {
+			if cmk.ID == failedID {
+				return errors.New("processor failure")
+			}
+			return nil
+		}),
+		WithEventLossHandler(func(loss EventLoss) {
+			losses = append(losses, loss)
+		}),
+		WithMeter(metric.NewMeterProvider(metric.WithReader(rdr)).Meter("test")),
+		WithLogger(zap.NewNop()),
+	)
+	require.NoError(t, err)
+	defer agg.Close(ctx)
+
+	start := time.Unix(agg.processingTime.Unix(), 0)
+	svcKey := serviceAggregationKey{Timestamp: start, ServiceName: "svc"}
+	// 2 of the 3 transaction groups overflow.
+	tcm := NewTestCombinedMetrics(WithEventsTotal(3))
+	tsim := tcm.AddServiceMetrics(svcKey).AddServiceInstanceMetrics(serviceInstanceAggregationKey{})
+	for i := 0; i < 3; i++ {
+		tsim.AddTransaction(transactionAggregationKey{
+			TransactionName: fmt.Sprintf("txn%d", i),
+			TransactionType: "typ",
+		}, WithTransactionCount(1))
+	}
+	aggregate := func(cmk CombinedMetricsKey, cm *aggregationpb.CombinedMetrics) error {
+		defer cm.ReturnToVTPool()
+		return agg.AggregateCombinedMetrics(ctx, cmk, cm)
+	}
+	require.NoError(t, aggregate(
+		CombinedMetricsKey{Interval: ivl, ProcessingTime: start, ID: okID},
+		tcm.GetProto(),
+	))
+	var staleErr *StaleProcessingTimeError
+	assert.ErrorAs(t, aggregate(
+		CombinedMetricsKey{Interval: ivl, ProcessingTime: start.Add(-2 * time.Hour), ID: okID},
+		NewTestCombinedMetrics(WithEventsTotal(2)).
+			AddServiceMetrics(svcKey).
+			AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+			GetProto(),
+	), &staleErr)
+	require.NoError(t, aggregate(
+		CombinedMetricsKey{Interval: ivl, ProcessingTime: start, ID: failedID},
+		NewTestCombinedMetrics(WithEventsTotal(4)).
+			AddServiceMetrics(svcKey).
+			AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+			GetProto(),
+	))
+
+	agg.mu.Lock()
+	batch := agg.takeBatches()
+	agg.mu.Unlock()
+	assert.Error(t, agg.commitAndHarvest(ctx, batch, start.Add(ivl), []time.Duration{ivl}, nil))
+
+	assert.ElementsMatch(t, []EventLoss{
+		{ID: okID, Interval: ivl, ProcessingTime: start, Overflow: 2, TooOld: 2},
+		{ID: failedID, Interval: ivl, ProcessingTime: start, ProcessorFailure: 4},
+	}, losses)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, rdr.Collect(ctx, &rm))
+	lost := make(map[string]float64)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "aggregator.events.lost" {
+				continue
+			}
+			for _, dp := range m.Data.(metricdata.Sum[float64]).DataPoints {
+				reason, ok := dp.Attributes.Value(attribute.Key(lossReasonKey))
+				require.True(t, ok)
+				lost[reason.AsString()] += dp.Value
+			}
+		}
+	}
+	assert.Equal(t, map[string]float64{
+		lossReasonOverflow:         2,
+		lossReasonTooOld:           2,
+		lossReasonProcessorFailure: 4,
+	}, lost)
+
+	// Losses are only reported once.
+	losses = nil
+	require.NoError(t, agg.commitAndHarvest(ctx, nil, start.Add(2*ivl), []time.Duration{ivl}, nil))
+	assert.Empty(t, losses)
+}
+
+func TestEventLossToAPMEvent(t *testing.T) {
+	ts := time.Unix(1000, 0).UTC()
+	event := EventLossToAPMEvent(EventLoss{
+		Interval:         time.Minute,
+		ProcessingTime:   ts,
+		Overflow:         1,
+		Invalid:          2,
+		TooOld:           3,
+		TooLate:          5,
+		RateLimited:      6,
+		ProcessorFailure: 4,
+	})
+	defer event.ReturnToVTPool()
+	assert.Equal(t, ts, event.Timestamp.AsTime())
+	assert.Equal(t, "aggregation_loss", event.Metricset.Name)
+	assert.Equal(t, "1m", event.Metricset.Interval)
+	samples := make(map[string]float64)
+	for _, s := range event.Metricset.Samples {
+		samples[s.Name] = s.Value
+	}
+	assert.Equal(t, map[string]float64{
+		"aggregation.loss.overflow":          1,
+		"aggregation.loss.invalid":           2,
+		"aggregation.loss.too_old":           3,
+		"aggregation.loss.too_late":          5,
+		"aggregation.loss.rate_limited":      6,
+		"aggregation.loss.processor_failure": 4,
+		"aggregation.loss.total":             21,
+	}, samples)
+}
@@ -6,14 +6,21 @@ package aggregators
 
 import (
 	"io"
+	"math"
 	"sort"
+	"time"
 
 	"github.com/axiomhq/hyperloglog"
 	"github.com/cespare/xxhash/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
 	"golang.org/x/exp/slices"
+	"golang.org/x/time/rate"
 
 	"github.com/elastic/apm-aggregation/aggregationpb"
 	"github.com/elastic/apm-aggregation/aggregators/internal/constraint"
+	"github.com/elastic/apm-aggregation/aggregators/internal/hdrhistogram"
 	"github.com/elastic/apm-aggregation/aggregators/internal/protohash"
 )
 
@@ -21,12 +28,63 @@ type combinedMetricsMerger struct {
 	limits      Limits
 	constraints constraints
 	metrics     combinedMetrics
+
+	// topK enables retaining the highest throughput transaction, service
+	// transaction and span groups when the limits are reached instead of
+	// the groups that were seen first.
+	topK bool
+
+	// maxExemplars is the maximum number of exemplars retained per
+	// transaction and span group.
+	maxExemplars int
+
+	// codec decodes the merged values and encodes the result.
+	codec *valueCodec
+	// sparseHistograms encodes the histograms of the result sparsely, see
+	// WithSparseHistograms.
+	sparseHistograms bool
+
+	// throttle, if set, limits the merge time per second of all the
+	// mergers of the aggregator, see WithMergeBudget. The tokens are
+	// microseconds of merge time.
+	throttle *rate.Limiter
+	// clock measures the merge time and waits for the throttle. It must
+	// be set if throttle or observe are set.
+	clock Clock
+	// sliceStart is the start of the merge time not yet accounted for
+	// by the throttle.
+	sliceStart time.Time
+	// throttled is the total time the merger waited for the throttle.
+	throttled time.Duration
+	// mergeTime is the total time spent merging, including the time
+	// waited for the throttle.
+	mergeTime time.Duration
+	// observe, if set, is called by Finish with the merge time, excluding
+	// the time waited for the throttle, and the throttled time.
+	observe func(mergeTime, throttled time.Duration)
+
+	// span, if set, traces the merge and is ended by Finish or by the
+	// first failed merge operation.
+	span trace.Span
+	// operands and bytes are the number and total size of the merged
+	// values, reported on the span.
+	operands int
+	bytes    int
+
+	// logger, if set, logs the values failing to be merged.
+	logger *zap.Logger
+
+	// overflowed, if set, is called once per limit hit while merging with
+	// the name of the service whose groups overflowed.
+	overflowed func(limit overflowLimit, keySample string)
+	// notifiedOverflows holds the limits already passed to overflowed.
+	notifiedOverflows [numOverflowLimits]bool
 }
 
 func (m *combinedMetricsMerger) MergeNewer(value []byte) error {
 	from := aggregationpb.CombinedMetricsFromVTPool()
 	defer from.ReturnToVTPool()
-	if err := from.UnmarshalVT(value); err != nil {
+	if err := m.unmarshal(value, from); err != nil {
 		return err
 	}
 	m.merge(from)
@@ -36,7 +94,7 @@ func (m *combinedMetricsMerger) MergeNewer(value []byte) error {
 func (m *combinedMetricsMerger) MergeOlder(value []byte) error {
 	from := aggregationpb.CombinedMetricsFromVTPool()
 	defer from.ReturnToVTPool()
-	if err := from.UnmarshalVT(value); err != nil {
+	if err := m.unmarshal(value, from); err != nil {
 		return err
 	}
 	m.merge(from)
@@ -46,11 +104,104 @@ func (m *combinedMetricsMerger) MergeOlder(value []byte) error {
 func (m *combinedMetricsMerger) Finish(includesBase bool) ([]byte, io.Closer, error) {
 	pb := m.metrics.ToProto()
 	defer pb.ReturnToVTPool()
+	pb.Version = denseCombinedMetricsVersion
+	if m.sparseHistograms {
+		pb.Version = combinedMetricsVersion
+		encodeSparseHistograms(pb)
+	}
 	data, err := pb.MarshalVT()
-	return data, nil, err
+	if err != nil {
+		m.endSpan(err)
+		return nil, nil, err
+	}
+	encoded, err := m.codec.encode(data)
+	if err != nil {
+		m.endSpan(err)
+		return nil, nil, err
+	}
+	if m.observe != nil {
+		m.observe(m.mergeTime-m.throttled, m.throttled)
+	}
+	if m.span != nil {
+		m.span.SetAttributes(attribute.Int("merged_bytes", len(encoded)))
+	}
+	m.endSpan(nil)
+	return encoded, nil, nil
+}
+
+// unmarshal decodes a value to be merged, accounting for it on the span.
+func (m *combinedMetricsMerger) unmarshal(value []byte, to *aggregationpb.CombinedMetrics) error {
+	m.operands++
+	m.bytes += len(value)
+	if err := m.codec.unmarshal(value, to); err != nil {
+		if m.logger != nil {
+			m.logger.Warn(
+				"failed to merge combined metrics",
+				errorClassField(mergeErrorClass),
+				zap.Int("bytes", len(value)),
+				zap.Error(err),
+			)
+		}
+		m.endSpan(err)
+		return err
+	}
+	return nil
+}
+
+// endSpan ends the merge span, if any, recording the error if not nil.
+func (m *combinedMetricsMerger) endSpan(err error) {
+	if m.span == nil {
+		return
+	}
+	if err != nil {
+		m.span.RecordError(err)
+	}
+	m.span.SetAttributes(
+		attribute.Int("operands", m.operands),
+		attribute.Int("bytes", m.bytes),
+		attribute.Int64("throttled_ns", m.throttled.Nanoseconds()),
+	)
+	m.span.End()
+	m.span = nil
+}
+
+// maybeThrottle accounts the merge time since the last call to the
+// throttle and waits if the merge budget of the aggregator is exhausted,
+// so that merges during compactions of large histograms cannot take more
+// than the budgeted share of the processor from the foreground writes.
+func (m *combinedMetricsMerger) maybeThrottle() {
+	if m.throttle == nil {
+		return
+	}
+	now := m.clock.Now()
+	if m.sliceStart.IsZero() {
+		m.sliceStart = now
+		return
+	}
+	// A reservation cannot exceed the burst of the throttle, the merge
+	// time is reserved in chunks so that long slices are charged in full.
+	// The delay of the last reservation includes the previous ones.
+	var delay time.Duration
+	burst := m.throttle.Burst()
+	for n := int(now.Sub(m.sliceStart).Microseconds()); n > 0; n -= burst {
+		chunk := n
+		if chunk > burst {
+			chunk = burst
+		}
+		delay = m.throttle.ReserveN(now, chunk).DelayFrom(now)
+	}
+	if delay > 0 {
+		<-m.clock.NewTimer(delay).C()
+		m.throttled += delay
+	}
+	m.sliceStart = m.clock.Now()
 }
 
 func (m *combinedMetricsMerger) merge(from *aggregationpb.CombinedMetrics) {
+	if m.observe != nil {
+		start := m.clock.Now()
+		defer func() { m.mergeTime += m.clock.Now().Sub(start) }()
+	}
 	// We merge the below fields irrespective of the services present
 	// because it is possible for services to be empty if the event
 	// does not fit the criteria for aggregations.
@@ -84,13 +235,32 @@ func (m *combinedMetricsMerger) merge(from *aggregationpb.CombinedMetrics) {
 	//         create a new service in _to_ bucket and merge.
 	//    2.b. Else, merge the _from_ bucket to the overflow service bucket
 	//         of the _to_ combined metrics.
+	// If the limits may be reached, the services, and their instances, are
+	// merged in the order of their key hashes so that the groups selected
+	// for overflow do not depend on the order in which the merged value was
+	// encoded, which follows the iteration order of maps. Merges within the
+	// limits do not depend on the order and are not sorted.
+	ordered := m.mayReachGlobalLimits(from)
+	if ordered {
+		sortServiceMetrics(from.ServiceMetrics)
+	}
 	for i := range from.ServiceMetrics {
+		m.maybeThrottle()
 		fromSvc := from.ServiceMetrics[i]
-		serviceKeyHash := protohash.HashServiceAggregationKey(xxhash.Digest{}, fromSvc.Key)
 		var sk serviceAggregationKey
 		sk.FromProto(fromSvc.Key)
+		// Limit the number of Kubernetes workloads tracked per service by
+		// dropping the Kubernetes dimensions once the limit is reached.
+		if _, ok := m.metrics.Services[sk]; !ok && m.limits.MaxKubernetesWorkloadsPerService > 0 &&
+			(sk.KubernetesNamespace != "" || sk.KubernetesDeploymentName != "") &&
+			kubernetesWorkloadsPerService(&m.metrics, sk) >= m.limits.MaxKubernetesWorkloadsPerService {
+			sk.KubernetesNamespace, sk.KubernetesDeploymentName = "", ""
+			fromSvc.Key.KubernetesNamespace, fromSvc.Key.KubernetesDeploymentName = "", ""
+		}
+		serviceKeyHash := protohash.HashServiceAggregationKey(xxhash.Digest{}, fromSvc.Key)
 		toSvc, svcOverflow := getServiceMetrics(&m.metrics, sk, m.limits.MaxServices)
 		if svcOverflow {
+			m.notifyOverflow(serviceInstanceGroupsOverflow, sk.ServiceName)
 			mergeOverflow(&m.metrics.OverflowServices, fromSvc.Metrics.OverflowGroups)
 			for j := range fromSvc.Metrics.ServiceInstanceMetrics {
 				ksim := fromSvc.Metrics.ServiceInstanceMetrics[j]
@@ -101,35 +271,174 @@ func (m *combinedMetricsMerger) merge(from *aggregationpb.CombinedMetrics) {
 			continue
 		}
 		if fromSvc.Metrics != nil {
+			var overflowedBefore [numOverflowLimits]bool
+			if m.overflowed != nil {
+				overflowedBefore = overflowedLimits(&toSvc.OverflowGroups)
+				overflowedBefore[serviceInstanceGroupsOverflow] = m.metrics.OverflowServiceInstancesEstimator != nil
+			}
 			mergeOverflow(&toSvc.OverflowGroups, fromSvc.Metrics.OverflowGroups)
 			mergeServiceInstanceGroups(
 				&toSvc,
 				fromSvc.Metrics.ServiceInstanceMetrics,
 				m.constraints,
 				m.limits,
+				m.topK,
+				m.maxExemplars,
+				ordered,
 				serviceKeyHash,
 				&m.metrics.OverflowServiceInstancesEstimator,
 			)
+			if m.overflowed != nil {
+				overflowedAfter := overflowedLimits(&toSvc.OverflowGroups)
+				overflowedAfter[serviceInstanceGroupsOverflow] = m.metrics.OverflowServiceInstancesEstimator != nil
+				for limit, overflowed := range overflowedAfter {
+					if overflowed && !overflowedBefore[limit] {
+						m.notifyOverflow(overflowLimit(limit), sk.ServiceName)
+					}
+				}
+			}
 		}
+		addKubernetesWorkload(&m.metrics, sk)
 		m.metrics.Services[sk] = toSvc
 	}
 }
 
+// mayReachGlobalLimits reports whether merging the combined metrics may
+// reach one of the limits shared by the services, i.e. the max services,
+// the Kubernetes workloads per service and the global group limits, by
+// comparing the number of services and groups of the combined metrics to
+// the remaining capacity.
+func (m *combinedMetricsMerger) mayReachGlobalLimits(from *aggregationpb.CombinedMetrics) bool {
+	if len(m.metrics.Services)+len(from.ServiceMetrics) > m.limits.MaxServices {
+		return true
+	}
+	var instances, txns, svcTxns, spans, errs, edges int
+	for _, ksm := range from.ServiceMetrics {
+		if m.limits.MaxKubernetesWorkloadsPerService > 0 &&
+			(ksm.Key.KubernetesNamespace != "" || ksm.Key.KubernetesDeploymentName != "") {
+			return true
+		}
+		if ksm.Metrics == nil {
+			continue
+		}
+		instances += len(ksm.Metrics.ServiceInstanceMetrics)
+		for _, ksim := range ksm.Metrics.ServiceInstanceMetrics {
+			txns += len(ksim.Metrics.TransactionMetrics)
+			svcTxns += len(ksim.Metrics.ServiceTransactionMetrics)
+			spans += len(ksim.Metrics.SpanMetrics)
+			errs += len(ksim.Metrics.ErrorMetrics)
+			edges += len(ksim.Metrics.ServiceGraphEdgeMetrics)
+		}
+	}
+	for _, c := range [...]struct {
+		constraint *constraint.Constraint
+		n          int
+	}{
+		{m.constraints.totalServiceInstanceGroups, instances},
+		{m.constraints.totalTransactionGroups, txns},
+		{m.constraints.totalServiceTransactionGroups, svcTxns},
+		{m.constraints.totalSpanGroups, spans},
+		{m.constraints.totalErrorGroups, errs},
+		{m.constraints.totalServiceGraphEdges, edges},
+	} {
+		if c.constraint.Value()+c.n > c.constraint.Limit() {
+			return true
+		}
+	}
+	return false
+}
+
+// sortServiceMetrics sorts the keyed service metrics by the hash of their
+// keys.
+func sortServiceMetrics(sms []*aggregationpb.KeyedServiceMetrics) {
+	if len(sms) < 2 {
+		return
+	}
+	hashes := make([]uint64, len(sms))
+	for i, sm := range sms {
+		h := protohash.HashServiceAggregationKey(xxhash.Digest{}, sm.Key)
+		hashes[i] = h.Sum64()
+	}
+	sort.Sort(byKeyHash{hashes: hashes, swap: func(i, j int) { sms[i], sms[j] = sms[j], sms[i] }})
+}
+
+// sortServiceInstanceMetrics sorts the keyed service instance metrics by
+// the hash of their keys.
+func sortServiceInstanceMetrics(sims []*aggregationpb.KeyedServiceInstanceMetrics) {
+	if len(sims) < 2 {
+		return
+	}
+	hashes := make([]uint64, len(sims))
+	for i, sim := range sims {
+		h := protohash.HashServiceInstanceAggregationKey(xxhash.Digest{}, sim.Key)
+		hashes[i] = h.Sum64()
+	}
+	sort.Sort(byKeyHash{hashes: hashes, swap: func(i, j int) { sims[i], sims[j] = sims[j], sims[i] }})
+}
+
+// byKeyHash sorts keyed metrics by the precomputed hashes of their keys.
+type byKeyHash struct {
+	hashes []uint64
+	swap   func(i, j int)
+}
+
+func (s byKeyHash) Len() int           { return len(s.hashes) }
+func (s byKeyHash) Less(i, j int) bool { return s.hashes[i] < s.hashes[j] }
+func (s byKeyHash) Swap(i, j int) {
+	s.hashes[i], s.hashes[j] = s.hashes[j], s.hashes[i]
+	s.swap(i, j)
+}
+
+// notifyOverflow passes the limit hit by the service to overflowed, if
+// set, unless the limit was already passed.
+func (m *combinedMetricsMerger) notifyOverflow(limit overflowLimit, service string) {
+	if m.overflowed == nil || m.notifiedOverflows[limit] {
+		return
+	}
+	m.notifiedOverflows[limit] = true
+	m.overflowed(limit, service)
+}
+
 func mergeServiceInstanceGroups(
 	to *serviceMetrics,
 	from []*aggregationpb.KeyedServiceInstanceMetrics,
 	globalConstraints constraints,
 	limits Limits,
+	topK bool,
+	maxExemplars int,
+	ordered bool,
 	hash xxhash.Digest,
 	overflowServiceInstancesEstimator **hyperloglog.Sketch,
 ) {
+	// The instances are merged in the order of their key hashes if the
+	// global limits or the limits on the instances of the service may be
+	// reached, see combinedMetricsMerger.merge.
+	if n := len(to.ServiceInstanceGroups) + len(from); ordered || len(from) > 1 &&
+		(n > limits.MaxServiceInstanceGroupsPerService ||
+			limits.MaxGlobalLabelsPerService > 0 && n > limits.MaxGlobalLabelsPerService) {
+		sortServiceInstanceMetrics(from)
+	}
 	for i := range from {
 		fromSvcIns := from[i]
 		var sik serviceInstanceAggregationKey
 		sik.FromProto(fromSvcIns.Key)
+		// Limit the number of global label sets tracked per service by
+		// dropping the global labels once the limit is reached.
+		if _, ok := to.ServiceInstanceGroups[sik]; !ok &&
+			limits.MaxGlobalLabelsPerService > 0 && sik.GlobalLabelsStr != "" &&
+			globalLabelsPerService(to) >= limits.MaxGlobalLabelsPerService {
+			insertHash(&to.OverflowGroups.GlobalLabelsEstimator, xxhash.Sum64String(sik.GlobalLabelsStr))
+			sik.GlobalLabelsStr = ""
+			fromSvcIns.Key.GlobalLabelsStr = nil
+			fromSvcIns.Metrics.GlobalLabelsStr = nil
+		}
 		sikHash := protohash.HashServiceInstanceAggregationKey(hash, fromSvcIns.Key)
 
-		toSvcIns, overflowed := getServiceInstanceMetrics(to, sik, limits.MaxServiceInstanceGroupsPerService)
+		toSvcIns, overflowed := getServiceInstanceMetrics(
+			to, sik,
+			limits.MaxServiceInstanceGroupsPerService,
+			globalConstraints.totalServiceInstanceGroups,
+		)
 		if overflowed {
 			mergeToOverflowFromSIM(
 				&to.OverflowGroups,
@@ -142,6 +451,12 @@ func mergeServiceInstanceGroups(
 			)
 			continue
 		}
+		if to.globalLabelSets != nil && sik.GlobalLabelsStr != "" {
+			to.globalLabelSets[sik.GlobalLabelsStr] = struct{}{}
+		}
+		if toSvcIns.GlobalLabelsStr == "" && len(fromSvcIns.Metrics.GlobalLabelsStr) > 0 {
+			toSvcIns.GlobalLabelsStr = string(fromSvcIns.Metrics.GlobalLabelsStr)
+		}
 		mergeTransactionGroups(
 			toSvcIns.TransactionGroups,
 			fromSvcIns.Metrics.TransactionMetrics,
@@ -150,6 +465,15 @@ func mergeServiceInstanceGroups(
 				limits.MaxTransactionGroupsPerService,
 			),
 			globalConstraints.totalTransactionGroups,
+			transactionDimensionLimits{
+				faasIDs:         limits.MaxFaasIDsPerService,
+				serviceVersions: limits.MaxServiceVersionsPerService,
+				hostOSVersions:  limits.MaxHostOSVersionsPerService,
+			},
+			&toSvcIns.transactionDimensionValues,
+			topK,
+			&toSvcIns.lowestTransactions,
+			maxExemplars,
 			hash,
 			&to.OverflowGroups.OverflowTransaction,
 		)
@@ -161,6 +485,8 @@ func mergeServiceInstanceGroups(
 				limits.MaxServiceTransactionGroupsPerService,
 			),
 			globalConstraints.totalServiceTransactionGroups,
+			topK,
+			&toSvcIns.lowestServiceTransactions,
 			hash,
 			&to.OverflowGroups.OverflowServiceTransaction,
 		)
@@ -172,19 +498,64 @@ func mergeServiceInstanceGroups(
 				limits.MaxSpanGroupsPerService,
 			),
 			globalConstraints.totalSpanGroups,
+			limits.MaxSpanNamePerDestination,
+			&toSvcIns.spanDestinationNames,
+			topK,
+			&toSvcIns.lowestSpans,
+			maxExemplars,
 			hash,
 			&to.OverflowGroups.OverflowSpan,
 		)
+		mergeErrorGroups(
+			toSvcIns.ErrorGroups,
+			fromSvcIns.Metrics.ErrorMetrics,
+			constraint.New(
+				len(toSvcIns.ErrorGroups),
+				limits.MaxErrorGroupsPerService,
+			),
+			globalConstraints.totalErrorGroups,
+			topK,
+			&toSvcIns.lowestErrors,
+			hash,
+			&to.OverflowGroups.OverflowError,
+		)
+		mergeServiceGraphEdgeGroups(
+			toSvcIns.ServiceGraphEdgeGroups,
+			fromSvcIns.Metrics.ServiceGraphEdgeMetrics,
+			constraint.New(
+				len(toSvcIns.ServiceGraphEdgeGroups),
+				limits.MaxServiceGraphEdgesPerService,
+			),
+			globalConstraints.totalServiceGraphEdges,
+			topK,
+			&toSvcIns.lowestServiceGraphEdges,
+			hash,
+			&to.OverflowGroups.OverflowServiceGraphEdge,
+		)
 		to.ServiceInstanceGroups[sik] = toSvcIns
 	}
 }
 
+// transactionDimensionLimits holds the limits on the number of unique values
+// of the high cardinality transaction dimensions tracked per service.
+type transactionDimensionLimits struct {
+	faasIDs         int
+	serviceVersions int
+	hostOSVersions  int
+}
+
 // mergeTransactionGroups merges transaction aggregation groups for two combined metrics
-// considering max transaction groups and max transaction groups per service limits.
+// considering max transaction groups, max transaction groups per service and the
+// transaction dimension limits.
 func mergeTransactionGroups(
 	to map[transactionAggregationKey]*aggregationpb.KeyedTransactionMetrics,
 	from []*aggregationpb.KeyedTransactionMetrics,
 	perSvcConstraint, globalConstraint *constraint.Constraint,
+	dimLimits transactionDimensionLimits,
+	dimValues *transactionDimensionValues,
+	topK bool,
+	lowest *lowestTransactionGroups,
+	maxExemplars int,
 	hash xxhash.Digest,
 	overflowTo *overflowTransaction,
 ) {
@@ -193,8 +564,45 @@ func mergeTransactionGroups(
 		var tk transactionAggregationKey
 		tk.FromProto(fromTxn.Key)
 		toTxn, ok := to[tk]
+		// Limit the number of unique values of the high cardinality
+		// dimensions tracked per service by dropping the dimension once
+		// its limit is reached.
+		for dim, limit := range [...]struct {
+			value int
+			pb    *string
+		}{
+			{dimLimits.faasIDs, &fromTxn.Key.FaasId},
+			{dimLimits.serviceVersions, &fromTxn.Key.ServiceVersion},
+			{dimLimits.hostOSVersions, &fromTxn.Key.HostOsVersion},
+		} {
+			field := transactionDimensions[dim](&tk)
+			if ok || limit.value <= 0 || *field == "" {
+				continue
+			}
+			if n, tracked := dimValues.count(dim, to, *field); !tracked && n >= limit.value {
+				*field = ""
+				*limit.pb = ""
+				toTxn, ok = to[tk]
+			}
+		}
 		if !ok {
 			overflowed := perSvcConstraint.Maxed() || globalConstraint.Maxed()
+			if overflowed && topK {
+				// Replace the lowest throughput group, if it has lower
+				// throughput than the new group, keeping the group count.
+				evictTK, evicted := lowestTransactionGroup(to, lowest, transactionCount(fromTxn.Metrics))
+				if evicted != nil {
+					delete(to, evictTK)
+					lowest.remove(evictTK)
+					dimValues.update(&evictTK, -1)
+					evictedKeyHash := protohash.HashTransactionAggregationKey(hash, evicted.Key)
+					overflowTo.Merge(evicted.Metrics, evictedKeyHash.Sum64())
+					to[tk] = cloneKeyedTransactionMetrics(fromTxn, maxExemplars)
+					lowest.set(tk, to[tk])
+					dimValues.update(&tk, 1)
+					continue
+				}
+			}
 			if overflowed {
 				fromTxnKeyHash := protohash.HashTransactionAggregationKey(hash, fromTxn.Key)
 				overflowTo.Merge(fromTxn.Metrics, fromTxnKeyHash.Sum64())
@@ -203,10 +611,13 @@ func mergeTransactionGroups(
 			perSvcConstraint.Add(1)
 			globalConstraint.Add(1)
 
-			to[tk] = fromTxn.CloneVT()
+			to[tk] = cloneKeyedTransactionMetrics(fromTxn, maxExemplars)
+			lowest.set(tk, to[tk])
+			dimValues.update(&tk, 1)
 			continue
 		}
-		mergeKeyedTransactionMetrics(toTxn, fromTxn)
+		mergeKeyedTransactionMetrics(toTxn, fromTxn, maxExemplars)
+		lowest.set(tk, toTxn)
 	}
 }
 
@@ -217,6 +628,8 @@ func mergeServiceTransactionGroups(
 	to map[serviceTransactionAggregationKey]*aggregationpb.KeyedServiceTransactionMetrics,
 	from []*aggregationpb.KeyedServiceTransactionMetrics,
 	perSvcConstraint, globalConstraint *constraint.Constraint,
+	topK bool,
+	lowest *lowestServiceTransactionGroups,
 	hash xxhash.Digest,
 	overflowTo *overflowServiceTransaction,
 ) {
@@ -227,6 +640,18 @@ func mergeServiceTransactionGroups(
 		toSvcTxn, ok := to[stk]
 		if !ok {
 			overflowed := perSvcConstraint.Maxed() || globalConstraint.Maxed()
+			if overflowed && topK {
+				evictSTK, evicted := lowestServiceTransactionGroup(to, lowest, serviceTransactionCount(fromSvcTxn.Metrics))
+				if evicted != nil {
+					delete(to, evictSTK)
+					lowest.remove(evictSTK)
+					evictedKeyHash := protohash.HashServiceTransactionAggregationKey(hash, evicted.Key)
+					overflowTo.Merge(evicted.Metrics, evictedKeyHash.Sum64())
+					to[stk] = fromSvcTxn.CloneVT()
+					lowest.set(stk, to[stk])
+					continue
+				}
+			}
 			if overflowed {
 				fromSvcTxnKeyHash := protohash.HashServiceTransactionAggregationKey(hash, fromSvcTxn.Key)
 				overflowTo.Merge(fromSvcTxn.Metrics, fromSvcTxnKeyHash.Sum64())
@@ -236,18 +661,26 @@ func mergeServiceTransactionGroups(
 			globalConstraint.Add(1)
 
 			to[stk] = fromSvcTxn.CloneVT()
+			lowest.set(stk, to[stk])
 			continue
 		}
 		mergeKeyedServiceTransactionMetrics(toSvcTxn, fromSvcTxn)
+		lowest.set(stk, toSvcTxn)
 	}
 }
 
 // mergeSpanGroups merges span aggregation groups for two combined metrics considering
-// max span groups and max span groups per service limits.
+// max span groups, max span groups per service and max span name per destination
+// limits.
 func mergeSpanGroups(
 	to map[spanAggregationKey]*aggregationpb.KeyedSpanMetrics,
 	from []*aggregationpb.KeyedSpanMetrics,
 	perSvcConstraint, globalConstraint *constraint.Constraint,
+	maxSpanNamePerDestination int,
+	destinationNames *spanDestinationNames,
+	topK bool,
+	lowest *lowestSpanGroups,
+	maxExemplars int,
 	hash xxhash.Digest,
 	overflowTo *overflowSpan,
 ) {
@@ -265,8 +698,30 @@ func mergeSpanGroups(
 				fromSpan.Key.SpanName = ""
 				toSpan, ok = to[spk]
 			}
+			// Limit the number of span names tracked per destination by
+			// dropping span.name once the limit for the destination is reached.
+			if !ok && maxSpanNamePerDestination > 0 && spk.SpanName != "" &&
+				destinationNames.count(to, spk) >= maxSpanNamePerDestination {
+				spk.SpanName = ""
+				fromSpan.Key.SpanName = ""
+				toSpan, ok = to[spk]
+			}
 			if !ok {
 				overflowed := perSvcConstraint.Maxed() || globalConstraint.Maxed()
+				if overflowed && topK {
+					evictSPK, evicted := lowestSpanGroup(to, lowest, spanCount(fromSpan.Metrics))
+					if evicted != nil {
+						delete(to, evictSPK)
+						lowest.remove(evictSPK)
+						destinationNames.update(evictSPK, -1)
+						evictedKeyHash := protohash.HashSpanAggregationKey(hash, evicted.Key)
+						overflowTo.Merge(evicted.Metrics, evictedKeyHash.Sum64())
+						to[spk] = cloneKeyedSpanMetrics(fromSpan, maxExemplars)
+						lowest.set(spk, to[spk])
+						destinationNames.update(spk, 1)
+						continue
+					}
+				}
 				if overflowed {
 					fromSpanKeyHash := protohash.HashSpanAggregationKey(hash, fromSpan.Key)
 					overflowTo.Merge(fromSpan.Metrics, fromSpanKeyHash.Sum64())
@@ -275,12 +730,415 @@ func mergeSpanGroups(
 				perSvcConstraint.Add(1)
 				globalConstraint.Add(1)
 
-				to[spk] = fromSpan.CloneVT()
+				to[spk] = cloneKeyedSpanMetrics(fromSpan, maxExemplars)
+				lowest.set(spk, to[spk])
+				destinationNames.update(spk, 1)
 				continue
 			}
 		}
-		mergeKeyedSpanMetrics(toSpan, fromSpan)
+		mergeKeyedSpanMetrics(toSpan, fromSpan, maxExemplars)
+		lowest.set(spk, toSpan)
+	}
+}
+
+// mergeErrorGroups merges error aggregation groups for two combined metrics
+// considering max error groups and max error groups per service limits.
+func mergeErrorGroups(
+	to map[errorAggregationKey]*aggregationpb.KeyedErrorMetrics,
+	from []*aggregationpb.KeyedErrorMetrics,
+	perSvcConstraint, globalConstraint *constraint.Constraint,
+	topK bool,
+	lowest *lowestErrorGroups,
+	hash xxhash.Digest,
+	overflowTo *overflowError,
+) {
+	for i := range from {
+		fromErr := from[i]
+		var ek errorAggregationKey
+		ek.FromProto(fromErr.Key)
+		toErr, ok := to[ek]
+		if !ok {
+			overflowed := perSvcConstraint.Maxed() || globalConstraint.Maxed()
+			if overflowed && topK {
+				evictEK, evicted := lowestErrorGroup(to, lowest, errorCount(fromErr.Metrics))
+				if evicted != nil {
+					delete(to, evictEK)
+					lowest.remove(evictEK)
+					evictedKeyHash := protohash.HashErrorAggregationKey(hash, evicted.Key)
+					overflowTo.Merge(evicted.Metrics, evictedKeyHash.Sum64())
+					to[ek] = fromErr.CloneVT()
+					lowest.set(ek, to[ek])
+					continue
+				}
+			}
+			if overflowed {
+				fromErrKeyHash := protohash.HashErrorAggregationKey(hash, fromErr.Key)
+				overflowTo.Merge(fromErr.Metrics, fromErrKeyHash.Sum64())
+				continue
+			}
+			perSvcConstraint.Add(1)
+			globalConstraint.Add(1)
+
+			to[ek] = fromErr.CloneVT()
+			lowest.set(ek, to[ek])
+			continue
+		}
+		mergeKeyedErrorMetrics(toErr, fromErr)
+		lowest.set(ek, toErr)
+	}
+}
+
+// mergeServiceGraphEdgeGroups merges service graph edge aggregation groups
+// for two combined metrics considering max service graph edges and max
+// service graph edges per service limits.
+func mergeServiceGraphEdgeGroups(
+	to map[serviceGraphEdgeAggregationKey]*aggregationpb.KeyedServiceGraphEdgeMetrics,
+	from []*aggregationpb.KeyedServiceGraphEdgeMetrics,
+	perSvcConstraint, globalConstraint *constraint.Constraint,
+	topK bool,
+	lowest *lowestServiceGraphEdges,
+	hash xxhash.Digest,
+	overflowTo *overflowServiceGraphEdge,
+) {
+	for i := range from {
+		fromEdge := from[i]
+		var ek serviceGraphEdgeAggregationKey
+		ek.FromProto(fromEdge.Key)
+		toEdge, ok := to[ek]
+		if !ok {
+			overflowed := perSvcConstraint.Maxed() || globalConstraint.Maxed()
+			if overflowed && topK {
+				evictEK, evicted := lowestServiceGraphEdge(to, lowest, serviceGraphEdgeCount(fromEdge.Metrics))
+				if evicted != nil {
+					delete(to, evictEK)
+					lowest.remove(evictEK)
+					evictedKeyHash := protohash.HashServiceGraphEdgeAggregationKey(hash, evicted.Key)
+					overflowTo.Merge(evicted.Metrics, evictedKeyHash.Sum64())
+					to[ek] = fromEdge.CloneVT()
+					lowest.set(ek, to[ek])
+					continue
+				}
+			}
+			if overflowed {
+				fromEdgeKeyHash := protohash.HashServiceGraphEdgeAggregationKey(hash, fromEdge.Key)
+				overflowTo.Merge(fromEdge.Metrics, fromEdgeKeyHash.Sum64())
+				continue
+			}
+			perSvcConstraint.Add(1)
+			globalConstraint.Add(1)
+
+			to[ek] = fromEdge.CloneVT()
+			lowest.set(ek, to[ek])
+			continue
+		}
+		mergeKeyedServiceGraphEdgeMetrics(toEdge, fromEdge)
+		lowest.set(ek, toEdge)
+	}
+}
+
+// lowestTransactionGroup returns the transaction group with the lowest
+// throughput if it is lower than the given count. A nil group is returned
+// if no such group exists.
+func lowestTransactionGroup(
+	groups map[transactionAggregationKey]*aggregationpb.KeyedTransactionMetrics,
+	lowest *lowestTransactionGroups,
+	count float64,
+) (transactionAggregationKey, *aggregationpb.KeyedTransactionMetrics) {
+	k, ok := lowest.lowest(groups, keyedTransactionCount, count)
+	if !ok {
+		return k, nil
+	}
+	return k, groups[k]
+}
+
+// keyedTransactionCount returns the throughput of the keyed metrics.
+func keyedTransactionCount(m *aggregationpb.KeyedTransactionMetrics) float64 {
+	return transactionCount(m.Metrics)
+}
+
+// lowestServiceTransactionGroup returns the service transaction group with
+// the lowest throughput if it is lower than the given count. A nil group is
+// returned if no such group exists.
+func lowestServiceTransactionGroup(
+	groups map[serviceTransactionAggregationKey]*aggregationpb.KeyedServiceTransactionMetrics,
+	lowest *lowestServiceTransactionGroups,
+	count float64,
+) (serviceTransactionAggregationKey, *aggregationpb.KeyedServiceTransactionMetrics) {
+	k, ok := lowest.lowest(groups, keyedServiceTransactionCount, count)
+	if !ok {
+		return k, nil
+	}
+	return k, groups[k]
+}
+
+// keyedServiceTransactionCount returns the throughput of the keyed metrics.
+func keyedServiceTransactionCount(m *aggregationpb.KeyedServiceTransactionMetrics) float64 {
+	return serviceTransactionCount(m.Metrics)
+}
+
+// kubernetesWorkloadsPerService returns the number of services with
+// Kubernetes dimensions for the service of the given key, i.e. services
+// matching the key on all fields except the Kubernetes dimensions.
+func kubernetesWorkloadsPerService(cm *combinedMetrics, key serviceAggregationKey) int {
+	if cm.kubernetesWorkloads == nil {
+		cm.kubernetesWorkloads = make(map[serviceAggregationKey]int)
+		for k := range cm.Services {
+			if k.KubernetesNamespace != "" || k.KubernetesDeploymentName != "" {
+				k.KubernetesNamespace, k.KubernetesDeploymentName = "", ""
+				cm.kubernetesWorkloads[k]++
+			}
+		}
+	}
+	key.KubernetesNamespace, key.KubernetesDeploymentName = "", ""
+	return cm.kubernetesWorkloads[key]
+}
+
+// addKubernetesWorkload counts the service of the given key, if it has
+// Kubernetes dimensions and is not yet part of the combined metrics, as
+// a Kubernetes workload once the workloads are tracked.
+func addKubernetesWorkload(cm *combinedMetrics, key serviceAggregationKey) {
+	if cm.kubernetesWorkloads == nil ||
+		key.KubernetesNamespace == "" && key.KubernetesDeploymentName == "" {
+		return
+	}
+	if _, ok := cm.Services[key]; ok {
+		return
+	}
+	key.KubernetesNamespace, key.KubernetesDeploymentName = "", ""
+	cm.kubernetesWorkloads[key]++
+}
+
+// globalLabelsPerService returns the number of unique global label sets
+// of the service instance groups of the service.
+func globalLabelsPerService(sm *serviceMetrics) int {
+	if sm.globalLabelSets == nil {
+		sm.globalLabelSets = make(map[string]struct{})
+		for k := range sm.ServiceInstanceGroups {
+			if k.GlobalLabelsStr != "" {
+				sm.globalLabelSets[k.GlobalLabelsStr] = struct{}{}
+			}
+		}
+	}
+	return len(sm.globalLabelSets)
+}
+
+// transactionDimensions holds the fields of the high cardinality
+// transaction dimensions limited per service, in the order of the
+// fields of transactionDimensionLimits.
+var transactionDimensions = [...]func(*transactionAggregationKey) *string{
+	func(k *transactionAggregationKey) *string { return &k.FAASID },
+	func(k *transactionAggregationKey) *string { return &k.ServiceVersion },
+	func(k *transactionAggregationKey) *string { return &k.HostOSVersion },
+}
+
+// transactionDimensionValues counts the transaction groups per non-empty
+// value of each of the transactionDimensions. The counts of a dimension
+// are built from the groups on first use and updated as groups are added
+// and evicted.
+type transactionDimensionValues [len(transactionDimensions)]map[string]int
+
+// count returns the number of unique non-empty values of the dimension
+// of the transaction groups and whether the given value is one of them.
+func (v *transactionDimensionValues) count(
+	dim int,
+	groups map[transactionAggregationKey]*aggregationpb.KeyedTransactionMetrics,
+	value string,
+) (int, bool) {
+	values := v[dim]
+	if values == nil {
+		values = make(map[string]int)
+		for k := range groups {
+			if val := *transactionDimensions[dim](&k); val != "" {
+				values[val]++
+			}
+		}
+		v[dim] = values
+	}
+	_, tracked := values[value]
+	return len(values), tracked
+}
+
+// update adds delta to the counts of the values of the group key for the
+// dimensions whose counts are built.
+func (v *transactionDimensionValues) update(key *transactionAggregationKey, delta int) {
+	for dim, values := range v {
+		if values == nil {
+			continue
+		}
+		value := *transactionDimensions[dim](key)
+		if value == "" {
+			continue
+		}
+		if values[value] += delta; values[value] <= 0 {
+			delete(values, value)
+		}
+	}
+}
+
+// spanDestinationNames counts the span groups with a span name per
+// destination, i.e. per span key without the span name. The counts are
+// built from the groups on first use and updated as groups are added and
+// evicted.
+type spanDestinationNames map[spanAggregationKey]int
+
+// count returns the number of span groups with a span name for the
+// destination of the given key.
+func (n *spanDestinationNames) count(
+	groups map[spanAggregationKey]*aggregationpb.KeyedSpanMetrics,
+	key spanAggregationKey,
+) int {
+	if *n == nil {
+		*n = make(spanDestinationNames)
+		for k := range groups {
+			n.update(k, 1)
+		}
+	}
+	key.SpanName = ""
+	return (*n)[key]
+}
+
+// update adds delta to the count of the destination of the group key if
+// the counts are built and the key has a span name.
+func (n *spanDestinationNames) update(key spanAggregationKey, delta int) {
+	if *n == nil || key.SpanName == "" {
+		return
+	}
+	key.SpanName = ""
+	if (*n)[key] += delta; (*n)[key] <= 0 {
+		delete(*n, key)
+	}
+}
+
+// lowestSpanGroup returns the span group with the lowest throughput if it
+// is lower than the given count. A nil group is returned if no such group
+// exists.
+func lowestSpanGroup(
+	groups map[spanAggregationKey]*aggregationpb.KeyedSpanMetrics,
+	lowest *lowestSpanGroups,
+	count float64,
+) (spanAggregationKey, *aggregationpb.KeyedSpanMetrics) {
+	k, ok := lowest.lowest(groups, keyedSpanCount, count)
+	if !ok {
+		return k, nil
+	}
+	return k, groups[k]
+}
+
+// keyedSpanCount returns the throughput of the keyed metrics.
+func keyedSpanCount(m *aggregationpb.KeyedSpanMetrics) float64 {
+	return spanCount(m.Metrics)
+}
+
+// lowestErrorGroup returns the error group with the lowest count if it is
+// lower than the given count. A nil group is returned if no such group
+// exists.
+func lowestErrorGroup(
+	groups map[errorAggregationKey]*aggregationpb.KeyedErrorMetrics,
+	lowest *lowestErrorGroups,
+	count float64,
+) (errorAggregationKey, *aggregationpb.KeyedErrorMetrics) {
+	k, ok := lowest.lowest(groups, keyedErrorCount, count)
+	if !ok {
+		return k, nil
+	}
+	return k, groups[k]
+}
+
+// keyedErrorCount returns the count of the keyed metrics.
+func keyedErrorCount(m *aggregationpb.KeyedErrorMetrics) float64 {
+	return errorCount(m.Metrics)
+}
+
+// lowestServiceGraphEdge returns the service graph edge with the lowest
+// request count if it is lower than the given count. A nil edge is
+// returned if no such edge exists.
+func lowestServiceGraphEdge(
+	groups map[serviceGraphEdgeAggregationKey]*aggregationpb.KeyedServiceGraphEdgeMetrics,
+	lowest *lowestServiceGraphEdges,
+	count float64,
+) (serviceGraphEdgeAggregationKey, *aggregationpb.KeyedServiceGraphEdgeMetrics) {
+	k, ok := lowest.lowest(groups, keyedServiceGraphEdgeCount, count)
+	if !ok {
+		return k, nil
+	}
+	return k, groups[k]
+}
+
+// keyedServiceGraphEdgeCount returns the request count of the keyed
+// metrics.
+func keyedServiceGraphEdgeCount(m *aggregationpb.KeyedServiceGraphEdgeMetrics) float64 {
+	return serviceGraphEdgeCount(m.Metrics)
+}
+
+func transactionCount(tm *aggregationpb.TransactionMetrics) float64 {
+	if tm == nil {
+		return 0
+	}
+	return histogramCount(tm.Histogram) + ddSketchCount(tm.DdSketch) + tDigestCount(tm.TDigest)
+}
+
+func serviceTransactionCount(stm *aggregationpb.ServiceTransactionMetrics) float64 {
+	if stm == nil {
+		return 0
+	}
+	return histogramCount(stm.Histogram) + ddSketchCount(stm.DdSketch) + tDigestCount(stm.TDigest)
+}
+
+func spanCount(sm *aggregationpb.SpanMetrics) float64 {
+	if sm == nil {
+		return 0
+	}
+	return sm.Count
+}
+
+func errorCount(em *aggregationpb.ErrorMetrics) float64 {
+	if em == nil {
+		return 0
+	}
+	return em.Count
+}
+
+func serviceGraphEdgeCount(em *aggregationpb.ServiceGraphEdgeMetrics) float64 {
+	if em == nil {
+		return 0
+	}
+	return em.Count
+}
+
+// histogramCount returns the total count recorded by the histogram.
+func histogramCount(h *aggregationpb.HDRHistogram) float64 {
+	if h == nil {
+		return 0
+	}
+	var total int64
+	for _, c := range h.Counts {
+		total += c
+	}
+	return hdrhistogram.UnscaledCount(total)
+}
+
+// ddSketchCount returns the total count recorded by the sketch.
+func ddSketchCount(s *aggregationpb.DDSketch) float64 {
+	if s == nil {
+		return 0
+	}
+	total := s.ZeroCount
+	for _, c := range s.Counts {
+		total += c
+	}
+	return total
+}
+
+// tDigestCount returns the total weight recorded by the t-digest.
+func tDigestCount(t *aggregationpb.TDigest) float64 {
+	if t == nil {
+		return 0
+	}
+	var total float64
+	for _, w := range t.Weights {
+		total += w
 	}
+	return total
 }
 
 func mergeToOverflowFromSIM(
@@ -303,6 +1161,14 @@ func mergeToOverflowFromSIM(
 		ksmKeyHash := protohash.HashSpanAggregationKey(hash, ksm.Key)
 		to.OverflowSpan.Merge(ksm.Metrics, ksmKeyHash.Sum64())
 	}
+	for _, kem := range from.Metrics.ErrorMetrics {
+		kemKeyHash := protohash.HashErrorAggregationKey(hash, kem.Key)
+		to.OverflowError.Merge(kem.Metrics, kemKeyHash.Sum64())
+	}
+	for _, kgm := range from.Metrics.ServiceGraphEdgeMetrics {
+		kgmKeyHash := protohash.HashServiceGraphEdgeAggregationKey(hash, kgm.Key)
+		to.OverflowServiceGraphEdge.Merge(kgm.Metrics, kgmKeyHash.Sum64())
+	}
 }
 
 func mergeOverflow(
@@ -317,10 +1183,16 @@ func mergeOverflow(
 	to.OverflowTransaction.MergeOverflow(&from.OverflowTransaction)
 	to.OverflowServiceTransaction.MergeOverflow(&from.OverflowServiceTransaction)
 	to.OverflowSpan.MergeOverflow(&from.OverflowSpan)
+	to.OverflowError.MergeOverflow(&from.OverflowError)
+	to.OverflowServiceGraphEdge.MergeOverflow(&from.OverflowServiceGraphEdge)
+	if from.GlobalLabelsEstimator != nil {
+		mergeEstimator(&to.GlobalLabelsEstimator, from.GlobalLabelsEstimator)
+	}
 }
 
 func mergeKeyedTransactionMetrics(
 	to, from *aggregationpb.KeyedTransactionMetrics,
+	maxExemplars int,
 ) {
 	if from.Metrics == nil {
 		return
@@ -328,18 +1200,41 @@ func mergeKeyedTransactionMetrics(
 	if to.Metrics == nil {
 		to.Metrics = aggregationpb.TransactionMetricsFromVTPool()
 	}
-	mergeTransactionMetrics(to.Metrics, from.Metrics)
+	mergeTransactionMetrics(to.Metrics, from.Metrics, maxExemplars)
+}
+
+// cloneKeyedTransactionMetrics clones the transaction group, retaining at
+// most maxExemplars exemplars.
+func cloneKeyedTransactionMetrics(
+	from *aggregationpb.KeyedTransactionMetrics,
+	maxExemplars int,
+) *aggregationpb.KeyedTransactionMetrics {
+	cloned := from.CloneVT()
+	if cloned.Metrics != nil {
+		cloned.Metrics.Exemplars = mergeExemplars(cloned.Metrics.Exemplars, nil, maxExemplars)
+	}
+	return cloned
 }
 
 func mergeTransactionMetrics(
 	to, from *aggregationpb.TransactionMetrics,
+	maxExemplars int,
 ) {
+	to.Sum = mergeDurationSum(
+		to.Sum, from.Sum,
+		func() bool { return transactionCount(to) == 0 },
+		func() bool { return transactionCount(from) == 0 },
+	)
 	if to.Histogram == nil && from.Histogram != nil {
 		to.Histogram = aggregationpb.HDRHistogramFromVTPool()
 	}
 	if to.Histogram != nil && from.Histogram != nil {
 		mergeHistogram(to.Histogram, from.Histogram)
 	}
+	to.DdSketch = mergeDDSketch(to.DdSketch, from.DdSketch)
+	to.TDigest = mergeTDigest(to.TDigest, from.TDigest)
+	to.Exemplars = mergeExemplars(to.Exemplars, from.Exemplars, maxExemplars)
+	to.Min, to.Max = mergeDurationExtremes(to.Min, to.Max, from.Min, from.Max)
 }
 
 func mergeKeyedServiceTransactionMetrics(
@@ -357,29 +1252,194 @@ func mergeKeyedServiceTransactionMetrics(
 func mergeServiceTransactionMetrics(
 	to, from *aggregationpb.ServiceTransactionMetrics,
 ) {
+	to.Sum = mergeDurationSum(
+		to.Sum, from.Sum,
+		func() bool { return serviceTransactionCount(to) == 0 },
+		func() bool { return serviceTransactionCount(from) == 0 },
+	)
 	if to.Histogram == nil && from.Histogram != nil {
 		to.Histogram = aggregationpb.HDRHistogramFromVTPool()
 	}
 	if to.Histogram != nil && from.Histogram != nil {
 		mergeHistogram(to.Histogram, from.Histogram)
 	}
+	to.DdSketch = mergeDDSketch(to.DdSketch, from.DdSketch)
+	to.TDigest = mergeTDigest(to.TDigest, from.TDigest)
 	to.FailureCount += from.FailureCount
 	to.SuccessCount += from.SuccessCount
+	to.Min, to.Max = mergeDurationExtremes(to.Min, to.Max, from.Min, from.Max)
+}
+
+// mergeDurationSum returns the recorded duration sum of two merged
+// groups. A zero sum of a group with durations means that the sum was not
+// recorded for all its durations, in which case the merged sum is not
+// recorded either, so that recorded sums are never mixed with metrics
+// aggregated without recording the sum. The emptiness of a group is only
+// checked if its sum is zero, as it requires counting its durations.
+func mergeDurationSum(toSum, fromSum float64, toEmpty, fromEmpty func() bool) float64 {
+	switch {
+	case toSum > 0 && fromSum > 0:
+		return toSum + fromSum
+	case toSum > 0 && fromEmpty():
+		return toSum
+	case fromSum > 0 && toEmpty():
+		return fromSum
+	}
+	return 0
+}
+
+// mergeDurationExtremes returns the minimum and maximum of two recorded
+// duration ranges. Ranges with both the minimum and the maximum zero were
+// not recorded, e.g. metrics aggregated before the extremes were tracked,
+// and are ignored.
+func mergeDurationExtremes(toMin, toMax, fromMin, fromMax float64) (float64, float64) {
+	if fromMin == 0 && fromMax == 0 {
+		return toMin, toMax
+	}
+	if toMin == 0 && toMax == 0 {
+		return fromMin, fromMax
+	}
+	return math.Min(toMin, fromMin), math.Max(toMax, fromMax)
 }
 
-func mergeKeyedSpanMetrics(to, from *aggregationpb.KeyedSpanMetrics) {
+func mergeKeyedSpanMetrics(to, from *aggregationpb.KeyedSpanMetrics, maxExemplars int) {
 	if from.Metrics == nil {
 		return
 	}
 	if to.Metrics == nil {
 		to.Metrics = aggregationpb.SpanMetricsFromVTPool()
 	}
-	mergeSpanMetrics(to.Metrics, from.Metrics)
+	mergeSpanMetrics(to.Metrics, from.Metrics, maxExemplars)
 }
 
-func mergeSpanMetrics(to, from *aggregationpb.SpanMetrics) {
+// cloneKeyedSpanMetrics clones the span group, retaining at most
+// maxExemplars exemplars.
+func cloneKeyedSpanMetrics(
+	from *aggregationpb.KeyedSpanMetrics,
+	maxExemplars int,
+) *aggregationpb.KeyedSpanMetrics {
+	cloned := from.CloneVT()
+	if cloned.Metrics != nil {
+		cloned.Metrics.Exemplars = mergeExemplars(cloned.Metrics.Exemplars, nil, maxExemplars)
+	}
+	return cloned
+}
+
+func mergeSpanMetrics(to, from *aggregationpb.SpanMetrics, maxExemplars int) {
 	to.Count += from.Count
 	to.Sum += from.Sum
+	to.Exemplars = mergeExemplars(to.Exemplars, from.Exemplars, maxExemplars)
+}
+
+func mergeKeyedErrorMetrics(to, from *aggregationpb.KeyedErrorMetrics) {
+	if from.Metrics == nil {
+		return
+	}
+	if to.Metrics == nil {
+		to.Metrics = aggregationpb.ErrorMetricsFromVTPool()
+	}
+	mergeErrorMetrics(to.Metrics, from.Metrics)
+}
+
+func mergeErrorMetrics(to, from *aggregationpb.ErrorMetrics) {
+	to.Count += from.Count
+}
+
+func mergeKeyedServiceGraphEdgeMetrics(to, from *aggregationpb.KeyedServiceGraphEdgeMetrics) {
+	if from.Metrics == nil {
+		return
+	}
+	if to.Metrics == nil {
+		to.Metrics = aggregationpb.ServiceGraphEdgeMetricsFromVTPool()
+	}
+	mergeServiceGraphEdgeMetrics(to.Metrics, from.Metrics)
+}
+
+func mergeServiceGraphEdgeMetrics(to, from *aggregationpb.ServiceGraphEdgeMetrics) {
+	to.Count += from.Count
+	to.ErrorCount += from.ErrorCount
+	if to.Histogram == nil && from.Histogram != nil {
+		to.Histogram = aggregationpb.HDRHistogramFromVTPool()
+	}
+	if to.Histogram != nil && from.Histogram != nil {
+		mergeHistogram(to.Histogram, from.Histogram)
+	}
+	to.DdSketch = mergeDDSketch(to.DdSketch, from.DdSketch)
+	to.TDigest = mergeTDigest(to.TDigest, from.TDigest)
+}
+
+// mergeExemplars merges the exemplars from into to, retaining at most
+// maxExemplars exemplars sorted by duration. If there are more exemplars,
+// the exemplars in the densest duration ranges are dropped first, always
+// retaining the fastest and the slowest exemplar, so that the retained
+// exemplars cover the range of recorded durations.
+//
+// The exemplars in from are cloned. Dropped exemplars are removed from the
+// backing array of to, as pooled metrics reuse the backing array.
+func mergeExemplars(to, from []*aggregationpb.Exemplar, maxExemplars int) []*aggregationpb.Exemplar {
+	if maxExemplars <= 0 {
+		for i := range to {
+			to[i] = nil
+		}
+		return to[:0]
+	}
+	for _, e := range from {
+		to = append(to, e.CloneVT())
+	}
+	if len(from) > 0 {
+		sort.SliceStable(to, func(i, j int) bool {
+			return to[i].Duration < to[j].Duration
+		})
+	}
+	for len(to) > maxExemplars {
+		// Drop the exemplar which leaves the smallest gap between its
+		// neighbours, or the fastest exemplar if only two are left.
+		drop := 0
+		if len(to) > 2 {
+			drop = 1
+			for i := 2; i < len(to)-1; i++ {
+				if to[i+1].Duration-to[i-1].Duration < to[drop+1].Duration-to[drop-1].Duration {
+					drop = i
+				}
+			}
+		}
+		copy(to[drop:], to[drop+1:])
+		to[len(to)-1] = nil
+		to = to[:len(to)-1]
+	}
+	return to
+}
+
+// mergeDDSketch merges the sketch from into to and returns the merged
+// sketch, allocating a new sketch from the pool if to is nil.
+func mergeDDSketch(to, from *aggregationpb.DDSketch) *aggregationpb.DDSketch {
+	if from == nil {
+		return to
+	}
+	if to == nil {
+		to = aggregationpb.DDSketchFromVTPool()
+		setDDSketchProto(ddSketchFromProto(from), to)
+		return to
+	}
+	s := ddSketchFromProto(to)
+	s.Merge(ddSketchFromProto(from))
+	setDDSketchProto(s, to)
+	return to
+}
+
+// mergeTDigest merges the t-digest from into to and returns the merged
+// t-digest, allocating a new t-digest from the pool if to is nil.
+func mergeTDigest(to, from *aggregationpb.TDigest) *aggregationpb.TDigest {
+	if from == nil {
+		return to
+	}
+	if to == nil {
+		to = aggregationpb.TDigestFromVTPool()
+	}
+	t := tDigestFromProto(to)
+	t.Merge(tDigestFromProto(from))
+	setTDigestProto(t, to)
+	return to
 }
 
 // mergeHistogram merges two proto representation of HDRHistogram. The
@@ -391,11 +1451,26 @@ func mergeHistogram(to, from *aggregationpb.HDRHistogram) {
 	}
 
 	if len(to.Buckets) == 0 {
+		to.LowestTrackableValue = from.LowestTrackableValue
+		to.HighestTrackableValue = from.HighestTrackableValue
+		to.SignificantFigures = from.SignificantFigures
 		to.Buckets = append(to.Buckets, from.Buckets...)
 		to.Counts = append(to.Counts, from.Counts...)
 		return
 	}
 
+	if !sameHistogramPrecision(to, from) {
+		// The buckets of histograms with different precisions, e.g. while
+		// the precision is reconfigured, are not comparable and the values
+		// of from are recorded again with the precision of to.
+		var toHist, fromHist hdrhistogram.HistogramRepresentation
+		histogramFromProto(&toHist, to)
+		histogramFromProto(&fromHist, from)
+		toHist.Merge(&fromHist)
+		setHistogramProto(&toHist, to)
+		return
+	}
+
 	startToIdx, found := sort.Find(len(to.Buckets), func(i int) int {
 		return int(from.Buckets[0] - to.Buckets[i])
 	})
@@ -454,6 +1529,27 @@ func mergeHistogram(to, from *aggregationpb.HDRHistogram) {
 	}
 }
 
+// sameHistogramPrecision returns true if the histograms have the same
+// parameters, and thus the same buckets.
+func sameHistogramPrecision(a, b *aggregationpb.HDRHistogram) bool {
+	if a.LowestTrackableValue == b.LowestTrackableValue &&
+		a.HighestTrackableValue == b.HighestTrackableValue &&
+		a.SignificantFigures == b.SignificantFigures {
+		return true
+	}
+	// Histograms without parameters have the default parameters.
+	aHist := hdrhistogram.HistogramRepresentation{
+		LowestTrackableValue:  a.LowestTrackableValue,
+		HighestTrackableValue: a.HighestTrackableValue,
+		SignificantFigures:    a.SignificantFigures,
+	}
+	return aHist.SamePrecision(&hdrhistogram.HistogramRepresentation{
+		LowestTrackableValue:  b.LowestTrackableValue,
+		HighestTrackableValue: b.HighestTrackableValue,
+		SignificantFigures:    b.SignificantFigures,
+	})
+}
+
 // getServiceMetrics returns the service metric from a combined metrics based on the
 // service key argument, creating one if needed. A second bool return value indicates
 // if a service is returned or no service can be created due to max svcs limit breach.
@@ -472,10 +1568,16 @@ func getServiceMetrics(cm *combinedMetrics, svcKey serviceAggregationKey, maxSvc
 // based on the service instance key argument, creating one if needed. A second bool
 // return value indicates if a service instance is returned or no service instance can
 // be created due to service instance per service limit breach.
-func getServiceInstanceMetrics(sm *serviceMetrics, siKey serviceInstanceAggregationKey, maxSvcInstancePerSvc int) (serviceInstanceMetrics, bool) {
+func getServiceInstanceMetrics(
+	sm *serviceMetrics,
+	siKey serviceInstanceAggregationKey,
+	maxSvcInstancePerSvc int,
+	globalConstraint *constraint.Constraint,
+) (serviceInstanceMetrics, bool) {
 	sim, ok := sm.ServiceInstanceGroups[siKey]
 	if !ok {
-		if len(sm.ServiceInstanceGroups) < maxSvcInstancePerSvc {
+		if len(sm.ServiceInstanceGroups) < maxSvcInstancePerSvc && !globalConstraint.Maxed() {
+			globalConstraint.Add(1)
 			return newServiceInstanceMetrics(), false
 		}
 		return serviceInstanceMetrics{}, true
@@ -494,6 +1596,8 @@ func newServiceInstanceMetrics() serviceInstanceMetrics {
 		TransactionGroups:        make(map[transactionAggregationKey]*aggregationpb.KeyedTransactionMetrics),
 		ServiceTransactionGroups: make(map[serviceTransactionAggregationKey]*aggregationpb.KeyedServiceTransactionMetrics),
 		SpanGroups:               make(map[spanAggregationKey]*aggregationpb.KeyedSpanMetrics),
+		ErrorGroups:              make(map[errorAggregationKey]*aggregationpb.KeyedErrorMetrics),
+		ServiceGraphEdgeGroups:   make(map[serviceGraphEdgeAggregationKey]*aggregationpb.KeyedServiceGraphEdgeMetrics),
 	}
 }
 
@@ -502,12 +1606,23 @@ type constraints struct {
 	totalTransactionGroups        *constraint.Constraint
 	totalServiceTransactionGroups *constraint.Constraint
 	totalSpanGroups               *constraint.Constraint
+	totalErrorGroups              *constraint.Constraint
+	totalServiceGraphEdges        *constraint.Constraint
+	totalServiceInstanceGroups    *constraint.Constraint
 }
 
 func newConstraints(limits Limits) constraints {
+	maxServiceInstanceGroups := limits.MaxServiceInstanceGroups
+	if maxServiceInstanceGroups <= 0 {
+		// No global limit on service instance groups.
+		maxServiceInstanceGroups = math.MaxInt
+	}
 	return constraints{
 		totalTransactionGroups:        constraint.New(0, limits.MaxTransactionGroups),
 		totalServiceTransactionGroups: constraint.New(0, limits.MaxServiceTransactionGroups),
 		totalSpanGroups:               constraint.New(0, limits.MaxSpanGroups),
+		totalErrorGroups:              constraint.New(0, limits.MaxErrorGroups),
+		totalServiceGraphEdges:        constraint.New(0, limits.MaxServiceGraphEdges),
+		totalServiceInstanceGroups:    constraint.New(0, maxServiceInstanceGroups),
 	}
 }
@@ -5,18 +5,24 @@
 package aggregators
 
 import (
+	"fmt"
 	"math/rand"
 	"reflect"
+	"sort"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
 	"google.golang.org/protobuf/testing/protocmp"
 
 	"github.com/elastic/apm-aggregation/aggregationpb"
+	"github.com/elastic/apm-aggregation/aggregators/internal/ddsketch"
 	"github.com/elastic/apm-aggregation/aggregators/internal/hdrhistogram"
+	"github.com/elastic/apm-aggregation/aggregators/internal/tdigest"
 )
 
 func TestMerge(t *testing.T) {
@@ -748,6 +754,57 @@ func TestMerge(t *testing.T) {
 				return tcm.Get()
 			},
 		},
+		{
+			name: "service_instance_overflow_total_instances",
+			limits: Limits{
+				MaxServices:                        2,
+				MaxServiceInstanceGroupsPerService: 2,
+				MaxServiceInstanceGroups:           2,
+			},
+			to: func() combinedMetrics {
+				return NewTestCombinedMetrics(WithEventsTotal(1)).
+					AddServiceMetrics(
+						serviceAggregationKey{Timestamp: ts, ServiceName: "svc1"}).
+					AddServiceInstanceMetrics(
+						serviceInstanceAggregationKey{HostName: "host1"}).
+					Get()
+			},
+			from: func() *aggregationpb.CombinedMetrics {
+				tcm := NewTestCombinedMetrics(WithEventsTotal(2))
+				tcm.
+					AddServiceMetrics(
+						serviceAggregationKey{Timestamp: ts, ServiceName: "svc1"}).
+					AddServiceInstanceMetrics(
+						serviceInstanceAggregationKey{HostName: "host2"})
+				tcm.
+					AddServiceMetrics(
+						serviceAggregationKey{Timestamp: ts, ServiceName: "svc2"}).
+					AddServiceInstanceMetrics(
+						serviceInstanceAggregationKey{HostName: "host1"})
+				return tcm.GetProto()
+			},
+			expected: func() combinedMetrics {
+				tcm := NewTestCombinedMetrics(WithEventsTotal(3))
+				tcm.
+					AddServiceMetrics(
+						serviceAggregationKey{Timestamp: ts, ServiceName: "svc1"}).
+					AddServiceInstanceMetrics(
+						serviceInstanceAggregationKey{HostName: "host1"})
+				tcm.
+					AddServiceMetrics(
+						serviceAggregationKey{Timestamp: ts, ServiceName: "svc1"}).
+					AddServiceInstanceMetrics(
+						serviceInstanceAggregationKey{HostName: "host2"})
+				// the service is within limits, but the instance exceeds
+				// the total number of service instance groups
+				tcm.
+					AddServiceMetrics(
+						serviceAggregationKey{Timestamp: ts, ServiceName: "svc2"}).
+					AddServiceInstanceMetricsOverflow(
+						serviceInstanceAggregationKey{HostName: "host1"})
+				return tcm.Get()
+			},
+		},
 		{
 			name: "service_instance_overflow_global",
 			limits: Limits{
@@ -949,6 +1006,320 @@ func TestMerge(t *testing.T) {
 				return tcm.Get()
 			},
 		},
+		{
+			name: "span_name_per_destination_limit",
+			limits: Limits{
+				MaxSpanGroups:                      100,
+				MaxSpanGroupsPerService:            100,
+				MaxSpanNamePerDestination:          1,
+				MaxServices:                        1,
+				MaxServiceInstanceGroupsPerService: 1,
+			},
+			to: func() combinedMetrics {
+				return NewTestCombinedMetrics(WithEventsTotal(3)).
+					AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "svc1"}).
+					AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+					AddSpan(spanAggregationKey{SpanName: "span1", Resource: "db"}, WithSpanCount(3)).
+					Get()
+			},
+			from: func() *aggregationpb.CombinedMetrics {
+				return NewTestCombinedMetrics(WithEventsTotal(3)).
+					AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "svc1"}).
+					AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+					AddSpan(spanAggregationKey{SpanName: "span2", Resource: "db"}, WithSpanCount(2)).
+					AddSpan(spanAggregationKey{SpanName: "span3", Resource: "cache"}, WithSpanCount(1)).
+					GetProto()
+			},
+			expected: func() combinedMetrics {
+				return NewTestCombinedMetrics(WithEventsTotal(6)).
+					AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "svc1"}).
+					AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+					AddSpan(spanAggregationKey{SpanName: "span1", Resource: "db"}, WithSpanCount(3)).
+					// span name is dropped as the limit for the destination is reached
+					AddSpan(spanAggregationKey{SpanName: "", Resource: "db"}, WithSpanCount(2)).
+					AddSpan(spanAggregationKey{SpanName: "span3", Resource: "cache"}, WithSpanCount(1)).
+					Get()
+			},
+		},
+		{
+			name: "faas_ids_per_service_limit",
+			limits: Limits{
+				MaxTransactionGroups:               100,
+				MaxTransactionGroupsPerService:     100,
+				MaxFaasIDsPerService:               1,
+				MaxServices:                        1,
+				MaxServiceInstanceGroupsPerService: 1,
+			},
+			to: func() combinedMetrics {
+				return NewTestCombinedMetrics(WithEventsTotal(3)).
+					AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "svc1"}).
+					AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+					AddTransaction(transactionAggregationKey{TransactionName: "txn1", FAASID: "fn1"}, WithTransactionCount(3)).
+					Get()
+			},
+			from: func() *aggregationpb.CombinedMetrics {
+				return NewTestCombinedMetrics(WithEventsTotal(6)).
+					AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "svc1"}).
+					AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+					AddTransaction(transactionAggregationKey{TransactionName: "txn2", FAASID: "fn1"}, WithTransactionCount(1)).
+					AddTransaction(transactionAggregationKey{TransactionName: "txn1", FAASID: "fn2"}, WithTransactionCount(2)).
+					AddTransaction(transactionAggregationKey{TransactionName: "txn1"}, WithTransactionCount(3)).
+					GetProto()
+			},
+			expected: func() combinedMetrics {
+				return NewTestCombinedMetrics(WithEventsTotal(9)).
+					AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "svc1"}).
+					AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+					AddTransaction(transactionAggregationKey{TransactionName: "txn1", FAASID: "fn1"}, WithTransactionCount(3)).
+					AddTransaction(transactionAggregationKey{TransactionName: "txn2", FAASID: "fn1"}, WithTransactionCount(1)).
+					// faas.id is dropped as the limit for the service is reached
+					AddTransaction(transactionAggregationKey{TransactionName: "txn1"}, WithTransactionCount(5)).
+					Get()
+			},
+		},
+		{
+			name: "mobile_dimensions_per_service_limits",
+			limits: Limits{
+				MaxTransactionGroups:               100,
+				MaxTransactionGroupsPerService:     100,
+				MaxServiceVersionsPerService:       1,
+				MaxHostOSVersionsPerService:        1,
+				MaxServices:                        1,
+				MaxServiceInstanceGroupsPerService: 1,
+			},
+			to: func() combinedMetrics {
+				return NewTestCombinedMetrics(WithEventsTotal(4)).
+					AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "app"}).
+					AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+					AddTransaction(transactionAggregationKey{
+						TransactionName: "txn1", ServiceVersion: "1.0", HostOSName: "android", HostOSVersion: "13",
+					}, WithTransactionCount(3)).
+					AddTransaction(transactionAggregationKey{
+						TransactionName: "txn1", HostOSName: "android", HostOSVersion: "13",
+					}, WithTransactionCount(1)).
+					Get()
+			},
+			from: func() *aggregationpb.CombinedMetrics {
+				return NewTestCombinedMetrics(WithEventsTotal(6)).
+					AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "app"}).
+					AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+					AddTransaction(transactionAggregationKey{
+						TransactionName: "txn1", ServiceVersion: "1.1", HostOSName: "android", HostOSVersion: "13",
+					}, WithTransactionCount(2)).
+					AddTransaction(transactionAggregationKey{
+						TransactionName: "txn1", ServiceVersion: "1.0", HostOSName: "android", HostOSVersion: "14",
+					}, WithTransactionCount(3)).
+					AddTransaction(transactionAggregationKey{
+						TransactionName: "txn2", ServiceVersion: "1.0", HostOSName: "android", HostOSVersion: "13",
+					}, WithTransactionCount(1)).
+					GetProto()
+			},
+			expected: func() combinedMetrics {
+				return NewTestCombinedMetrics(WithEventsTotal(10)).
+					AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "app"}).
+					AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+					AddTransaction(transactionAggregationKey{
+						TransactionName: "txn1", ServiceVersion: "1.0", HostOSName: "android", HostOSVersion: "13",
+					}, WithTransactionCount(3)).
+					// service version is dropped as its limit is reached
+					AddTransaction(transactionAggregationKey{
+						TransactionName: "txn1", HostOSName: "android", HostOSVersion: "13",
+					}, WithTransactionCount(3)).
+					// host OS version is dropped as its limit is reached
+					AddTransaction(transactionAggregationKey{
+						TransactionName: "txn1", ServiceVersion: "1.0", HostOSName: "android",
+					}, WithTransactionCount(3)).
+					AddTransaction(transactionAggregationKey{
+						TransactionName: "txn2", ServiceVersion: "1.0", HostOSName: "android", HostOSVersion: "13",
+					}, WithTransactionCount(1)).
+					Get()
+			},
+		},
+		{
+			name: "kubernetes_workloads_per_service_limit",
+			limits: Limits{
+				MaxServices:                        10,
+				MaxServiceInstanceGroupsPerService: 1,
+				MaxKubernetesWorkloadsPerService:   1,
+			},
+			to: func() combinedMetrics {
+				return NewTestCombinedMetrics(WithEventsTotal(1)).
+					AddServiceMetrics(serviceAggregationKey{
+						Timestamp: ts, ServiceName: "svc1", KubernetesNamespace: "ns1", KubernetesDeploymentName: "dep1",
+					}).
+					AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+					Get()
+			},
+			from: func() *aggregationpb.CombinedMetrics {
+				tcm := NewTestCombinedMetrics(WithEventsTotal(3))
+				tcm.AddServiceMetrics(serviceAggregationKey{
+					Timestamp: ts, ServiceName: "svc1", KubernetesNamespace: "ns2", KubernetesDeploymentName: "dep1",
+				}).AddServiceInstanceMetrics(serviceInstanceAggregationKey{})
+				tcm.AddServiceMetrics(serviceAggregationKey{
+					Timestamp: ts, ServiceName: "svc2", KubernetesNamespace: "ns1", KubernetesDeploymentName: "dep2",
+				}).AddServiceInstanceMetrics(serviceInstanceAggregationKey{})
+				return tcm.GetProto()
+			},
+			expected: func() combinedMetrics {
+				tcm := NewTestCombinedMetrics(WithEventsTotal(4))
+				tcm.AddServiceMetrics(serviceAggregationKey{
+					Timestamp: ts, ServiceName: "svc1", KubernetesNamespace: "ns1", KubernetesDeploymentName: "dep1",
+				}).AddServiceInstanceMetrics(serviceInstanceAggregationKey{})
+				// Kubernetes dimensions are dropped as the limit for the service is reached
+				tcm.AddServiceMetrics(serviceAggregationKey{
+					Timestamp: ts, ServiceName: "svc1",
+				}).AddServiceInstanceMetrics(serviceInstanceAggregationKey{})
+				tcm.AddServiceMetrics(serviceAggregationKey{
+					Timestamp: ts, ServiceName: "svc2", KubernetesNamespace: "ns1", KubernetesDeploymentName: "dep2",
+				}).AddServiceInstanceMetrics(serviceInstanceAggregationKey{})
+				return tcm.Get()
+			},
+		},
+		{
+			name: "error_groups_no_overflow",
+			limits: Limits{
+				MaxServices:                        1,
+				MaxServiceInstanceGroupsPerService: 1,
+				MaxErrorGroups:                     100,
+				MaxErrorGroupsPerService:           100,
+			},
+			to: func() combinedMetrics {
+				return NewTestCombinedMetrics(WithEventsTotal(3)).
+					AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "svc1"}).
+					AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+					AddError(errorAggregationKey{GroupingKey: "err1"}, 3).
+					Get()
+			},
+			from: func() *aggregationpb.CombinedMetrics {
+				return NewTestCombinedMetrics(WithEventsTotal(3)).
+					AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "svc1"}).
+					AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+					AddError(errorAggregationKey{GroupingKey: "err1"}, 1).
+					AddError(errorAggregationKey{GroupingKey: "err2", Outcome: "failure"}, 2).
+					GetProto()
+			},
+			expected: func() combinedMetrics {
+				return NewTestCombinedMetrics(WithEventsTotal(6)).
+					AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "svc1"}).
+					AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+					AddError(errorAggregationKey{GroupingKey: "err1"}, 4).
+					AddError(errorAggregationKey{GroupingKey: "err2", Outcome: "failure"}, 2).
+					Get()
+			},
+		},
+		{
+			name: "error_groups_overflow",
+			limits: Limits{
+				MaxServices:                        1,
+				MaxServiceInstanceGroupsPerService: 1,
+				MaxErrorGroups:                     100,
+				MaxErrorGroupsPerService:           1,
+			},
+			to: func() combinedMetrics {
+				return NewTestCombinedMetrics(WithEventsTotal(3)).
+					AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "svc1"}).
+					AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+					AddError(errorAggregationKey{GroupingKey: "err1"}, 3).
+					Get()
+			},
+			from: func() *aggregationpb.CombinedMetrics {
+				tcm := NewTestCombinedMetrics(WithEventsTotal(4))
+				tcm.AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "svc1"}).
+					AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+					AddError(errorAggregationKey{GroupingKey: "err2"}, 2)
+				tcm.AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "svc2"}).
+					AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+					AddError(errorAggregationKey{GroupingKey: "err3"}, 2)
+				return tcm.GetProto()
+			},
+			expected: func() combinedMetrics {
+				tcm := NewTestCombinedMetrics(WithEventsTotal(7))
+				tcm.AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "svc1"}).
+					AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+					AddError(errorAggregationKey{GroupingKey: "err1"}, 3).
+					AddErrorOverflow(errorAggregationKey{GroupingKey: "err2"}, 2)
+				tcm.AddServiceMetricsOverflow(serviceAggregationKey{Timestamp: ts, ServiceName: "svc2"}).
+					AddServiceInstanceMetricsOverflow(serviceInstanceAggregationKey{}).
+					AddErrorOverflow(errorAggregationKey{GroupingKey: "err3"}, 2)
+				return tcm.Get()
+			},
+		},
+		{
+			name: "service_graph_edges_no_overflow",
+			limits: Limits{
+				MaxServices:                        1,
+				MaxServiceInstanceGroupsPerService: 1,
+				MaxServiceGraphEdges:               100,
+				MaxServiceGraphEdgesPerService:     100,
+			},
+			to: func() combinedMetrics {
+				return NewTestCombinedMetrics(WithEventsTotal(3)).
+					AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "svc1"}).
+					AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+					AddServiceGraphEdge(serviceGraphEdgeAggregationKey{Resource: "db"}, 3, 1, time.Millisecond).
+					Get()
+			},
+			from: func() *aggregationpb.CombinedMetrics {
+				return NewTestCombinedMetrics(WithEventsTotal(3)).
+					AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "svc1"}).
+					AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+					AddServiceGraphEdge(serviceGraphEdgeAggregationKey{Resource: "db"}, 1, 1, time.Second).
+					AddServiceGraphEdge(
+						serviceGraphEdgeAggregationKey{TargetType: "http", TargetName: "svc2"},
+						2, 0, time.Millisecond,
+					).
+					GetProto()
+			},
+			expected: func() combinedMetrics {
+				return NewTestCombinedMetrics(WithEventsTotal(6)).
+					AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "svc1"}).
+					AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+					AddServiceGraphEdge(serviceGraphEdgeAggregationKey{Resource: "db"}, 3, 1, time.Millisecond).
+					AddServiceGraphEdge(serviceGraphEdgeAggregationKey{Resource: "db"}, 1, 1, time.Second).
+					AddServiceGraphEdge(
+						serviceGraphEdgeAggregationKey{TargetType: "http", TargetName: "svc2"},
+						2, 0, time.Millisecond,
+					).
+					Get()
+			},
+		},
+		{
+			name: "service_graph_edges_overflow",
+			limits: Limits{
+				MaxServices:                        1,
+				MaxServiceInstanceGroupsPerService: 1,
+				MaxServiceGraphEdges:               100,
+				MaxServiceGraphEdgesPerService:     1,
+			},
+			to: func() combinedMetrics {
+				return NewTestCombinedMetrics(WithEventsTotal(3)).
+					AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "svc1"}).
+					AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+					AddServiceGraphEdge(serviceGraphEdgeAggregationKey{Resource: "db"}, 3, 0, time.Millisecond).
+					Get()
+			},
+			from: func() *aggregationpb.CombinedMetrics {
+				tcm := NewTestCombinedMetrics(WithEventsTotal(4))
+				tcm.AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "svc1"}).
+					AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+					AddServiceGraphEdge(serviceGraphEdgeAggregationKey{Resource: "cache"}, 2, 1, time.Millisecond)
+				tcm.AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "svc2"}).
+					AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+					AddServiceGraphEdge(serviceGraphEdgeAggregationKey{Resource: "db"}, 2, 0, time.Millisecond)
+				return tcm.GetProto()
+			},
+			expected: func() combinedMetrics {
+				tcm := NewTestCombinedMetrics(WithEventsTotal(7))
+				tcm.AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "svc1"}).
+					AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+					AddServiceGraphEdge(serviceGraphEdgeAggregationKey{Resource: "db"}, 3, 0, time.Millisecond).
+					AddServiceGraphEdgeOverflow(serviceGraphEdgeAggregationKey{Resource: "cache"}, 2, 1, time.Millisecond)
+				tcm.AddServiceMetricsOverflow(serviceAggregationKey{Timestamp: ts, ServiceName: "svc2"}).
+					AddServiceInstanceMetricsOverflow(serviceInstanceAggregationKey{}).
+					AddServiceGraphEdgeOverflow(serviceGraphEdgeAggregationKey{Resource: "db"}, 2, 0, time.Millisecond)
+				return tcm.Get()
+			},
+		},
 		{
 			name: "merge_with_empty_combined_metrics",
 			limits: Limits{
@@ -1001,10 +1372,13 @@ func TestMerge(t *testing.T) {
 			metrics := tc.to()
 			constraints := newConstraints(tc.limits)
 			for _, svc := range metrics.Services {
+				constraints.totalServiceInstanceGroups.Add(len(svc.ServiceInstanceGroups))
 				for _, si := range svc.ServiceInstanceGroups {
 					constraints.totalTransactionGroups.Add(len(si.TransactionGroups))
 					constraints.totalServiceTransactionGroups.Add(len(si.ServiceTransactionGroups))
 					constraints.totalSpanGroups.Add(len(si.SpanGroups))
+					constraints.totalErrorGroups.Add(len(si.ErrorGroups))
+					constraints.totalServiceGraphEdges.Add(len(si.ServiceGraphEdgeGroups))
 				}
 			}
 			cmm := combinedMetricsMerger{
@@ -1016,12 +1390,26 @@ func TestMerge(t *testing.T) {
 			assert.Empty(t, cmp.Diff(
 				tc.expected(), cmm.metrics,
 				protocmp.Transform(),
+				ignoreMergeIndexes,
 				cmp.Exporter(func(reflect.Type) bool { return true }),
 			))
 		})
 	}
 }
 
+// ignoreMergeIndexes ignores the values tracked by the merger to enforce
+// the per service limits, which are built on demand.
+var ignoreMergeIndexes = cmp.Options{
+	cmpopts.IgnoreFields(combinedMetrics{}, "kubernetesWorkloads"),
+	cmpopts.IgnoreFields(serviceMetrics{}, "globalLabelSets"),
+	cmpopts.IgnoreFields(
+		serviceInstanceMetrics{},
+		"transactionDimensionValues", "spanDestinationNames",
+		"lowestTransactions", "lowestServiceTransactions", "lowestSpans",
+		"lowestErrors", "lowestServiceGraphEdges",
+	),
+}
+
 func TestCardinalityEstimationOnSubKeyCollision(t *testing.T) {
 	limits := Limits{
 		MaxSpanGroups:                         100,
@@ -1072,6 +1460,359 @@ func TestCardinalityEstimationOnSubKeyCollision(t *testing.T) {
 	assert.Equal(t, uint64(2), cmm.metrics.OverflowServices.OverflowSpan.Estimator.Estimate())
 }
 
+func TestMergeGlobalLabelsPerServiceLimit(t *testing.T) {
+	limits := Limits{
+		MaxServices:                        10,
+		MaxServiceInstanceGroupsPerService: 10,
+		MaxTransactionGroups:               10,
+		MaxTransactionGroupsPerService:     10,
+		MaxGlobalLabelsPerService:          2,
+	}
+	ts := time.Unix(0, 0).UTC()
+	from := NewTestCombinedMetrics(WithEventsTotal(4))
+	svc := from.AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: "svc1"})
+	for _, labels := range []string{"1", "2", "3", "4"} {
+		svc.AddServiceInstanceMetrics(serviceInstanceAggregationKey{
+			GlobalLabelsStr: getTestGlobalLabelsStr(t, labels),
+		}).
+			AddTransaction(transactionAggregationKey{
+				TransactionName: "txn1",
+				TransactionType: "type1",
+			}, WithTransactionCount(1))
+	}
+	cmm := combinedMetricsMerger{
+		limits:      limits,
+		constraints: newConstraints(limits),
+		metrics:     NewTestCombinedMetrics(WithEventsTotal(0)).Get(),
+	}
+	cmm.merge(from.GetProto())
+
+	require.Len(t, cmm.metrics.Services, 1)
+	sm := cmm.metrics.Services[serviceAggregationKey{Timestamp: ts, ServiceName: "svc1"}]
+	// global labels are dropped once the limit for the service is reached
+	assert.Len(t, sm.ServiceInstanceGroups, 3)
+	assert.Equal(t, 2, globalLabelsPerService(&sm))
+	assert.Len(t, sm.ServiceInstanceGroups[serviceInstanceAggregationKey{}].TransactionGroups, 1)
+	assert.Equal(t, uint64(2), sm.OverflowGroups.GlobalLabelsEstimator.Estimate())
+
+	b, err := CombinedMetricsToBatch(cmm.metrics.ToProto(), ts, time.Minute)
+	require.NoError(t, err)
+	var overflowCount float64
+	for _, e := range *b {
+		for _, sample := range e.GetMetricset().GetSamples() {
+			if sample.Name == "service_summary.aggregation.global_labels_overflow_count" {
+				overflowCount += sample.Value
+			}
+		}
+	}
+	assert.Equal(t, 2.0, overflowCount)
+}
+
+func TestMergeTopKRetention(t *testing.T) {
+	limits := Limits{
+		MaxSpanGroups:                         100,
+		MaxSpanGroupsPerService:               100,
+		MaxTransactionGroups:                  100,
+		MaxTransactionGroupsPerService:        1,
+		MaxServiceTransactionGroups:           100,
+		MaxServiceTransactionGroupsPerService: 1,
+		MaxServices:                           1,
+		MaxServiceInstanceGroupsPerService:    1,
+	}
+	svcKey := serviceAggregationKey{Timestamp: time.Unix(0, 0).UTC(), ServiceName: "svc1"}
+	newFrom := func(name string, count int) *aggregationpb.CombinedMetrics {
+		return NewTestCombinedMetrics(WithEventsTotal(float64(count))).
+			AddServiceMetrics(svcKey).
+			AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+			AddTransaction(transactionAggregationKey{
+				TransactionName: name,
+				TransactionType: name,
+			}, WithTransactionCount(count)).
+			AddServiceTransaction(serviceTransactionAggregationKey{
+				TransactionType: name,
+			}, WithTransactionCount(count)).
+			GetProto()
+	}
+
+	cmm := combinedMetricsMerger{
+		limits:      limits,
+		constraints: newConstraints(limits),
+		topK:        true,
+	}
+	cmm.merge(newFrom("txn1", 1))
+	// txn2 has higher throughput and replaces txn1.
+	cmm.merge(newFrom("txn2", 5))
+	// txn3 has lower throughput than txn2 and overflows.
+	cmm.merge(newFrom("txn3", 2))
+
+	sm := cmm.metrics.Services[svcKey]
+	sim := sm.ServiceInstanceGroups[serviceInstanceAggregationKey{}]
+	require.Len(t, sim.TransactionGroups, 1)
+	for tk := range sim.TransactionGroups {
+		assert.Equal(t, "txn2", tk.TransactionName)
+	}
+	require.Len(t, sim.ServiceTransactionGroups, 1)
+	for stk := range sim.ServiceTransactionGroups {
+		assert.Equal(t, "txn2", stk.TransactionType)
+	}
+	overflow := sm.OverflowGroups
+	assert.Equal(t, uint64(2), overflow.OverflowTransaction.Estimator.Estimate())
+	assert.Equal(t, uint64(2), overflow.OverflowServiceTransaction.Estimator.Estimate())
+}
+
+func TestMergeDimensionLimitsTrackEvictions(t *testing.T) {
+	limits := Limits{
+		MaxSpanGroups:                         100,
+		MaxSpanGroupsPerService:               100,
+		MaxTransactionGroups:                  100,
+		MaxTransactionGroupsPerService:        1,
+		MaxFaasIDsPerService:                  1,
+		MaxServiceTransactionGroups:           100,
+		MaxServiceTransactionGroupsPerService: 100,
+		MaxServices:                           1,
+		MaxServiceInstanceGroupsPerService:    1,
+	}
+	svcKey := serviceAggregationKey{Timestamp: time.Unix(0, 0).UTC(), ServiceName: "svc1"}
+	newFrom := func(faasID string, count int) *aggregationpb.CombinedMetrics {
+		return NewTestCombinedMetrics(WithEventsTotal(float64(count))).
+			AddServiceMetrics(svcKey).
+			AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+			AddTransaction(transactionAggregationKey{
+				TransactionName: "txn",
+				TransactionType: "type",
+				FAASID:          faasID,
+			}, WithTransactionCount(count)).
+			GetProto()
+	}
+
+	cmm := combinedMetricsMerger{
+		limits:      limits,
+		constraints: newConstraints(limits),
+		topK:        true,
+	}
+	cmm.merge(newFrom("a", 1))
+	// The FaaS ID limit is reached, b is dropped from the key and the
+	// group replaces the group of a.
+	cmm.merge(newFrom("b", 5))
+	// The group of a was evicted, so c is tracked and replaces the group
+	// without FaaS ID.
+	cmm.merge(newFrom("c", 10))
+
+	sim := cmm.metrics.Services[svcKey].ServiceInstanceGroups[serviceInstanceAggregationKey{}]
+	require.Len(t, sim.TransactionGroups, 1)
+	for tk := range sim.TransactionGroups {
+		assert.Equal(t, "c", tk.FAASID)
+	}
+	assert.Equal(t, map[string]int{"c": 1}, sim.transactionDimensionValues[0])
+}
+
+func TestMergeOverflowIndependentOfEncodingOrder(t *testing.T) {
+	limits := Limits{
+		MaxServices:                        1,
+		MaxServiceInstanceGroupsPerService: 1,
+	}
+	ts := time.Unix(0, 0).UTC()
+	from := func(reverse bool) *aggregationpb.CombinedMetrics {
+		tcm := NewTestCombinedMetrics(WithEventsTotal(4))
+		for _, svc := range []string{"svc1", "svc2"} {
+			sm := tcm.AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: svc})
+			sm.AddServiceInstanceMetrics(serviceInstanceAggregationKey{HostName: "host1"})
+			sm.AddServiceInstanceMetrics(serviceInstanceAggregationKey{HostName: "host2"})
+		}
+		pb := tcm.GetProto()
+		less := func(i, j int) bool {
+			return pb.ServiceMetrics[i].Key.ServiceName < pb.ServiceMetrics[j].Key.ServiceName
+		}
+		if reverse {
+			less = func(i, j int) bool {
+				return pb.ServiceMetrics[i].Key.ServiceName > pb.ServiceMetrics[j].Key.ServiceName
+			}
+		}
+		sort.Slice(pb.ServiceMetrics, less)
+		for _, sm := range pb.ServiceMetrics {
+			instances := sm.Metrics.ServiceInstanceMetrics
+			sort.Slice(instances, func(i, j int) bool {
+				return (instances[i].Key.HostName < instances[j].Key.HostName) != reverse
+			})
+		}
+		return pb
+	}
+	merge := func(reverse bool) combinedMetrics {
+		cmm := combinedMetricsMerger{
+			limits:      limits,
+			constraints: newConstraints(limits),
+		}
+		cmm.merge(from(reverse))
+		return cmm.metrics
+	}
+	assert.Empty(t, cmp.Diff(
+		merge(false), merge(true),
+		protocmp.Transform(),
+		ignoreMergeIndexes,
+		cmp.Exporter(func(reflect.Type) bool { return true }),
+	))
+}
+
+func TestMergeOrderedOnlyNearLimits(t *testing.T) {
+	ts := time.Unix(0, 0).UTC()
+	from := func(reverse bool) *aggregationpb.CombinedMetrics {
+		tcm := NewTestCombinedMetrics(WithEventsTotal(2))
+		for _, svc := range []string{"svc1", "svc2"} {
+			tcm.AddServiceMetrics(serviceAggregationKey{Timestamp: ts, ServiceName: svc}).
+				AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+				AddTransaction(transactionAggregationKey{
+					TransactionName: svc,
+					TransactionType: "type",
+				}, WithTransactionCount(1))
+		}
+		pb := tcm.GetProto()
+		sort.Slice(pb.ServiceMetrics, func(i, j int) bool {
+			return (pb.ServiceMetrics[i].Key.ServiceName < pb.ServiceMetrics[j].Key.ServiceName) != reverse
+		})
+		return pb
+	}
+	serviceNames := func(pb *aggregationpb.CombinedMetrics) []string {
+		var names []string
+		for _, ksm := range pb.ServiceMetrics {
+			names = append(names, ksm.Key.ServiceName)
+		}
+		return names
+	}
+	merge := func(limits Limits, pb *aggregationpb.CombinedMetrics) combinedMetrics {
+		cmm := combinedMetricsMerger{
+			limits:      limits,
+			constraints: newConstraints(limits),
+		}
+		cmm.merge(pb)
+		return cmm.metrics
+	}
+
+	// Merges within the limits keep the encoding order.
+	limits := Limits{
+		MaxServices:                        10,
+		MaxServiceInstanceGroupsPerService: 10,
+		MaxTransactionGroups:               10,
+		MaxTransactionGroupsPerService:     10,
+	}
+	for _, reverse := range []bool{false, true} {
+		pb := from(reverse)
+		names := serviceNames(pb)
+		merge(limits, pb)
+		assert.Equal(t, names, serviceNames(pb))
+	}
+
+	// The global transaction group limit is reached while the services are
+	// within their limits, the retained group does not depend on the
+	// encoding order.
+	limits.MaxTransactionGroups = 1
+	assert.Empty(t, cmp.Diff(
+		merge(limits, from(false)), merge(limits, from(true)),
+		protocmp.Transform(),
+		ignoreMergeIndexes,
+		cmp.Exporter(func(reflect.Type) bool { return true }),
+	))
+}
+
+func TestMergeBudget(t *testing.T) {
+	limits := Limits{
+		MaxServices:                        10,
+		MaxServiceInstanceGroupsPerService: 10,
+	}
+	ts := time.Unix(0, 0).UTC()
+	tcm := NewTestCombinedMetrics(WithEventsTotal(3))
+	for i := 0; i < 3; i++ {
+		tcm.AddServiceMetrics(serviceAggregationKey{
+			Timestamp:   ts,
+			ServiceName: fmt.Sprintf("svc%d", i),
+		}).AddServiceInstanceMetrics(serviceInstanceAggregationKey{})
+	}
+	codec, err := newValueCodec(NoCompression, 0, nil, "")
+	require.NoError(t, err)
+
+	// Every reading of the clock takes 1ms of merge time. The budget of
+	// 100ms of merge time per second is exhausted.
+	clock := &stepClock{now: ts, step: time.Millisecond}
+	throttle := rate.NewLimiter(rate.Limit(1e5), 100)
+	require.True(t, throttle.AllowN(ts, 100))
+
+	var mergeTime, throttled time.Duration
+	cmm := combinedMetricsMerger{
+		limits:      limits,
+		constraints: newConstraints(limits),
+		codec:       codec,
+		throttle:    throttle,
+		clock:       clock,
+		observe: func(d, throttledTime time.Duration) {
+			mergeTime, throttled = d, throttledTime
+		},
+	}
+	cmm.merge(tcm.GetProto())
+	_, _, err = cmm.Finish(true)
+	require.NoError(t, err)
+	assert.Len(t, cmm.metrics.Services, 3)
+
+	// The first service starts the first slice, the slices ending at the
+	// next services take 1ms of merge time, i.e. 10ms of the budget of
+	// which 1ms is refilled during the slice.
+	assert.Equal(t, []time.Duration{9 * time.Millisecond, 9 * time.Millisecond}, clock.waited)
+	assert.Equal(t, 18*time.Millisecond, throttled)
+	assert.Equal(t, clock.elapsed()-throttled, mergeTime)
+}
+
+func TestMergeBudgetChargesFullSlice(t *testing.T) {
+	start := time.Unix(0, 0)
+	clock := &stepClock{now: start}
+	// The burst of 100µs of merge time is exhausted.
+	throttle := rate.NewLimiter(rate.Limit(1e5), 100)
+	require.True(t, throttle.AllowN(start, 100))
+
+	m := combinedMetricsMerger{throttle: throttle, clock: clock, sliceStart: start}
+	// A slice of 1ms is 10 times the burst, it is charged in full in
+	// chunks of the burst. At 100ms per second, the budget for 1ms of
+	// merge time takes 10ms, of which 1ms passed during the slice.
+	clock.now = start.Add(time.Millisecond)
+	m.maybeThrottle()
+	assert.Equal(t, []time.Duration{9 * time.Millisecond}, clock.waited)
+	assert.Equal(t, 9*time.Millisecond, m.throttled)
+	assert.Equal(t, clock.now, m.sliceStart)
+	// All the reserved tokens are used once the merger waited.
+	assert.False(t, throttle.AllowN(clock.now, 1))
+	assert.True(t, throttle.AllowN(clock.now.Add(10*time.Microsecond), 1))
+}
+
+// stepClock is a Clock advancing by step on every reading, whose timers
+// fire immediately, advancing the clock by their duration.
+type stepClock struct {
+	start  time.Time
+	now    time.Time
+	step   time.Duration
+	waited []time.Duration
+}
+
+func (c *stepClock) Now() time.Time {
+	if c.start.IsZero() {
+		c.start = c.now
+	}
+	now := c.now
+	c.now = c.now.Add(c.step)
+	return now
+}
+
+func (c *stepClock) NewTimer(d time.Duration) Timer {
+	c.waited = append(c.waited, d)
+	c.now = c.now.Add(d)
+	return realClock{}.NewTimer(0)
+}
+
+func (c *stepClock) AfterFunc(time.Duration, func()) Timer {
+	panic("not implemented")
+}
+
+// elapsed returns the time elapsed since the first reading.
+func (c *stepClock) elapsed() time.Duration {
+	return c.now.Sub(c.start) - c.step
+}
+
 func TestMergeHistogramEquiv(t *testing.T) {
 	for _, tc := range []struct {
 		name       string
@@ -1261,3 +2002,121 @@ func TestMergeHistogram(t *testing.T) {
 		})
 	}
 }
+
+func TestMergeTransactionMetricsSketches(t *testing.T) {
+	newDDSketch := func(d time.Duration, n float64) *aggregationpb.DDSketch {
+		pb := &aggregationpb.DDSketch{}
+		s := ddsketch.New()
+		s.RecordDuration(d, n)
+		setDDSketchProto(s, pb)
+		return pb
+	}
+	newTDigest := func(d time.Duration, n float64) *aggregationpb.TDigest {
+		pb := &aggregationpb.TDigest{}
+		td := tdigest.New()
+		td.RecordDuration(d, n)
+		setTDigestProto(td, pb)
+		return pb
+	}
+
+	to := &aggregationpb.TransactionMetrics{}
+	mergeTransactionMetrics(to, &aggregationpb.TransactionMetrics{
+		DdSketch: newDDSketch(time.Millisecond, 2),
+		TDigest:  newTDigest(time.Millisecond, 2),
+	}, 0)
+	mergeTransactionMetrics(to, &aggregationpb.TransactionMetrics{
+		DdSketch: newDDSketch(time.Millisecond, 1),
+		TDigest:  newTDigest(time.Second, 1),
+	}, 0)
+	mergeTransactionMetrics(to, &aggregationpb.TransactionMetrics{
+		DdSketch: newDDSketch(time.Second, 1),
+	}, 0)
+
+	require.NotNil(t, to.DdSketch)
+	assert.Len(t, to.DdSketch.Indexes, 2)
+	assert.Equal(t, []float64{3, 1}, to.DdSketch.Counts)
+	assert.Equal(t, ddsketch.DefaultRelativeAccuracy, to.DdSketch.RelativeAccuracy)
+
+	require.NotNil(t, to.TDigest)
+	assert.Equal(t, []float64{1000, 1000000}, to.TDigest.Means)
+	assert.Equal(t, []float64{2, 1}, to.TDigest.Weights)
+
+	assert.Equal(t, float64(7), transactionCount(to))
+}
+
+func TestMergeDurationExtremes(t *testing.T) {
+	to := &aggregationpb.TransactionMetrics{}
+	mergeTransactionMetrics(to, &aggregationpb.TransactionMetrics{Min: 500, Max: 2000}, 0)
+	assert.Equal(t, float64(500), to.Min)
+	assert.Equal(t, float64(2000), to.Max)
+
+	mergeTransactionMetrics(to, &aggregationpb.TransactionMetrics{Min: 100, Max: 1000}, 0)
+	mergeTransactionMetrics(to, &aggregationpb.TransactionMetrics{Min: 1000, Max: 5000}, 0)
+	// Extremes which were not recorded are ignored.
+	mergeTransactionMetrics(to, &aggregationpb.TransactionMetrics{}, 0)
+	assert.Equal(t, float64(100), to.Min)
+	assert.Equal(t, float64(5000), to.Max)
+
+	stm := &aggregationpb.ServiceTransactionMetrics{}
+	mergeServiceTransactionMetrics(stm, &aggregationpb.ServiceTransactionMetrics{})
+	mergeServiceTransactionMetrics(stm, &aggregationpb.ServiceTransactionMetrics{Min: 30, Max: 30})
+	mergeServiceTransactionMetrics(stm, &aggregationpb.ServiceTransactionMetrics{Min: 10, Max: 20})
+	assert.Equal(t, float64(10), stm.Min)
+	assert.Equal(t, float64(30), stm.Max)
+}
+
+func TestMergeDurationSum(t *testing.T) {
+	newTxn := func(sum float64, d time.Duration) *aggregationpb.TransactionMetrics {
+		h := hdrhistogram.New()
+		h.RecordDuration(d, 1)
+		pb := &aggregationpb.TransactionMetrics{Histogram: &aggregationpb.HDRHistogram{}, Sum: sum}
+		setHistogramProto(h, pb.Histogram)
+		return pb
+	}
+
+	to := &aggregationpb.TransactionMetrics{}
+	mergeTransactionMetrics(to, newTxn(1000.5, time.Millisecond), 0)
+	mergeTransactionMetrics(to, newTxn(2000, 2*time.Millisecond), 0)
+	assert.Equal(t, 3000.5, to.Sum)
+	// Metrics aggregated without recording the sum drop the recorded sum,
+	// which is not recorded again by later merges.
+	mergeTransactionMetrics(to, newTxn(0, time.Millisecond), 0)
+	assert.Zero(t, to.Sum)
+	mergeTransactionMetrics(to, newTxn(1000, time.Millisecond), 0)
+	assert.Zero(t, to.Sum)
+
+	to = &aggregationpb.TransactionMetrics{}
+	mergeTransactionMetrics(to, newTxn(0, time.Millisecond), 0)
+	mergeTransactionMetrics(to, newTxn(1000, time.Millisecond), 0)
+	assert.Zero(t, to.Sum)
+}
+
+func TestMergeExemplars(t *testing.T) {
+	exemplars := func(durations ...int64) []*aggregationpb.Exemplar {
+		out := make([]*aggregationpb.Exemplar, len(durations))
+		for i, d := range durations {
+			out[i] = &aggregationpb.Exemplar{Duration: d}
+		}
+		return out
+	}
+	durations := func(exemplars []*aggregationpb.Exemplar) []int64 {
+		out := make([]int64, len(exemplars))
+		for i, e := range exemplars {
+			out[i] = e.Duration
+		}
+		return out
+	}
+
+	to := mergeExemplars(exemplars(10, 50), exemplars(20, 11, 100, 49), 4)
+	assert.Equal(t, []int64{10, 20, 50, 100}, durations(to))
+	// Dropped exemplars are cleared from the backing array.
+	assert.Nil(t, to[:cap(to)][len(to)])
+
+	to = mergeExemplars(to, exemplars(1000), 2)
+	assert.Equal(t, []int64{10, 1000}, durations(to))
+
+	to = mergeExemplars(to, nil, 1)
+	assert.Equal(t, []int64{1000}, durations(to))
+
+	assert.Empty(t, mergeExemplars(exemplars(1, 2), exemplars(3), 0))
+}
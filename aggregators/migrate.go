@@ -0,0 +1,85 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"fmt"
+
+	"github.com/cockroachdb/pebble"
+	"go.uber.org/zap"
+)
+
+// migrateKeys upgrades the combined metrics keys written by older versions
+// of the library to the current version of the key encoding, see
+// combinedMetricsKeyVersion, so that the metrics which were not harvested
+// before the upgrade are harvested as usual. The metrics are merged into
+// the upgraded keys, which may already exist if a previous migration was
+// interrupted.
+func (a *Aggregator) migrateKeys() error {
+	var migrated int
+	for _, shard := range a.shards {
+		n, err := a.migrateShardKeys(shard)
+		migrated += n
+		if err != nil {
+			return err
+		}
+	}
+	if migrated > 0 {
+		a.cfg.Logger.Info(
+			"migrated combined metrics keys",
+			zap.Int("keys", migrated),
+			zap.Int("version", combinedMetricsKeyVersion),
+		)
+	}
+	return nil
+}
+
+func (a *Aggregator) migrateShardKeys(db *pebble.DB) (int, error) {
+	iter := db.NewIter(&pebble.IterOptions{
+		LowerBound: combinedMetricsLowerBound,
+		KeyTypes:   pebble.IterKeyTypePointsOnly,
+	})
+	defer iter.Close()
+
+	batch := db.NewBatch()
+	defer batch.Close()
+	var migrated int
+	for iter.First(); iter.Valid(); iter.Next() {
+		key := iter.Key()
+		if len(key) != legacyCombinedMetricsKeyEncodedSize {
+			continue
+		}
+		var cmk CombinedMetricsKey
+		if err := cmk.UnmarshalBinary(key); err != nil {
+			return migrated, fmt.Errorf("failed to unmarshal legacy combined metrics key: %w", err)
+		}
+		value := iter.Value()
+		op := batch.MergeDeferred(cmk.SizeBinary(), len(value))
+		if err := cmk.MarshalBinaryToSizedBuffer(op.Key); err != nil {
+			return migrated, fmt.Errorf("failed to marshal combined metrics key: %w", err)
+		}
+		copy(op.Value, value)
+		if err := op.Finish(); err != nil {
+			return migrated, fmt.Errorf("failed to finalize merge operation: %w", err)
+		}
+		if err := batch.Delete(key, nil); err != nil {
+			return migrated, fmt.Errorf("failed to delete legacy combined metrics key: %w", err)
+		}
+		migrated++
+		if batch.Len() >= dbCommitThresholdBytes {
+			if err := batch.Commit(a.writeOptions); err != nil {
+				return migrated, fmt.Errorf("failed to commit migrated keys: %w", err)
+			}
+			batch.Reset()
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return migrated, fmt.Errorf("failed to iterate combined metrics: %w", err)
+	}
+	if err := batch.Commit(a.writeOptions); err != nil {
+		return migrated, fmt.Errorf("failed to commit migrated keys: %w", err)
+	}
+	return migrated, nil
+}
@@ -0,0 +1,93 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+)
+
+func TestMigrateKeys(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	newAggregator := func() *Aggregator {
+		agg, err := New(
+			WithDataDir(dir),
+			WithLimits(Limits{
+				MaxServices:                           10,
+				MaxServiceInstanceGroupsPerService:    10,
+				MaxTransactionGroups:                  10,
+				MaxTransactionGroupsPerService:        10,
+				MaxServiceTransactionGroups:           10,
+				MaxServiceTransactionGroupsPerService: 10,
+				MaxSpanGroups:                         10,
+				MaxSpanGroupsPerService:               10,
+			}),
+			WithAggregationIntervals([]time.Duration{time.Minute}),
+			WithProcessor(noOpProcessor()),
+			WithLogger(zap.NewNop()),
+		)
+		require.NoError(t, err)
+		return agg
+	}
+
+	agg := newAggregator()
+	// The metrics are aggregated for a future processing time so that they
+	// are not harvested when the aggregator is closed.
+	cmk := CombinedMetricsKey{
+		Interval:       time.Minute,
+		ProcessingTime: agg.processingTime.Add(time.Hour),
+		ID:             EncodeToCombinedMetricsKeyID(t, "ab01"),
+	}
+	newMetrics := func(events float64) *aggregationpb.CombinedMetrics {
+		return NewTestCombinedMetrics(WithEventsTotal(events)).
+			AddServiceMetrics(serviceAggregationKey{Timestamp: cmk.ProcessingTime, ServiceName: "svc"}).
+			AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+			GetProto()
+	}
+	// Write combined metrics with a key without version, as written by
+	// older versions of the library, and with the current key version.
+	legacy := newMetrics(3)
+	b, err := legacy.MarshalVT()
+	require.NoError(t, err)
+	value, err := agg.codec.encode(b)
+	require.NoError(t, err)
+	key := make([]byte, CombinedMetricsKeyEncodedSize)
+	require.NoError(t, cmk.MarshalBinaryToSizedBuffer(key))
+	require.NoError(t, agg.db.Set(key[:legacyCombinedMetricsKeyEncodedSize], value, pebble.Sync))
+	require.NoError(t, agg.AggregateCombinedMetrics(ctx, cmk, newMetrics(2)))
+	require.NoError(t, agg.Close(ctx))
+
+	agg = newAggregator()
+	t.Cleanup(func() { agg.Close(ctx) })
+	iter := agg.db.NewIter(&pebble.IterOptions{LowerBound: combinedMetricsLowerBound})
+	var keys [][]byte
+	for iter.First(); iter.Valid(); iter.Next() {
+		keys = append(keys, append([]byte(nil), iter.Key()...))
+	}
+	require.NoError(t, iter.Close())
+	assert.Equal(t, [][]byte{key}, keys)
+
+	it, err := agg.NewIterator(IteratorOptions{})
+	require.NoError(t, err)
+	defer it.Close()
+	require.True(t, it.First())
+	assert.Equal(t, cmk, it.Key())
+	var cm aggregationpb.CombinedMetrics
+	require.NoError(t, it.Value(&cm))
+	assert.Equal(t, float64(5), cm.EventsTotal)
+	assert.False(t, it.Next())
+
+	// Migrating again is a no-op.
+	require.NoError(t, agg.migrateKeys())
+}
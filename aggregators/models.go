@@ -28,6 +28,29 @@ type Limits struct {
 	// unique ServiceInstanceAggregationKey.
 	MaxServiceInstanceGroupsPerService int
 
+	// MaxServiceInstanceGroups is the limit on the total number of unique
+	// service instance groups across all services. A limit of 0 disables
+	// the global limit, leaving service instance groups limited only by
+	// MaxServices and MaxServiceInstanceGroupsPerService.
+	// A unique service instance group is identified by a unique
+	// ServiceAggregationKey + ServiceInstanceAggregationKey.
+	MaxServiceInstanceGroups int
+
+	// MaxGlobalLabelsPerService is the limit on the number of unique global
+	// label sets tracked within a service. Once the limit is reached,
+	// service instance groups with new global label sets are aggregated
+	// without the global labels, protecting against agents sending volatile
+	// global labels. A limit of 0 disables the limit.
+	MaxGlobalLabelsPerService int
+
+	// MaxKubernetesWorkloadsPerService is the limit on the number of unique
+	// Kubernetes namespace and deployment name combinations tracked per
+	// service, see WithKubernetesDimensions. A service is identified by the
+	// ServiceAggregationKey excluding the Kubernetes dimensions. Once the
+	// limit is reached, services with new combinations are aggregated
+	// without the Kubernetes dimensions. A limit of 0 disables the limit.
+	MaxKubernetesWorkloadsPerService int
+
 	// MaxSpanGroups is the limit on total number of unique span groups
 	// across all services.
 	// A unique span group is identified by a unique
@@ -40,6 +63,14 @@ type Limits struct {
 	// SpanAggregationKey.
 	MaxSpanGroupsPerService int
 
+	// MaxSpanNamePerDestination is the limit on the number of unique span
+	// names tracked for a single destination within a service instance.
+	// A destination is identified by the SpanAggregationKey excluding the
+	// span name. Once the limit is reached, span groups with new span names
+	// for the destination are aggregated without the span name. A limit of
+	// 0 disables the per destination limit.
+	MaxSpanNamePerDestination int
+
 	// MaxTransactionGroups is the limit on total number of unique
 	// transaction groups across all services.
 	// A unique transaction group is identified by a unique
@@ -52,6 +83,27 @@ type Limits struct {
 	// TransactionAggregationKey.
 	MaxTransactionGroupsPerService int
 
+	// MaxFaasIDsPerService is the limit on the number of unique FaaS IDs
+	// tracked in the transaction groups within a service. Once the limit is
+	// reached, transaction groups with new FaaS IDs are aggregated without
+	// the FaaS ID, protecting against high cardinality function IDs such as
+	// versioned function ARNs. A limit of 0 disables the limit.
+	MaxFaasIDsPerService int
+
+	// MaxServiceVersionsPerService is the limit on the number of unique
+	// service versions, i.e. app versions of mobile services, tracked in
+	// the transaction groups within a service. Once the limit is reached,
+	// transaction groups with new service versions are aggregated without
+	// the service version. A limit of 0 disables the limit.
+	MaxServiceVersionsPerService int
+
+	// MaxHostOSVersionsPerService is the limit on the number of unique host
+	// OS versions tracked in the transaction groups within a service, see
+	// WithMobileDimensions. Once the limit is reached, transaction groups
+	// with new OS versions are aggregated without the OS version. A limit
+	// of 0 disables the limit.
+	MaxHostOSVersionsPerService int
+
 	// MaxServiceTransactionGroups is the limit on total number of unique
 	// service transaction groups across all services.
 	// A unique service transaction group is identified by a unique
@@ -63,6 +115,32 @@ type Limits struct {
 	// A unique service transaction group within a service is identified
 	// by a unique ServiceTransactionAggregationKey.
 	MaxServiceTransactionGroupsPerService int
+
+	// MaxErrorGroups is the limit on total number of unique error groups
+	// across all services. Error events are only aggregated if the limit
+	// is greater than zero.
+	// A unique error group is identified by a unique
+	// ServiceAggregationKey + ServiceInstanceAggregationKey + ErrorAggregationKey.
+	MaxErrorGroups int
+
+	// MaxErrorGroupsPerService is the limit on the number of unique error
+	// groups within a service.
+	// A unique error group within a service is identified by a unique
+	// ErrorAggregationKey.
+	MaxErrorGroupsPerService int
+
+	// MaxServiceGraphEdges is the limit on total number of unique service
+	// graph edges across all services. Service graph edges are only
+	// aggregated if the limit is greater than zero.
+	// A unique service graph edge is identified by a unique
+	// ServiceAggregationKey + ServiceInstanceAggregationKey + ServiceGraphEdgeAggregationKey.
+	MaxServiceGraphEdges int
+
+	// MaxServiceGraphEdgesPerService is the limit on the number of unique
+	// service graph edges within a service.
+	// A unique service graph edge within a service is identified by a
+	// unique ServiceGraphEdgeAggregationKey.
+	MaxServiceGraphEdgesPerService int
 }
 
 // CombinedMetricsKey models the key to store the data in LSM tree.
@@ -84,6 +162,47 @@ type GlobalLabels struct {
 	NumericLabels modelpb.NumericLabels
 }
 
+// MaxCustomDimensions is the maximum number of custom dimensions of an
+// aggregation key. Additional dimensions set by a key extractor are
+// ignored.
+const MaxCustomDimensions = 8
+
+// MaxCustomDimensionValueLength is the maximum length of the value of a
+// custom dimension. Longer values are truncated.
+const MaxCustomDimensionValueLength = 1024
+
+// KeySet holds the custom dimensions added by a key extractor to the
+// service, transaction and span aggregation keys of an event.
+type KeySet struct {
+	Service     CustomDimensions
+	Transaction CustomDimensions
+	Span        CustomDimensions
+}
+
+// CustomDimensions holds up to MaxCustomDimensions custom dimensions of
+// an aggregation key. The custom dimensions are added as labels to the
+// aggregated metrics.
+type CustomDimensions struct {
+	dims map[string]string
+}
+
+// Set sets the custom dimension with the given key to the value. Set
+// returns false, without setting the dimension, if MaxCustomDimensions
+// other dimensions are already set.
+func (d *CustomDimensions) Set(key, value string) bool {
+	if _, ok := d.dims[key]; !ok && len(d.dims) >= MaxCustomDimensions {
+		return false
+	}
+	if d.dims == nil {
+		d.dims = make(map[string]string, MaxCustomDimensions)
+	}
+	if len(value) > MaxCustomDimensionValueLength {
+		value = value[:MaxCustomDimensionValueLength]
+	}
+	d.dims[key] = value
+	return true
+}
+
 // combinedMetrics models the value to store the data in LSM tree.
 // Each unique combined metrics ID stores a combined metrics per aggregation
 // interval. combinedMetrics encapsulates the aggregated metrics
@@ -110,6 +229,11 @@ type combinedMetrics struct {
 	// YoungestEventTimestamp is the youngest event that was aggregated
 	// in the combined metrics based on the received timestamp.
 	YoungestEventTimestamp uint64
+
+	// kubernetesWorkloads counts the services with Kubernetes dimensions
+	// per service key without them. It is built from the services on first
+	// use and updated as services are added.
+	kubernetesWorkloads map[serviceAggregationKey]int
 }
 
 // serviceAggregationKey models the key used to store service specific
@@ -120,6 +244,10 @@ type serviceAggregationKey struct {
 	ServiceEnvironment  string
 	ServiceLanguageName string
 	AgentName           string
+	CustomDimensions    string
+
+	KubernetesNamespace      string
+	KubernetesDeploymentName string
 }
 
 // serviceMetrics models the value to store all the aggregated metrics
@@ -127,20 +255,52 @@ type serviceAggregationKey struct {
 type serviceMetrics struct {
 	ServiceInstanceGroups map[serviceInstanceAggregationKey]serviceInstanceMetrics
 	OverflowGroups        overflow
+
+	// globalLabelSets holds the unique global label sets of the service
+	// instance groups. It is built from the groups on first use and
+	// updated as groups are added.
+	globalLabelSets map[string]struct{}
 }
 
 // serviceInstanceAggregationKey models the key used to store service instance specific
 // aggregation metrics.
 type serviceInstanceAggregationKey struct {
-	GlobalLabelsStr string
+	GlobalLabelsStr   string
+	HostName          string
+	ContainerID       string
+	KubernetesPodName string
 }
 
 // serviceInstanceMetrics models the value to store all the aggregated metrics
 // for a specific service instance aggregation key.
 type serviceInstanceMetrics struct {
+	// GlobalLabelsStr holds the serialized global labels if the service
+	// instance key holds a hash of the global labels, see
+	// WithHashedGlobalLabels.
+	GlobalLabelsStr string
+
 	TransactionGroups        map[transactionAggregationKey]*aggregationpb.KeyedTransactionMetrics
 	ServiceTransactionGroups map[serviceTransactionAggregationKey]*aggregationpb.KeyedServiceTransactionMetrics
 	SpanGroups               map[spanAggregationKey]*aggregationpb.KeyedSpanMetrics
+	ErrorGroups              map[errorAggregationKey]*aggregationpb.KeyedErrorMetrics
+	ServiceGraphEdgeGroups   map[serviceGraphEdgeAggregationKey]*aggregationpb.KeyedServiceGraphEdgeMetrics
+
+	// spanDestinationNames tracks the span names per destination of the
+	// span groups, see Limits.MaxSpanNamePerDestination.
+	spanDestinationNames spanDestinationNames
+	// transactionDimensionValues tracks the values of the transaction
+	// dimensions limited per service, see transactionDimensionLimits.
+	transactionDimensionValues transactionDimensionValues
+
+	// lowestTransactions, lowestServiceTransactions and lowestSpans order
+	// the groups by throughput for the top-K retention.
+	lowestTransactions        lowestTransactionGroups
+	lowestServiceTransactions lowestServiceTransactionGroups
+	lowestSpans               lowestSpanGroups
+	// lowestErrors and lowestServiceGraphEdges order the error groups and
+	// the service graph edges by count for the top-K retention.
+	lowestErrors            lowestErrorGroups
+	lowestServiceGraphEdges lowestServiceGraphEdges
 }
 
 func insertHash(to **hyperloglog.Sketch, hash uint64) {
@@ -159,6 +319,9 @@ func mergeEstimator(to **hyperloglog.Sketch, from *hyperloglog.Sketch) {
 	(*to).Merge(from)
 }
 
+// Overflow buckets do not retain exemplars, as the exemplars of the groups
+// merged into an overflow bucket are not representative of the bucket.
+
 type overflowTransaction struct {
 	Metrics   *aggregationpb.TransactionMetrics
 	Estimator *hyperloglog.Sketch
@@ -171,7 +334,7 @@ func (o *overflowTransaction) Merge(
 	if o.Metrics == nil {
 		o.Metrics = aggregationpb.TransactionMetricsFromVTPool()
 	}
-	mergeTransactionMetrics(o.Metrics, from)
+	mergeTransactionMetrics(o.Metrics, from, 0)
 	insertHash(&o.Estimator, hash)
 }
 
@@ -180,7 +343,7 @@ func (o *overflowTransaction) MergeOverflow(from *overflowTransaction) {
 		if o.Metrics == nil {
 			o.Metrics = aggregationpb.TransactionMetricsFromVTPool()
 		}
-		mergeTransactionMetrics(o.Metrics, from.Metrics)
+		mergeTransactionMetrics(o.Metrics, from.Metrics, 0)
 		mergeEstimator(&o.Estimator, from.Estimator)
 	}
 }
@@ -231,7 +394,7 @@ func (o *overflowSpan) Merge(
 	if o.Metrics == nil {
 		o.Metrics = aggregationpb.SpanMetricsFromVTPool()
 	}
-	mergeSpanMetrics(o.Metrics, from)
+	mergeSpanMetrics(o.Metrics, from, 0)
 	insertHash(&o.Estimator, hash)
 }
 
@@ -240,7 +403,7 @@ func (o *overflowSpan) MergeOverflow(from *overflowSpan) {
 		if o.Metrics == nil {
 			o.Metrics = aggregationpb.SpanMetricsFromVTPool()
 		}
-		mergeSpanMetrics(o.Metrics, from.Metrics)
+		mergeSpanMetrics(o.Metrics, from.Metrics, 0)
 		mergeEstimator(&o.Estimator, from.Estimator)
 	}
 }
@@ -249,12 +412,79 @@ func (o *overflowSpan) Empty() bool {
 	return o.Estimator == nil
 }
 
-// overflow contains transaction and spans overflow metrics and cardinality
-// estimators for the aggregation group for overflow buckets.
+type overflowError struct {
+	Metrics   *aggregationpb.ErrorMetrics
+	Estimator *hyperloglog.Sketch
+}
+
+func (o *overflowError) Merge(
+	from *aggregationpb.ErrorMetrics,
+	hash uint64,
+) {
+	if o.Metrics == nil {
+		o.Metrics = aggregationpb.ErrorMetricsFromVTPool()
+	}
+	mergeErrorMetrics(o.Metrics, from)
+	insertHash(&o.Estimator, hash)
+}
+
+func (o *overflowError) MergeOverflow(from *overflowError) {
+	if from.Estimator != nil {
+		if o.Metrics == nil {
+			o.Metrics = aggregationpb.ErrorMetricsFromVTPool()
+		}
+		mergeErrorMetrics(o.Metrics, from.Metrics)
+		mergeEstimator(&o.Estimator, from.Estimator)
+	}
+}
+
+func (o *overflowError) Empty() bool {
+	return o.Estimator == nil
+}
+
+type overflowServiceGraphEdge struct {
+	Metrics   *aggregationpb.ServiceGraphEdgeMetrics
+	Estimator *hyperloglog.Sketch
+}
+
+func (o *overflowServiceGraphEdge) Merge(
+	from *aggregationpb.ServiceGraphEdgeMetrics,
+	hash uint64,
+) {
+	if o.Metrics == nil {
+		o.Metrics = aggregationpb.ServiceGraphEdgeMetricsFromVTPool()
+	}
+	mergeServiceGraphEdgeMetrics(o.Metrics, from)
+	insertHash(&o.Estimator, hash)
+}
+
+func (o *overflowServiceGraphEdge) MergeOverflow(from *overflowServiceGraphEdge) {
+	if from.Estimator != nil {
+		if o.Metrics == nil {
+			o.Metrics = aggregationpb.ServiceGraphEdgeMetricsFromVTPool()
+		}
+		mergeServiceGraphEdgeMetrics(o.Metrics, from.Metrics)
+		mergeEstimator(&o.Estimator, from.Estimator)
+	}
+}
+
+func (o *overflowServiceGraphEdge) Empty() bool {
+	return o.Estimator == nil
+}
+
+// overflow contains transaction, spans, errors and service graph edges
+// overflow metrics and cardinality estimators for the aggregation group
+// for overflow buckets.
 type overflow struct {
 	OverflowTransaction        overflowTransaction
 	OverflowServiceTransaction overflowServiceTransaction
 	OverflowSpan               overflowSpan
+	OverflowError              overflowError
+	OverflowServiceGraphEdge   overflowServiceGraphEdge
+
+	// GlobalLabelsEstimator estimates the number of unique global label
+	// sets dropped due to the max global labels per service limit.
+	GlobalLabelsEstimator *hyperloglog.Sketch
 }
 
 // transactionAggregationKey models the key used to store transaction
@@ -275,6 +505,10 @@ type transactionAggregationKey struct {
 	HostHostname   string
 	HostName       string
 	HostOSPlatform string
+	HostOSName     string
+	HostOSVersion  string
+
+	NetworkConnectionType string
 
 	EventOutcome string
 
@@ -297,6 +531,8 @@ type transactionAggregationKey struct {
 	CloudMachineType      string
 	CloudProjectID        string
 	CloudProjectName      string
+
+	CustomDimensions string
 }
 
 // spanAggregationKey models the key used to store span aggregation metrics.
@@ -308,6 +544,10 @@ type spanAggregationKey struct {
 	TargetName string
 
 	Resource string
+
+	SpanSubtype string
+
+	CustomDimensions string
 }
 
 // serviceTransactionAggregationKey models the key used to store
@@ -315,3 +555,19 @@ type spanAggregationKey struct {
 type serviceTransactionAggregationKey struct {
 	TransactionType string
 }
+
+// errorAggregationKey models the key used to store error aggregation
+// metrics.
+type errorAggregationKey struct {
+	GroupingKey string
+	Outcome     string
+}
+
+// serviceGraphEdgeAggregationKey models the key used to store service
+// graph edge aggregation metrics.
+type serviceGraphEdgeAggregationKey struct {
+	TargetType string
+	TargetName string
+
+	Resource string
+}
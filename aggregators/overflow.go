@@ -0,0 +1,110 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+)
+
+// overflowLimitKey is the attribute key of the aggregator.overflow.groups
+// metric identifying the limit which caused the groups to overflow.
+const overflowLimitKey = "limit"
+
+// overflowLimit is the type of limit causing groups to be folded into the
+// overflow buckets.
+type overflowLimit int
+
+const (
+	// serviceInstanceGroupsOverflow counts the service instance groups
+	// folded into the overflow service due to the services or the service
+	// instance groups limits.
+	serviceInstanceGroupsOverflow overflowLimit = iota
+	globalLabelsOverflow
+	transactionGroupsOverflow
+	serviceTransactionGroupsOverflow
+	spanGroupsOverflow
+	errorGroupsOverflow
+	serviceGraphEdgesOverflow
+
+	numOverflowLimits
+)
+
+var overflowLimitNames = [numOverflowLimits]string{
+	serviceInstanceGroupsOverflow:    "service_instance_groups",
+	globalLabelsOverflow:             "global_labels",
+	transactionGroupsOverflow:        "transaction_groups",
+	serviceTransactionGroupsOverflow: "service_transaction_groups",
+	spanGroupsOverflow:               "span_groups",
+	errorGroupsOverflow:              "error_groups",
+	serviceGraphEdgesOverflow:        "service_graph_edges",
+}
+
+// overflowCounts holds the estimated number of groups of a harvested
+// combined metrics folded into overflow, per limit.
+type overflowCounts [numOverflowLimits]uint64
+
+// add adds the estimated overflow groups of the combined metrics. The
+// groups of overflowed services are counted by their group type.
+func (c *overflowCounts) add(cm *aggregationpb.CombinedMetrics) {
+	c[serviceInstanceGroupsOverflow] += estimate(cm.OverflowServiceInstancesEstimator)
+	c.addOverflow(cm.OverflowServices)
+	for _, ksm := range cm.ServiceMetrics {
+		if ksm.Metrics != nil {
+			c.addOverflow(ksm.Metrics.OverflowGroups)
+		}
+	}
+}
+
+func (c *overflowCounts) addOverflow(o *aggregationpb.Overflow) {
+	if o == nil {
+		return
+	}
+	c[globalLabelsOverflow] += estimate(o.OverflowGlobalLabelsEstimator)
+	c[transactionGroupsOverflow] += estimate(o.OverflowTransactionsEstimator)
+	c[serviceTransactionGroupsOverflow] += estimate(o.OverflowServiceTransactionsEstimator)
+	c[spanGroupsOverflow] += estimate(o.OverflowSpansEstimator)
+	c[errorGroupsOverflow] += estimate(o.OverflowErrorsEstimator)
+	c[serviceGraphEdgesOverflow] += estimate(o.OverflowServiceGraphEdgesEstimator)
+}
+
+// record records the non-zero overflow counts with the given attributes
+// and the limit attribute.
+func (c *overflowCounts) record(ctx context.Context, counter metric.Int64Counter, attrs []attribute.KeyValue) {
+	for limit, n := range c {
+		if n == 0 {
+			continue
+		}
+		limitAttrs := make([]attribute.KeyValue, 0, len(attrs)+1)
+		limitAttrs = append(limitAttrs, attrs...)
+		limitAttrs = append(limitAttrs, attribute.String(overflowLimitKey, overflowLimitNames[limit]))
+		counter.Add(ctx, int64(n), metric.WithAttributeSet(attribute.NewSet(limitAttrs...)))
+	}
+}
+
+// logFields returns the log fields of the non-zero overflow counts, keyed
+// by the limit names.
+func (c *overflowCounts) logFields() []zap.Field {
+	var fields []zap.Field
+	for limit, n := range c {
+		if n > 0 {
+			fields = append(fields, zap.Uint64(overflowLimitNames[limit], n))
+		}
+	}
+	return fields
+}
+
+// estimate returns the estimated cardinality of the encoded estimator.
+func estimate(estimator []byte) uint64 {
+	if sketch := hllSketch(estimator); sketch != nil {
+		return sketch.Estimate()
+	}
+	return 0
+}
@@ -0,0 +1,97 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap"
+)
+
+func TestOverflowGroupsMetric(t *testing.T) {
+	ctx := context.Background()
+	ivl := time.Minute
+	rdr := metric.NewManualReader()
+	agg, err := New(
+		WithDataDir(t.TempDir()),
+		WithLimits(Limits{
+			MaxServices:                           1,
+			MaxServiceInstanceGroupsPerService:    1,
+			MaxTransactionGroups:                  10,
+			MaxTransactionGroupsPerService:        1,
+			MaxServiceTransactionGroups:           10,
+			MaxServiceTransactionGroupsPerService: 10,
+			MaxSpanGroups:                         10,
+			MaxSpanGroupsPerService:               10,
+		}),
+		WithAggregationIntervals([]time.Duration{ivl}),
+		WithMeter(metric.NewMeterProvider(metric.WithReader(rdr)).Meter("test")),
+		WithLogger(zap.NewNop()),
+	)
+	require.NoError(t, err)
+	defer agg.Close(ctx)
+
+	start := time.Unix(agg.processingTime.Unix(), 0)
+	cmk := CombinedMetricsKey{
+		Interval:       ivl,
+		ProcessingTime: start,
+		ID:             EncodeToCombinedMetricsKeyID(t, "ab01"),
+	}
+	tcm := NewTestCombinedMetrics(WithEventsTotal(5))
+	// svc1 is within the services limit, 2 of its 3 transaction groups
+	// overflow the transaction groups per service limit.
+	svc1 := tcm.AddServiceMetrics(serviceAggregationKey{Timestamp: start, ServiceName: "svc1"}).
+		AddServiceInstanceMetrics(serviceInstanceAggregationKey{})
+	for i := 0; i < 3; i++ {
+		svc1.AddTransaction(transactionAggregationKey{
+			TransactionName: fmt.Sprintf("txn%d", i),
+			TransactionType: "typ",
+		})
+	}
+	// svc2 overflows the services limit.
+	tcm.AddServiceMetrics(serviceAggregationKey{Timestamp: start, ServiceName: "svc2"}).
+		AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+		AddTransaction(transactionAggregationKey{TransactionName: "txn", TransactionType: "typ"})
+	cm := tcm.GetProto()
+	require.NoError(t, agg.AggregateCombinedMetrics(ctx, cmk, cm))
+	cm.ReturnToVTPool()
+
+	agg.mu.Lock()
+	batch := agg.takeBatches()
+	agg.mu.Unlock()
+	require.NoError(t, agg.commitAndHarvest(ctx, batch, start.Add(ivl), []time.Duration{ivl}, nil))
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, rdr.Collect(ctx, &rm))
+	overflow := make(map[string]int64)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "aggregator.overflow.groups" {
+				continue
+			}
+			for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				limit, ok := dp.Attributes.Value(attribute.Key(overflowLimitKey))
+				require.True(t, ok)
+				ivlAttr, ok := dp.Attributes.Value(attribute.Key(aggregationIvlKey))
+				require.True(t, ok)
+				assert.Equal(t, "1m", ivlAttr.AsString())
+				overflow[limit.AsString()] += dp.Value
+			}
+		}
+	}
+	assert.Equal(t, map[string]int64{
+		"service_instance_groups": 1,
+		// 2 groups of svc1 and the group of the overflowed svc2.
+		"transaction_groups": 3,
+	}, overflow)
+}
@@ -0,0 +1,110 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// overflowNotificationRate and overflowNotificationBurst rate limit
+	// the overflow notifications across all IDs, bounding the cost of
+	// the notifier when many IDs hit their limits at the same time.
+	overflowNotificationRate  = rate.Limit(10)
+	overflowNotificationBurst = 100
+)
+
+// OverflowEvent notifies that the combined metrics of an ID hit an
+// aggregation limit for the first time in an aggregation period, see
+// WithOverflowNotifier.
+type OverflowEvent struct {
+	ID       [16]byte
+	Interval time.Duration
+	// ProcessingTime is the processing time of the aggregation period.
+	ProcessingTime time.Time
+
+	// Limit is the name of the limit which was hit, e.g. span_groups.
+	// The names match the limit attribute of the aggregator.overflow.groups
+	// metric.
+	Limit string
+	// KeySample is a sample of the offending aggregation keys: the name
+	// of the service whose groups were the first to be folded into the
+	// overflow buckets.
+	KeySample string
+}
+
+// overflowNotifier notifies the configured notifier of the first overflow
+// of every limit by the combined metrics of an ID in an aggregation
+// period, rate limiting the notifications.
+type overflowNotifier struct {
+	notify  func(OverflowEvent)
+	limiter *rate.Limiter
+
+	mu sync.Mutex
+	// notified holds the processing time of the last period notified
+	// per ID, interval and limit.
+	notified map[overflowNotificationKey]time.Time
+}
+
+type overflowNotificationKey struct {
+	id       [16]byte
+	interval time.Duration
+	limit    overflowLimit
+}
+
+// newOverflowNotifier returns the overflow notifier for the config, or nil
+// if no notifier is configured.
+func newOverflowNotifier(cfg Config) *overflowNotifier {
+	if cfg.OverflowNotifier == nil {
+		return nil
+	}
+	return &overflowNotifier{
+		notify:   cfg.OverflowNotifier,
+		limiter:  rate.NewLimiter(overflowNotificationRate, overflowNotificationBurst),
+		notified: make(map[overflowNotificationKey]time.Time),
+	}
+}
+
+// overflowed notifies the overflow of the limit by the combined metrics
+// of the key, unless the limit was already notified for the period of the
+// key or the notifications are rate limited.
+func (n *overflowNotifier) overflowed(cmk CombinedMetricsKey, limit overflowLimit, keySample string) {
+	key := overflowNotificationKey{id: cmk.ID, interval: cmk.Interval, limit: limit}
+	n.mu.Lock()
+	if last, ok := n.notified[key]; ok && !cmk.ProcessingTime.After(last) {
+		n.mu.Unlock()
+		return
+	}
+	if !n.limiter.Allow() {
+		n.mu.Unlock()
+		return
+	}
+	n.notified[key] = cmk.ProcessingTime
+	n.mu.Unlock()
+
+	n.notify(OverflowEvent{
+		ID:             cmk.ID,
+		Interval:       cmk.Interval,
+		ProcessingTime: cmk.ProcessingTime,
+		Limit:          overflowLimitNames[limit],
+		KeySample:      keySample,
+	})
+}
+
+// overflowedLimits returns the limits for which groups were folded into
+// the overflow buckets.
+func overflowedLimits(o *overflow) [numOverflowLimits]bool {
+	var limits [numOverflowLimits]bool
+	limits[globalLabelsOverflow] = o.GlobalLabelsEstimator != nil
+	limits[transactionGroupsOverflow] = !o.OverflowTransaction.Empty()
+	limits[serviceTransactionGroupsOverflow] = !o.OverflowServiceTransaction.Empty()
+	limits[spanGroupsOverflow] = !o.OverflowSpan.Empty()
+	limits[errorGroupsOverflow] = !o.OverflowError.Empty()
+	limits[serviceGraphEdgesOverflow] = !o.OverflowServiceGraphEdge.Empty()
+	return limits
+}
@@ -0,0 +1,94 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/apm-data/model/modelpb"
+)
+
+func TestOverflowNotifier(t *testing.T) {
+	var mu sync.Mutex
+	var events []OverflowEvent
+	cmID := EncodeToCombinedMetricsKeyID(t, "ab01")
+	agg, err := New(
+		WithDataDir(t.TempDir()),
+		WithLimits(Limits{
+			MaxSpanGroups:                         10,
+			MaxSpanGroupsPerService:               10,
+			MaxTransactionGroups:                  10,
+			MaxTransactionGroupsPerService:        10,
+			MaxServiceTransactionGroups:           10,
+			MaxServiceTransactionGroupsPerService: 10,
+			MaxServices:                           1,
+			MaxServiceInstanceGroupsPerService:    10,
+		}),
+		WithProcessor(noOpProcessor()),
+		WithAggregationIntervals([]time.Duration{time.Minute}),
+		WithHarvestDelay(time.Hour), // disable auto harvest
+		WithOverflowNotifier(func(e OverflowEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, e)
+		}),
+	)
+	require.NoError(t, err)
+
+	ts := time.Now()
+	for _, svc := range []string{"svc1", "svc2", "svc3"} {
+		event := lateTestEvent(ts)
+		event.Service.Name = svc
+		batch := modelpb.Batch{event}
+		require.NoError(t, agg.AggregateBatch(context.Background(), cmID, &batch))
+	}
+	require.NoError(t, agg.Close(context.Background()))
+
+	mu.Lock()
+	defer mu.Unlock()
+	// The limit is notified once for the period, regardless of the number
+	// of merges and overflowed services.
+	require.Len(t, events, 1)
+	assert.Equal(t, cmID, events[0].ID)
+	assert.Equal(t, time.Minute, events[0].Interval)
+	assert.Equal(t, ts.Truncate(time.Minute), events[0].ProcessingTime)
+	assert.Equal(t, "service_instance_groups", events[0].Limit)
+	assert.Contains(t, []string{"svc1", "svc2", "svc3"}, events[0].KeySample)
+}
+
+func TestOverflowNotifierPeriods(t *testing.T) {
+	var events []OverflowEvent
+	notifier := newOverflowNotifier(Config{OverflowNotifier: func(e OverflowEvent) {
+		events = append(events, e)
+	}})
+	cmk := CombinedMetricsKey{
+		Interval:       time.Minute,
+		ProcessingTime: time.Unix(0, 0).Add(time.Hour),
+		ID:             EncodeToCombinedMetricsKeyID(t, "ab01"),
+	}
+	notifier.overflowed(cmk, spanGroupsOverflow, "svc")
+	notifier.overflowed(cmk, spanGroupsOverflow, "svc")
+	notifier.overflowed(cmk, errorGroupsOverflow, "svc")
+	previous := cmk
+	previous.ProcessingTime = cmk.ProcessingTime.Add(-time.Minute)
+	notifier.overflowed(previous, spanGroupsOverflow, "svc")
+	next := cmk
+	next.ProcessingTime = cmk.ProcessingTime.Add(time.Minute)
+	notifier.overflowed(next, spanGroupsOverflow, "svc")
+
+	require.Len(t, events, 3)
+	assert.Equal(t, "span_groups", events[0].Limit)
+	assert.Equal(t, "error_groups", events[1].Limit)
+	assert.Equal(t, "span_groups", events[2].Limit)
+	assert.Equal(t, next.ProcessingTime, events[2].ProcessingTime)
+
+	assert.Nil(t, newOverflowNotifier(Config{}))
+}
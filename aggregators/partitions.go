@@ -0,0 +1,70 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"math"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// partitionsFor returns the number of partitions of the combined metrics
+// of the ID for the aggregation interval. Must be called with the lock
+// held.
+func (a *Aggregator) partitionsFor(ivl time.Duration, id [16]byte) uint16 {
+	if n, ok := a.partitions[ivl][id]; ok {
+		return n
+	}
+	return a.cfg.Partitions
+}
+
+// adaptPartitions sets the number of partitions of the IDs for the next
+// periods of the aggregation intervals whose period ends at the given time,
+// from the number of events aggregated per ID in the lowest aggregation
+// interval of the previous period, see WithAdaptivePartitions. IDs without
+// events in the previous period fall back to the configured partitions.
+//
+// The number of partitions of an interval only changes at the period
+// boundaries of the interval so that an aggregation key is routed to the
+// same partition for the whole period. With interval rollups, the higher
+// intervals are derived from the partitions of the lowest interval, all
+// the intervals are thus only adapted at the period boundaries of the
+// highest interval. The partitions are adapted once per end time, a
+// harvest retried after a crash keeps the adapted partitions. Must be
+// called with the lock held.
+func (a *Aggregator) adaptPartitions(end time.Time, events map[[16]byte]float64) {
+	ivls := a.cfg.AggregationIntervals
+	if a.cfg.IntervalRollups && !end.Truncate(ivls[len(ivls)-1]).Equal(end) {
+		return
+	}
+	if !end.After(a.partitionsAdaptedAt) {
+		return
+	}
+	a.partitionsAdaptedAt = end
+	partitions := make(map[[16]byte]uint16)
+	for id, n := range events {
+		p := math.Ceil(n / float64(a.cfg.EventsPerPartition))
+		if p <= float64(a.cfg.Partitions) {
+			continue
+		}
+		partitions[id] = uint16(math.Min(p, float64(a.cfg.MaxAdaptivePartitions)))
+	}
+	if a.partitions == nil {
+		a.partitions = make(map[time.Duration]map[[16]byte]uint16, len(ivls))
+	}
+	for _, ivl := range ivls {
+		if !end.Truncate(ivl).Equal(end) {
+			continue
+		}
+		if len(partitions) > 0 || len(a.partitions[ivl]) > 0 {
+			a.cfg.Logger.Debug("adapted partitions",
+				zap.Duration("interval", ivl),
+				zap.Int("adapted_ids", len(partitions)),
+				zap.Int("previously_adapted_ids", len(a.partitions[ivl])))
+		}
+		a.partitions[ivl] = partitions
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/elastic/apm-data/model/modelpb"
+)
+
+func TestAdaptivePartitions(t *testing.T) {
+	ctx := context.Background()
+	ivl := time.Minute
+	hotID := EncodeToCombinedMetricsKeyID(t, "ab01")
+	idleID := EncodeToCombinedMetricsKeyID(t, "ab02")
+	agg, err := New(
+		WithDataDir(t.TempDir()),
+		WithLimits(Limits{
+			MaxServices:                           10,
+			MaxServiceInstanceGroupsPerService:    10,
+			MaxTransactionGroups:                  100,
+			MaxTransactionGroupsPerService:        100,
+			MaxServiceTransactionGroups:           10,
+			MaxServiceTransactionGroupsPerService: 10,
+			MaxSpanGroups:                         10,
+			MaxSpanGroupsPerService:               10,
+		}),
+		WithAggregationIntervals([]time.Duration{ivl}),
+		WithPartitions(1),
+		WithAdaptivePartitions(4, 2),
+		WithProcessor(noOpProcessor()),
+		WithLogger(zap.NewNop()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { agg.Close(ctx) })
+
+	start := time.Unix(agg.processingTime.Unix(), 0).Truncate(ivl)
+	agg.mu.Lock()
+	agg.processingTime = start
+	agg.mu.Unlock()
+	aggregate := func(id [16]byte, n int) {
+		batch := make(modelpb.Batch, n)
+		for i := range batch {
+			batch[i] = lateTestEvent(agg.processingTime)
+			batch[i].Transaction.Name = fmt.Sprintf("txn%d", i)
+		}
+		require.NoError(t, agg.AggregateBatch(ctx, id, &batch))
+	}
+	harvest := func(end time.Time) {
+		agg.mu.Lock()
+		batch := agg.takeBatches()
+		agg.processingTime = end
+		agg.mu.Unlock()
+		require.NoError(t, agg.commitAndHarvest(
+			ctx, batch, end, []time.Duration{ivl}, agg.cachedEvents.loadAndDelete(end),
+		))
+	}
+	storedPartitions := func(id [16]byte) map[uint16]struct{} {
+		agg.mu.Lock()
+		batch := agg.takeBatches()
+		agg.mu.Unlock()
+		if batch != nil {
+			require.NoError(t, agg.commitBatch(batch))
+			require.NoError(t, batch.close())
+		}
+		partitions := make(map[uint16]struct{})
+		for _, shard := range agg.shards {
+			iter := shard.NewIter(&pebble.IterOptions{LowerBound: combinedMetricsLowerBound})
+			for iter.First(); iter.Valid(); iter.Next() {
+				var cmk CombinedMetricsKey
+				require.NoError(t, cmk.UnmarshalBinary(iter.Key()))
+				if cmk.ID == id {
+					partitions[cmk.PartitionID] = struct{}{}
+				}
+			}
+			require.NoError(t, iter.Close())
+		}
+		return partitions
+	}
+
+	aggregate(hotID, 5)
+	aggregate(idleID, 1)
+	assert.Equal(t, map[uint16]struct{}{0: {}}, storedPartitions(hotID))
+	harvest(start.Add(ivl))
+
+	// The hot ID is spread over 3 partitions, one per 2 events of the
+	// previous period, the idle ID keeps the configured partitions.
+	agg.mu.Lock()
+	assert.Equal(t, uint16(3), agg.partitionsFor(ivl, hotID))
+	assert.Equal(t, uint16(1), agg.partitionsFor(ivl, idleID))
+	agg.mu.Unlock()
+	aggregate(hotID, 20)
+	aggregate(idleID, 1)
+	partitions := storedPartitions(hotID)
+	assert.Greater(t, len(partitions), 1)
+	for p := range partitions {
+		assert.Less(t, p, uint16(3))
+	}
+	assert.Equal(t, map[uint16]struct{}{0: {}}, storedPartitions(idleID))
+	harvest(start.Add(2 * ivl))
+
+	// The number of partitions is capped at the maximum.
+	agg.mu.Lock()
+	assert.Equal(t, uint16(4), agg.partitionsFor(ivl, hotID))
+	agg.mu.Unlock()
+
+	// IDs without events shrink back to the configured partitions.
+	harvest(start.Add(3 * ivl))
+	agg.mu.Lock()
+	assert.Equal(t, uint16(1), agg.partitionsFor(ivl, hotID))
+	agg.mu.Unlock()
+}
+
+func TestAdaptivePartitionsHigherInterval(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		rollups bool
+		// expected is the number of partitions of the lowest and the
+		// higher interval after the lowest interval period ending in the
+		// middle of the higher interval period.
+		expected [2]uint16
+	}{
+		{name: "independent_intervals", expected: [2]uint16{3, 1}},
+		{name: "interval_rollups", rollups: true, expected: [2]uint16{1, 1}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			ivls := []time.Duration{time.Minute, 2 * time.Minute}
+			id := EncodeToCombinedMetricsKeyID(t, "ab01")
+			agg, err := New(
+				WithDataDir(t.TempDir()),
+				WithLimits(Limits{
+					MaxServices:                           10,
+					MaxServiceInstanceGroupsPerService:    10,
+					MaxTransactionGroups:                  100,
+					MaxTransactionGroupsPerService:        100,
+					MaxServiceTransactionGroups:           10,
+					MaxServiceTransactionGroupsPerService: 10,
+					MaxSpanGroups:                         10,
+					MaxSpanGroupsPerService:               10,
+				}),
+				WithAggregationIntervals(ivls),
+				WithIntervalRollups(tc.rollups),
+				WithPartitions(1),
+				WithAdaptivePartitions(4, 2),
+				WithProcessor(noOpProcessor()),
+				WithLogger(zap.NewNop()),
+			)
+			require.NoError(t, err)
+			t.Cleanup(func() { agg.Close(ctx) })
+
+			start := time.Unix(agg.processingTime.Unix(), 0).Truncate(ivls[1])
+			agg.mu.Lock()
+			agg.processingTime = start
+			agg.mu.Unlock()
+			aggregate := func(n int) {
+				batch := make(modelpb.Batch, n)
+				for i := range batch {
+					batch[i] = lateTestEvent(agg.processingTime)
+					batch[i].Transaction.Name = fmt.Sprintf("txn%d", i)
+				}
+				require.NoError(t, agg.AggregateBatch(ctx, id, &batch))
+			}
+			harvest := func(end time.Time) {
+				agg.mu.Lock()
+				batch := agg.takeBatches()
+				agg.processingTime = end
+				agg.mu.Unlock()
+				require.NoError(t, agg.commitAndHarvest(
+					ctx, batch, end, ivls, agg.cachedEvents.loadAndDelete(end),
+				))
+			}
+			partitionsFor := func() [2]uint16 {
+				agg.mu.Lock()
+				defer agg.mu.Unlock()
+				return [2]uint16{agg.partitionsFor(ivls[0], id), agg.partitionsFor(ivls[1], id)}
+			}
+
+			aggregate(5)
+			harvest(start.Add(ivls[0]))
+			// The lowest interval period ends in the middle of the higher
+			// interval period, the partitions of the higher interval are
+			// unchanged until the end of its period.
+			assert.Equal(t, tc.expected, partitionsFor())
+			aggregate(20)
+
+			agg.mu.Lock()
+			batch := agg.takeBatches()
+			agg.mu.Unlock()
+			require.NoError(t, agg.commitBatch(batch))
+			require.NoError(t, batch.close())
+			partitions := make(map[uint16]struct{})
+			for _, shard := range agg.shards {
+				iter := shard.NewIter(&pebble.IterOptions{LowerBound: combinedMetricsLowerBound})
+				for iter.First(); iter.Valid(); iter.Next() {
+					var cmk CombinedMetricsKey
+					require.NoError(t, cmk.UnmarshalBinary(iter.Key()))
+					if cmk.ID == id && cmk.Interval == ivls[1] {
+						partitions[cmk.PartitionID] = struct{}{}
+					}
+				}
+				require.NoError(t, iter.Close())
+			}
+			// All the events of the higher interval period are aggregated
+			// into the same partition.
+			assert.Equal(t, map[uint16]struct{}{0: {}}, partitions)
+
+			// Both intervals are adapted at the end of the higher interval
+			// period.
+			harvest(start.Add(ivls[1]))
+			assert.Equal(t, [2]uint16{4, 4}, partitionsFor())
+		})
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"context"
+	"sync"
+)
+
+// pauseState tracks whether the aggregator is paused and the harvest in
+// progress, if any, so that Pause returns once harvesting is suspended.
+type pauseState struct {
+	mu sync.Mutex
+	// resumed is closed by Resume, it is nil if the aggregator is not
+	// paused.
+	resumed chan struct{}
+	// harvesting is a semaphore held by the harvest loop while harvesting.
+	harvesting chan struct{}
+}
+
+func newPauseState() pauseState {
+	return pauseState{harvesting: make(chan struct{}, 1)}
+}
+
+// resumedChan returns the channel closed once the aggregator is resumed,
+// or nil if the aggregator is not paused.
+func (s *pauseState) resumedChan() chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.resumed
+}
+
+func (s *pauseState) paused() bool {
+	return s.resumedChan() != nil
+}
+
+// Pause stops the aggregator from accepting writes, AggregateBatch and
+// AggregateCombinedMetrics return ErrAggregatorPaused, and suspends the
+// harvests of Run, while keeping the database open. This allows for
+// coordinated maintenance, e.g. of the processor destination, without
+// losing the aggregated state. Pause waits for a harvest in progress to
+// complete; if the context is done before, the aggregator is paused and
+// the context error is returned. The harvests missed while paused are
+// performed once resumed. Pausing a paused aggregator has no effect, and
+// Close harvests and closes a paused aggregator as usual.
+func (a *Aggregator) Pause(ctx context.Context) error {
+	ctx, span := a.cfg.Tracer.Start(ctx, "Aggregator.Pause")
+	defer span.End()
+
+	a.mu.Lock()
+	select {
+	case <-a.closed:
+		a.mu.Unlock()
+		return ErrAggregatorClosed
+	default:
+	}
+	a.pause.mu.Lock()
+	if a.pause.resumed == nil {
+		a.pause.resumed = make(chan struct{})
+	}
+	a.pause.mu.Unlock()
+	a.mu.Unlock()
+
+	// The harvest loop checks whether it is paused after acquiring the
+	// semaphore, acquiring it once ensures no harvest is in progress.
+	select {
+	case a.pause.harvesting <- struct{}{}:
+		<-a.pause.harvesting
+	case <-ctx.Done():
+		span.RecordError(ctx.Err())
+		return ctx.Err()
+	}
+	return nil
+}
+
+// Resume resumes the writes and harvests of a paused aggregator. Resuming
+// an aggregator which is not paused has no effect.
+func (a *Aggregator) Resume(ctx context.Context) error {
+	_, span := a.cfg.Tracer.Start(ctx, "Aggregator.Resume")
+	defer span.End()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	select {
+	case <-a.closed:
+		return ErrAggregatorClosed
+	default:
+	}
+	a.pause.mu.Lock()
+	defer a.pause.mu.Unlock()
+	if a.pause.resumed != nil {
+		close(a.pause.resumed)
+		a.pause.resumed = nil
+	}
+	return nil
+}
+
+// beginHarvest waits until the aggregator is not paused and marks a
+// harvest in progress, endHarvest must be called once the harvest is
+// completed.
+func (a *Aggregator) beginHarvest(ctx context.Context) error {
+	for {
+		select {
+		case a.pause.harvesting <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-a.closed:
+			return ErrAggregatorClosed
+		}
+		resumed := a.pause.resumedChan()
+		if resumed == nil {
+			return nil
+		}
+		<-a.pause.harvesting
+		select {
+		case <-resumed:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-a.closed:
+			return ErrAggregatorClosed
+		}
+	}
+}
+
+func (a *Aggregator) endHarvest() {
+	<-a.pause.harvesting
+}
@@ -0,0 +1,89 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+	"github.com/elastic/apm-data/model/modelpb"
+)
+
+func TestPauseResume(t *testing.T) {
+	ctx := context.Background()
+	ivl := time.Second
+	var harvests atomic.Int64
+	agg, err := New(
+		WithDataDir(t.TempDir()),
+		WithLimits(Limits{
+			MaxServices:                        10,
+			MaxServiceInstanceGroupsPerService: 10,
+		}),
+		WithAggregationIntervals([]time.Duration{ivl}),
+		WithProcessor(func(
+			context.Context,
+			CombinedMetricsKey,
+			*aggregationpb.CombinedMetrics,
+			time.Duration,
+		) error {
+			harvests.Add(1)
+			return nil
+		}),
+		WithLogger(zap.NewNop()),
+	)
+	require.NoError(t, err)
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- agg.Run(ctx) }()
+	assert.Eventually(t, func() bool {
+		return agg.Health().Ready()
+	}, 10*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, agg.Pause(ctx))
+	// Pausing twice has no effect.
+	require.NoError(t, agg.Pause(ctx))
+	h := agg.Health()
+	assert.True(t, h.Paused)
+	assert.False(t, h.Ready())
+
+	id := EncodeToCombinedMetricsKeyID(t, "ab01")
+	batch := modelpb.Batch{{
+		Timestamp: timestamppb.Now(),
+		Service:   &modelpb.Service{Name: "svc"},
+	}}
+	assert.ErrorIs(t, agg.AggregateBatch(ctx, id, &batch), ErrAggregatorPaused)
+	cm := NewTestCombinedMetrics(WithEventsTotal(1)).
+		AddServiceMetrics(serviceAggregationKey{Timestamp: time.Now().Truncate(ivl), ServiceName: "svc"}).
+		AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+		GetProto()
+	defer cm.ReturnToVTPool()
+	cmk := CombinedMetricsKey{Interval: ivl, ProcessingTime: time.Now().Truncate(ivl), ID: id}
+	assert.ErrorIs(t, agg.AggregateCombinedMetrics(ctx, cmk, cm), ErrAggregatorPaused)
+
+	// No harvests are performed while paused.
+	lastHarvest := agg.Health().LastHarvest
+	time.Sleep(2 * ivl)
+	assert.Equal(t, lastHarvest, agg.Health().LastHarvest)
+
+	require.NoError(t, agg.Resume(ctx))
+	assert.False(t, agg.Health().Paused)
+	require.NoError(t, agg.AggregateCombinedMetrics(ctx, cmk, cm))
+	assert.Eventually(t, func() bool {
+		return harvests.Load() > 0
+	}, 10*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, agg.Close(ctx))
+	assert.ErrorIs(t, <-runErr, ErrAggregatorClosed)
+	assert.ErrorIs(t, agg.Pause(ctx), ErrAggregatorClosed)
+	assert.ErrorIs(t, agg.Resume(ctx), ErrAggregatorClosed)
+}
@@ -0,0 +1,153 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/cockroachdb/pebble"
+
+	"github.com/elastic/apm-aggregation/aggregators/internal/telemetry"
+)
+
+// defaultMaxOpenFiles is the pebble default for the max number of open
+// files, used to size the shared table cache.
+const defaultMaxOpenFiles = 1000
+
+// ErrPoolClosed means that the pool was closed when the method was called.
+var ErrPoolClosed = errors.New("pool is closed")
+
+// Pool creates aggregators sharing resources that would otherwise be
+// allocated for each aggregator. Aggregators created by a pool share the
+// pebble block cache, the pebble table cache along with its background
+// workers, and the telemetry instruments. Pebble metrics are reported
+// aggregated across all the open aggregators of the pool.
+//
+// Pool is meant for processes running multiple aggregators, for example
+// one for each pipeline. Close must be called when the pool is no longer
+// needed.
+type Pool struct {
+	opts       []Option
+	cache      *pebble.Cache
+	tableCache *pebble.TableCache
+	metrics    *telemetry.Metrics
+
+	mu          sync.Mutex
+	aggregators map[*Aggregator]struct{}
+	closed      bool
+}
+
+// NewPool returns a new pool with a shared block cache of the given size
+// in bytes. The options are used as the base options for all aggregators
+// created by the pool. The telemetry instruments are created using the
+// meter configured by the options, meters configured for individual
+// aggregators are ignored.
+func NewPool(cacheSize int64, opts ...Option) (*Pool, error) {
+	if cacheSize <= 0 {
+		return nil, errors.New("cache size must be greater than zero")
+	}
+	cfg, err := NewConfig(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create aggregation config: %w", err)
+	}
+	metrics, err := telemetry.NewMetrics(nil, telemetry.WithMeter(cfg.Meter))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metrics: %w", err)
+	}
+
+	cache := pebble.NewCache(cacheSize)
+	return &Pool{
+		opts:  opts,
+		cache: cache,
+		tableCache: pebble.NewTableCache(
+			cache, runtime.GOMAXPROCS(0), pebble.TableCacheSize(defaultMaxOpenFiles),
+		),
+		metrics:     metrics,
+		aggregators: make(map[*Aggregator]struct{}),
+	}, nil
+}
+
+// New returns a new aggregator using the resources shared by the pool. The
+// options are applied after the base options of the pool. Each aggregator
+// must use a different data directory, unless it is in-memory.
+//
+// Close must be called when the aggregator is no longer needed.
+func (p *Pool) New(opts ...Option) (*Aggregator, error) {
+	allOpts := make([]Option, 0, len(p.opts)+len(opts))
+	allOpts = append(allOpts, p.opts...)
+	allOpts = append(allOpts, opts...)
+	cfg, err := NewConfig(allOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create aggregation config: %w", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, ErrPoolClosed
+	}
+	a, err := newAggregator(cfg, p)
+	if err != nil {
+		return nil, err
+	}
+	p.aggregators[a] = struct{}{}
+	return a, nil
+}
+
+// Close closes all the open aggregators created by the pool and releases
+// the shared resources. No new aggregators can be created after Close is
+// called.
+func (p *Pool) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	aggs := make([]*Aggregator, 0, len(p.aggregators))
+	for a := range p.aggregators {
+		aggs = append(aggs, a)
+	}
+	p.mu.Unlock()
+
+	var errs []error
+	for _, a := range aggs {
+		if err := a.Close(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to close aggregators: %w", errors.Join(errs...))
+	}
+
+	// Pebble databases hold their own references to the caches, the pool
+	// only releases its own references.
+	if err := p.tableCache.Unref(); err != nil {
+		return fmt.Errorf("failed to release table cache: %w", err)
+	}
+	p.cache.Unref()
+	if err := p.metrics.CleanUp(); err != nil {
+		return fmt.Errorf("failed to cleanup instrumentation: %w", err)
+	}
+	return nil
+}
+
+// release is called by a closed aggregator to release its share of the
+// pool resources.
+func (p *Pool) release(a *Aggregator) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.aggregators[a]; !ok {
+		return
+	}
+	delete(p.aggregators, a)
+	a.removePebbleProvider()
+	a.removeLimitsProvider()
+	a.removeFingerprint()
+}
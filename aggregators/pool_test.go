@@ -0,0 +1,89 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/elastic/apm-data/model/modelpb"
+)
+
+func TestPool(t *testing.T) {
+	rdr := metric.NewManualReader()
+	pool, err := NewPool(
+		1<<20,
+		WithMeter(metric.NewMeterProvider(metric.WithReader(rdr)).Meter("test")),
+		WithProcessor(noOpProcessor()),
+		WithLogger(zap.NewNop()),
+	)
+	require.NoError(t, err)
+
+	var aggs []*Aggregator
+	for i := 0; i < 2; i++ {
+		agg, err := pool.New(WithDataDir(t.TempDir()))
+		require.NoError(t, err)
+		aggs = append(aggs, agg)
+		require.NoError(t, agg.AggregateBatch(
+			context.Background(),
+			EncodeToCombinedMetricsKeyID(t, "ab01"),
+			&modelpb.Batch{
+				&modelpb.APMEvent{
+					Event: &modelpb.Event{Duration: durationpb.New(time.Millisecond)},
+					Transaction: &modelpb.Transaction{
+						Name:                "T-1000",
+						Type:                "type",
+						RepresentativeCount: 1,
+					},
+				},
+			},
+		))
+	}
+
+	collect := func(name string) metricdata.Aggregation {
+		var rm metricdata.ResourceMetrics
+		require.NoError(t, rdr.Collect(context.Background(), &rm))
+		require.Len(t, rm.ScopeMetrics, 1)
+		for _, m := range rm.ScopeMetrics[0].Metrics {
+			if m.Name == name {
+				return m.Data
+			}
+		}
+		t.Fatalf("metric %s not found", name)
+		return nil
+	}
+	requests := collect("aggregator.requests.total").(metricdata.Sum[int64])
+	require.Len(t, requests.DataPoints, 1)
+	assert.Equal(t, int64(2), requests.DataPoints[0].Value)
+
+	// Pebble metrics are reported for the open aggregators only.
+	require.NoError(t, aggs[0].Close(context.Background()))
+	diskUsage := collect("pebble.disk.usage").(metricdata.Gauge[int64])
+	require.Len(t, diskUsage.DataPoints, 1)
+	assert.Equal(t, int64(aggs[1].db.Metrics().DiskSpaceUsage()), diskUsage.DataPoints[0].Value)
+	memtableSize := collect("aggregator.storage.memtable.size").(metricdata.Gauge[int64])
+	require.Len(t, memtableSize.DataPoints, 1)
+	assert.Equal(t, int64(aggs[1].db.Metrics().MemTable.Size), memtableSize.DataPoints[0].Value)
+
+	require.NoError(t, pool.Close(context.Background()))
+	assert.ErrorIs(t, aggs[1].AggregateBatch(
+		context.Background(), EncodeToCombinedMetricsKeyID(t, "ab01"), &modelpb.Batch{},
+	), ErrAggregatorClosed)
+	_, err = pool.New(WithDataDir(t.TempDir()))
+	assert.ErrorIs(t, err, ErrPoolClosed)
+}
+
+func TestNewPoolInvalidCacheSize(t *testing.T) {
+	_, err := NewPool(0)
+	assert.EqualError(t, err, "cache size must be greater than zero")
+}
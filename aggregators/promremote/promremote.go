@@ -0,0 +1,338 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+// Package promremote provides an aggregators.Processor sending the
+// harvested service transaction, transaction and span metrics to a
+// Prometheus remote-write endpoint, such as Prometheus, Mimir or Thanos.
+//
+// The harvested metrics are deltas over the aggregation interval rather
+// than cumulative counters. Every series carries an interval label and
+// should be queried using the *_over_time functions instead of rate.
+// Native histograms are sent with the gauge reset hint accordingly.
+package promremote
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/golang/snappy"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+	"github.com/elastic/apm-aggregation/aggregators"
+	"github.com/elastic/apm-data/model/modelpb"
+)
+
+const (
+	transactionDurationMetric    = "apm_transaction_duration_seconds"
+	serviceTransactionMetric     = "apm_service_transaction_duration_seconds"
+	spanResponseTimeMetric       = "apm_span_destination_response_time_seconds"
+	defaultNativeHistogramSchema = 3
+)
+
+// DefaultBuckets are the default upper bounds, in seconds, of the classic
+// histogram buckets.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Option configures the processor.
+type Option func(config) config
+
+type config struct {
+	client           *http.Client
+	headers          http.Header
+	nativeHistograms bool
+	buckets          []float64
+	converterOpts    []aggregators.ConverterOption
+}
+
+// WithHTTPClient configures the HTTP client used for the remote-write
+// requests. Defaults to http.DefaultClient.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c config) config {
+		c.client = client
+		return c
+	}
+}
+
+// WithHeaders configures additional headers sent with every request, for
+// example the X-Scope-OrgID tenant header of Mimir or an Authorization
+// header.
+func WithHeaders(headers http.Header) Option {
+	return func(c config) config {
+		c.headers = headers
+		return c
+	}
+}
+
+// WithNativeHistograms configures sending the duration distributions as
+// native histograms using exponential buckets with a growth factor of
+// 2^(1/8). The receiver must have native histograms enabled. Defaults to
+// false, sending classic histograms with the buckets configured using
+// WithBuckets.
+func WithNativeHistograms(enabled bool) Option {
+	return func(c config) config {
+		c.nativeHistograms = enabled
+		return c
+	}
+}
+
+// WithBuckets configures the upper bounds, in seconds, of the classic
+// histogram buckets. The bounds must be positive and in ascending order,
+// the +Inf bucket is always added. Defaults to DefaultBuckets.
+func WithBuckets(bounds []float64) Option {
+	return func(c config) config {
+		c.buckets = bounds
+		return c
+	}
+}
+
+// WithConverterOptions configures the options used to convert the
+// harvested combined metrics, see aggregators.CombinedMetricsToBatch.
+func WithConverterOptions(opts ...aggregators.ConverterOption) Option {
+	return func(c config) config {
+		c.converterOpts = opts
+		return c
+	}
+}
+
+type processor struct {
+	endpoint string
+	cfg      config
+}
+
+// NewProcessor returns a processor sending the harvested metrics to the
+// Prometheus remote-write endpoint, one request per combined metrics.
+func NewProcessor(endpoint string, opts ...Option) (aggregators.Processor, error) {
+	cfg := config{
+		client:  http.DefaultClient,
+		buckets: DefaultBuckets,
+	}
+	for _, opt := range opts {
+		cfg = opt(cfg)
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse remote-write endpoint: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported remote-write endpoint scheme %q", u.Scheme)
+	}
+	if cfg.client == nil {
+		return nil, errors.New("http client is required")
+	}
+	for i, b := range cfg.buckets {
+		if b <= 0 || math.IsInf(b, 1) {
+			return nil, fmt.Errorf("bucket bound %v must be positive and finite", b)
+		}
+		if i > 0 && b <= cfg.buckets[i-1] {
+			return nil, errors.New("bucket bounds must be in ascending order")
+		}
+	}
+	p := &processor{endpoint: endpoint, cfg: cfg}
+	return p.process, nil
+}
+
+func (p *processor) process(
+	ctx context.Context,
+	cmk aggregators.CombinedMetricsKey,
+	cm *aggregationpb.CombinedMetrics,
+	aggIvl time.Duration,
+) error {
+	batch, err := aggregators.CombinedMetricsToBatch(cm, cmk.ProcessingTime, aggIvl, p.cfg.converterOpts...)
+	if err != nil {
+		return fmt.Errorf("failed to convert combined metrics: %w", err)
+	}
+	if batch == nil {
+		return nil
+	}
+	var series []timeSeries
+	for _, e := range *batch {
+		series = p.appendTimeSeries(series, e)
+		e.ReturnToVTPool()
+	}
+	if len(series) == 0 {
+		return nil
+	}
+	return p.write(ctx, series)
+}
+
+// appendTimeSeries appends the time series for the metrics of the event.
+// Events of other metricsets are ignored.
+func (p *processor) appendTimeSeries(series []timeSeries, e *modelpb.APMEvent) []timeSeries {
+	ts := e.GetTimestamp().AsTime().UnixMilli()
+	switch e.GetMetricset().GetName() {
+	case "transaction":
+		txn := e.GetTransaction()
+		return p.appendDuration(series, transactionDurationMetric, eventLabels(e), ts,
+			txn.GetDurationHistogram(), txn.GetDurationSummary())
+	case "service_transaction":
+		txn := e.GetTransaction()
+		return p.appendDuration(series, serviceTransactionMetric, eventLabels(e), ts,
+			txn.GetDurationHistogram(), txn.GetDurationSummary())
+	case "service_destination":
+		rt := e.GetSpan().GetDestinationService().GetResponseTime()
+		labels := eventLabels(e)
+		return append(series,
+			newSeries(spanResponseTimeMetric+"_count", labels, float64(rt.GetCount()), ts),
+			newSeries(spanResponseTimeMetric+"_sum", labels, rt.GetSum().AsDuration().Seconds(), ts),
+		)
+	}
+	return series
+}
+
+// appendDuration appends the time series for a duration distribution with
+// values in microseconds.
+func (p *processor) appendDuration(
+	series []timeSeries,
+	name string,
+	labels []label,
+	ts int64,
+	h *modelpb.Histogram,
+	summary *modelpb.SummaryMetric,
+) []timeSeries {
+	sum := summary.GetSum() / 1e6
+	if p.cfg.nativeHistograms {
+		return append(series, timeSeries{
+			labels: withName(name, labels),
+			histograms: []histogram{
+				nativeHistogram(h.GetCounts(), h.GetValues(), sum, ts, defaultNativeHistogramSchema),
+			},
+		})
+	}
+
+	counts, values := h.GetCounts(), h.GetValues()
+	var cumulative uint64
+	var j int
+	for _, bound := range p.cfg.buckets {
+		for ; j < len(values) && values[j]/1e6 <= bound; j++ {
+			cumulative += counts[j]
+		}
+		le := label{name: "le", value: strconv.FormatFloat(bound, 'f', -1, 64)}
+		series = append(series, newSeries(name+"_bucket", append(labels, le), float64(cumulative), ts))
+	}
+	for ; j < len(values); j++ {
+		cumulative += counts[j]
+	}
+	inf := label{name: "le", value: "+Inf"}
+	return append(series,
+		newSeries(name+"_bucket", append(labels, inf), float64(cumulative), ts),
+		newSeries(name+"_sum", labels, sum, ts),
+		newSeries(name+"_count", labels, float64(summary.GetCount()), ts),
+	)
+}
+
+// nativeHistogram returns a native histogram of the values in
+// microseconds, which must be in ascending order, using exponential
+// buckets of the given schema over the values in seconds.
+func nativeHistogram(counts []uint64, values []float64, sum float64, ts int64, schema int32) histogram {
+	h := histogram{sum: sum, schema: schema, timestamp: ts}
+	var indexes []int32
+	var bucketCounts []uint64
+	for i, v := range values {
+		c := counts[i]
+		h.count += c
+		if v <= 0 {
+			h.zeroCount += c
+			continue
+		}
+		// Bucket index i holds the values in (base^(i-1), base^i] with
+		// base = 2^(2^-schema).
+		idx := int32(math.Ceil(math.Log2(v/1e6) * math.Exp2(float64(schema))))
+		if n := len(indexes); n > 0 && indexes[n-1] == idx {
+			bucketCounts[n-1] += c
+			continue
+		}
+		indexes = append(indexes, idx)
+		bucketCounts = append(bucketCounts, c)
+	}
+	var prev int64
+	for i, idx := range indexes {
+		switch {
+		case i == 0:
+			h.positiveSpans = append(h.positiveSpans, bucketSpan{offset: idx})
+		case idx != indexes[i-1]+1:
+			h.positiveSpans = append(h.positiveSpans, bucketSpan{offset: idx - indexes[i-1] - 1})
+		}
+		h.positiveSpans[len(h.positiveSpans)-1].length++
+		h.positiveDeltas = append(h.positiveDeltas, int64(bucketCounts[i])-prev)
+		prev = int64(bucketCounts[i])
+	}
+	return h
+}
+
+// eventLabels returns the labels identifying the metrics of the event,
+// excluding the metric name.
+func eventLabels(e *modelpb.APMEvent) []label {
+	var labels []label
+	add := func(name, value string) {
+		if value != "" {
+			labels = append(labels, label{name: name, value: value})
+		}
+	}
+	add("interval", e.GetMetricset().GetInterval())
+	add("agent_name", e.GetAgent().GetName())
+	add("service_name", e.GetService().GetName())
+	add("service_environment", e.GetService().GetEnvironment())
+	add("service_language_name", e.GetService().GetLanguage().GetName())
+	add("service_version", e.GetService().GetVersion())
+	add("service_target_type", e.GetService().GetTarget().GetType())
+	add("service_target_name", e.GetService().GetTarget().GetName())
+	add("transaction_type", e.GetTransaction().GetType())
+	add("transaction_name", e.GetTransaction().GetName())
+	add("transaction_result", e.GetTransaction().GetResult())
+	add("event_outcome", e.GetEvent().GetOutcome())
+	add("span_name", e.GetSpan().GetName())
+	add("span_destination_service_resource", e.GetSpan().GetDestinationService().GetResource())
+	return labels
+}
+
+func newSeries(name string, labels []label, value float64, ts int64) timeSeries {
+	return timeSeries{
+		labels:  withName(name, labels),
+		samples: []sample{{value: value, timestamp: ts}},
+	}
+}
+
+// withName returns a copy of the labels including the metric name, sorted
+// by label name as required by the remote-write protocol.
+func withName(name string, labels []label) []label {
+	out := make([]label, 0, len(labels)+1)
+	out = append(out, label{name: "__name__", value: name})
+	out = append(out, labels...)
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+	return out
+}
+
+func (p *processor) write(ctx context.Context, series []timeSeries) error {
+	body := snappy.Encode(nil, marshalWriteRequest(series))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create remote-write request: %w", err)
+	}
+	for k, v := range p.cfg.headers {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	resp, err := p.cfg.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send remote-write request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("remote-write request failed with status %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
@@ -0,0 +1,184 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package promremote
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/elastic/apm-aggregation/aggregators/aggregatorstest"
+)
+
+func TestNativeHistogram(t *testing.T) {
+	// Values in microseconds: 1ms and 1.1ms fall into the adjacent buckets
+	// -79 and -78 of schema 3, 2ms into bucket -71 and 1s into bucket 0.
+	h := nativeHistogram(
+		[]uint64{1, 2, 3, 4, 5},
+		[]float64{0, 1000, 1100, 2000, 1e6},
+		1.5, 60000, 3,
+	)
+	assert.Equal(t, histogram{
+		count:          15,
+		sum:            1.5,
+		schema:         3,
+		zeroCount:      1,
+		positiveSpans:  []bucketSpan{{offset: -79, length: 2}, {offset: 6, length: 1}, {offset: 70, length: 1}},
+		positiveDeltas: []int64{2, 1, 1, 1},
+		timestamp:      60000,
+	}, h)
+}
+
+func TestNewProcessorInvalid(t *testing.T) {
+	_, err := NewProcessor("ftp://localhost")
+	assert.EqualError(t, err, `unsupported remote-write endpoint scheme "ftp"`)
+	_, err = NewProcessor("http://localhost", WithBuckets([]float64{1, 0.5}))
+	assert.EqualError(t, err, "bucket bounds must be in ascending order")
+	_, err = NewProcessor("http://localhost", WithBuckets([]float64{math.Inf(1)}))
+	assert.EqualError(t, err, "bucket bound +Inf must be positive and finite")
+}
+
+func TestProcessor(t *testing.T) {
+	cmk, cm, err := aggregatorstest.GenerateCombinedMetrics(aggregatorstest.FixtureConfig{
+		TransactionGroups: 1,
+		SpanGroups:        1,
+		Timestamp:         time.Unix(120, 0),
+	})
+	require.NoError(t, err)
+
+	for _, native := range []bool{false, true} {
+		var received []decodedSeries
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "snappy", r.Header.Get("Content-Encoding"))
+			assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+			assert.Equal(t, "tenant-1", r.Header.Get("X-Scope-OrgID"))
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			data, err := snappy.Decode(nil, body)
+			require.NoError(t, err)
+			received = decodeWriteRequest(t, data)
+		}))
+		defer srv.Close()
+
+		processor, err := NewProcessor(srv.URL,
+			WithNativeHistograms(native),
+			WithBuckets([]float64{0.1, 1}),
+			WithHeaders(http.Header{"X-Scope-OrgID": []string{"tenant-1"}}),
+		)
+		require.NoError(t, err)
+		require.NoError(t, processor(context.Background(), cmk, cm, time.Minute))
+
+		names := make(map[string]int)
+		for _, s := range received {
+			assert.Equal(t, "1m", s.labels["interval"])
+			assert.NotEmpty(t, s.labels["service_name"])
+			names[s.labels["__name__"]]++
+			for _, ts := range s.timestamps {
+				assert.Equal(t, int64(120000), ts)
+			}
+		}
+		if native {
+			assert.Equal(t, map[string]int{
+				"apm_transaction_duration_seconds":                 1,
+				"apm_service_transaction_duration_seconds":         1,
+				"apm_span_destination_response_time_seconds_count": 1,
+				"apm_span_destination_response_time_seconds_sum":   1,
+			}, names)
+		} else {
+			assert.Equal(t, map[string]int{
+				"apm_transaction_duration_seconds_bucket":          3,
+				"apm_transaction_duration_seconds_sum":             1,
+				"apm_transaction_duration_seconds_count":           1,
+				"apm_service_transaction_duration_seconds_bucket":  3,
+				"apm_service_transaction_duration_seconds_sum":     1,
+				"apm_service_transaction_duration_seconds_count":   1,
+				"apm_span_destination_response_time_seconds_count": 1,
+				"apm_span_destination_response_time_seconds_sum":   1,
+			}, names)
+		}
+	}
+}
+
+func TestProcessorError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "out of order sample", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	cmk, cm, err := aggregatorstest.GenerateCombinedMetrics(aggregatorstest.FixtureConfig{TransactionGroups: 1})
+	require.NoError(t, err)
+	processor, err := NewProcessor(srv.URL)
+	require.NoError(t, err)
+	err = processor(context.Background(), cmk, cm, time.Minute)
+	assert.EqualError(t, err, "remote-write request failed with status 400 Bad Request: out of order sample")
+}
+
+type decodedSeries struct {
+	labels     map[string]string
+	timestamps []int64
+}
+
+// decodeWriteRequest decodes the labels and timestamps of the time series
+// in the protobuf encoded prometheus.WriteRequest.
+func decodeWriteRequest(t *testing.T, b []byte) []decodedSeries {
+	var series []decodedSeries
+	for _, ts := range decodeFields(t, b)[1] {
+		s := decodedSeries{labels: make(map[string]string)}
+		fields := decodeFields(t, ts)
+		for _, l := range fields[1] {
+			lf := decodeFields(t, l)
+			s.labels[string(lf[1][0])] = string(lf[2][0])
+		}
+		for _, sample := range fields[2] {
+			ts, n := protowire.ConsumeVarint(decodeFields(t, sample)[2][0])
+			require.Greater(t, n, 0)
+			s.timestamps = append(s.timestamps, int64(ts))
+		}
+		for _, h := range fields[4] {
+			ts, n := protowire.ConsumeVarint(decodeFields(t, h)[15][0])
+			require.Greater(t, n, 0)
+			s.timestamps = append(s.timestamps, int64(ts))
+		}
+		series = append(series, s)
+	}
+	return series
+}
+
+// decodeFields returns the raw values of the fields of a protobuf message
+// by field number. Varint values are re-encoded as varints.
+func decodeFields(t *testing.T, b []byte) map[protowire.Number][][]byte {
+	fields := make(map[protowire.Number][][]byte)
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		require.Greater(t, n, 0)
+		b = b[n:]
+		var v []byte
+		switch typ {
+		case protowire.BytesType:
+			v, n = protowire.ConsumeBytes(b)
+		case protowire.VarintType:
+			var x uint64
+			x, n = protowire.ConsumeVarint(b)
+			v = protowire.AppendVarint(nil, x)
+		case protowire.Fixed64Type:
+			v, n = b[:8], 8
+		default:
+			t.Fatalf("unexpected wire type %v", typ)
+		}
+		require.Greater(t, n, 0)
+		b = b[n:]
+		fields[num] = append(fields[num], v)
+	}
+	return fields
+}
@@ -0,0 +1,143 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package promremote
+
+import (
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The types below mirror the subset of the Prometheus remote-write
+// protocol (prometheus/prompb) used by the processor. They are encoded by
+// hand to avoid depending on the Prometheus module, the field numbers
+// must match prompb/types.proto and prompb/remote.proto.
+
+// resetHintGauge marks native histograms whose counts are not cumulative.
+const resetHintGauge = 3
+
+type label struct {
+	name  string
+	value string
+}
+
+type sample struct {
+	value     float64
+	timestamp int64
+}
+
+type bucketSpan struct {
+	offset int32
+	length uint32
+}
+
+// histogram is a native histogram with integer counts.
+type histogram struct {
+	count          uint64
+	sum            float64
+	schema         int32
+	zeroThreshold  float64
+	zeroCount      uint64
+	positiveSpans  []bucketSpan
+	positiveDeltas []int64
+	timestamp      int64
+}
+
+type timeSeries struct {
+	labels     []label
+	samples    []sample
+	histograms []histogram
+}
+
+// marshalWriteRequest returns the protobuf encoded prometheus.WriteRequest
+// holding the time series.
+func marshalWriteRequest(series []timeSeries) []byte {
+	var b []byte
+	for _, ts := range series {
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, ts.marshal())
+	}
+	return b
+}
+
+func (ts timeSeries) marshal() []byte {
+	var b []byte
+	for _, l := range ts.labels {
+		var lb []byte
+		lb = appendString(lb, 1, l.name)
+		lb = appendString(lb, 2, l.value)
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, lb)
+	}
+	for _, s := range ts.samples {
+		var sb []byte
+		sb = appendDouble(sb, 1, s.value)
+		sb = appendVarint(sb, 2, uint64(s.timestamp))
+		b = protowire.AppendTag(b, 2, protowire.BytesType)
+		b = protowire.AppendBytes(b, sb)
+	}
+	for _, h := range ts.histograms {
+		b = protowire.AppendTag(b, 4, protowire.BytesType)
+		b = protowire.AppendBytes(b, h.marshal())
+	}
+	return b
+}
+
+func (h histogram) marshal() []byte {
+	var b []byte
+	// count_int and zero_count_int are oneof fields and are encoded even
+	// if zero to select the integer histogram.
+	b = protowire.AppendTag(b, 1, protowire.VarintType)
+	b = protowire.AppendVarint(b, h.count)
+	b = appendDouble(b, 3, h.sum)
+	b = protowire.AppendTag(b, 4, protowire.VarintType)
+	b = protowire.AppendVarint(b, protowire.EncodeZigZag(int64(h.schema)))
+	b = appendDouble(b, 5, h.zeroThreshold)
+	b = protowire.AppendTag(b, 6, protowire.VarintType)
+	b = protowire.AppendVarint(b, h.zeroCount)
+	for _, s := range h.positiveSpans {
+		var sb []byte
+		sb = protowire.AppendTag(sb, 1, protowire.VarintType)
+		sb = protowire.AppendVarint(sb, protowire.EncodeZigZag(int64(s.offset)))
+		sb = appendVarint(sb, 2, uint64(s.length))
+		b = protowire.AppendTag(b, 11, protowire.BytesType)
+		b = protowire.AppendBytes(b, sb)
+	}
+	if len(h.positiveDeltas) > 0 {
+		var db []byte
+		for _, d := range h.positiveDeltas {
+			db = protowire.AppendVarint(db, protowire.EncodeZigZag(d))
+		}
+		b = protowire.AppendTag(b, 12, protowire.BytesType)
+		b = protowire.AppendBytes(b, db)
+	}
+	b = appendVarint(b, 14, resetHintGauge)
+	b = appendVarint(b, 15, uint64(h.timestamp))
+	return b
+}
+
+func appendString(b []byte, num protowire.Number, v string) []byte {
+	if v == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+func appendDouble(b []byte, num protowire.Number, v float64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, math.Float64bits(v))
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
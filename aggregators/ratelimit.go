@@ -0,0 +1,78 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimitedError is returned by AggregateBatch for a batch exceeding the
+// ingest rate limit of its combined metrics ID, see WithIngestRateLimit.
+// It matches ErrRateLimited.
+type RateLimitedError struct {
+	ID [16]byte
+	// Limit is the rate limit of the ID, in events per second.
+	Limit rate.Limit
+	// Events is the number of events of the rejected batch.
+	Events int
+}
+
+func (e *RateLimitedError) Error() string {
+	return fmt.Sprintf(
+		"%s: %d events of ID %s exceed the limit of %g events per second",
+		ErrRateLimited, e.Events, hex.EncodeToString(e.ID[:]), float64(e.Limit),
+	)
+}
+
+// Is returns true for ErrRateLimited.
+func (e *RateLimitedError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// allowIngest returns a *RateLimitedError if the n events of the ID exceed
+// its ingest rate limit. Must be called with the lock held.
+func (a *Aggregator) allowIngest(id [16]byte, n int) error {
+	if a.cfg.IngestRateLimit == nil || n == 0 {
+		return nil
+	}
+	limit := a.cfg.IngestRateLimit(id)
+	if limit == rate.Inf {
+		delete(a.limiters, id)
+		return nil
+	}
+	if limit <= 0 {
+		return &RateLimitedError{ID: id, Limit: limit, Events: n}
+	}
+	// The burst is one second of events, raised to the size of the batch
+	// so that large batches are accepted once enough tokens accumulated.
+	burst := int(math.Ceil(float64(limit)))
+	if burst < n {
+		burst = n
+	}
+	now := a.cfg.Clock.Now()
+	l, ok := a.limiters[id]
+	if !ok {
+		if a.limiters == nil {
+			a.limiters = make(map[[16]byte]*rate.Limiter)
+		}
+		l = rate.NewLimiter(limit, burst)
+		a.limiters[id] = l
+	} else {
+		if l.Limit() != limit {
+			l.SetLimitAt(now, limit)
+		}
+		if l.Burst() < burst {
+			l.SetBurstAt(now, burst)
+		}
+	}
+	if !l.AllowN(now, n) {
+		return &RateLimitedError{ID: id, Limit: limit, Events: n}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+
+	"github.com/elastic/apm-data/model/modelpb"
+)
+
+func TestIngestRateLimit(t *testing.T) {
+	ctx := context.Background()
+	ivl := time.Minute
+	limitedID := EncodeToCombinedMetricsKeyID(t, "ab01")
+	otherID := EncodeToCombinedMetricsKeyID(t, "ab02")
+	clock := &fixedClock{now: time.Unix(1000, 0)}
+	var losses []EventLoss
+	agg, err := New(
+		WithDataDir(t.TempDir()),
+		WithLimits(Limits{
+			MaxServices:                           10,
+			MaxServiceInstanceGroupsPerService:    10,
+			MaxTransactionGroups:                  10,
+			MaxTransactionGroupsPerService:        10,
+			MaxServiceTransactionGroups:           10,
+			MaxServiceTransactionGroupsPerService: 10,
+			MaxSpanGroups:                         10,
+			MaxSpanGroupsPerService:               10,
+		}),
+		WithAggregationIntervals([]time.Duration{ivl}),
+		WithClock(clock),
+		WithIngestRateLimit(func(id [16]byte) rate.Limit {
+			if id == limitedID {
+				return 2
+			}
+			return rate.Inf
+		}),
+		WithProcessor(noOpProcessor()),
+		WithEventLossHandler(func(loss EventLoss) {
+			losses = append(losses, loss)
+		}),
+		WithLogger(zap.NewNop()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { agg.Close(ctx) })
+
+	start := time.Unix(agg.processingTime.Unix(), 0)
+	aggregate := func(id [16]byte, n int) error {
+		batch := make(modelpb.Batch, n)
+		for i := range batch {
+			batch[i] = lateTestEvent(start)
+		}
+		return agg.AggregateBatch(ctx, id, &batch)
+	}
+
+	// The burst allows one second of events.
+	require.NoError(t, aggregate(limitedID, 2))
+	err = aggregate(limitedID, 1)
+	assert.ErrorIs(t, err, ErrRateLimited)
+	var rateLimitedErr *RateLimitedError
+	require.ErrorAs(t, err, &rateLimitedErr)
+	assert.Equal(t, RateLimitedError{ID: limitedID, Limit: 2, Events: 1}, *rateLimitedErr)
+	require.NoError(t, aggregate(otherID, 10))
+
+	// The tokens are refilled over time, up to the burst. Batches larger
+	// than the burst raise it and are accepted once enough tokens
+	// accumulated.
+	clock.now = clock.now.Add(2 * time.Second)
+	assert.ErrorIs(t, aggregate(limitedID, 5), ErrRateLimited)
+	clock.now = clock.now.Add(1500 * time.Millisecond)
+	require.NoError(t, aggregate(limitedID, 5))
+
+	agg.mu.Lock()
+	batch := agg.takeBatches()
+	agg.mu.Unlock()
+	require.NoError(t, agg.commitAndHarvest(ctx, batch, start.Add(ivl), []time.Duration{ivl}, nil))
+	assert.Equal(t, []EventLoss{{
+		ID:             limitedID,
+		Interval:       ivl,
+		ProcessingTime: start,
+		RateLimited:    6,
+	}}, losses)
+}
+
+func TestRateLimitedError(t *testing.T) {
+	err := error(&RateLimitedError{ID: [16]byte{0xab}, Limit: 1.5, Events: 3})
+	assert.True(t, errors.Is(err, ErrRateLimited))
+	assert.EqualError(t, err, "ingest rate limit exceeded: 3 events of ID ab000000000000000000000000000000 exceed the limit of 1.5 events per second")
+}
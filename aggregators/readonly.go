@@ -0,0 +1,147 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/cockroachdb/pebble"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+)
+
+// ReadOnlyStore provides read-only access to the combined metrics stored in
+// the data directory of an aggregator, for example to inspect the contents
+// of a stuck aggregator.
+type ReadOnlyStore struct {
+	db    *pebble.DB
+	codec *valueCodec
+}
+
+// OpenReadOnly opens the aggregator data directory dir in read-only mode.
+// No writes, flushes or compactions are performed on the data directory.
+// The options are used to merge combined metrics which have not been
+// compacted yet and should match the options of the aggregator that wrote
+// the data, in particular the limits.
+//
+// Pebble holds a file lock on the data directory while it is open, so a
+// data directory in use by a running aggregator must be copied before it
+// can be opened.
+func OpenReadOnly(dir string, opts ...Option) (*ReadOnlyStore, error) {
+	cfg, err := NewConfig(append(opts, WithDataDir(dir))...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create aggregation config: %w", err)
+	}
+	codec, err := newValueCodec(cfg.valueCompression(), cfg.ValueCompressionLevel, cfg.ValueEncryption, cfg.DataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create value codec: %w", err)
+	}
+	db, err := pebble.Open(cfg.DataDir, &pebble.Options{
+		Merger:           newCombinedMetricsMerger(cfg, codec, nil, nil),
+		ReadOnly:         true,
+		ErrorIfNotExists: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pebble db: %w", err)
+	}
+	return &ReadOnlyStore{db: db, codec: codec}, nil
+}
+
+// NewIterator returns a new iterator over the stored combined metrics.
+// Close must be called when the iterator is no longer needed and all
+// iterators must be closed before the store is closed.
+func (s *ReadOnlyStore) NewIterator(opts IteratorOptions) (*Iterator, error) {
+	return newIterator([]*pebble.DB{s.db}, s.codec, opts)
+}
+
+// Keys returns the keys of the stored combined metrics within the range
+// configured by opts.
+func (s *ReadOnlyStore) Keys(opts IteratorOptions) ([]CombinedMetricsKey, error) {
+	it, err := s.NewIterator(opts)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	var keys []CombinedMetricsKey
+	for valid := it.First(); valid; valid = it.Next() {
+		keys = append(keys, it.Key())
+	}
+	if err := it.Error(); err != nil {
+		return nil, fmt.Errorf("failed to iterate keys: %w", err)
+	}
+	return keys, nil
+}
+
+// Get returns the combined metrics stored for the given key.
+func (s *ReadOnlyStore) Get(key CombinedMetricsKey) (*aggregationpb.CombinedMetrics, error) {
+	kb := make([]byte, CombinedMetricsKeyEncodedSize)
+	if err := key.MarshalBinaryToSizedBuffer(kb); err != nil {
+		return nil, fmt.Errorf("failed to marshal combined metrics key: %w", err)
+	}
+	value, closer, err := s.db.Get(kb)
+	if errors.Is(err, pebble.ErrNotFound) {
+		// Data directories written by older library versions hold keys
+		// without version, which are only migrated when opened by an
+		// aggregator.
+		value, closer, err = s.db.Get(kb[:legacyCombinedMetricsKeyEncodedSize])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get combined metrics: %w", err)
+	}
+	defer closer.Close()
+
+	cm := &aggregationpb.CombinedMetrics{}
+	if err := s.codec.unmarshal(value, cm); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal combined metrics: %w", err)
+	}
+	return cm, nil
+}
+
+// DumpJSON writes the stored combined metrics within the range configured
+// by opts to w as newline delimited JSON, one object per key.
+func (s *ReadOnlyStore) DumpJSON(w io.Writer, opts IteratorOptions) error {
+	it, err := s.NewIterator(opts)
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	enc := json.NewEncoder(w)
+	cm := aggregationpb.CombinedMetricsFromVTPool()
+	defer cm.ReturnToVTPool()
+	for valid := it.First(); valid; valid = it.Next() {
+		cm.ResetVT()
+		if err := it.Value(cm); err != nil {
+			return err
+		}
+		if err := enc.Encode(dumpedCombinedMetrics{
+			combinedMetricsKeyJSON: it.Key().toJSON(),
+			Metrics:                cm,
+		}); err != nil {
+			return fmt.Errorf("failed to write combined metrics: %w", err)
+		}
+	}
+	if err := it.Error(); err != nil {
+		return fmt.Errorf("failed to iterate combined metrics: %w", err)
+	}
+	return nil
+}
+
+// Close closes the store.
+func (s *ReadOnlyStore) Close() error {
+	if err := s.db.Close(); err != nil {
+		return fmt.Errorf("failed to close pebble db: %w", err)
+	}
+	return nil
+}
+
+type dumpedCombinedMetrics struct {
+	combinedMetricsKeyJSON
+	Metrics *aggregationpb.CombinedMetrics `json:"metrics"`
+}
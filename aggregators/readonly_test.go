@@ -0,0 +1,99 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadOnlyStore(t *testing.T) {
+	dir := t.TempDir()
+	limits := Limits{
+		MaxServices:                        10,
+		MaxServiceInstanceGroupsPerService: 10,
+		MaxServiceInstanceGroups:           10,
+		MaxTransactionGroups:               10,
+		MaxTransactionGroupsPerService:     10,
+	}
+	cfg, err := NewConfig(WithDataDir(dir), WithLimits(limits))
+	require.NoError(t, err)
+
+	processingTime := time.Unix(0, 0).Add(time.Hour).UTC()
+	keys := []CombinedMetricsKey{
+		{Interval: time.Minute, ProcessingTime: processingTime, ID: EncodeToCombinedMetricsKeyID(t, "ab01")},
+		{Interval: time.Hour, ProcessingTime: processingTime, ID: EncodeToCombinedMetricsKeyID(t, "ab02")},
+	}
+	db, err := pebble.Open(dir, &pebble.Options{Merger: newCombinedMetricsMerger(cfg, nil, nil, nil)})
+	require.NoError(t, err)
+	for _, k := range keys {
+		kb := make([]byte, CombinedMetricsKeyEncodedSize)
+		require.NoError(t, k.MarshalBinaryToSizedBuffer(kb))
+		// Write two merge operands per key to verify they are merged
+		// when read.
+		for i := 0; i < 2; i++ {
+			cm := NewTestCombinedMetrics(WithEventsTotal(1)).
+				AddServiceMetrics(serviceAggregationKey{ServiceName: "svc"}).
+				AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+				AddTransaction(transactionAggregationKey{TransactionName: "txn", TransactionType: "type"}).
+				GetProto()
+			vb, err := cm.MarshalVT()
+			require.NoError(t, err)
+			require.NoError(t, db.Merge(kb, vb, pebble.NoSync))
+		}
+	}
+	require.NoError(t, db.Close())
+
+	store, err := OpenReadOnly(dir, WithLimits(limits))
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, store.Close()) }()
+
+	listed, err := store.Keys(IteratorOptions{})
+	require.NoError(t, err)
+	require.Len(t, listed, 2)
+	for i, k := range listed {
+		assert.Equal(t, keys[i].Interval, k.Interval)
+		assert.True(t, keys[i].ProcessingTime.Equal(k.ProcessingTime))
+		assert.Equal(t, keys[i].ID, k.ID)
+	}
+
+	cm, err := store.Get(keys[0])
+	require.NoError(t, err)
+	assert.Equal(t, float64(2), cm.EventsTotal)
+	require.Len(t, cm.ServiceMetrics, 1)
+
+	_, err = store.Get(CombinedMetricsKey{Interval: time.Second, ProcessingTime: processingTime})
+	assert.ErrorIs(t, err, pebble.ErrNotFound)
+
+	var buf bytes.Buffer
+	require.NoError(t, store.DumpJSON(&buf, IteratorOptions{
+		UpperBound: &CombinedMetricsKey{Interval: time.Hour, ProcessingTime: time.Unix(0, 0)},
+	}))
+	var lines []map[string]any
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var line map[string]any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		lines = append(lines, line)
+	}
+	require.Len(t, lines, 1)
+	assert.Equal(t, "1m0s", lines[0]["interval"])
+	assert.Equal(t, "1970-01-01T01:00:00Z", lines[0]["processing_time"])
+	assert.Equal(t, "00000000000000000000000061623031", lines[0]["id"])
+	assert.Equal(t, float64(2), lines[0]["metrics"].(map[string]any)["eventsTotal"])
+}
+
+func TestOpenReadOnlyMissingDir(t *testing.T) {
+	_, err := OpenReadOnly(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,231 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+// Package remote provides a gRPC service and client for forwarding
+// pre-aggregated combined metrics from edge aggregators to a central
+// aggregator over the network.
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/status"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+	"github.com/elastic/apm-aggregation/aggregators"
+)
+
+const aggregateMethod = "/elastic.apm.Aggregation/Aggregate"
+
+// Aggregator is the aggregator which combined metrics received by a Server
+// are aggregated into, typically an *aggregators.Aggregator.
+type Aggregator interface {
+	AggregateCombinedMetrics(
+		ctx context.Context,
+		cmk aggregators.CombinedMetricsKey,
+		cm *aggregationpb.CombinedMetrics,
+	) error
+}
+
+// ServerOption configures a Server.
+type ServerOption func(serverConfig) serverConfig
+
+type serverConfig struct {
+	authFunc func(ctx context.Context, method string) error
+}
+
+// WithAuthFunc configures a function to authenticate each request before it
+// is aggregated. The function is called with the request context, holding
+// the incoming gRPC metadata, and the full gRPC method name. If the
+// function returns an error, the request is rejected with the
+// Unauthenticated code, unless the error is already a gRPC status error.
+func WithAuthFunc(fn func(ctx context.Context, method string) error) ServerOption {
+	return func(c serverConfig) serverConfig {
+		c.authFunc = fn
+		return c
+	}
+}
+
+// Server implements the gRPC Aggregation service by aggregating the
+// received combined metrics into an Aggregator. Gzip compressed requests
+// are supported.
+type Server struct {
+	agg Aggregator
+	cfg serverConfig
+}
+
+// NewServer returns a new Server aggregating into agg.
+func NewServer(agg Aggregator, opts ...ServerOption) *Server {
+	var cfg serverConfig
+	for _, opt := range opts {
+		cfg = opt(cfg)
+	}
+	return &Server{agg: agg, cfg: cfg}
+}
+
+// Register registers the Aggregation service with the gRPC server.
+func (s *Server) Register(r grpc.ServiceRegistrar) {
+	r.RegisterService(&aggregationServiceDesc, s)
+}
+
+// Aggregate aggregates the combined metrics of the request.
+func (s *Server) Aggregate(
+	ctx context.Context,
+	req *aggregationpb.AggregateRequest,
+) (*aggregationpb.AggregateResponse, error) {
+	if s.cfg.authFunc != nil {
+		if err := s.cfg.authFunc(ctx, aggregateMethod); err != nil {
+			if _, ok := status.FromError(err); ok {
+				return nil, err
+			}
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+	}
+	// The key length is validated when unmarshaling, accepting the keys
+	// without version sent by clients of older library versions.
+	var cmk aggregators.CombinedMetricsKey
+	if err := cmk.UnmarshalBinary(req.Key); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid combined metrics key: %v", err)
+	}
+	if req.Metrics == nil {
+		return nil, status.Error(codes.InvalidArgument, "combined metrics are required")
+	}
+	if err := s.agg.AggregateCombinedMetrics(ctx, cmk, req.Metrics); err != nil {
+		return nil, status.Error(ErrorCode(err), err.Error())
+	}
+	return &aggregationpb.AggregateResponse{}, nil
+}
+
+// ErrorCode maps the errors returned by the aggregator to gRPC codes, so
+// that clients can decide whether to retry.
+func ErrorCode(err error) codes.Code {
+	var staleErr *aggregators.StaleProcessingTimeError
+	var tooLargeErr *aggregators.CombinedMetricsTooLargeError
+	switch {
+	case errors.Is(err, aggregators.ErrBackpressure):
+		return codes.ResourceExhausted
+	case errors.Is(err, aggregators.ErrAggregatorClosed):
+		return codes.Unavailable
+	case errors.Is(err, context.Canceled):
+		return codes.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return codes.DeadlineExceeded
+	case errors.As(err, &staleErr):
+		return codes.FailedPrecondition
+	case errors.As(err, &tooLargeErr):
+		return codes.InvalidArgument
+	default:
+		return codes.Internal
+	}
+}
+
+// ClientOption configures a Client.
+type ClientOption func(clientConfig) clientConfig
+
+type clientConfig struct {
+	compression bool
+	callOpts    []grpc.CallOption
+}
+
+// WithCompression configures the client to gzip compress the requests.
+func WithCompression(enabled bool) ClientOption {
+	return func(c clientConfig) clientConfig {
+		c.compression = enabled
+		return c
+	}
+}
+
+// WithCallOptions configures additional gRPC call options used for every
+// request, for example grpc.PerRPCCredentials for authentication.
+func WithCallOptions(opts ...grpc.CallOption) ClientOption {
+	return func(c clientConfig) clientConfig {
+		c.callOpts = append(c.callOpts, opts...)
+		return c
+	}
+}
+
+// Client forwards combined metrics to a remote aggregator serving the
+// Aggregation service.
+type Client struct {
+	conn     grpc.ClientConnInterface
+	callOpts []grpc.CallOption
+}
+
+// NewClient returns a new Client using the given connection.
+func NewClient(conn grpc.ClientConnInterface, opts ...ClientOption) *Client {
+	var cfg clientConfig
+	for _, opt := range opts {
+		cfg = opt(cfg)
+	}
+	callOpts := cfg.callOpts
+	if cfg.compression {
+		callOpts = append(callOpts, grpc.UseCompressor(gzip.Name))
+	}
+	return &Client{conn: conn, callOpts: callOpts}
+}
+
+// AggregateCombinedMetrics forwards the combined metrics to the remote
+// aggregator. The signature matches Aggregator.AggregateCombinedMetrics,
+// so that the client can be used in place of a local aggregator, for
+// example from a Processor harvesting an edge aggregator.
+func (c *Client) AggregateCombinedMetrics(
+	ctx context.Context,
+	cmk aggregators.CombinedMetricsKey,
+	cm *aggregationpb.CombinedMetrics,
+) error {
+	key := make([]byte, aggregators.CombinedMetricsKeyEncodedSize)
+	if err := cmk.MarshalBinaryToSizedBuffer(key); err != nil {
+		return fmt.Errorf("failed to marshal combined metrics key: %w", err)
+	}
+	req := &aggregationpb.AggregateRequest{Key: key, Metrics: cm}
+	var resp aggregationpb.AggregateResponse
+	if err := c.conn.Invoke(ctx, aggregateMethod, req, &resp, c.callOpts...); err != nil {
+		return fmt.Errorf("failed to aggregate remotely: %w", err)
+	}
+	return nil
+}
+
+// aggregationServer is the interface implemented by Server, used for type
+// checking the registered service implementation.
+type aggregationServer interface {
+	Aggregate(context.Context, *aggregationpb.AggregateRequest) (*aggregationpb.AggregateResponse, error)
+}
+
+var aggregationServiceDesc = grpc.ServiceDesc{
+	ServiceName: "elastic.apm.Aggregation",
+	HandlerType: (*aggregationServer)(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Aggregate",
+		Handler:    aggregateHandler,
+	}},
+	Metadata: "proto/remote.proto",
+}
+
+func aggregateHandler(
+	srv interface{},
+	ctx context.Context,
+	dec func(interface{}) error,
+	interceptor grpc.UnaryServerInterceptor,
+) (interface{}, error) {
+	in := new(aggregationpb.AggregateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(aggregationServer).Aggregate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: aggregateMethod,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(aggregationServer).Aggregate(ctx, req.(*aggregationpb.AggregateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
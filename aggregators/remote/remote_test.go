@@ -0,0 +1,139 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package remote
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+	"github.com/elastic/apm-aggregation/aggregators"
+)
+
+type aggregateCall struct {
+	cmk aggregators.CombinedMetricsKey
+	cm  *aggregationpb.CombinedMetrics
+}
+
+type fakeAggregator struct {
+	calls []aggregateCall
+	err   error
+}
+
+func (f *fakeAggregator) AggregateCombinedMetrics(
+	_ context.Context,
+	cmk aggregators.CombinedMetricsKey,
+	cm *aggregationpb.CombinedMetrics,
+) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.calls = append(f.calls, aggregateCall{cmk: cmk, cm: cm.CloneVT()})
+	return nil
+}
+
+func newTestClient(t *testing.T, srv *Server, opts ...ClientOption) *Client {
+	lis := bufconn.Listen(1 << 20)
+	gs := grpc.NewServer()
+	srv.Register(gs)
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+
+	conn, err := grpc.Dial(
+		"bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return NewClient(conn, opts...)
+}
+
+func TestClientServer(t *testing.T) {
+	cmk := aggregators.CombinedMetricsKey{
+		Interval:       time.Minute,
+		ProcessingTime: time.Unix(60, 0),
+		PartitionID:    3,
+		ID:             [16]byte{1, 2, 3},
+	}
+	cm := &aggregationpb.CombinedMetrics{
+		EventsTotal: 7,
+		ServiceMetrics: []*aggregationpb.KeyedServiceMetrics{{
+			Key: &aggregationpb.ServiceAggregationKey{ServiceName: "svc"},
+		}},
+	}
+
+	for _, compression := range []bool{false, true} {
+		agg := &fakeAggregator{}
+		client := newTestClient(t, NewServer(agg), WithCompression(compression))
+		require.NoError(t, client.AggregateCombinedMetrics(context.Background(), cmk, cm))
+		require.Len(t, agg.calls, 1)
+		assert.Equal(t, cmk.Interval, agg.calls[0].cmk.Interval)
+		assert.True(t, cmk.ProcessingTime.Equal(agg.calls[0].cmk.ProcessingTime))
+		assert.Equal(t, cmk.PartitionID, agg.calls[0].cmk.PartitionID)
+		assert.Equal(t, cmk.ID, agg.calls[0].cmk.ID)
+		assert.True(t, proto.Equal(cm, agg.calls[0].cm))
+	}
+}
+
+func TestServerAuth(t *testing.T) {
+	agg := &fakeAggregator{}
+	srv := NewServer(agg, WithAuthFunc(func(ctx context.Context, method string) error {
+		assert.Equal(t, "/elastic.apm.Aggregation/Aggregate", method)
+		md, _ := metadata.FromIncomingContext(ctx)
+		if tokens := md.Get("authorization"); len(tokens) == 1 && tokens[0] == "secret" {
+			return nil
+		}
+		return errors.New("invalid token")
+	}))
+	client := newTestClient(t, srv)
+
+	cmk := aggregators.CombinedMetricsKey{Interval: time.Minute, ProcessingTime: time.Unix(0, 0)}
+	cm := &aggregationpb.CombinedMetrics{}
+	err := client.AggregateCombinedMetrics(context.Background(), cmk, cm)
+	assert.Equal(t, codes.Unauthenticated, status.Code(errors.Unwrap(err)))
+	assert.Empty(t, agg.calls)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "secret")
+	assert.NoError(t, client.AggregateCombinedMetrics(ctx, cmk, cm))
+	assert.Len(t, agg.calls, 1)
+}
+
+func TestServerErrors(t *testing.T) {
+	cmk := aggregators.CombinedMetricsKey{Interval: time.Minute, ProcessingTime: time.Unix(0, 0)}
+	for _, tc := range []struct {
+		err      error
+		expected codes.Code
+	}{
+		{err: aggregators.ErrBackpressure, expected: codes.ResourceExhausted},
+		{err: aggregators.ErrAggregatorClosed, expected: codes.Unavailable},
+		{err: &aggregators.StaleProcessingTimeError{}, expected: codes.FailedPrecondition},
+		{err: &aggregators.CombinedMetricsTooLargeError{}, expected: codes.InvalidArgument},
+		{err: errors.New("boom"), expected: codes.Internal},
+	} {
+		client := newTestClient(t, NewServer(&fakeAggregator{err: tc.err}))
+		err := client.AggregateCombinedMetrics(context.Background(), cmk, &aggregationpb.CombinedMetrics{})
+		assert.Equal(t, tc.expected, status.Code(errors.Unwrap(err)), tc.err.Error())
+	}
+
+	srv := NewServer(&fakeAggregator{})
+	_, err := srv.Aggregate(context.Background(), &aggregationpb.AggregateRequest{Key: []byte{1, 2, 3}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
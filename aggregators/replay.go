@@ -0,0 +1,110 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+)
+
+// gzipMagic are the first bytes of a gzip stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// Replay reads the combined metrics of an archive file written by the
+// Archiver and passes them to the processor in the order they were
+// archived, with the aggregation interval of their key. Both archive
+// formats are supported, the format is detected from the content. Files
+// which are not gzip compressed are accepted too, e.g. the output of
+// ReadOnlyStore.DumpJSON.
+//
+// The archived metrics can be re-aggregated into a live aggregator, e.g.
+// for backfills, using the processor returned by
+// Aggregator.ReaggregateProcessor.
+func Replay(ctx context.Context, r io.Reader, processor Processor) error {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(len(gzipMagic)); bytes.Equal(magic, gzipMagic) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+		defer zr.Close()
+		br = bufio.NewReader(zr)
+	}
+
+	cm := aggregationpb.CombinedMetricsFromVTPool()
+	defer cm.ReturnToVTPool()
+	header, _ := br.Peek(len(archiveHeader))
+	keySize := CombinedMetricsKeyEncodedSize
+	if bytes.Equal(header, legacyArchiveHeader) {
+		keySize = legacyCombinedMetricsKeyEncodedSize
+	}
+	if bytes.Equal(header, archiveHeader) || bytes.Equal(header, legacyArchiveHeader) {
+		if _, err := br.Discard(len(archiveHeader)); err != nil {
+			return fmt.Errorf("failed to read archive header: %w", err)
+		}
+		rr := newRecordReader(br, "archive", keySize)
+		for {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			cm.ResetVT()
+			cmk, err := rr.next(cm)
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					return nil
+				}
+				return err
+			}
+			if err := processor(ctx, cmk, cm, cmk.Interval); err != nil {
+				return fmt.Errorf("failed to process replayed combined metrics: %w", err)
+			}
+		}
+	}
+
+	dec := json.NewDecoder(br)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		cm.ResetVT()
+		dumped := dumpedCombinedMetrics{Metrics: cm}
+		if err := dec.Decode(&dumped); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+		cmk, err := dumped.key()
+		if err != nil {
+			return err
+		}
+		if err := processor(ctx, cmk, cm, cmk.Interval); err != nil {
+			return fmt.Errorf("failed to process replayed combined metrics: %w", err)
+		}
+	}
+}
+
+// ReaggregateProcessor returns a Processor aggregating the processed
+// combined metrics into the aggregator with AggregateCombinedMetrics, e.g.
+// for re-aggregating archived metrics with Replay.
+func (a *Aggregator) ReaggregateProcessor() Processor {
+	return func(
+		ctx context.Context,
+		cmk CombinedMetricsKey,
+		cm *aggregationpb.CombinedMetrics,
+		_ time.Duration,
+	) error {
+		return a.AggregateCombinedMetrics(ctx, cmk, cm)
+	}
+}
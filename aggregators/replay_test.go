@@ -0,0 +1,108 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+)
+
+func TestReplay(t *testing.T) {
+	ctx := context.Background()
+	ivl := time.Minute
+	processingTime := time.Unix(1700000000, 0).UTC()
+	keys := []CombinedMetricsKey{
+		{Interval: ivl, ProcessingTime: processingTime, ID: EncodeToCombinedMetricsKeyID(t, "ab01")},
+		{Interval: ivl, ProcessingTime: processingTime, ID: EncodeToCombinedMetricsKeyID(t, "ab02"), PartitionID: 1},
+	}
+	cm := NewTestCombinedMetrics(WithEventsTotal(3)).
+		AddServiceMetrics(serviceAggregationKey{Timestamp: processingTime, ServiceName: "svc"}).
+		AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+		AddTransaction(transactionAggregationKey{
+			TransactionName: "txn",
+			TransactionType: "typ",
+		}, WithTransactionCount(3)).
+		GetProto()
+	defer cm.ReturnToVTPool()
+
+	archive := func(t *testing.T, format ArchiveFormat) string {
+		dir := t.TempDir()
+		archiver, err := NewArchiver(dir, WithArchiveFormat(format))
+		require.NoError(t, err)
+		for _, cmk := range keys {
+			require.NoError(t, archiver.Process(ctx, cmk, cm, ivl))
+		}
+		require.NoError(t, archiver.Close())
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		return filepath.Join(dir, entries[0].Name())
+	}
+
+	for _, format := range []ArchiveFormat{ArchiveNDJSON, ArchiveProtobuf} {
+		f, err := os.Open(archive(t, format))
+		require.NoError(t, err)
+		var replayed []CombinedMetricsKey
+		require.NoError(t, Replay(ctx, f, func(
+			_ context.Context,
+			cmk CombinedMetricsKey,
+			actual *aggregationpb.CombinedMetrics,
+			aggIvl time.Duration,
+		) error {
+			assert.Equal(t, ivl, aggIvl)
+			assert.Empty(t, cmp.Diff(cm, actual, protocmp.Transform()))
+			cmk.ProcessingTime = cmk.ProcessingTime.UTC()
+			replayed = append(replayed, cmk)
+			return nil
+		}))
+		require.NoError(t, f.Close())
+		assert.Equal(t, keys, replayed, "format %d", format)
+	}
+
+	t.Run("reaggregate", func(t *testing.T) {
+		var eventsTotal float64
+		agg, err := New(
+			WithDataDir(t.TempDir()),
+			WithAggregationIntervals([]time.Duration{ivl}),
+			WithProcessor(func(
+				_ context.Context,
+				_ CombinedMetricsKey,
+				cm *aggregationpb.CombinedMetrics,
+				_ time.Duration,
+			) error {
+				eventsTotal += cm.EventsTotal
+				return nil
+			}),
+			WithLogger(zap.NewNop()),
+		)
+		require.NoError(t, err)
+		f, err := os.Open(archive(t, ArchiveProtobuf))
+		require.NoError(t, err)
+		defer f.Close()
+		require.NoError(t, Replay(ctx, f, agg.ReaggregateProcessor()))
+		// The archived periods are long past, drain them on close.
+		report, err := agg.CloseWithDrain(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, DrainReport{Flushed: 2}, report)
+		assert.Equal(t, float64(6), eventsTotal)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		err := Replay(ctx, strings.NewReader(`{"interval":"1x"}`), noOpProcessor())
+		assert.EqualError(t, err, `failed to parse interval: time: unknown unit "x" in duration "1x"`)
+	})
+}
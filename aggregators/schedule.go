@@ -0,0 +1,96 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// HarvestSchedule describes the upcoming harvests of the aggregator, see
+// NextHarvest.
+type HarvestSchedule struct {
+	// Next is the time of the next harvest per aggregation interval,
+	// including the harvest delay and offset but not the random jitter.
+	// The time is in the past if the harvest is overdue, e.g. if Run is
+	// not running or the harvest loop is stuck.
+	Next map[time.Duration]time.Time
+
+	// PendingPeriods is the number of processing time buckets with
+	// aggregated metrics which are not harvested yet, per aggregation
+	// interval. The current period is counted if it has writes pending to
+	// be committed. More than a few pending periods indicate harvests
+	// falling behind or failing.
+	PendingPeriods map[time.Duration]int
+}
+
+// Overdue returns the aggregation intervals whose next harvest is more
+// than the given grace period before now, e.g. to detect a stuck harvest
+// loop.
+func (s HarvestSchedule) Overdue(now time.Time, grace time.Duration) []time.Duration {
+	var overdue []time.Duration
+	for ivl, next := range s.Next {
+		if now.Sub(next) > grace {
+			overdue = append(overdue, ivl)
+		}
+	}
+	slices.Sort(overdue)
+	return overdue
+}
+
+// NextHarvest returns the schedule of the next harvests, e.g. for
+// displaying when the aggregated metrics are due. Counting the pending
+// periods iterates the stored combined metrics, NextHarvest is meant to be
+// called periodically rather than on a hot path.
+func (a *Aggregator) NextHarvest() (HarvestSchedule, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	select {
+	case <-a.closed:
+		return HarvestSchedule{}, ErrAggregatorClosed
+	default:
+	}
+
+	schedule := HarvestSchedule{
+		Next:           make(map[time.Duration]time.Time, len(a.cfg.AggregationIntervals)),
+		PendingPeriods: make(map[time.Duration]int, len(a.cfg.AggregationIntervals)),
+	}
+	for _, ivl := range a.cfg.AggregationIntervals {
+		// The processing time is advanced by the harvest loop at every
+		// lowest aggregation interval, the current period of the interval
+		// is harvested once it ends.
+		end := a.processingTime.Truncate(ivl)
+		// Offsets not less than the lowest aggregation interval delay the
+		// harvest of the previous period past the following processing
+		// times.
+		lag := a.cfg.HarvestOffsets[ivl].Truncate(a.cfg.AggregationIntervals[0])
+		if lag == 0 || a.processingTime.After(end.Add(lag)) {
+			end = end.Add(ivl)
+		}
+		schedule.Next[ivl] = end.Add(a.cfg.HarvestDelay + a.cfg.HarvestOffsets[ivl])
+
+		periods, err := a.storedPeriods(ivl)
+		if err != nil {
+			return HarvestSchedule{}, err
+		}
+		pending := len(periods)
+		if a.pendingBatchBytes() > 0 {
+			current := a.processingTime.Truncate(ivl)
+			stored := false
+			for _, p := range periods {
+				if p.processingTime.Equal(current) {
+					stored = true
+					break
+				}
+			}
+			if !stored {
+				pending++
+			}
+		}
+		schedule.PendingPeriods[ivl] = pending
+	}
+	return schedule, nil
+}
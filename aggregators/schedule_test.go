@@ -0,0 +1,105 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/elastic/apm-data/model/modelpb"
+)
+
+// fixedClock is a Clock whose time is fixed, its timers use the wall clock.
+type fixedClock struct {
+	realClock
+	now time.Time
+}
+
+func (c fixedClock) Now() time.Time {
+	return c.now
+}
+
+func TestNextHarvest(t *testing.T) {
+	ctx := context.Background()
+	start := time.Unix(1700000000, 0).Truncate(10 * time.Minute)
+	agg, err := New(
+		WithDataDir(t.TempDir()),
+		WithAggregationIntervals([]time.Duration{time.Minute, 10 * time.Minute}),
+		WithHarvestDelay(5*time.Second),
+		WithClock(fixedClock{now: start.Add(30 * time.Second)}),
+		WithProcessor(noOpProcessor()),
+		WithLogger(zap.NewNop()),
+	)
+	require.NoError(t, err)
+
+	schedule, err := agg.NextHarvest()
+	require.NoError(t, err)
+	assert.Equal(t, HarvestSchedule{
+		Next: map[time.Duration]time.Time{
+			time.Minute:      start.Add(time.Minute + 5*time.Second),
+			10 * time.Minute: start.Add(10*time.Minute + 5*time.Second),
+		},
+		PendingPeriods: map[time.Duration]int{time.Minute: 0, 10 * time.Minute: 0},
+	}, schedule)
+	assert.Empty(t, schedule.Overdue(start.Add(time.Minute), 10*time.Second))
+	assert.Equal(t,
+		[]time.Duration{time.Minute},
+		schedule.Overdue(start.Add(2*time.Minute), 10*time.Second),
+	)
+
+	batch := modelpb.Batch{lateTestEvent(start.Add(time.Second))}
+	require.NoError(t, agg.AggregateBatch(ctx, EncodeToCombinedMetricsKeyID(t, "ab01"), &batch))
+	// The current period is pending with uncommitted writes.
+	schedule, err = agg.NextHarvest()
+	require.NoError(t, err)
+	assert.Equal(t, map[time.Duration]int{time.Minute: 1, 10 * time.Minute: 1}, schedule.PendingPeriods)
+
+	// The current period is counted once committed.
+	it, err := agg.NewIterator(IteratorOptions{})
+	require.NoError(t, err)
+	require.NoError(t, it.Close())
+	require.NoError(t, agg.AggregateBatch(ctx, EncodeToCombinedMetricsKeyID(t, "ab02"), &batch))
+	schedule, err = agg.NextHarvest()
+	require.NoError(t, err)
+	assert.Equal(t, map[time.Duration]int{time.Minute: 1, 10 * time.Minute: 1}, schedule.PendingPeriods)
+
+	require.NoError(t, agg.Close(ctx))
+	_, err = agg.NextHarvest()
+	assert.ErrorIs(t, err, ErrAggregatorClosed)
+}
+
+func TestNextHarvestLaggingOffset(t *testing.T) {
+	start := time.Unix(1700000000, 0).Truncate(time.Hour)
+	agg, err := New(
+		WithDataDir(t.TempDir()),
+		WithAggregationIntervals([]time.Duration{time.Minute, time.Hour}),
+		WithHarvestOffsets(map[time.Duration]time.Duration{time.Hour: 5 * time.Minute}),
+		WithClock(fixedClock{now: start.Add(2*time.Minute + 30*time.Second)}),
+		WithProcessor(noOpProcessor()),
+		WithLogger(zap.NewNop()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { agg.Close(context.Background()) })
+
+	// The previous hour is harvested 5 minutes after it ended.
+	schedule, err := agg.NextHarvest()
+	require.NoError(t, err)
+	assert.Equal(t, map[time.Duration]time.Time{
+		time.Minute: start.Add(3 * time.Minute),
+		time.Hour:   start.Add(5 * time.Minute),
+	}, schedule.Next)
+
+	agg.mu.Lock()
+	agg.processingTime = start.Add(6 * time.Minute)
+	agg.mu.Unlock()
+	schedule, err = agg.NextHarvest()
+	require.NoError(t, err)
+	assert.Equal(t, start.Add(time.Hour+5*time.Minute), schedule.Next[time.Hour])
+}
@@ -0,0 +1,269 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/cockroachdb/pebble"
+	"go.uber.org/zap"
+)
+
+// openShards opens a database in the data directory and in each of the
+// shard data directories, the first database being the primary shard.
+// A new set of pebble options is created for each shard.
+func openShards(cfg Config, newOptions func() *pebble.Options) ([]*pebble.DB, error) {
+	dirs := append([]string{cfg.DataDir}, cfg.ShardDataDirs...)
+	shards := make([]*pebble.DB, 0, len(dirs))
+	for _, dir := range dirs {
+		db, err := pebble.Open(dir, newOptions())
+		if err != nil {
+			for _, shard := range shards {
+				shard.Close()
+			}
+			return nil, fmt.Errorf("failed to open pebble db in %s: %w", dir, err)
+		}
+		shards = append(shards, db)
+	}
+	return shards, nil
+}
+
+// shardFor returns the shard storing the combined metrics of the ID, the
+// cold tier database if the ID is cold, see WithColdTier.
+func (a *Aggregator) shardFor(id [16]byte) *pebble.DB {
+	return a.shards[a.shardIndex(id)]
+}
+
+// shardIndex returns the index of the shard storing the combined metrics
+// of the ID, see shardFor.
+func (a *Aggregator) shardIndex(id [16]byte) int {
+	n := len(a.shards)
+	if a.cold != nil {
+		// The cold tier database is the last of the shards.
+		if a.tiers.isCold(id) {
+			return n - 1
+		}
+		n--
+	}
+	if n == 1 {
+		return 0
+	}
+	// The upper bits of the hash are used so that the IDs of a shard are
+	// still distributed across all the harvest workers, which use the
+	// lower bits, see WithHarvestConcurrency.
+	return int((xxhash.Sum64(id[:]) >> 32) % uint64(n))
+}
+
+// shardBatch is the batch of the pending writes to a shard. The writes
+// are routed to the batch of their shard as they are aggregated. Each
+// shard batch is guarded by its own lock, so that committing the writes
+// to a shard does not block the writes to the other shards.
+//
+// Routing the writes at aggregation time is safe with the cold tier, the
+// IDs moved to the cold tier are idle and have no pending writes.
+type shardBatch struct {
+	mu    sync.Mutex
+	shard *pebble.DB
+	batch *pebble.Batch
+	// created is the time the batch was created at, the batch is flushed
+	// by flushTimer once the flush interval has passed, see
+	// WithWriteCoalescing. The timer is reused across batches.
+	created    time.Time
+	flushTimer Timer
+}
+
+// newShardBatches returns the batches of the shards.
+func newShardBatches(shards []*pebble.DB) []*shardBatch {
+	batches := make([]*shardBatch, len(shards))
+	for i, shard := range shards {
+		batches[i] = &shardBatch{shard: shard}
+	}
+	return batches
+}
+
+// pendingBatches are the batches taken from the shard batches, indexed by
+// shard. The batch of a shard without pending writes is nil.
+type pendingBatches []*pebble.Batch
+
+// len returns the total size of the batches.
+func (b pendingBatches) len() int {
+	var n int
+	for _, batch := range b {
+		if batch != nil {
+			n += batch.Len()
+		}
+	}
+	return n
+}
+
+// close closes the batches.
+func (b pendingBatches) close() error {
+	var errs []error
+	for _, batch := range b {
+		if batch != nil {
+			if err := batch.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// batchFor returns the batch of the shard storing the combined metrics
+// of the ID. Must be called with the lock held, as the shard depends on
+// the tier of the ID.
+func (a *Aggregator) batchFor(id [16]byte) *shardBatch {
+	return a.batches[a.shardIndex(id)]
+}
+
+// takeBatches takes the batches of the shards with pending writes, nil if
+// there are no pending writes.
+func (a *Aggregator) takeBatches() pendingBatches {
+	var taken pendingBatches
+	for i, sb := range a.batches {
+		sb.mu.Lock()
+		if sb.batch != nil {
+			if taken == nil {
+				taken = make(pendingBatches, len(a.batches))
+			}
+			taken[i] = sb.batch
+			sb.batch = nil
+		}
+		sb.mu.Unlock()
+	}
+	return taken
+}
+
+// pendingBatchBytes returns the size of the pending writes of the shards
+// which are not yet taken for committing.
+func (a *Aggregator) pendingBatchBytes() int64 {
+	var n int64
+	for _, sb := range a.batches {
+		sb.mu.Lock()
+		if sb.batch != nil {
+			n += int64(sb.batch.Len())
+		}
+		sb.mu.Unlock()
+	}
+	return n
+}
+
+// commitBatch commits the batches of the shards concurrently. The batches
+// are not closed.
+//
+// The batches are committed independently, if the commit to a shard fails
+// then the writes to the other shards are still committed. The writes of
+// the failed shards are lost, the returned error reports the failed
+// shards.
+func (a *Aggregator) commitBatch(batches pendingBatches) error {
+	errs := make([]error, len(batches))
+	var wg sync.WaitGroup
+	for i, batch := range batches {
+		if batch == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, batch *pebble.Batch) {
+			defer wg.Done()
+			if err := batch.Commit(a.writeOptions); err != nil {
+				errs[i] = fmt.Errorf("failed to commit writes to shard %d: %w", i, err)
+			}
+		}(i, batch)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// flushBatches commits and releases the pending writes of all the shards,
+// waking up the writers blocked on pending bytes.
+func (a *Aggregator) flushBatches() error {
+	var errs []error
+	for _, sb := range a.batches {
+		sb.mu.Lock()
+		if sb.batch != nil {
+			if err := a.flushShardBatch(sb); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		sb.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// flushShardBatch commits and releases the batch of the shard, waking up
+// the writers blocked on pending bytes. Must be called with the lock of
+// the shard batch held.
+func (a *Aggregator) flushShardBatch(sb *shardBatch) error {
+	if err := a.health.recordStorageError(sb.batch.Commit(a.writeOptions)); err != nil {
+		return fmt.Errorf("failed to commit pebble batch: %w", err)
+	}
+	if err := sb.batch.Close(); err != nil {
+		return fmt.Errorf("failed to close pebble batch: %w", err)
+	}
+	sb.batch = nil
+	a.signalPendingReleased()
+	return nil
+}
+
+// flushBatch commits the coalesced writes of the shard batch once the
+// flush interval has passed since it was created. The batch is not
+// flushed if it was created after the timer fired, the timer is reset for
+// the new batch.
+func (a *Aggregator) flushBatch(sb *shardBatch) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	select {
+	case <-a.closed:
+		return
+	default:
+	}
+	if sb.batch == nil || a.cfg.Clock.Now().Sub(sb.created) < a.cfg.FlushInterval {
+		return
+	}
+	if err := a.flushShardBatch(sb); err != nil {
+		a.cfg.Logger.Warn("failed to flush coalesced writes", zap.Error(err))
+	}
+}
+
+// requeueBatches puts back the batches taken from the shard batches,
+// merging the writes added to the shard batches since they were taken
+// into them.
+func (a *Aggregator) requeueBatches(batches pendingBatches) {
+	for i, batch := range batches {
+		if batch == nil {
+			continue
+		}
+		sb := a.batches[i]
+		sb.mu.Lock()
+		if sb.batch != nil {
+			if err := batch.Apply(sb.batch, nil); err != nil {
+				a.cfg.Logger.Warn("failed to re-queue batch of crashed harvest", zap.Error(err))
+				batch.Close()
+				sb.mu.Unlock()
+				continue
+			}
+			sb.batch.Close()
+		}
+		sb.batch = batch
+		sb.mu.Unlock()
+	}
+}
+
+// deleteRange deletes the keys in the range [start, end) from all the
+// shards.
+func (a *Aggregator) deleteRange(start, end []byte) error {
+	var errs []error
+	for _, shard := range a.shards {
+		if err := shard.DeleteRange(start, end, a.writeOptions); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
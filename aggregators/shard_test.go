@@ -0,0 +1,120 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+)
+
+func TestShardedAggregator(t *testing.T) {
+	ctx := context.Background()
+	ivl := time.Second
+	var processed [][16]byte
+	agg, err := New(
+		WithDataDir(t.TempDir()),
+		WithShardDataDirs([]string{t.TempDir(), t.TempDir()}),
+		WithLimits(Limits{
+			MaxServices:                        10,
+			MaxServiceInstanceGroupsPerService: 10,
+		}),
+		WithAggregationIntervals([]time.Duration{ivl}),
+		WithProcessor(func(
+			_ context.Context,
+			cmk CombinedMetricsKey,
+			_ *aggregationpb.CombinedMetrics,
+			_ time.Duration,
+		) error {
+			processed = append(processed, cmk.ID)
+			return nil
+		}),
+		WithLogger(zap.NewNop()),
+	)
+	require.NoError(t, err)
+	defer agg.Close(ctx)
+	require.Len(t, agg.shards, 3)
+
+	start := time.Unix(agg.processingTime.Unix(), 0)
+	var ids [][16]byte
+	for i := 0; i < 32; i++ {
+		id := EncodeToCombinedMetricsKeyID(t, fmt.Sprintf("ab%02d", i))
+		ids = append(ids, id)
+		cm := NewTestCombinedMetrics(WithEventsTotal(1)).
+			AddServiceMetrics(serviceAggregationKey{Timestamp: start, ServiceName: "svc"}).
+			AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+			GetProto()
+		require.NoError(t, agg.AggregateCombinedMetrics(ctx, CombinedMetricsKey{
+			Interval:       ivl,
+			ProcessingTime: start,
+			ID:             id,
+		}, cm))
+		cm.ReturnToVTPool()
+	}
+
+	// The writes are routed to the batch of their shard as they are
+	// aggregated.
+	for i, sb := range agg.batches {
+		require.NotNil(t, sb.batch)
+		reader := sb.batch.Reader()
+		for {
+			_, key, _, ok := reader.Next()
+			if !ok {
+				break
+			}
+			var cmk CombinedMetricsKey
+			require.NoError(t, cmk.UnmarshalBinary(key))
+			assert.Equal(t, i, agg.shardIndex(cmk.ID))
+		}
+	}
+
+	// The iterator commits the batches and iterates all shards in key order.
+	it, err := agg.NewIterator(IteratorOptions{})
+	require.NoError(t, err)
+	var iterated [][16]byte
+	for valid := it.First(); valid; valid = it.Next() {
+		iterated = append(iterated, it.Key().ID)
+	}
+	require.NoError(t, it.Error())
+	require.NoError(t, it.Close())
+	sort.Slice(ids, func(i, j int) bool {
+		return string(ids[i][:]) < string(ids[j][:])
+	})
+	assert.Equal(t, ids, iterated)
+
+	// Each shard only stores the combined metrics of its IDs.
+	for _, shard := range agg.shards {
+		var count int
+		iter := shard.NewIter(&pebble.IterOptions{LowerBound: combinedMetricsLowerBound})
+		for iter.First(); iter.Valid(); iter.Next() {
+			var cmk CombinedMetricsKey
+			require.NoError(t, cmk.UnmarshalBinary(iter.Key()))
+			assert.Equal(t, shard, agg.shardFor(cmk.ID))
+			count++
+		}
+		require.NoError(t, iter.Close())
+		assert.NotZero(t, count)
+	}
+
+	require.NoError(t, agg.commitAndHarvest(ctx, nil, start.Add(ivl), []time.Duration{ivl}, nil))
+	assert.ElementsMatch(t, ids, processed)
+	for _, shard := range agg.shards {
+		iter := shard.NewIter(&pebble.IterOptions{LowerBound: combinedMetricsLowerBound})
+		assert.False(t, iter.First())
+		require.NoError(t, iter.Close())
+	}
+	checkpoints, err := agg.Checkpoint()
+	require.NoError(t, err)
+	assert.Len(t, checkpoints, len(ids))
+}
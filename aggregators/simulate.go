@@ -0,0 +1,327 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"runtime"
+	"strconv"
+	"time"
+
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+	"github.com/elastic/apm-data/model/modelpb"
+)
+
+// simulationStart is the processing time of the first simulated period,
+// fixed so that simulations are reproducible.
+var simulationStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// simulationBatchSize is the number of transactions, with their spans and
+// errors, aggregated per batch by Simulate.
+const simulationBatchSize = 100
+
+// Workload describes the synthetic events generated by Simulate.
+type Workload struct {
+	// Periods is the number of lowest aggregation intervals simulated.
+	Periods int
+	// TransactionsPerPeriod is the number of transactions generated per
+	// lowest aggregation interval, across all the services.
+	TransactionsPerPeriod int
+	// Services is the number of distinct services.
+	Services int
+	// TransactionGroups is the number of distinct transaction names per
+	// service.
+	TransactionGroups int
+	// SpanFanOut is the number of spans per transaction, each with a
+	// distinct name and destination per transaction group.
+	SpanFanOut int
+	// ErrorRate is the fraction of failed transactions, each failed
+	// transaction reports an error.
+	ErrorRate float64
+	// ErrorGroups is the number of distinct error grouping keys per
+	// service. Defaults to 1.
+	ErrorGroups int
+	// LabelCardinality is the number of distinct values of a global label
+	// per service, each value making a service instance group. Defaults
+	// to 0, no global labels.
+	LabelCardinality int
+	// Seed seeds the random selection of the service, transaction group,
+	// label value and outcome of each transaction.
+	Seed int64
+}
+
+// SimulationReport is the outcome of a simulation.
+type SimulationReport struct {
+	// Events is the number of simulated APM events.
+	Events int
+	// Harvested holds the counts of the harvested metrics per
+	// aggregation interval.
+	Harvested map[time.Duration]SimulatedHarvest
+	// Overflow holds the estimated number of groups folded into overflow
+	// buckets per limit, e.g. `transaction_groups`, across the harvests.
+	Overflow map[string]uint64
+	// EventsLost is the total of the lost events reported by the event
+	// loss accounting, including the overflowed events.
+	EventsLost float64
+	// PeakDiskUsage is the peak disk space used by the database, in bytes,
+	// sampled after each simulated period.
+	PeakDiskUsage uint64
+	// PeakHeapInuse is the peak of the in use heap of the process, in
+	// bytes, sampled after each simulated period.
+	PeakHeapInuse uint64
+}
+
+// SimulatedHarvest holds the counts of the metrics harvested for an
+// aggregation interval during a simulation.
+type SimulatedHarvest struct {
+	CombinedMetrics          int
+	Services                 int
+	ServiceInstances         int
+	TransactionGroups        int
+	ServiceTransactionGroups int
+	SpanGroups               int
+	ErrorGroups              int
+}
+
+// Simulate feeds the synthetic workload through an aggregator created with
+// the given options, for capacity planning of the limits. The simulation
+// runs on a simulated clock: each period is aggregated and harvested as by
+// Run, without waiting for the aggregation intervals to elapse, so that
+// simulations are deterministic and fast. The processor and event loss
+// handler are set by the simulation. The database is stored in a temporary
+// directory unless configured otherwise, and the aggregator is closed once
+// the simulation is completed.
+func Simulate(ctx context.Context, w Workload, opts ...Option) (SimulationReport, error) {
+	if err := w.validate(); err != nil {
+		return SimulationReport{}, err
+	}
+	dir, err := os.MkdirTemp("", "apm-aggregation-simulation-")
+	if err != nil {
+		return SimulationReport{}, fmt.Errorf("failed to create simulation directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	report := SimulationReport{
+		Harvested: make(map[time.Duration]SimulatedHarvest),
+		Overflow:  make(map[string]uint64),
+	}
+	opts = append([]Option{WithDataDir(dir)}, opts...)
+	opts = append(opts,
+		WithProcessor(func(
+			_ context.Context,
+			_ CombinedMetricsKey,
+			cm *aggregationpb.CombinedMetrics,
+			ivl time.Duration,
+		) error {
+			report.Harvested[ivl] = report.Harvested[ivl].add(cm)
+			var overflow overflowCounts
+			overflow.add(cm)
+			for limit, n := range overflow {
+				if n > 0 {
+					report.Overflow[overflowLimitNames[limit]] += n
+				}
+			}
+			return nil
+		}),
+		WithEventLossHandler(func(loss EventLoss) {
+			report.EventsLost += loss.Total()
+		}),
+	)
+	a, err := New(opts...)
+	if err != nil {
+		return report, err
+	}
+	err = a.simulate(ctx, w, &report)
+	// Closing harvests the periods of the higher aggregation intervals
+	// which are in progress at the end of the simulation.
+	if closeErr := a.Close(ctx); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to close simulated aggregator: %w", closeErr)
+	}
+	return report, err
+}
+
+// simulate aggregates and harvests the simulated periods.
+func (a *Aggregator) simulate(ctx context.Context, w Workload, report *SimulationReport) error {
+	lowest := a.cfg.AggregationIntervals[0]
+	groups := a.harvestGroups()
+	gen := newWorkloadGenerator(w)
+	a.mu.Lock()
+	a.processingTime = simulationStart
+	a.mu.Unlock()
+	for period := 0; period < w.Periods; period++ {
+		start := simulationStart.Add(time.Duration(period) * lowest)
+		for i := 0; i < w.TransactionsPerPeriod; i += simulationBatchSize {
+			n := simulationBatchSize
+			if remaining := w.TransactionsPerPeriod - i; remaining < n {
+				n = remaining
+			}
+			batch := gen.batch(start, lowest, n)
+			report.Events += len(batch)
+			if err := a.AggregateBatch(ctx, simulationID, &batch); err != nil {
+				return fmt.Errorf("failed to aggregate simulated events: %w", err)
+			}
+		}
+
+		end := start.Add(lowest)
+		for i, group := range groups {
+			ivls := group.endingAt(end)
+			if i > 0 && len(ivls) == 0 {
+				continue
+			}
+			a.mu.Lock()
+			batch := a.takeBatches()
+			a.processingTime = end
+			var cachedEventsStats map[time.Duration]map[[16]byte]float64
+			if i == 0 {
+				cachedEventsStats = a.cachedEvents.loadAndDelete(end)
+			}
+			a.mu.Unlock()
+			if err := a.commitAndHarvest(ctx, batch, end, ivls, cachedEventsStats); err != nil {
+				return fmt.Errorf("failed to harvest simulated period: %w", err)
+			}
+		}
+		report.sampleUsage(a)
+	}
+	return nil
+}
+
+// simulationID is the combined metrics ID of the simulated events.
+var simulationID = [16]byte{'s', 'i', 'm'}
+
+func (w Workload) validate() error {
+	switch {
+	case w.Periods <= 0:
+		return errors.New("simulated periods must be greater than zero")
+	case w.TransactionsPerPeriod < 0:
+		return errors.New("simulated transactions per period must not be negative")
+	case w.Services <= 0:
+		return errors.New("simulated services must be greater than zero")
+	case w.TransactionGroups <= 0:
+		return errors.New("simulated transaction groups must be greater than zero")
+	case w.SpanFanOut < 0:
+		return errors.New("simulated span fan-out must not be negative")
+	case w.ErrorRate < 0 || w.ErrorRate > 1:
+		return errors.New("simulated error rate must be within [0, 1]")
+	case w.ErrorGroups < 0:
+		return errors.New("simulated error groups must not be negative")
+	case w.LabelCardinality < 0:
+		return errors.New("simulated label cardinality must not be negative")
+	}
+	return nil
+}
+
+func (h SimulatedHarvest) add(cm *aggregationpb.CombinedMetrics) SimulatedHarvest {
+	h.CombinedMetrics++
+	for _, ksm := range cm.ServiceMetrics {
+		h.Services++
+		for _, ksim := range ksm.GetMetrics().GetServiceInstanceMetrics() {
+			sim := ksim.GetMetrics()
+			h.ServiceInstances++
+			h.TransactionGroups += len(sim.GetTransactionMetrics())
+			h.ServiceTransactionGroups += len(sim.GetServiceTransactionMetrics())
+			h.SpanGroups += len(sim.GetSpanMetrics())
+			h.ErrorGroups += len(sim.GetErrorMetrics())
+		}
+	}
+	return h
+}
+
+func (r *SimulationReport) sampleUsage(a *Aggregator) {
+	var disk uint64
+	for _, shard := range a.shards {
+		disk += shard.Metrics().DiskSpaceUsage()
+	}
+	if disk > r.PeakDiskUsage {
+		r.PeakDiskUsage = disk
+	}
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	if ms.HeapInuse > r.PeakHeapInuse {
+		r.PeakHeapInuse = ms.HeapInuse
+	}
+}
+
+// workloadGenerator generates the events of a workload.
+type workloadGenerator struct {
+	w   Workload
+	rnd *rand.Rand
+}
+
+func newWorkloadGenerator(w Workload) *workloadGenerator {
+	if w.ErrorGroups == 0 {
+		w.ErrorGroups = 1
+	}
+	return &workloadGenerator{w: w, rnd: rand.New(rand.NewSource(w.Seed))}
+}
+
+// batch generates n transactions, with their spans and errors, timestamped
+// within the period starting at the given time.
+func (g *workloadGenerator) batch(start time.Time, ivl time.Duration, n int) modelpb.Batch {
+	batch := make(modelpb.Batch, 0, n*(1+g.w.SpanFanOut))
+	for i := 0; i < n; i++ {
+		svc := "service-" + strconv.Itoa(g.rnd.Intn(g.w.Services))
+		txnName := "transaction-" + strconv.Itoa(g.rnd.Intn(g.w.TransactionGroups))
+		var labels modelpb.Labels
+		if g.w.LabelCardinality > 0 {
+			labels = modelpb.Labels{"simulated": &modelpb.LabelValue{
+				Global: true,
+				Value:  strconv.Itoa(g.rnd.Intn(g.w.LabelCardinality)),
+			}}
+		}
+		outcome := "success"
+		failed := g.rnd.Float64() < g.w.ErrorRate
+		if failed {
+			outcome = "failure"
+		}
+		ts := timestamppb.New(start.Add(time.Duration(g.rnd.Int63n(int64(ivl)))))
+		event := func() *modelpb.APMEvent {
+			return &modelpb.APMEvent{
+				Timestamp: ts,
+				Service:   &modelpb.Service{Name: svc},
+				Labels:    labels,
+				Event: &modelpb.Event{
+					Duration: durationpb.New(time.Duration(1+g.rnd.Intn(1000)) * time.Millisecond),
+					Outcome:  outcome,
+				},
+			}
+		}
+
+		txn := event()
+		txn.Transaction = &modelpb.Transaction{
+			Name:                txnName,
+			Type:                "request",
+			RepresentativeCount: 1,
+		}
+		batch = append(batch, txn)
+		for j := 0; j < g.w.SpanFanOut; j++ {
+			span := event()
+			span.ParentId = "parent"
+			span.Span = &modelpb.Span{
+				Name:                txnName + "-span-" + strconv.Itoa(j),
+				Type:                "db",
+				RepresentativeCount: 1,
+				DestinationService: &modelpb.DestinationService{
+					Resource: "destination-" + strconv.Itoa(j),
+				},
+			}
+			batch = append(batch, span)
+		}
+		if failed {
+			e := event()
+			e.Error = &modelpb.Error{
+				GroupingKey: "error-" + strconv.Itoa(g.rnd.Intn(g.w.ErrorGroups)),
+			}
+			batch = append(batch, e)
+		}
+	}
+	return batch
+}
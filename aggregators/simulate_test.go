@@ -0,0 +1,84 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestSimulate(t *testing.T) {
+	ctx := context.Background()
+	workload := Workload{
+		Periods:               3,
+		TransactionsPerPeriod: 250,
+		Services:              2,
+		TransactionGroups:     3,
+		SpanFanOut:            2,
+		ErrorRate:             0.5,
+		ErrorGroups:           2,
+		LabelCardinality:      2,
+		Seed:                  1,
+	}
+	limits := Limits{
+		MaxServices:                           10,
+		MaxServiceInstanceGroupsPerService:    10,
+		MaxTransactionGroups:                  100,
+		MaxTransactionGroupsPerService:        100,
+		MaxServiceTransactionGroups:           100,
+		MaxServiceTransactionGroupsPerService: 100,
+		MaxSpanGroups:                         100,
+		MaxSpanGroupsPerService:               100,
+		MaxErrorGroups:                        100,
+		MaxErrorGroupsPerService:              100,
+	}
+	opts := []Option{
+		WithAggregationIntervals([]time.Duration{time.Minute, 10 * time.Minute}),
+		WithLimits(limits),
+		WithLogger(zap.NewNop()),
+	}
+
+	report, err := Simulate(ctx, workload, opts...)
+	require.NoError(t, err)
+	assert.Greater(t, report.Events, 3*250*3)
+	// All the services, label values, transaction groups and spans are
+	// seen in every period.
+	assert.Equal(t, SimulatedHarvest{
+		CombinedMetrics:          3,
+		Services:                 3 * 2,
+		ServiceInstances:         3 * 2 * 2,
+		TransactionGroups:        3 * 2 * 2 * 3 * 2,
+		ServiceTransactionGroups: 3 * 2 * 2,
+		SpanGroups:               3 * 2 * 2 * 3 * 2 * 2,
+		ErrorGroups:              3 * 2 * 2 * 2,
+	}, report.Harvested[time.Minute])
+	// The 10m period in progress is harvested at the end.
+	assert.Equal(t, 1, report.Harvested[10*time.Minute].CombinedMetrics)
+	assert.Empty(t, report.Overflow)
+	assert.Zero(t, report.EventsLost)
+	assert.Positive(t, report.PeakDiskUsage)
+	assert.Positive(t, report.PeakHeapInuse)
+
+	// Simulations are deterministic.
+	again, err := Simulate(ctx, workload, opts...)
+	require.NoError(t, err)
+	assert.Equal(t, report.Events, again.Events)
+	assert.Equal(t, report.Harvested, again.Harvested)
+
+	limits.MaxTransactionGroups = 2
+	limits.MaxTransactionGroupsPerService = 2
+	overflowed, err := Simulate(ctx, workload, append(opts, WithLimits(limits))...)
+	require.NoError(t, err)
+	assert.Positive(t, overflowed.Overflow["transaction_groups"])
+	assert.Positive(t, overflowed.EventsLost)
+
+	_, err = Simulate(ctx, Workload{})
+	assert.EqualError(t, err, "simulated periods must be greater than zero")
+}
@@ -0,0 +1,223 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+)
+
+const (
+	sinkKey = "sink"
+
+	defaultSinkQueueSize = 1024
+)
+
+// ErrSinkQueueFull is passed to the FailureHandler of a sink for the
+// harvested combined metrics dropped as the queue of the sink was full.
+var ErrSinkQueueFull = errors.New("sink queue is full")
+
+// sinkQueue holds the harvested combined metrics queued for a sink.
+type sinkQueue struct {
+	sink Sink
+	jobs chan sinkJob
+	// done is closed once the goroutine of the sink returns.
+	done chan struct{}
+}
+
+type sinkJob struct {
+	ctx    context.Context
+	cmk    CombinedMetricsKey
+	cm     *aggregationpb.CombinedMetrics
+	aggIvl time.Duration
+}
+
+// sinkContext carries the values, e.g. the harvest chunk and the span, of
+// the harvest context without its cancellation, which is controlled by
+// the aggregator as the queued combined metrics outlive the harvest.
+type sinkContext struct {
+	context.Context
+	values context.Context
+}
+
+func (c sinkContext) Value(key any) any {
+	return c.values.Value(key)
+}
+
+// startSinks starts a goroutine processing the queue of each configured
+// sink, stopped by stopSinks.
+func (a *Aggregator) startSinks() {
+	if len(a.cfg.Sinks) == 0 {
+		return
+	}
+	a.sinkCtx, a.cancelSinks = context.WithCancel(context.Background())
+	a.sinks = make([]*sinkQueue, 0, len(a.cfg.Sinks))
+	for _, sink := range a.cfg.Sinks {
+		size := sink.QueueSize
+		if size == 0 {
+			size = defaultSinkQueueSize
+		}
+		q := &sinkQueue{
+			sink: sink,
+			jobs: make(chan sinkJob, size),
+			done: make(chan struct{}),
+		}
+		a.sinks = append(a.sinks, q)
+		go a.runSink(q)
+	}
+}
+
+// stopSinks waits for the sinks to process their queues. If the context
+// is done first, the pending retries are cancelled and the remaining
+// queued combined metrics are reported as failed.
+func (a *Aggregator) stopSinks(ctx context.Context) error {
+	if a.sinks == nil {
+		return nil
+	}
+	for _, q := range a.sinks {
+		close(q.jobs)
+	}
+	var err error
+	for _, q := range a.sinks {
+		select {
+		case <-q.done:
+			continue
+		case <-ctx.Done():
+			err = fmt.Errorf("context cancelled while waiting for sinks: %w", ctx.Err())
+		}
+		break
+	}
+	a.cancelSinks()
+	for _, q := range a.sinks {
+		<-q.done
+	}
+	a.sinks = nil
+	return err
+}
+
+// runSink processes the queued combined metrics with the sink until the
+// queue is closed.
+func (a *Aggregator) runSink(q *sinkQueue) {
+	defer close(q.done)
+	for job := range q.jobs {
+		if err := a.sinkCtx.Err(); err != nil {
+			a.metrics.SinkFailed.Add(job.ctx, 1, sinkAttrs(q.sink, job.aggIvl))
+			a.sinkFailed(q.sink, job.cmk, fmt.Errorf("sink %q failed: %w", q.sink.Name, err))
+		} else if err := a.processSink(job.ctx, q.sink, job.cmk, job.cm, job.aggIvl); err != nil {
+			a.cfg.Logger.Error(
+				"sink failed to process combined metrics",
+				zap.String(sinkKey, q.sink.Name),
+				zap.Error(err),
+			)
+			a.sinkFailed(q.sink, job.cmk, err)
+		}
+		job.cm.ReturnToVTPool()
+	}
+}
+
+// process processes the harvested combined metrics with the configured
+// processor, or queues them for the configured sinks. Sink failures are
+// reported through the telemetry and the FailureHandler of the sinks,
+// they do not fail the harvest.
+func (a *Aggregator) process(
+	ctx context.Context,
+	cmk CombinedMetricsKey,
+	cm *aggregationpb.CombinedMetrics,
+	aggIvl time.Duration,
+) error {
+	if len(a.cfg.Sinks) == 0 {
+		return a.cfg.Processor(ctx, cmk, cm, aggIvl)
+	}
+	jobCtx := sinkContext{Context: a.sinkCtx, values: ctx}
+	for _, q := range a.sinks {
+		job := sinkJob{ctx: jobCtx, cmk: cmk, cm: cm.CloneVT(), aggIvl: aggIvl}
+		select {
+		case q.jobs <- job:
+		default:
+			job.cm.ReturnToVTPool()
+			a.metrics.SinkDropped.Add(ctx, 1, sinkAttrs(q.sink, aggIvl))
+			a.cfg.Logger.Warn(
+				"sink queue is full, dropping combined metrics",
+				zap.String(sinkKey, q.sink.Name),
+			)
+			a.sinkFailed(q.sink, cmk, ErrSinkQueueFull)
+		}
+	}
+	return nil
+}
+
+// processSink processes the combined metrics with the sink, retrying as
+// configured. The sink is passed a copy of the combined metrics for each
+// attempt, as the combined metrics may be mutated by the processor.
+func (a *Aggregator) processSink(
+	ctx context.Context,
+	sink Sink,
+	cmk CombinedMetricsKey,
+	cm *aggregationpb.CombinedMetrics,
+	aggIvl time.Duration,
+) error {
+	attrSet := sinkAttrs(sink, aggIvl)
+	err := processCopy(ctx, sink.Processor, cmk, cm, aggIvl)
+	for attempt := 1; err != nil && attempt <= sink.MaxRetries; attempt++ {
+		a.cfg.Logger.Warn(
+			"sink failed to process combined metrics, retrying",
+			zap.String(sinkKey, sink.Name),
+			zap.Int("attempt", attempt),
+			zap.Duration("backoff", sink.RetryBackoff),
+			zap.Error(err),
+		)
+		timer := a.cfg.Clock.NewTimer(sink.RetryBackoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			a.metrics.SinkFailed.Add(ctx, 1, attrSet)
+			return fmt.Errorf("sink %q failed: %w", sink.Name, errors.Join(err, ctx.Err()))
+		case <-timer.C():
+		}
+		a.metrics.SinkRetries.Add(ctx, 1, attrSet)
+		err = processCopy(ctx, sink.Processor, cmk, cm, aggIvl)
+	}
+	if err != nil {
+		a.metrics.SinkFailed.Add(ctx, 1, attrSet)
+		return fmt.Errorf("sink %q failed: %w", sink.Name, err)
+	}
+	a.metrics.SinkProcessed.Add(ctx, 1, attrSet)
+	return nil
+}
+
+func (a *Aggregator) sinkFailed(sink Sink, cmk CombinedMetricsKey, err error) {
+	if sink.FailureHandler != nil {
+		sink.FailureHandler(cmk, err)
+	}
+}
+
+func sinkAttrs(sink Sink, aggIvl time.Duration) metric.MeasurementOption {
+	return metric.WithAttributeSet(attribute.NewSet(
+		attribute.String(sinkKey, sink.Name),
+		attribute.String(aggregationIvlKey, formatDuration(aggIvl)),
+	))
+}
+
+// processCopy calls the processor with a copy of the combined metrics,
+// released back to the pool once the processor returns.
+func processCopy(
+	ctx context.Context,
+	processor Processor,
+	cmk CombinedMetricsKey,
+	cm *aggregationpb.CombinedMetrics,
+	aggIvl time.Duration,
+) error {
+	clone := cm.CloneVT()
+	defer clone.ReturnToVTPool()
+	return processor(ctx, cmk, clone, aggIvl)
+}
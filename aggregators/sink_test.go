@@ -0,0 +1,179 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+	"github.com/elastic/apm-data/model/modelpb"
+)
+
+func TestSinks(t *testing.T) {
+	ctx := context.Background()
+	ivl := time.Minute
+	rdr := metric.NewManualReader()
+	var okEvents, failingCalls atomic.Int64
+	var failuresMu sync.Mutex
+	failures := make(map[string][]string)
+	ids := make(map[[16]byte]string)
+	for _, id := range []string{"ab01", "ab02", "ab03"} {
+		ids[EncodeToCombinedMetricsKeyID(t, id)] = id
+	}
+	failureHandler := func(sink string) func(CombinedMetricsKey, error) {
+		return func(cmk CombinedMetricsKey, err error) {
+			failuresMu.Lock()
+			defer failuresMu.Unlock()
+			failures[sink] = append(failures[sink], fmt.Sprintf("%s: %v", ids[cmk.ID], err))
+		}
+	}
+	blockedStarted := make(chan struct{}, 3)
+	releaseBlocked := make(chan struct{})
+	agg, err := New(
+		WithDataDir(t.TempDir()),
+		WithLimits(Limits{
+			MaxServices:                           10,
+			MaxServiceInstanceGroupsPerService:    10,
+			MaxTransactionGroups:                  10,
+			MaxTransactionGroupsPerService:        10,
+			MaxServiceTransactionGroups:           10,
+			MaxServiceTransactionGroupsPerService: 10,
+			MaxSpanGroups:                         10,
+			MaxSpanGroupsPerService:               10,
+		}),
+		WithAggregationIntervals([]time.Duration{ivl}),
+		WithSinks(
+			Sink{
+				Name: "ok",
+				Processor: func(
+					_ context.Context,
+					_ CombinedMetricsKey,
+					cm *aggregationpb.CombinedMetrics,
+					_ time.Duration,
+				) error {
+					okEvents.Add(int64(cm.EventsTotal))
+					// Mutating the combined metrics does not affect the
+					// other sinks.
+					cm.ResetVT()
+					return nil
+				},
+			},
+			Sink{
+				Name: "failing",
+				Processor: func(
+					_ context.Context,
+					_ CombinedMetricsKey,
+					cm *aggregationpb.CombinedMetrics,
+					_ time.Duration,
+				) error {
+					assert.Equal(t, float64(1), cm.EventsTotal)
+					failingCalls.Add(1)
+					return errors.New("sink failure")
+				},
+				MaxRetries:     2,
+				RetryBackoff:   time.Millisecond,
+				FailureHandler: failureHandler("failing"),
+			},
+			Sink{
+				Name: "blocked",
+				Processor: func(
+					_ context.Context,
+					_ CombinedMetricsKey,
+					_ *aggregationpb.CombinedMetrics,
+					_ time.Duration,
+				) error {
+					blockedStarted <- struct{}{}
+					<-releaseBlocked
+					return nil
+				},
+				QueueSize:      1,
+				FailureHandler: failureHandler("blocked"),
+			},
+		),
+		WithMeter(metric.NewMeterProvider(metric.WithReader(rdr)).Meter("test")),
+		WithLogger(zap.NewNop()),
+	)
+	require.NoError(t, err)
+	defer agg.Close(ctx)
+
+	start := time.Unix(agg.processingTime.Unix(), 0).Truncate(ivl)
+	harvest := func(end time.Time, ids ...string) {
+		agg.mu.Lock()
+		agg.processingTime = end.Add(-ivl)
+		agg.mu.Unlock()
+		for _, id := range ids {
+			batch := modelpb.Batch{lateTestEvent(end.Add(-ivl).Add(time.Second))}
+			require.NoError(t, agg.AggregateBatch(ctx, EncodeToCombinedMetricsKeyID(t, id), &batch))
+		}
+		agg.mu.Lock()
+		pb := agg.takeBatches()
+		agg.mu.Unlock()
+		// Sink failures do not fail the harvest.
+		require.NoError(t, agg.commitAndHarvest(ctx, pb, end, []time.Duration{ivl}, nil))
+	}
+
+	harvest(start.Add(ivl), "ab01")
+	<-blockedStarted
+	// The blocked sink is processing ab01, ab02 fills its queue and ab03
+	// is dropped, neither blocking the harvest nor the other sinks.
+	harvest(start.Add(2*ivl), "ab02", "ab03")
+	assert.Eventually(t, func() bool { return okEvents.Load() == 3 }, 10*time.Second, time.Millisecond)
+	assert.Eventually(t, func() bool { return failingCalls.Load() == 9 }, 10*time.Second, time.Millisecond)
+
+	// Close waits for the sinks to process their queues.
+	close(releaseBlocked)
+	require.NoError(t, agg.Close(ctx))
+	assert.Len(t, blockedStarted, 1)
+	assert.Equal(t, int64(3), okEvents.Load())
+	assert.Equal(t, int64(9), failingCalls.Load())
+	assert.Equal(t, map[string][]string{
+		"failing": {
+			`ab01: sink "failing" failed: sink failure`,
+			`ab02: sink "failing" failed: sink failure`,
+			`ab03: sink "failing" failed: sink failure`,
+		},
+		"blocked": {"ab03: sink queue is full"},
+	}, failures)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, rdr.Collect(ctx, &rm))
+	type sinkMetric struct{ name, sink string }
+	actual := make(map[sinkMetric]int64)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			if !ok {
+				continue
+			}
+			for _, dp := range sum.DataPoints {
+				sink, ok := dp.Attributes.Value(attribute.Key(sinkKey))
+				if !ok {
+					continue
+				}
+				actual[sinkMetric{m.Name, sink.AsString()}] += dp.Value
+			}
+		}
+	}
+	assert.Equal(t, map[sinkMetric]int64{
+		{"aggregator.sink.processed", "ok"}:      3,
+		{"aggregator.sink.retries", "failing"}:   6,
+		{"aggregator.sink.failed", "failing"}:    3,
+		{"aggregator.sink.processed", "blocked"}: 2,
+		{"aggregator.sink.dropped", "blocked"}:   1,
+	}, actual)
+}
@@ -0,0 +1,189 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+)
+
+// snapshotHeader identifies the snapshot format written by Snapshot. The
+// last byte is the version of the format.
+var snapshotHeader = []byte("apm-aggregation-snapshot\x02")
+
+// legacySnapshotHeader identifies the snapshots written before the
+// combined metrics keys were versioned, see combinedMetricsKeyVersion.
+var legacySnapshotHeader = []byte("apm-aggregation-snapshot\x01")
+
+// maxSnapshotValueSize is the maximum size of a single combined metrics
+// accepted by RestoreSnapshot, guarding against allocating unbounded
+// memory for a corrupt snapshot.
+const maxSnapshotValueSize = 1 << 30
+
+// Snapshot writes a consistent, gzip compressed export of all the combined
+// metrics which have not been harvested yet to w. Buffered writes are
+// committed before the snapshot is taken, so the export includes all the
+// metrics aggregated before Snapshot was called. The snapshot can be
+// restored into another aggregator using RestoreSnapshot, for example to
+// move the aggregation state between nodes without losing partially filled
+// intervals. The aggregated metrics are not removed from the aggregator.
+func (a *Aggregator) Snapshot(ctx context.Context, w io.Writer) error {
+	it, err := a.NewIterator(IteratorOptions{})
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(snapshotHeader); err != nil {
+		return fmt.Errorf("failed to write snapshot header: %w", err)
+	}
+	var lenBuf [binary.MaxVarintLen64]byte
+	for valid := it.First(); valid; valid = it.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// Snapshots hold uncompressed values so that they can be restored
+		// independently of the compression dictionary.
+		value, err := it.codec.decode(it.cur.Value())
+		if err != nil {
+			return err
+		}
+		n := binary.PutUvarint(lenBuf[:], uint64(len(value)))
+		if _, err := zw.Write(it.cur.Key()); err != nil {
+			return fmt.Errorf("failed to write snapshot key: %w", err)
+		}
+		if _, err := zw.Write(lenBuf[:n]); err != nil {
+			return fmt.Errorf("failed to write snapshot value length: %w", err)
+		}
+		if _, err := zw.Write(value); err != nil {
+			return fmt.Errorf("failed to write snapshot value: %w", err)
+		}
+	}
+	if err := it.Error(); err != nil {
+		return fmt.Errorf("failed to iterate combined metrics: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to flush snapshot: %w", err)
+	}
+	return nil
+}
+
+// RestoreSnapshot aggregates the combined metrics exported by Snapshot into
+// the aggregator. The restored combined metrics are merged with any
+// metrics already aggregated for the same keys, subject to the limits of
+// the aggregator, and are harvested as usual. A snapshot can only be
+// restored into an aggregator with the same aggregation intervals for the
+// restored metrics to be harvested.
+func (a *Aggregator) RestoreSnapshot(ctx context.Context, r io.Reader) error {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	defer zr.Close()
+	br := bufio.NewReader(zr)
+
+	header := make([]byte, len(snapshotHeader))
+	if _, err := io.ReadFull(br, header); err != nil {
+		return fmt.Errorf("failed to read snapshot header: %w", err)
+	}
+	keySize := CombinedMetricsKeyEncodedSize
+	switch {
+	case bytes.Equal(header, snapshotHeader):
+	case bytes.Equal(header, legacySnapshotHeader):
+		keySize = legacyCombinedMetricsKeyEncodedSize
+	default:
+		return errors.New("invalid snapshot header")
+	}
+
+	rr := newRecordReader(br, "snapshot", keySize)
+	cm := aggregationpb.CombinedMetricsFromVTPool()
+	defer cm.ReturnToVTPool()
+	for {
+		cm.ResetVT()
+		cmk, err := rr.next(cm)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if err := a.AggregateCombinedMetrics(ctx, cmk, cm); err != nil {
+			return fmt.Errorf("failed to restore combined metrics: %w", err)
+		}
+	}
+}
+
+// recordReader reads the records of combined metrics written by Snapshot
+// and the Archiver, each made of the binary encoded key followed by the
+// length delimited protobuf encoded combined metrics.
+type recordReader struct {
+	br *bufio.Reader
+	// kind names the read format in the errors.
+	kind  string
+	key   []byte
+	value []byte
+}
+
+func newRecordReader(br *bufio.Reader, kind string, keySize int) *recordReader {
+	return &recordReader{
+		br:   br,
+		kind: kind,
+		key:  make([]byte, keySize),
+	}
+}
+
+// next reads the next record into cm, returning io.EOF once all the
+// records are read.
+func (r *recordReader) next(cm *aggregationpb.CombinedMetrics) (CombinedMetricsKey, error) {
+	var cmk CombinedMetricsKey
+	if _, err := io.ReadFull(r.br, r.key); err != nil {
+		if errors.Is(err, io.EOF) {
+			return cmk, io.EOF
+		}
+		return cmk, fmt.Errorf("failed to read %s key: %w", r.kind, err)
+	}
+	size, err := binary.ReadUvarint(r.br)
+	if err != nil {
+		return cmk, fmt.Errorf("failed to read %s value length: %w", r.kind, noEOF(err))
+	}
+	if size > maxSnapshotValueSize {
+		return cmk, fmt.Errorf("%s value of %d bytes exceeds the maximum size", r.kind, size)
+	}
+	if uint64(cap(r.value)) < size {
+		r.value = make([]byte, size)
+	}
+	r.value = r.value[:size]
+	if _, err := io.ReadFull(r.br, r.value); err != nil {
+		return cmk, fmt.Errorf("failed to read %s value: %w", r.kind, noEOF(err))
+	}
+	if err := cmk.UnmarshalBinary(r.key); err != nil {
+		return cmk, fmt.Errorf("failed to unmarshal combined metrics key: %w", err)
+	}
+	if err := cm.UnmarshalVT(r.value); err != nil {
+		return cmk, fmt.Errorf("failed to unmarshal combined metrics: %w", err)
+	}
+	if err := decodeSparseHistograms(cm); err != nil {
+		return cmk, fmt.Errorf("failed to unmarshal combined metrics: %w", err)
+	}
+	return cmk, nil
+}
+
+// noEOF converts io.EOF to io.ErrUnexpectedEOF, a snapshot must not end
+// within a record.
+func noEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
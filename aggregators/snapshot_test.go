@@ -0,0 +1,126 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+	"github.com/elastic/apm-data/model/modelpb"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	ctx := context.Background()
+	limits := Limits{
+		MaxServices:                           10,
+		MaxServiceInstanceGroupsPerService:    10,
+		MaxTransactionGroups:                  10,
+		MaxTransactionGroupsPerService:        10,
+		MaxServiceTransactionGroups:           10,
+		MaxServiceTransactionGroupsPerService: 10,
+	}
+	newAggregator := func(processor Processor) *Aggregator {
+		agg, err := New(
+			WithDataDir(t.TempDir()),
+			WithLimits(limits),
+			WithProcessor(processor),
+			WithAggregationIntervals([]time.Duration{time.Minute, time.Hour}),
+			WithLogger(zap.NewNop()),
+		)
+		require.NoError(t, err)
+		return agg
+	}
+
+	src := newAggregator(noOpProcessor())
+	for _, id := range []string{"ab01", "ab02"} {
+		require.NoError(t, src.AggregateBatch(ctx, EncodeToCombinedMetricsKeyID(t, id), &modelpb.Batch{{
+			Event: &modelpb.Event{Duration: durationpb.New(time.Millisecond)},
+			Transaction: &modelpb.Transaction{
+				Name:                "T-1000",
+				Type:                "type",
+				RepresentativeCount: 1,
+			},
+			Service: &modelpb.Service{Name: "svc"},
+		}}))
+	}
+	var snapshot bytes.Buffer
+	require.NoError(t, src.Snapshot(ctx, &snapshot))
+
+	// The snapshot does not remove the metrics from the source.
+	expected := make(map[CombinedMetricsKey]*aggregationpb.CombinedMetrics)
+	it, err := src.NewIterator(IteratorOptions{})
+	require.NoError(t, err)
+	for valid := it.First(); valid; valid = it.Next() {
+		cm := &aggregationpb.CombinedMetrics{}
+		require.NoError(t, it.Value(cm))
+		expected[it.Key()] = cm
+	}
+	require.NoError(t, it.Error())
+	require.NoError(t, it.Close())
+	require.Len(t, expected, 4)
+	require.NoError(t, src.Close(ctx))
+
+	restored := make(map[CombinedMetricsKey]*aggregationpb.CombinedMetrics)
+	dst := newAggregator(func(_ context.Context, cmk CombinedMetricsKey, cm *aggregationpb.CombinedMetrics, _ time.Duration) error {
+		restored[cmk] = cm.CloneVT()
+		return nil
+	})
+	require.NoError(t, dst.RestoreSnapshot(ctx, bytes.NewReader(snapshot.Bytes())))
+	require.NoError(t, dst.Close(ctx))
+
+	require.Len(t, restored, len(expected))
+	for k, cm := range expected {
+		require.Contains(t, restored, k)
+		assert.Empty(t, cmp.Diff(cm, restored[k], protocmp.Transform()))
+	}
+}
+
+func TestRestoreSnapshotInvalid(t *testing.T) {
+	agg, err := New(
+		WithDataDir(t.TempDir()),
+		WithProcessor(noOpProcessor()),
+		WithLogger(zap.NewNop()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { agg.Close(context.Background()) })
+
+	compress := func(b []byte) *bytes.Buffer {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		_, err := zw.Write(b)
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+		return &buf
+	}
+
+	err = agg.RestoreSnapshot(context.Background(), bytes.NewReader([]byte("not gzip")))
+	assert.ErrorContains(t, err, "failed to read snapshot")
+
+	err = agg.RestoreSnapshot(context.Background(), compress([]byte("invalid-snapshot-header..")))
+	assert.EqualError(t, err, "invalid snapshot header")
+
+	truncated := append(append([]byte{}, snapshotHeader...), make([]byte, CombinedMetricsKeyEncodedSize)...)
+	err = agg.RestoreSnapshot(context.Background(), compress(truncated))
+	assert.EqualError(t, err, "failed to read snapshot value length: unexpected EOF")
+
+	// Snapshots written before the keys were versioned hold legacy keys.
+	truncated = append(append([]byte{}, legacySnapshotHeader...), make([]byte, legacyCombinedMetricsKeyEncodedSize)...)
+	err = agg.RestoreSnapshot(context.Background(), compress(truncated))
+	assert.EqualError(t, err, "failed to read snapshot value length: unexpected EOF")
+
+	// An empty snapshot restores nothing.
+	assert.NoError(t, agg.RestoreSnapshot(context.Background(), compress(snapshotHeader)))
+}
@@ -0,0 +1,331 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+)
+
+// CardinalityStats reports the usage of a combined metrics ID against each
+// of the configured limits. Limits are enforced independently for each
+// partition of a combined metrics, so the usage counts report the highest
+// value observed in any of the partitions. Overflow counts are estimates of
+// the number of unique groups that have already overflowed and are summed
+// across all the partitions.
+type CardinalityStats struct {
+	// Services is the number of unique services.
+	Services int
+
+	// ServiceInstanceGroups is the total number of unique service
+	// instance groups.
+	ServiceInstanceGroups int
+
+	// ServiceInstanceGroupsPerService is the highest number of unique
+	// service instance groups recorded for any single service.
+	ServiceInstanceGroupsPerService int
+
+	// TransactionGroups is the total number of unique transaction groups.
+	TransactionGroups int
+
+	// TransactionGroupsPerService is the highest number of unique
+	// transaction groups recorded for any single service.
+	TransactionGroupsPerService int
+
+	// ServiceTransactionGroups is the total number of unique service
+	// transaction groups.
+	ServiceTransactionGroups int
+
+	// ServiceTransactionGroupsPerService is the highest number of unique
+	// service transaction groups recorded for any single service.
+	ServiceTransactionGroupsPerService int
+
+	// SpanGroups is the total number of unique span groups.
+	SpanGroups int
+
+	// SpanGroupsPerService is the highest number of unique span groups
+	// recorded for any single service.
+	SpanGroupsPerService int
+
+	// ErrorGroups is the total number of unique error groups.
+	ErrorGroups int
+
+	// ErrorGroupsPerService is the highest number of unique error groups
+	// recorded for any single service.
+	ErrorGroupsPerService int
+
+	// ServiceGraphEdges is the total number of unique service graph edges.
+	ServiceGraphEdges int
+
+	// ServiceGraphEdgesPerService is the highest number of unique service
+	// graph edges recorded for any single service.
+	ServiceGraphEdgesPerService int
+
+	// OverflowServiceInstances is the estimated number of unique service
+	// instances that overflowed due to the max services or the max service
+	// instance groups per service limit.
+	OverflowServiceInstances uint64
+
+	// OverflowTransactionGroups is the estimated number of unique
+	// transaction groups that overflowed.
+	OverflowTransactionGroups uint64
+
+	// OverflowServiceTransactionGroups is the estimated number of unique
+	// service transaction groups that overflowed.
+	OverflowServiceTransactionGroups uint64
+
+	// OverflowSpanGroups is the estimated number of unique span groups
+	// that overflowed.
+	OverflowSpanGroups uint64
+
+	// OverflowErrorGroups is the estimated number of unique error groups
+	// that overflowed.
+	OverflowErrorGroups uint64
+
+	// OverflowServiceGraphEdges is the estimated number of unique service
+	// graph edges that overflowed.
+	OverflowServiceGraphEdges uint64
+
+	// OverflowGlobalLabels is the estimated number of unique global label
+	// sets dropped due to the max global labels per service limit.
+	OverflowGlobalLabels uint64
+}
+
+// Stats returns the cardinality usage of the given combined metrics ID for
+// the active aggregation period of each configured aggregation interval. The
+// returned stats can be compared with the configured Limits to find how close
+// a combined metrics ID is to overflowing.
+//
+// Stats commits any buffered writes to the database before reading and it
+// blocks aggregation while the stats are being calculated.
+func (a *Aggregator) Stats(id [16]byte) (map[time.Duration]CardinalityStats, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	select {
+	case <-a.closed:
+		return nil, ErrAggregatorClosed
+	default:
+	}
+
+	if err := a.flushBatches(); err != nil {
+		return nil, fmt.Errorf("failed to commit batch: %w", err)
+	}
+
+	stats := make(map[time.Duration]CardinalityStats, len(a.cfg.AggregationIntervals))
+	for _, ivl := range a.cfg.AggregationIntervals {
+		cmk := CombinedMetricsKey{
+			Interval:       ivl,
+			ProcessingTime: a.processingTime.Truncate(ivl),
+			ID:             id,
+		}
+		s, err := a.statsForKey(cmk)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"failed to calculate stats for interval %s: %w", formatDuration(ivl), err,
+			)
+		}
+		stats[ivl] = s
+	}
+	return stats, nil
+}
+
+// statsForKey calculates the cardinality stats for all the partitions of
+// the given combined metrics key. The partition ID of the key is ignored.
+func (a *Aggregator) statsForKey(cmk CombinedMetricsKey) (CardinalityStats, error) {
+	var stats CardinalityStats
+	lb := make([]byte, CombinedMetricsKeyEncodedSize)
+	if err := cmk.MarshalBinaryToSizedBuffer(lb); err != nil {
+		return stats, fmt.Errorf("failed to marshal combined metrics key: %w", err)
+	}
+	prefix := lb[:combinedMetricsKeyPrefixSize]
+
+	iter := a.shardFor(cmk.ID).NewIter(&pebble.IterOptions{
+		LowerBound: lb,
+		KeyTypes:   pebble.IterKeyTypePointsOnly,
+	})
+	defer iter.Close()
+
+	cm := aggregationpb.CombinedMetricsFromVTPool()
+	defer cm.ReturnToVTPool()
+	for iter.First(); iter.Valid() && bytes.HasPrefix(iter.Key(), prefix); iter.Next() {
+		cm.ResetVT()
+		if err := a.codec.unmarshal(iter.Value(), cm); err != nil {
+			return stats, fmt.Errorf("failed to unmarshal metrics: %w", err)
+		}
+		stats.add(cm)
+	}
+	return stats, iter.Error()
+}
+
+// add records the usage of a single partition of combined metrics.
+func (s *CardinalityStats) add(cm *aggregationpb.CombinedMetrics) {
+	var p CardinalityStats
+	p.Services = len(cm.ServiceMetrics)
+	for _, ksm := range cm.ServiceMetrics {
+		sm := ksm.Metrics
+		if sm == nil {
+			continue
+		}
+		var txns, svcTxns, spans, errs, edges int
+		for _, ksim := range sm.ServiceInstanceMetrics {
+			if ksim.Metrics == nil {
+				continue
+			}
+			txns += len(ksim.Metrics.TransactionMetrics)
+			svcTxns += len(ksim.Metrics.ServiceTransactionMetrics)
+			spans += len(ksim.Metrics.SpanMetrics)
+			errs += len(ksim.Metrics.ErrorMetrics)
+			edges += len(ksim.Metrics.ServiceGraphEdgeMetrics)
+		}
+		p.ServiceInstanceGroups += len(sm.ServiceInstanceMetrics)
+		p.ServiceInstanceGroupsPerService = maxInt(p.ServiceInstanceGroupsPerService, len(sm.ServiceInstanceMetrics))
+		p.TransactionGroups += txns
+		p.TransactionGroupsPerService = maxInt(p.TransactionGroupsPerService, txns)
+		p.ServiceTransactionGroups += svcTxns
+		p.ServiceTransactionGroupsPerService = maxInt(p.ServiceTransactionGroupsPerService, svcTxns)
+		p.SpanGroups += spans
+		p.SpanGroupsPerService = maxInt(p.SpanGroupsPerService, spans)
+		p.ErrorGroups += errs
+		p.ErrorGroupsPerService = maxInt(p.ErrorGroupsPerService, errs)
+		p.ServiceGraphEdges += edges
+		p.ServiceGraphEdgesPerService = maxInt(p.ServiceGraphEdgesPerService, edges)
+		p.addOverflow(sm.OverflowGroups)
+	}
+	if len(cm.OverflowServiceInstancesEstimator) > 0 {
+		p.OverflowServiceInstances = hllSketch(cm.OverflowServiceInstancesEstimator).Estimate()
+		p.addOverflow(cm.OverflowServices)
+	}
+
+	s.Services = maxInt(s.Services, p.Services)
+	s.ServiceInstanceGroups = maxInt(s.ServiceInstanceGroups, p.ServiceInstanceGroups)
+	s.ServiceInstanceGroupsPerService = maxInt(s.ServiceInstanceGroupsPerService, p.ServiceInstanceGroupsPerService)
+	s.TransactionGroups = maxInt(s.TransactionGroups, p.TransactionGroups)
+	s.TransactionGroupsPerService = maxInt(s.TransactionGroupsPerService, p.TransactionGroupsPerService)
+	s.ServiceTransactionGroups = maxInt(s.ServiceTransactionGroups, p.ServiceTransactionGroups)
+	s.ServiceTransactionGroupsPerService = maxInt(s.ServiceTransactionGroupsPerService, p.ServiceTransactionGroupsPerService)
+	s.SpanGroups = maxInt(s.SpanGroups, p.SpanGroups)
+	s.SpanGroupsPerService = maxInt(s.SpanGroupsPerService, p.SpanGroupsPerService)
+	s.ErrorGroups = maxInt(s.ErrorGroups, p.ErrorGroups)
+	s.ErrorGroupsPerService = maxInt(s.ErrorGroupsPerService, p.ErrorGroupsPerService)
+	s.ServiceGraphEdges = maxInt(s.ServiceGraphEdges, p.ServiceGraphEdges)
+	s.ServiceGraphEdgesPerService = maxInt(s.ServiceGraphEdgesPerService, p.ServiceGraphEdgesPerService)
+	s.OverflowServiceInstances += p.OverflowServiceInstances
+	s.OverflowTransactionGroups += p.OverflowTransactionGroups
+	s.OverflowServiceTransactionGroups += p.OverflowServiceTransactionGroups
+	s.OverflowSpanGroups += p.OverflowSpanGroups
+	s.OverflowErrorGroups += p.OverflowErrorGroups
+	s.OverflowServiceGraphEdges += p.OverflowServiceGraphEdges
+	s.OverflowGlobalLabels += p.OverflowGlobalLabels
+}
+
+func (s *CardinalityStats) addOverflow(o *aggregationpb.Overflow) {
+	if o == nil {
+		return
+	}
+	if len(o.OverflowTransactionsEstimator) > 0 {
+		s.OverflowTransactionGroups += hllSketch(o.OverflowTransactionsEstimator).Estimate()
+	}
+	if len(o.OverflowServiceTransactionsEstimator) > 0 {
+		s.OverflowServiceTransactionGroups += hllSketch(o.OverflowServiceTransactionsEstimator).Estimate()
+	}
+	if len(o.OverflowSpansEstimator) > 0 {
+		s.OverflowSpanGroups += hllSketch(o.OverflowSpansEstimator).Estimate()
+	}
+	if len(o.OverflowErrorsEstimator) > 0 {
+		s.OverflowErrorGroups += hllSketch(o.OverflowErrorsEstimator).Estimate()
+	}
+	if len(o.OverflowServiceGraphEdgesEstimator) > 0 {
+		s.OverflowServiceGraphEdges += hllSketch(o.OverflowServiceGraphEdgesEstimator).Estimate()
+	}
+	if len(o.OverflowGlobalLabelsEstimator) > 0 {
+		s.OverflowGlobalLabels += hllSketch(o.OverflowGlobalLabelsEstimator).Estimate()
+	}
+}
+
+// RunStats reports the state of the harvest loop started by Run.
+type RunStats struct {
+	// Running is true if Run has been called and has not returned yet.
+	Running bool
+
+	// Restarts is the total number of times the harvest loop has been
+	// restarted after a crash.
+	Restarts int
+
+	// ConsecutiveCrashes is the number of times the harvest loop has
+	// crashed since the last successful harvest.
+	ConsecutiveCrashes int
+
+	// LastCrash is the error reported by the last crash of the harvest
+	// loop, if any.
+	LastCrash error
+
+	// LastCrashTime is the time of the last crash of the harvest loop.
+	LastCrashTime time.Time
+}
+
+// RunStats returns the state of the harvest loop.
+func (a *Aggregator) RunStats() RunStats {
+	a.runState.mu.Lock()
+	defer a.runState.mu.Unlock()
+	return a.runState.stats
+}
+
+// runState tracks the state of the harvest loop for RunStats.
+type runState struct {
+	mu    sync.Mutex
+	stats RunStats
+}
+
+func (s *runState) setRunning(running bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.Running = running
+}
+
+// recordCrash records a crash of the harvest loop and returns the number
+// of consecutive crashes.
+func (s *runState) recordCrash(err error) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.ConsecutiveCrashes++
+	s.stats.LastCrash = err
+	s.stats.LastCrashTime = time.Now()
+	return s.stats.ConsecutiveCrashes
+}
+
+func (s *runState) recordRestart() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.Restarts++
+}
+
+// restarting returns true if the harvest loop was restarted after a crash
+// and no harvest completed since.
+func (s *runState) restarting() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats.ConsecutiveCrashes > 0
+}
+
+// resetCrashes records a successful harvest.
+func (s *runState) resetCrashes() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.ConsecutiveCrashes = 0
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,89 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/elastic/apm-data/model/modelpb"
+)
+
+func TestStats(t *testing.T) {
+	ivls := []time.Duration{time.Minute, time.Hour}
+	agg, err := New(
+		WithDataDir(t.TempDir()),
+		WithLimits(Limits{
+			MaxSpanGroups:                         1000,
+			MaxSpanGroupsPerService:               100,
+			MaxTransactionGroups:                  100,
+			MaxTransactionGroupsPerService:        3,
+			MaxServiceTransactionGroups:           100,
+			MaxServiceTransactionGroupsPerService: 10,
+			MaxServices:                           10,
+			MaxServiceInstanceGroupsPerService:    10,
+		}),
+		WithProcessor(noOpProcessor()),
+		WithAggregationIntervals(ivls),
+		WithLogger(zap.NewNop()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { agg.Close(context.Background()) })
+
+	cmID := EncodeToCombinedMetricsKeyID(t, "ab01")
+	var batch modelpb.Batch
+	for _, svc := range []string{"svc1", "svc2"} {
+		for i := 0; i < 5; i++ {
+			batch = append(batch, &modelpb.APMEvent{
+				Event: &modelpb.Event{Duration: durationpb.New(time.Millisecond)},
+				Transaction: &modelpb.Transaction{
+					Name:                fmt.Sprintf("txn%d", i),
+					Type:                "type",
+					RepresentativeCount: 1,
+				},
+				Service: &modelpb.Service{Name: svc},
+			})
+		}
+	}
+	batch = append(batch, makeSpan(
+		time.Unix(0, 0), "svc1", "", "dest", "", "", "success",
+		time.Millisecond, 1, nil, nil,
+	))
+	require.NoError(t, agg.AggregateBatch(context.Background(), cmID, &batch))
+
+	stats, err := agg.Stats(cmID)
+	require.NoError(t, err)
+	require.Len(t, stats, len(ivls))
+	for _, ivl := range ivls {
+		s := stats[ivl]
+		assert.Equal(t, 2, s.Services)
+		assert.Equal(t, 1, s.ServiceInstanceGroupsPerService)
+		assert.Equal(t, 6, s.TransactionGroups)
+		assert.Equal(t, 3, s.TransactionGroupsPerService)
+		assert.Equal(t, 2, s.ServiceTransactionGroups)
+		assert.Equal(t, 1, s.ServiceTransactionGroupsPerService)
+		assert.Equal(t, 1, s.SpanGroups)
+		assert.Equal(t, 1, s.SpanGroupsPerService)
+		assert.Equal(t, uint64(4), s.OverflowTransactionGroups)
+		assert.Zero(t, s.OverflowServiceInstances)
+		assert.Zero(t, s.OverflowServiceTransactionGroups)
+		assert.Zero(t, s.OverflowSpanGroups)
+	}
+
+	unknown, err := agg.Stats(EncodeToCombinedMetricsKeyID(t, "ab02"))
+	require.NoError(t, err)
+	assert.Equal(t, CardinalityStats{}, unknown[time.Minute])
+
+	require.NoError(t, agg.Close(context.Background()))
+	_, err = agg.Stats(cmID)
+	assert.ErrorIs(t, err, ErrAggregatorClosed)
+}
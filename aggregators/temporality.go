@@ -0,0 +1,212 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/elastic/apm-data/model/modelpb"
+)
+
+// Temporality identifies the temporality of the counters of the events
+// converted by CombinedMetricsToBatch.
+type Temporality uint8
+
+const (
+	// DeltaTemporality reports the counts of a single aggregation interval.
+	// This is how metrics are harvested.
+	DeltaTemporality Temporality = iota + 1
+	// CumulativeTemporality reports the counts accumulated across all
+	// harvests of the same aggregated metrics.
+	CumulativeTemporality
+)
+
+// String returns the name of the temporality as declared by the
+// TemporalityLabel label.
+func (t Temporality) String() string {
+	switch t {
+	case DeltaTemporality:
+		return "delta"
+	case CumulativeTemporality:
+		return "cumulative"
+	}
+	return "unspecified"
+}
+
+// CumulativeCounters holds the counters of converted events accumulated
+// across harvests, see WithTemporality. Counters of metrics which are not
+// harvested for longer than the configured TTL are dropped, the metrics
+// restart from zero when harvested again. CumulativeCounters is safe for
+// concurrent use.
+type CumulativeCounters struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	counters  map[uint64]*cumulativeCounter
+	lastSweep time.Time
+}
+
+// cumulativeCounter holds the accumulated counters of an event.
+type cumulativeCounter struct {
+	lastSeen time.Time
+
+	docCount          uint64
+	durationCount     uint64
+	durationSum       float64
+	durationValues    []float64
+	durationCounts    []uint64
+	successCount      uint64
+	successSum        float64
+	responseTimeCount uint64
+	responseTimeSum   time.Duration
+	samples           map[string]float64
+	histogramSamples  map[string]*modelpb.Histogram
+}
+
+// NewCumulativeCounters returns a new CumulativeCounters dropping the
+// counters of metrics not harvested for the given TTL, measured in
+// processing time. A TTL of 0 keeps counters indefinitely.
+func NewCumulativeCounters(ttl time.Duration) *CumulativeCounters {
+	return &CumulativeCounters{
+		ttl:      ttl,
+		counters: make(map[uint64]*cumulativeCounter),
+	}
+}
+
+// Len returns the number of accumulated metrics.
+func (c *CumulativeCounters) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.counters)
+}
+
+// accumulate adds the counters of the events of the batch to the counters
+// of the given event keys, replacing the counters of the events with the
+// accumulated counters. Events without a key are left unchanged.
+func (c *CumulativeCounters) accumulate(
+	b modelpb.Batch,
+	keys map[*modelpb.APMEvent]uint64,
+	processingTime time.Time,
+) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, e := range b {
+		key, ok := keys[e]
+		if !ok {
+			continue
+		}
+		counter, ok := c.counters[key]
+		if !ok {
+			counter = &cumulativeCounter{}
+			c.counters[key] = counter
+		}
+		counter.add(e)
+		if processingTime.After(counter.lastSeen) {
+			counter.lastSeen = processingTime
+		}
+	}
+	if c.ttl > 0 && processingTime.Sub(c.lastSweep) >= c.ttl {
+		for key, counter := range c.counters {
+			if processingTime.Sub(counter.lastSeen) > c.ttl {
+				delete(c.counters, key)
+			}
+		}
+		c.lastSweep = processingTime
+	}
+}
+
+// add adds the counters of the event to the accumulated counters and sets
+// the accumulated counters on the event. Gauges are left unchanged.
+func (c *cumulativeCounter) add(e *modelpb.APMEvent) {
+	if ms := e.GetMetricset(); ms != nil {
+		c.docCount += ms.DocCount
+		ms.DocCount = c.docCount
+		for _, sample := range ms.Samples {
+			switch sample.Type {
+			case modelpb.MetricType_METRIC_TYPE_COUNTER:
+				if c.samples == nil {
+					c.samples = make(map[string]float64)
+				}
+				c.samples[sample.Name] += sample.Value
+				sample.Value = c.samples[sample.Name]
+			case modelpb.MetricType_METRIC_TYPE_HISTOGRAM:
+				if sample.Histogram == nil {
+					continue
+				}
+				if c.histogramSamples == nil {
+					c.histogramSamples = make(map[string]*modelpb.Histogram)
+				}
+				h, ok := c.histogramSamples[sample.Name]
+				if !ok {
+					h = &modelpb.Histogram{}
+					c.histogramSamples[sample.Name] = h
+				}
+				h.Values, h.Counts = mergeHistogramBuckets(
+					h.Values, h.Counts, sample.Histogram.Values, sample.Histogram.Counts,
+				)
+				sample.Histogram.Values = append([]float64(nil), h.Values...)
+				sample.Histogram.Counts = append([]uint64(nil), h.Counts...)
+			}
+		}
+	}
+	if txn := e.GetTransaction(); txn != nil {
+		if s := txn.DurationSummary; s != nil {
+			c.durationCount += s.Count
+			c.durationSum += s.Sum
+			s.Count, s.Sum = c.durationCount, c.durationSum
+		}
+		if h := txn.DurationHistogram; h != nil {
+			c.durationValues, c.durationCounts = mergeHistogramBuckets(
+				c.durationValues, c.durationCounts, h.Values, h.Counts,
+			)
+			h.Values = append([]float64(nil), c.durationValues...)
+			h.Counts = append([]uint64(nil), c.durationCounts...)
+		}
+	}
+	if s := e.GetEvent().GetSuccessCount(); s != nil {
+		c.successCount += s.Count
+		c.successSum += s.Sum
+		s.Count, s.Sum = c.successCount, c.successSum
+	}
+	if rt := e.GetSpan().GetDestinationService().GetResponseTime(); rt != nil {
+		c.responseTimeCount += rt.Count
+		c.responseTimeSum += rt.Sum.AsDuration()
+		rt.Count = c.responseTimeCount
+		rt.Sum = durationpb.New(c.responseTimeSum)
+	}
+}
+
+// mergeHistogramBuckets merges the buckets of the second histogram into
+// the first histogram. Bucket values of both histograms must be sorted in
+// ascending order.
+func mergeHistogramBuckets(
+	values []float64, counts []uint64,
+	addValues []float64, addCounts []uint64,
+) ([]float64, []uint64) {
+	mergedValues := make([]float64, 0, len(values)+len(addValues))
+	mergedCounts := make([]uint64, 0, len(counts)+len(addCounts))
+	var i, j int
+	for i < len(values) || j < len(addValues) {
+		switch {
+		case j == len(addValues) || (i < len(values) && values[i] < addValues[j]):
+			mergedValues = append(mergedValues, values[i])
+			mergedCounts = append(mergedCounts, counts[i])
+			i++
+		case i == len(values) || addValues[j] < values[i]:
+			mergedValues = append(mergedValues, addValues[j])
+			mergedCounts = append(mergedCounts, addCounts[j])
+			j++
+		default:
+			mergedValues = append(mergedValues, values[i])
+			mergedCounts = append(mergedCounts, counts[i]+addCounts[j])
+			i++
+			j++
+		}
+	}
+	return mergedValues, mergedCounts
+}
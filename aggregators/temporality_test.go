@@ -0,0 +1,54 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/apm-data/model/modelpb"
+)
+
+func TestMergeHistogramBuckets(t *testing.T) {
+	values, counts := mergeHistogramBuckets(nil, nil, []float64{1, 3}, []uint64{1, 2})
+	assert.Equal(t, []float64{1, 3}, values)
+	assert.Equal(t, []uint64{1, 2}, counts)
+
+	values, counts = mergeHistogramBuckets(values, counts, []float64{0, 3, 5}, []uint64{4, 5, 6})
+	assert.Equal(t, []float64{0, 1, 3, 5}, values)
+	assert.Equal(t, []uint64{4, 1, 7, 6}, counts)
+}
+
+func TestCumulativeCountersTTL(t *testing.T) {
+	counters := NewCumulativeCounters(2 * time.Minute)
+	newEvent := func() *modelpb.APMEvent {
+		return &modelpb.APMEvent{Metricset: &modelpb.Metricset{DocCount: 1}}
+	}
+	accumulate := func(ts time.Time, keys ...uint64) []uint64 {
+		var b modelpb.Batch
+		eventKeys := make(map[*modelpb.APMEvent]uint64)
+		for _, key := range keys {
+			e := newEvent()
+			b = append(b, e)
+			eventKeys[e] = key
+		}
+		counters.accumulate(b, eventKeys, ts)
+		var docCounts []uint64
+		for _, e := range b {
+			docCounts = append(docCounts, e.Metricset.DocCount)
+		}
+		return docCounts
+	}
+
+	ts := time.Unix(0, 0).UTC()
+	assert.Equal(t, []uint64{1, 1}, accumulate(ts, 1, 2))
+	assert.Equal(t, []uint64{2}, accumulate(ts.Add(time.Minute), 1))
+	assert.Equal(t, []uint64{3}, accumulate(ts.Add(3*time.Minute), 1))
+	assert.Equal(t, 1, counters.Len())
+	// Counters of key 2 restart after being dropped.
+	assert.Equal(t, []uint64{4, 1}, accumulate(ts.Add(4*time.Minute), 1, 2))
+}
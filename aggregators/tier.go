@@ -0,0 +1,418 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	"go.uber.org/zap"
+)
+
+// coldTierDir is the directory of the cold tier database within the data
+// directory, see WithColdTier.
+const coldTierDir = "cold"
+
+// coldTierMemTableSize is the memtable size of the cold tier database. The
+// memtable is flushed after every move of combined metrics to the cold
+// tier, so it only buffers the writes of cold IDs between the moves.
+const coldTierMemTableSize = 1 << 20
+
+// openColdTier opens the cold tier database in the data directory.
+func openColdTier(cfg Config, newOptions func() *pebble.Options) (*pebble.DB, error) {
+	dir := filepath.Join(cfg.DataDir, coldTierDir)
+	opts := newOptions()
+	opts.MemTableSize = coldTierMemTableSize
+	db, err := pebble.Open(dir, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pebble db in %s: %w", dir, err)
+	}
+	return db, nil
+}
+
+// tierState tracks the activity of the combined metrics IDs, the IDs
+// routed to the cold tier and the periods with combined metrics stored
+// per ID, so that moving the combined metrics of an ID between the tiers
+// seeks to its periods instead of scanning the databases. It has its own
+// lock as the tier of an ID is looked up when committing batches, which is
+// done outside the lock of the aggregator by the harvest loop.
+type tierState struct {
+	mu sync.Mutex
+	// lastActive holds the processing time of the last period in which
+	// events were aggregated per warm ID.
+	lastActive map[[16]byte]time.Time
+	// cold holds the IDs routed to the cold tier.
+	cold map[[16]byte]struct{}
+	// rewarmed holds the IDs which aggregated events while cold, their
+	// combined metrics stored in the cold tier are pending to be moved
+	// back to the shards.
+	rewarmed map[[16]byte]struct{}
+	// stored holds the periods with combined metrics stored in either
+	// tier per ID. It may hold periods whose combined metrics were
+	// already deleted, until they are pruned, but never misses a stored
+	// period.
+	stored map[[16]byte]map[tierPeriod]struct{}
+}
+
+// tierPeriod is the aggregation interval and processing time of stored
+// combined metrics, i.e. the combined metrics key without the ID and the
+// partition.
+type tierPeriod struct {
+	ivl            time.Duration
+	processingTime int64
+}
+
+func newTierState() *tierState {
+	return &tierState{
+		lastActive: make(map[[16]byte]time.Time),
+		cold:       make(map[[16]byte]struct{}),
+		rewarmed:   make(map[[16]byte]struct{}),
+		stored:     make(map[[16]byte]map[tierPeriod]struct{}),
+	}
+}
+
+// touch records that events were aggregated for the ID in the period of
+// the given processing time, routing the ID back to the shards if cold.
+func (t *tierState) touch(id [16]byte, processingTime time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.cold[id]; ok {
+		delete(t.cold, id)
+		t.rewarmed[id] = struct{}{}
+	}
+	t.lastActive[id] = processingTime
+}
+
+// store records that combined metrics are stored for the key.
+func (t *tierState) store(cmk CombinedMetricsKey) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	periods, ok := t.stored[cmk.ID]
+	if !ok {
+		periods = make(map[tierPeriod]struct{})
+		t.stored[cmk.ID] = periods
+	}
+	periods[tierPeriod{ivl: cmk.Interval, processingTime: cmk.ProcessingTime.Unix()}] = struct{}{}
+}
+
+// prune forgets the stored periods of the aggregation interval with a
+// processing time in the range [start, end), once their combined metrics
+// are deleted from both tiers.
+func (t *tierState) prune(ivl time.Duration, start, end time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id, periods := range t.stored {
+		for p := range periods {
+			if p.ivl == ivl && p.processingTime >= start.Unix() && p.processingTime < end.Unix() {
+				delete(periods, p)
+			}
+		}
+		if len(periods) == 0 {
+			delete(t.stored, id)
+		}
+	}
+}
+
+// expire forgets the stored periods of all the aggregation intervals with
+// a processing time before the cutoff, once their combined metrics are
+// dropped by the garbage collection.
+func (t *tierState) expire(cutoff time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id, periods := range t.stored {
+		for p := range periods {
+			if p.processingTime < cutoff.Unix() {
+				delete(periods, p)
+			}
+		}
+		if len(periods) == 0 {
+			delete(t.stored, id)
+		}
+	}
+}
+
+// periods returns the stored periods of the given IDs.
+func (t *tierState) periods(ids map[[16]byte]struct{}) map[[16]byte][]tierPeriod {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	periods := make(map[[16]byte][]tierPeriod, len(ids))
+	for id := range ids {
+		for p := range t.stored[id] {
+			periods[id] = append(periods[id], p)
+		}
+	}
+	return periods
+}
+
+// isCold returns true if the combined metrics of the ID are routed to the
+// cold tier.
+func (t *tierState) isCold(id [16]byte) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, ok := t.cold[id]
+	return ok
+}
+
+// takeRewarmed returns and resets the rewarmed IDs.
+func (t *tierState) takeRewarmed() map[[16]byte]struct{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	rewarmed := t.rewarmed
+	t.rewarmed = make(map[[16]byte]struct{})
+	return rewarmed
+}
+
+// coolDown routes the IDs idle since before the given time to the cold
+// tier and returns them.
+func (t *tierState) coolDown(idleSince time.Time) map[[16]byte]struct{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	idle := make(map[[16]byte]struct{})
+	for id, last := range t.lastActive {
+		if last.Before(idleSince) {
+			delete(t.lastActive, id)
+			t.cold[id] = struct{}{}
+			idle[id] = struct{}{}
+		}
+	}
+	return idle
+}
+
+// retainCold forgets the cold IDs without stored periods, they are routed
+// to the shards if they aggregate events again.
+func (t *tierState) retainCold() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id := range t.cold {
+		if _, ok := t.stored[id]; !ok {
+			delete(t.cold, id)
+		}
+	}
+}
+
+// touch records the activity of the ID for the cold tier, if enabled. Must
+// be called with the lock held.
+func (a *Aggregator) touch(id [16]byte) {
+	if a.tiers != nil {
+		a.tiers.touch(id, a.processingTime)
+	}
+}
+
+// storeTier records the stored period of the key for the cold tier, if
+// enabled.
+func (a *Aggregator) storeTier(cmk CombinedMetricsKey) {
+	if a.tiers != nil {
+		a.tiers.store(cmk)
+	}
+}
+
+// pruneTier forgets the stored periods of the aggregation interval in the
+// range [start, end) for the cold tier, if enabled.
+func (a *Aggregator) pruneTier(ivl time.Duration, start, end time.Time) {
+	if a.tiers != nil {
+		a.tiers.prune(ivl, start, end)
+	}
+}
+
+// loadTiers records the periods stored in the shards, e.g. by a previous
+// run, and routes the IDs with combined metrics stored in the cold tier
+// to the cold tier. It is the only scan of the stored keys, the periods
+// are tracked as they are written afterwards.
+func (a *Aggregator) loadTiers() error {
+	for _, shard := range a.shards {
+		stored, err := storedKeys(shard)
+		if err != nil {
+			return err
+		}
+		for _, cmk := range stored {
+			a.tiers.store(cmk)
+		}
+		if shard != a.cold {
+			continue
+		}
+		a.tiers.mu.Lock()
+		for _, cmk := range stored {
+			a.tiers.cold[cmk.ID] = struct{}{}
+		}
+		a.tiers.mu.Unlock()
+	}
+	return nil
+}
+
+// reconcileTiers moves the combined metrics of the rewarmed IDs back to the
+// shards and the combined metrics of the IDs idle for the configured number
+// of lowest aggregation intervals before the given harvest end time to the
+// cold tier. Reconciling before every harvest ensures that the combined
+// metrics of an ID are never harvested from both tiers. Must be called with
+// the lock held, so that no batch is committed while moving.
+func (a *Aggregator) reconcileTiers(end time.Time) error {
+	if a.cold == nil {
+		return nil
+	}
+	var errs []error
+	warm := a.shards[:len(a.shards)-1]
+	if rewarmed := a.tiers.takeRewarmed(); len(rewarmed) > 0 {
+		n, err := a.moveCombinedMetrics([]*pebble.DB{a.cold}, rewarmed, a.shardFor)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to move combined metrics to the warm tier: %w", err))
+		}
+		if n > 0 {
+			a.cfg.Logger.Debug("moved combined metrics to the warm tier",
+				zap.Int("ids", len(rewarmed)), zap.Int("combined_metrics", n))
+		}
+	}
+
+	idleFor := time.Duration(a.cfg.ColdTierIdleIntervals) * a.cfg.AggregationIntervals[0]
+	if idle := a.tiers.coolDown(end.Add(-idleFor)); len(idle) > 0 {
+		n, err := a.moveCombinedMetrics(warm, idle, func([16]byte) *pebble.DB { return a.cold })
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to move combined metrics to the cold tier: %w", err))
+		}
+		if n > 0 {
+			// Flushing moves the combined metrics of the cold IDs out of
+			// the memtable, they are only read back for harvesting.
+			if err := a.cold.Flush(); err != nil {
+				errs = append(errs, fmt.Errorf("failed to flush cold tier: %w", err))
+			}
+			a.cfg.Logger.Debug("moved combined metrics to the cold tier",
+				zap.Int("ids", len(idle)), zap.Int("combined_metrics", n))
+		}
+	}
+
+	a.tiers.retainCold()
+	return a.health.recordStorageError(errors.Join(errs...))
+}
+
+// moveCombinedMetrics moves the combined metrics of the given IDs from the
+// source databases to the destination database of their ID and returns the
+// number of moved combined metrics. The combined metrics are merged into
+// the destination, with any metrics already stored there, before being
+// deleted from the source. Only the stored periods of the IDs are read.
+func (a *Aggregator) moveCombinedMetrics(
+	srcs []*pebble.DB,
+	ids map[[16]byte]struct{},
+	dst func([16]byte) *pebble.DB,
+) (int, error) {
+	periods := a.tiers.periods(ids)
+	var moved int
+	var errs []error
+	for _, src := range srcs {
+		n, err := a.moveShardCombinedMetrics(src, periods, dst)
+		moved += n
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return moved, errors.Join(errs...)
+}
+
+func (a *Aggregator) moveShardCombinedMetrics(
+	src *pebble.DB,
+	periods map[[16]byte][]tierPeriod,
+	dst func([16]byte) *pebble.DB,
+) (int, error) {
+	iter := src.NewIter(&pebble.IterOptions{
+		LowerBound: combinedMetricsLowerBound,
+		KeyTypes:   pebble.IterKeyTypePointsOnly,
+	})
+	defer iter.Close()
+
+	deletes := src.NewBatch()
+	defer deletes.Close()
+	merges := make(map[*pebble.DB]*pebble.Batch)
+	defer func() {
+		for _, b := range merges {
+			b.Close()
+		}
+	}()
+	for id, idPeriods := range periods {
+		db := dst(id)
+		for _, p := range idPeriods {
+			lb, ub := tierPeriodBounds(id, p)
+			for valid := iter.SeekGE(lb); valid && bytes.Compare(iter.Key(), ub) < 0; valid = iter.Next() {
+				b, ok := merges[db]
+				if !ok {
+					b = db.NewBatch()
+					merges[db] = b
+				}
+				// The values are moved as stored, the databases of both
+				// tiers share the value codec.
+				if err := b.Merge(iter.Key(), iter.Value(), nil); err != nil {
+					return 0, fmt.Errorf("failed to add merge operation: %w", err)
+				}
+				if err := deletes.Delete(iter.Key(), nil); err != nil {
+					return 0, fmt.Errorf("failed to add delete operation: %w", err)
+				}
+			}
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return 0, fmt.Errorf("failed to iterate combined metrics: %w", err)
+	}
+	if deletes.Empty() {
+		return 0, nil
+	}
+	// The combined metrics are only deleted from the source once written
+	// to the destination, a failure can at worst leave them in both.
+	for _, b := range merges {
+		if err := b.Commit(a.writeOptions); err != nil {
+			return 0, fmt.Errorf("failed to commit moved combined metrics: %w", err)
+		}
+	}
+	if err := deletes.Commit(a.writeOptions); err != nil {
+		return 0, fmt.Errorf("failed to delete moved combined metrics: %w", err)
+	}
+	return int(deletes.Count()), nil
+}
+
+// tierPeriodBounds returns the bounds of the combined metrics keys of
+// all the partitions of the ID in the stored period.
+func tierPeriodBounds(id [16]byte, p tierPeriod) (lb, ub []byte) {
+	cmk := CombinedMetricsKey{
+		Interval:       p.ivl,
+		ProcessingTime: time.Unix(p.processingTime, 0),
+		ID:             id,
+	}
+	key := make([]byte, CombinedMetricsKeyEncodedSize)
+	cmk.MarshalBinaryToSizedBuffer(key)
+	// The keys of the period share the prefix up to the partition ID, the
+	// upper bound sorts after the keys of all the partitions.
+	lb = key[:CombinedMetricsKeyEncodedSize-3]
+	ub = append(append([]byte(nil), lb...), 0xff, 0xff, 0xff)
+	return lb, ub
+}
+
+// storedKeys returns a key of each period of each ID with combined metrics
+// stored in the database, skipping the other partitions of the periods.
+func storedKeys(db *pebble.DB) ([]CombinedMetricsKey, error) {
+	iter := db.NewIter(&pebble.IterOptions{
+		LowerBound: combinedMetricsLowerBound,
+		KeyTypes:   pebble.IterKeyTypePointsOnly,
+	})
+	defer iter.Close()
+
+	var keys []CombinedMetricsKey
+	for valid := iter.First(); valid; {
+		var cmk CombinedMetricsKey
+		if err := cmk.UnmarshalBinary(iter.Key()); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal key: %w", err)
+		}
+		keys = append(keys, cmk)
+		_, ub := tierPeriodBounds(cmk.ID, tierPeriod{
+			ivl:            cmk.Interval,
+			processingTime: cmk.ProcessingTime.Unix(),
+		})
+		valid = iter.SeekGE(ub)
+	}
+	if err := iter.Error(); err != nil {
+		return nil, fmt.Errorf("failed to iterate stored keys: %w", err)
+	}
+	return keys, nil
+}
@@ -0,0 +1,167 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+	"github.com/elastic/apm-data/model/modelpb"
+)
+
+func TestColdTier(t *testing.T) {
+	ctx := context.Background()
+	ivls := []time.Duration{time.Minute, 10 * time.Minute}
+	idleID := EncodeToCombinedMetricsKeyID(t, "ab01")
+	activeID := EncodeToCombinedMetricsKeyID(t, "cd01")
+
+	type harvested struct {
+		id  [16]byte
+		ivl time.Duration
+	}
+	processed := make(map[harvested][]float64)
+	dir := t.TempDir()
+	agg, err := New(
+		WithDataDir(dir),
+		WithLimits(Limits{
+			MaxServices:                           10,
+			MaxServiceInstanceGroupsPerService:    10,
+			MaxTransactionGroups:                  10,
+			MaxTransactionGroupsPerService:        10,
+			MaxServiceTransactionGroups:           10,
+			MaxServiceTransactionGroupsPerService: 10,
+			MaxSpanGroups:                         10,
+			MaxSpanGroupsPerService:               10,
+		}),
+		WithAggregationIntervals(ivls),
+		WithColdTier(2),
+		WithProcessor(func(
+			_ context.Context,
+			cmk CombinedMetricsKey,
+			cm *aggregationpb.CombinedMetrics,
+			ivl time.Duration,
+		) error {
+			k := harvested{id: cmk.ID, ivl: ivl}
+			processed[k] = append(processed[k], cm.EventsTotal)
+			return nil
+		}),
+		WithLogger(zap.NewNop()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { agg.Close(ctx) })
+
+	start := time.Unix(agg.processingTime.Unix(), 0).Truncate(10 * time.Minute)
+	agg.mu.Lock()
+	agg.processingTime = start
+	agg.mu.Unlock()
+	aggregate := func(id [16]byte) {
+		batch := modelpb.Batch{lateTestEvent(agg.processingTime)}
+		require.NoError(t, agg.AggregateBatch(ctx, id, &batch))
+	}
+	harvest := func(end time.Time) {
+		agg.mu.Lock()
+		batch := agg.takeBatches()
+		agg.processingTime = end
+		agg.mu.Unlock()
+		var endingIvls []time.Duration
+		for _, ivl := range ivls {
+			if end.Truncate(ivl).Equal(end) {
+				endingIvls = append(endingIvls, ivl)
+			}
+		}
+		require.NoError(t, agg.commitAndHarvest(ctx, batch, end, endingIvls, nil))
+	}
+	storedIn := func(db *pebble.DB) map[[16]byte]struct{} {
+		keys, err := storedKeys(db)
+		require.NoError(t, err)
+		ids := make(map[[16]byte]struct{})
+		for _, cmk := range keys {
+			ids[cmk.ID] = struct{}{}
+		}
+		return ids
+	}
+
+	aggregate(idleID)
+	aggregate(activeID)
+	harvest(start.Add(time.Minute))
+	aggregate(activeID)
+	harvest(start.Add(2 * time.Minute))
+	assert.False(t, agg.tiers.isCold(idleID))
+	assert.Empty(t, storedIn(agg.cold))
+
+	// The ID is idle for 2 intervals, its metrics of the 10m interval are
+	// moved to the cold tier.
+	aggregate(activeID)
+	harvest(start.Add(3 * time.Minute))
+	assert.True(t, agg.tiers.isCold(idleID))
+	assert.False(t, agg.tiers.isCold(activeID))
+	assert.Equal(t, map[[16]byte]struct{}{idleID: {}}, storedIn(agg.cold))
+	assert.NotContains(t, storedIn(agg.shards[0]), idleID)
+	assert.Contains(t, storedIn(agg.shards[0]), activeID)
+
+	// The ID is moved back to the warm tier once active again, the metrics
+	// of both tiers are harvested together.
+	aggregate(idleID)
+	assert.False(t, agg.tiers.isCold(idleID))
+	for i := 4; i <= 10; i++ {
+		harvest(start.Add(time.Duration(i) * time.Minute))
+	}
+	assert.Empty(t, storedIn(agg.cold))
+	// The periods tracked for moving the combined metrics between the
+	// tiers are forgotten once harvested.
+	assert.Empty(t, agg.tiers.stored)
+	assert.Equal(t, []float64{1, 1}, processed[harvested{id: idleID, ivl: time.Minute}])
+	assert.Equal(t, []float64{2}, processed[harvested{id: idleID, ivl: 10 * time.Minute}])
+	assert.Equal(t, []float64{3}, processed[harvested{id: activeID, ivl: 10 * time.Minute}])
+}
+
+func TestColdTierRestart(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	id := EncodeToCombinedMetricsKeyID(t, "ab01")
+	newAggregator := func() *Aggregator {
+		agg, err := New(
+			WithDataDir(dir),
+			WithAggregationIntervals([]time.Duration{time.Minute, time.Hour}),
+			WithColdTier(1),
+			WithProcessor(noOpProcessor()),
+			WithLogger(zap.NewNop()),
+		)
+		require.NoError(t, err)
+		return agg
+	}
+
+	agg := newAggregator()
+	cmk := CombinedMetricsKey{
+		Interval:       time.Hour,
+		ProcessingTime: agg.processingTime.Truncate(time.Hour),
+		ID:             id,
+	}
+	key := make([]byte, CombinedMetricsKeyEncodedSize)
+	require.NoError(t, cmk.MarshalBinaryToSizedBuffer(key))
+	value, err := NewTestCombinedMetrics(WithEventsTotal(1)).GetProto().MarshalVT()
+	require.NoError(t, err)
+	require.NoError(t, agg.cold.Set(key, value, pebble.Sync))
+	// Close the databases without harvesting, as if the aggregator crashed.
+	for _, shard := range agg.shards {
+		require.NoError(t, shard.Close())
+	}
+
+	// The cold IDs, and the periods of their combined metrics, are
+	// restored from the cold tier.
+	agg = newAggregator()
+	t.Cleanup(func() { agg.Close(ctx) })
+	assert.True(t, agg.tiers.isCold(id))
+	assert.Equal(t, map[[16]byte]map[tierPeriod]struct{}{
+		id: {{ivl: time.Hour, processingTime: cmk.ProcessingTime.Unix()}: {}},
+	}, agg.tiers.stored)
+}
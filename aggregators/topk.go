@@ -0,0 +1,111 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"container/heap"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+)
+
+type (
+	lowestTransactionGroups        = lowestGroups[transactionAggregationKey, *aggregationpb.KeyedTransactionMetrics]
+	lowestServiceTransactionGroups = lowestGroups[serviceTransactionAggregationKey, *aggregationpb.KeyedServiceTransactionMetrics]
+	lowestSpanGroups               = lowestGroups[spanAggregationKey, *aggregationpb.KeyedSpanMetrics]
+	lowestErrorGroups              = lowestGroups[errorAggregationKey, *aggregationpb.KeyedErrorMetrics]
+	lowestServiceGraphEdges        = lowestGroups[serviceGraphEdgeAggregationKey, *aggregationpb.KeyedServiceGraphEdgeMetrics]
+)
+
+// lowestGroups is a min-heap of the groups of a service instance by their
+// count, finding the group to evict when the top-K groups are retained,
+// see WithTopKRetention. The heap is built from the groups on first use
+// and updated as groups are added, merged and evicted, so that the groups
+// are only tracked once their limit is reached.
+type lowestGroups[K comparable, V any] struct {
+	count   func(V) float64
+	index   map[K]int
+	entries []lowestGroup[K]
+}
+
+type lowestGroup[K comparable] struct {
+	key   K
+	count float64
+}
+
+// lowest returns the key of the group with the lowest count if it is
+// lower than the given count, building the heap from the groups on first
+// use. The returned bool is false if no such group exists.
+func (h *lowestGroups[K, V]) lowest(
+	groups map[K]V,
+	count func(V) float64,
+	than float64,
+) (K, bool) {
+	if h.index == nil {
+		h.count = count
+		h.index = make(map[K]int, len(groups))
+		h.entries = make([]lowestGroup[K], 0, len(groups))
+		for k, v := range groups {
+			h.index[k] = len(h.entries)
+			h.entries = append(h.entries, lowestGroup[K]{key: k, count: count(v)})
+		}
+		heap.Init(h)
+	}
+	if len(h.entries) == 0 || h.entries[0].count >= than {
+		var zero K
+		return zero, false
+	}
+	return h.entries[0].key, true
+}
+
+// set adds the group, or updates its count after a merge, once the heap
+// is built.
+func (h *lowestGroups[K, V]) set(key K, v V) {
+	if h.index == nil {
+		return
+	}
+	count := h.count(v)
+	if i, ok := h.index[key]; ok {
+		h.entries[i].count = count
+		heap.Fix(h, i)
+		return
+	}
+	heap.Push(h, lowestGroup[K]{key: key, count: count})
+}
+
+// remove removes the evicted group once the heap is built.
+func (h *lowestGroups[K, V]) remove(key K) {
+	if h.index == nil {
+		return
+	}
+	if i, ok := h.index[key]; ok {
+		heap.Remove(h, i)
+	}
+}
+
+func (h *lowestGroups[K, V]) Len() int { return len(h.entries) }
+
+func (h *lowestGroups[K, V]) Less(i, j int) bool {
+	return h.entries[i].count < h.entries[j].count
+}
+
+func (h *lowestGroups[K, V]) Swap(i, j int) {
+	h.entries[i], h.entries[j] = h.entries[j], h.entries[i]
+	h.index[h.entries[i].key] = i
+	h.index[h.entries[j].key] = j
+}
+
+func (h *lowestGroups[K, V]) Push(x any) {
+	g := x.(lowestGroup[K])
+	h.index[g.key] = len(h.entries)
+	h.entries = append(h.entries, g)
+}
+
+func (h *lowestGroups[K, V]) Pop() any {
+	last := len(h.entries) - 1
+	g := h.entries[last]
+	h.entries = h.entries[:last]
+	delete(h.index, g.key)
+	return g
+}
@@ -0,0 +1,75 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLowestGroups(t *testing.T) {
+	identity := func(v float64) float64 { return v }
+	groups := map[int]float64{1: 5, 2: 3, 3: 7}
+	var h lowestGroups[int, float64]
+
+	// The heap is not tracking the groups until first used.
+	h.set(4, 1)
+	assert.Zero(t, h.Len())
+
+	k, ok := h.lowest(groups, identity, 10)
+	require.True(t, ok)
+	assert.Equal(t, 2, k)
+	_, ok = h.lowest(groups, identity, 3)
+	assert.False(t, ok, "groups with the same count are not evicted")
+
+	// Merging into the lowest group raises its count.
+	groups[2] = 6
+	h.set(2, groups[2])
+	k, _ = h.lowest(groups, identity, 10)
+	assert.Equal(t, 1, k)
+
+	delete(groups, 1)
+	h.remove(1)
+	groups[4] = 4
+	h.set(4, groups[4])
+	k, _ = h.lowest(groups, identity, 10)
+	assert.Equal(t, 4, k)
+}
+
+func TestLowestGroupsRandomized(t *testing.T) {
+	identity := func(v float64) float64 { return v }
+	r := rand.New(rand.NewSource(0))
+	groups := make(map[int]float64)
+	for i := 0; i < 100; i++ {
+		groups[i] = float64(r.Intn(1000))
+	}
+	var h lowestGroups[int, float64]
+	h.lowest(groups, identity, 0)
+	for i := 0; i < 10000; i++ {
+		k := r.Intn(200)
+		switch _, ok := groups[k]; {
+		case ok && r.Intn(4) == 0:
+			delete(groups, k)
+			h.remove(k)
+		default:
+			groups[k] += float64(r.Intn(100))
+			h.set(k, groups[k])
+		}
+
+		lowest, ok := h.lowest(groups, identity, 1e9)
+		require.Equal(t, len(groups) > 0, ok)
+		lowestCount := groups[lowest]
+		for _, v := range groups {
+			if v < lowestCount {
+				lowestCount = v
+			}
+		}
+		require.Equal(t, lowestCount, groups[lowest])
+	}
+	assert.Equal(t, len(groups), h.Len())
+}
@@ -0,0 +1,412 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+)
+
+const (
+	// compressedValueMarker prefixes zstd compressed values. A protobuf
+	// encoded message never starts with a byte less than 8 as field number
+	// 0 is invalid, so compressed and uncompressed values can be told
+	// apart.
+	compressedValueMarker byte = 0
+
+	// snappyValueMarker prefixes snappy compressed values.
+	snappyValueMarker byte = 1
+
+	// encryptedValueMarker prefixes encrypted values. The nonce follows
+	// the marker, the sealed value, optionally compressed, follows the
+	// nonce.
+	encryptedValueMarker byte = 2
+
+	// dictionaryFileName is the name of the file in the data directory
+	// persisting the trained compression dictionary.
+	dictionaryFileName = "values.dict"
+
+	// maxDictionarySize is the maximum size of a trained dictionary.
+	maxDictionarySize = 64 << 10
+
+	// minDictionaryID is the lowest dictionary ID not reserved by zstd.
+	minDictionaryID = 1 << 15
+
+	// combinedMetricsVersion is the latest version of the combined metrics
+	// value format. It must be bumped whenever the meaning of the stored
+	// values changes, with upgradeCombinedMetrics converting the values of
+	// at least the previous version. Older versions of the library ignore
+	// the fields they do not know, so they can still merge the values
+	// written by newer versions during rolling upgrades, except for the
+	// histograms of version 2 values which are encoded sparsely. The merger
+	// thus only writes version 2 values if sparse histograms are enabled,
+	// see WithSparseHistograms.
+	combinedMetricsVersion = 2
+
+	// denseCombinedMetricsVersion is the version of the combined metrics
+	// values written by the merger with densely encoded histograms.
+	denseCombinedMetricsVersion = 1
+)
+
+// DictionaryTrainer builds a zstd compression dictionary of at most maxSize
+// bytes from sampled combined metrics values. The returned dictionary is
+// either a raw content dictionary or a dictionary in the zstd dictionary
+// format, for example trained with `zstd --train`.
+type DictionaryTrainer func(samples [][]byte, maxSize int) ([]byte, error)
+
+// RawContentDictionary is the default DictionaryTrainer. It builds a raw
+// content dictionary from the samples, favouring the first samples which
+// are placed at the end of the dictionary where zstd matches them most
+// cheaply.
+func RawContentDictionary(samples [][]byte, maxSize int) ([]byte, error) {
+	if len(samples) == 0 {
+		return nil, errors.New("no samples to train dictionary")
+	}
+	var size int
+	n := 0
+	for ; n < len(samples) && size+len(samples[n]) <= maxSize; n++ {
+		size += len(samples[n])
+	}
+	if n == 0 {
+		return samples[0][len(samples[0])-maxSize:], nil
+	}
+	dict := make([]byte, 0, size)
+	for i := n - 1; i >= 0; i-- {
+		dict = append(dict, samples[i]...)
+	}
+	return dict, nil
+}
+
+// valueCodec encodes and decodes the combined metrics values stored in
+// the database. Values are optionally snappy or zstd compressed, zstd
+// using a dictionary once trained, and optionally encrypted after
+// compression. Values are decoded regardless of the configured
+// compression, so compression can be changed for existing data
+// directories. Unencrypted values are always decoded, so encryption can be
+// enabled for existing data directories. A valueCodec is safe for
+// concurrent use. A nil valueCodec stores values uncompressed.
+type valueCodec struct {
+	compression Compression
+	zstdLevel   zstd.EncoderLevel
+	zstd        atomic.Pointer[zstdCodec]
+	aead        cipher.AEAD
+}
+
+type zstdCodec struct {
+	enc *zstd.Encoder
+	dec *zstd.Decoder
+	// dict is true if the codec uses a dictionary.
+	dict bool
+}
+
+func newZstdCodec(dict []byte, level zstd.EncoderLevel) (*zstdCodec, error) {
+	encOpts := []zstd.EOption{
+		zstd.WithEncoderConcurrency(1),
+		zstd.WithEncoderLevel(level),
+	}
+	decOpts := []zstd.DOption{zstd.WithDecoderConcurrency(0)}
+	if len(dict) > 0 {
+		id, err := dictionaryID(dict)
+		if err != nil {
+			return nil, err
+		}
+		if id == 0 {
+			// Raw content dictionary, derive a stable ID from the content.
+			id = minDictionaryID + crc32.ChecksumIEEE(dict)%(1<<31-minDictionaryID)
+			encOpts = append(encOpts, zstd.WithEncoderDictRaw(id, dict))
+			decOpts = append(decOpts, zstd.WithDecoderDictRaw(id, dict))
+		} else {
+			encOpts = append(encOpts, zstd.WithEncoderDict(dict))
+			decOpts = append(decOpts, zstd.WithDecoderDicts(dict))
+		}
+	}
+	enc, err := zstd.NewWriter(nil, encOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zstd encoder: %w", err)
+	}
+	dec, err := zstd.NewReader(nil, decOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zstd decoder: %w", err)
+	}
+	return &zstdCodec{enc: enc, dec: dec, dict: len(dict) > 0}, nil
+}
+
+// dictionaryID returns the ID of a dictionary in the zstd dictionary format
+// or 0 for a raw content dictionary.
+func dictionaryID(dict []byte) (uint32, error) {
+	const magic = 0xEC30A437
+	if len(dict) < 8 || uint32(dict[0])|uint32(dict[1])<<8|uint32(dict[2])<<16|uint32(dict[3])<<24 != magic {
+		return 0, nil
+	}
+	id := uint32(dict[4]) | uint32(dict[5])<<8 | uint32(dict[6])<<16 | uint32(dict[7])<<24
+	if id == 0 {
+		return 0, errors.New("invalid zstd dictionary ID 0")
+	}
+	return id, nil
+}
+
+// newValueCodec returns a new value codec compressing values using the
+// given compression, see WithValueCompressionCodec for the level, and
+// encrypting values using aead, if not nil. The dictionary persisted in
+// dataDir is used if any. dataDir is empty for in-memory databases.
+func newValueCodec(
+	compression Compression,
+	level int,
+	aead cipher.AEAD,
+	dataDir string,
+) (*valueCodec, error) {
+	c := &valueCodec{aead: aead}
+	// Values are compressed on the ingest path, the fastest level trades
+	// little compression for small values and makes best use of the
+	// dictionary.
+	zstdLevel := zstd.SpeedFastest
+	if level > 0 {
+		zstdLevel = zstd.EncoderLevelFromZstd(level)
+	}
+	var dict []byte
+	if dataDir != "" {
+		var err error
+		dict, err = os.ReadFile(filepath.Join(dataDir, dictionaryFileName))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read compression dictionary: %w", err)
+		}
+		// The dictionary is derived from values, it is encrypted the same
+		// way as the values.
+		if dict, err = c.open(dict); err != nil {
+			return nil, fmt.Errorf("failed to decrypt compression dictionary: %w", err)
+		}
+	}
+	zc, err := newZstdCodec(dict, zstdLevel)
+	if err != nil {
+		return nil, err
+	}
+	c.compression = compression
+	c.zstdLevel = zstdLevel
+	c.zstd.Store(zc)
+	return c, nil
+}
+
+// compresses returns true if the codec compresses values.
+func (c *valueCodec) compresses() bool {
+	return c != nil && (c.compression == SnappyCompression || c.compression == ZstdCompression)
+}
+
+// encodes returns true if the stored representation of values differs
+// from the protobuf encoding, i.e. if the codec compresses or encrypts
+// values.
+func (c *valueCodec) encodes() bool {
+	return c.compresses() || (c != nil && c.aead != nil)
+}
+
+// hasDictionary returns true if the codec uses a compression dictionary.
+func (c *valueCodec) hasDictionary() bool {
+	return c != nil && c.zstd.Load().dict
+}
+
+// setDictionary switches the codec to the dictionary, persisting it in
+// dataDir unless dataDir is empty. Values compressed before are still
+// decoded as they do not reference a dictionary.
+func (c *valueCodec) setDictionary(dict []byte, dataDir string) error {
+	zc, err := newZstdCodec(dict, c.zstdLevel)
+	if err != nil {
+		return err
+	}
+	if dataDir != "" {
+		sealed, err := c.seal(dict)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt compression dictionary: %w", err)
+		}
+		path := filepath.Join(dataDir, dictionaryFileName)
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, sealed, 0o644); err != nil {
+			return fmt.Errorf("failed to write compression dictionary: %w", err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			return fmt.Errorf("failed to persist compression dictionary: %w", err)
+		}
+	}
+	c.zstd.Store(zc)
+	return nil
+}
+
+// encodeBuffers are the buffers reused for encoding values, avoiding the
+// allocations per encoded value. The encoded value is only valid until
+// the buffers are reused.
+type encodeBuffers struct {
+	key, value, compressed, sealed []byte
+}
+
+// resizeBuffer returns the buffer resized to n bytes, reallocated only if
+// its capacity is insufficient.
+func resizeBuffer(buf []byte, n int) []byte {
+	if cap(buf) < n {
+		return make([]byte, n)
+	}
+	return buf[:n]
+}
+
+// encode returns the stored representation of the protobuf encoded value.
+func (c *valueCodec) encode(value []byte) ([]byte, error) {
+	return c.seal(c.compress(value))
+}
+
+// encodeTo is like encode, but encodes the value into the buffers.
+func (c *valueCodec) encodeTo(bufs *encodeBuffers, value []byte) ([]byte, error) {
+	compressed := value
+	if c.compresses() {
+		bufs.compressed = c.compressTo(bufs.compressed, value)
+		compressed = bufs.compressed
+	}
+	if c == nil || c.aead == nil {
+		return compressed, nil
+	}
+	sealed, err := c.sealTo(bufs.sealed, compressed)
+	if err != nil {
+		return nil, err
+	}
+	bufs.sealed = sealed
+	return sealed, nil
+}
+
+// compress returns the compressed value, prefixed with the marker of the
+// compression, or the value itself if the codec does not compress values.
+func (c *valueCodec) compress(value []byte) []byte {
+	if !c.compresses() {
+		return value
+	}
+	return c.compressTo(nil, value)
+}
+
+// compressTo compresses the value into dst, which is reallocated only if
+// its capacity is insufficient.
+func (c *valueCodec) compressTo(dst, value []byte) []byte {
+	if c.compression == SnappyCompression {
+		dst = resizeBuffer(dst, 1+snappy.MaxEncodedLen(len(value)))
+		dst[0] = snappyValueMarker
+		return dst[:1+len(snappy.Encode(dst[1:], value))]
+	}
+	if dst == nil {
+		dst = make([]byte, 0, len(value)/2+1)
+	}
+	dst = append(dst[:0], compressedValueMarker)
+	return c.zstd.Load().enc.EncodeAll(value, dst)
+}
+
+// seal encrypts the value using a random nonce, if the codec encrypts
+// values. Otherwise the value is returned as is.
+func (c *valueCodec) seal(value []byte) ([]byte, error) {
+	if c == nil || c.aead == nil {
+		return value, nil
+	}
+	return c.sealTo(nil, value)
+}
+
+// sealTo encrypts the value into dst, which is reallocated only if its
+// capacity is insufficient.
+func (c *valueCodec) sealTo(dst, value []byte) ([]byte, error) {
+	nonceSize := c.aead.NonceSize()
+	if n := 1 + nonceSize + len(value) + c.aead.Overhead(); cap(dst) < n {
+		dst = make([]byte, 0, n)
+	}
+	dst = dst[:1+nonceSize]
+	dst[0] = encryptedValueMarker
+	nonce := dst[1:]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return c.aead.Seal(dst, nonce, value, nil), nil
+}
+
+// open decrypts the value if it is encrypted. Otherwise the value is
+// returned as is.
+func (c *valueCodec) open(value []byte) ([]byte, error) {
+	if len(value) == 0 || value[0] != encryptedValueMarker {
+		return value, nil
+	}
+	if c == nil || c.aead == nil {
+		return nil, errors.New("no encryption key to decrypt value")
+	}
+	nonceSize := c.aead.NonceSize()
+	if len(value) < 1+nonceSize {
+		return nil, errors.New("invalid encrypted value of insufficient length")
+	}
+	opened, err := c.aead.Open(nil, value[1:1+nonceSize], value[1+nonceSize:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt value: %w", err)
+	}
+	return opened, nil
+}
+
+// decode returns the protobuf encoded value of the stored value.
+func (c *valueCodec) decode(value []byte) ([]byte, error) {
+	value, err := c.open(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(value) == 0 {
+		return value, nil
+	}
+	switch value[0] {
+	case compressedValueMarker:
+	case snappyValueMarker:
+		decoded, err := snappy.Decode(nil, value[1:])
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress value: %w", err)
+		}
+		return decoded, nil
+	default:
+		return value, nil
+	}
+	if c == nil {
+		return nil, errors.New("no codec to decompress value")
+	}
+	decoded, err := c.zstd.Load().dec.DecodeAll(value[1:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress value: %w", err)
+	}
+	return decoded, nil
+}
+
+// unmarshal decodes the stored value into cm, upgrading values of previous
+// versions of the format to the current version.
+func (c *valueCodec) unmarshal(value []byte, cm *aggregationpb.CombinedMetrics) error {
+	decoded, err := c.decode(value)
+	if err != nil {
+		return err
+	}
+	if err := cm.UnmarshalVT(decoded); err != nil {
+		return err
+	}
+	if err := decodeSparseHistograms(cm); err != nil {
+		return err
+	}
+	upgradeCombinedMetrics(cm)
+	return nil
+}
+
+// upgradeCombinedMetrics converts combined metrics decoded from a value of
+// a previous version of the format to the current version. Values of newer
+// versions are left as is, the fields unknown to this version are ignored.
+func upgradeCombinedMetrics(cm *aggregationpb.CombinedMetrics) {
+	if cm.Version >= combinedMetricsVersion {
+		return
+	}
+	// Values written before the format was versioned, version 0, have the
+	// same meaning as version 1 values. Version 1 values only differ from
+	// version 2 values in the encoding of their histograms, which are
+	// decoded from either encoding.
+	cm.Version = combinedMetricsVersion
+}
@@ -0,0 +1,414 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+	"github.com/elastic/apm-data/model/modelpb"
+)
+
+func TestValueCodec(t *testing.T) {
+	cm := &aggregationpb.CombinedMetrics{EventsTotal: 3, Version: combinedMetricsVersion}
+	for _, name := range []string{"frontend", "checkout", "payment", "inventory"} {
+		cm.ServiceMetrics = append(cm.ServiceMetrics, &aggregationpb.KeyedServiceMetrics{
+			Key: &aggregationpb.ServiceAggregationKey{ServiceName: name, ServiceEnvironment: "production"},
+		})
+	}
+	plain, err := cm.MarshalVT()
+	require.NoError(t, err)
+
+	assertRoundTrip := func(t *testing.T, c *valueCodec, encoded []byte) {
+		t.Helper()
+		decoded := &aggregationpb.CombinedMetrics{}
+		require.NoError(t, c.unmarshal(encoded, decoded))
+		assert.True(t, proto.Equal(cm, decoded))
+	}
+
+	uncompressed, err := newValueCodec(NoCompression, 0, nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, plain, mustEncode(t, uncompressed, plain))
+	assertRoundTrip(t, uncompressed, plain)
+
+	dir := t.TempDir()
+	compressed, err := newValueCodec(ZstdCompression, 0, nil, dir)
+	require.NoError(t, err)
+	assert.False(t, compressed.hasDictionary())
+	beforeDict := mustEncode(t, compressed, plain)
+	assert.Equal(t, compressedValueMarker, beforeDict[0])
+	assertRoundTrip(t, compressed, beforeDict)
+	assertRoundTrip(t, compressed, plain)
+
+	dict, err := RawContentDictionary([][]byte{plain}, maxDictionarySize)
+	require.NoError(t, err)
+	require.NoError(t, compressed.setDictionary(dict, dir))
+	assert.True(t, compressed.hasDictionary())
+	withDict := mustEncode(t, compressed, plain)
+	assert.Less(t, len(withDict), len(beforeDict))
+	assertRoundTrip(t, compressed, withDict)
+	assertRoundTrip(t, compressed, beforeDict)
+
+	// The persisted dictionary is loaded, also for decoding only.
+	reopened, err := newValueCodec(NoCompression, 0, nil, dir)
+	require.NoError(t, err)
+	assert.True(t, reopened.hasDictionary())
+	assertRoundTrip(t, reopened, withDict)
+
+	// Compressed values cannot be decoded without the dictionary.
+	_, err = uncompressed.decode(withDict)
+	assert.ErrorContains(t, err, "failed to decompress value")
+}
+
+func TestValueCodecCompression(t *testing.T) {
+	cm := &aggregationpb.CombinedMetrics{EventsTotal: 3, Version: combinedMetricsVersion}
+	for i := 0; i < 20; i++ {
+		cm.ServiceMetrics = append(cm.ServiceMetrics, &aggregationpb.KeyedServiceMetrics{
+			Key: &aggregationpb.ServiceAggregationKey{ServiceName: "frontend", ServiceEnvironment: "production"},
+		})
+	}
+	plain, err := cm.MarshalVT()
+	require.NoError(t, err)
+
+	var encoded [][]byte
+	for _, tc := range []struct {
+		compression Compression
+		level       int
+		marker      byte
+	}{
+		{compression: SnappyCompression, marker: snappyValueMarker},
+		{compression: ZstdCompression, marker: compressedValueMarker},
+		{compression: ZstdCompression, level: 19, marker: compressedValueMarker},
+	} {
+		c, err := newValueCodec(tc.compression, tc.level, nil, "")
+		require.NoError(t, err)
+		value := mustEncode(t, c, plain)
+		assert.Equal(t, tc.marker, value[0])
+		assert.Less(t, len(value), len(plain))
+		encoded = append(encoded, value)
+	}
+
+	// Values are decoded regardless of the compression of the codec.
+	for _, compression := range []Compression{NoCompression, SnappyCompression, ZstdCompression} {
+		c, err := newValueCodec(compression, 0, nil, "")
+		require.NoError(t, err)
+		for _, value := range encoded {
+			decoded, err := c.decode(value)
+			require.NoError(t, err)
+			assert.Equal(t, plain, decoded)
+		}
+	}
+}
+
+func TestValueCodecVersion(t *testing.T) {
+	// Values written before the format was versioned.
+	legacy, err := (&aggregationpb.CombinedMetrics{EventsTotal: 3}).MarshalVT()
+	require.NoError(t, err)
+	// Values written by a newer version with a field unknown to this one.
+	newer, err := (&aggregationpb.CombinedMetrics{
+		EventsTotal: 2,
+		Version:     combinedMetricsVersion + 1,
+	}).MarshalVT()
+	require.NoError(t, err)
+	newer = protowire.AppendTag(newer, 1000, protowire.VarintType)
+	newer = protowire.AppendVarint(newer, 1)
+
+	var c *valueCodec
+	var cm aggregationpb.CombinedMetrics
+	require.NoError(t, c.unmarshal(legacy, &cm))
+	assert.Equal(t, uint32(combinedMetricsVersion), cm.Version)
+	assert.Equal(t, float64(3), cm.EventsTotal)
+	cm.ResetVT()
+	require.NoError(t, c.unmarshal(newer, &cm))
+	assert.Equal(t, uint32(combinedMetricsVersion+1), cm.Version)
+	assert.Equal(t, float64(2), cm.EventsTotal)
+
+	// Values of all versions are merged into a value with densely encoded
+	// histograms by default.
+	merger := newCombinedMetricsMerger(Config{}, c, nil, nil)
+	vm, err := merger.Merge(nil, legacy)
+	require.NoError(t, err)
+	require.NoError(t, vm.MergeNewer(newer))
+	merged, closer, err := vm.Finish(true)
+	require.NoError(t, err)
+	assert.Nil(t, closer)
+	cm.ResetVT()
+	require.NoError(t, cm.UnmarshalVT(merged))
+	assert.Equal(t, uint32(denseCombinedMetricsVersion), cm.Version)
+	assert.Equal(t, float64(5), cm.EventsTotal)
+}
+
+func TestValueCodecSparseHistograms(t *testing.T) {
+	tcm := NewTestCombinedMetrics(WithEventsTotal(1))
+	tcm.AddServiceMetrics(serviceAggregationKey{
+		Timestamp:   time.Now(),
+		ServiceName: "svc",
+	}).AddServiceInstanceMetrics(serviceInstanceAggregationKey{}).
+		AddTransaction(transactionAggregationKey{TransactionName: "txn"})
+	expected := tcm.GetProto()
+	expectedHistogram := expected.ServiceMetrics[0].Metrics.ServiceInstanceMetrics[0].Metrics.
+		TransactionMetrics[0].Metrics.Histogram
+	// Values written before the sparse encoding of histograms.
+	dense := expected.CloneVT()
+	dense.Version = 1
+	value, err := dense.MarshalVT()
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name            string
+		sparse          bool
+		expectedVersion uint32
+	}{
+		// Histograms are encoded densely by default so that versions of
+		// the library predating the sparse encoding can read the values.
+		{name: "dense", expectedVersion: denseCombinedMetricsVersion},
+		{name: "sparse", sparse: true, expectedVersion: combinedMetricsVersion},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var c *valueCodec
+			merger := newCombinedMetricsMerger(Config{
+				Limits: Limits{
+					MaxServices:                        10,
+					MaxServiceInstanceGroupsPerService: 10,
+					MaxServiceInstanceGroups:           10,
+					MaxTransactionGroups:               10,
+					MaxTransactionGroupsPerService:     10,
+				},
+				SparseHistograms: tc.sparse,
+			}, c, nil, nil)
+			vm, err := merger.Merge(nil, value)
+			require.NoError(t, err)
+			merged, _, err := vm.Finish(true)
+			require.NoError(t, err)
+
+			var cm aggregationpb.CombinedMetrics
+			require.NoError(t, cm.UnmarshalVT(merged))
+			assert.Equal(t, tc.expectedVersion, cm.Version)
+			histogram := cm.ServiceMetrics[0].Metrics.ServiceInstanceMetrics[0].Metrics.
+				TransactionMetrics[0].Metrics.Histogram
+			if tc.sparse {
+				assert.Empty(t, histogram.Buckets)
+				assert.NotEmpty(t, histogram.Sparse)
+			} else {
+				assert.Equal(t, expectedHistogram.Buckets, histogram.Buckets)
+				assert.Empty(t, histogram.Sparse)
+			}
+
+			cm.ResetVT()
+			require.NoError(t, c.unmarshal(merged, &cm))
+			histogram = cm.ServiceMetrics[0].Metrics.ServiceInstanceMetrics[0].Metrics.
+				TransactionMetrics[0].Metrics.Histogram
+			assert.Equal(t, expectedHistogram.Buckets, histogram.Buckets)
+			assert.Equal(t, expectedHistogram.Counts, histogram.Counts)
+		})
+	}
+}
+
+func mustEncode(t *testing.T, c *valueCodec, value []byte) []byte {
+	t.Helper()
+	encoded, err := c.encode(value)
+	require.NoError(t, err)
+	return encoded
+}
+
+func TestRawContentDictionary(t *testing.T) {
+	_, err := RawContentDictionary(nil, 10)
+	assert.EqualError(t, err, "no samples to train dictionary")
+
+	dict, err := RawContentDictionary([][]byte{[]byte("abc"), []byte("def"), []byte("ghi")}, 7)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("defabc"), dict)
+
+	dict, err = RawContentDictionary([][]byte{[]byte("abcdefghi")}, 4)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("fghi"), dict)
+}
+
+func TestAggregatorValueCompression(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	var trainedSamples int
+	agg, err := New(
+		WithDataDir(dir),
+		WithLimits(Limits{
+			MaxServices:                           10,
+			MaxServiceInstanceGroupsPerService:    10,
+			MaxTransactionGroups:                  10,
+			MaxTransactionGroupsPerService:        10,
+			MaxServiceTransactionGroups:           10,
+			MaxServiceTransactionGroupsPerService: 10,
+		}),
+		WithProcessor(noOpProcessor()),
+		WithValueCompression(true),
+		WithDictionaryTraining(2, func(samples [][]byte, maxSize int) ([]byte, error) {
+			trainedSamples = len(samples)
+			return RawContentDictionary(samples, maxSize)
+		}),
+		WithLogger(zap.NewNop()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { agg.Close(ctx) })
+
+	ids := []string{"ab01", "ab02", "ab03"}
+	for _, id := range ids {
+		require.NoError(t, agg.AggregateBatch(ctx, EncodeToCombinedMetricsKeyID(t, id), &modelpb.Batch{{
+			Event: &modelpb.Event{Duration: durationpb.New(time.Millisecond)},
+			Transaction: &modelpb.Transaction{
+				Name:                "T-1000",
+				Type:                "type",
+				RepresentativeCount: 1,
+			},
+			Service: &modelpb.Service{Name: "svc"},
+		}}))
+	}
+	assert.Equal(t, 2, trainedSamples)
+	assert.FileExists(t, filepath.Join(dir, dictionaryFileName))
+
+	it, err := agg.NewIterator(IteratorOptions{})
+	require.NoError(t, err)
+	var n int
+	for valid := it.First(); valid; valid = it.Next() {
+		n++
+		assert.Equal(t, compressedValueMarker, it.cur.Value()[0])
+		cm := &aggregationpb.CombinedMetrics{}
+		require.NoError(t, it.Value(cm))
+		assert.Equal(t, 1.0, cm.EventsTotal)
+		require.Len(t, cm.ServiceMetrics, 1)
+		assert.Equal(t, "svc", cm.ServiceMetrics[0].Key.ServiceName)
+	}
+	require.NoError(t, it.Error())
+	require.NoError(t, it.Close())
+	assert.Equal(t, len(ids), n)
+}
+
+func newTestAEAD(t *testing.T, key string) cipher.AEAD {
+	t.Helper()
+	block, err := aes.NewCipher([]byte(key))
+	require.NoError(t, err)
+	aead, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+	return aead
+}
+
+func TestValueCodecEncryption(t *testing.T) {
+	cm := &aggregationpb.CombinedMetrics{EventsTotal: 3, Version: combinedMetricsVersion}
+	cm.ServiceMetrics = append(cm.ServiceMetrics, &aggregationpb.KeyedServiceMetrics{
+		Key: &aggregationpb.ServiceAggregationKey{ServiceName: "frontend"},
+	})
+	plain, err := cm.MarshalVT()
+	require.NoError(t, err)
+	aead := newTestAEAD(t, "0123456789abcdef")
+
+	dir := t.TempDir()
+	c, err := newValueCodec(ZstdCompression, 0, aead, dir)
+	require.NoError(t, err)
+	encrypted := mustEncode(t, c, plain)
+	assert.Equal(t, encryptedValueMarker, encrypted[0])
+	assert.NotContains(t, string(encrypted), "frontend")
+	decoded, err := c.decode(encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, plain, decoded)
+	// Unencrypted values remain readable.
+	decoded, err = c.decode(plain)
+	require.NoError(t, err)
+	assert.Equal(t, plain, decoded)
+
+	// The dictionary is persisted encrypted.
+	require.NoError(t, c.setDictionary(plain, dir))
+	dict, err := os.ReadFile(filepath.Join(dir, dictionaryFileName))
+	require.NoError(t, err)
+	assert.NotContains(t, string(dict), "frontend")
+	reopened, err := newValueCodec(ZstdCompression, 0, aead, dir)
+	require.NoError(t, err)
+	assert.True(t, reopened.hasDictionary())
+	_, err = newValueCodec(ZstdCompression, 0, nil, dir)
+	assert.EqualError(t, err, "failed to decrypt compression dictionary: no encryption key to decrypt value")
+
+	unencrypted, err := newValueCodec(NoCompression, 0, nil, "")
+	require.NoError(t, err)
+	_, err = unencrypted.decode(encrypted)
+	assert.EqualError(t, err, "no encryption key to decrypt value")
+	wrongKey, err := newValueCodec(NoCompression, 0, newTestAEAD(t, "fedcba9876543210"), "")
+	require.NoError(t, err)
+	_, err = wrongKey.decode(encrypted)
+	assert.ErrorContains(t, err, "failed to decrypt value")
+}
+
+func TestAggregatorValueEncryption(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	aead := newTestAEAD(t, "0123456789abcdef")
+	opts := []Option{
+		WithLimits(Limits{
+			MaxServices:                           10,
+			MaxServiceInstanceGroupsPerService:    10,
+			MaxTransactionGroups:                  10,
+			MaxTransactionGroupsPerService:        10,
+			MaxServiceTransactionGroups:           10,
+			MaxServiceTransactionGroupsPerService: 10,
+		}),
+		WithProcessor(noOpProcessor()),
+		WithValueEncryption(aead),
+		WithLogger(zap.NewNop()),
+	}
+	agg, err := New(append(opts, WithDataDir(dir))...)
+	require.NoError(t, err)
+	for _, id := range []string{"ab01", "ab02"} {
+		require.NoError(t, agg.AggregateBatch(ctx, EncodeToCombinedMetricsKeyID(t, id), &modelpb.Batch{{
+			Event: &modelpb.Event{Duration: durationpb.New(time.Millisecond)},
+			Transaction: &modelpb.Transaction{
+				Name:                "T-1000",
+				Type:                "type",
+				RepresentativeCount: 1,
+			},
+			Service: &modelpb.Service{Name: "secret-service"},
+		}}))
+	}
+	it, err := agg.NewIterator(IteratorOptions{})
+	require.NoError(t, err)
+	require.NoError(t, it.Close())
+	// Closing without running the harvest loop keeps the metrics on disk.
+	agg.db.Close()
+
+	require.NoError(t, filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), "secret-service", path)
+		return nil
+	}))
+
+	store, err := OpenReadOnly(dir, opts...)
+	require.NoError(t, err)
+	defer store.Close()
+	storeIt, err := store.NewIterator(IteratorOptions{})
+	require.NoError(t, err)
+	defer storeIt.Close()
+	var n int
+	for valid := storeIt.First(); valid; valid = storeIt.Next() {
+		n++
+		cm := &aggregationpb.CombinedMetrics{}
+		require.NoError(t, storeIt.Value(cm))
+		require.Len(t, cm.ServiceMetrics, 1)
+		assert.Equal(t, "secret-service", cm.ServiceMetrics[0].Key.ServiceName)
+	}
+	assert.Equal(t, 2, n)
+}
@@ -0,0 +1,218 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+
+	"github.com/elastic/apm-data/model/modelpb"
+)
+
+// AdvanceWatermark advances the event time watermark of the combined
+// metrics ID to the given time, asserting that no more events timestamped
+// before it will be aggregated for the ID. The periods of the aggregation
+// intervals of the ID ending at or before the watermark are harvested
+// before AdvanceWatermark returns, rather than when the harvest loop
+// reaches them on the wall clock. This gives correct results when
+// replaying historical data, whose periods are far behind the wall clock.
+//
+// Once an ID has a watermark, its events are bucketed into the periods of
+// their timestamps regardless of the allowed lateness, and the events
+// timestamped before the watermark are dropped. The watermark never moves
+// backwards. Finding the combined metrics of the ID seeks to the ID in
+// each stored period between the previous watermark of the ID and the new
+// watermark, the periods before the previous watermark being already
+// harvested. AdvanceWatermark requires event time bucketing, see
+// WithEventTimeBucketing.
+func (a *Aggregator) AdvanceWatermark(ctx context.Context, id [16]byte, ts time.Time) error {
+	if !a.cfg.EventTimeBucketing {
+		return errors.New("watermarks require event time bucketing")
+	}
+	if err := a.beginHarvest(ctx); err != nil {
+		return err
+	}
+	defer a.endHarvest()
+
+	a.mu.Lock()
+	prev := a.watermarks[id]
+	if !ts.After(prev) {
+		a.mu.Unlock()
+		return nil
+	}
+	if a.watermarks == nil {
+		a.watermarks = make(map[[16]byte]time.Time)
+	}
+	a.watermarks[id] = ts
+	// The pending writes are committed for the periods behind the
+	// watermark to be harvested.
+	err := a.flushBatches()
+	a.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, ivl := range a.cfg.AggregationIntervals {
+		// The periods of the interval ending at or before the watermark
+		// start before the watermark truncated to the interval, those
+		// ending at or before the previous watermark are harvested.
+		start, end := time.Unix(0, 0), ts.Truncate(ivl)
+		if !prev.IsZero() {
+			start = prev.Truncate(ivl)
+		}
+		if !start.Before(end) {
+			continue
+		}
+		var cmCount int
+		for _, shard := range a.shards {
+			n, err := a.harvestWatermark(ctx, shard, id, ivl, start, end)
+			cmCount += n
+			if err != nil {
+				errs = append(errs, fmt.Errorf(
+					"failed to harvest aggregated metrics for interval %s: %w", ivl, err,
+				))
+			}
+		}
+		a.cfg.Logger.Debug(
+			"Finished harvesting aggregated metrics behind watermark",
+			zap.Int("combined_metrics_successfully_harvested", cmCount),
+			zap.Duration("aggregation_interval_ns", ivl),
+			zap.Time("watermark", ts),
+		)
+	}
+	return errors.Join(errs...)
+}
+
+// harvestWatermark harvests the combined metrics of the ID for the
+// aggregation interval with processing times in the range [start, end)
+// from the shard, seeking to the ID in each stored period. Returns the
+// number of combined metrics successfully harvested and an error.
+func (a *Aggregator) harvestWatermark(
+	ctx context.Context,
+	shard *pebble.DB,
+	id [16]byte,
+	ivl time.Duration,
+	start, end time.Time,
+) (int, error) {
+	from := CombinedMetricsKey{Interval: ivl, ProcessingTime: start}
+	to := CombinedMetricsKey{Interval: ivl, ProcessingTime: end}
+	lb := make([]byte, CombinedMetricsKeyEncodedSize)
+	ub := make([]byte, CombinedMetricsKeyEncodedSize)
+	from.MarshalBinaryToSizedBuffer(lb)
+	to.MarshalBinaryToSizedBuffer(ub)
+
+	snap := shard.NewSnapshot()
+	defer snap.Close()
+	iter := snap.NewIter(&pebble.IterOptions{
+		LowerBound: lb,
+		UpperBound: ub,
+		KeyTypes:   pebble.IterKeyTypePointsOnly,
+	})
+	defer iter.Close()
+
+	batch := shard.NewBatch()
+	defer batch.Close()
+	ivlAttr := attribute.String(aggregationIvlKey, formatDuration(ivl))
+	var cmCount int
+	var checkpoint time.Time
+	var errs []error
+	for valid := iter.First(); valid; {
+		var cmk CombinedMetricsKey
+		if err := cmk.UnmarshalBinary(iter.Key()); err != nil {
+			errs = append(errs, fmt.Errorf("failed to unmarshal key: %w", err))
+			valid = iter.Next()
+			continue
+		}
+		if cmk.ID != id {
+			// Skip the combined metrics of the other IDs.
+			valid = iter.SeekGE(watermarkSeekKey(cmk, id))
+			continue
+		}
+		if err := a.harvestCombinedMetrics(ctx, cmk, iter.Value(), ivl, ivlAttr, false); err != nil {
+			errs = append(errs, err)
+		} else {
+			cmCount++
+			if cmk.ProcessingTime.After(checkpoint) {
+				checkpoint = cmk.ProcessingTime
+			}
+		}
+		if err := batch.Delete(iter.Key(), nil); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete harvested key: %w", err))
+		}
+		valid = iter.Next()
+	}
+	var err error
+	if iterErr := a.health.recordStorageError(iter.Error()); iterErr != nil {
+		err = fmt.Errorf("failed to iterate combined metrics: %w", iterErr)
+	}
+	if !batch.Empty() {
+		err = errors.Join(err, a.health.recordStorageError(batch.Commit(a.writeOptions)))
+	}
+	if len(errs) == 0 && !checkpoint.IsZero() {
+		err = errors.Join(err, a.health.recordStorageError(
+			a.recordCheckpoint(id, ivl, checkpoint),
+		))
+	}
+	if len(errs) > 0 {
+		err = errors.Join(err, fmt.Errorf(
+			"failed to process %d out of %d metrics:\n%w",
+			len(errs), cmCount+len(errs), errors.Join(errs...),
+		))
+	}
+	return cmCount, err
+}
+
+// advanceBackfillWatermark advances the watermark of the ID to the latest
+// event timestamp of the batch minus the allowed lateness, see
+// WithBackfill. The watermark is only advanced when it crosses a boundary
+// of the lowest aggregation interval, i.e. when it closes a period, so
+// that the batches within a period do not harvest.
+func (a *Aggregator) advanceBackfillWatermark(ctx context.Context, id [16]byte, b *modelpb.Batch) error {
+	var latest time.Time
+	for _, e := range *b {
+		if e.GetTimestamp() == nil {
+			continue
+		}
+		if ts := e.GetTimestamp().AsTime(); ts.After(latest) {
+			latest = ts
+		}
+	}
+	if latest.IsZero() {
+		return nil
+	}
+	ts := latest.Add(-a.cfg.AllowedLateness)
+	ivl := a.cfg.AggregationIntervals[0]
+	a.mu.Lock()
+	prev, ok := a.watermarks[id]
+	a.mu.Unlock()
+	if ok && !ts.Truncate(ivl).After(prev.Truncate(ivl)) {
+		return nil
+	}
+	return a.AdvanceWatermark(ctx, id, ts)
+}
+
+// watermarkSeekKey returns the key to seek to from the key of another ID
+// to find the combined metrics of the ID: the first key of the ID in the
+// period of the key, or the first key of the next period if the ID is
+// ordered before the key. The returned key is always after the key.
+func watermarkSeekKey(cmk CombinedMetricsKey, id [16]byte) []byte {
+	seek := CombinedMetricsKey{Interval: cmk.Interval, ProcessingTime: cmk.ProcessingTime, ID: id}
+	if bytes.Compare(id[:], cmk.ID[:]) < 0 {
+		seek = CombinedMetricsKey{Interval: cmk.Interval, ProcessingTime: cmk.ProcessingTime.Add(time.Second)}
+	}
+	key := make([]byte, CombinedMetricsKeyEncodedSize)
+	seek.MarshalBinaryToSizedBuffer(key)
+	// The key without the partition ID and the version sorts before all
+	// the partitions.
+	return key[:CombinedMetricsKeyEncodedSize-3]
+}
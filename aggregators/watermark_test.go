@@ -0,0 +1,237 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package aggregators
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+	"github.com/elastic/apm-data/model/modelpb"
+)
+
+func TestAdvanceWatermark(t *testing.T) {
+	ctx := context.Background()
+	type harvested struct {
+		id             [16]byte
+		processingTime time.Time
+		eventsTotal    float64
+	}
+	var results []harvested
+	agg, err := New(
+		WithDataDir(t.TempDir()),
+		WithLimits(Limits{
+			MaxServices:                           10,
+			MaxServiceInstanceGroupsPerService:    10,
+			MaxTransactionGroups:                  10,
+			MaxTransactionGroupsPerService:        10,
+			MaxServiceTransactionGroups:           10,
+			MaxServiceTransactionGroupsPerService: 10,
+			MaxSpanGroups:                         10,
+			MaxSpanGroupsPerService:               10,
+		}),
+		WithAggregationIntervals([]time.Duration{time.Minute}),
+		WithEventTimeBucketing(true),
+		WithProcessor(func(_ context.Context, cmk CombinedMetricsKey, cm *aggregationpb.CombinedMetrics, _ time.Duration) error {
+			results = append(results, harvested{
+				id:             cmk.ID,
+				processingTime: cmk.ProcessingTime,
+				eventsTotal:    cm.EventsTotal,
+			})
+			return nil
+		}),
+		WithLogger(zap.NewNop()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { agg.Close(ctx) })
+
+	id1 := EncodeToCombinedMetricsKeyID(t, "ab01")
+	id2 := EncodeToCombinedMetricsKeyID(t, "ab02")
+	// Historical data far behind the wall clock.
+	base := time.Unix(1600000000, 0).Truncate(time.Minute)
+	aggregate := func(id [16]byte, offsets ...time.Duration) {
+		batch := make(modelpb.Batch, len(offsets))
+		for i, offset := range offsets {
+			batch[i] = lateTestEvent(base.Add(offset))
+		}
+		require.NoError(t, agg.AggregateBatch(ctx, id, &batch))
+	}
+
+	require.NoError(t, agg.AdvanceWatermark(ctx, id1, base))
+	require.NoError(t, agg.AdvanceWatermark(ctx, id2, base))
+	aggregate(id1, 10*time.Second, 70*time.Second)
+	aggregate(id2, 10*time.Second)
+
+	require.NoError(t, agg.AdvanceWatermark(ctx, id1, base.Add(90*time.Second)))
+	assert.Equal(t, []harvested{{id: id1, processingTime: base, eventsTotal: 1}}, results)
+
+	// Events before the watermark are dropped, the period of the
+	// watermark is still open.
+	results = nil
+	aggregate(id1, 20*time.Second, 100*time.Second)
+	require.NoError(t, agg.AdvanceWatermark(ctx, id1, base.Add(2*time.Minute)))
+	assert.Equal(t, []harvested{{id: id1, processingTime: base.Add(time.Minute), eventsTotal: 2}}, results)
+
+	// The watermark never moves backwards.
+	results = nil
+	require.NoError(t, agg.AdvanceWatermark(ctx, id1, base))
+	assert.Empty(t, results)
+
+	require.NoError(t, agg.AdvanceWatermark(ctx, id2, base.Add(time.Minute)))
+	assert.Equal(t, []harvested{{id: id2, processingTime: base, eventsTotal: 1}}, results)
+
+	checkpoints, err := agg.Checkpoint()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []Checkpoint{
+		{ID: id1, Interval: time.Minute, ProcessingTime: base.Add(time.Minute)},
+		{ID: id2, Interval: time.Minute, ProcessingTime: base},
+	}, checkpoints)
+}
+
+func TestAdvanceWatermarkSeeksID(t *testing.T) {
+	ctx := context.Background()
+	type harvested struct {
+		id             [16]byte
+		processingTime time.Time
+	}
+	var results []harvested
+	agg, err := New(
+		WithDataDir(t.TempDir()),
+		WithAggregationIntervals([]time.Duration{time.Minute}),
+		WithPartitions(2),
+		WithEventTimeBucketing(true),
+		WithProcessor(func(_ context.Context, cmk CombinedMetricsKey, _ *aggregationpb.CombinedMetrics, _ time.Duration) error {
+			results = append(results, harvested{id: cmk.ID, processingTime: cmk.ProcessingTime})
+			return nil
+		}),
+		WithLogger(zap.NewNop()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { agg.Close(ctx) })
+
+	ids := [][16]byte{
+		EncodeToCombinedMetricsKeyID(t, "ab00"),
+		EncodeToCombinedMetricsKeyID(t, "ab01"),
+		EncodeToCombinedMetricsKeyID(t, "ab02"),
+	}
+	base := time.Unix(1600000000, 0).Truncate(time.Minute)
+	for _, id := range ids {
+		require.NoError(t, agg.AdvanceWatermark(ctx, id, base))
+	}
+	store := func(id [16]byte, processingTime time.Time) {
+		for p := uint16(0); p < 2; p++ {
+			cmk := CombinedMetricsKey{
+				Interval:       time.Minute,
+				ProcessingTime: processingTime,
+				ID:             id,
+				PartitionID:    p,
+			}
+			key := make([]byte, CombinedMetricsKeyEncodedSize)
+			require.NoError(t, cmk.MarshalBinaryToSizedBuffer(key))
+			value, err := NewTestCombinedMetrics(WithEventsTotal(1)).GetProto().MarshalVT()
+			require.NoError(t, err)
+			require.NoError(t, agg.db.Set(key, value, pebble.Sync))
+		}
+	}
+	for i := 0; i < 3; i++ {
+		for _, id := range ids {
+			store(id, base.Add(time.Duration(i)*time.Minute))
+		}
+	}
+	// Combined metrics of the ID before its watermark, as if left behind
+	// by a previous harvest, are outside the harvested range.
+	store(ids[1], base.Add(-time.Minute))
+
+	require.NoError(t, agg.AdvanceWatermark(ctx, ids[1], base.Add(2*time.Minute)))
+	assert.Equal(t, []harvested{
+		{id: ids[1], processingTime: base},
+		{id: ids[1], processingTime: base},
+		{id: ids[1], processingTime: base.Add(time.Minute)},
+		{id: ids[1], processingTime: base.Add(time.Minute)},
+	}, results)
+
+	results = nil
+	require.NoError(t, agg.AdvanceWatermark(ctx, ids[2], base.Add(time.Minute)))
+	assert.Equal(t, []harvested{
+		{id: ids[2], processingTime: base},
+		{id: ids[2], processingTime: base},
+	}, results)
+}
+
+func TestAdvanceWatermarkWithoutEventTimeBucketing(t *testing.T) {
+	agg, err := New(
+		WithDataDir(t.TempDir()),
+		WithProcessor(noOpProcessor()),
+		WithLogger(zap.NewNop()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { agg.Close(context.Background()) })
+
+	err = agg.AdvanceWatermark(context.Background(), EncodeToCombinedMetricsKeyID(t, "ab01"), time.Now())
+	assert.EqualError(t, err, "watermarks require event time bucketing")
+}
+
+func TestBackfill(t *testing.T) {
+	ctx := context.Background()
+	var harvested []time.Time
+	agg, err := New(
+		WithDataDir(t.TempDir()),
+		WithLimits(Limits{
+			MaxServices:                           10,
+			MaxServiceInstanceGroupsPerService:    10,
+			MaxTransactionGroups:                  10,
+			MaxTransactionGroupsPerService:        10,
+			MaxServiceTransactionGroups:           10,
+			MaxServiceTransactionGroupsPerService: 10,
+			MaxSpanGroups:                         10,
+			MaxSpanGroupsPerService:               10,
+		}),
+		WithAggregationIntervals([]time.Duration{time.Minute}),
+		WithEventTimeBucketing(true),
+		WithAllowedLateness(30*time.Second),
+		WithBackfill(true),
+		WithProcessor(func(_ context.Context, cmk CombinedMetricsKey, _ *aggregationpb.CombinedMetrics, _ time.Duration) error {
+			harvested = append(harvested, cmk.ProcessingTime)
+			return nil
+		}),
+		WithLogger(zap.NewNop()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { agg.Close(ctx) })
+
+	id := EncodeToCombinedMetricsKeyID(t, "ab01")
+	// Archived events of days ago.
+	base := time.Now().Add(-72 * time.Hour).Truncate(time.Minute)
+	aggregate := func(offsets ...time.Duration) {
+		batch := make(modelpb.Batch, len(offsets))
+		for i, offset := range offsets {
+			batch[i] = lateTestEvent(base.Add(offset))
+		}
+		require.NoError(t, agg.AggregateBatch(ctx, id, &batch))
+	}
+
+	aggregate(10*time.Second, 70*time.Second)
+	assert.Empty(t, harvested)
+
+	// The first period is closed once the watermark, lagging the latest
+	// event by the allowed lateness, passes its end.
+	aggregate(50*time.Second, 80*time.Second)
+	assert.Empty(t, harvested)
+	// The watermark is only advanced when it closes a period.
+	agg.mu.Lock()
+	assert.True(t, base.Add(40*time.Second).Equal(agg.watermarks[id]))
+	agg.mu.Unlock()
+	aggregate(95 * time.Second)
+	assert.Equal(t, []time.Time{base}, harvested)
+
+	aggregate(3 * time.Minute)
+	assert.Equal(t, []time.Time{base, base.Add(time.Minute)}, harvested)
+}
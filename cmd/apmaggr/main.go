@@ -0,0 +1,310 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+// Command apmaggr inspects the combined metrics stored in the data directory
+// of an aggregator.
+//
+// Usage:
+//
+//	apmaggr dump -data-dir <dir> [flags]
+//	apmaggr replay -data-dir <dir> -processor <events|metrics> [flags]
+//
+// The dump subcommand writes the stored combined metrics as newline
+// delimited JSON. The replay subcommand re-emits the stored combined
+// metrics through a processor, either converting them to APM events or
+// writing them as is. Both subcommands can be filtered by combined metrics
+// ID, aggregation interval and processing time range. The data directory
+// can be read from an aggregator configuration file, see package
+// github.com/elastic/apm-aggregation/aggregators/config, with -config.
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+	"github.com/elastic/apm-aggregation/aggregators"
+	"github.com/elastic/apm-aggregation/aggregators/config"
+)
+
+func main() {
+	if err := run(context.Background(), os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("usage: apmaggr <dump|replay> [flags]")
+	}
+	switch cmd, args := args[0], args[1:]; cmd {
+	case "dump":
+		return dump(args, out)
+	case "replay":
+		return replay(ctx, args, out)
+	default:
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+}
+
+// options holds the flags shared by all subcommands.
+type options struct {
+	config   string
+	dataDir  string
+	id       string
+	interval time.Duration
+	from, to string
+	pretty   bool
+
+	filter filter
+}
+
+func newFlagSet(name string, opts *options) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&opts.config, "config", "", "aggregator configuration file to read the data directory from")
+	fs.StringVar(&opts.dataDir, "data-dir", "", "aggregator data directory (required without -config)")
+	fs.StringVar(&opts.id, "id", "", "only include the hex encoded combined metrics ID")
+	fs.DurationVar(&opts.interval, "interval", 0, "only include the aggregation interval")
+	fs.StringVar(&opts.from, "from", "", "only include processing times at or after the RFC3339 time")
+	fs.StringVar(&opts.to, "to", "", "only include processing times before the RFC3339 time")
+	fs.BoolVar(&opts.pretty, "pretty", false, "pretty-print the JSON output")
+	return fs
+}
+
+func (opts *options) parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if opts.config != "" && opts.dataDir == "" {
+		cfg, err := config.Load(opts.config)
+		if err != nil {
+			return fmt.Errorf("invalid -config: %w", err)
+		}
+		opts.dataDir = cfg.DataDir
+	}
+	if opts.dataDir == "" {
+		return errors.New("-data-dir is required")
+	}
+	opts.filter.interval = opts.interval
+	if opts.id != "" {
+		id, err := hex.DecodeString(opts.id)
+		if err != nil || len(id) != len(opts.filter.id) {
+			return fmt.Errorf("invalid -id %q: must be %d hex encoded bytes", opts.id, len(opts.filter.id))
+		}
+		copy(opts.filter.id[:], id)
+		opts.filter.hasID = true
+	}
+	var err error
+	if opts.from != "" {
+		if opts.filter.from, err = time.Parse(time.RFC3339, opts.from); err != nil {
+			return fmt.Errorf("invalid -from: %w", err)
+		}
+	}
+	if opts.to != "" {
+		if opts.filter.to, err = time.Parse(time.RFC3339, opts.to); err != nil {
+			return fmt.Errorf("invalid -to: %w", err)
+		}
+	}
+	return nil
+}
+
+// filter matches combined metrics keys against the configured ID, interval
+// and processing time range. Zero values match all keys.
+type filter struct {
+	hasID    bool
+	id       [16]byte
+	interval time.Duration
+	from, to time.Time
+}
+
+func (f filter) match(k aggregators.CombinedMetricsKey) bool {
+	if f.hasID && k.ID != f.id {
+		return false
+	}
+	if f.interval != 0 && k.Interval != f.interval {
+		return false
+	}
+	if !f.from.IsZero() && k.ProcessingTime.Before(f.from) {
+		return false
+	}
+	if !f.to.IsZero() && !k.ProcessingTime.Before(f.to) {
+		return false
+	}
+	return true
+}
+
+// unlimited are the limits used for merging combined metrics which have not
+// been compacted yet, so that the stored metrics are shown without
+// overflowing regardless of the limits the aggregator was configured with.
+var unlimited = aggregators.Limits{
+	MaxServices:                           math.MaxInt,
+	MaxServiceInstanceGroupsPerService:    math.MaxInt,
+	MaxServiceInstanceGroups:              math.MaxInt,
+	MaxSpanGroups:                         math.MaxInt,
+	MaxSpanGroupsPerService:               math.MaxInt,
+	MaxTransactionGroups:                  math.MaxInt,
+	MaxTransactionGroupsPerService:        math.MaxInt,
+	MaxServiceTransactionGroups:           math.MaxInt,
+	MaxServiceTransactionGroupsPerService: math.MaxInt,
+	MaxErrorGroups:                        math.MaxInt,
+	MaxErrorGroupsPerService:              math.MaxInt,
+	MaxServiceGraphEdges:                  math.MaxInt,
+	MaxServiceGraphEdgesPerService:        math.MaxInt,
+}
+
+// each opens the data directory read-only and calls fn for each stored
+// combined metrics matching the filter.
+func each(
+	opts options,
+	fn func(aggregators.CombinedMetricsKey, *aggregationpb.CombinedMetrics) error,
+) (err error) {
+	store, err := aggregators.OpenReadOnly(opts.dataDir, aggregators.WithLimits(unlimited))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := store.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	it, err := store.NewIterator(aggregators.IteratorOptions{})
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	cm := aggregationpb.CombinedMetricsFromVTPool()
+	defer cm.ReturnToVTPool()
+	for valid := it.First(); valid; valid = it.Next() {
+		if !opts.filter.match(it.Key()) {
+			continue
+		}
+		cm.ResetVT()
+		if err := it.Value(cm); err != nil {
+			return err
+		}
+		if err := fn(it.Key(), cm); err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
+
+func dump(args []string, out io.Writer) error {
+	var opts options
+	if err := opts.parse(newFlagSet("dump", &opts), args); err != nil {
+		return err
+	}
+	enc := newEncoder(out, opts.pretty)
+	return each(opts, func(k aggregators.CombinedMetricsKey, cm *aggregationpb.CombinedMetrics) error {
+		metrics, err := protojson.Marshal(cm)
+		if err != nil {
+			return fmt.Errorf("failed to marshal combined metrics: %w", err)
+		}
+		return enc.Encode(struct {
+			Interval       string          `json:"interval"`
+			ProcessingTime time.Time       `json:"processing_time"`
+			PartitionID    uint16          `json:"partition_id"`
+			ID             string          `json:"id"`
+			Metrics        json.RawMessage `json:"metrics"`
+		}{
+			Interval:       k.Interval.String(),
+			ProcessingTime: k.ProcessingTime.UTC(),
+			PartitionID:    k.PartitionID,
+			ID:             hex.EncodeToString(k.ID[:]),
+			Metrics:        metrics,
+		})
+	})
+}
+
+func replay(ctx context.Context, args []string, out io.Writer) error {
+	var opts options
+	var processorName string
+	fs := newFlagSet("replay", &opts)
+	fs.StringVar(&processorName, "processor", "events",
+		"processor to replay through: events converts to APM events, metrics writes combined metrics")
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+	enc := newEncoder(out, opts.pretty)
+	var processor aggregators.Processor
+	switch processorName {
+	case "events":
+		processor = eventsProcessor(enc)
+	case "metrics":
+		processor = metricsProcessor(enc)
+	default:
+		return fmt.Errorf("unknown processor %q", processorName)
+	}
+	return each(opts, func(k aggregators.CombinedMetricsKey, cm *aggregationpb.CombinedMetrics) error {
+		return processor(ctx, k, cm, k.Interval)
+	})
+}
+
+// eventsProcessor returns a processor converting the combined metrics to
+// APM events and writing them to enc.
+func eventsProcessor(enc *json.Encoder) aggregators.Processor {
+	return func(
+		_ context.Context,
+		cmk aggregators.CombinedMetricsKey,
+		cm *aggregationpb.CombinedMetrics,
+		aggIvl time.Duration,
+	) error {
+		batch, err := aggregators.CombinedMetricsToBatch(cm, cmk.ProcessingTime, aggIvl)
+		if err != nil {
+			return fmt.Errorf("failed to convert combined metrics: %w", err)
+		}
+		if batch == nil {
+			return nil
+		}
+		for _, e := range *batch {
+			err := encodeProto(enc, e)
+			e.ReturnToVTPool()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// metricsProcessor returns a processor writing the combined metrics to enc.
+func metricsProcessor(enc *json.Encoder) aggregators.Processor {
+	return func(
+		_ context.Context,
+		_ aggregators.CombinedMetricsKey,
+		cm *aggregationpb.CombinedMetrics,
+		_ time.Duration,
+	) error {
+		return encodeProto(enc, cm)
+	}
+}
+
+func encodeProto(enc *json.Encoder, m proto.Message) error {
+	b, err := protojson.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	return enc.Encode(json.RawMessage(b))
+}
+
+func newEncoder(out io.Writer, pretty bool) *json.Encoder {
+	enc := json.NewEncoder(out)
+	if pretty {
+		enc.SetIndent("", "  ")
+	}
+	return enc
+}
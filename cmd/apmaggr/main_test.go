@@ -0,0 +1,146 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/elastic/apm-aggregation/aggregationpb"
+	"github.com/elastic/apm-aggregation/aggregators"
+)
+
+func TestDumpAndReplay(t *testing.T) {
+	dataDir := t.TempDir()
+	// Use processing times in the future so that the metrics are not
+	// harvested when the aggregator is closed.
+	processingTime := time.Now().Add(24 * time.Hour).Truncate(time.Hour).UTC()
+	writeCombinedMetrics(t, dataDir, []aggregators.CombinedMetricsKey{
+		{Interval: time.Minute, ProcessingTime: processingTime, ID: [16]byte{1}},
+		{Interval: time.Minute, ProcessingTime: processingTime.Add(time.Minute), ID: [16]byte{2}},
+		{Interval: time.Hour, ProcessingTime: processingTime, ID: [16]byte{1}},
+	})
+
+	for _, tc := range []struct {
+		name     string
+		args     []string
+		expected int
+	}{
+		{name: "all", args: []string{"dump"}, expected: 3},
+		{name: "id", args: []string{"dump", "-id", "01000000000000000000000000000000"}, expected: 2},
+		{name: "interval", args: []string{"dump", "-interval", "1m"}, expected: 2},
+		{name: "time_range", args: []string{
+			"dump",
+			"-from", processingTime.Add(time.Minute).Format(time.RFC3339),
+			"-to", processingTime.Add(time.Hour).Format(time.RFC3339),
+		}, expected: 1},
+		{name: "replay_metrics", args: []string{"replay", "-processor", "metrics"}, expected: 3},
+		// Each combined metrics converts to a transaction and a
+		// service_summary event.
+		{name: "replay_events", args: []string{"replay", "-interval", "1h"}, expected: 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			args := append(tc.args, "-data-dir", dataDir)
+			require.NoError(t, run(context.Background(), args, &out))
+			var lines int
+			scanner := bufio.NewScanner(&out)
+			for scanner.Scan() {
+				assert.True(t, json.Valid(scanner.Bytes()))
+				lines++
+			}
+			assert.Equal(t, tc.expected, lines)
+		})
+	}
+}
+
+func TestDumpWithConfig(t *testing.T) {
+	dataDir := t.TempDir()
+	processingTime := time.Now().Add(24 * time.Hour).Truncate(time.Hour).UTC()
+	writeCombinedMetrics(t, dataDir, []aggregators.CombinedMetricsKey{
+		{Interval: time.Minute, ProcessingTime: processingTime, ID: [16]byte{1}},
+	})
+	configFile := filepath.Join(t.TempDir(), "config.yml")
+	t.Setenv("APMAGGR_DATA_DIR", dataDir)
+	require.NoError(t, os.WriteFile(configFile, []byte("data_dir: ${APMAGGR_DATA_DIR}\n"), 0o600))
+
+	var out bytes.Buffer
+	require.NoError(t, run(context.Background(), []string{"dump", "-config", configFile}, &out))
+	assert.Equal(t, 1, bytes.Count(out.Bytes(), []byte("\n")))
+}
+
+func TestRunErrors(t *testing.T) {
+	for _, tc := range []struct {
+		args     []string
+		expected string
+	}{
+		{args: nil, expected: "usage: apmaggr <dump|replay> [flags]"},
+		{args: []string{"unknown"}, expected: `unknown command "unknown"`},
+		{args: []string{"dump"}, expected: "-data-dir is required"},
+		{args: []string{"dump", "-data-dir", "x", "-id", "zz"}, expected: `invalid -id "zz": must be 16 hex encoded bytes`},
+		{args: []string{"replay", "-data-dir", "x", "-processor", "x"}, expected: `unknown processor "x"`},
+	} {
+		err := run(context.Background(), tc.args, &bytes.Buffer{})
+		assert.EqualError(t, err, tc.expected)
+	}
+}
+
+func writeCombinedMetrics(t *testing.T, dataDir string, keys []aggregators.CombinedMetricsKey) {
+	agg, err := aggregators.New(
+		aggregators.WithDataDir(dataDir),
+		aggregators.WithAggregationIntervals([]time.Duration{time.Minute, time.Hour}),
+		aggregators.WithLimits(aggregators.Limits{
+			MaxServices:                        10,
+			MaxServiceInstanceGroupsPerService: 10,
+			MaxServiceInstanceGroups:           10,
+			MaxTransactionGroups:               10,
+			MaxTransactionGroupsPerService:     10,
+		}),
+		aggregators.WithLogger(zap.NewNop()),
+	)
+	require.NoError(t, err)
+	for _, k := range keys {
+		cm := &aggregationpb.CombinedMetrics{
+			EventsTotal: 1,
+			ServiceMetrics: []*aggregationpb.KeyedServiceMetrics{{
+				Key: &aggregationpb.ServiceAggregationKey{ServiceName: "svc"},
+				Metrics: &aggregationpb.ServiceMetrics{
+					ServiceInstanceMetrics: []*aggregationpb.KeyedServiceInstanceMetrics{{
+						Key: &aggregationpb.ServiceInstanceAggregationKey{},
+						Metrics: &aggregationpb.ServiceInstanceMetrics{
+							TransactionMetrics: []*aggregationpb.KeyedTransactionMetrics{{
+								Key: &aggregationpb.TransactionAggregationKey{
+									TransactionName: "txn",
+									TransactionType: "type",
+								},
+								Metrics: &aggregationpb.TransactionMetrics{
+									Histogram: &aggregationpb.HDRHistogram{
+										LowestTrackableValue:  1,
+										HighestTrackableValue: 3600000000,
+										SignificantFigures:    2,
+										Buckets:               []int32{1780},
+										Counts:                []int64{1000},
+									},
+								},
+							}},
+						},
+					}},
+				},
+			}},
+		}
+		require.NoError(t, agg.AggregateCombinedMetrics(context.Background(), k, cm))
+	}
+	require.NoError(t, agg.Close(context.Background()))
+}